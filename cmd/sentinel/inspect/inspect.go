@@ -17,16 +17,24 @@ func NewInspectCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "inspect [agent_id]",
 		Short: "Display detailed information about an agent",
-		Long:  `Display detailed information about an agent, including configuration, state, and capabilities.`,
-		Args:  cobra.ExactArgs(1),
+		Long: `Display detailed information about an agent, including configuration, state, and capabilities.
+
+With --provenance, the argument is a package file or extracted package
+directory: its provenance attestation is shown and verified against the
+package's files and the trust policy.`,
+		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			format, _ := cmd.Flags().GetString("format")
+			if showProvenance, _ := cmd.Flags().GetBool("provenance"); showProvenance {
+				return runInspectProvenance(args[0], format, cmd.Flags().Changed("format"))
+			}
 			return runInspect(args[0], format)
 		},
 	}
 
 	// Add flags
 	cmd.Flags().StringP("format", "f", "yaml", "Format the output (json or yaml)")
+	cmd.Flags().Bool("provenance", false, "Show and verify the provenance attestation of a package")
 
 	return cmd
 }
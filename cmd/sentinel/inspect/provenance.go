@@ -0,0 +1,249 @@
+package inspect
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v2"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/registry/format"
+	packages "github.com/satishgonella2024/sentinelstacks/internal/registry/package"
+	"github.com/satishgonella2024/sentinelstacks/internal/registry/provenance"
+	"github.com/satishgonella2024/sentinelstacks/internal/registry/security"
+)
+
+// runInspectProvenance shows the provenance attestation of a package file or
+// extracted package directory and verifies it: its signatures against the
+// trust policy and its subjects against the package's files. With an
+// explicit output format the statement is printed as JSON or YAML instead.
+func runInspectProvenance(packagePath, outputFormat string, raw bool) error {
+	dir, pkg, cleanup, err := openPackage(packagePath)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	envelope, err := provenance.Read(filepath.Join(dir, provenance.FileName))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("package %s has no provenance attestation", packagePath)
+		}
+		return err
+	}
+	statement, err := envelope.Statement()
+	if err != nil {
+		return err
+	}
+
+	var problems []string
+
+	// The manifest lists the attestation, so its hash is checked with the
+	// other files
+	_, failures, err := pkg.VerifyIntegrity(dir)
+	if err != nil {
+		return fmt.Errorf("failed to verify package integrity: %w", err)
+	}
+	problems = append(problems, failures...)
+
+	files := make(map[string]string, len(pkg.Manifest.Files))
+	for _, file := range pkg.Manifest.Files {
+		if file.Path != provenance.FileName {
+			files[file.Path] = file.SHA256
+		}
+	}
+	problems = append(problems, statement.CheckSubjects(files)...)
+
+	// The attestation must be about this package, whose manifest also
+	// chooses the trust rule: the attestation's own claim could pick a
+	// laxer one
+	attested := statement.Predicate.Package
+	if attested.Name != pkg.Manifest.Name || attested.Version != pkg.Manifest.Version {
+		problems = append(problems, fmt.Sprintf("attestation is for %s:%s, not %s:%s", attested.Name, attested.Version, pkg.Manifest.Name, pkg.Manifest.Version))
+	}
+
+	// Check the signatures against the trust policy
+	policy, keyManager, err := security.LoadTrust(viper.GetString("security.keys_dir"), viper.GetString("security.trust_policy"))
+	if err != nil {
+		return err
+	}
+	ref := security.Reference{
+		Registry: viper.GetString("registry.url"),
+		Name:     pkg.Manifest.Name,
+		Version:  pkg.Manifest.Version,
+	}
+	verified := envelope.Verify(keyManager)
+	if len(envelope.Signatures) == 0 {
+		problems = append(problems, "attestation is not signed")
+	}
+	if trustErr := policy.Evaluate(keyManager, ref, envelope.Payload, envelope.Signatures).Err(ref); trustErr != nil {
+		if policy.RuleFor(ref).Mode == security.TrustModeWarn {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", trustErr)
+		} else {
+			problems = append(problems, trustErr.Error())
+		}
+	}
+
+	if raw {
+		if err := printStatement(statement, outputFormat); err != nil {
+			return err
+		}
+	} else {
+		printProvenance(statement, envelope, verified)
+	}
+
+	if len(problems) > 0 {
+		fmt.Fprintln(os.Stderr, "\nProvenance verification failed:")
+		for _, problem := range problems {
+			fmt.Fprintf(os.Stderr, "  %s\n", problem)
+		}
+		return fmt.Errorf("provenance of %s could not be verified", packagePath)
+	}
+	if !raw {
+		fmt.Println("\nProvenance verified")
+	}
+	return nil
+}
+
+// openPackage returns the directory holding a package's files and its
+// manifest. Package files are extracted to a temporary directory, which
+// cleanup removes.
+func openPackage(packagePath string) (string, *packages.SentinelPackage, func(), error) {
+	info, err := os.Stat(packagePath)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("package not found: %w", err)
+	}
+
+	pkg := &packages.SentinelPackage{}
+	if info.IsDir() {
+		data, err := os.ReadFile(filepath.Join(packagePath, format.ManifestFileName))
+		if err != nil {
+			return "", nil, nil, fmt.Errorf("failed to read package manifest: %w", err)
+		}
+		if err := json.Unmarshal(data, &pkg.Manifest); err != nil {
+			return "", nil, nil, fmt.Errorf("failed to parse package manifest: %w", err)
+		}
+		return packagePath, pkg, func() {}, nil
+	}
+
+	tempDir, err := os.MkdirTemp("", "sentinel-inspect-")
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	cleanup := func() { os.RemoveAll(tempDir) }
+	if err := pkg.Unpackage(packagePath, tempDir); err != nil {
+		cleanup()
+		return "", nil, nil, fmt.Errorf("failed to extract package: %w", err)
+	}
+	return tempDir, pkg, cleanup, nil
+}
+
+// printStatement prints the statement as JSON or YAML
+func printStatement(statement *provenance.Statement, outputFormat string) error {
+	data, err := json.MarshalIndent(statement, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal provenance to JSON: %w", err)
+	}
+
+	switch strings.ToLower(outputFormat) {
+	case "json":
+	case "yaml":
+		// Go through JSON so the field names match the JSON form
+		var fields interface{}
+		if err := yaml.Unmarshal(data, &fields); err != nil {
+			return fmt.Errorf("failed to convert provenance to YAML: %w", err)
+		}
+		if data, err = yaml.Marshal(fields); err != nil {
+			return fmt.Errorf("failed to marshal provenance to YAML: %w", err)
+		}
+	default:
+		return fmt.Errorf("unsupported format: %s (use json or yaml)", outputFormat)
+	}
+
+	fmt.Fprintln(os.Stdout, string(data))
+	return nil
+}
+
+// printProvenance prints a readable summary of the attestation
+func printProvenance(statement *provenance.Statement, envelope *provenance.Envelope, verified []error) {
+	p := statement.Predicate
+	fmt.Printf("Package:  %s:%s (%s)\n", p.Package.Name, p.Package.Version, p.Package.Type)
+	if p.Package.Author != "" {
+		fmt.Printf("Author:   %s\n", p.Package.Author)
+	}
+	fmt.Printf("Built:    %s by %s on %s (%s/%s, %s)\n",
+		p.Build.FinishedAt.Format("2006-01-02 15:04:05 MST"), p.Build.User, p.Build.Host, p.Build.OS, p.Build.Arch, p.Build.GoVersion)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	if len(p.Agents) > 0 {
+		fmt.Fprintln(w, "\nAGENTS\t")
+		fmt.Fprintln(w, "NAME\tSOURCE\tMODEL\tTOOLS")
+		for _, a := range p.Agents {
+			source := a.Source
+			if source == "" {
+				source = a.Uses + " (unresolved)"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", a.Name, source, a.BaseModel, strings.Join(a.Tools, ", "))
+		}
+	}
+
+	if len(p.Models) > 0 {
+		fmt.Fprintf(w, "\nMODELS\t%s\n", strings.Join(p.Models, ", "))
+	}
+
+	if len(p.Tools) > 0 {
+		fmt.Fprintln(w, "\nTOOLS\t")
+		fmt.Fprintln(w, "NAME\tPERMISSION\tAGENTS")
+		for _, tool := range p.Tools {
+			permission := string(tool.Permission)
+			if permission == "" {
+				permission = "unknown"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", tool.Name, permission, strings.Join(tool.Agents, ", "))
+		}
+	}
+
+	if len(p.Prompts) > 0 {
+		fmt.Fprintln(w, "\nPROMPTS\t")
+		fmt.Fprintln(w, "AGENT\tKEY\tSHA256")
+		for _, prompt := range p.Prompts {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", prompt.Agent, prompt.Key, prompt.SHA256)
+		}
+	}
+
+	if len(p.Dependencies) > 0 {
+		fmt.Fprintln(w, "\nDEPENDENCIES\t")
+		fmt.Fprintln(w, "NAME\tVERSION\tTYPE\tDIGEST")
+		for _, dep := range p.Dependencies {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", dep.Name, dep.Version, dep.Type, dep.Digest)
+		}
+	}
+
+	fmt.Fprintln(w, "\nFILES\t")
+	fmt.Fprintln(w, "PATH\tSHA256")
+	for _, subject := range statement.Subject {
+		fmt.Fprintf(w, "%s\t%s\n", subject.Name, subject.Digest["sha256"])
+	}
+
+	fmt.Fprintln(w, "\nSIGNATURES\t")
+	fmt.Fprintln(w, "SIGNER\tKEY\tSTATUS")
+	for i, signature := range envelope.Signatures {
+		status := "valid"
+		if verified[i] != nil {
+			status = verified[i].Error()
+		}
+		signer, key := signature.Info.Signer, signature.Info.KeyID
+		if signature.Certificate != nil {
+			signer, key = signature.Certificate.Identity, "keyless ("+signature.Certificate.Issuer+")"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", signer, key, status)
+	}
+
+	w.Flush()
+}
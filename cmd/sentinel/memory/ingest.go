@@ -0,0 +1,182 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/memory"
+	"github.com/satishgonella2024/sentinelstacks/pkg/embedding"
+	"github.com/satishgonella2024/sentinelstacks/pkg/ingest"
+	"github.com/satishgonella2024/sentinelstacks/pkg/services"
+	"github.com/satishgonella2024/sentinelstacks/pkg/types"
+)
+
+// newMemoryIngestCmd creates a command to ingest documents into an agent's
+// vector memory
+func newMemoryIngestCmd() *cobra.Command {
+	var (
+		chunkSize           int
+		chunkOverlap        int
+		embeddingProvider   string
+		embeddingModel      string
+		embeddingDimensions int
+		force               bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "ingest <agent> <path...>",
+		Short: "Ingest documents into an agent's vector memory",
+		Long: `Split documents into chunks, embed them and store them in an agent's vector
+memory, where retrieval finds them. Paths may be files or directories to walk
+for Markdown, plain text, HTML, PDF and source code files; hidden files and
+dependency directories are skipped.
+
+Ingesting again is incremental: unchanged files are skipped, only the new
+chunks of changed files are embedded, and files removed from an ingested
+directory are removed from memory. Use the embedding settings the agent's
+Sentinelfile retrieves with.`,
+		Example: `  sentinel memory ingest research-assistant ./docs
+  sentinel memory ingest research-assistant handbook.pdf --chunk-size 1500 --chunk-overlap 300
+  sentinel memory ingest research-assistant ./docs --embedding-provider ollama --embedding-model nomic-embed-text`,
+		Args: cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			agentName, paths := args[0], args[1:]
+
+			options := services.IngestOptions{
+				Chunks: ingest.ChunkConfig{Size: chunkSize, Overlap: chunkOverlap},
+				Force:  force,
+				Embedding: types.EmbeddingConfig{
+					Provider:   embeddingProvider,
+					Model:      embeddingModel,
+					Dimensions: embeddingDimensions,
+				},
+			}
+			if err := options.Chunks.Validate(); err != nil {
+				return err
+			}
+
+			embedder, err := embedding.New(options.Embedding)
+			if err != nil {
+				return fmt.Errorf("failed to create embedder: %w", err)
+			}
+
+			ctx := context.Background()
+			memoryService := services.NewMemoryService(memory.NewDefaultFactory())
+
+			var documents, unchanged, failed int
+			var total services.IngestResult
+			seen := make(map[string]bool)
+			for _, root := range paths {
+				err := ingest.Walk(root, func(path string) error {
+					doc, err := ingest.Load(path)
+					if err != nil {
+						fmt.Printf("Warning: Skipping %s: %v\n", path, err)
+						failed++
+						return nil
+					}
+					seen[doc.Source] = true
+
+					result, err := memoryService.IngestDocument(ctx, agentName, doc, embedder, options)
+					if err != nil {
+						return fmt.Errorf("failed to ingest %s: %w", path, err)
+					}
+
+					documents++
+					total.Kept += result.Kept
+					if result.Unchanged {
+						unchanged++
+						return nil
+					}
+					total.Stored += result.Stored
+					total.Removed += result.Removed
+					fmt.Printf("Ingested %s (%d chunks stored, %d kept, %d removed)\n", path, result.Stored, result.Kept, result.Removed)
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+			}
+
+			// Forget documents deleted from the directories ingested
+			forgotten, err := forgetMissing(ctx, memoryService, agentName, paths, seen)
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Ingested %d documents into %s: %d chunks stored, %d kept, %d removed; %d documents unchanged",
+				documents, agentName, total.Stored, total.Kept, total.Removed+forgotten, unchanged)
+			if failed > 0 {
+				fmt.Printf(", %d skipped", failed)
+			}
+			fmt.Println()
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVar(&chunkSize, "chunk-size", ingest.DefaultChunkSize, "Most characters in a chunk")
+	cmd.Flags().IntVar(&chunkOverlap, "chunk-overlap", ingest.DefaultChunkOverlap, "Characters each chunk repeats from the last")
+	cmd.Flags().StringVar(&embeddingProvider, "embedding-provider", embedding.ProviderLocal, "Embedding provider (ollama, openai, local)")
+	cmd.Flags().StringVar(&embeddingModel, "embedding-model", "", "Embedding model")
+	cmd.Flags().IntVar(&embeddingDimensions, "embedding-dimensions", 0, "Embedding dimensions, when the model's are not known")
+	cmd.Flags().BoolVar(&force, "force", false, "Re-embed documents even when unchanged")
+
+	return cmd
+}
+
+// forgetMissing removes the documents ingested from under the given
+// directories that no longer exist, returning how many chunks were removed
+func forgetMissing(ctx context.Context, memoryService *services.MemoryService, agentName string, paths []string, seen map[string]bool) (int, error) {
+	var dirs []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil || !info.IsDir() {
+			continue
+		}
+		dir, err := filepath.Abs(path)
+		if err != nil {
+			return 0, fmt.Errorf("failed to resolve %s: %w", path, err)
+		}
+		dirs = append(dirs, dir+string(filepath.Separator))
+	}
+	if len(dirs) == 0 {
+		return 0, nil
+	}
+
+	sources, err := memoryService.IngestedDocuments(ctx, agentName)
+	if err != nil {
+		return 0, err
+	}
+
+	var removed int
+	for _, source := range sources {
+		if seen[source] {
+			continue
+		}
+		under := false
+		for _, dir := range dirs {
+			if strings.HasPrefix(source, dir) {
+				under = true
+				break
+			}
+		}
+		if !under {
+			continue
+		}
+		if _, err := os.Stat(source); !os.IsNotExist(err) {
+			continue
+		}
+
+		count, err := memoryService.ForgetDocument(ctx, agentName, source)
+		if err != nil {
+			return 0, fmt.Errorf("failed to forget %s: %w", source, err)
+		}
+		fmt.Printf("Removed %s (%d chunks)\n", source, count)
+		removed += count
+	}
+	return removed, nil
+}
@@ -4,8 +4,6 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
-	"time"
 
 	"github.com/spf13/cobra"
 	
@@ -25,6 +23,8 @@ func NewMemoryCmd() *cobra.Command {
 	cmd.AddCommand(newMemoryListCmd())
 	cmd.AddCommand(newMemoryCleanCmd())
 	cmd.AddCommand(newMemoryInfoCmd())
+	cmd.AddCommand(newMemoryReindexCmd())
+	cmd.AddCommand(newMemoryIngestCmd())
 	
 	return cmd
 }
@@ -0,0 +1,69 @@
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/memory"
+	vectormemory "github.com/satishgonella2024/sentinelstacks/pkg/memory"
+)
+
+// newMemoryReindexCmd creates a command to rebuild vector indexes
+func newMemoryReindexCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "reindex [path...]",
+		Short: "Rebuild vector store indexes",
+		Long: `Rebuild the HNSW index kept next to each SQLite vector store from the
+embeddings in the store, and its keyword index from its texts. Paths may be
+vector store databases or directories to search for them, and default to the
+memory storage directory.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			paths := args
+			if len(paths) == 0 {
+				memoryPath, err := memory.DefaultMemoryPath()
+				if err != nil {
+					return fmt.Errorf("failed to get memory path: %w", err)
+				}
+				paths = []string{memoryPath}
+			}
+
+			var stores, vectors int
+			for _, root := range paths {
+				err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+					if err != nil {
+						return err
+					}
+					if info.IsDir() || !strings.HasSuffix(path, ".db") {
+						return nil
+					}
+
+					count, err := vectormemory.ReindexSQLiteVectorStore(path)
+					if errors.Is(err, vectormemory.ErrNotVectorStore) {
+						return nil
+					}
+					if err != nil {
+						return fmt.Errorf("failed to reindex %s: %w", path, err)
+					}
+
+					fmt.Printf("Reindexed %s (%d vectors)\n", path, count)
+					stores++
+					vectors += count
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+			}
+
+			fmt.Printf("Reindexed %d vector stores holding %d vectors\n", stores, vectors)
+			return nil
+		},
+	}
+
+	return cmd
+}
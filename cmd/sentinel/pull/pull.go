@@ -1,24 +1,40 @@
 package pull
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/registry"
+	"github.com/satishgonella2024/sentinelstacks/internal/registry/client"
+	"github.com/satishgonella2024/sentinelstacks/internal/registry/format"
+	packages "github.com/satishgonella2024/sentinelstacks/internal/registry/package"
+	"github.com/satishgonella2024/sentinelstacks/internal/registry/security"
+	"github.com/satishgonella2024/sentinelstacks/pkg/agent"
 )
 
 // NewPullCmd creates the pull command
 func NewPullCmd() *cobra.Command {
 	var (
-		force    bool
-		registry string
+		force        bool
+		registryFlag string
 	)
 
 	cmd := &cobra.Command{
 		Use:   "pull [image_name]",
 		Short: "Pull an agent image from a registry",
-		Long:  `Pull an agent image from a remote registry to your local environment`,
+		Long: `Pull an agent image from a remote registry to your local environment.
+
+The image's signatures are checked against the trust policy
+(~/.sentinel/trust-policy.yaml); in enforce mode an image without a
+trusted signature is not installed.`,
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			imageName := args[0]
@@ -26,25 +42,105 @@ func NewPullCmd() *cobra.Command {
 			// Parse image name and tag
 			name, tag := parseImageName(imageName)
 			
+			// Check whether the image is already available
+			localRegistry, err := registry.GetLocalRegistry()
+			if err != nil {
+				return fmt.Errorf("failed to get local registry: %w", err)
+			}
+			if _, err := localRegistry.Get(name, tag); err == nil && !force {
+				fmt.Printf("Image '%s:%s' is already available locally; use --force to pull it again\n", name, tag)
+				return nil
+			}
+			
 			// Determine registry URL
-			registryURL := registry
+			registryURL := registryFlag
 			if registryURL == "" {
 				registryURL = viper.GetString("registry.default")
-				if registryURL == "" {
-					registryURL = "sentinel.registry.ai" // Default registry
-				}
+			}
+			if registryURL == "" {
+				registryURL = viper.GetString("registry.url")
+			}
+			if registryURL == "" {
+				registryURL = "https://registry.sentinelstacks.io" // Default registry
+			}
+			if !strings.Contains(registryURL, "://") {
+				registryURL = "https://" + registryURL
 			}
 			
 			fmt.Printf("Pulling image '%s:%s' from registry '%s'\n", name, tag, registryURL)
 			
-			// TODO: Implement actual image pulling logic here
+			// Download the image package
+			tempDir, err := os.MkdirTemp("", "sentinel-pull-")
+			if err != nil {
+				return fmt.Errorf("failed to create temp directory: %w", err)
+			}
+			defer os.RemoveAll(tempDir)
+			
+			ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
+			defer cancel()
+			
+			registryClient := client.NewRegistryClient(registryURL, viper.GetString("registry.auth_token"))
+			packagePath := filepath.Join(tempDir, format.GetDefaultFilename(strings.ReplaceAll(name, "/", "-"), tag, "agent"))
+			if err := registryClient.PullPackage(ctx, name, tag, packagePath); err != nil {
+				return fmt.Errorf("failed to pull image: %w", err)
+			}
+			
+			// Check the manifest's signatures before writing any file
+			pkg := &packages.SentinelPackage{}
+			if err := pkg.ReadManifest(packagePath); err != nil {
+				return fmt.Errorf("failed to read image manifest: %w", err)
+			}
+			policy, keyManager, err := security.LoadTrust(viper.GetString("security.keys_dir"), viper.GetString("security.trust_policy"))
+			if err != nil {
+				return err
+			}
+			ref := security.Reference{Registry: registryURL, Name: name, Version: tag}
+			if err := pkg.VerifyTrust(policy, keyManager, ref); err != nil {
+				return err
+			}
+			
+			// Extract it and check the files against the manifest
+			extractDir := filepath.Join(tempDir, "extract")
+			if err := pkg.Unpackage(packagePath, extractDir); err != nil {
+				return fmt.Errorf("failed to extract image: %w", err)
+			}
+			valid, failures, err := pkg.VerifyIntegrity(extractDir)
+			if err != nil {
+				return fmt.Errorf("image verification failed: %w", err)
+			}
+			if !valid {
+				return fmt.Errorf("image integrity check failed: %v", failures)
+			}
 			
-			// Simulate download progress
-			fmt.Println("Downloading agent definition...")
-			fmt.Println("Downloading capabilities...")
-			fmt.Println("Downloading tools configuration...")
+			// Install the image in the local registry
+			image, err := loadPackageImage(pkg, extractDir)
+			if err != nil {
+				return err
+			}
+			image.Name = name
+			image.Tag = tag
+			if err := localRegistry.Save(image); err != nil {
+				return fmt.Errorf("failed to save image: %w", err)
+			}
 			
-			fmt.Printf("Successfully pulled image '%s:%s'\n", name, tag)
+			// Record the signatures so 'sentinel run' can check the image
+			// against the trust policy in force when it runs
+			record, err := pkg.TrustRecord(ref)
+			if err != nil {
+				return err
+			}
+			if err := record.AddFile(localRegistry.Path(name, tag)); err != nil {
+				return err
+			}
+			trustStore, err := security.DefaultTrustStore()
+			if err != nil {
+				return err
+			}
+			if err := trustStore.Save(record); err != nil {
+				return err
+			}
+			
+			fmt.Printf("Successfully pulled image '%s:%s' (%d signature(s))\n", name, tag, len(pkg.Manifest.Signatures))
 			fmt.Println("Image is now available for local use")
 			
 			return nil
@@ -52,7 +148,7 @@ func NewPullCmd() *cobra.Command {
 	}
 
 	cmd.Flags().BoolVar(&force, "force", false, "Force pull even if image exists locally")
-	cmd.Flags().StringVar(&registry, "registry", "", "Registry URL to pull from")
+	cmd.Flags().StringVar(&registryFlag, "registry", "", "Registry URL to pull from")
 
 	return cmd
 }
@@ -74,3 +170,27 @@ func parseImageName(imageName string) (string, string) {
 	
 	return name, tag
 }
+
+// imageFileName is the file holding the agent image in an image package
+const imageFileName = "image.json"
+
+// loadPackageImage reads the agent image from an extracted package
+func loadPackageImage(pkg *packages.SentinelPackage, extractDir string) (*registry.Image, error) {
+	for _, file := range pkg.Manifest.Files {
+		if filepath.Base(file.Path) != imageFileName {
+			continue
+		}
+		
+		data, err := os.ReadFile(filepath.Join(extractDir, file.Path))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read image: %w", err)
+		}
+		var agentImage agent.Image
+		if err := json.Unmarshal(data, &agentImage); err != nil {
+			return nil, fmt.Errorf("failed to parse image: %w", err)
+		}
+		return registry.ConvertFromAgentImage(&agentImage), nil
+	}
+	
+	return nil, fmt.Errorf("package %s contains no agent image (%s)", pkg.Manifest.Name, imageFileName)
+}
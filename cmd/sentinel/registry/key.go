@@ -0,0 +1,191 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/registry/security"
+)
+
+// defaultKeysDir returns the default signing keys directory
+func defaultKeysDir() string {
+	dir, err := security.DefaultKeysDir()
+	if err != nil {
+		return "keys"
+	}
+	return dir
+}
+
+// NewKeyCmd creates a new key command for managing signing keys and the
+// keys trusted by the trust policy
+func NewKeyCmd() *cobra.Command {
+	var keysDir string
+
+	cmd := &cobra.Command{
+		Use:   "key",
+		Short: "Manage signing keys",
+		Long: `Generate, list, import and export the keys packages are signed and verified with.
+
+Keys are referenced by ID or fingerprint in the trust policy
+(~/.sentinel/trust-policy.yaml), which decides which signatures are trusted
+when pulling and running packages.`,
+	}
+
+	cmd.PersistentFlags().StringVar(&keysDir, "keys-dir", defaultKeysDir(), "Keys directory")
+
+	generateCmd := &cobra.Command{
+		Use:   "generate [key-id]",
+		Short: "Generate a signing key pair",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			km, err := security.NewKeyManager(keysDir)
+			if err != nil {
+				return err
+			}
+
+			keyID := args[0]
+			if _, err := os.Stat(filepath.Join(keysDir, keyID+".pub")); err == nil {
+				return fmt.Errorf("key %s already exists in %s", keyID, keysDir)
+			}
+
+			keyType, _ := cmd.Flags().GetString("type")
+			if err := km.GenerateKey(keyID, keyType); err != nil {
+				return err
+			}
+
+			keyFingerprint, err := km.Fingerprint(keyID)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Generated key %s (%s)\n", keyID, keyFingerprint)
+			return nil
+		},
+	}
+	generateCmd.Flags().String("type", security.KeyTypeEd25519, "Key type: ed25519 or rsa")
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List keys",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			km, err := security.NewKeyManager(keysDir)
+			if err != nil {
+				return err
+			}
+			keys, err := km.ListKeys()
+			if err != nil {
+				return err
+			}
+
+			if len(keys) == 0 {
+				fmt.Printf("No keys in %s\n", keysDir)
+				return nil
+			}
+			fmt.Printf("%-20s %-8s %-8s %s\n", "ID", "TYPE", "PRIVATE", "FINGERPRINT")
+			for _, key := range keys {
+				fmt.Printf("%-20s %-8s %-8v %s\n", key.ID, key.Type, key.HasPrivate, key.Fingerprint)
+			}
+			return nil
+		},
+	}
+
+	importCmd := &cobra.Command{
+		Use:   "import [key-id] [public-key-file]",
+		Short: "Import a public key to verify signatures with",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			km, err := security.NewKeyManager(keysDir)
+			if err != nil {
+				return err
+			}
+			if err := km.ImportPublicKey(args[0], args[1]); err != nil {
+				return fmt.Errorf("failed to import key: %w", err)
+			}
+
+			keyFingerprint, err := km.Fingerprint(args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Imported key %s (%s)\n", args[0], keyFingerprint)
+			return nil
+		},
+	}
+
+	exportCmd := &cobra.Command{
+		Use:   "export [key-id]",
+		Short: "Print a public key",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			km, err := security.NewKeyManager(keysDir)
+			if err != nil {
+				return err
+			}
+			publicKey, err := km.ExportPublicKey(args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Print(string(publicKey))
+			return nil
+		},
+	}
+
+	issuerCmd := &cobra.Command{
+		Use:   "import-issuer",
+		Short: "Import the keyless signing issuer key of a registry",
+		Long: `Fetch the key a registry certifies keyless signing keys with and add it to the
+keys directory, so keyless signatures from that registry can be verified.
+Compare the printed fingerprint with one obtained out of band before
+trusting the issuer in the trust policy.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			registryURL, _ := cmd.Flags().GetString("registry")
+			if registryURL == "" {
+				registryURL = os.Getenv("SENTINEL_REGISTRY_URL")
+			}
+			if registryURL == "" {
+				registryURL = "https://registry.sentinelstacks.io"
+			}
+
+			km, err := security.NewKeyManager(keysDir)
+			if err != nil {
+				return err
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+
+			info, err := security.NewRemoteIssuer(registryURL, "").Issuer(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to fetch issuer key: %w", err)
+			}
+			// Registries may all name their issuer key alike, so store it
+			// under the registry's host
+			keyID := strings.NewReplacer(":", "_", "/", "_").Replace(security.RegistryHost(registryURL)) + "-issuer"
+			if err := km.ImportPublicKeyData(keyID, []byte(info.PublicKey)); err != nil {
+				return fmt.Errorf("failed to import issuer key: %w", err)
+			}
+			keyFingerprint, err := km.Fingerprint(keyID)
+			if err != nil {
+				return err
+			}
+			if keyFingerprint != info.Fingerprint {
+				return fmt.Errorf("issuer key fingerprint %s does not match advertised %s", keyFingerprint, info.Fingerprint)
+			}
+
+			fmt.Printf("Imported issuer key %s as %s (%s) from %s\n", info.KeyID, keyID, keyFingerprint, registryURL)
+			fmt.Printf("Trust it for a registry in the trust policy with:\n\n")
+			fmt.Printf("  policies:\n    - registry: %s\n      keyless:\n        - issuer: %s\n", security.RegistryHost(registryURL), keyFingerprint)
+			return nil
+		},
+	}
+	issuerCmd.Flags().String("registry", "", "Registry URL (default: https://registry.sentinelstacks.io)")
+
+	cmd.AddCommand(generateCmd, listCmd, importCmd, exportCmd, issuerCmd)
+	return cmd
+}
@@ -68,6 +68,11 @@ func NewPullCmd() *cobra.Command {
 				return fmt.Errorf("failed to pull package: %w", err)
 			}
 
+			if stats := registryClient.LastTransfer; stats != nil {
+				fmt.Printf("Downloaded %d of %d blobs (%s); %s already cached\n",
+					stats.Transferred, stats.Blobs, formatSize(stats.Bytes), formatSize(stats.SkippedBytes))
+			}
+
 			// Move to output path if specified
 			if outputPath != "" {
 				// TODO: Implement moving to output path
@@ -99,6 +99,10 @@ func NewPushCmd() *cobra.Command {
 				return fmt.Errorf("failed to push package: %w", err)
 			}
 
+			if stats := registryClient.LastTransfer; stats != nil {
+				fmt.Printf("Uploaded %d of %d blobs (%s); %s already in the registry\n",
+					stats.Transferred, stats.Blobs, formatSize(stats.Bytes), formatSize(stats.SkippedBytes))
+			}
 			fmt.Println("Package pushed successfully!")
 			return nil
 		},
@@ -112,3 +116,20 @@ func NewPushCmd() *cobra.Command {
 
 	return cmd
 }
+
+// formatSize formats a byte size into a human-readable string
+func formatSize(bytes int64) string {
+	const (
+		MB = 1024 * 1024
+		KB = 1024
+	)
+
+	switch {
+	case bytes >= MB:
+		return fmt.Sprintf("%.2f MB", float64(bytes)/float64(MB))
+	case bytes >= KB:
+		return fmt.Sprintf("%.2f KB", float64(bytes)/float64(KB))
+	default:
+		return fmt.Sprintf("%d bytes", bytes)
+	}
+}
@@ -10,7 +10,7 @@ func NewRegistryCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "registry",
 		Short: "Manage agent registries",
-		Long:  `Commands for managing agent registries, including push, pull, login, and logout.`,
+		Long:  `Commands for managing agent registries, including push, pull, login, and logout, and for running a self-hosted registry.`,
 	}
 	// Add subcommands
 	cmd.AddCommand(NewLoginCmd())
@@ -19,5 +19,8 @@ func NewRegistryCmd() *cobra.Command {
 	cmd.AddCommand(NewPullCmd())
 	cmd.AddCommand(NewSearchCmd())
 	cmd.AddCommand(NewTagsCmd())
+	cmd.AddCommand(NewServeCmd())
+	cmd.AddCommand(NewUserCmd())
+	cmd.AddCommand(NewKeyCmd())
 	return cmd
 }
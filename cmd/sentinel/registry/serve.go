@@ -0,0 +1,202 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/registry/auth"
+	"github.com/satishgonella2024/sentinelstacks/internal/registry/security"
+	"github.com/satishgonella2024/sentinelstacks/internal/registry/server"
+)
+
+// defaultRegistryDataDir returns the default data directory of a self-hosted registry
+func defaultRegistryDataDir() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "registry-data"
+	}
+	return filepath.Join(homeDir, ".sentinel", "registry-server")
+}
+
+// NewServeCmd creates a new serve command
+func NewServeCmd() *cobra.Command {
+	var (
+		addr          string
+		dataDir       string
+		storage       string
+		usersFile     string
+		secretFile    string
+		tokenTTL      time.Duration
+		private       bool
+		admins        []string
+		maxUploadSize int64
+		tlsCert       string
+		tlsKey        string
+		keyless       bool
+		certTTL       time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Run a self-hosted registry",
+		Long: `Run a registry server for packages and stacks, backed by local disk or SQLite.
+
+The server needs no network access beyond its listening address, so it can run
+on an air-gapped machine. Publishing always requires a token issued by the
+server's login endpoint; add users with 'sentinel registry user add'. Use
+--private to require a token for pulls and searches as well. A name belongs
+to the user who first publishes it; only --admin users may push to names
+published by others.
+
+With --keyless the server certifies short-lived signing keys for logged-in
+users, so they can sign packages without managing keys. Clients trust these
+signatures by importing the issuer key from /v1/signing/issuer.`,
+		Example: `  sentinel registry user add alice
+  sentinel registry serve --addr :5000 --storage sqlite
+  sentinel registry login --registry http://localhost:5000`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := os.MkdirAll(dataDir, 0755); err != nil {
+				return fmt.Errorf("failed to create data directory: %w", err)
+			}
+			if usersFile == "" {
+				usersFile = filepath.Join(dataDir, "users.json")
+			}
+			if secretFile == "" {
+				secretFile = filepath.Join(dataDir, "token-secret")
+			}
+
+			var store server.Store
+			var err error
+			switch storage {
+			case "disk":
+				store, err = server.NewDiskStore(filepath.Join(dataDir, "artifacts"))
+			case "sqlite":
+				store, err = server.NewSQLiteStore(filepath.Join(dataDir, "registry.db"))
+			default:
+				return fmt.Errorf("unknown storage %q: use disk or sqlite", storage)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to open registry store: %w", err)
+			}
+			defer store.Close()
+
+			users, err := auth.LoadUserStore(usersFile)
+			if err != nil {
+				return err
+			}
+			if len(users.Usernames()) == 0 {
+				fmt.Printf("Warning: no users in %s; nobody can publish until one is added with 'sentinel registry user add'\n", usersFile)
+			}
+
+			secret, err := auth.LoadOrCreateSecret(secretFile)
+			if err != nil {
+				return err
+			}
+
+			config := server.Config{
+				Store:          store,
+				Users:          users,
+				Tokens:         auth.NewTokenIssuer(secret, tokenTTL),
+				Private:        private,
+				Admins:         admins,
+				MaxUploadSize:  maxUploadSize,
+				CertificateTTL: certTTL,
+			}
+			if keyless {
+				config.Issuer, config.IssuerKeyID, err = loadIssuerKey(filepath.Join(dataDir, "keys"))
+				if err != nil {
+					return err
+				}
+			}
+
+			registryServer, err := server.NewServer(config)
+			if err != nil {
+				return fmt.Errorf("failed to create registry server: %w", err)
+			}
+
+			httpServer := &http.Server{
+				Addr:              addr,
+				Handler:           registryServer,
+				ReadHeaderTimeout: 15 * time.Second,
+			}
+
+			errCh := make(chan error, 1)
+			go func() {
+				if tlsCert != "" || tlsKey != "" {
+					errCh <- httpServer.ListenAndServeTLS(tlsCert, tlsKey)
+				} else {
+					errCh <- httpServer.ListenAndServe()
+				}
+			}()
+
+			fmt.Printf("Registry listening on %s (storage: %s, data: %s)\n", addr, storage, dataDir)
+
+			sigCh := make(chan os.Signal, 1)
+			signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+
+			select {
+			case err := <-errCh:
+				if err != http.ErrServerClosed {
+					return fmt.Errorf("registry server failed: %w", err)
+				}
+				return nil
+			case <-sigCh:
+				fmt.Println("Shutting down registry...")
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			return httpServer.Shutdown(ctx)
+		},
+	}
+
+	// Add flags
+	cmd.Flags().StringVar(&addr, "addr", ":5000", "Address to listen on")
+	cmd.Flags().StringVar(&dataDir, "data", defaultRegistryDataDir(), "Directory for registry data")
+	cmd.Flags().StringVar(&storage, "storage", "disk", "Storage backend: disk or sqlite")
+	cmd.Flags().StringVar(&usersFile, "users", "", "Users file (default: <data>/users.json)")
+	cmd.Flags().StringVar(&secretFile, "secret", "", "Token signing secret file, created if missing (default: <data>/token-secret)")
+	cmd.Flags().DurationVar(&tokenTTL, "token-ttl", auth.DefaultTokenTTL, "Lifetime of issued tokens")
+	cmd.Flags().BoolVar(&private, "private", false, "Require a token for pulls and searches")
+	cmd.Flags().StringSliceVar(&admins, "admin", nil, "Users who may push to names published by others")
+	cmd.Flags().Int64Var(&maxUploadSize, "max-upload-size", server.DefaultMaxUploadSize, "Largest accepted push in bytes")
+	cmd.Flags().StringVar(&tlsCert, "tls-cert", "", "TLS certificate file")
+	cmd.Flags().StringVar(&tlsKey, "tls-key", "", "TLS key file")
+	cmd.Flags().BoolVar(&keyless, "keyless", false, "Issue keyless signing certificates to logged-in users")
+	cmd.Flags().DurationVar(&certTTL, "certificate-ttl", security.DefaultCertificateTTL, "Lifetime of keyless signing certificates")
+
+	return cmd
+}
+
+// issuerKeyID names the key a registry certifies keyless signing keys with
+const issuerKeyID = "registry-issuer"
+
+// loadIssuerKey loads the registry's issuer key, generating it on first use
+func loadIssuerKey(keysDir string) (*security.KeyManager, string, error) {
+	km, err := security.NewKeyManager(keysDir)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if _, err := os.Stat(filepath.Join(keysDir, issuerKeyID+".key")); os.IsNotExist(err) {
+		if err := km.GenerateKey(issuerKeyID, security.KeyTypeEd25519); err != nil {
+			return nil, "", fmt.Errorf("failed to generate issuer key: %w", err)
+		}
+	}
+
+	keyFingerprint, err := km.Fingerprint(issuerKeyID)
+	if err != nil {
+		return nil, "", err
+	}
+	fmt.Printf("Keyless signing enabled; issuer key %s (%s)\n", issuerKeyID, keyFingerprint)
+
+	return km, issuerKeyID, nil
+}
@@ -0,0 +1,95 @@
+package registry
+
+import (
+	"fmt"
+	"path/filepath"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/registry/auth"
+)
+
+// NewUserCmd creates a new user command for managing the users of a
+// self-hosted registry
+func NewUserCmd() *cobra.Command {
+	var usersFile string
+
+	cmd := &cobra.Command{
+		Use:   "user",
+		Short: "Manage users of a self-hosted registry",
+		Long:  `Add, remove and list the users who can log in to a registry run with 'sentinel registry serve'.`,
+	}
+
+	cmd.PersistentFlags().StringVar(&usersFile, "users", filepath.Join(defaultRegistryDataDir(), "users.json"), "Users file")
+
+	addCmd := &cobra.Command{
+		Use:   "add [username]",
+		Short: "Add a user or change their password",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			users, err := auth.LoadUserStore(usersFile)
+			if err != nil {
+				return err
+			}
+
+			password, _ := cmd.Flags().GetString("password")
+			if password == "" {
+				fmt.Print("Password: ")
+				passwordBytes, err := term.ReadPassword(int(syscall.Stdin))
+				fmt.Println()
+				if err != nil {
+					return fmt.Errorf("failed to read password: %w", err)
+				}
+				password = string(passwordBytes)
+			}
+
+			if err := users.Add(args[0], password); err != nil {
+				return fmt.Errorf("failed to add user: %w", err)
+			}
+
+			fmt.Printf("User %s saved to %s\n", args[0], usersFile)
+			return nil
+		},
+	}
+	addCmd.Flags().String("password", "", "Password (prompted for if not set)")
+
+	removeCmd := &cobra.Command{
+		Use:   "remove [username]",
+		Short: "Remove a user",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			users, err := auth.LoadUserStore(usersFile)
+			if err != nil {
+				return err
+			}
+			if err := users.Remove(args[0]); err != nil {
+				return err
+			}
+
+			fmt.Printf("User %s removed\n", args[0])
+			return nil
+		},
+	}
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List users",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			users, err := auth.LoadUserStore(usersFile)
+			if err != nil {
+				return err
+			}
+
+			for _, username := range users.Usernames() {
+				fmt.Println(username)
+			}
+			return nil
+		},
+	}
+
+	cmd.AddCommand(addCmd, removeCmd, listCmd)
+	return cmd
+}
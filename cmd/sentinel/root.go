@@ -28,6 +28,7 @@ import (
 	"github.com/satishgonella2024/sentinelstacks/cmd/sentinel/ps"
 	"github.com/satishgonella2024/sentinelstacks/cmd/sentinel/pull"
 	"github.com/satishgonella2024/sentinelstacks/cmd/sentinel/push"
+	registryCmd "github.com/satishgonella2024/sentinelstacks/cmd/sentinel/registry"
 	"github.com/satishgonella2024/sentinelstacks/cmd/sentinel/run"
 	"github.com/satishgonella2024/sentinelstacks/cmd/sentinel/search"
 	"github.com/satishgonella2024/sentinelstacks/cmd/sentinel/shell"
@@ -37,6 +38,7 @@ import (
 	toolsCmd "github.com/satishgonella2024/sentinelstacks/cmd/sentinel/tools"
 	"github.com/satishgonella2024/sentinelstacks/cmd/sentinel/version"
 	"github.com/satishgonella2024/sentinelstacks/cmd/sentinel/volume"
+	"github.com/satishgonella2024/sentinelstacks/internal/runtime"
 )
 
 // rootCmd is the root command for the sentinel CLI
@@ -130,6 +132,9 @@ func Execute() error {
 	// Add service provider to context
 	ctx = context.WithValue(ctx, serviceProviderKey, serviceProvider)
 	
+	// Stop tool plugins started by the command
+	defer runtime.ShutdownTools()
+	
 	// Execute with context
 	return rootCmd.ExecuteContext(ctx)
 }
@@ -174,6 +179,7 @@ func init() {
 	rootCmd.AddCommand(shell.NewShellCmd())              // Shell command
 	rootCmd.AddCommand(pull.NewPullCmd())                // Pull command
 	rootCmd.AddCommand(push.NewPushCmd())                // Push command
+	rootCmd.AddCommand(registryCmd.NewRegistryCmd())     // Registry command (packages, self-hosted server)
 	rootCmd.AddCommand(login.NewLoginCmd())              // Login command
 	rootCmd.AddCommand(logout.NewLogoutCmd())            // Logout command
 	rootCmd.AddCommand(search.NewSearchCmd())            // Search command
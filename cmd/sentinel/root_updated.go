@@ -35,6 +35,7 @@ import (
 	toolsCmd "github.com/satishgonella2024/sentinelstacks/cmd/sentinel/tools"
 	"github.com/satishgonella2024/sentinelstacks/cmd/sentinel/version"
 	"github.com/satishgonella2024/sentinelstacks/cmd/sentinel/volume"
+	"github.com/satishgonella2024/sentinelstacks/internal/runtime"
 )
 
 // rootCmd is the root command for the sentinel CLI
@@ -129,6 +130,9 @@ func Execute() error {
 	// Add service provider to context
 	ctx = context.WithValue(ctx, serviceProviderKey, serviceProvider)
 	
+	// Stop tool plugins started by the command
+	defer runtime.ShutdownTools()
+	
 	// Execute with context
 	return rootCmd.ExecuteContext(ctx)
 }
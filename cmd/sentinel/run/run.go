@@ -3,6 +3,7 @@ package run
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
@@ -14,9 +15,12 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
+	"github.com/satishgonella2024/sentinelstacks/internal/memory"
 	"github.com/satishgonella2024/sentinelstacks/internal/multimodal"
 	"github.com/satishgonella2024/sentinelstacks/internal/registry"
+	"github.com/satishgonella2024/sentinelstacks/internal/registry/security"
 	"github.com/satishgonella2024/sentinelstacks/internal/runtime"
+	"github.com/satishgonella2024/sentinelstacks/pkg/services"
 )
 
 // NewRunCmd creates the run command
@@ -95,6 +99,12 @@ func NewRunCmd() *cobra.Command {
 				return fmt.Errorf("failed to create multimodal agent: %w", err)
 			}
 			
+			// Retrieve context from the agent's memory if the Sentinelfile asks for it
+			memoryService := services.NewMemoryService(memory.NewDefaultFactory())
+			if err := mmAgent.ConfigureRetrieval(image.Definition.Parameters, memoryService); err != nil {
+				return fmt.Errorf("failed to configure retrieval: %w", err)
+			}
+			
 			// Set up context with cancellation
 			ctx, cancel := context.WithCancel(context.Background())
 			defer cancel()
@@ -214,9 +224,39 @@ func loadImage(name, tag string) (*registry.Image, error) {
 		return nil, fmt.Errorf("failed to load image: %w", err)
 	}
 	
+	// Check the image against the trust policy before running it
+	if err := verifyImageTrust(name, tag); err != nil {
+		return nil, err
+	}
+	
 	return image, nil
 }
 
+// verifyImageTrust checks the signatures recorded when an image was pulled
+// against the current trust policy. Images that were not pulled, such as
+// local builds, are checked as unsigned images of the "local" registry.
+func verifyImageTrust(name, tag string) error {
+	policy, keyManager, err := security.LoadTrust(viper.GetString("security.keys_dir"), viper.GetString("security.trust_policy"))
+	if err != nil {
+		return err
+	}
+	
+	trustStore, err := security.DefaultTrustStore()
+	if err != nil {
+		return err
+	}
+	record, err := trustStore.Load(name, tag)
+	if errors.Is(err, security.ErrNoTrustRecord) {
+		ref := security.Reference{Registry: security.LocalRegistry, Name: name, Version: tag}
+		return policy.Enforce(keyManager, ref, nil, nil)
+	}
+	if err != nil {
+		return err
+	}
+	
+	return policy.EnforceRecord(keyManager, record)
+}
+
 // loadMultimodalContent loads multimodal content from a file
 func loadMultimodalContent(imageFile string) (*multimodal.Content, error) {
 	if imageFile == "" {
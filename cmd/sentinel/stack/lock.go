@@ -0,0 +1,134 @@
+package stack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/registry/client"
+	regstack "github.com/satishgonella2024/sentinelstacks/internal/registry/stack"
+	"github.com/satishgonella2024/sentinelstacks/internal/stack"
+)
+
+// NewLockCommand creates a 'stack lock' command
+func NewLockCommand() *cobra.Command {
+	var (
+		stackFile string
+		verify    bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "lock",
+		Short: "Resolve agent versions into stack.lock",
+		Long: `Resolve the version constraints in the uses: references of a Stackfile
+(such as summarizer:^1.2, analyzer:~0.4.1 or loader:>=2 <3) against the
+registry and record the exact versions and digests in stack.lock next to it.
+
+sentinel stack run uses the locked versions, so a stack runs the same agents
+on every machine until it is locked again.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+			defer cancel()
+
+			lockPath := regstack.LockfilePath(stackFile)
+			resolver := newDependencyResolver()
+
+			if verify {
+				lock, err := regstack.ReadLockfile(lockPath)
+				if err != nil {
+					return err
+				}
+				if err := resolver.VerifyLock(ctx, lock); err != nil {
+					return err
+				}
+				fmt.Printf("%s matches the registry\n", lockPath)
+				return nil
+			}
+
+			spec, err := readStackDefinition(stackFile)
+			if err != nil {
+				return fmt.Errorf("failed to parse stack file: %w", err)
+			}
+
+			result, err := resolver.ResolveStackDependencies(ctx, spec)
+			if err != nil {
+				return err
+			}
+			result.Lock.Registry = configuredRegistryURL()
+			if err := result.Lock.Write(lockPath); err != nil {
+				return err
+			}
+
+			for _, agent := range result.Lock.Agents {
+				fmt.Printf("  %s %v -> %s\n", agent.Name, agent.Constraints, agent.Version)
+			}
+			fmt.Printf("Wrote %s (%d agents, %d not pulled yet)\n", lockPath, len(result.Required), len(result.Missing))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&stackFile, "file", "f", "Stackfile.yaml", "Path to Stackfile")
+	cmd.Flags().BoolVar(&verify, "verify", false, "Check that the locked versions still have their locked digests instead of resolving again")
+
+	return cmd
+}
+
+// pinAgentVersions rewrites the uses: references of a stack to exact
+// versions. A Stackfile's stack.lock is used unless ignoreLock is set;
+// without one, version constraints are resolved against the registry and
+// the result is written to stack.lock so later runs use the same versions.
+func pinAgentVersions(ctx context.Context, spec *stack.StackSpec, stackFile string, ignoreLock bool) error {
+	lockPath := ""
+	if stackFile != "" && !ignoreLock {
+		lockPath = regstack.LockfilePath(stackFile)
+		lock, err := regstack.ReadLockfile(lockPath)
+		if err == nil {
+			if err := lock.Apply(spec); err != nil {
+				return fmt.Errorf("%w; run 'sentinel stack lock -f %s' to update it", err, stackFile)
+			}
+			return nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	if !regstack.NeedsResolution(*spec) {
+		return nil
+	}
+
+	result, err := newDependencyResolver().ResolveStackDependencies(ctx, *spec)
+	if err != nil {
+		return fmt.Errorf("failed to resolve agent versions: %w", err)
+	}
+	if lockPath != "" {
+		result.Lock.Registry = configuredRegistryURL()
+		if err := result.Lock.Write(lockPath); err != nil {
+			return err
+		}
+		fmt.Printf("Resolved agent versions into %s\n", lockPath)
+	}
+
+	return result.Lock.Apply(spec)
+}
+
+// newDependencyResolver resolves agent versions against the configured
+// registry
+func newDependencyResolver() *regstack.DependencyResolver {
+	registryClient := client.NewRegistryClient(configuredRegistryURL(), viper.GetString("registry.auth_token"))
+	return regstack.NewDependencyResolver(regstack.PackageSource{Client: registryClient})
+}
+
+// configuredRegistryURL returns the configured registry URL
+func configuredRegistryURL() string {
+	if url := viper.GetString("registry.url"); url != "" {
+		return url
+	}
+	return "https://registry.sentinelstacks.io"
+}
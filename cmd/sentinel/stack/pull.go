@@ -11,12 +11,12 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
-	"gopkg.in/yaml.v3"
 
 	"github.com/satishgonella2024/sentinelstacks/internal/registry/client"
 	"github.com/satishgonella2024/sentinelstacks/internal/registry/format"
 	packages "github.com/satishgonella2024/sentinelstacks/internal/registry/package"
 	"github.com/satishgonella2024/sentinelstacks/internal/registry/security"
+	"github.com/satishgonella2024/sentinelstacks/pkg/types"
 )
 
 // NewPullCommand creates a 'stack pull' command
@@ -94,25 +94,37 @@ func NewPullCommand() *cobra.Command {
 				return fmt.Errorf("failed to pull package: %w", err)
 			}
 
+			// Check signatures against the trust policy, whose mode decides
+			// whether untrusted packages are refused, before writing any file
+			pkg := &packages.SentinelPackage{}
+			if err := pkg.ReadManifest(packagePath); err != nil {
+				return fmt.Errorf("failed to read package manifest: %w", err)
+			}
+			policy, keyManager, err := security.LoadTrust(viper.GetString("security.keys_dir"), viper.GetString("security.trust_policy"))
+			if err != nil {
+				return err
+			}
+			ref := security.Reference{Registry: registryURL, Name: name, Version: version}
+			if err := pkg.VerifyTrust(policy, keyManager, ref); err != nil {
+				return err
+			}
+
+			// Extract the package
+			extractDir := filepath.Join(tempDir, "extract")
+			if err := os.MkdirAll(extractDir, 0755); err != nil {
+				return fmt.Errorf("failed to create extraction directory: %w", err)
+			}
+
+			fmt.Printf("Extracting package contents...\n")
+			if err := pkg.Unpackage(packagePath, extractDir); err != nil {
+				return fmt.Errorf("failed to extract package: %w", err)
+			}
+
 			// Verify the package if requested
 			if verify {
-				fmt.Printf("Verifying package integrity and signatures...\n")
+				fmt.Printf("Verifying package integrity...\n")
 				
-				// Get keys directory from config
-				keysDir := viper.GetString("security.keys_dir")
-				if keysDir == "" {
-					home, _ := os.UserHomeDir()
-					keysDir = filepath.Join(home, ".sentinel", "keys")
-				}
-
-				// Create key manager
-				keyManager, err := security.NewKeyManager(keysDir)
-				if err != nil {
-					return fmt.Errorf("failed to create key manager: %w", err)
-				}
-
-				// Verify package
-				valid, failures, err := registryClient.VerifyPackage(packagePath)
+				valid, failures, err := pkg.VerifyIntegrity(extractDir)
 				if err != nil {
 					return fmt.Errorf("package verification failed: %w", err)
 				}
@@ -124,18 +136,6 @@ func NewPullCommand() *cobra.Command {
 				fmt.Printf("Package verification successful\n")
 			}
 
-			// Extract the package
-			extractDir := filepath.Join(tempDir, "extract")
-			if err := os.MkdirAll(extractDir, 0755); err != nil {
-				return fmt.Errorf("failed to create extraction directory: %w", err)
-			}
-
-			fmt.Printf("Extracting package contents...\n")
-			pkg := &packages.SentinelPackage{}
-			if err := pkg.Unpackage(packagePath, extractDir); err != nil {
-				return fmt.Errorf("failed to extract package: %w", err)
-			}
-
 			// Find the stack definition file
 			var stackFilePath string
 			for _, file := range pkg.Manifest.Files {
@@ -178,7 +178,7 @@ func NewPullCommand() *cobra.Command {
 				fmt.Printf("Pulling required agents...\n")
 				
 				for _, dep := range pkg.Manifest.Dependencies {
-					if dep.Type == packages.PackageTypeAgent {
+					if dep.Type == types.PackageTypeAgent {
 						fmt.Printf("  - Pulling agent: %s:%s\n", dep.Name, dep.Version)
 						
 						// Pull agent
@@ -216,7 +216,7 @@ func NewPullCommand() *cobra.Command {
 
 	// Add flags
 	cmd.Flags().StringVarP(&outputDir, "output", "o", "", "Output directory")
-	cmd.Flags().BoolVarP(&verify, "verify", "v", true, "Verify package integrity and signatures")
+	cmd.Flags().BoolVarP(&verify, "verify", "v", true, "Verify package integrity")
 	cmd.Flags().BoolVarP(&savePackage, "save-package", "s", false, "Save the package file")
 	cmd.Flags().BoolVarP(&extractAgent, "extract-agents", "e", false, "Extract required agents")
 
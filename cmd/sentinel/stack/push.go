@@ -2,8 +2,11 @@ package stack
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
@@ -16,7 +19,10 @@ import (
 	"github.com/satishgonella2024/sentinelstacks/internal/registry/format"
 	packages "github.com/satishgonella2024/sentinelstacks/internal/registry/package"
 	"github.com/satishgonella2024/sentinelstacks/internal/registry/security"
+	regstack "github.com/satishgonella2024/sentinelstacks/internal/registry/stack"
+	"github.com/satishgonella2024/sentinelstacks/internal/runtime"
 	"github.com/satishgonella2024/sentinelstacks/internal/stack"
+	"github.com/satishgonella2024/sentinelstacks/pkg/types"
 )
 
 // NewPushCommand creates a 'stack push' command
@@ -25,6 +31,7 @@ func NewPushCommand() *cobra.Command {
 		author        string
 		buildPackage  bool
 		sign          bool
+		keyless       bool
 		keyID         string
 		outputPackage string
 	)
@@ -53,7 +60,7 @@ func NewPushCommand() *cobra.Command {
 			}
 
 			// Parse the stack file
-			stackSpec, err := parseStackFile(stackFilePath)
+			stackSpec, err := parseStackSpec(stackFilePath)
 			if err != nil {
 				return fmt.Errorf("failed to parse stack file: %w", err)
 			}
@@ -81,14 +88,24 @@ func NewPushCommand() *cobra.Command {
 					packagePath = format.GetDefaultFilename(stackSpec.Name, stackSpec.Version, "stack")
 				}
 
+				// Register shell and plugin tools, so the provenance
+				// records the permissions they require
+				runtime.RegisterConfiguredTools()
+
 				// Create package builder
-				builder := packages.NewPackageBuilder(packages.PackageTypeStack, stackSpec.Name, stackSpec.Version, stackSpec.Description, author)
+				builder := packages.NewPackageBuilder(types.PackageTypeStack, stackSpec.Name, stackSpec.Version, stackSpec.Description, author)
 
 				// Add main stack file
 				if err := builder.AddFile(stackFilePath, format.GetDefaultFilename(stackSpec.Name, "", "stack-def"), true, packages.FileTypeManifest); err != nil {
 					return fmt.Errorf("failed to add stack file: %w", err)
 				}
 
+				// Record the agents the stack uses, pinned to the versions
+				// in stack.lock so the provenance names exact digests
+				if err := addAgentDependencies(builder, stackSpec, stackFilePath); err != nil {
+					return err
+				}
+
 				// Add any additional files in the same directory
 				stackDir := filepath.Dir(stackFilePath)
 				readmePath := filepath.Join(stackDir, "README.md")
@@ -133,10 +150,19 @@ func NewPushCommand() *cobra.Command {
 					keyPath := filepath.Join(keysDir, keyID+".key")
 					if _, err := os.Stat(keyPath); os.IsNotExist(err) {
 						fmt.Printf("Generating new signing key: %s\n", keyID)
-						if err := keyManager.GenerateKeyPair(keyID, 2048); err != nil {
+						if err := keyManager.GenerateKey(keyID, security.KeyTypeEd25519); err != nil {
 							return fmt.Errorf("failed to generate key pair: %w", err)
 						}
 					}
+					builder.SetSigningKey(keyID, author)
+				}
+
+				// Sign with a short-lived key certified by the registry
+				if keyless {
+					if authToken == "" {
+						return fmt.Errorf("keyless signing requires a registry auth token")
+					}
+					builder.SetKeylessIssuer(security.NewRemoteIssuer(registryURL, authToken))
 				}
 
 				// Build the package
@@ -173,14 +199,41 @@ func NewPushCommand() *cobra.Command {
 	cmd.Flags().StringVarP(&author, "author", "a", "", "Author of the stack")
 	cmd.Flags().BoolVarP(&buildPackage, "build", "b", false, "Build a package file")
 	cmd.Flags().BoolVarP(&sign, "sign", "s", false, "Sign the package")
+	cmd.Flags().BoolVar(&keyless, "keyless", false, "Sign with a short-lived key certified by the registry")
 	cmd.Flags().StringVarP(&keyID, "key", "k", "", "Key ID for signing")
 	cmd.Flags().StringVarP(&outputPackage, "output", "o", "", "Output package path")
 
 	return cmd
 }
 
-// parseStackFile parses a stack file (YAML or JSON)
-func parseStackFile(filePath string) (stack.StackSpec, error) {
+// addAgentDependencies adds the agents a stack uses as package
+// dependencies, pinned to their locked versions when there is a stack.lock
+func addAgentDependencies(builder *packages.PackageBuilder, spec stack.StackSpec, stackFilePath string) error {
+	seen := make(map[string]bool)
+	for _, agent := range spec.Agents {
+		name, constraint := regstack.ParseUses(agent.Uses)
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		builder.AddDependency(name, constraint, types.PackageTypeAgent, true)
+	}
+
+	lock, err := regstack.ReadLockfile(regstack.LockfilePath(stackFilePath))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, agent := range lock.Agents {
+		builder.PinDependency(agent.Name, agent.Version, agent.Digest)
+	}
+	return nil
+}
+
+// parseStackSpec parses the full spec of a stack file (YAML or JSON)
+func parseStackSpec(filePath string) (stack.StackSpec, error) {
 	var stackSpec stack.StackSpec
 
 	// Read file content
@@ -18,6 +18,7 @@ import (
 	"github.com/satishgonella2024/sentinelstacks/internal/memory"
 	"github.com/satishgonella2024/sentinelstacks/internal/parser"
 	"github.com/satishgonella2024/sentinelstacks/internal/stack"
+	"github.com/satishgonella2024/sentinelstacks/pkg/types"
 )
 
 var (
@@ -27,6 +28,10 @@ var (
 	inputNl    string
 	verbose    bool
 	timeoutSec int
+	parallel   int
+	resumeID   string
+	watch      bool
+	noLock     bool
 )
 
 // NewRunCommand creates a new command for running stacks
@@ -45,6 +50,10 @@ func NewRunCommand() *cobra.Command {
 	cmd.Flags().StringVarP(&inputNl, "nl", "n", "", "Natural language description of the stack")
 	cmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose output")
 	cmd.Flags().IntVarP(&timeoutSec, "timeout", "t", 0, "Execution timeout in seconds (0 for no timeout)")
+	cmd.Flags().IntVarP(&parallel, "parallel", "p", stack.DefaultMaxParallelism, "Maximum number of agents to execute concurrently")
+	cmd.Flags().StringVar(&resumeID, "resume", "", "Resume a previous run by ID, re-executing only agents that did not complete")
+	cmd.Flags().BoolVarP(&watch, "watch", "w", false, "Show live progress as agents start, retry, complete, fail or are skipped")
+	cmd.Flags().BoolVar(&noLock, "no-lock", false, "Ignore stack.lock and resolve agent version constraints against the registry")
 
 	return cmd
 }
@@ -78,6 +87,11 @@ func runStack(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to parse input: %w", err)
 	}
 
+	// Pin agents to the versions in stack.lock
+	if err := pinAgentVersions(ctx, &stackSpec, inputFile, noLock); err != nil {
+		return err
+	}
+
 	// Parse input JSON if provided
 	var inputData map[string]interface{}
 	if inputJson != "" {
@@ -101,6 +115,16 @@ func runStack(cmd *cobra.Command, args []string) error {
 	engineOptions := []stack.EngineOption{
 		stack.WithVerbose(verbose),
 		stack.WithMemoryFactory(memoryFactory),
+		stack.WithStateStore(types.MemoryStoreTypeSQLite),
+	}
+	if resumeID != "" {
+		engineOptions = append(engineOptions, stack.WithResume(resumeID))
+	}
+
+	var events *stack.EventBus
+	if watch {
+		events = stack.NewEventBus()
+		engineOptions = append(engineOptions, stack.WithEventBus(events))
 	}
 
 	// Create engine
@@ -120,13 +144,19 @@ func runStack(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to determine execution order: %w", err)
 	}
 
-	fmt.Printf("Executing stack: %s\n", stackSpec.Name)
+	if resumeID != "" {
+		fmt.Printf("Resuming stack: %s\n", stackSpec.Name)
+	} else {
+		fmt.Printf("Executing stack: %s\n", stackSpec.Name)
+	}
+	fmt.Printf("Run ID: %s\n", engine.RunID())
 	fmt.Printf("Agents: %d\n", len(stackSpec.Agents))
 	fmt.Printf("Execution order: %v\n", executionOrder)
 
 	// Create execution options
 	executeOptions := []stack.ExecuteOption{
 		stack.WithTimeout(timeoutSec),
+		stack.WithMaxParallelism(parallel),
 	}
 
 	// Add input data if provided
@@ -137,12 +167,22 @@ func runStack(cmd *cobra.Command, args []string) error {
 	// Execute the stack
 	fmt.Println("Starting execution...")
 	startTime := time.Now()
-	
+
+	var stopWatching func()
+	if events != nil {
+		stopWatching = watchRun(events, engine.RunID(), startTime)
+	}
+
 	err = engine.Execute(ctx, executeOptions...)
-	
+
+	if stopWatching != nil {
+		stopWatching()
+	}
+
 	duration := time.Since(startTime)
 	if err != nil {
 		fmt.Printf("Stack execution failed after %v: %v\n", duration, err)
+		fmt.Printf("Resume with: sentinel stack run --resume %s <stack definition flags>\n", engine.RunID())
 		return err
 	}
 
@@ -155,21 +195,61 @@ func runStack(cmd *cobra.Command, args []string) error {
 	fmt.Printf("Completed: %d\n", summary.CompletedCount)
 	fmt.Printf("Failed: %d\n", summary.FailedCount)
 	fmt.Printf("Blocked: %d\n", summary.BlockedCount)
+	fmt.Printf("Skipped: %d\n", summary.SkippedCount)
 	
 	// If verbose, show detailed agent states
 	if verbose {
 		fmt.Println("\nAgent details:")
 		for id, state := range summary.AgentStates {
 			fmt.Printf("  - %s: %s\n", id, state.Status)
-			if state.Status == stack.AgentStatusFailed && state.ErrorMessage != "" {
+			if (state.Status == stack.AgentStatusFailed || state.Status == stack.AgentStatusSkipped) && state.ErrorMessage != "" {
 				fmt.Printf("    Error: %s\n", state.ErrorMessage)
 			}
+			printAttempts(engine, id)
 		}
 	}
 
 	return nil
 }
 
+// printAttempts lists the failed attempts of an agent that was retried
+func printAttempts(engine *stack.StackEngine, agentID string) {
+	agentState, err := engine.GetAgentState(agentID)
+	if err != nil {
+		return
+	}
+
+	attempts := stack.AttemptsFromState(agentState)
+	if len(attempts) < 2 {
+		return
+	}
+
+	fmt.Printf("    Attempts: %d\n", len(attempts))
+	for _, attempt := range attempts {
+		if attempt.Error != "" {
+			fmt.Printf("      #%d: %s\n", attempt.Attempt, attempt.Error)
+		}
+	}
+}
+
+// readStackDefinition parses a Stackfile, choosing the format by extension
+func readStackDefinition(path string) (stack.StackSpec, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return stack.StackSpec{}, fmt.Errorf("failed to read input file: %w", err)
+	}
+
+	p := parser.NewStackParser()
+	switch filepath.Ext(path) {
+	case ".json":
+		return p.ParseFromJSON(string(content))
+	case ".yaml", ".yml":
+		return p.ParseFromYAML(string(content))
+	default:
+		return stack.StackSpec{}, fmt.Errorf("unknown file format: %s", path)
+	}
+}
+
 // parseInput parses the stack definition from various input sources
 func parseInput() (stack.StackSpec, error) {
 	p := parser.NewStackParser()
@@ -177,20 +257,7 @@ func parseInput() (stack.StackSpec, error) {
 	// Check input sources in order of precedence
 	if inputFile != "" {
 		// Load from file
-		content, err := ioutil.ReadFile(inputFile)
-		if err != nil {
-			return stack.StackSpec{}, fmt.Errorf("failed to read input file: %w", err)
-		}
-		
-		// Determine file type from extension
-		switch filepath.Ext(inputFile) {
-		case ".json":
-			return p.ParseFromJSON(string(content))
-		case ".yaml", ".yml":
-			return p.ParseFromYAML(string(content))
-		default:
-			return stack.StackSpec{}, fmt.Errorf("unknown file format: %s", inputFile)
-		}
+		return readStackDefinition(inputFile)
 	} else if inputJson != "" {
 		// Parse JSON input
 		return p.ParseFromJSON(inputJson)
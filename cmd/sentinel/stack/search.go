@@ -10,7 +10,7 @@ import (
 	"github.com/spf13/viper"
 
 	"github.com/satishgonella2024/sentinelstacks/internal/registry/client"
-	packages "github.com/satishgonella2024/sentinelstacks/internal/registry/package"
+	"github.com/satishgonella2024/sentinelstacks/pkg/types"
 )
 
 // NewSearchCommand creates a 'stack search' command
@@ -50,7 +50,7 @@ func NewSearchCommand() *cobra.Command {
 			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 			defer cancel()
 
-			result, err := registryClient.SearchPackages(ctx, query, packages.PackageTypeStack, limit)
+			result, err := registryClient.SearchPackages(ctx, query, types.PackageTypeStack, limit)
 			if err != nil {
 				return fmt.Errorf("search failed: %w", err)
 			}
@@ -180,14 +180,6 @@ func printJSONSearch(result *client.SearchResult) {
 	fmt.Println("}")
 }
 
-// truncateString truncates a string to max length with ellipsis
-func truncateString(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
-	}
-	return s[:maxLen-3] + "..."
-}
-
 // escapeString escapes special characters in a string for JSON
 func escapeString(s string) string {
 	s = strings.ReplaceAll(s, "\\", "\\\\")
@@ -2,9 +2,6 @@ package stack
 
 import (
 	"fmt"
-	"os"
-	"path/filepath"
-	"strings"
 
 	"github.com/spf13/cobra"
 )
@@ -25,6 +22,7 @@ func NewStackCommand() *cobra.Command {
 	cmd.AddCommand(NewPushCommand())   // Push stack to registry
 	cmd.AddCommand(NewPullCommand())   // Pull stack from registry
 	cmd.AddCommand(NewSearchCommand()) // Search stacks in registry
+	cmd.AddCommand(NewLockCommand())   // Lock agent versions
 
 	return cmd
 }
@@ -0,0 +1,50 @@
+package stack
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/stack"
+)
+
+// watchRun prints the events of a run as they happen. The returned function
+// stops watching and waits until every event received so far is printed.
+func watchRun(events *stack.EventBus, runID string, startTime time.Time) func() {
+	ch, cancel := events.Subscribe(runID)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		for event := range ch {
+			printEvent(event, startTime)
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// printEvent renders a single execution event as a progress line
+func printEvent(event stack.Event, startTime time.Time) {
+	elapsed := event.Time.Sub(startTime).Round(100 * time.Millisecond)
+	took := time.Duration(event.DurationMs) * time.Millisecond
+
+	switch event.Type {
+	case stack.EventAgentStarted:
+		fmt.Printf("[%8v] started    %s\n", elapsed, event.AgentID)
+	case stack.EventAgentCompleted:
+		fmt.Printf("[%8v] completed  %s (%v)\n", elapsed, event.AgentID, took)
+	case stack.EventAgentFailed:
+		fmt.Printf("[%8v] failed     %s (%v): %s\n", elapsed, event.AgentID, took, event.Error)
+	case stack.EventAgentRetry:
+		fmt.Printf("[%8v] retrying   %s: attempt %d failed, next in %v: %s\n", elapsed, event.AgentID,
+			event.Attempt, time.Duration(event.RetryInMs)*time.Millisecond, event.Error)
+	case stack.EventAgentSkipped:
+		fmt.Printf("[%8v] skipped    %s: %s\n", elapsed, event.AgentID, event.Reason)
+	case stack.EventStackFinished:
+		fmt.Printf("[%8v] finished   %s: %s, %d/%d agents completed\n", elapsed, event.Stack, event.Status,
+			event.Summary["completed"], event.Summary["total"])
+	}
+}
@@ -33,7 +33,8 @@ func NewToolsListCmd() *cobra.Command {
 		Short: "List available tools",
 		Long:  `List all available tools that can be used by Sentinel agents`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			// Get registry
+			// Get registry, plugin tools included
+			runtime.RegisterConfiguredTools()
 			registry := tools.GetRegistry()
 			
 			// Get all tools
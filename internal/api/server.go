@@ -42,6 +42,7 @@ type Server struct {
 	log       *log.Logger
 	once      sync.Once
 	wsManager *WebSocketManager
+	stackRuns *stackRuns
 }
 
 // Config contains API server configuration
@@ -90,6 +91,7 @@ func NewServer(config *Config) (*Server, error) {
 		config:    config,
 		log:       logger,
 		wsManager: NewWebSocketManager(logger),
+		stackRuns: newStackRuns(),
 	}
 
 	s.setupRoutes()
@@ -148,6 +150,11 @@ func (s *Server) setupRoutes() {
 	images.HandleFunc("", s.listImagesHandler).Methods("GET")
 	images.HandleFunc("/{id}", s.getImageHandler).Methods("GET")
 
+	// Stack run routes (starting a run is protected by auth)
+	stacks := api.PathPrefix("/stacks").Subrouter()
+	stacks.Handle("/runs", s.authMiddleware(http.HandlerFunc(s.createStackRunHandler))).Methods("POST")
+	stacks.HandleFunc("/runs/{id}/events", s.stackRunEventsHandler).Methods("GET")
+
 	// Registry routes (protected by auth)
 	registry := api.PathPrefix("/registry").Subrouter()
 	registry.Use(s.authMiddleware)
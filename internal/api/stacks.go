@@ -0,0 +1,256 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/satishgonella2024/sentinelstacks/internal/memory"
+	"github.com/satishgonella2024/sentinelstacks/internal/parser"
+	"github.com/satishgonella2024/sentinelstacks/internal/stack"
+	"github.com/satishgonella2024/sentinelstacks/pkg/types"
+)
+
+// stackRuns tracks the stack runs started through the API
+type stackRuns struct {
+	mu      sync.Mutex
+	engines map[string]*stack.StackEngine
+	events  *stack.EventBus
+}
+
+// newStackRuns creates an empty run tracker with its own event bus
+func newStackRuns() *stackRuns {
+	return &stackRuns{
+		engines: make(map[string]*stack.StackEngine),
+		events:  stack.NewEventBus(),
+	}
+}
+
+// exists reports whether a run is known to the server
+func (r *stackRuns) exists(runID string) bool {
+	r.mu.Lock()
+	_, ok := r.engines[runID]
+	r.mu.Unlock()
+	return ok || r.events.HasRun(runID)
+}
+
+// remove forgets the engine of a finished run
+func (r *stackRuns) remove(runID string) {
+	r.mu.Lock()
+	delete(r.engines, runID)
+	r.mu.Unlock()
+}
+
+// StackRunRequest represents a request to run a stack
+type StackRunRequest struct {
+	Stack    json.RawMessage        `json:"stack"`
+	Input    map[string]interface{} `json:"input,omitempty"`
+	Timeout  int                    `json:"timeout,omitempty"`
+	Parallel int                    `json:"parallel,omitempty"`
+}
+
+// StackRunResponse represents the response to starting a stack run
+type StackRunResponse struct {
+	RunID  string `json:"runId"`
+	Stack  string `json:"stack"`
+	Events string `json:"events"`
+}
+
+// @Summary Run a stack
+// @Description Start executing a stack in the background and return its run ID
+// @Tags stacks
+// @Accept json
+// @Produce json
+// @Param run body StackRunRequest true "Stack Run Request"
+// @Success 202 {object} StackRunResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /stacks/runs [post]
+func (s *Server) createStackRunHandler(w http.ResponseWriter, r *http.Request) {
+	var req StackRunRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if len(req.Stack) == 0 {
+		s.sendError(w, http.StatusBadRequest, "Stack definition is required")
+		return
+	}
+
+	spec, err := parser.NewStackParser().ParseFromJSON(string(req.Stack))
+	if err != nil {
+		s.sendError(w, http.StatusBadRequest, fmt.Sprintf("Invalid stack definition: %v", err))
+		return
+	}
+
+	memoryFactory, err := memory.NewMemoryStoreFactory("")
+	if err != nil {
+		s.sendError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to create memory factory: %v", err))
+		return
+	}
+
+	engine, err := stack.NewStackEngine(spec,
+		stack.WithMemoryFactory(memoryFactory),
+		stack.WithStateStore(types.MemoryStoreTypeSQLite),
+		stack.WithEventBus(s.stackRuns.events),
+	)
+	if err != nil {
+		s.sendError(w, http.StatusBadRequest, fmt.Sprintf("Failed to create stack engine: %v", err))
+		return
+	}
+
+	runID := engine.RunID()
+	s.stackRuns.mu.Lock()
+	s.stackRuns.engines[runID] = engine
+	s.stackRuns.mu.Unlock()
+
+	options := []stack.ExecuteOption{stack.WithTimeout(req.Timeout)}
+	if req.Parallel > 0 {
+		options = append(options, stack.WithMaxParallelism(req.Parallel))
+	}
+	if req.Input != nil {
+		options = append(options, stack.WithInput(req.Input))
+	}
+
+	// The run outlives the request. Once it finishes, its events remain
+	// available from the event bus.
+	go func() {
+		defer s.stackRuns.remove(runID)
+		if err := engine.Execute(context.Background(), options...); err != nil {
+			s.log.Printf("Stack run %s failed: %v", runID, err)
+		}
+	}()
+
+	s.sendJSON(w, http.StatusAccepted, StackRunResponse{
+		RunID:  runID,
+		Stack:  spec.Name,
+		Events: fmt.Sprintf("/v1/stacks/runs/%s/events", runID),
+	})
+}
+
+// @Summary Stream stack run events
+// @Description Stream the execution events of a stack run, from its start until it finishes. Requests that ask for a WebSocket upgrade receive one event message per frame; all other requests receive Server-Sent Events.
+// @Tags stacks
+// @Produce text/event-stream
+// @Param id path string true "Run ID"
+// @Success 200 {string} string "Event stream"
+// @Success 101 {string} string "Switching to WebSocket protocol"
+// @Failure 404 {object} map[string]string
+// @Router /stacks/runs/{id}/events [get]
+func (s *Server) stackRunEventsHandler(w http.ResponseWriter, r *http.Request) {
+	runID := mux.Vars(r)["id"]
+
+	if !s.stackRuns.exists(runID) {
+		s.sendError(w, http.StatusNotFound, "Stack run not found")
+		return
+	}
+
+	if websocket.IsWebSocketUpgrade(r) {
+		s.streamRunEventsWebSocket(w, r, runID)
+		return
+	}
+	s.streamRunEventsSSE(w, r, runID)
+}
+
+// streamRunEventsSSE writes a run's events as Server-Sent Events. Clients
+// that reconnect with a Last-Event-ID header only receive newer events.
+func (s *Server) streamRunEventsSSE(w http.ResponseWriter, r *http.Request, runID string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		s.sendError(w, http.StatusInternalServerError, "Streaming is not supported")
+		return
+	}
+
+	lastSeq, _ := strconv.Atoi(r.Header.Get("Last-Event-ID"))
+
+	// The stream lasts as long as the run, beyond the server's write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	events, cancel := s.stackRuns.events.Subscribe(runID)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if event.Seq <= lastSeq {
+				continue
+			}
+
+			data, err := json.Marshal(event)
+			if err != nil {
+				s.log.Printf("Error encoding stack event: %v", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Seq, event.Type, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// streamRunEventsWebSocket writes a run's events to a WebSocket connection,
+// one EventMessage per event, and closes it once the run has finished
+func (s *Server) streamRunEventsWebSocket(w http.ResponseWriter, r *http.Request, runID string) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		s.log.Printf("Failed to upgrade connection to WebSocket: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	events, cancel := s.stackRuns.events.Subscribe(runID)
+	defer cancel()
+
+	// Stop streaming when the client goes away
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case event, ok := <-events:
+			if !ok {
+				conn.WriteMessage(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseNormalClosure, "stack run finished"))
+				return
+			}
+
+			message := EventMessage{
+				Type:      string(event.Type),
+				Timestamp: event.Time.Format(time.RFC3339),
+				Data:      event,
+			}
+			if err := conn.WriteJSON(message); err != nil {
+				s.log.Printf("Error writing stack event: %v", err)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,283 @@
+// Package jsonrpc implements a JSON-RPC 2.0 client over a stream of
+// newline-delimited messages, such as the stdio of a child process
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+)
+
+// Version is the JSON-RPC version of every message
+const Version = "2.0"
+
+// Standard error codes
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+)
+
+// ErrClosed is returned for calls on a client whose stream has ended
+var ErrClosed = errors.New("jsonrpc: connection closed")
+
+// Message is a JSON-RPC request, notification or response
+type Message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// IsResponse reports whether the message is a response to a call
+func (m *Message) IsResponse() bool {
+	return m.Method == "" && len(m.ID) > 0
+}
+
+// Error is the error object of a failed call
+type Error struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+// RequestHandler answers a request sent by the peer
+type RequestHandler func(method string, params json.RawMessage) (interface{}, error)
+
+// NotificationHandler handles a notification sent by the peer
+type NotificationHandler func(method string, params json.RawMessage)
+
+// Option configures a client
+type Option func(*Client)
+
+// WithCancelFunc sets the function called with the ID of a call whose
+// context ends before its response arrives, to tell the peer to stop
+func WithCancelFunc(cancel func(id int64)) Option {
+	return func(c *Client) {
+		c.onCancel = cancel
+	}
+}
+
+// WithRequestHandler sets the handler of requests sent by the peer. Without
+// one, requests are answered with a method not found error.
+func WithRequestHandler(handler RequestHandler) Option {
+	return func(c *Client) {
+		c.onRequest = handler
+	}
+}
+
+// WithNotificationHandler sets the handler of notifications sent by the
+// peer. Without one, notifications are ignored.
+func WithNotificationHandler(handler NotificationHandler) Option {
+	return func(c *Client) {
+		c.onNotification = handler
+	}
+}
+
+// Client sends calls and notifications over a stream and matches
+// responses to calls. It is safe for concurrent use.
+type Client struct {
+	w       io.Writer
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	nextID  int64
+	pending map[int64]chan *Message
+	err     error
+	done    chan struct{}
+
+	onCancel       func(id int64)
+	onRequest      RequestHandler
+	onNotification NotificationHandler
+}
+
+// NewClient creates a client writing to w and reading from r until r ends
+func NewClient(r io.Reader, w io.Writer, opts ...Option) *Client {
+	c := &Client{
+		w:       w,
+		pending: make(map[int64]chan *Message),
+		done:    make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	go c.readLoop(r)
+	return c
+}
+
+// Call sends a request and decodes its result into result, which may be
+// nil to discard it. If ctx ends first, the cancel function is called and
+// ctx's error is returned.
+func (c *Client) Call(ctx context.Context, method string, params, result interface{}) error {
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return c.err
+	}
+	c.nextID++
+	id := c.nextID
+	response := make(chan *Message, 1)
+	c.pending[id] = response
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	if err := c.send(json.RawMessage(strconv.FormatInt(id, 10)), method, params); err != nil {
+		return err
+	}
+
+	select {
+	case message := <-response:
+		if message.Error != nil {
+			return message.Error
+		}
+		if result == nil || len(message.Result) == 0 {
+			return nil
+		}
+		if err := json.Unmarshal(message.Result, result); err != nil {
+			return fmt.Errorf("failed to decode result of %s: %w", method, err)
+		}
+		return nil
+	case <-ctx.Done():
+		if c.onCancel != nil {
+			c.onCancel(id)
+		}
+		return ctx.Err()
+	case <-c.done:
+		return c.Err()
+	}
+}
+
+// Notify sends a notification, which has no response
+func (c *Client) Notify(method string, params interface{}) error {
+	return c.send(nil, method, params)
+}
+
+// Done is closed when the stream ends
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns why the stream ended, or nil while it is open
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// send writes a request, or a notification when id is nil
+func (c *Client) send(id json.RawMessage, method string, params interface{}) error {
+	message := Message{JSONRPC: Version, ID: id, Method: method}
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return fmt.Errorf("failed to encode params of %s: %w", method, err)
+		}
+		message.Params = data
+	}
+	return c.write(&message)
+}
+
+// write writes a message followed by a newline
+func (c *Client) write(message *Message) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if _, err := c.w.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	return nil
+}
+
+// readLoop dispatches incoming messages until the stream ends or holds
+// something other than JSON-RPC messages
+func (c *Client) readLoop(r io.Reader) {
+	decoder := json.NewDecoder(r)
+	var err error
+	for {
+		var message Message
+		if err = decoder.Decode(&message); err != nil {
+			break
+		}
+
+		switch {
+		case message.IsResponse():
+			c.deliver(&message)
+		case message.Method != "" && len(message.ID) > 0:
+			go c.answer(&message)
+		case message.Method != "" && c.onNotification != nil:
+			c.onNotification(message.Method, message.Params)
+		}
+	}
+
+	if errors.Is(err, io.EOF) {
+		err = ErrClosed
+	} else {
+		err = fmt.Errorf("%w: %v", ErrClosed, err)
+	}
+	c.mu.Lock()
+	c.err = err
+	c.mu.Unlock()
+	close(c.done)
+}
+
+// deliver passes a response to the call waiting for it
+func (c *Client) deliver(message *Message) {
+	id, err := strconv.ParseInt(string(message.ID), 10, 64)
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	response, ok := c.pending[id]
+	c.mu.Unlock()
+	if ok {
+		response <- message
+	}
+}
+
+// answer responds to a request from the peer
+func (c *Client) answer(request *Message) {
+	response := Message{JSONRPC: Version, ID: request.ID}
+
+	if c.onRequest == nil {
+		response.Error = &Error{Code: CodeMethodNotFound, Message: "method not found: " + request.Method}
+	} else if result, err := c.onRequest(request.Method, request.Params); err != nil {
+		var rpcErr *Error
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &Error{Code: CodeInternalError, Message: err.Error()}
+		}
+		response.Error = rpcErr
+	} else {
+		data, err := json.Marshal(result)
+		if err != nil {
+			response.Error = &Error{Code: CodeInternalError, Message: err.Error()}
+		} else {
+			response.Result = data
+		}
+	}
+
+	c.write(&response)
+}
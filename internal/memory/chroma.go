@@ -57,7 +57,7 @@ type ChromaVectorStore struct {
 }
 
 // NewChromaMemoryStore creates a new Chroma memory store
-func NewChromaMemoryStore(config types.MemoryConfig) (*ChromaMemoryStore, error) {
+func NewChromaMemoryStore(config MemoryConfig) (*ChromaMemoryStore, error) {
 	// Set default connection string
 	baseURL := "http://localhost:8000"
 	if config.ConnectionString != "" {
@@ -505,12 +505,47 @@ func (s *ChromaMemoryStore) executeRequest(method, url string, payload interface
 
 // SaveEmbedding stores a vector embedding
 func (s *ChromaMemoryStore) SaveEmbedding(ctx context.Context, key string, vector []float32, metadata map[string]interface{}) error {
+	// Create request payload
+	payload := map[string]interface{}{
+		"ids":        []string{key},
+		"embeddings": [][]float32{vector},
+		"metadatas":  []map[string]interface{}{s.embeddingMetadata(metadata)},
+	}
+
+	// Upsert embedding
+	url := fmt.Sprintf("%s/upsert", s.collectionURL())
+
+	// Execute request
+	err := s.executeRequest("POST", url, payload, nil)
+	if err != nil {
+		return fmt.Errorf("failed to upsert embedding: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateMetadata replaces the metadata of a stored embedding
+func (s *ChromaMemoryStore) UpdateMetadata(ctx context.Context, key string, metadata map[string]interface{}) error {
+	payload := map[string]interface{}{
+		"ids":       []string{key},
+		"metadatas": []map[string]interface{}{s.embeddingMetadata(metadata)},
+	}
+
+	url := fmt.Sprintf("%s/update", s.collectionURL())
+	if err := s.executeRequest("POST", url, payload, nil); err != nil {
+		return fmt.Errorf("failed to update embedding metadata: %w", err)
+	}
+
+	return nil
+}
+
+// embeddingMetadata copies the metadata of an embedding and adds the
+// store's namespace, update time and expiry
+func (s *ChromaMemoryStore) embeddingMetadata(metadata map[string]interface{}) map[string]interface{} {
 	// Create copy of metadata
 	metadataCopy := make(map[string]interface{})
-	if metadata != nil {
-		for k, v := range metadata {
-			metadataCopy[k] = v
-		}
+	for k, v := range metadata {
+		metadataCopy[k] = v
 	}
 
 	// Add namespace to metadata if specified
@@ -526,23 +561,7 @@ func (s *ChromaMemoryStore) SaveEmbedding(ctx context.Context, key string, vecto
 		metadataCopy["expires_at"] = time.Now().Add(s.ttl).Format(time.RFC3339)
 	}
 
-	// Create request payload
-	payload := map[string]interface{}{
-		"ids":        []string{key},
-		"embeddings": [][]float32{vector},
-		"metadatas":  []map[string]interface{}{metadataCopy},
-	}
-
-	// Upsert embedding
-	url := fmt.Sprintf("%s/upsert", s.collectionURL())
-
-	// Execute request
-	err := s.executeRequest("POST", url, payload, nil)
-	if err != nil {
-		return fmt.Errorf("failed to upsert embedding: %w", err)
-	}
-
-	return nil
+	return metadataCopy
 }
 
 // Query performs a similarity search on stored embeddings
@@ -600,7 +619,7 @@ func (s *ChromaMemoryStore) DeleteEmbedding(ctx context.Context, key string) err
 }
 
 // NewChromaVectorStore creates a new vector store backed by Chroma
-func NewChromaVectorStore(config types.MemoryConfig) (*ChromaVectorStore, error) {
+func NewChromaVectorStore(config MemoryConfig) (*ChromaVectorStore, error) {
 	// Set defaults
 	baseURL := "http://localhost:8000"
 	dimensions := 1536
@@ -3,29 +3,27 @@ package memory
 import (
 	"fmt"
 	"sync"
-
-	"github.com/satishgonella2024/sentinelstacks/pkg/types"
 )
 
 // DefaultFactory is the default implementation of MemoryStoreFactory
 type DefaultFactory struct {
 	mu               sync.Mutex
-	memoryStores     map[string]types.MemoryStore
-	vectorStores     map[string]types.VectorStore
-	overrideCreators map[types.MemoryStoreType]func(config types.MemoryConfig) (types.MemoryStore, error)
+	memoryStores     map[string]MemoryStore
+	vectorStores     map[string]VectorStore
+	overrideCreators map[MemoryStoreType]func(config MemoryConfig) (MemoryStore, error)
 }
 
 // NewDefaultFactory creates a new default factory
 func NewDefaultFactory() *DefaultFactory {
 	return &DefaultFactory{
-		memoryStores:     make(map[string]types.MemoryStore),
-		vectorStores:     make(map[string]types.VectorStore),
-		overrideCreators: make(map[types.MemoryStoreType]func(config types.MemoryConfig) (types.MemoryStore, error)),
+		memoryStores:     make(map[string]MemoryStore),
+		vectorStores:     make(map[string]VectorStore),
+		overrideCreators: make(map[MemoryStoreType]func(config MemoryConfig) (MemoryStore, error)),
 	}
 }
 
 // Create creates a new memory store of the requested type
-func (f *DefaultFactory) Create(storeType types.MemoryStoreType, config types.MemoryConfig) (types.MemoryStore, error) {
+func (f *DefaultFactory) Create(storeType MemoryStoreType, config MemoryConfig) (MemoryStore, error) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
@@ -54,15 +52,15 @@ func (f *DefaultFactory) Create(storeType types.MemoryStoreType, config types.Me
 	}
 
 	// Create new store based on type
-	var store types.MemoryStore
+	var store MemoryStore
 	var err error
 
 	switch storeType {
-	case types.MemoryStoreTypeLocal:
+	case MemoryStoreTypeLocal:
 		store, err = NewLocalMemoryStore(config)
-	case types.MemoryStoreTypeSQLite:
+	case MemoryStoreTypeSQLite:
 		store, err = NewSQLiteMemoryStore(config)
-	case types.MemoryStoreTypeChroma:
+	case MemoryStoreTypeChroma:
 		store, err = NewChromaMemoryStore(config)
 	default:
 		return nil, fmt.Errorf("unsupported memory store type: %s", storeType)
@@ -78,13 +76,13 @@ func (f *DefaultFactory) Create(storeType types.MemoryStoreType, config types.Me
 	return store, nil
 }
 
-// CreateVector creates a new vector store
-func (f *DefaultFactory) CreateVector(config types.MemoryConfig) (types.VectorStore, error) {
+// CreateVector creates a new vector store of the requested type
+func (f *DefaultFactory) CreateVector(storeType MemoryStoreType, config MemoryConfig) (VectorStore, error) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
 	// Generate store key
-	storeKey := "vector"
+	storeKey := "vector:" + string(storeType)
 	if config.CollectionName != "" {
 		storeKey += ":" + config.CollectionName
 	}
@@ -98,19 +96,18 @@ func (f *DefaultFactory) CreateVector(config types.MemoryConfig) (types.VectorSt
 	}
 
 	// Create new store based on type
-	var store types.VectorStore
+	var store VectorStore
 	var err error
 
-	// Determine which vector store to create based on configuration
-	if config.StoragePath != "" && config.StoragePath != "memory" {
-		// If storage path is provided, use SQLite vector store
-		store, err = NewSQLiteVectorStore(config)
-	} else if config.ConnectionString != "" {
-		// If connection string is provided, use Chroma vector store
-		store, err = NewChromaVectorStore(config)
-	} else {
-		// Default to local in-memory vector store
+	switch storeType {
+	case MemoryStoreTypeLocal:
 		store, err = NewLocalVectorStore(config)
+	case MemoryStoreTypeSQLite:
+		store, err = NewSQLiteVectorStore(config)
+	case MemoryStoreTypeChroma:
+		store, err = NewChromaMemoryStore(config)
+	default:
+		return nil, fmt.Errorf("unsupported vector store type: %s", storeType)
 	}
 
 	if err != nil {
@@ -124,13 +121,13 @@ func (f *DefaultFactory) CreateVector(config types.MemoryConfig) (types.VectorSt
 }
 
 // RegisterOverride registers a custom creator for a memory store type
-func (f *DefaultFactory) RegisterOverride(storeType types.MemoryStoreType, creator func(config types.MemoryConfig) (types.MemoryStore, error)) {
+func (f *DefaultFactory) RegisterOverride(storeType MemoryStoreType, creator func(config MemoryConfig) (MemoryStore, error)) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 	f.overrideCreators[storeType] = creator
 }
 
 // NewMemoryStoreFactory creates a new memory store factory
-func NewMemoryStoreFactory(storageBasePath string) (types.MemoryStoreFactory, error) {
+func NewMemoryStoreFactory(storageBasePath string) (MemoryStoreFactory, error) {
 	return NewDefaultFactory(), nil
 }
@@ -22,7 +22,7 @@ type LocalMemoryStore struct {
 }
 
 // NewLocalMemoryStore creates a new local memory store
-func NewLocalMemoryStore(config types.MemoryConfig) (*LocalMemoryStore, error) {
+func NewLocalMemoryStore(config MemoryConfig) (*LocalMemoryStore, error) {
 	name := "local-memory"
 	if config.CollectionName != "" {
 		name = config.CollectionName
@@ -75,7 +75,7 @@ func (s *LocalMemoryStore) Load(ctx context.Context, key string) (interface{}, e
 	// Check if entry exists
 	entry, ok := s.data[key]
 	if !ok {
-		return nil, fmt.Errorf("key not found: %s", key)
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, key)
 	}
 
 	// Check if entry has expired
@@ -186,7 +186,7 @@ type LocalVectorStore struct {
 }
 
 // NewLocalVectorStore creates a new local vector store
-func NewLocalVectorStore(config types.MemoryConfig) (*LocalVectorStore, error) {
+func NewLocalVectorStore(config MemoryConfig) (*LocalVectorStore, error) {
 	// Default vector dimensions
 	maxDim := 1536
 	if config.VectorDimensions > 0 {
@@ -345,6 +345,50 @@ func (s *LocalVectorStore) DeleteVector(ctx context.Context, id string) error {
 	return nil
 }
 
+// SaveEmbedding stores an embedding with its metadata
+func (s *LocalVectorStore) SaveEmbedding(ctx context.Context, key string, vector []float32, metadata map[string]interface{}) error {
+	return s.StoreVector(ctx, key, vector, metadata)
+}
+
+// Query returns the topK embeddings most similar to vector
+func (s *LocalVectorStore) Query(ctx context.Context, vector []float32, topK int) ([]SimilarityMatch, error) {
+	results, err := s.FindSimilar(ctx, vector, topK)
+	if err != nil {
+		return nil, err
+	}
+	return SimilarityResultsToMatches(results), nil
+}
+
+// UpdateMetadata replaces the metadata of a stored embedding
+func (s *LocalVectorStore) UpdateMetadata(ctx context.Context, key string, metadata map[string]interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	storeID := key
+	if s.namespace != "" {
+		storeID = s.namespace + ":" + key
+	}
+	if _, ok := s.vectors[storeID]; !ok {
+		return fmt.Errorf("%w: %s", ErrKeyNotFound, key)
+	}
+	s.metadata[storeID] = metadata
+	return nil
+}
+
+// DeleteEmbedding removes an embedding, if it is stored
+func (s *LocalVectorStore) DeleteEmbedding(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	storeID := key
+	if s.namespace != "" {
+		storeID = s.namespace + ":" + key
+	}
+	delete(s.vectors, storeID)
+	delete(s.metadata, storeID)
+	return nil
+}
+
 // Clear removes all vectors
 func (s *LocalVectorStore) Clear(ctx context.Context) error {
 	s.mu.Lock()
@@ -4,18 +4,16 @@ package memory
 import (
 	"fmt"
 	"plugin"
-
-	"github.com/satishgonella2024/sentinelstacks/pkg/types"
 )
 
 // PluginMemoryStoreFactory is a factory that loads memory stores from plugins
 type PluginMemoryStoreFactory struct {
-	baseFactory types.MemoryStoreFactory
+	baseFactory MemoryStoreFactory
 	plugins     map[string]*plugin.Plugin
 }
 
 // NewPluginMemoryStoreFactory creates a new plugin memory store factory
-func NewPluginMemoryStoreFactory(baseFactory types.MemoryStoreFactory) *PluginMemoryStoreFactory {
+func NewPluginMemoryStoreFactory(baseFactory MemoryStoreFactory) *PluginMemoryStoreFactory {
 	return &PluginMemoryStoreFactory{
 		baseFactory: baseFactory,
 		plugins:     make(map[string]*plugin.Plugin),
@@ -23,7 +21,7 @@ func NewPluginMemoryStoreFactory(baseFactory types.MemoryStoreFactory) *PluginMe
 }
 
 // Create creates a new memory store from a plugin
-func (f *PluginMemoryStoreFactory) Create(storeType types.MemoryStoreType, config types.MemoryConfig) (types.MemoryStore, error) {
+func (f *PluginMemoryStoreFactory) Create(storeType MemoryStoreType, config MemoryConfig) (MemoryStore, error) {
 	// First try to create from base factory
 	store, err := f.baseFactory.Create(storeType, config)
 	if err == nil {
@@ -49,7 +47,7 @@ func (f *PluginMemoryStoreFactory) Create(storeType types.MemoryStoreType, confi
 	}
 
 	// Convert to function
-	createFunc, ok := createSym.(func(types.MemoryConfig) (types.MemoryStore, error))
+	createFunc, ok := createSym.(func(MemoryConfig) (MemoryStore, error))
 	if !ok {
 		return nil, fmt.Errorf("invalid CreateMemoryStore function signature in plugin")
 	}
@@ -59,23 +57,23 @@ func (f *PluginMemoryStoreFactory) Create(storeType types.MemoryStoreType, confi
 }
 
 // CreateVector creates a new vector store from a plugin
-func (f *PluginMemoryStoreFactory) CreateVector(config types.MemoryConfig) (types.VectorStore, error) {
+func (f *PluginMemoryStoreFactory) CreateVector(storeType MemoryStoreType, config MemoryConfig) (VectorStore, error) {
 	// First try to create from base factory
-	store, err := f.baseFactory.CreateVector(config)
+	store, err := f.baseFactory.CreateVector(storeType, config)
 	if err == nil {
 		return store, nil
 	}
 
 	// Try to load from plugin
-	pluginPath := fmt.Sprintf("vector_%s.so", config.CollectionName)
-	p, ok := f.plugins[config.CollectionName]
+	pluginPath := fmt.Sprintf("vector_%s.so", storeType)
+	p, ok := f.plugins["vector_"+string(storeType)]
 	if !ok {
 		var err error
 		p, err = plugin.Open(pluginPath)
 		if err != nil {
 			return nil, fmt.Errorf("failed to load vector store plugin: %w", err)
 		}
-		f.plugins[config.CollectionName] = p
+		f.plugins["vector_"+string(storeType)] = p
 	}
 
 	// Get create function
@@ -85,7 +83,7 @@ func (f *PluginMemoryStoreFactory) CreateVector(config types.MemoryConfig) (type
 	}
 
 	// Convert to function
-	createFunc, ok := createSym.(func(types.MemoryConfig) (types.VectorStore, error))
+	createFunc, ok := createSym.(func(MemoryConfig) (VectorStore, error))
 	if !ok {
 		return nil, fmt.Errorf("invalid CreateVectorStore function signature in plugin")
 	}
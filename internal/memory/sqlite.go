@@ -13,7 +13,6 @@ import (
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
-	"github.com/satishgonella2024/sentinelstacks/pkg/types"
 )
 
 // SQLiteMemoryStore is a SQLite-backed implementation of MemoryStore
@@ -25,7 +24,7 @@ type SQLiteMemoryStore struct {
 }
 
 // NewSQLiteMemoryStore creates a new SQLite memory store
-func NewSQLiteMemoryStore(config types.MemoryConfig) (*SQLiteMemoryStore, error) {
+func NewSQLiteMemoryStore(config MemoryConfig) (*SQLiteMemoryStore, error) {
 	tableName := "memory"
 	if config.CollectionName != "" {
 		// Replace any invalid characters in table name
@@ -148,7 +147,7 @@ func (s *SQLiteMemoryStore) Load(ctx context.Context, key string) (interface{},
 	err := s.db.QueryRowContext(ctx, querySQL, key).Scan(&valueStr, &createdAt, &updatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("key not found: %s", key)
+			return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, key)
 		}
 		return nil, fmt.Errorf("failed to load value: %w", err)
 	}
@@ -524,6 +523,29 @@ func (s *SQLiteVectorStore) Query(ctx context.Context, vector []float32, topK in
 	return results, nil
 }
 
+// UpdateMetadata replaces the metadata of a stored embedding
+func (s *SQLiteVectorStore) UpdateMetadata(ctx context.Context, key string, metadata map[string]interface{}) error {
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	result, err := s.db.ExecContext(ctx, fmt.Sprintf("UPDATE %s SET metadata = ?, updated_at = ? WHERE id = ?", s.tableName),
+		string(metadataJSON), time.Now().UTC(), s.getNamespacedKey(key))
+	if err != nil {
+		return fmt.Errorf("failed to update metadata: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("%w: %s", ErrKeyNotFound, key)
+	}
+	return nil
+}
+
 // DeleteEmbedding removes an embedding
 func (s *SQLiteVectorStore) DeleteEmbedding(ctx context.Context, key string) error {
 	fullKey := s.getNamespacedKey(key)
@@ -1,7 +1,93 @@
 // Package memory provides memory storage implementations
 package memory
 
-import "github.com/satishgonella2024/sentinelstacks/pkg/types"
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/satishgonella2024/sentinelstacks/pkg/types"
+)
+
+// MemoryStoreType is the type of a memory store
+type MemoryStoreType = types.MemoryStoreType
+
+// Memory store types
+const (
+	MemoryStoreTypeLocal  = types.MemoryStoreTypeLocal
+	MemoryStoreTypeSQLite = types.MemoryStoreTypeSQLite
+	MemoryStoreTypeChroma = types.MemoryStoreTypeChroma
+)
+
+// ErrKeyNotFound is returned by Load when no entry has the key
+var ErrKeyNotFound = errors.New("key not found")
+
+// MemoryConfig configures a memory store
+type MemoryConfig struct {
+	// CollectionName is the collection or table the store keeps entries in
+	CollectionName string
+
+	// Namespace scopes the store's keys within the collection
+	Namespace string
+
+	// StoragePath is where file-backed stores keep their data
+	StoragePath string
+
+	// ConnectionString is the address of a server-backed store
+	ConnectionString string
+
+	// TTL is how long entries are kept, or 0 to keep them
+	TTL time.Duration
+
+	// VectorDimensions is the dimensionality of stored embeddings
+	VectorDimensions int
+}
+
+// MemoryStore stores values by key
+type MemoryStore interface {
+	// Save stores a value with the given key
+	Save(ctx context.Context, key string, value interface{}) error
+
+	// Load retrieves a value by key
+	Load(ctx context.Context, key string) (interface{}, error)
+
+	// Delete removes a value by key
+	Delete(ctx context.Context, key string) error
+
+	// Clear removes all values
+	Clear(ctx context.Context) error
+
+	// Close releases resources
+	Close() error
+}
+
+// VectorStore stores embeddings and finds those similar to a query
+type VectorStore interface {
+	// SaveEmbedding stores an embedding with its metadata
+	SaveEmbedding(ctx context.Context, key string, vector []float32, metadata map[string]interface{}) error
+
+	// Query returns the topK embeddings most similar to vector
+	Query(ctx context.Context, vector []float32, topK int) ([]SimilarityMatch, error)
+
+	// UpdateMetadata replaces the metadata of a stored embedding, keeping
+	// its vector
+	UpdateMetadata(ctx context.Context, key string, metadata map[string]interface{}) error
+
+	// DeleteEmbedding removes an embedding
+	DeleteEmbedding(ctx context.Context, key string) error
+
+	// Close releases resources
+	Close() error
+}
+
+// MemoryStoreFactory creates memory and vector stores
+type MemoryStoreFactory interface {
+	// Create creates a memory store of the given type
+	Create(storeType MemoryStoreType, config MemoryConfig) (MemoryStore, error)
+
+	// CreateVector creates a vector store of the given type
+	CreateVector(storeType MemoryStoreType, config MemoryConfig) (VectorStore, error)
+}
 
 // SimilarityMatch represents a similarity search result for backwards compatibility
 // This should be replaced with types.SimilarityResult in the future
@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
@@ -8,6 +9,7 @@ import (
 
 	"github.com/satishgonella2024/sentinelstacks/internal/shim"
 	"github.com/satishgonella2024/sentinelstacks/pkg/agent"
+	"github.com/satishgonella2024/sentinelstacks/pkg/types"
 )
 
 // SentinelfileParser handles parsing Sentinelfiles
@@ -111,6 +113,25 @@ func (p *SentinelfileParser) parseLLM(content string) (*agent.Definition, error)
 		}
 	}
 
+	// Extract the MCP servers
+	if servers, ok := result["mcpServers"].([]interface{}); ok {
+		for _, server := range servers {
+			var config types.MCPServerConfig
+			data, err := json.Marshal(server)
+			if err == nil {
+				err = json.Unmarshal(data, &config)
+			}
+			if err == nil {
+				err = config.Validate()
+			}
+			if err != nil {
+				fmt.Printf("Warning: Ignoring invalid MCP server: %v\n", err)
+				continue
+			}
+			def.MCPServers = append(def.MCPServers, config)
+		}
+	}
+
 	// Extract the parameters
 	if parameters, ok := result["parameters"].(map[string]interface{}); ok {
 		def.Parameters = parameters
@@ -364,6 +364,18 @@ func validateStackSpec(spec stack.StackSpec) error {
 		agentIDs[agent.ID] = true
 	}
 	
+	// Check MCP server declarations
+	serverNames := make(map[string]bool)
+	for _, server := range spec.MCPServers {
+		if err := server.Validate(); err != nil {
+			return err
+		}
+		if serverNames[server.Name] {
+			return fmt.Errorf("duplicate MCP server name detected: %s", server.Name)
+		}
+		serverNames[server.Name] = true
+	}
+	
 	// Check for references to non-existent agents
 	for _, agent := range spec.Agents {
 		for _, inputFrom := range agent.InputFrom {
@@ -373,6 +385,11 @@ func validateStackSpec(spec stack.StackSpec) error {
 		}
 	}
 	
+	// Check the output references of inputs mappings, conditions and forEach
+	if err := stack.ValidateOutputReferences(spec); err != nil {
+		return err
+	}
+	
 	return nil
 }
 
@@ -2,43 +2,31 @@
 package auth
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
-
-	"github.com/golang-jwt/jwt/v5"
 )
 
-// TokenProvider provides authentication tokens for registry operations
-type TokenProvider interface {
-	// GetToken returns a valid authentication token
-	GetToken(ctx context.Context) (string, error)
-
-	// Login performs authentication and returns a token
-	Login(ctx context.Context, username, password string) (string, error)
-
-	// Logout invalidates the current token
-	Logout(ctx context.Context) error
-
-	// IsAuthenticated checks if the client is authenticated
-	IsAuthenticated(ctx context.Context) bool
-}
-
 // AuthConfig contains configuration for authentication
 type AuthConfig struct {
 	RegistryURL string
 	TokenFile   string
 }
 
-// FileTokenProvider implements TokenProvider with file-based token storage
+// FileTokenProvider implements types.AuthProvider with file-based token storage
 type FileTokenProvider struct {
 	registryURL string
 	tokenFile   string
 	token       string
+	expiresAt   time.Time
+	httpClient  *http.Client
 }
 
 // TokenData represents the stored token data
@@ -68,6 +56,7 @@ func NewFileTokenProvider(config AuthConfig) (*FileTokenProvider, error) {
 	provider := &FileTokenProvider{
 		registryURL: config.RegistryURL,
 		tokenFile:   tokenFile,
+		httpClient:  &http.Client{Timeout: 30 * time.Second},
 	}
 
 	// Load token if exists
@@ -96,38 +85,66 @@ func (p *FileTokenProvider) GetToken(ctx context.Context) (string, error) {
 	return "", fmt.Errorf("authentication required")
 }
 
-// Login performs authentication and returns a token
+// Login exchanges a username and password for a token at the registry's
+// login endpoint and stores it in the token file
 func (p *FileTokenProvider) Login(ctx context.Context, username, password string) (string, error) {
-	// TODO: Implement actual API call to authenticate
-	// For now, generate a demo token
+	reqBody, err := json.Marshal(LoginRequest{Username: username, Password: password})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal login request: %w", err)
+	}
 
-	// Create token claims
-	claims := jwt.MapClaims{
-		"sub": username,
-		"iat": time.Now().Unix(),
-		"exp": time.Now().Add(24 * time.Hour).Unix(),
+	// Create request
+	url := fmt.Sprintf("%s/v1/auth/login", strings.TrimSuffix(p.registryURL, "/"))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(reqBody))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
 	}
+	req.Header.Set("Content-Type", "application/json")
 
-	// Create token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte("demo-secret-key"))
+	// Send request
+	resp, err := p.httpClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to generate token: %w", err)
+		return "", fmt.Errorf("login request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("login failed: %s (status: %d)", strings.TrimSpace(string(body)), resp.StatusCode)
+	}
+
+	// Parse response
+	var loginResp LoginResponse
+	if err := json.Unmarshal(body, &loginResp); err != nil {
+		return "", fmt.Errorf("failed to parse login response: %w", err)
+	}
+	if loginResp.Token == "" {
+		return "", fmt.Errorf("login response did not contain a token")
+	}
+
+	expiresAt := loginResp.Expires
+	if expiresAt.IsZero() {
+		expiresAt = time.Now().Add(time.Duration(loginResp.ExpiresIn) * time.Second)
 	}
 
 	// Save token
-	p.token = tokenString
-	if err := p.saveToken(tokenString, time.Now().Add(24*time.Hour)); err != nil {
+	p.token = loginResp.Token
+	p.expiresAt = expiresAt
+	if err := p.saveToken(loginResp.Token, expiresAt); err != nil {
 		return "", fmt.Errorf("failed to save token: %w", err)
 	}
 
-	return tokenString, nil
+	return loginResp.Token, nil
 }
 
 // Logout invalidates the current token
 func (p *FileTokenProvider) Logout(ctx context.Context) error {
 	// Clear token
 	p.token = ""
+	p.expiresAt = time.Time{}
 
 	// Remove token file
 	if err := os.Remove(p.tokenFile); err != nil && !os.IsNotExist(err) {
@@ -142,37 +159,10 @@ func (p *FileTokenProvider) IsAuthenticated(ctx context.Context) bool {
 	return p.isTokenValid()
 }
 
-// isTokenValid checks if the current token is valid
+// isTokenValid checks if the current token is set and not expired. The
+// token is signed by the registry, so only the registry can verify it.
 func (p *FileTokenProvider) isTokenValid() bool {
-	if p.token == "" {
-		return false
-	}
-
-	// Parse token
-	token, err := jwt.Parse(p.token, func(token *jwt.Token) (interface{}, error) {
-		// Validate signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-
-		// Return signing key
-		return []byte("demo-secret-key"), nil
-	})
-
-	// Check if token is valid
-	if err != nil {
-		return false
-	}
-
-	// Check claims
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		// Check expiration
-		if exp, ok := claims["exp"].(float64); ok {
-			return time.Now().Unix() < int64(exp)
-		}
-	}
-
-	return false
+	return p.token != "" && time.Now().Before(p.expiresAt)
 }
 
 // loadToken loads the token from the token file
@@ -201,6 +191,7 @@ func (p *FileTokenProvider) loadToken() error {
 
 	// Set token
 	p.token = tokenData.Token
+	p.expiresAt = tokenData.ExpiresAt
 
 	return nil
 }
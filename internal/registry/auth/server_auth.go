@@ -0,0 +1,313 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// passwordHashScheme identifies the password hash format in user files
+	passwordHashScheme = "pbkdf2-sha256"
+
+	// passwordHashIterations is the PBKDF2 work factor for new hashes
+	passwordHashIterations = 210000
+
+	// DefaultTokenTTL is how long registry tokens stay valid
+	DefaultTokenTTL = 24 * time.Hour
+
+	// tokenIssuer is the issuer claim of registry tokens
+	tokenIssuer = "sentinel-registry"
+)
+
+// ErrInvalidToken is returned for tokens that are malformed, expired or not
+// signed by the registry
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// LoginRequest is the body of a registry login request
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// LoginResponse is the body of a successful registry login. Expires and
+// ExpiresIn describe the same instant for clients that expect either.
+type LoginResponse struct {
+	Token     string    `json:"token"`
+	Expires   time.Time `json:"expires"`
+	ExpiresIn int64     `json:"expiresIn"`
+}
+
+// UserStore holds the users of a registry server and their password hashes.
+// It is stored as a JSON object mapping usernames to hashes.
+type UserStore struct {
+	path  string
+	mu    sync.RWMutex
+	users map[string]string
+}
+
+// LoadUserStore loads the user file at path. A missing file yields an
+// empty store that is created on the first Add.
+func LoadUserStore(path string) (*UserStore, error) {
+	store := &UserStore{
+		path:  path,
+		users: make(map[string]string),
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read user file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &store.users); err != nil {
+		return nil, fmt.Errorf("failed to parse user file %s: %w", path, err)
+	}
+
+	return store, nil
+}
+
+// Add creates or updates a user and saves the store
+func (s *UserStore) Add(username, password string) error {
+	if username == "" || strings.ContainsAny(username, " :/") {
+		return fmt.Errorf("invalid username %q", username)
+	}
+	if password == "" {
+		return fmt.Errorf("password must not be empty")
+	}
+
+	hash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[username] = hash
+	return s.save()
+}
+
+// Remove deletes a user and saves the store
+func (s *UserStore) Remove(username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.users[username]; !exists {
+		return fmt.Errorf("user %s not found", username)
+	}
+	delete(s.users, username)
+	return s.save()
+}
+
+// Usernames returns the registered users in order
+func (s *UserStore) Usernames() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := make([]string, 0, len(s.users))
+	for name := range s.users {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Authenticate reports whether the password is correct for the user
+func (s *UserStore) Authenticate(username, password string) bool {
+	s.mu.RLock()
+	hash, exists := s.users[username]
+	s.mu.RUnlock()
+
+	if !exists {
+		// Spend the same time as for a known user
+		VerifyPassword(dummyPasswordHash, password)
+		return false
+	}
+	return VerifyPassword(hash, password)
+}
+
+// save writes the store to its file; the caller holds the lock
+func (s *UserStore) save() error {
+	data, err := json.MarshalIndent(s.users, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal users: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create user file directory: %w", err)
+	}
+	if err := os.WriteFile(s.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write user file: %w", err)
+	}
+
+	return nil
+}
+
+// dummyPasswordHash is verified against for unknown users
+var dummyPasswordHash = fmt.Sprintf("%s$%d$%s$%s", passwordHashScheme, passwordHashIterations,
+	base64.RawStdEncoding.EncodeToString(make([]byte, 16)), base64.RawStdEncoding.EncodeToString(make([]byte, 32)))
+
+// HashPassword returns a salted PBKDF2-SHA256 hash of a password in the
+// form pbkdf2-sha256$<iterations>$<salt>$<hash>
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	key := pbkdf2SHA256([]byte(password), salt, passwordHashIterations, sha256.Size)
+	return fmt.Sprintf("%s$%d$%s$%s", passwordHashScheme, passwordHashIterations,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// VerifyPassword reports whether a password matches a hash from HashPassword
+func VerifyPassword(hash, password string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != passwordHashScheme {
+		return false
+	}
+
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations < 1 {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	expected, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return false
+	}
+
+	key := pbkdf2SHA256([]byte(password), salt, iterations, len(expected))
+	return subtle.ConstantTimeCompare(key, expected) == 1
+}
+
+// pbkdf2SHA256 derives a key with PBKDF2 (RFC 8018) using HMAC-SHA256
+func pbkdf2SHA256(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	key := make([]byte, 0, keyLen)
+
+	for block := uint32(1); len(key) < keyLen; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		var counter [4]byte
+		binary.BigEndian.PutUint32(counter[:], block)
+		prf.Write(counter[:])
+		u := prf.Sum(nil)
+
+		t := make([]byte, len(u))
+		copy(t, u)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+
+	return key[:keyLen]
+}
+
+// TokenIssuer issues and verifies the bearer tokens of a registry server
+type TokenIssuer struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+// NewTokenIssuer creates a token issuer that signs tokens with secret. A
+// ttl of zero uses DefaultTokenTTL.
+func NewTokenIssuer(secret []byte, ttl time.Duration) *TokenIssuer {
+	if ttl <= 0 {
+		ttl = DefaultTokenTTL
+	}
+	return &TokenIssuer{secret: secret, ttl: ttl}
+}
+
+// Issue returns a signed token for a user and its expiry time
+func (i *TokenIssuer) Issue(username string) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(i.ttl)
+
+	claims := jwt.RegisteredClaims{
+		Issuer:    tokenIssuer,
+		Subject:   username,
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(i.secret)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to sign token: %w", err)
+	}
+
+	return token, expiresAt, nil
+}
+
+// Verify checks a token's signature and expiry and returns its user
+func (i *TokenIssuer) Verify(tokenString string) (string, error) {
+	claims := &jwt.RegisteredClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return i.secret, nil
+	}, jwt.WithIssuer(tokenIssuer), jwt.WithExpirationRequired())
+	if err != nil || !token.Valid || claims.Subject == "" {
+		return "", ErrInvalidToken
+	}
+
+	return claims.Subject, nil
+}
+
+// LoadOrCreateSecret reads the token signing secret at path, generating
+// and saving a random one if the file does not exist
+func LoadOrCreateSecret(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		secret, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(secret) < 32 {
+			return nil, fmt.Errorf("invalid token secret in %s", path)
+		}
+		return secret, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read token secret: %w", err)
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate token secret: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create secret directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(hex.EncodeToString(secret)), 0600); err != nil {
+		return nil, fmt.Errorf("failed to write token secret: %w", err)
+	}
+
+	return secret, nil
+}
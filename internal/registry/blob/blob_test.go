@@ -0,0 +1,140 @@
+package blob
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStorePutVerifiesDigest(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte("You are a helpful assistant.")
+	d, err := store.PutBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d != FromBytes(data) || !store.Has(d) {
+		t.Fatalf("blob %s not stored", d)
+	}
+
+	if _, _, err := store.Put(strings.NewReader("tampered"), FromBytes([]byte("other"))); !errors.Is(err, ErrDigestMismatch) {
+		t.Fatalf("Put with wrong digest = %v, want ErrDigestMismatch", err)
+	}
+
+	if _, err := Parse("sha256:ABC"); err == nil {
+		t.Fatal("Parse accepted an invalid digest")
+	}
+}
+
+func TestImportAndWriteArchive(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manifest := []byte(`{"name":"summarizer","version":"1.0.0","files":[{"path":"agent.yaml","isMain":true}]}`)
+	files := map[string]string{
+		"agent.yaml":       "name: summarizer",
+		"prompts/main.txt": "Summarize the input.",
+		"prompts/copy.txt": "Summarize the input.",
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	tw.WriteHeader(&tar.Header{Name: ArchiveManifestName, Mode: 0644, Size: int64(len(manifest))})
+	tw.Write(manifest)
+	for name, content := range files {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))})
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	gz.Close()
+
+	// Builder archives carry a header before the gzip stream
+	archivePath := filepath.Join(t.TempDir(), "summarizer.sentinel-pkg")
+	if err := os.WriteFile(archivePath, append([]byte("SNTL-AGENT-PKG1.0\x00"), buf.Bytes()...), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m, d, config, err := ImportArchive(store, archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Name != "summarizer" || len(m.Layers) != 3 {
+		t.Fatalf("imported %s with %d layers", config.Name, len(m.Layers))
+	}
+	// The two identical prompts share one blob
+	if blobs := m.Blobs(); len(blobs) != 3 {
+		t.Fatalf("manifest references %d distinct blobs, want 3", len(blobs))
+	}
+	for _, layer := range m.Layers {
+		if layer.Title() == "agent.yaml" && layer.Annotations[AnnotationMain] != "true" {
+			t.Fatal("agent.yaml not marked as the main file")
+		}
+	}
+
+	if err := store.Tag("summarizer", "1.0.0", d); err != nil {
+		t.Fatal(err)
+	}
+	tagged, _, err := store.Manifest("summarizer", "1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := WriteArchive(&out, store, tagged); err != nil {
+		t.Fatal(err)
+	}
+	tr, closer, err := OpenArchive(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closer.Close()
+
+	written := make(map[string]string)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := io.ReadAll(tr)
+		written[header.Name] = string(content)
+	}
+	if written[ArchiveManifestName] != string(manifest) {
+		t.Fatalf("archive manifest = %q", written[ArchiveManifestName])
+	}
+	for name, content := range files {
+		if written[name] != content {
+			t.Fatalf("%s = %q, want %q", name, written[name], content)
+		}
+	}
+}
+
+func TestParseManifestRejectsUnsafePaths(t *testing.T) {
+	config := Descriptor{MediaType: MediaTypeConfig, Digest: FromBytes([]byte("{}")), Size: 2}
+	for _, path := range []string{"../escape", "/etc/passwd", "a/../../b", ""} {
+		m := newManifest(config)
+		m.Layers = append(m.Layers, fileDescriptor(path, FromBytes([]byte("x")), 1, false, ""))
+		data, _, err := m.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ParseManifest(data); err == nil {
+			t.Errorf("ParseManifest accepted layer path %q", path)
+		}
+	}
+}
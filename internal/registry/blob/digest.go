@@ -0,0 +1,85 @@
+// Package blob implements content-addressed storage for registry packages.
+// A package is a set of blobs, one per file plus a config blob holding its
+// package manifest, tied together by an OCI-style manifest that references
+// every blob by digest. Identical files are stored and transferred once.
+package blob
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Algorithm is the digest algorithm used for blobs
+const Algorithm = "sha256"
+
+// Digest identifies a blob by its content, as "sha256:<hex>"
+type Digest string
+
+// FromBytes returns the digest of a byte slice
+func FromBytes(data []byte) Digest {
+	sum := sha256.Sum256(data)
+	return Digest(Algorithm + ":" + hex.EncodeToString(sum[:]))
+}
+
+// FromReader returns the digest and size of everything read from r
+func FromReader(r io.Reader) (Digest, int64, error) {
+	hash := sha256.New()
+	size, err := io.Copy(hash, r)
+	if err != nil {
+		return "", 0, err
+	}
+	return Digest(Algorithm + ":" + hex.EncodeToString(hash.Sum(nil))), size, nil
+}
+
+// FromHex returns the digest of a hex-encoded SHA256 sum, as recorded in
+// package manifests
+func FromHex(sum string) Digest {
+	return Digest(Algorithm + ":" + strings.ToLower(sum))
+}
+
+// Parse validates a digest string
+func Parse(s string) (Digest, error) {
+	d := Digest(s)
+	if err := d.Validate(); err != nil {
+		return "", err
+	}
+	return d, nil
+}
+
+// Validate checks that a digest is a well-formed sha256 digest
+func (d Digest) Validate() error {
+	algorithm, encoded, ok := strings.Cut(string(d), ":")
+	if !ok || algorithm != Algorithm {
+		return fmt.Errorf("invalid digest %q: expected %s:<hex>", d, Algorithm)
+	}
+	if len(encoded) != sha256.Size*2 {
+		return fmt.Errorf("invalid digest %q: wrong length", d)
+	}
+	if _, err := hex.DecodeString(encoded); err != nil || strings.ToLower(encoded) != encoded {
+		return fmt.Errorf("invalid digest %q: not lowercase hex", d)
+	}
+	return nil
+}
+
+// Hex returns the encoded part of a digest
+func (d Digest) Hex() string {
+	_, encoded, _ := strings.Cut(string(d), ":")
+	return encoded
+}
+
+// String implements fmt.Stringer
+func (d Digest) String() string {
+	return string(d)
+}
+
+// Short returns an abbreviated digest for display
+func (d Digest) Short() string {
+	encoded := d.Hex()
+	if len(encoded) > 12 {
+		encoded = encoded[:12]
+	}
+	return encoded
+}
@@ -0,0 +1,139 @@
+package blob
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Media types of manifests and blobs
+const (
+	// MediaTypeManifest identifies a package manifest
+	MediaTypeManifest = "application/vnd.sentinel.manifest.v1+json"
+
+	// MediaTypeConfig identifies the config blob holding a package manifest
+	MediaTypeConfig = "application/vnd.sentinel.package.config.v1+json"
+
+	// MediaTypeFile identifies a blob holding one package file
+	MediaTypeFile = "application/vnd.sentinel.file.v1"
+)
+
+// Annotations of file layers
+const (
+	// AnnotationTitle is the path of a file within its package
+	AnnotationTitle = "org.opencontainers.image.title"
+
+	// AnnotationMain marks the main file of a package
+	AnnotationMain = "io.sentinelstacks.file.main"
+
+	// AnnotationFileType holds the file type recorded in the package manifest
+	AnnotationFileType = "io.sentinelstacks.file.type"
+)
+
+// Descriptor references a blob
+type Descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      Digest            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// Title returns the file path of a layer
+func (d Descriptor) Title() string {
+	return d.Annotations[AnnotationTitle]
+}
+
+// Manifest lists the blobs of a package
+type Manifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType"`
+	Config        Descriptor        `json:"config"`
+	Layers        []Descriptor      `json:"layers"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+// Blobs returns the descriptors of every blob a manifest references,
+// config first, without duplicates
+func (m *Manifest) Blobs() []Descriptor {
+	seen := map[Digest]bool{m.Config.Digest: true}
+	blobs := []Descriptor{m.Config}
+	for _, layer := range m.Layers {
+		if !seen[layer.Digest] {
+			seen[layer.Digest] = true
+			blobs = append(blobs, layer)
+		}
+	}
+	return blobs
+}
+
+// Size returns the total size of the blobs a manifest references
+func (m *Manifest) Size() int64 {
+	var size int64
+	for _, blob := range m.Blobs() {
+		size += blob.Size
+	}
+	return size
+}
+
+// Marshal encodes a manifest and returns its digest
+func (m *Manifest) Marshal() ([]byte, Digest, error) {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	return data, FromBytes(data), nil
+}
+
+// ParseManifest decodes and validates a manifest. File paths must be
+// relative and stay within the package.
+func ParseManifest(data []byte) (*Manifest, error) {
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+
+	if m.SchemaVersion != 2 || m.MediaType != MediaTypeManifest {
+		return nil, fmt.Errorf("unsupported manifest: schema %d, media type %q", m.SchemaVersion, m.MediaType)
+	}
+	if err := m.Config.Digest.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	paths := make(map[string]bool)
+	for _, layer := range m.Layers {
+		if err := layer.Digest.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid layer: %w", err)
+		}
+		title := layer.Title()
+		if !SafePath(title) {
+			return nil, fmt.Errorf("invalid layer path %q", title)
+		}
+		if paths[title] {
+			return nil, fmt.Errorf("duplicate layer path %q", title)
+		}
+		paths[title] = true
+	}
+
+	return &m, nil
+}
+
+// newManifest creates an empty package manifest
+func newManifest(config Descriptor) *Manifest {
+	return &Manifest{
+		SchemaVersion: 2,
+		MediaType:     MediaTypeManifest,
+		Config:        config,
+		Layers:        []Descriptor{},
+	}
+}
+
+// SafePath reports whether a file path is relative and stays within its
+// package directory
+func SafePath(p string) bool {
+	if p == "" || strings.Contains(p, "\\") || path.IsAbs(p) {
+		return false
+	}
+	clean := path.Clean(p)
+	return clean == p && clean != "." && clean != ".." && !strings.HasPrefix(clean, "../")
+}
@@ -0,0 +1,322 @@
+package blob
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const (
+	// ArchiveManifestName is the manifest entry of a package archive
+	ArchiveManifestName = "sentinel-manifest.json"
+
+	// BuilderManifestName is the manifest entry of archives written by
+	// packages.PackageBuilder
+	BuilderManifestName = "sentinel.manifest.json"
+
+	// archiveMagicPrefix starts the header PackageBuilder writes before the
+	// gzip stream: a magic string such as SNTL-AGENT-PKG and a four byte
+	// format version
+	archiveMagicPrefix = "SNTL-"
+
+	// archiveHeaderSize is the length of the PackageBuilder header
+	archiveHeaderSize = len("SNTL-AGENT-PKG") + len("1.0\x00")
+)
+
+// File is a file to store as a package layer
+type File struct {
+	// Path is the file's path within the package
+	Path string
+	// SourcePath is where the file is read from
+	SourcePath string
+	IsMain     bool
+	Type       string
+}
+
+// PackageConfig holds the identifying fields of a package manifest stored
+// as a config blob
+type PackageConfig struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Files   []struct {
+		Path   string `json:"path"`
+		IsMain bool   `json:"isMain"`
+		Type   string `json:"type"`
+	} `json:"files"`
+}
+
+// ParseConfig decodes the identifying fields of a config blob
+func ParseConfig(data []byte) (*PackageConfig, error) {
+	var config PackageConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse package config: %w", err)
+	}
+	return &config, nil
+}
+
+// Pack stores a package manifest as the config blob and each file as a
+// layer, then stores and returns the manifest referencing them. Files
+// already in the store are not written again.
+func Pack(store *Store, config []byte, files []File) (*Manifest, Digest, error) {
+	configDigest, err := store.PutBytes(config)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to store package config: %w", err)
+	}
+
+	m := newManifest(Descriptor{
+		MediaType: MediaTypeConfig,
+		Digest:    configDigest,
+		Size:      int64(len(config)),
+	})
+
+	for _, file := range files {
+		if !SafePath(filepath.ToSlash(file.Path)) {
+			return nil, "", fmt.Errorf("invalid package path %q", file.Path)
+		}
+
+		content, err := os.Open(file.SourcePath)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to open %s: %w", file.SourcePath, err)
+		}
+		d, size, err := store.Put(content, "")
+		content.Close()
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to store %s: %w", file.Path, err)
+		}
+
+		m.Layers = append(m.Layers, fileDescriptor(filepath.ToSlash(file.Path), d, size, file.IsMain, file.Type))
+	}
+
+	return storeManifest(store, m)
+}
+
+// fileDescriptor describes a file layer
+func fileDescriptor(path string, d Digest, size int64, isMain bool, fileType string) Descriptor {
+	annotations := map[string]string{AnnotationTitle: path}
+	if isMain {
+		annotations[AnnotationMain] = strconv.FormatBool(true)
+	}
+	if fileType != "" {
+		annotations[AnnotationFileType] = fileType
+	}
+
+	return Descriptor{
+		MediaType:   MediaTypeFile,
+		Digest:      d,
+		Size:        size,
+		Annotations: annotations,
+	}
+}
+
+// storeManifest stores a manifest as a blob and returns its digest
+func storeManifest(store *Store, m *Manifest) (*Manifest, Digest, error) {
+	data, d, err := m.Marshal()
+	if err != nil {
+		return nil, "", err
+	}
+	if _, _, err := store.Put(bytes.NewReader(data), d); err != nil {
+		return nil, "", fmt.Errorf("failed to store manifest: %w", err)
+	}
+	return m, d, nil
+}
+
+// Unpack writes the files of a package to a directory
+func Unpack(source Source, m *Manifest, dir string) error {
+	for _, layer := range m.Layers {
+		if !SafePath(layer.Title()) {
+			return fmt.Errorf("invalid layer path %q", layer.Title())
+		}
+
+		target := filepath.Join(dir, filepath.FromSlash(layer.Title()))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+
+		if err := copyBlob(source, layer.Digest, target); err != nil {
+			return fmt.Errorf("failed to unpack %s: %w", layer.Title(), err)
+		}
+	}
+	return nil
+}
+
+// copyBlob writes a blob to a file
+func copyBlob(source Source, d Digest, target string) error {
+	content, err := source.Open(d)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	out, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, content); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// OpenArchive returns a tar reader over a gzipped package archive, with or
+// without a PackageBuilder header
+func OpenArchive(r io.Reader) (*tar.Reader, io.Closer, error) {
+	buffered := bufio.NewReader(r)
+	if prefix, err := buffered.Peek(len(archiveMagicPrefix)); err == nil && string(prefix) == archiveMagicPrefix {
+		if _, err := buffered.Discard(archiveHeaderSize); err != nil {
+			return nil, nil, fmt.Errorf("failed to read package header: %w", err)
+		}
+	}
+
+	gzipReader, err := gzip.NewReader(buffered)
+	if err != nil {
+		return nil, nil, fmt.Errorf("package is not a gzip archive: %w", err)
+	}
+
+	return tar.NewReader(gzipReader), gzipReader, nil
+}
+
+// ImportArchive stores the files of a package archive as blobs, with its
+// manifest entry as the config, and returns the package manifest
+func ImportArchive(store *Store, archivePath string) (*Manifest, Digest, *PackageConfig, error) {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to open package: %w", err)
+	}
+	defer file.Close()
+
+	tarReader, closer, err := OpenArchive(file)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	defer closer.Close()
+
+	var configData []byte
+	var layers []Descriptor
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, "", nil, fmt.Errorf("failed to read package archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg && header.Typeflag != tar.TypeRegA {
+			continue
+		}
+
+		if header.Name == ArchiveManifestName || header.Name == BuilderManifestName {
+			if configData, err = io.ReadAll(tarReader); err != nil {
+				return nil, "", nil, fmt.Errorf("failed to read package manifest: %w", err)
+			}
+			continue
+		}
+
+		if !SafePath(header.Name) {
+			return nil, "", nil, fmt.Errorf("invalid path %q in package archive", header.Name)
+		}
+		d, size, err := store.Put(tarReader, "")
+		if err != nil {
+			return nil, "", nil, fmt.Errorf("failed to store %s: %w", header.Name, err)
+		}
+		layers = append(layers, fileDescriptor(header.Name, d, size, false, ""))
+	}
+
+	if configData == nil {
+		return nil, "", nil, fmt.Errorf("package has no manifest")
+	}
+	config, err := ParseConfig(configData)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if config.Name == "" || config.Version == "" {
+		return nil, "", nil, fmt.Errorf("package manifest must set name and version")
+	}
+
+	// Carry the file attributes of the package manifest into the layers
+	for i := range layers {
+		for _, info := range config.Files {
+			if info.Path != layers[i].Title() {
+				continue
+			}
+			layers[i] = fileDescriptor(info.Path, layers[i].Digest, layers[i].Size, info.IsMain, info.Type)
+		}
+	}
+
+	configDigest, err := store.PutBytes(configData)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to store package config: %w", err)
+	}
+
+	m := newManifest(Descriptor{
+		MediaType: MediaTypeConfig,
+		Digest:    configDigest,
+		Size:      int64(len(configData)),
+	})
+	m.Layers = append(m.Layers, layers...)
+
+	m, d, err := storeManifest(store, m)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return m, d, config, nil
+}
+
+// WriteArchive writes a package as a gzipped tar archive with its config
+// as the manifest entry, the format of packages built without blobs
+func WriteArchive(w io.Writer, source Source, m *Manifest) error {
+	gzipWriter := gzip.NewWriter(w)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	config, err := readAll(source, m.Config.Digest)
+	if err != nil {
+		return fmt.Errorf("failed to read package config: %w", err)
+	}
+
+	now := time.Now()
+	if err := tarWriter.WriteHeader(&tar.Header{
+		Name:    ArchiveManifestName,
+		Mode:    0644,
+		Size:    int64(len(config)),
+		ModTime: now,
+	}); err != nil {
+		return fmt.Errorf("failed to write manifest header: %w", err)
+	}
+	if _, err := tarWriter.Write(config); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	for _, layer := range m.Layers {
+		if err := tarWriter.WriteHeader(&tar.Header{
+			Name:    layer.Title(),
+			Mode:    0644,
+			Size:    layer.Size,
+			ModTime: now,
+		}); err != nil {
+			return fmt.Errorf("failed to write header for %s: %w", layer.Title(), err)
+		}
+
+		content, err := source.Open(layer.Digest)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", layer.Title(), err)
+		}
+		_, err = io.Copy(tarWriter, content)
+		content.Close()
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %w", layer.Title(), err)
+		}
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return fmt.Errorf("failed to close tar writer: %w", err)
+	}
+	return gzipWriter.Close()
+}
@@ -0,0 +1,210 @@
+package blob
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// ErrNotFound is returned for blobs and tags that are not stored
+	ErrNotFound = errors.New("blob not found")
+
+	// ErrDigestMismatch is returned when content does not match the digest
+	// it was stored under
+	ErrDigestMismatch = errors.New("blob digest mismatch")
+)
+
+// Source provides blob content
+type Source interface {
+	// Open returns the content of a blob
+	Open(d Digest) (io.ReadCloser, error)
+}
+
+// Store is a local content-addressed blob store. Blobs live under
+// <root>/blobs/sha256/<hex> and tags under <root>/index/<name>/<ref>,
+// each holding the digest of a manifest blob.
+type Store struct {
+	root string
+}
+
+// NewStore creates a blob store rooted at a directory
+func NewStore(root string) (*Store, error) {
+	for _, dir := range []string{filepath.Join(root, "blobs", Algorithm), filepath.Join(root, "index")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create blob store directory: %w", err)
+		}
+	}
+	return &Store{root: root}, nil
+}
+
+// DefaultStore returns the blob store of the local package cache,
+// ~/.sentinel/blobs unless SENTINEL_CACHE_DIR is set
+func DefaultStore() (*Store, error) {
+	if cacheDir := os.Getenv("SENTINEL_CACHE_DIR"); cacheDir != "" {
+		return NewStore(filepath.Join(cacheDir, "blobs"))
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return NewStore(filepath.Join(homeDir, ".sentinel", "blobs"))
+}
+
+// path returns the file of a blob
+func (s *Store) path(d Digest) string {
+	return filepath.Join(s.root, "blobs", Algorithm, d.Hex())
+}
+
+// Has reports whether a blob is stored
+func (s *Store) Has(d Digest) bool {
+	_, err := s.Stat(d)
+	return err == nil
+}
+
+// Stat returns the size of a stored blob
+func (s *Store) Stat(d Digest) (int64, error) {
+	if err := d.Validate(); err != nil {
+		return 0, err
+	}
+	info, err := os.Stat(s.path(d))
+	if os.IsNotExist(err) {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat blob: %w", err)
+	}
+	return info.Size(), nil
+}
+
+// Put stores the content read from r and returns its digest and size. If
+// expected is set, content with a different digest is rejected.
+func (s *Store) Put(r io.Reader, expected Digest) (Digest, int64, error) {
+	if expected != "" {
+		if err := expected.Validate(); err != nil {
+			return "", 0, err
+		}
+		if size, err := s.Stat(expected); err == nil {
+			// Already stored
+			return expected, size, nil
+		}
+	}
+
+	temp, err := os.CreateTemp(filepath.Join(s.root, "blobs"), ".upload-*")
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create blob file: %w", err)
+	}
+	defer os.Remove(temp.Name())
+
+	d, size, err := FromReader(io.TeeReader(r, temp))
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to write blob: %w", err)
+	}
+
+	if expected != "" && d != expected {
+		return "", 0, fmt.Errorf("%w: expected %s, got %s", ErrDigestMismatch, expected, d)
+	}
+
+	if err := os.Rename(temp.Name(), s.path(d)); err != nil {
+		return "", 0, fmt.Errorf("failed to store blob: %w", err)
+	}
+
+	return d, size, nil
+}
+
+// PutBytes stores a byte slice and returns its digest
+func (s *Store) PutBytes(data []byte) (Digest, error) {
+	d, _, err := s.Put(bytes.NewReader(data), FromBytes(data))
+	return d, err
+}
+
+// Open returns the content of a stored blob
+func (s *Store) Open(d Digest) (io.ReadCloser, error) {
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+	file, err := os.Open(s.path(d))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open blob: %w", err)
+	}
+	return file, nil
+}
+
+// ReadAll returns the content of a stored blob
+func (s *Store) ReadAll(d Digest) ([]byte, error) {
+	return readAll(s, d)
+}
+
+// readAll reads a whole blob from a source
+func readAll(source Source, d Digest) ([]byte, error) {
+	content, err := source.Open(d)
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+	return io.ReadAll(content)
+}
+
+// tagPath returns the file of a tag
+func (s *Store) tagPath(name, ref string) string {
+	return filepath.Join(s.root, "index", url.PathEscape(name), url.PathEscape(ref))
+}
+
+// Tag points a name and reference at a stored manifest
+func (s *Store) Tag(name, ref string, manifest Digest) error {
+	if !s.Has(manifest) {
+		return fmt.Errorf("cannot tag %s:%s: manifest %s is not stored", name, ref, manifest)
+	}
+
+	tagPath := s.tagPath(name, ref)
+	if err := os.MkdirAll(filepath.Dir(tagPath), 0755); err != nil {
+		return fmt.Errorf("failed to create tag directory: %w", err)
+	}
+	if err := os.WriteFile(tagPath, []byte(manifest), 0644); err != nil {
+		return fmt.Errorf("failed to write tag: %w", err)
+	}
+	return nil
+}
+
+// Resolve returns the manifest digest a name and reference point at
+func (s *Store) Resolve(name, ref string) (Digest, error) {
+	data, err := os.ReadFile(s.tagPath(name, ref))
+	if os.IsNotExist(err) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read tag: %w", err)
+	}
+	return Parse(strings.TrimSpace(string(data)))
+}
+
+// Manifest loads the manifest a name and reference point at
+func (s *Store) Manifest(name, ref string) (*Manifest, Digest, error) {
+	d, err := s.Resolve(name, ref)
+	if err != nil {
+		return nil, "", err
+	}
+
+	data, err := s.ReadAll(d)
+	if err != nil {
+		return nil, "", err
+	}
+
+	m, err := ParseManifest(data)
+	if err != nil {
+		return nil, "", err
+	}
+	return m, d, nil
+}
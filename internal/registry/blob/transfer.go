@@ -0,0 +1,281 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrUnsupported is returned when a registry does not implement the
+	// blob protocol
+	ErrUnsupported = errors.New("registry does not support blob transfers")
+
+	// ErrManifestNotFound is returned when a registry has no manifest for a
+	// name and reference
+	ErrManifestNotFound = errors.New("manifest not found")
+)
+
+// TransferStats summarizes a push or pull
+type TransferStats struct {
+	// Blobs is the number of blobs the manifest references
+	Blobs int
+	// Transferred is the number of blobs sent or received
+	Transferred int
+	// Bytes is the size of the transferred blobs
+	Bytes int64
+	// SkippedBytes is the size of the blobs already present at the
+	// destination
+	SkippedBytes int64
+}
+
+// Client transfers blobs and manifests to and from a registry
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	Token      string
+	UserAgent  string
+}
+
+// NewClient creates a blob transfer client
+func NewClient(baseURL, token string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		HTTPClient: &http.Client{Timeout: 5 * time.Minute},
+		Token:      token,
+		UserAgent:  "SentinelStacks-CLI",
+	}
+}
+
+// blobURL returns the URL of a blob
+func (c *Client) blobURL(d Digest) string {
+	return fmt.Sprintf("%s/v1/blobs/%s", c.BaseURL, d)
+}
+
+// manifestURL returns the URL of a manifest
+func (c *Client) manifestURL(name, ref string) string {
+	return fmt.Sprintf("%s/v1/manifests/%s/%s", c.BaseURL, url.PathEscape(name), url.PathEscape(ref))
+}
+
+// do sends a request with the client's credentials
+func (c *Client) do(ctx context.Context, method, target string, body io.Reader, size int64, contentType string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.ContentLength = size
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	return resp, nil
+}
+
+// HasBlob reports whether the registry stores a blob
+func (c *Client) HasBlob(ctx context.Context, d Digest) (bool, error) {
+	resp, err := c.do(ctx, http.MethodHead, c.blobURL(d), nil, 0, "")
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	case http.StatusMethodNotAllowed:
+		return false, ErrUnsupported
+	default:
+		return false, fmt.Errorf("failed to check blob %s: status %d", d.Short(), resp.StatusCode)
+	}
+}
+
+// Push uploads the blobs of a manifest the registry does not have, then
+// the manifest itself under name and reference
+func (c *Client) Push(ctx context.Context, store *Store, name, ref string, manifest Digest) (*TransferStats, error) {
+	data, err := store.ReadAll(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	m, err := ParseManifest(data)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &TransferStats{}
+	for _, desc := range m.Blobs() {
+		stats.Blobs++
+
+		exists, err := c.HasBlob(ctx, desc.Digest)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			stats.SkippedBytes += desc.Size
+			continue
+		}
+
+		if err := c.putBlob(ctx, store, desc); err != nil {
+			return nil, err
+		}
+		stats.Transferred++
+		stats.Bytes += desc.Size
+	}
+
+	resp, err := c.do(ctx, http.MethodPut, c.manifestURL(name, ref), bytes.NewReader(data), int64(len(data)), MediaTypeManifest)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp, http.StatusCreated, http.StatusOK); err != nil {
+		return nil, fmt.Errorf("failed to push manifest: %w", err)
+	}
+
+	return stats, nil
+}
+
+// putBlob uploads one blob
+func (c *Client) putBlob(ctx context.Context, store *Store, desc Descriptor) error {
+	content, err := store.Open(desc.Digest)
+	if err != nil {
+		return fmt.Errorf("failed to open blob %s: %w", desc.Digest.Short(), err)
+	}
+	defer content.Close()
+
+	resp, err := c.do(ctx, http.MethodPut, c.blobURL(desc.Digest), content, desc.Size, "application/octet-stream")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp, http.StatusCreated, http.StatusOK); err != nil {
+		return fmt.Errorf("failed to push blob %s: %w", desc.Digest.Short(), err)
+	}
+	return nil
+}
+
+// Pull downloads the manifest of name and reference and the blobs it
+// references that are not stored locally, then tags it in the store
+func (c *Client) Pull(ctx context.Context, store *Store, name, ref string) (*Manifest, Digest, *TransferStats, error) {
+	resp, err := c.do(ctx, http.MethodGet, c.manifestURL(name, ref), nil, 0, "")
+	if err != nil {
+		return nil, "", nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, "", nil, ErrManifestNotFound
+	case http.StatusMethodNotAllowed:
+		return nil, "", nil, ErrUnsupported
+	default:
+		return nil, "", nil, fmt.Errorf("failed to pull manifest: %w", checkResponse(resp))
+	}
+
+	// Registries without the blob protocol may answer with something else
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), MediaTypeManifest) {
+		return nil, "", nil, ErrUnsupported
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	m, err := ParseManifest(data)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	manifest, err := store.PutBytes(data)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to store manifest: %w", err)
+	}
+
+	stats := &TransferStats{}
+	for _, desc := range m.Blobs() {
+		stats.Blobs++
+
+		if store.Has(desc.Digest) {
+			stats.SkippedBytes += desc.Size
+			continue
+		}
+
+		if err := c.getBlob(ctx, store, desc); err != nil {
+			return nil, "", nil, err
+		}
+		stats.Transferred++
+		stats.Bytes += desc.Size
+	}
+
+	if err := store.Tag(name, ref, manifest); err != nil {
+		return nil, "", nil, err
+	}
+
+	return m, manifest, stats, nil
+}
+
+// getBlob downloads one blob, verifying its digest
+func (c *Client) getBlob(ctx context.Context, store *Store, desc Descriptor) error {
+	resp, err := c.do(ctx, http.MethodGet, c.blobURL(desc.Digest), nil, 0, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp, http.StatusOK); err != nil {
+		return fmt.Errorf("failed to pull blob %s: %w", desc.Digest.Short(), err)
+	}
+
+	if _, _, err := store.Put(resp.Body, desc.Digest); err != nil {
+		return fmt.Errorf("failed to store blob %s: %w", desc.Digest.Short(), err)
+	}
+	return nil
+}
+
+// checkResponse returns an error for a response without one of the
+// expected statuses, using the registry's error message when it sent one
+func checkResponse(resp *http.Response, expected ...int) error {
+	for _, status := range expected {
+		if resp.StatusCode == status {
+			return nil
+		}
+	}
+
+	// Registries without the blob protocol have no route for uploads
+	if resp.Request != nil && resp.Request.Method == http.MethodPut &&
+		(resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed) {
+		return ErrUnsupported
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	var errorResponse struct {
+		Error string `json:"error"`
+	}
+	message := strings.TrimSpace(string(body))
+	if json.Unmarshal(body, &errorResponse) == nil && errorResponse.Error != "" {
+		message = errorResponse.Error
+	}
+	if message == "" {
+		message = http.StatusText(resp.StatusCode)
+	}
+	return fmt.Errorf("registry returned status %d: %s", resp.StatusCode, message)
+}
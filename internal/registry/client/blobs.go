@@ -0,0 +1,81 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/registry/blob"
+)
+
+// pushBlobs pushes a package archive to a registry as content-addressed
+// blobs, uploading only the blobs the registry does not have. It returns
+// blob.ErrUnsupported for registries without the blob protocol.
+func pushBlobs(ctx context.Context, httpClient *http.Client, baseURL, token, packagePath string) (*blob.TransferStats, error) {
+	store, err := blob.DefaultStore()
+	if err != nil {
+		return nil, err
+	}
+
+	_, manifest, config, err := blob.ImportArchive(store, packagePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to import package: %w", err)
+	}
+
+	transfer := blob.NewClient(baseURL, token)
+	transfer.HTTPClient = httpClient
+	return transfer.Push(ctx, store, config.Name, config.Version, manifest)
+}
+
+// pullBlobs pulls a package from a registry as content-addressed blobs,
+// downloading only the blobs missing from the local cache, and writes it as
+// an archive to the path outputPath returns for the package's config. It
+// returns blob.ErrUnsupported or blob.ErrManifestNotFound when the package
+// must be downloaded as an archive instead.
+func pullBlobs(ctx context.Context, httpClient *http.Client, baseURL, token, name, version string, outputPath func(*blob.PackageConfig) string) (string, *blob.TransferStats, error) {
+	store, err := blob.DefaultStore()
+	if err != nil {
+		return "", nil, err
+	}
+
+	transfer := blob.NewClient(baseURL, token)
+	transfer.HTTPClient = httpClient
+	m, _, stats, err := transfer.Pull(ctx, store, name, version)
+	if err != nil {
+		return "", nil, err
+	}
+
+	data, err := store.ReadAll(m.Config.Digest)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read package config: %w", err)
+	}
+	config, err := blob.ParseConfig(data)
+	if err != nil {
+		return "", nil, err
+	}
+
+	path := outputPath(config)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+	output, err := os.Create(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer output.Close()
+
+	if err := blob.WriteArchive(output, store, m); err != nil {
+		return "", nil, fmt.Errorf("failed to write package: %w", err)
+	}
+
+	return path, stats, nil
+}
+
+// archiveFallback reports whether a failed blob pull should be retried as
+// an archive download
+func archiveFallback(err error) bool {
+	return errors.Is(err, blob.ErrUnsupported) || errors.Is(err, blob.ErrManifestNotFound)
+}
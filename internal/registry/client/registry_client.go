@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
@@ -14,6 +15,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/satishgonella2024/sentinelstacks/internal/registry/blob"
 	"github.com/satishgonella2024/sentinelstacks/internal/registry/format"
 	packages "github.com/satishgonella2024/sentinelstacks/internal/registry/package"
 	"github.com/satishgonella2024/sentinelstacks/internal/stack"
@@ -34,6 +36,10 @@ type Client struct {
 	AuthProvider types.AuthProvider
 	HTTPClient   *http.Client
 	UserAgent    string
+
+	// LastTransfer describes the blobs moved by the last Push or Pull, or is
+	// nil if the registry does not support blob transfers
+	LastTransfer *blob.TransferStats
 }
 
 // PackageReference represents a reference to a package in a registry
@@ -100,6 +106,18 @@ func (c *Client) Push(ctx context.Context, packagePath string) error {
 		return fmt.Errorf("authentication required: %w", err)
 	}
 
+	// Push as content-addressed blobs, uploading only what the registry
+	// lacks; registries without the blob protocol get the whole archive
+	c.LastTransfer = nil
+	stats, err := pushBlobs(ctx, c.HTTPClient, c.BaseURL, token, packagePath)
+	if err == nil {
+		c.LastTransfer = stats
+		return nil
+	}
+	if !errors.Is(err, blob.ErrUnsupported) {
+		return fmt.Errorf("push failed: %w", err)
+	}
+
 	// Prepare request
 	url := fmt.Sprintf("%s/api/v1/packages/upload", c.BaseURL)
 
@@ -175,6 +193,27 @@ func (c *Client) Pull(ctx context.Context, name, version string) (string, error)
 		return "", fmt.Errorf("authentication required: %w", err)
 	}
 
+	// Create output directory
+	outputDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	outputDir = filepath.Join(outputDir, ".sentinel", "cache", "packages")
+
+	// Pull as content-addressed blobs, downloading only what the local
+	// cache lacks; fall back to the archive download otherwise
+	c.LastTransfer = nil
+	outputPath, stats, err := pullBlobs(ctx, c.HTTPClient, c.BaseURL, token, name, version, func(config *blob.PackageConfig) string {
+		return filepath.Join(outputDir, fmt.Sprintf("%s-%s.sentinel-pkg", strings.ReplaceAll(name, "/", "-"), config.Version))
+	})
+	if err == nil {
+		c.LastTransfer = stats
+		return outputPath, nil
+	}
+	if !archiveFallback(err) {
+		return "", fmt.Errorf("pull failed: %w", err)
+	}
+
 	// Prepare request
 	url := fmt.Sprintf("%s/api/v1/packages/%s/%s/download", c.BaseURL, url.PathEscape(name), url.PathEscape(version))
 
@@ -215,17 +254,12 @@ func (c *Client) Pull(ctx context.Context, name, version string) (string, error)
 	}
 
 	// Create output directory
-	outputDir, err := os.UserHomeDir()
-	if err != nil {
-		return "", fmt.Errorf("failed to get home directory: %w", err)
-	}
-	outputDir = filepath.Join(outputDir, ".sentinel", "cache", "packages")
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
 		return "", fmt.Errorf("failed to create output directory: %w", err)
 	}
 
 	// Create output file
-	outputPath := filepath.Join(outputDir, filename)
+	outputPath = filepath.Join(outputDir, filename)
 	output, err := os.Create(outputPath)
 	if err != nil {
 		return "", fmt.Errorf("failed to create output file: %w", err)
@@ -412,16 +446,16 @@ type SearchResult struct {
 
 // PackageSummary contains basic information about a package
 type PackageSummary struct {
-	Name         string                `json:"name"`
-	Type         packages.PackageType  `json:"type"`
-	Version      string                `json:"version"`
-	Description  string                `json:"description"`
-	Author       string                `json:"author"`
-	CreatedAt    time.Time             `json:"createdAt"`
-	Downloads    int                   `json:"downloads"`
-	Labels       map[string]string     `json:"labels,omitempty"`
-	Dependencies []packages.Dependency `json:"dependencies,omitempty"`
-	Verified     bool                  `json:"verified"`
+	Name         string             `json:"name"`
+	Type         types.PackageType  `json:"type"`
+	Version      string             `json:"version"`
+	Description  string             `json:"description"`
+	Author       string             `json:"author"`
+	CreatedAt    time.Time          `json:"createdAt"`
+	Downloads    int                `json:"downloads"`
+	Labels       map[string]string  `json:"labels,omitempty"`
+	Dependencies []types.Dependency `json:"dependencies,omitempty"`
+	Verified     bool               `json:"verified"`
 }
 
 // NewRegistryClient creates a new registry client
@@ -457,6 +491,13 @@ func (c *RegistryClient) SetAuthToken(token string) {
 
 // PushPackage pushes a package to the registry
 func (c *RegistryClient) PushPackage(ctx context.Context, packagePath string) error {
+	// Push as content-addressed blobs when the registry supports it
+	if _, err := pushBlobs(ctx, c.HTTPClient, c.BaseURL, c.AuthToken, packagePath); err == nil {
+		return nil
+	} else if !errors.Is(err, blob.ErrUnsupported) {
+		return fmt.Errorf("push failed: %w", err)
+	}
+
 	// Open package file
 	file, err := os.Open(packagePath)
 	if err != nil {
@@ -545,6 +586,17 @@ func (c *RegistryClient) PullPackage(ctx context.Context, name, version, outputP
 		version = "latest"
 	}
 
+	// Pull as content-addressed blobs when the registry supports it
+	_, _, err := pullBlobs(ctx, c.HTTPClient, c.BaseURL, c.AuthToken, name, version, func(*blob.PackageConfig) string {
+		return outputPath
+	})
+	if err == nil {
+		return nil
+	}
+	if !archiveFallback(err) {
+		return fmt.Errorf("pull failed: %w", err)
+	}
+
 	// Set up proper content type
 	fileType := ""
 	if strings.Contains(name, ".agent") {
@@ -663,7 +715,7 @@ func (c *RegistryClient) PullStack(ctx context.Context, name, version string) (*
 }
 
 // SearchPackages searches for packages in the registry
-func (c *RegistryClient) SearchPackages(ctx context.Context, query string, packageType packages.PackageType, limit int) (*SearchResult, error) {
+func (c *RegistryClient) SearchPackages(ctx context.Context, query string, packageType types.PackageType, limit int) (*SearchResult, error) {
 	// Create URL with query parameters
 	params := url.Values{}
 	if query != "" {
@@ -752,6 +804,45 @@ func (c *RegistryClient) GetPackageVersions(ctx context.Context, name string) ([
 	return versions, nil
 }
 
+// GetPackageDigest gets the content digest the registry records for a
+// package version, which changes if the version is ever republished
+func (c *RegistryClient) GetPackageDigest(ctx context.Context, name, version string) (string, error) {
+	// Create request
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/api/v1/packages/%s/%s", c.BaseURL, name, version), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Set headers
+	req.Header.Set("User-Agent", c.UserAgent)
+	if c.AuthToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.AuthToken)
+	}
+
+	// Send request
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Check status code
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("digest lookup failed: %s (status: %d)", string(respBody), resp.StatusCode)
+	}
+
+	// Parse response
+	var info struct {
+		Digest string `json:"digest"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return info.Digest, nil
+}
+
 // Authenticate authenticates with the registry server
 func (c *RegistryClient) Authenticate(ctx context.Context, username, password string) (string, error) {
 	// Create request payload
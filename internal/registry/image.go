@@ -22,6 +22,7 @@ type ImageDefinition struct {
 	Capabilities []string               `json:"capabilities,omitempty"`
 	Tools        []string               `json:"tools,omitempty"`
 	Parameters   map[string]interface{} `json:"parameters,omitempty"`
+	StateSchema  map[string]agent.StateField `json:"stateSchema,omitempty"`
 }
 
 // ConvertFromAgentImage converts from an agent.Image to a registry.Image
@@ -38,6 +39,7 @@ func ConvertFromAgentImage(image *agent.Image) *Image {
 			Capabilities: image.Definition.Capabilities,
 			Tools:        image.Definition.Tools,
 			Parameters:   image.Definition.Parameters,
+			StateSchema:  image.Definition.StateSchema,
 		},
 		Dependencies: image.Dependencies,
 	}
@@ -57,6 +59,7 @@ func ConvertToAgentImage(image *Image) *agent.Image {
 			Capabilities: image.Definition.Capabilities,
 			Tools:        image.Definition.Tools,
 			Parameters:   image.Definition.Parameters,
+			StateSchema:  image.Definition.StateSchema,
 		},
 		Dependencies: image.Dependencies,
 	}
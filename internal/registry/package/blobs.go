@@ -0,0 +1,74 @@
+package packages
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/registry/blob"
+)
+
+// StoreBlobs stores the package in a content-addressed blob store: its
+// manifest as the config blob and each file as a blob named by the SHA256
+// already recorded for it. Files shared with other packages are stored once.
+func (p *SentinelPackage) StoreBlobs(store *blob.Store) (*blob.Manifest, blob.Digest, error) {
+	config, err := json.MarshalIndent(p.Manifest, "", "  ")
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+
+	files := make([]blob.File, 0, len(p.Manifest.Files))
+	for _, fileInfo := range p.Manifest.Files {
+		sourcePath, exists := p.sourceFiles[fileInfo.Path]
+		if !exists {
+			return nil, "", fmt.Errorf("source path for %s not found", fileInfo.Path)
+		}
+		files = append(files, blob.File{
+			Path:       fileInfo.Path,
+			SourcePath: sourcePath,
+			IsMain:     fileInfo.IsMain,
+			Type:       string(fileInfo.Type),
+		})
+	}
+
+	m, d, err := blob.Pack(store, config, files)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// A file changed since it was added would not match its manifest entry
+	for i, layer := range m.Layers {
+		if layer.Digest != blob.FromHex(p.Manifest.Files[i].SHA256) {
+			return nil, "", fmt.Errorf("file %s changed since it was added to the package", layer.Title())
+		}
+	}
+
+	return m, d, nil
+}
+
+// ExtractBlobs writes the files of a package stored as blobs to a directory
+// and loads its manifest, verifying each file against its recorded hash
+func (p *SentinelPackage) ExtractBlobs(source blob.Source, m *blob.Manifest, outputDir string) error {
+	content, err := source.Open(m.Config.Digest)
+	if err != nil {
+		return fmt.Errorf("failed to read package manifest: %w", err)
+	}
+	err = json.NewDecoder(content).Decode(&p.Manifest)
+	content.Close()
+	if err != nil {
+		return fmt.Errorf("error parsing manifest: %w", err)
+	}
+
+	if err := blob.Unpack(source, m, outputDir); err != nil {
+		return err
+	}
+
+	valid, failures, err := p.VerifyIntegrity(outputDir)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return fmt.Errorf("package integrity check failed: %v", failures)
+	}
+
+	return nil
+}
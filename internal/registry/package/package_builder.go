@@ -3,35 +3,43 @@ package packages
 import (
 	"archive/tar"
 	"bufio"
-	"bytes"
 	"compress/gzip"
-	"crypto/sha256"
-	"encoding/hex"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"gopkg.in/yaml.v3"
+
 	"github.com/satishgonella2024/sentinelstacks/internal/registry/format"
+	"github.com/satishgonella2024/sentinelstacks/internal/registry/provenance"
 	"github.com/satishgonella2024/sentinelstacks/internal/registry/security"
 	"github.com/satishgonella2024/sentinelstacks/internal/stack"
+	"github.com/satishgonella2024/sentinelstacks/internal/tools"
+	"github.com/satishgonella2024/sentinelstacks/pkg/types"
 )
 
 // PackageBuilder builds SentinelStacks packages in the standard format
 type PackageBuilder struct {
-	packageType  PackageType
+	packageType  types.PackageType
 	name         string
 	version      string
 	description  string
 	author       string
 	files        []FileEntry
-	dependencies []Dependency
+	dependencies []types.Dependency
 	labels       map[string]string
 	keyManager   *security.KeyManager
+	keyID        string
+	signer       string
+	issuer       security.CertificateIssuer
 	buildTime    time.Time
+
+	toolRegistry  *tools.Registry
+	agentResolver AgentResolver
 }
 
 // FileEntry represents a file to include in the package
@@ -43,7 +51,7 @@ type FileEntry struct {
 }
 
 // NewPackageBuilder creates a new package builder
-func NewPackageBuilder(pkgType PackageType, name, version, description, author string) *PackageBuilder {
+func NewPackageBuilder(pkgType types.PackageType, name, version, description, author string) *PackageBuilder {
 	return &PackageBuilder{
 		packageType:  pkgType,
 		name:         name,
@@ -51,7 +59,7 @@ func NewPackageBuilder(pkgType PackageType, name, version, description, author s
 		description:  description,
 		author:       author,
 		files:        []FileEntry{},
-		dependencies: []Dependency{},
+		dependencies: []types.Dependency{},
 		labels:       make(map[string]string),
 		buildTime:    time.Now().UTC(),
 	}
@@ -62,6 +70,19 @@ func (b *PackageBuilder) SetKeyManager(km *security.KeyManager) {
 	b.keyManager = km
 }
 
+// SetSigningKey sets the key the manifest is signed with and the signer
+// recorded with it. The defaults are the key "default" and the author.
+func (b *PackageBuilder) SetSigningKey(keyID, signer string) {
+	b.keyID = keyID
+	b.signer = signer
+}
+
+// SetKeylessIssuer signs the manifest with an ephemeral key certified by
+// the issuer, in addition to any key manager signature
+func (b *PackageBuilder) SetKeylessIssuer(issuer security.CertificateIssuer) {
+	b.issuer = issuer
+}
+
 // AddFile adds a file to the package
 func (b *PackageBuilder) AddFile(sourcePath, targetPath string, isMain bool, fileType FileType) error {
 	// Check that the source file exists
@@ -87,8 +108,8 @@ func (b *PackageBuilder) AddLabel(key, value string) {
 }
 
 // AddDependency adds a dependency to the package
-func (b *PackageBuilder) AddDependency(name, version string, depType PackageType, required bool) {
-	b.dependencies = append(b.dependencies, Dependency{
+func (b *PackageBuilder) AddDependency(name, version string, depType types.PackageType, required bool) {
+	b.dependencies = append(b.dependencies, types.Dependency{
 		Name:     name,
 		Version:  version,
 		Type:     depType,
@@ -128,7 +149,7 @@ func (b *PackageBuilder) AddDirectory(sourceDirPath, targetPrefix string) error
 // ImportStackDefinition imports a stack definition into the package
 func (b *PackageBuilder) ImportStackDefinition(stackFilePath string) error {
 	// Verify that this is a stack package
-	if b.packageType != PackageTypeStack {
+	if b.packageType != types.PackageTypeStack {
 		return fmt.Errorf("cannot import stack definition into non-stack package")
 	}
 	
@@ -146,8 +167,9 @@ func (b *PackageBuilder) ImportStackDefinition(stackFilePath string) error {
 		}
 	} else {
 		// Assume YAML by default
-		// In a real implementation, this would use yaml.Unmarshal
-		return fmt.Errorf("YAML parsing not implemented")
+		if err := yaml.Unmarshal(content, &stackSpec); err != nil {
+			return fmt.Errorf("failed to parse stack YAML: %w", err)
+		}
 	}
 	
 	// Set package metadata from stack spec
@@ -164,16 +186,16 @@ func (b *PackageBuilder) ImportStackDefinition(stackFilePath string) error {
 		return fmt.Errorf("failed to add stack file: %w", err)
 	}
 	
-	// Extract agent dependencies
+	// Extract agent dependencies, once per version
+	seen := make(map[string]bool)
 	for _, agent := range stackSpec.Agents {
-		parts := strings.Split(agent.Uses, ":")
-		agentName := parts[0]
-		agentVersion := "latest"
-		if len(parts) > 1 {
-			agentVersion = parts[1]
+		agentName, agentVersion := splitUses(agent.Uses)
+		if seen[agentName+":"+agentVersion] {
+			continue
 		}
+		seen[agentName+":"+agentVersion] = true
 		
-		b.AddDependency(agentName, agentVersion, PackageTypeAgent, true)
+		b.AddDependency(agentName, agentVersion, types.PackageTypeAgent, true)
 	}
 	
 	return nil
@@ -194,7 +216,7 @@ func (b *PackageBuilder) Build(outputPath string) error {
 	
 	// Write magic header based on package type
 	var magicHeader []byte
-	if b.packageType == PackageTypeAgent {
+	if b.packageType == types.PackageTypeAgent {
 		magicHeader = format.MagicHeaders[format.AgentExtension]
 	} else {
 		magicHeader = format.MagicHeaders[format.StackExtension]
@@ -264,31 +286,65 @@ func (b *PackageBuilder) Build(outputPath string) error {
 		}
 	}
 	
+	// Attest what the package was built from. The attestation is listed in
+	// the manifest, so the manifest signature covers it too.
+	files := make(map[string]string, len(manifest.Files))
+	for _, file := range manifest.Files {
+		files[file.Path] = file.SHA256
+	}
+	attestationPath, err := b.buildAttestation(files)
+	if err != nil {
+		return fmt.Errorf("failed to build provenance attestation: %w", err)
+	}
+	defer os.Remove(attestationPath)
+	
+	attestationHash, err := calculateFileSHA256(attestationPath)
+	if err != nil {
+		return fmt.Errorf("failed to calculate hash for attestation: %w", err)
+	}
+	attestationInfo, err := os.Stat(attestationPath)
+	if err != nil {
+		return fmt.Errorf("failed to stat attestation: %w", err)
+	}
+	manifest.Files = append(manifest.Files, FileInfo{
+		Path:   provenance.FileName,
+		Size:   attestationInfo.Size(),
+		SHA256: attestationHash,
+		Type:   FileTypeAttestation,
+	})
+	if err := addFileToTar(tarWriter, attestationPath, provenance.FileName); err != nil {
+		return fmt.Errorf("failed to add attestation to archive: %w", err)
+	}
+	
 	// Sign the manifest if a key manager is provided
 	if b.keyManager != nil {
-		manifestBytes, err := json.Marshal(manifest)
+		manifestBytes, err := manifest.SignedContent()
 		if err != nil {
-			return fmt.Errorf("failed to marshal manifest: %w", err)
+			return err
 		}
 		
-		// Get default key ID (in a real implementation, this would be configurable)
-		keyID := "default"
-		signer := b.author
-		
 		// Sign the manifest
-		signature, err := b.keyManager.Sign(manifestBytes, keyID, signer)
+		signature, err := b.keyManager.Sign(manifestBytes, b.signingKeyID(), b.signerName())
 		if err != nil {
 			return fmt.Errorf("failed to sign manifest: %w", err)
 		}
 		
 		// Add signature to manifest
-		manifest.Signatures = append(manifest.Signatures, SignatureRecord{
-			KeyID:     signature.Info.KeyID,
-			Signer:    signature.Info.Signer,
-			Algorithm: signature.Info.Algorithm,
-			Signature: signature.Data,
-			Timestamp: signature.Info.Timestamp,
-		})
+		manifest.Signatures = append(manifest.Signatures, newSignatureRecord(signature))
+	}
+	
+	// Sign keyless if an issuer is provided
+	if b.issuer != nil {
+		manifestBytes, err := manifest.SignedContent()
+		if err != nil {
+			return err
+		}
+		
+		signature, err := security.SignKeyless(context.Background(), manifestBytes, b.issuer)
+		if err != nil {
+			return fmt.Errorf("failed to sign manifest: %w", err)
+		}
+		manifest.Signatures = append(manifest.Signatures, newSignatureRecord(signature))
 	}
 	
 	// Add manifest file to tar archive
@@ -327,57 +383,10 @@ func (b *PackageBuilder) Build(outputPath string) error {
 	return nil
 }
 
-// calculateFileSHA256 calculates SHA256 hash of a file
-func calculateFileSHA256(filePath string) (string, error) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return "", fmt.Errorf("failed to open file: %w", err)
-	}
-	defer file.Close()
-	
-	hash := sha256.New()
-	if _, err := io.Copy(hash, file); err != nil {
-		return "", fmt.Errorf("failed to read file: %w", err)
-	}
-	
-	return hex.EncodeToString(hash.Sum(nil)), nil
-}
-
-// addFileToTar adds a file to a tar archive
-func addFileToTar(tarWriter *tar.Writer, sourcePath, targetPath string) error {
-	file, err := os.Open(sourcePath)
-	if err != nil {
-		return fmt.Errorf("failed to open file: %w", err)
-	}
-	defer file.Close()
-	
-	stat, err := file.Stat()
-	if err != nil {
-		return fmt.Errorf("failed to stat file: %w", err)
-	}
-	
-	header := &tar.Header{
-		Name:    targetPath,
-		Size:    stat.Size(),
-		Mode:    int64(stat.Mode()),
-		ModTime: stat.ModTime(),
-	}
-	
-	if err := tarWriter.WriteHeader(header); err != nil {
-		return fmt.Errorf("failed to write header: %w", err)
-	}
-	
-	if _, err := io.Copy(tarWriter, file); err != nil {
-		return fmt.Errorf("failed to copy file data: %w", err)
-	}
-	
-	return nil
-}
-
 // BuildFromStackSpec creates a package directly from a stack spec
 func BuildFromStackSpec(spec stack.StackSpec, outputPath string, author string) error {
 	// Create a new package builder
-	builder := NewPackageBuilder(PackageTypeStack, spec.Name, spec.Version, spec.Description, author)
+	builder := NewPackageBuilder(types.PackageTypeStack, spec.Name, spec.Version, spec.Description, author)
 	
 	// Create a temporary file for the stack definition
 	tempDir, err := os.MkdirTemp("", "sentinel-stack-")
@@ -0,0 +1,350 @@
+package packages
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/registry"
+	"github.com/satishgonella2024/sentinelstacks/internal/registry/format"
+	"github.com/satishgonella2024/sentinelstacks/internal/registry/provenance"
+	"github.com/satishgonella2024/sentinelstacks/internal/stack"
+	"github.com/satishgonella2024/sentinelstacks/internal/tools"
+	"github.com/satishgonella2024/sentinelstacks/pkg/agent"
+)
+
+// FileTypeAttestation marks the provenance attestation of a package
+const FileTypeAttestation FileType = "attestation"
+
+// promptKeys are the parameters whose values are recorded as prompts
+var promptKeys = []string{"prompt", "systemPrompt", "system_prompt", "instructions"}
+
+// AgentResolver returns the definition of an agent image a stack uses, so
+// its model and tools can be attested
+type AgentResolver func(name, version string) (*agent.Definition, error)
+
+// LocalAgentResolver resolves agents from the local image registry
+func LocalAgentResolver(name, version string) (*agent.Definition, error) {
+	localRegistry, err := registry.GetLocalRegistry()
+	if err != nil {
+		return nil, err
+	}
+	image, err := localRegistry.Get(name, version)
+	if err != nil {
+		return nil, err
+	}
+	return &agent.Definition{
+		Name:       image.Definition.Name,
+		BaseModel:  image.Definition.BaseModel,
+		Tools:      image.Definition.Tools,
+		Parameters: image.Definition.Parameters,
+	}, nil
+}
+
+// SetToolRegistry sets the registry the permissions of declared tools are
+// looked up in. The default is the global tool registry.
+func (b *PackageBuilder) SetToolRegistry(toolRegistry *tools.Registry) {
+	b.toolRegistry = toolRegistry
+}
+
+// SetAgentResolver sets how the definitions of sub-agents are found. The
+// default is LocalAgentResolver.
+func (b *PackageBuilder) SetAgentResolver(resolver AgentResolver) {
+	b.agentResolver = resolver
+}
+
+// PinDependency sets the exact version and digest of a dependency, such as
+// those recorded in a stack.lock
+func (b *PackageBuilder) PinDependency(name, version, digest string) {
+	for i := range b.dependencies {
+		if b.dependencies[i].Name == name {
+			b.dependencies[i].Version = version
+			b.dependencies[i].Digest = digest
+		}
+	}
+}
+
+// buildAttestation writes the signed provenance attestation of a package
+// to a temporary file and returns its path. files maps package paths to
+// their SHA256 and become the statement's subjects.
+func (b *PackageBuilder) buildAttestation(files map[string]string) (string, error) {
+	predicate, err := b.provenance()
+	if err != nil {
+		return "", err
+	}
+	predicate.Build.FinishedAt = time.Now().UTC()
+
+	envelope, err := provenance.NewEnvelope(&provenance.Statement{
+		Type:          provenance.StatementType,
+		Subject:       provenance.NewSubjects(files),
+		PredicateType: provenance.PredicateType,
+		Predicate:     *predicate,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if b.keyManager != nil {
+		if err := envelope.Sign(b.keyManager, b.signingKeyID(), b.signerName()); err != nil {
+			return "", err
+		}
+	}
+	if b.issuer != nil {
+		if err := envelope.SignKeyless(context.Background(), b.issuer); err != nil {
+			return "", err
+		}
+	}
+
+	data, err := envelope.Marshal()
+	if err != nil {
+		return "", err
+	}
+	file, err := os.CreateTemp("", "sentinel-provenance-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create attestation file: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(data); err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to write attestation: %w", err)
+	}
+
+	return file.Name(), nil
+}
+
+// provenance collects the agents, models, tools, prompts and dependencies
+// of the package from its stack and agent definitions
+func (b *PackageBuilder) provenance() (*provenance.Provenance, error) {
+	host, _ := os.Hostname()
+	predicate := &provenance.Provenance{
+		Package: provenance.Package{
+			Name:    b.name,
+			Version: b.version,
+			Type:    string(b.packageType),
+			Author:  b.author,
+		},
+		Build: provenance.Build{
+			Host:      host,
+			OS:        runtime.GOOS,
+			Arch:      runtime.GOARCH,
+			GoVersion: runtime.Version(),
+			User:      buildUser(),
+			StartedAt: b.buildTime,
+		},
+	}
+
+	// Agent definitions shipped in the package
+	definitions := make(map[string]*agent.Definition)
+	for _, entry := range b.files {
+		if !isAgentDefinition(entry.TargetPath) {
+			continue
+		}
+		definition, err := readAgentDefinition(entry.SourcePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read agent definition %s: %w", entry.TargetPath, err)
+		}
+		if definition.Name == "" {
+			definition.Name = strings.TrimSuffix(strings.TrimSuffix(filepath.Base(entry.TargetPath), format.AgentDefinitionExtension), ".agent.json")
+		}
+		definitions[definition.Name] = definition
+		predicate.Agents = append(predicate.Agents, provenance.Agent{
+			Name:      definition.Name,
+			Source:    entry.TargetPath,
+			BaseModel: definition.BaseModel,
+			Tools:     definition.Tools,
+		})
+		addPrompts(predicate, definition.Name, definition.Parameters)
+	}
+
+	// Sub-agents used by stack definitions
+	for _, entry := range b.files {
+		if !isStackDefinition(entry.TargetPath) {
+			continue
+		}
+		spec, err := readStackSpec(entry.SourcePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read stack definition %s: %w", entry.TargetPath, err)
+		}
+		for _, stackAgent := range spec.Agents {
+			predicate.Agents = append(predicate.Agents, b.subAgent(stackAgent, definitions))
+			addPrompts(predicate, stackAgent.ID, stackAgent.Params)
+		}
+	}
+
+	// Models and tools, with the permission each tool requires
+	toolRegistry := b.toolRegistry
+	if toolRegistry == nil {
+		toolRegistry = tools.GetRegistry()
+	}
+	models := make(map[string]bool)
+	toolIndex := make(map[string]int)
+	for _, a := range predicate.Agents {
+		if a.BaseModel != "" && !models[a.BaseModel] {
+			models[a.BaseModel] = true
+			predicate.Models = append(predicate.Models, a.BaseModel)
+		}
+		for _, name := range a.Tools {
+			i, ok := toolIndex[name]
+			if !ok {
+				tool := provenance.Tool{Name: name}
+				if registered, err := toolRegistry.GetTool(name); err == nil {
+					tool.Permission = registered.RequiredPermission()
+				}
+				i = len(predicate.Tools)
+				toolIndex[name] = i
+				predicate.Tools = append(predicate.Tools, tool)
+			}
+			predicate.Tools[i].Agents = append(predicate.Tools[i].Agents, a.Name)
+		}
+	}
+	sort.Strings(predicate.Models)
+	sort.Slice(predicate.Tools, func(i, j int) bool {
+		return predicate.Tools[i].Name < predicate.Tools[j].Name
+	})
+
+	for _, dep := range b.dependencies {
+		predicate.Dependencies = append(predicate.Dependencies, provenance.Dependency{
+			Name:    dep.Name,
+			Version: dep.Version,
+			Type:    string(dep.Type),
+			Digest:  dep.Digest,
+		})
+	}
+
+	return predicate, nil
+}
+
+// subAgent describes an agent of a stack. Its definition is taken from the
+// package or, for the pinned dependency version, from the agent resolver.
+func (b *PackageBuilder) subAgent(stackAgent stack.StackAgentSpec, definitions map[string]*agent.Definition) provenance.Agent {
+	name, version := splitUses(stackAgent.Uses)
+	for _, dep := range b.dependencies {
+		if dep.Name == name && dep.Digest != "" {
+			version = dep.Version
+		}
+	}
+
+	a := provenance.Agent{Name: stackAgent.ID, Uses: stackAgent.Uses, Version: version}
+	definition, ok := definitions[name]
+	if ok {
+		a.Source = "package"
+	} else {
+		resolver := b.agentResolver
+		if resolver == nil {
+			resolver = LocalAgentResolver
+		}
+		var err error
+		if definition, err = resolver(name, version); err != nil {
+			return a
+		}
+		a.Source = name + ":" + version
+	}
+
+	a.BaseModel = definition.BaseModel
+	a.Tools = definition.Tools
+	return a
+}
+
+// addPrompts records the digests of the prompt parameters of an agent
+func addPrompts(predicate *provenance.Provenance, agentName string, params map[string]interface{}) {
+	for _, key := range promptKeys {
+		prompt, ok := params[key].(string)
+		if !ok || prompt == "" {
+			continue
+		}
+		sum := sha256.Sum256([]byte(prompt))
+		predicate.Prompts = append(predicate.Prompts, provenance.Prompt{
+			Agent:  agentName,
+			Key:    key,
+			SHA256: hex.EncodeToString(sum[:]),
+		})
+	}
+}
+
+// signingKeyID returns the key the package is signed with
+func (b *PackageBuilder) signingKeyID() string {
+	if b.keyID == "" {
+		return "default"
+	}
+	return b.keyID
+}
+
+// signerName returns the signer recorded with signatures
+func (b *PackageBuilder) signerName() string {
+	if b.signer == "" {
+		return b.author
+	}
+	return b.signer
+}
+
+// buildUser returns the name of the user running the build
+func buildUser() string {
+	if current, err := user.Current(); err == nil {
+		return current.Username
+	}
+	return os.Getenv("USER")
+}
+
+// isAgentDefinition reports whether a package path is an agent definition
+func isAgentDefinition(path string) bool {
+	return strings.HasSuffix(path, format.AgentDefinitionExtension) || strings.HasSuffix(path, ".agent.json")
+}
+
+// isStackDefinition reports whether a package path is a stack definition
+func isStackDefinition(path string) bool {
+	return strings.HasSuffix(path, format.StackDefinitionExtension) || strings.HasSuffix(path, ".stack.json")
+}
+
+// readAgentDefinition parses an agent definition file. It is read as YAML,
+// which covers JSON, with the field names of the JSON form.
+func readAgentDefinition(path string) (*agent.Definition, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	if err := yaml.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	if data, err = json.Marshal(fields); err != nil {
+		return nil, err
+	}
+
+	var definition agent.Definition
+	if err := json.Unmarshal(data, &definition); err != nil {
+		return nil, err
+	}
+	return &definition, nil
+}
+
+// readStackSpec parses a stack definition file, JSON or YAML
+func readStackSpec(path string) (stack.StackSpec, error) {
+	var spec stack.StackSpec
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return spec, err
+	}
+	err = yaml.Unmarshal(data, &spec)
+	return spec, err
+}
+
+// splitUses splits a uses: reference into name and version, which
+// defaults to "latest"
+func splitUses(uses string) (string, string) {
+	start := strings.LastIndex(uses, "/") + 1
+	if i := strings.Index(uses[start:], ":"); i >= 0 {
+		return uses[:start+i], uses[start+i+1:]
+	}
+	return uses, "latest"
+}
@@ -14,6 +14,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/satishgonella2024/sentinelstacks/internal/registry/blob"
 	"github.com/satishgonella2024/sentinelstacks/internal/registry/security"
 	"github.com/satishgonella2024/sentinelstacks/pkg/types"
 )
@@ -69,6 +70,8 @@ type SignatureRecord struct {
 	Algorithm string    `json:"algorithm"`
 	Signature string    `json:"signature"`
 	Timestamp time.Time `json:"timestamp"`
+	// Certificate is set on keyless signatures
+	Certificate *security.Certificate `json:"certificate,omitempty"`
 }
 
 // SentinelPackage is the standard format for distributing agents and stacks
@@ -188,11 +191,9 @@ func (p *SentinelPackage) Sign(keyID, signer string) error {
 	}
 	
 	// Marshal the manifest without signatures
-	tempManifest := p.Manifest
-	tempManifest.Signatures = []SignatureRecord{}
-	manifestBytes, err := json.Marshal(tempManifest)
+	manifestBytes, err := p.Manifest.SignedContent()
 	if err != nil {
-		return fmt.Errorf("failed to marshal manifest: %w", err)
+		return err
 	}
 	
 	// Sign the manifest
@@ -202,13 +203,7 @@ func (p *SentinelPackage) Sign(keyID, signer string) error {
 	}
 	
 	// Add signature to manifest
-	p.Manifest.Signatures = append(p.Manifest.Signatures, SignatureRecord{
-		KeyID:     signature.Info.KeyID,
-		Signer:    signature.Info.Signer,
-		Algorithm: signature.Info.Algorithm,
-		Signature: signature.Data,
-		Timestamp: signature.Info.Timestamp,
-	})
+	p.Manifest.Signatures = append(p.Manifest.Signatures, newSignatureRecord(signature))
 	
 	return nil
 }
@@ -268,7 +263,54 @@ func (p *SentinelPackage) Package(outputPath string) error {
 	return nil
 }
 
-// Unpackage extracts a package to a directory
+// ReadManifest reads a package's manifest without extracting its files, so
+// that its signatures can be checked before anything is written
+func (p *SentinelPackage) ReadManifest(packagePath string) error {
+	file, err := os.Open(packagePath)
+	if err != nil {
+		return fmt.Errorf("failed to open package: %w", err)
+	}
+	defer file.Close()
+	
+	tarReader, closer, err := blob.OpenArchive(file)
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+	
+	var manifestFound bool
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("error reading tar: %w", err)
+		}
+		if !isManifestEntry(header) {
+			continue
+		}
+		if manifestFound {
+			return fmt.Errorf("package has more than one manifest")
+		}
+		
+		if err := p.readManifestEntry(tarReader); err != nil {
+			return err
+		}
+		manifestFound = true
+	}
+	
+	if !manifestFound {
+		return fmt.Errorf("no manifest found in package")
+	}
+	
+	p.Path = packagePath
+	
+	return nil
+}
+
+// Unpackage extracts a package to a directory. Entries whose paths are
+// absolute or leave the directory are refused.
 func (p *SentinelPackage) Unpackage(packagePath, outputDir string) error {
 	// Open the package file
 	file, err := os.Open(packagePath)
@@ -277,15 +319,12 @@ func (p *SentinelPackage) Unpackage(packagePath, outputDir string) error {
 	}
 	defer file.Close()
 	
-	// Create gzip reader
-	gzipReader, err := gzip.NewReader(file)
+	// Create tar reader, skipping the header of builder packages
+	tarReader, closer, err := blob.OpenArchive(file)
 	if err != nil {
-		return fmt.Errorf("failed to create gzip reader: %w", err)
+		return err
 	}
-	defer gzipReader.Close()
-	
-	// Create tar reader
-	tarReader := tar.NewReader(gzipReader)
+	defer closer.Close()
 	
 	// Create output directory
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
@@ -308,7 +347,11 @@ func (p *SentinelPackage) Unpackage(packagePath, outputDir string) error {
 		switch header.Typeflag {
 		case tar.TypeReg, tar.TypeRegA:
 			// Regular file
-			outPath := filepath.Join(outputDir, header.Name)
+			if !blob.SafePath(header.Name) {
+				return fmt.Errorf("invalid path in package: %q", header.Name)
+			}
+			
+			outPath := filepath.Join(outputDir, filepath.FromSlash(header.Name))
 			outDir := filepath.Dir(outPath)
 			
 			// Create directory if it doesn't exist
@@ -317,29 +360,31 @@ func (p *SentinelPackage) Unpackage(packagePath, outputDir string) error {
 			}
 			
 			// Create file
-			outFile, err := os.OpenFile(outPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode))
+			outFile, err := os.OpenFile(outPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode)&0777)
 			if err != nil {
 				return fmt.Errorf("error creating file: %w", err)
 			}
 			
-			// Copy content
-			if _, err := io.Copy(outFile, tarReader); err != nil {
+			// Copy content, parsing the manifest as it is written
+			var content io.Reader = tarReader
+			var manifestData bytes.Buffer
+			if isManifestEntry(header) {
+				if manifestFound {
+					outFile.Close()
+					return fmt.Errorf("package has more than one manifest")
+				}
+				content = io.TeeReader(tarReader, &manifestData)
+			}
+			if _, err := io.Copy(outFile, content); err != nil {
 				outFile.Close()
 				return fmt.Errorf("error copying file content: %w", err)
 			}
 			outFile.Close()
 			
-			// Check if this is the manifest
-			if header.Name == "sentinel-manifest.json" {
-				manifestData, err := os.ReadFile(outPath)
-				if err != nil {
-					return fmt.Errorf("error reading manifest: %w", err)
+			if isManifestEntry(header) {
+				if err := p.readManifestEntry(&manifestData); err != nil {
+					return err
 				}
-				
-				if err := json.Unmarshal(manifestData, &p.Manifest); err != nil {
-					return fmt.Errorf("error parsing manifest: %w", err)
-				}
-				
 				manifestFound = true
 			}
 		}
@@ -355,6 +400,27 @@ func (p *SentinelPackage) Unpackage(packagePath, outputDir string) error {
 	return nil
 }
 
+// isManifestEntry reports whether a tar entry is a package manifest
+func isManifestEntry(header *tar.Header) bool {
+	return header.Name == blob.ArchiveManifestName || header.Name == blob.BuilderManifestName
+}
+
+// readManifestEntry parses the manifest entry being read
+func (p *SentinelPackage) readManifestEntry(r io.Reader) error {
+	manifestData, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("error reading manifest: %w", err)
+	}
+	
+	var manifest PackageManifest
+	if err := json.Unmarshal(manifestData, &manifest); err != nil {
+		return fmt.Errorf("error parsing manifest: %w", err)
+	}
+	p.Manifest = manifest
+	
+	return nil
+}
+
 // VerifyIntegrity checks that all files match their recorded hashes
 func (p *SentinelPackage) VerifyIntegrity(baseDir string) (bool, []string, error) {
 	var failures []string
@@ -386,30 +452,17 @@ func (p *SentinelPackage) VerifySignatures() (bool, []string, error) {
 	var validSigners []string
 	
 	// Create manifest copy without signatures
-	tempManifest := p.Manifest
-	tempManifest.Signatures = []SignatureRecord{}
-	manifestBytes, err := json.Marshal(tempManifest)
+	manifestBytes, err := p.Manifest.SignedContent()
 	if err != nil {
-		return false, nil, fmt.Errorf("failed to marshal manifest: %w", err)
+		return false, nil, err
 	}
 	
 	// Verify each signature
-	for _, sig := range p.Manifest.Signatures {
-		// Create signature object
-		signature := &security.Signature{
-			Data: sig.Signature,
-			Info: security.SignatureInfo{
-				KeyID:     sig.KeyID,
-				Signer:    sig.Signer,
-				Algorithm: sig.Algorithm,
-				Timestamp: sig.Timestamp,
-			},
-		}
-		
+	for _, signature := range p.Manifest.SecuritySignatures() {
 		// Verify signature
-		err := p.keyManager.Verify(signature, manifestBytes)
+		err := p.keyManager.Verify(&signature, manifestBytes)
 		if err == nil {
-			validSigners = append(validSigners, sig.Signer)
+			validSigners = append(validSigners, signature.Info.Signer)
 		}
 	}
 	
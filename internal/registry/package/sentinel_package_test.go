@@ -0,0 +1,118 @@
+package packages
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeArchive writes a gzipped tar of the given entries, in order
+func writeArchive(t *testing.T, entries [][2]string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "package.tar.gz")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	gzipWriter := gzip.NewWriter(file)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for _, entry := range entries {
+		header := &tar.Header{Name: entry[0], Mode: 0644, Size: int64(len(entry[1])), Typeflag: tar.TypeReg}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tarWriter.Write([]byte(entry[1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const testManifest = `{"name":"demo","version":"1.0.0","files":[{"path":"Sentinelfile","sha256":"x"}]}`
+
+func TestReadManifestWritesNothing(t *testing.T) {
+	path := writeArchive(t, [][2]string{
+		{"Sentinelfile", "name: demo"},
+		{"sentinel-manifest.json", testManifest},
+	})
+
+	pkg := &SentinelPackage{}
+	if err := pkg.ReadManifest(path); err != nil {
+		t.Fatalf("ReadManifest failed: %v", err)
+	}
+	if pkg.Manifest.Name != "demo" || len(pkg.Manifest.Files) != 1 {
+		t.Errorf("Unexpected manifest %+v", pkg.Manifest)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected only the archive in its directory, found %d entries", len(entries))
+	}
+}
+
+func TestReadManifestRejectsDuplicates(t *testing.T) {
+	path := writeArchive(t, [][2]string{
+		{"sentinel-manifest.json", testManifest},
+		{"sentinel.manifest.json", `{"name":"other"}`},
+	})
+
+	pkg := &SentinelPackage{}
+	if err := pkg.ReadManifest(path); err == nil {
+		t.Error("Expected a package with two manifests to be rejected")
+	}
+	if err := pkg.Unpackage(path, t.TempDir()); err == nil {
+		t.Error("Expected Unpackage to reject a package with two manifests")
+	}
+}
+
+func TestUnpackageRejectsUnsafePaths(t *testing.T) {
+	for _, name := range []string{"../escape", "/tmp/escape", "files/../../escape", "files\\..\\escape"} {
+		path := writeArchive(t, [][2]string{
+			{"sentinel-manifest.json", testManifest},
+			{name, "payload"},
+		})
+
+		root := t.TempDir()
+		outputDir := filepath.Join(root, "extract")
+		pkg := &SentinelPackage{}
+		if err := pkg.Unpackage(path, outputDir); err == nil {
+			t.Errorf("Unpackage accepted entry %q", name)
+		}
+		if _, err := os.Stat(filepath.Join(root, "escape")); err == nil {
+			t.Errorf("Entry %q was written outside the output directory", name)
+		}
+	}
+}
+
+func TestUnpackageExtractsFiles(t *testing.T) {
+	path := writeArchive(t, [][2]string{
+		{"sentinel-manifest.json", testManifest},
+		{"docs/README.md", "# Demo"},
+	})
+
+	outputDir := t.TempDir()
+	pkg := &SentinelPackage{}
+	if err := pkg.Unpackage(path, outputDir); err != nil {
+		t.Fatalf("Unpackage failed: %v", err)
+	}
+	if pkg.Manifest.Name != "demo" {
+		t.Errorf("Unexpected manifest %+v", pkg.Manifest)
+	}
+	data, err := os.ReadFile(filepath.Join(outputDir, "docs", "README.md"))
+	if err != nil || string(data) != "# Demo" {
+		t.Errorf("Expected docs/README.md to be extracted, got %q, %v", data, err)
+	}
+}
@@ -0,0 +1,107 @@
+package packages
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/registry/security"
+)
+
+// SignedContent returns the bytes signatures cover: the manifest marshaled
+// without its signatures
+func (m PackageManifest) SignedContent() ([]byte, error) {
+	m.Signatures = []SignatureRecord{}
+	content, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	return content, nil
+}
+
+// SecuritySignatures returns the manifest's signatures in the form the
+// security package verifies
+func (m PackageManifest) SecuritySignatures() []security.Signature {
+	signatures := make([]security.Signature, 0, len(m.Signatures))
+	for _, record := range m.Signatures {
+		signatures = append(signatures, security.Signature{
+			Data: record.Signature,
+			Info: security.SignatureInfo{
+				KeyID:     record.KeyID,
+				Signer:    record.Signer,
+				Algorithm: record.Algorithm,
+				Timestamp: record.Timestamp,
+			},
+			Certificate: record.Certificate,
+		})
+	}
+	return signatures
+}
+
+// newSignatureRecord converts a signature into the form stored in manifests
+func newSignatureRecord(signature *security.Signature) SignatureRecord {
+	return SignatureRecord{
+		KeyID:       signature.Info.KeyID,
+		Signer:      signature.Info.Signer,
+		Algorithm:   signature.Info.Algorithm,
+		Signature:   signature.Data,
+		Timestamp:   signature.Info.Timestamp,
+		Certificate: signature.Certificate,
+	}
+}
+
+// SignKeyless signs the package manifest with an ephemeral key certified
+// by the issuer
+func (p *SentinelPackage) SignKeyless(ctx context.Context, issuer security.CertificateIssuer) error {
+	manifestBytes, err := p.Manifest.SignedContent()
+	if err != nil {
+		return err
+	}
+
+	signature, err := security.SignKeyless(ctx, manifestBytes, issuer)
+	if err != nil {
+		return fmt.Errorf("failed to sign manifest: %w", err)
+	}
+
+	p.Manifest.Signatures = append(p.Manifest.Signatures, newSignatureRecord(signature))
+	return nil
+}
+
+// TrustRecord returns a record of the package's manifest and signatures
+// for checking the package against the trust policy after it is installed
+func (p *SentinelPackage) TrustRecord(ref security.Reference) (*security.TrustRecord, error) {
+	manifestBytes, err := p.Manifest.SignedContent()
+	if err != nil {
+		return nil, err
+	}
+
+	return &security.TrustRecord{
+		Reference:  ref,
+		Manifest:   manifestBytes,
+		Signatures: p.Manifest.SecuritySignatures(),
+		PulledAt:   time.Now().UTC(),
+	}, nil
+}
+
+// VerifyTrust checks that the manifest is for the referenced package and
+// its signatures against a trust policy, returning a *security.TrustError
+// naming each failing signature when the policy enforces signing and none
+// is trusted. A signed manifest of another package or version is refused
+// whatever the mode, so a registry cannot substitute one for the other.
+// The "latest" tag resolves to a version, which is not compared.
+func (p *SentinelPackage) VerifyTrust(policy *security.TrustPolicy, km *security.KeyManager, ref security.Reference) error {
+	if p.Manifest.Name != ref.Name {
+		return fmt.Errorf("package manifest is for %q, not %q", p.Manifest.Name, ref.Name)
+	}
+	if ref.Version != "" && ref.Version != "latest" && p.Manifest.Version != ref.Version {
+		return fmt.Errorf("package manifest is for %s version %q, not %q", ref.Name, p.Manifest.Version, ref.Version)
+	}
+
+	manifestBytes, err := p.Manifest.SignedContent()
+	if err != nil {
+		return err
+	}
+
+	return policy.Enforce(km, ref, manifestBytes, p.Manifest.SecuritySignatures())
+}
@@ -0,0 +1,64 @@
+package packages
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/registry/security"
+	"github.com/satishgonella2024/sentinelstacks/pkg/types"
+)
+
+func TestVerifyTrustRejectsSubstitutedPackages(t *testing.T) {
+	km, err := security.NewKeyManager(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := km.GenerateKey("release", security.KeyTypeEd25519); err != nil {
+		t.Fatal(err)
+	}
+	policy := &security.TrustPolicy{TrustRule: security.TrustRule{Mode: security.TrustModeEnforce, Keys: []string{"release"}}}
+
+	// A genuine, trusted signature over an old release of the package
+	pkg := NewSentinelPackage(types.PackageTypeAgent, "acme/summarizer", "1.0.0", "", "alice")
+	pkg.SetKeyManager(km)
+	if err := pkg.Sign("release", "alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		ref     security.Reference
+		wantErr string
+	}{
+		{"same package", security.Reference{Name: "acme/summarizer", Version: "1.0.0"}, ""},
+		{"latest tag", security.Reference{Name: "acme/summarizer", Version: "latest"}, ""},
+		{"other version", security.Reference{Name: "acme/summarizer", Version: "1.2.0"}, `version "1.0.0", not "1.2.0"`},
+		{"other package", security.Reference{Name: "acme/deployer", Version: "1.0.0"}, `is for "acme/summarizer", not "acme/deployer"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := pkg.VerifyTrust(policy, km, tt.ref)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Expected the package to be trusted, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Expected an error containing %q, got %v", tt.wantErr, err)
+			}
+			var trustErr *security.TrustError
+			if errors.As(err, &trustErr) {
+				t.Errorf("Expected a substitution error, got a signature failure: %v", err)
+			}
+		})
+	}
+
+	// Substitution is refused even when signatures are not checked
+	off := &security.TrustPolicy{TrustRule: security.TrustRule{Mode: security.TrustModeOff}}
+	if err := pkg.VerifyTrust(off, km, security.Reference{Name: "acme/deployer", Version: "1.0.0"}); err == nil {
+		t.Error("Expected another package to be refused in off mode")
+	}
+}
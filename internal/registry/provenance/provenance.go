@@ -0,0 +1,239 @@
+// Package provenance describes what a package was built from: the agent
+// definitions, models, tools, prompts and dependencies it pulls in and the
+// host that built it. The description is an in-toto style statement whose
+// subjects are the package's files, carried in a signed envelope inside the
+// package.
+package provenance
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/registry/security"
+	"github.com/satishgonella2024/sentinelstacks/internal/tools"
+)
+
+const (
+	// FileName is the package entry holding the attestation
+	FileName = "sentinel.provenance.json"
+
+	// StatementType identifies in-toto statements
+	StatementType = "https://in-toto.io/Statement/v1"
+
+	// PredicateType identifies SentinelStacks provenance predicates
+	PredicateType = "https://sentinelstacks.io/provenance/v1"
+
+	// PayloadType is the media type of envelope payloads
+	PayloadType = "application/vnd.in-toto+json"
+)
+
+// Statement binds a provenance predicate to the files it describes
+type Statement struct {
+	Type          string     `json:"_type"`
+	Subject       []Subject  `json:"subject"`
+	PredicateType string     `json:"predicateType"`
+	Predicate     Provenance `json:"predicate"`
+}
+
+// Subject is a file of the package, identified by its digests
+type Subject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// Provenance lists what a package pulls in and how it was built
+type Provenance struct {
+	Package      Package      `json:"package"`
+	Agents       []Agent      `json:"agents,omitempty"`
+	Models       []string     `json:"models,omitempty"`
+	Tools        []Tool       `json:"tools,omitempty"`
+	Prompts      []Prompt     `json:"prompts,omitempty"`
+	Dependencies []Dependency `json:"dependencies,omitempty"`
+	Build        Build        `json:"build"`
+}
+
+// Package identifies the attested package
+type Package struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Type    string `json:"type"`
+	Author  string `json:"author,omitempty"`
+}
+
+// Agent is an agent definition in the package or a sub-agent a stack uses.
+// Source is the package file it was read from, or the image reference of a
+// sub-agent whose definition was resolved at build time; sub-agents that
+// could not be resolved have no model or tools.
+type Agent struct {
+	Name      string   `json:"name"`
+	Uses      string   `json:"uses,omitempty"`
+	Version   string   `json:"version,omitempty"`
+	Source    string   `json:"source,omitempty"`
+	BaseModel string   `json:"baseModel,omitempty"`
+	Tools     []string `json:"tools,omitempty"`
+}
+
+// Tool is a tool declared by an agent with the permission it requires.
+// Permission is empty for tools not registered on the build host.
+type Tool struct {
+	Name       string           `json:"name"`
+	Permission tools.Permission `json:"permission,omitempty"`
+	Agents     []string         `json:"agents"`
+}
+
+// Prompt records the digest of a prompt an agent is configured with
+type Prompt struct {
+	Agent  string `json:"agent"`
+	Key    string `json:"key"`
+	SHA256 string `json:"sha256"`
+}
+
+// Dependency is a package the attested package depends on. Digest is set
+// when the dependency was pinned, for example from a stack.lock.
+type Dependency struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Type    string `json:"type"`
+	Digest  string `json:"digest,omitempty"`
+}
+
+// Build describes the build host and time
+type Build struct {
+	Host       string    `json:"host"`
+	OS         string    `json:"os"`
+	Arch       string    `json:"arch"`
+	GoVersion  string    `json:"goVersion"`
+	User       string    `json:"user,omitempty"`
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+}
+
+// Envelope carries a statement and the signatures over it. Signatures
+// cover the payload bytes as stored.
+type Envelope struct {
+	PayloadType string               `json:"payloadType"`
+	Payload     []byte               `json:"payload"`
+	Signatures  []security.Signature `json:"signatures"`
+}
+
+// NewEnvelope wraps a statement in an unsigned envelope
+func NewEnvelope(statement *Statement) (*Envelope, error) {
+	payload, err := json.Marshal(statement)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal provenance statement: %w", err)
+	}
+	return &Envelope{PayloadType: PayloadType, Payload: payload}, nil
+}
+
+// Sign adds a signature made with a stored key
+func (e *Envelope) Sign(km *security.KeyManager, keyID, signer string) error {
+	signature, err := km.Sign(e.Payload, keyID, signer)
+	if err != nil {
+		return fmt.Errorf("failed to sign provenance: %w", err)
+	}
+	e.Signatures = append(e.Signatures, *signature)
+	return nil
+}
+
+// SignKeyless adds a signature made with an ephemeral key certified by the
+// issuer
+func (e *Envelope) SignKeyless(ctx context.Context, issuer security.CertificateIssuer) error {
+	signature, err := security.SignKeyless(ctx, e.Payload, issuer)
+	if err != nil {
+		return fmt.Errorf("failed to sign provenance: %w", err)
+	}
+	e.Signatures = append(e.Signatures, *signature)
+	return nil
+}
+
+// Statement decodes the envelope's statement
+func (e *Envelope) Statement() (*Statement, error) {
+	if e.PayloadType != PayloadType {
+		return nil, fmt.Errorf("unsupported provenance payload type %q", e.PayloadType)
+	}
+
+	var statement Statement
+	if err := json.Unmarshal(e.Payload, &statement); err != nil {
+		return nil, fmt.Errorf("failed to parse provenance statement: %w", err)
+	}
+	if statement.Type != StatementType || statement.PredicateType != PredicateType {
+		return nil, fmt.Errorf("unsupported provenance statement %s (%s)", statement.Type, statement.PredicateType)
+	}
+
+	return &statement, nil
+}
+
+// Verify checks every signature with the key manager, returning nil or the
+// reason each one failed, by index
+func (e *Envelope) Verify(km *security.KeyManager) []error {
+	results := make([]error, len(e.Signatures))
+	for i := range e.Signatures {
+		signature := e.Signatures[i]
+		results[i] = km.Verify(&signature, e.Payload)
+	}
+	return results
+}
+
+// Marshal encodes the envelope as stored in packages
+func (e *Envelope) Marshal() ([]byte, error) {
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal provenance envelope: %w", err)
+	}
+	return data, nil
+}
+
+// Read loads an envelope from a file
+func Read(path string) (*Envelope, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read provenance: %w", err)
+	}
+
+	var envelope Envelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, fmt.Errorf("failed to parse provenance envelope: %w", err)
+	}
+	return &envelope, nil
+}
+
+// NewSubjects returns subjects for files given as path to hex SHA256,
+// sorted by path
+func NewSubjects(files map[string]string) []Subject {
+	subjects := make([]Subject, 0, len(files))
+	for path, sum := range files {
+		subjects = append(subjects, Subject{Name: path, Digest: map[string]string{"sha256": sum}})
+	}
+	sort.Slice(subjects, func(i, j int) bool {
+		return subjects[i].Name < subjects[j].Name
+	})
+	return subjects
+}
+
+// CheckSubjects compares the statement's subjects with a package's files,
+// given as path to hex SHA256, and describes every difference
+func (s *Statement) CheckSubjects(files map[string]string) []string {
+	var problems []string
+	seen := make(map[string]bool, len(s.Subject))
+	for _, subject := range s.Subject {
+		seen[subject.Name] = true
+		sum, ok := files[subject.Name]
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("%s: attested but not in package", subject.Name))
+		case subject.Digest["sha256"] != sum:
+			problems = append(problems, fmt.Sprintf("%s: sha256 %s, attested %s", subject.Name, sum, subject.Digest["sha256"]))
+		}
+	}
+	for path := range files {
+		if !seen[path] {
+			problems = append(problems, fmt.Sprintf("%s: in package but not attested", path))
+		}
+	}
+	sort.Strings(problems)
+	return problems
+}
@@ -0,0 +1,92 @@
+package provenance
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/registry/security"
+	"github.com/satishgonella2024/sentinelstacks/internal/tools"
+)
+
+func TestEnvelopeSignAndVerify(t *testing.T) {
+	km, err := security.NewKeyManager(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"release", "issuer"} {
+		if err := km.GenerateKey(id, security.KeyTypeEd25519); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files := map[string]string{"research.stack.yaml": "aa", "README.md": "bb"}
+	statement := &Statement{
+		Type:          StatementType,
+		Subject:       NewSubjects(files),
+		PredicateType: PredicateType,
+		Predicate: Provenance{
+			Package: Package{Name: "research", Version: "1.0.0", Type: "stack"},
+			Agents:  []Agent{{Name: "researcher", Uses: "summarizer:^1.2", Version: "1.4.0", BaseModel: "llama3", Tools: []string{"web_search"}}},
+			Tools:   []Tool{{Name: "web_search", Permission: tools.PermissionNetwork, Agents: []string{"researcher"}}},
+		},
+	}
+
+	envelope, err := NewEnvelope(statement)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := envelope.Sign(km, "release", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := envelope.SignKeyless(context.Background(), &security.LocalIssuer{KeyManager: km, KeyID: "issuer", Identity: "ci@acme.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := envelope.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), FileName)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	read, err := Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, err := range read.Verify(km) {
+		if err != nil {
+			t.Fatalf("signature %d: %v", i+1, err)
+		}
+	}
+	decoded, err := read.Statement()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Predicate.Tools[0].Permission != tools.PermissionNetwork || decoded.Predicate.Agents[0].Version != "1.4.0" {
+		t.Fatalf("decoded predicate = %+v", decoded.Predicate)
+	}
+	if problems := decoded.CheckSubjects(files); len(problems) != 0 {
+		t.Fatalf("CheckSubjects = %v", problems)
+	}
+
+	// A changed payload no longer verifies
+	read.Payload = []byte(strings.Replace(string(read.Payload), "llama3", "gpt-4o", 1))
+	for i, err := range read.Verify(km) {
+		if err == nil {
+			t.Fatalf("signature %d verified a tampered payload", i+1)
+		}
+	}
+}
+
+func TestCheckSubjects(t *testing.T) {
+	statement := &Statement{Subject: NewSubjects(map[string]string{"a": "1", "b": "2"})}
+	problems := statement.CheckSubjects(map[string]string{"a": "1", "b": "3", "c": "4"})
+	if len(problems) != 2 || !strings.HasPrefix(problems[0], "b: sha256 3") || !strings.HasPrefix(problems[1], "c: in package") {
+		t.Fatalf("CheckSubjects = %v", problems)
+	}
+}
@@ -107,6 +107,12 @@ func (r *LocalRegistry) ListImageInfo() ([]ImageInfo, error) {
 	return images, nil
 }
 
+// Path returns the file an image is stored in
+func (r *LocalRegistry) Path(name, tag string) string {
+	filename := fmt.Sprintf("%s_%s.json", strings.ReplaceAll(name, "/", "_"), tag)
+	return filepath.Join(r.imagesDir, filename)
+}
+
 // Get returns an image by name and tag
 func (r *LocalRegistry) Get(name, tag string) (*Image, error) {
 	// If tag is not specified, use "latest"
@@ -115,8 +121,7 @@ func (r *LocalRegistry) Get(name, tag string) (*Image, error) {
 	}
 
 	// Create the file path
-	filename := fmt.Sprintf("%s_%s.json", strings.ReplaceAll(name, "/", "_"), tag)
-	filePath := filepath.Join(r.imagesDir, filename)
+	filePath := r.Path(name, tag)
 
 	// Check if the file exists
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
@@ -149,8 +154,7 @@ func (r *LocalRegistry) Save(image *Image) error {
 	}
 
 	// Create the file path
-	filename := fmt.Sprintf("%s_%s.json", strings.ReplaceAll(image.Name, "/", "_"), image.Tag)
-	filePath := filepath.Join(r.imagesDir, filename)
+	filePath := r.Path(image.Name, image.Tag)
 
 	// Convert to agent Image
 	agentImage := ConvertToAgentImage(image)
@@ -177,8 +181,7 @@ func (r *LocalRegistry) Delete(name, tag string) error {
 	}
 
 	// Create the file path
-	filename := fmt.Sprintf("%s_%s.json", strings.ReplaceAll(name, "/", "_"), tag)
-	filePath := filepath.Join(r.imagesDir, filename)
+	filePath := r.Path(name, tag)
 
 	// Check if the file exists
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
@@ -0,0 +1,228 @@
+package security
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// KeylessKeyID is the key ID recorded for keyless signatures
+const KeylessKeyID = "keyless"
+
+// DefaultCertificateTTL is how long a keyless signing certificate is valid
+const DefaultCertificateTTL = 10 * time.Minute
+
+// Certificate binds a public key to an identity for a short time. It is
+// signed by an issuer key, so a verifier that trusts the issuer can check
+// signatures made with the certified key without ever having stored it.
+type Certificate struct {
+	Identity          string    `json:"identity"`
+	Issuer            string    `json:"issuer"`
+	IssuerFingerprint string    `json:"issuerFingerprint"`
+	PublicKey         string    `json:"publicKey"`
+	NotBefore         time.Time `json:"notBefore"`
+	NotAfter          time.Time `json:"notAfter"`
+	Algorithm         string    `json:"algorithm"`
+	Signature         string    `json:"signature"`
+}
+
+// CertificateIssuer issues short-lived certificates for ephemeral keys
+type CertificateIssuer interface {
+	// IssueCertificate certifies a public key for the issuer's identity
+	IssueCertificate(ctx context.Context, publicKey ed25519.PublicKey) (*Certificate, error)
+}
+
+// LocalIssuer issues certificates with a key from a KeyManager. Registries
+// use it to certify the keys of authenticated users; it can also sign in CI
+// where the issuer key is provisioned as a secret.
+type LocalIssuer struct {
+	KeyManager *KeyManager
+	KeyID      string
+	Identity   string
+	TTL        time.Duration
+}
+
+// IssueCertificate implements CertificateIssuer
+func (i *LocalIssuer) IssueCertificate(ctx context.Context, publicKey ed25519.PublicKey) (*Certificate, error) {
+	return i.KeyManager.IssueCertificate(i.KeyID, i.Identity, publicKey, i.TTL)
+}
+
+// IssueCertificate certifies a public key for an identity with a stored
+// issuer key. A zero ttl uses DefaultCertificateTTL.
+func (km *KeyManager) IssueCertificate(issuerKeyID, identity string, publicKey ed25519.PublicKey, ttl time.Duration) (*Certificate, error) {
+	if identity == "" {
+		return nil, fmt.Errorf("certificate identity is required")
+	}
+	if len(publicKey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid Ed25519 public key")
+	}
+	if ttl <= 0 {
+		ttl = DefaultCertificateTTL
+	}
+
+	issuerFingerprint, err := km.Fingerprint(issuerKeyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load issuer key: %w", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal public key: %w", err)
+	}
+
+	now := time.Now().UTC()
+	cert := &Certificate{
+		Identity:          identity,
+		Issuer:            issuerKeyID,
+		IssuerFingerprint: issuerFingerprint,
+		PublicKey:         base64.StdEncoding.EncodeToString(der),
+		NotBefore:         now,
+		NotAfter:          now.Add(ttl),
+	}
+
+	content, err := cert.signedContent()
+	if err != nil {
+		return nil, err
+	}
+	signature, err := km.Sign(content, issuerKeyID, identity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign certificate: %w", err)
+	}
+	cert.Algorithm = signature.Info.Algorithm
+	cert.Signature = signature.Data
+
+	return cert, nil
+}
+
+// SignKeyless signs content with a fresh Ed25519 key that is certified by
+// the issuer and then discarded. The signature carries the certificate, so
+// verifiers need only the issuer's public key.
+func SignKeyless(ctx context.Context, content []byte, issuer CertificateIssuer) (*Signature, error) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+
+	cert, err := issuer.IssueCertificate(ctx, publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to obtain signing certificate: %w", err)
+	}
+
+	hash := sha256.Sum256(content)
+	return &Signature{
+		Data: base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, hash[:])),
+		Info: SignatureInfo{
+			Signer:    cert.Identity,
+			Timestamp: time.Now().UTC(),
+			Algorithm: AlgorithmEd25519,
+			Version:   "1.0",
+			KeyID:     KeylessKeyID,
+		},
+		Content:     content,
+		Certificate: cert,
+	}, nil
+}
+
+// verifyKeyless checks a keyless signature: the certificate must be signed
+// by a stored issuer key, the signature must have been made while the
+// certificate was valid, and it must verify with the certified key
+func (km *KeyManager) verifyKeyless(signature *Signature, digest, signatureBytes []byte) error {
+	cert := signature.Certificate
+	if err := km.VerifyCertificate(cert); err != nil {
+		return err
+	}
+
+	if signature.Info.Signer != cert.Identity {
+		return fmt.Errorf("signer %q does not match certificate identity %q", signature.Info.Signer, cert.Identity)
+	}
+	timestamp := signature.Info.Timestamp
+	if timestamp.Before(cert.NotBefore) || timestamp.After(cert.NotAfter) {
+		return fmt.Errorf("signature made at %s outside certificate validity (%s to %s)",
+			timestamp.Format(time.RFC3339), cert.NotBefore.Format(time.RFC3339), cert.NotAfter.Format(time.RFC3339))
+	}
+
+	der, err := base64.StdEncoding.DecodeString(cert.PublicKey)
+	if err != nil {
+		return fmt.Errorf("failed to decode certificate key: %w", err)
+	}
+	publicKey, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return fmt.Errorf("failed to parse certificate key: %w", err)
+	}
+	if _, ok := publicKey.(ed25519.PublicKey); !ok {
+		return fmt.Errorf("certificate key is not an Ed25519 key")
+	}
+
+	if err := verifyWithKey(publicKey, signature.Info.Algorithm, digest, signatureBytes); err != nil {
+		return fmt.Errorf("invalid signature: %w", err)
+	}
+
+	return nil
+}
+
+// VerifyCertificate checks that a certificate was signed by its issuer.
+// The issuer's public key must be in the keys directory, under the ID the
+// certificate names or any other ID, with the fingerprint the certificate
+// records.
+func (km *KeyManager) VerifyCertificate(cert *Certificate) error {
+	issuerKey, err := km.findIssuerKey(cert)
+	if err != nil {
+		return err
+	}
+
+	content, err := cert.signedContent()
+	if err != nil {
+		return err
+	}
+	signatureBytes, err := base64.StdEncoding.DecodeString(cert.Signature)
+	if err != nil {
+		return fmt.Errorf("failed to decode certificate signature: %w", err)
+	}
+	hash := sha256.Sum256(content)
+	if err := verifyWithKey(issuerKey, cert.Algorithm, hash[:], signatureBytes); err != nil {
+		return fmt.Errorf("invalid certificate signature: %w", err)
+	}
+
+	return nil
+}
+
+// findIssuerKey returns the stored public key matching a certificate's
+// issuer fingerprint. Issuer keys imported from several registries may
+// share an ID, so other IDs are searched when the named key differs.
+func (km *KeyManager) findIssuerKey(cert *Certificate) (crypto.PublicKey, error) {
+	if publicKey, err := km.loadPublicKey(cert.Issuer); err == nil {
+		if keyFingerprint, err := fingerprint(publicKey); err == nil && keyFingerprint == cert.IssuerFingerprint {
+			return publicKey, nil
+		}
+	}
+
+	keys, err := km.ListKeys()
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		if key.Fingerprint == cert.IssuerFingerprint {
+			return km.loadPublicKey(key.ID)
+		}
+	}
+
+	return nil, fmt.Errorf("unknown certificate issuer %q (%s)", cert.Issuer, cert.IssuerFingerprint)
+}
+
+// signedContent returns the certificate fields covered by its signature
+func (c *Certificate) signedContent() ([]byte, error) {
+	unsigned := *c
+	unsigned.Algorithm = ""
+	unsigned.Signature = ""
+	content, err := json.Marshal(unsigned)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal certificate: %w", err)
+	}
+	return content, nil
+}
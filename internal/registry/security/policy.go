@@ -0,0 +1,372 @@
+package security
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TrustMode controls what happens when a package has no trusted signature
+type TrustMode string
+
+const (
+	// TrustModeEnforce refuses packages without a trusted signature
+	TrustModeEnforce TrustMode = "enforce"
+
+	// TrustModeWarn logs a warning and accepts the package
+	TrustModeWarn TrustMode = "warn"
+
+	// TrustModeOff skips signature checks
+	TrustModeOff TrustMode = "off"
+)
+
+// LocalRegistry is the registry scope of images built on this machine
+const LocalRegistry = "local"
+
+// TrustPolicy lists the keys trusted to sign packages. The top-level rule
+// applies to every package; entries in Policies override it for a registry
+// and namespace.
+type TrustPolicy struct {
+	TrustRule `yaml:",inline"`
+	Policies  []TrustRule `yaml:"policies,omitempty"`
+}
+
+// TrustRule lists the signers trusted for a scope. Keys are key IDs from
+// the keys directory or "sha256:" fingerprints.
+type TrustRule struct {
+	Registry  string        `yaml:"registry,omitempty"`
+	Namespace string        `yaml:"namespace,omitempty"`
+	Mode      TrustMode     `yaml:"mode,omitempty"`
+	Keys      []string      `yaml:"keys,omitempty"`
+	Keyless   []KeylessRule `yaml:"keyless,omitempty"`
+}
+
+// KeylessRule trusts keyless signatures certified by an issuer key, named
+// by its ID in the keys directory or its fingerprint, for identities
+// matching one of the patterns. No patterns trusts any identity the issuer
+// certifies.
+type KeylessRule struct {
+	Issuer     string   `yaml:"issuer"`
+	Identities []string `yaml:"identities,omitempty"`
+}
+
+// Reference identifies the package a policy is evaluated for
+type Reference struct {
+	Registry string `json:"registry"`
+	Name     string `json:"name"`
+	Version  string `json:"version"`
+}
+
+// String returns the reference as "registry/name:version"
+func (r Reference) String() string {
+	s := r.Name
+	if r.Version != "" {
+		s += ":" + r.Version
+	}
+	if r.Registry != "" {
+		s = r.Registry + "/" + s
+	}
+	return s
+}
+
+// SignatureFailure explains why a signature was not trusted
+type SignatureFailure struct {
+	Index  int
+	KeyID  string
+	Signer string
+	Reason string
+}
+
+// String names the signature and the reason it failed
+func (f SignatureFailure) String() string {
+	return fmt.Sprintf("signature %d (key %q, signer %q): %s", f.Index+1, f.KeyID, f.Signer, f.Reason)
+}
+
+// TrustError is returned when a package has no trusted signature
+type TrustError struct {
+	Reference Reference
+	Mode      TrustMode
+	Failures  []SignatureFailure
+}
+
+// Error lists every signature that failed
+func (e *TrustError) Error() string {
+	if len(e.Failures) == 0 {
+		return fmt.Sprintf("trust policy (%s): %s is not signed", e.Mode, e.Reference)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "trust policy (%s): %s has no trusted signature", e.Mode, e.Reference)
+	for _, failure := range e.Failures {
+		b.WriteString("\n  ")
+		b.WriteString(failure.String())
+	}
+	return b.String()
+}
+
+// TrustResult is the outcome of evaluating a policy for a package
+type TrustResult struct {
+	Mode     TrustMode
+	Trusted  []Signature
+	Failures []SignatureFailure
+}
+
+// DefaultTrustPolicyPath returns $SENTINEL_TRUST_POLICY or
+// ~/.sentinel/trust-policy.yaml
+func DefaultTrustPolicyPath() (string, error) {
+	if path := os.Getenv("SENTINEL_TRUST_POLICY"); path != "" {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, ".sentinel", "trust-policy.yaml"), nil
+}
+
+// LoadTrustPolicy reads a trust policy file. Without a file signatures are
+// not checked, matching the behaviour before policies existed.
+func LoadTrustPolicy(path string) (*TrustPolicy, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &TrustPolicy{TrustRule: TrustRule{Mode: TrustModeOff}}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trust policy: %w", err)
+	}
+
+	var policy TrustPolicy
+	if err := yaml.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("failed to parse trust policy %s: %w", path, err)
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid trust policy %s: %w", path, err)
+	}
+
+	return &policy, nil
+}
+
+// Validate checks modes and keyless rules. A missing top-level mode
+// defaults to enforce.
+func (p *TrustPolicy) Validate() error {
+	if p.Mode == "" {
+		p.Mode = TrustModeEnforce
+	}
+	if err := p.TrustRule.validate(); err != nil {
+		return err
+	}
+	for i := range p.Policies {
+		rule := &p.Policies[i]
+		if rule.Registry == "" && rule.Namespace == "" {
+			return fmt.Errorf("policy %d: registry or namespace is required", i+1)
+		}
+		rule.Registry = RegistryHost(rule.Registry)
+		if err := rule.validate(); err != nil {
+			return fmt.Errorf("policy %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// validate checks the mode and keyless rules of a rule
+func (r *TrustRule) validate() error {
+	switch r.Mode {
+	case "", TrustModeEnforce, TrustModeWarn, TrustModeOff:
+	default:
+		return fmt.Errorf("unknown mode %q: use enforce, warn or off", r.Mode)
+	}
+	for _, keyless := range r.Keyless {
+		if keyless.Issuer == "" {
+			return fmt.Errorf("keyless rule without an issuer")
+		}
+		for _, pattern := range keyless.Identities {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid identity pattern %q: %w", pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// RuleFor returns the rule for a package: the policy with the matching
+// registry and the longest matching namespace, or the top-level rule. A
+// rule without a mode inherits the top-level mode.
+func (p *TrustPolicy) RuleFor(ref Reference) TrustRule {
+	registry := RegistryHost(ref.Registry)
+
+	best, bestScore := p.TrustRule, -1
+	for _, rule := range p.Policies {
+		score := 0
+		if rule.Registry != "" {
+			if rule.Registry != registry {
+				continue
+			}
+			score += 1000
+		}
+		if rule.Namespace != "" {
+			namespace := strings.Trim(rule.Namespace, "/")
+			if ref.Name != namespace && !strings.HasPrefix(ref.Name, namespace+"/") {
+				continue
+			}
+			score += len(namespace)
+		}
+		if score > bestScore {
+			best, bestScore = rule, score
+		}
+	}
+
+	if best.Mode == "" {
+		best.Mode = p.Mode
+	}
+	return best
+}
+
+// Evaluate checks signatures over content against the rule for a package.
+// A signature is trusted when it verifies and was made by a key or keyless
+// identity the rule lists.
+func (p *TrustPolicy) Evaluate(km *KeyManager, ref Reference, content []byte, signatures []Signature) *TrustResult {
+	rule := p.RuleFor(ref)
+	result := &TrustResult{Mode: rule.Mode}
+	if rule.Mode == TrustModeOff {
+		return result
+	}
+
+	for i := range signatures {
+		signature := signatures[i]
+		failure := SignatureFailure{Index: i, KeyID: signature.Info.KeyID, Signer: signature.Info.Signer}
+
+		if err := km.Verify(&signature, content); err != nil {
+			failure.Reason = err.Error()
+			result.Failures = append(result.Failures, failure)
+			continue
+		}
+		if reason := rule.trusts(km, &signature); reason != "" {
+			failure.Reason = reason
+			result.Failures = append(result.Failures, failure)
+			continue
+		}
+		result.Trusted = append(result.Trusted, signature)
+	}
+
+	return result
+}
+
+// Err returns a *TrustError if no signature was trusted, or nil
+func (r *TrustResult) Err(ref Reference) error {
+	if r.Mode == TrustModeOff || len(r.Trusted) > 0 {
+		return nil
+	}
+	return &TrustError{Reference: ref, Mode: r.Mode, Failures: r.Failures}
+}
+
+// Enforce evaluates the policy and applies its mode: enforce returns a
+// *TrustError when no signature is trusted, warn logs it, off does nothing
+func (p *TrustPolicy) Enforce(km *KeyManager, ref Reference, content []byte, signatures []Signature) error {
+	result := p.Evaluate(km, ref, content, signatures)
+	err := result.Err(ref)
+	if err != nil && result.Mode == TrustModeWarn {
+		log.Printf("Warning: %v", err)
+		return nil
+	}
+	return err
+}
+
+// trusts returns why a verified signature is not trusted by the rule, or
+// "" if it is
+func (r *TrustRule) trusts(km *KeyManager, signature *Signature) string {
+	if cert := signature.Certificate; cert != nil {
+		for _, keyless := range r.Keyless {
+			// Issuers are compared by fingerprint: the name in a
+			// certificate is chosen by whoever issued it
+			if resolveFingerprint(km, keyless.Issuer) != cert.IssuerFingerprint {
+				continue
+			}
+			if matchIdentity(keyless.Identities, cert.Identity) {
+				return ""
+			}
+			return fmt.Sprintf("identity %q is not trusted for issuer %q", cert.Identity, cert.Issuer)
+		}
+		return fmt.Sprintf("certificate issuer %q is not trusted", cert.Issuer)
+	}
+
+	keyFingerprint, err := km.Fingerprint(signature.Info.KeyID)
+	if err != nil {
+		return err.Error()
+	}
+	for _, key := range r.Keys {
+		if key == signature.Info.KeyID || key == keyFingerprint {
+			return ""
+		}
+	}
+	return fmt.Sprintf("key %s is not trusted", keyFingerprint)
+}
+
+// resolveFingerprint returns a fingerprint as is, or the fingerprint of
+// the stored key with the given ID; "" if there is no such key
+func resolveFingerprint(km *KeyManager, key string) string {
+	if strings.HasPrefix(key, "sha256:") {
+		return key
+	}
+	keyFingerprint, err := km.Fingerprint(key)
+	if err != nil {
+		return ""
+	}
+	return keyFingerprint
+}
+
+// matchIdentity reports whether an identity matches any pattern
+func matchIdentity(patterns []string, identity string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, identity); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// RegistryHost returns the host of a registry URL, which is how policies
+// name registries
+func RegistryHost(registry string) string {
+	if strings.Contains(registry, "://") {
+		if u, err := url.Parse(registry); err == nil {
+			return u.Host
+		}
+	}
+	return strings.TrimSuffix(registry, "/")
+}
+
+// LoadTrust loads the trust policy and the keys it is checked with. Empty
+// paths use DefaultTrustPolicyPath and DefaultKeysDir.
+func LoadTrust(keysDir, policyPath string) (*TrustPolicy, *KeyManager, error) {
+	var err error
+	if keysDir == "" {
+		if keysDir, err = DefaultKeysDir(); err != nil {
+			return nil, nil, err
+		}
+	}
+	if policyPath == "" {
+		if policyPath, err = DefaultTrustPolicyPath(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	policy, err := LoadTrustPolicy(policyPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	km, err := NewKeyManager(keysDir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create key manager: %w", err)
+	}
+	return policy, km, nil
+}
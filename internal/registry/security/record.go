@@ -0,0 +1,131 @@
+package security
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrNoTrustRecord is returned when nothing was recorded for a package
+var ErrNoTrustRecord = errors.New("no trust record")
+
+// TrustRecord keeps the signed manifest and signatures of a pulled package
+// so commands that use it later can check it against the current policy
+type TrustRecord struct {
+	Reference  Reference   `json:"reference"`
+	Manifest   []byte      `json:"manifest"`
+	Signatures []Signature `json:"signatures,omitempty"`
+	// Files maps installed files to their SHA256, detecting changes made
+	// after the pull
+	Files    map[string]string `json:"files,omitempty"`
+	PulledAt time.Time         `json:"pulledAt"`
+}
+
+// TrustStore stores trust records on disk
+type TrustStore struct {
+	Dir string
+}
+
+// DefaultTrustStore returns the trust store in ~/.sentinel/trust
+func DefaultTrustStore() (*TrustStore, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return &TrustStore{Dir: filepath.Join(home, ".sentinel", "trust")}, nil
+}
+
+// recordPath returns the file of the record for a package version
+func (s *TrustStore) recordPath(name, version string) string {
+	return filepath.Join(s.Dir, fmt.Sprintf("%s_%s.json", strings.ReplaceAll(name, "/", "_"), version))
+}
+
+// Save writes a record, replacing any earlier record for the package
+func (s *TrustStore) Save(record *TrustRecord) error {
+	if err := os.MkdirAll(s.Dir, 0700); err != nil {
+		return fmt.Errorf("failed to create trust directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal trust record: %w", err)
+	}
+	if err := os.WriteFile(s.recordPath(record.Reference.Name, record.Reference.Version), data, 0600); err != nil {
+		return fmt.Errorf("failed to write trust record: %w", err)
+	}
+	return nil
+}
+
+// Load reads the record for a package version, returning
+// ErrNoTrustRecord if there is none
+func (s *TrustStore) Load(name, version string) (*TrustRecord, error) {
+	data, err := os.ReadFile(s.recordPath(name, version))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w for %s:%s", ErrNoTrustRecord, name, version)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trust record: %w", err)
+	}
+
+	var record TrustRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("failed to parse trust record: %w", err)
+	}
+	return &record, nil
+}
+
+// AddFile records the SHA256 of an installed file
+func (r *TrustRecord) AddFile(path string) error {
+	sum, err := fileSHA256(path)
+	if err != nil {
+		return err
+	}
+	if r.Files == nil {
+		r.Files = make(map[string]string)
+	}
+	r.Files[path] = sum
+	return nil
+}
+
+// EnforceRecord checks a trust record against the policy. Installed files
+// that changed since the pull fail regardless of mode, unless the mode is
+// off.
+func (p *TrustPolicy) EnforceRecord(km *KeyManager, record *TrustRecord) error {
+	if p.RuleFor(record.Reference).Mode == TrustModeOff {
+		return nil
+	}
+
+	for path, expected := range record.Files {
+		sum, err := fileSHA256(path)
+		if err != nil {
+			return fmt.Errorf("trust policy: %s: %w", record.Reference, err)
+		}
+		if sum != expected {
+			return fmt.Errorf("trust policy: %s: %s was modified after it was pulled", record.Reference, path)
+		}
+	}
+
+	return p.Enforce(km, record.Reference, record.Manifest, record.Signatures)
+}
+
+// fileSHA256 returns the hex SHA256 of a file
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,115 @@
+package security
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// CertificateRequest asks a registry to certify an ephemeral public key
+type CertificateRequest struct {
+	PublicKey []byte `json:"publicKey"`
+}
+
+// IssuerInfo describes the key a registry certifies signing keys with
+type IssuerInfo struct {
+	KeyID       string `json:"keyId"`
+	Fingerprint string `json:"fingerprint"`
+	PublicKey   string `json:"publicKey"`
+}
+
+// NewIssuerInfo describes a stored issuer key
+func NewIssuerInfo(km *KeyManager, keyID string) (*IssuerInfo, error) {
+	publicKey, err := km.ExportPublicKey(keyID)
+	if err != nil {
+		return nil, err
+	}
+	keyFingerprint, err := km.Fingerprint(keyID)
+	if err != nil {
+		return nil, err
+	}
+	return &IssuerInfo{KeyID: keyID, Fingerprint: keyFingerprint, PublicKey: string(publicKey)}, nil
+}
+
+// RemoteIssuer obtains certificates from a registry, which certifies keys
+// for the user its token belongs to
+type RemoteIssuer struct {
+	BaseURL    string
+	Token      string
+	HTTPClient *http.Client
+}
+
+// NewRemoteIssuer creates an issuer for a registry
+func NewRemoteIssuer(baseURL, token string) *RemoteIssuer {
+	return &RemoteIssuer{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		Token:      token,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// IssueCertificate implements CertificateIssuer
+func (i *RemoteIssuer) IssueCertificate(ctx context.Context, publicKey ed25519.PublicKey) (*Certificate, error) {
+	body, err := json.Marshal(CertificateRequest{PublicKey: publicKey})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, i.BaseURL+"/v1/signing/certificate", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+i.Token)
+
+	var cert Certificate
+	if err := i.do(req, http.StatusCreated, &cert); err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
+
+// Issuer fetches the registry's issuer key
+func (i *RemoteIssuer) Issuer(ctx context.Context) (*IssuerInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, i.BaseURL+"/v1/signing/issuer", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	var info IssuerInfo
+	if err := i.do(req, http.StatusOK, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// do sends a request and decodes a JSON response with the expected status
+func (i *RemoteIssuer) do(req *http.Request, status int, result interface{}) error {
+	resp, err := i.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != status {
+		var errResp struct {
+			Error string `json:"error"`
+		}
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+		if json.Unmarshal(body, &errResp) == nil && errResp.Error != "" {
+			return fmt.Errorf("registry error: %s (status: %d)", errResp.Error, resp.StatusCode)
+		}
+		return fmt.Errorf("registry error: status %d", resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
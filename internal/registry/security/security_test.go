@@ -0,0 +1,169 @@
+package security
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestKeyManager(t *testing.T) *KeyManager {
+	t.Helper()
+	km, err := NewKeyManager(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return km
+}
+
+func TestEd25519SignAndVerify(t *testing.T) {
+	km := newTestKeyManager(t)
+	if err := km.GenerateKey("release", KeyTypeEd25519); err != nil {
+		t.Fatal(err)
+	}
+
+	content := []byte(`{"name":"summarizer","version":"1.0.0"}`)
+	signature, err := km.Sign(content, "release", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signature.Info.Algorithm != AlgorithmEd25519 {
+		t.Fatalf("algorithm = %s, want %s", signature.Info.Algorithm, AlgorithmEd25519)
+	}
+	if err := km.Verify(signature, content); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if err := km.VerifyStream(strings.NewReader(string(content)), signature); err != nil {
+		t.Fatalf("VerifyStream: %v", err)
+	}
+	if err := km.Verify(signature, []byte("tampered")); err == nil {
+		t.Fatal("Verify accepted tampered content")
+	}
+
+	// A signature may not claim another algorithm than its key's
+	signature.Info.Algorithm = AlgorithmRSA
+	if err := km.Verify(signature, content); err == nil {
+		t.Fatal("Verify accepted a mismatched algorithm")
+	}
+
+	keys, err := km.ListKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0].Type != KeyTypeEd25519 || !strings.HasPrefix(keys[0].Fingerprint, "sha256:") {
+		t.Fatalf("ListKeys = %+v", keys)
+	}
+}
+
+func TestKeylessSignAndVerify(t *testing.T) {
+	km := newTestKeyManager(t)
+	if err := km.GenerateKey("issuer", KeyTypeEd25519); err != nil {
+		t.Fatal(err)
+	}
+	issuer := &LocalIssuer{KeyManager: km, KeyID: "issuer", Identity: "alice@acme.com"}
+
+	content := []byte("manifest")
+	signature, err := SignKeyless(context.Background(), content, issuer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signature.Info.KeyID != KeylessKeyID || signature.Info.Signer != "alice@acme.com" {
+		t.Fatalf("signature info = %+v", signature.Info)
+	}
+	if err := km.Verify(signature, content); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+
+	forged := *signature
+	cert := *signature.Certificate
+	cert.Identity = "mallory@acme.com"
+	forged.Certificate = &cert
+	forged.Info.Signer = cert.Identity
+	if err := km.Verify(&forged, content); err == nil {
+		t.Fatal("Verify accepted a certificate with a changed identity")
+	}
+
+	expired := *signature
+	expired.Info.Timestamp = signature.Certificate.NotAfter.Add(time.Minute)
+	if err := km.Verify(&expired, content); err == nil {
+		t.Fatal("Verify accepted a signature made after the certificate expired")
+	}
+}
+
+func TestTrustPolicyEnforcement(t *testing.T) {
+	km := newTestKeyManager(t)
+	for _, id := range []string{"release", "personal", "issuer"} {
+		if err := km.GenerateKey(id, KeyTypeEd25519); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	policyPath := filepath.Join(t.TempDir(), "trust-policy.yaml")
+	policyYAML := `
+mode: warn
+policies:
+  - registry: https://registry.acme.com
+    namespace: acme
+    mode: enforce
+    keys: [release]
+    keyless:
+      - issuer: issuer
+        identities: ["*@acme.com"]
+  - registry: local
+    mode: off
+`
+	if err := os.WriteFile(policyPath, []byte(policyYAML), 0644); err != nil {
+		t.Fatal(err)
+	}
+	policy, err := LoadTrustPolicy(policyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := []byte("manifest")
+	ref := Reference{Registry: "registry.acme.com", Name: "acme/summarizer", Version: "1.0.0"}
+	sign := func(keyID string) Signature {
+		signature, err := km.Sign(content, keyID, "alice")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return *signature
+	}
+
+	if err := policy.Enforce(km, ref, content, []Signature{sign("release")}); err != nil {
+		t.Fatalf("trusted key rejected: %v", err)
+	}
+
+	keyless, err := SignKeyless(context.Background(), content, &LocalIssuer{KeyManager: km, KeyID: "issuer", Identity: "bob@acme.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := policy.Enforce(km, ref, content, []Signature{*keyless}); err != nil {
+		t.Fatalf("trusted keyless identity rejected: %v", err)
+	}
+
+	err = policy.Enforce(km, ref, content, []Signature{sign("personal")})
+	var trustErr *TrustError
+	if !errors.As(err, &trustErr) {
+		t.Fatalf("untrusted key: got %v, want *TrustError", err)
+	}
+	if len(trustErr.Failures) != 1 || !strings.Contains(err.Error(), `signature 1 (key "personal", signer "alice")`) {
+		t.Fatalf("error does not name the failing signature: %v", err)
+	}
+
+	if err := policy.Enforce(km, ref, content, nil); err == nil || !strings.Contains(err.Error(), "is not signed") {
+		t.Fatalf("unsigned package: got %v", err)
+	}
+
+	// Other registries fall back to the top-level warn mode
+	other := Reference{Registry: "registry.example.com", Name: "acme/summarizer", Version: "1.0.0"}
+	if err := policy.Enforce(km, other, content, nil); err != nil {
+		t.Fatalf("warn mode returned %v", err)
+	}
+	if rule := policy.RuleFor(Reference{Registry: LocalRegistry, Name: "summarizer"}); rule.Mode != TrustModeOff {
+		t.Fatalf("local images use mode %s, want off", rule.Mode)
+	}
+}
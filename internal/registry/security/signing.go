@@ -2,19 +2,47 @@ package security
 
 import (
 	"crypto"
+	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
 	"crypto/x509"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/pem"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 )
 
+// Signature algorithms. Both sign the SHA256 digest of the content, so a
+// signature over a stream verifies against the same content in memory.
+const (
+	// AlgorithmRSA is RSA PKCS#1 v1.5 over SHA256
+	AlgorithmRSA = "RSA-PKCS1-SHA256"
+
+	// AlgorithmEd25519 is Ed25519 over the SHA256 digest
+	AlgorithmEd25519 = "Ed25519-SHA256"
+)
+
+// Key types accepted by GenerateKey
+const (
+	KeyTypeRSA     = "rsa"
+	KeyTypeEd25519 = "ed25519"
+)
+
+// KeyInfo describes a key in the keys directory
+type KeyInfo struct {
+	ID          string `json:"id"`
+	Type        string `json:"type"`
+	Fingerprint string `json:"fingerprint"`
+	HasPrivate  bool   `json:"hasPrivate"`
+}
+
 // SignatureInfo contains metadata about a signature
 type SignatureInfo struct {
 	Signer    string    `json:"signer"`
@@ -26,9 +54,12 @@ type SignatureInfo struct {
 
 // Signature represents a cryptographic signature with metadata
 type Signature struct {
-	Data    string       `json:"data"`
+	Data    string        `json:"data"`
 	Info    SignatureInfo `json:"info"`
-	Content []byte       `json:"-"` // Original content (not serialized)
+	Content []byte        `json:"-"` // Original content (not serialized)
+	// Certificate binds the ephemeral key of a keyless signature to an
+	// identity; it is nil for signatures made with a stored key
+	Certificate *Certificate `json:"certificate,omitempty"`
 }
 
 // KeyManager handles cryptographic keys for signing and verification
@@ -48,6 +79,18 @@ func NewKeyManager(keysDir string) (*KeyManager, error) {
 	}, nil
 }
 
+// DefaultKeysDir returns $SENTINEL_KEYS_DIR or ~/.sentinel/keys
+func DefaultKeysDir() (string, error) {
+	if dir := os.Getenv("SENTINEL_KEYS_DIR"); dir != "" {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, ".sentinel", "keys"), nil
+}
+
 // GenerateKeyPair generates a new RSA key pair
 func (km *KeyManager) GenerateKeyPair(keyID string, keySize int) error {
 	if keySize == 0 {
@@ -91,42 +134,138 @@ func (km *KeyManager) GenerateKeyPair(keyID string, keySize int) error {
 	return nil
 }
 
+// GenerateEd25519KeyPair generates a new Ed25519 key pair
+func (km *KeyManager) GenerateEd25519KeyPair(keyID string) error {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return fmt.Errorf("failed to generate key pair: %w", err)
+	}
+
+	// Encode private key to PKCS#8 PEM
+	privateKeyBytes, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return fmt.Errorf("failed to marshal private key: %w", err)
+	}
+	privateKeyPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "PRIVATE KEY",
+		Bytes: privateKeyBytes,
+	})
+
+	// Encode public key to PEM
+	publicKeyBytes, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return fmt.Errorf("failed to marshal public key: %w", err)
+	}
+	publicKeyPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: publicKeyBytes,
+	})
+
+	// Write keys to files
+	privateKeyPath := filepath.Join(km.KeysDir, keyID+".key")
+	if err := os.WriteFile(privateKeyPath, privateKeyPEM, 0600); err != nil {
+		return fmt.Errorf("failed to write private key: %w", err)
+	}
+
+	publicKeyPath := filepath.Join(km.KeysDir, keyID+".pub")
+	if err := os.WriteFile(publicKeyPath, publicKeyPEM, 0644); err != nil {
+		return fmt.Errorf("failed to write public key: %w", err)
+	}
+
+	return nil
+}
+
+// GenerateKey generates a key pair of the given type, Ed25519 by default
+func (km *KeyManager) GenerateKey(keyID, keyType string) error {
+	switch keyType {
+	case "", KeyTypeEd25519:
+		return km.GenerateEd25519KeyPair(keyID)
+	case KeyTypeRSA:
+		return km.GenerateKeyPair(keyID, 0)
+	default:
+		return fmt.Errorf("unsupported key type %q: use %s or %s", keyType, KeyTypeEd25519, KeyTypeRSA)
+	}
+}
+
 // Sign creates a signature for the given content
 func (km *KeyManager) Sign(content []byte, keyID string, signer string) (*Signature, error) {
+	// Create hash of content
+	hash := sha256.Sum256(content)
+
+	signature, err := km.signDigest(hash[:], keyID, signer)
+	if err != nil {
+		return nil, err
+	}
+	signature.Content = content
+
+	return signature, nil
+}
+
+// signDigest signs a SHA256 digest with a stored private key
+func (km *KeyManager) signDigest(digest []byte, keyID string, signer string) (*Signature, error) {
 	// Load private key
 	privateKey, err := km.loadPrivateKey(keyID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load private key: %w", err)
 	}
 
-	// Create hash of content
-	hash := sha256.Sum256(content)
-
 	// Sign the hash
-	signature, err := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, hash[:])
+	signature, algorithm, err := signWithKey(privateKey, digest)
 	if err != nil {
 		return nil, fmt.Errorf("failed to sign content: %w", err)
 	}
 
-	// Encode signature to base64
-	signatureBase64 := base64.StdEncoding.EncodeToString(signature)
-
 	// Create signature info
 	info := SignatureInfo{
 		Signer:    signer,
 		Timestamp: time.Now().UTC(),
-		Algorithm: "RSA-PKCS1-SHA256",
+		Algorithm: algorithm,
 		Version:   "1.0",
 		KeyID:     keyID,
 	}
 
 	return &Signature{
-		Data:    signatureBase64,
-		Info:    info,
-		Content: content,
+		Data: base64.StdEncoding.EncodeToString(signature),
+		Info: info,
 	}, nil
 }
 
+// signWithKey signs a SHA256 digest and returns the signature and its
+// algorithm
+func signWithKey(privateKey crypto.Signer, digest []byte) ([]byte, string, error) {
+	switch key := privateKey.(type) {
+	case *rsa.PrivateKey:
+		signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest)
+		return signature, AlgorithmRSA, err
+	case ed25519.PrivateKey:
+		return ed25519.Sign(key, digest), AlgorithmEd25519, nil
+	default:
+		return nil, "", fmt.Errorf("unsupported private key type %T", privateKey)
+	}
+}
+
+// verifyWithKey checks a signature over a SHA256 digest. The algorithm
+// recorded with the signature must match the key type.
+func verifyWithKey(publicKey crypto.PublicKey, algorithm string, digest, signature []byte) error {
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		if algorithm != AlgorithmRSA {
+			return fmt.Errorf("algorithm %q does not match RSA key", algorithm)
+		}
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest, signature)
+	case ed25519.PublicKey:
+		if algorithm != AlgorithmEd25519 {
+			return fmt.Errorf("algorithm %q does not match Ed25519 key", algorithm)
+		}
+		if !ed25519.Verify(key, digest, signature) {
+			return fmt.Errorf("ed25519: verification error")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported public key type %T", publicKey)
+	}
+}
+
 // SignFile creates a signature for a file
 func (km *KeyManager) SignFile(filePath string, keyID string, signer string) (*Signature, error) {
 	// Read file
@@ -139,26 +278,35 @@ func (km *KeyManager) SignFile(filePath string, keyID string, signer string) (*S
 	return km.Sign(content, keyID, signer)
 }
 
-// Verify checks if a signature is valid
+// Verify checks if a signature is valid. Keyless signatures are checked
+// against their certificate instead of a stored key.
 func (km *KeyManager) Verify(signature *Signature, content []byte) error {
-	// Load public key
-	publicKey, err := km.loadPublicKey(signature.Info.KeyID)
-	if err != nil {
-		return fmt.Errorf("failed to load public key: %w", err)
-	}
+	// Create hash of content
+	hash := sha256.Sum256(content)
 
+	return km.verifyDigest(signature, hash[:])
+}
+
+// verifyDigest checks a signature over a SHA256 digest
+func (km *KeyManager) verifyDigest(signature *Signature, digest []byte) error {
 	// Decode signature from base64
 	signatureBytes, err := base64.StdEncoding.DecodeString(signature.Data)
 	if err != nil {
 		return fmt.Errorf("failed to decode signature: %w", err)
 	}
 
-	// Create hash of content
-	hash := sha256.Sum256(content)
+	if signature.Certificate != nil {
+		return km.verifyKeyless(signature, digest, signatureBytes)
+	}
 
-	// Verify signature
-	err = rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, hash[:], signatureBytes)
+	// Load public key
+	publicKey, err := km.loadPublicKey(signature.Info.KeyID)
 	if err != nil {
+		return fmt.Errorf("failed to load public key: %w", err)
+	}
+
+	// Verify signature
+	if err := verifyWithKey(publicKey, signature.Info.Algorithm, digest, signatureBytes); err != nil {
 		return fmt.Errorf("invalid signature: %w", err)
 	}
 
@@ -185,10 +333,14 @@ func (km *KeyManager) ImportPublicKey(keyID string, keyPath string) error {
 		return fmt.Errorf("failed to read key file: %w", err)
 	}
 
+	return km.ImportPublicKeyData(keyID, keyData)
+}
+
+// ImportPublicKeyData imports a PEM encoded public key
+func (km *KeyManager) ImportPublicKeyData(keyID string, keyData []byte) error {
 	// Verify it's a valid public key
-	block, _ := pem.Decode(keyData)
-	if block == nil || block.Type != "RSA PUBLIC KEY" {
-		return fmt.Errorf("invalid public key format")
+	if _, err := parsePublicKey(keyData); err != nil {
+		return err
 	}
 
 	// Save to keys directory
@@ -200,8 +352,9 @@ func (km *KeyManager) ImportPublicKey(keyID string, keyPath string) error {
 	return nil
 }
 
-// loadPrivateKey loads a private key from the keys directory
-func (km *KeyManager) loadPrivateKey(keyID string) (*rsa.PrivateKey, error) {
+// loadPrivateKey loads an RSA or Ed25519 private key from the keys
+// directory
+func (km *KeyManager) loadPrivateKey(keyID string) (crypto.Signer, error) {
 	// Read key file
 	keyPath := filepath.Join(km.KeysDir, keyID+".key")
 	keyData, err := os.ReadFile(keyPath)
@@ -211,21 +364,37 @@ func (km *KeyManager) loadPrivateKey(keyID string) (*rsa.PrivateKey, error) {
 
 	// Decode PEM block
 	block, _ := pem.Decode(keyData)
-	if block == nil || block.Type != "RSA PRIVATE KEY" {
+	if block == nil {
 		return nil, fmt.Errorf("invalid private key format")
 	}
 
-	// Parse private key
-	privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse private key: %w", err)
+		}
+		return privateKey, nil
+	case "PRIVATE KEY":
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse private key: %w", err)
+		}
+		switch privateKey := parsed.(type) {
+		case *rsa.PrivateKey:
+			return privateKey, nil
+		case ed25519.PrivateKey:
+			return privateKey, nil
+		default:
+			return nil, fmt.Errorf("unsupported private key type %T", parsed)
+		}
+	default:
+		return nil, fmt.Errorf("invalid private key format")
 	}
-
-	return privateKey, nil
 }
 
-// loadPublicKey loads a public key from the keys directory
-func (km *KeyManager) loadPublicKey(keyID string) (*rsa.PublicKey, error) {
+// loadPublicKey loads an RSA or Ed25519 public key from the keys directory
+func (km *KeyManager) loadPublicKey(keyID string) (crypto.PublicKey, error) {
 	// Read key file
 	keyPath := filepath.Join(km.KeysDir, keyID+".pub")
 	keyData, err := os.ReadFile(keyPath)
@@ -233,9 +402,14 @@ func (km *KeyManager) loadPublicKey(keyID string) (*rsa.PublicKey, error) {
 		return nil, fmt.Errorf("failed to read public key: %w", err)
 	}
 
+	return parsePublicKey(keyData)
+}
+
+// parsePublicKey parses a PEM encoded RSA or Ed25519 public key
+func parsePublicKey(keyData []byte) (crypto.PublicKey, error) {
 	// Decode PEM block
 	block, _ := pem.Decode(keyData)
-	if block == nil || block.Type != "RSA PUBLIC KEY" {
+	if block == nil || (block.Type != "RSA PUBLIC KEY" && block.Type != "PUBLIC KEY") {
 		return nil, fmt.Errorf("invalid public key format")
 	}
 
@@ -245,13 +419,84 @@ func (km *KeyManager) loadPublicKey(keyID string) (*rsa.PublicKey, error) {
 		return nil, fmt.Errorf("failed to parse public key: %w", err)
 	}
 
-	// Cast to RSA public key
-	publicKey, ok := pubInterface.(*rsa.PublicKey)
-	if !ok {
-		return nil, fmt.Errorf("not an RSA public key")
+	switch publicKey := pubInterface.(type) {
+	case *rsa.PublicKey:
+		return publicKey, nil
+	case ed25519.PublicKey:
+		return publicKey, nil
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", pubInterface)
+	}
+}
+
+// Fingerprint returns the fingerprint of a stored public key, the SHA256
+// of its DER encoding as "sha256:<hex>"
+func (km *KeyManager) Fingerprint(keyID string) (string, error) {
+	publicKey, err := km.loadPublicKey(keyID)
+	if err != nil {
+		return "", err
+	}
+	return fingerprint(publicKey)
+}
+
+// fingerprint returns the fingerprint of a public key
+func fingerprint(publicKey crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal public key: %w", err)
 	}
+	sum := sha256.Sum256(der)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
 
-	return publicKey, nil
+// ListKeys describes the public keys in the keys directory
+func (km *KeyManager) ListKeys() ([]KeyInfo, error) {
+	entries, err := os.ReadDir(km.KeysDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keys directory: %w", err)
+	}
+
+	var keys []KeyInfo
+	for _, entry := range entries {
+		keyID, ok := strings.CutSuffix(entry.Name(), ".pub")
+		if !ok || entry.IsDir() {
+			continue
+		}
+
+		publicKey, err := km.loadPublicKey(keyID)
+		if err != nil {
+			continue
+		}
+		keyFingerprint, err := fingerprint(publicKey)
+		if err != nil {
+			continue
+		}
+
+		keyType := KeyTypeRSA
+		if _, ok := publicKey.(ed25519.PublicKey); ok {
+			keyType = KeyTypeEd25519
+		}
+
+		_, err = os.Stat(filepath.Join(km.KeysDir, keyID+".key"))
+		keys = append(keys, KeyInfo{
+			ID:          keyID,
+			Type:        keyType,
+			Fingerprint: keyFingerprint,
+			HasPrivate:  err == nil,
+		})
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+
+	return keys, nil
+}
+
+// ExportPublicKey returns the PEM encoded public key of a stored key
+func (km *KeyManager) ExportPublicKey(keyID string) ([]byte, error) {
+	keyData, err := os.ReadFile(filepath.Join(km.KeysDir, keyID+".pub"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read public key: %w", err)
+	}
+	return keyData, nil
 }
 
 // SignStream signs a data stream
@@ -263,35 +508,13 @@ func (km *KeyManager) SignStream(reader io.Reader, keyID string, signer string)
 	}
 	hashSum := hash.Sum(nil)
 
-	// Load private key
-	privateKey, err := km.loadPrivateKey(keyID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to load private key: %w", err)
-	}
-
-	// Sign the hash
-	signature, err := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, hashSum)
+	signature, err := km.signDigest(hashSum, keyID, signer)
 	if err != nil {
-		return nil, fmt.Errorf("failed to sign hash: %w", err)
-	}
-
-	// Encode signature to base64
-	signatureBase64 := base64.StdEncoding.EncodeToString(signature)
-
-	// Create signature info
-	info := SignatureInfo{
-		Signer:    signer,
-		Timestamp: time.Now().UTC(),
-		Algorithm: "RSA-PKCS1-SHA256",
-		Version:   "1.0",
-		KeyID:     keyID,
+		return nil, err
 	}
+	signature.Content = hashSum
 
-	return &Signature{
-		Data:    signatureBase64,
-		Info:    info,
-		Content: hashSum,
-	}, nil
+	return signature, nil
 }
 
 // VerifyStream verifies a signature against a data stream
@@ -301,25 +524,6 @@ func (km *KeyManager) VerifyStream(reader io.Reader, signature *Signature) error
 	if _, err := io.Copy(hash, reader); err != nil {
 		return fmt.Errorf("failed to hash stream: %w", err)
 	}
-	hashSum := hash.Sum(nil)
-
-	// Load public key
-	publicKey, err := km.loadPublicKey(signature.Info.KeyID)
-	if err != nil {
-		return fmt.Errorf("failed to load public key: %w", err)
-	}
-
-	// Decode signature from base64
-	signatureBytes, err := base64.StdEncoding.DecodeString(signature.Data)
-	if err != nil {
-		return fmt.Errorf("failed to decode signature: %w", err)
-	}
 
-	// Verify signature
-	err = rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, hashSum, signatureBytes)
-	if err != nil {
-		return fmt.Errorf("invalid signature: %w", err)
-	}
-
-	return nil
+	return km.verifyDigest(signature, hash.Sum(nil))
 }
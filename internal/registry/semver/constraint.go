@@ -0,0 +1,334 @@
+package semver
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Latest is the tag that names the newest version of a package
+const Latest = "latest"
+
+// Constraint is a set of version ranges. Ranges are separated by "||"; a
+// range is a list of comparators separated by spaces or commas, all of
+// which a version must satisfy:
+//
+//	1.2.3        exactly 1.2.3
+//	1.2, 1.2.x   any 1.2 version
+//	^1.2.3       >=1.2.3 <2.0.0 (^0.4.1 is >=0.4.1 <0.5.0)
+//	~1.2.3       >=1.2.3 <1.3.0
+//	>=2 <3       any 2.x version
+//	*            any version
+//
+// Pre-releases only satisfy a range that names a pre-release of the same
+// major, minor and patch version, so ^1.2 never selects 2.0.0-rc.1.
+type Constraint struct {
+	raw    string
+	ranges [][]comparator
+}
+
+// comparator compares a version against a bound
+type comparator struct {
+	op      string
+	version *Version
+}
+
+// ParseConstraint parses a version constraint
+func ParseConstraint(s string) (*Constraint, error) {
+	c := &Constraint{raw: strings.TrimSpace(s)}
+	if c.raw == "" {
+		return nil, fmt.Errorf("empty version constraint")
+	}
+
+	for _, part := range strings.Split(c.raw, "||") {
+		tokens := tokenize(part)
+		if len(tokens) == 0 {
+			return nil, fmt.Errorf("invalid version constraint %q: empty range", s)
+		}
+
+		var r []comparator
+		for _, token := range tokens {
+			comparators, err := expand(token)
+			if err != nil {
+				return nil, fmt.Errorf("invalid version constraint %q: %w", s, err)
+			}
+			r = append(r, comparators...)
+		}
+		c.ranges = append(c.ranges, r)
+	}
+
+	return c, nil
+}
+
+// tokenize splits a range into comparators, joining operators written
+// apart from their version (">= 2")
+func tokenize(s string) []string {
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ' ' || r == '\t' || r == ','
+	})
+
+	var tokens []string
+	for i := 0; i < len(fields); i++ {
+		token := fields[i]
+		if strings.Trim(token, "<>=~^") == "" && i+1 < len(fields) {
+			token += fields[i+1]
+			i++
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
+
+// expand turns a comparator, which may use a shorthand operator or a
+// partial version, into primitive comparators
+func expand(token string) ([]comparator, error) {
+	op := ""
+	for _, prefix := range []string{">=", "<=", "~>", ">", "<", "=", "^", "~"} {
+		if strings.HasPrefix(token, prefix) {
+			op = prefix
+			break
+		}
+	}
+
+	v, given, err := parsePartial(strings.TrimPrefix(token, op))
+	if err != nil {
+		return nil, err
+	}
+	bump := func(major, minor, patch uint64) *Version {
+		return &Version{Major: major, Minor: minor, Patch: patch}
+	}
+	upper := func() *Version {
+		switch given {
+		case 1:
+			return bump(v.Major+1, 0, 0)
+		case 2:
+			return bump(v.Major, v.Minor+1, 0)
+		}
+		return bump(v.Major, v.Minor, v.Patch+1)
+	}
+
+	switch op {
+	case "", "=":
+		if given == 0 {
+			return nil, nil
+		}
+		if given == 3 {
+			return []comparator{{"=", v}}, nil
+		}
+		return []comparator{{">=", v}, {"<", upper()}}, nil
+
+	case ">":
+		if given == 0 {
+			return nil, fmt.Errorf("%q matches no version", token)
+		}
+		if given == 3 {
+			return []comparator{{">", v}}, nil
+		}
+		return []comparator{{">=", upper()}}, nil
+
+	case ">=":
+		if given == 0 {
+			return nil, nil
+		}
+		return []comparator{{">=", v}}, nil
+
+	case "<":
+		if given == 0 {
+			return nil, fmt.Errorf("%q matches no version", token)
+		}
+		return []comparator{{"<", v}}, nil
+
+	case "<=":
+		if given == 0 {
+			return nil, nil
+		}
+		if given == 3 {
+			return []comparator{{"<=", v}}, nil
+		}
+		return []comparator{{"<", upper()}}, nil
+
+	case "^":
+		if given == 0 {
+			return nil, nil
+		}
+		var limit *Version
+		switch {
+		case v.Major > 0 || given == 1:
+			limit = bump(v.Major+1, 0, 0)
+		case v.Minor > 0 || given == 2:
+			limit = bump(0, v.Minor+1, 0)
+		default:
+			limit = bump(0, 0, v.Patch+1)
+		}
+		return []comparator{{">=", v}, {"<", limit}}, nil
+
+	case "~", "~>":
+		if given == 0 {
+			return nil, nil
+		}
+		if given == 1 {
+			return []comparator{{">=", v}, {"<", bump(v.Major+1, 0, 0)}}, nil
+		}
+		return []comparator{{">=", v}, {"<", bump(v.Major, v.Minor+1, 0)}}, nil
+	}
+
+	return nil, fmt.Errorf("unknown operator in %q", token)
+}
+
+// String returns the constraint as it was written
+func (c *Constraint) String() string {
+	return c.raw
+}
+
+// Check reports whether a version satisfies the constraint
+func (c *Constraint) Check(v *Version) bool {
+	for _, r := range c.ranges {
+		if checkRange(r, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkRange reports whether a version satisfies every comparator of a
+// range, with pre-releases only allowed when the range names one of the
+// same version
+func checkRange(r []comparator, v *Version) bool {
+	for _, cmp := range r {
+		if !cmp.check(v) {
+			return false
+		}
+	}
+	if v.Prerelease == "" {
+		return true
+	}
+	for _, cmp := range r {
+		if cmp.version.Prerelease != "" && cmp.version.sameCore(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// check compares a version with the comparator's bound
+func (c comparator) check(v *Version) bool {
+	result := v.Compare(c.version)
+	switch c.op {
+	case "=":
+		return result == 0
+	case ">":
+		return result > 0
+	case ">=":
+		return result >= 0
+	case "<":
+		return result < 0
+	case "<=":
+		return result <= 0
+	}
+	return false
+}
+
+// IsConstraint reports whether s is a version constraint rather than a
+// plain tag name such as "latest" or "stable"
+func IsConstraint(s string) bool {
+	if s == "" || s == Latest {
+		return false
+	}
+	_, err := ParseConstraint(s)
+	return err == nil
+}
+
+// IsExact reports whether s is a constraint that allows a single version,
+// such as "1.2.3", so resolving it needs no registry lookup
+func IsExact(s string) bool {
+	c, err := ParseConstraint(s)
+	if err != nil || len(c.ranges) != 1 || len(c.ranges[0]) != 1 {
+		return false
+	}
+	return c.ranges[0][0].op == "="
+}
+
+// Select returns the newest of the available versions that satisfies every
+// constraint. An empty constraint or "latest" accepts any release, falling
+// back to a "latest" tag when no version is a semantic version. Other tags
+// that are not constraints, such as "stable", select themselves and cannot
+// be combined with different constraints.
+func Select(available []string, constraints ...string) (string, error) {
+	var tag string
+	var ranges []*Constraint
+	for _, constraint := range constraints {
+		constraint = strings.TrimSpace(constraint)
+		if constraint == "" || constraint == Latest {
+			continue
+		}
+		c, err := ParseConstraint(constraint)
+		if err != nil {
+			if tag != "" && tag != constraint {
+				return "", fmt.Errorf("conflicting tags %q and %q", tag, constraint)
+			}
+			tag = constraint
+			continue
+		}
+		ranges = append(ranges, c)
+	}
+
+	if tag != "" {
+		if len(ranges) > 0 {
+			return "", fmt.Errorf("tag %q cannot be combined with constraint %q", tag, ranges[0])
+		}
+		if !contains(available, tag) {
+			return "", fmt.Errorf("tag %q not found", tag)
+		}
+		return tag, nil
+	}
+
+	var versions []*Version
+	for _, s := range available {
+		if v, err := Parse(s); err == nil {
+			versions = append(versions, v)
+		}
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].Compare(versions[j]) > 0
+	})
+
+	for _, v := range versions {
+		// Without a constraint only releases are picked
+		if len(ranges) == 0 && v.Prerelease != "" {
+			continue
+		}
+		satisfied := true
+		for _, c := range ranges {
+			if !c.Check(v) {
+				satisfied = false
+				break
+			}
+		}
+		if satisfied {
+			return v.String(), nil
+		}
+	}
+
+	if len(ranges) == 0 {
+		if contains(available, Latest) {
+			return Latest, nil
+		}
+		return "", fmt.Errorf("no versions available")
+	}
+
+	names := make([]string, len(ranges))
+	for i, c := range ranges {
+		names[i] = c.String()
+	}
+	return "", fmt.Errorf("no version satisfies %s (available: %s)", strings.Join(names, " and "), strings.Join(available, ", "))
+}
+
+// contains reports whether a list holds a string
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package semver
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	ordered := []string{"0.9.0", "1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta.2", "1.0.0-beta.11", "1.0.0-rc.1", "v1.0.0", "1.0.1", "1.10.0"}
+	for i := 1; i < len(ordered); i++ {
+		a, err := Parse(ordered[i-1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := Parse(ordered[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a.Compare(b) >= 0 || b.Compare(a) <= 0 {
+			t.Errorf("%s should sort before %s", a, b)
+		}
+	}
+
+	for _, invalid := range []string{"", "latest", "1.2.3.4", "1.x.3", "1.x", "a.b.c"} {
+		if _, err := Parse(invalid); err == nil {
+			t.Errorf("Parse(%q) succeeded", invalid)
+		}
+	}
+}
+
+func TestConstraintCheck(t *testing.T) {
+	tests := []struct {
+		constraint string
+		match      []string
+		noMatch    []string
+	}{
+		{"^1.2", []string{"1.2.0", "1.9.9"}, []string{"1.1.9", "2.0.0", "2.0.0-rc.1"}},
+		{"^1.2.3", []string{"1.2.3", "1.3.0"}, []string{"1.2.2", "2.0.0"}},
+		{"^0.4.1", []string{"0.4.1", "0.4.9"}, []string{"0.4.0", "0.5.0"}},
+		{"^0.0.3", []string{"0.0.3"}, []string{"0.0.4"}},
+		{"~0.4.1", []string{"0.4.1", "0.4.7"}, []string{"0.4.0", "0.5.0"}},
+		{"~1", []string{"1.0.0", "1.5.0"}, []string{"2.0.0"}},
+		{">=2 <3", []string{"2.0.0", "v2.9.1"}, []string{"1.9.0", "3.0.0"}},
+		{">= 2, < 3", []string{"2.1.0"}, []string{"3.0.0"}},
+		{"1.2", []string{"1.2.0", "1.2.8"}, []string{"1.3.0"}},
+		{"1.x", []string{"1.0.0", "1.8.0"}, []string{"2.0.0"}},
+		{"1.2.3", []string{"1.2.3", "v1.2.3"}, []string{"1.2.4"}},
+		{"<=1.2", []string{"1.2.9"}, []string{"1.3.0"}},
+		{"*", []string{"0.0.1", "5.0.0"}, []string{"5.0.0-beta"}},
+		{"^1 || ^3", []string{"1.1.0", "3.2.0"}, []string{"2.0.0"}},
+		{">=1.0.0-rc.1 <2", []string{"1.0.0-rc.2", "1.4.0"}, []string{"1.1.0-rc.1"}},
+	}
+
+	for _, tt := range tests {
+		c, err := ParseConstraint(tt.constraint)
+		if err != nil {
+			t.Fatalf("ParseConstraint(%q): %v", tt.constraint, err)
+		}
+		for _, s := range tt.match {
+			if v, _ := Parse(s); !c.Check(v) {
+				t.Errorf("%q should match %s", tt.constraint, s)
+			}
+		}
+		for _, s := range tt.noMatch {
+			if v, _ := Parse(s); c.Check(v) {
+				t.Errorf("%q should not match %s", tt.constraint, s)
+			}
+		}
+	}
+
+	for s, want := range map[string]bool{"1.2.3": true, "v1.2.3-rc.1": true, "=1.2.3": true, "1.2": false, "^1.2.3": false, "latest": false} {
+		if IsExact(s) != want {
+			t.Errorf("IsExact(%q) = %v, want %v", s, !want, want)
+		}
+	}
+
+	for _, invalid := range []string{"", "stable", "^", ">*", "1.2 || ", "=>1"} {
+		if _, err := ParseConstraint(invalid); err == nil {
+			t.Errorf("ParseConstraint(%q) succeeded", invalid)
+		}
+	}
+}
+
+func TestSelect(t *testing.T) {
+	available := []string{"latest", "1.0.0", "1.2.0", "v1.4.2", "2.0.0-rc.1", "stable", "0.4.1", "0.4.3"}
+
+	tests := []struct {
+		constraints []string
+		want        string
+	}{
+		{nil, "v1.4.2"},
+		{[]string{"latest"}, "v1.4.2"},
+		{[]string{"^1.2"}, "v1.4.2"},
+		{[]string{"^1.0", "<1.3"}, "1.2.0"},
+		{[]string{"~0.4.1"}, "0.4.3"},
+		{[]string{"1.0.0"}, "1.0.0"},
+		{[]string{"stable", "stable"}, "stable"},
+		{[]string{">=2.0.0-rc.1"}, "2.0.0-rc.1"},
+	}
+	for _, tt := range tests {
+		got, err := Select(available, tt.constraints...)
+		if err != nil {
+			t.Errorf("Select(%v): %v", tt.constraints, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Select(%v) = %s, want %s", tt.constraints, got, tt.want)
+		}
+	}
+
+	if got, err := Select([]string{"latest", "dev"}); err != nil || got != "latest" {
+		t.Errorf("Select without releases = %s, %v, want latest", got, err)
+	}
+
+	failures := map[string][]string{
+		"no version satisfies ^3":         {"^3"},
+		"no version satisfies ^1 and >=2": {"^1", ">=2"},
+		`tag "nightly" not found`:         {"nightly"},
+		`conflicting tags`:                {"stable", "dev"},
+		`cannot be combined`:              {"stable", "^1"},
+	}
+	for want, constraints := range failures {
+		if _, err := Select(available, constraints...); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Select(%v) = %v, want error containing %q", constraints, err, want)
+		}
+	}
+}
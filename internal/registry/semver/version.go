@@ -0,0 +1,158 @@
+// Package semver parses semantic versions and the version constraints used
+// in stack "uses:" references, and selects the newest published version
+// that satisfies them.
+package semver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a parsed semantic version
+type Version struct {
+	Major      uint64
+	Minor      uint64
+	Patch      uint64
+	Prerelease string
+	Build      string
+
+	original string
+	wildcard bool
+}
+
+// Parse parses a version such as "1.2.3", "v1.2.3-rc.1" or "1.2". Missing
+// minor and patch numbers are zero.
+func Parse(s string) (*Version, error) {
+	v, given, err := parsePartial(s)
+	if err != nil {
+		return nil, err
+	}
+	if given < 1 || v.wildcard {
+		return nil, fmt.Errorf("invalid version %q", s)
+	}
+	return v, nil
+}
+
+// parsePartial parses a version whose minor and patch numbers may be
+// missing or wildcards ("x", "X", "*"), returning how many numbers were
+// given before the first missing or wildcard one
+func parsePartial(s string) (*Version, int, error) {
+	v := &Version{original: s}
+
+	rest := strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(s), "v"), "=")
+	rest, v.Build, _ = strings.Cut(rest, "+")
+	rest, v.Prerelease, _ = strings.Cut(rest, "-")
+	if rest == "" {
+		return nil, 0, fmt.Errorf("invalid version %q", s)
+	}
+
+	fields := strings.Split(rest, ".")
+	if len(fields) > 3 {
+		return nil, 0, fmt.Errorf("invalid version %q", s)
+	}
+
+	numbers := []*uint64{&v.Major, &v.Minor, &v.Patch}
+	given := 0
+	for i, field := range fields {
+		if field == "x" || field == "X" || field == "*" {
+			v.wildcard = true
+			continue
+		}
+		if given < i {
+			return nil, 0, fmt.Errorf("invalid version %q: number after wildcard", s)
+		}
+		n, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid version %q", s)
+		}
+		*numbers[i] = n
+		given++
+	}
+	if v.wildcard && v.Prerelease != "" {
+		return nil, 0, fmt.Errorf("invalid version %q: pre-release on a wildcard", s)
+	}
+
+	return v, given, nil
+}
+
+// String returns the version as it was written
+func (v *Version) String() string {
+	if v.original != "" {
+		return v.original
+	}
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Prerelease != "" {
+		s += "-" + v.Prerelease
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+	return s
+}
+
+// Compare returns -1, 0 or 1 as v is older than, the same as or newer than
+// o. Pre-releases are older than their release; build metadata is ignored.
+func (v *Version) Compare(o *Version) int {
+	if c := compareUint(v.Major, o.Major); c != 0 {
+		return c
+	}
+	if c := compareUint(v.Minor, o.Minor); c != 0 {
+		return c
+	}
+	if c := compareUint(v.Patch, o.Patch); c != 0 {
+		return c
+	}
+	return comparePrerelease(v.Prerelease, o.Prerelease)
+}
+
+// sameCore reports whether two versions have the same major, minor and patch
+func (v *Version) sameCore(o *Version) bool {
+	return v.Major == o.Major && v.Minor == o.Minor && v.Patch == o.Patch
+}
+
+// compareUint compares two numbers
+func compareUint(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// comparePrerelease orders pre-release identifiers: numeric identifiers
+// numerically and before alphanumeric ones, and no pre-release last
+func comparePrerelease(a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return 1
+	case b == "":
+		return -1
+	}
+
+	aFields := strings.Split(a, ".")
+	bFields := strings.Split(b, ".")
+	for i := 0; i < len(aFields) && i < len(bFields); i++ {
+		aNum, aErr := strconv.ParseUint(aFields[i], 10, 64)
+		bNum, bErr := strconv.ParseUint(bFields[i], 10, 64)
+		var c int
+		switch {
+		case aErr == nil && bErr == nil:
+			c = compareUint(aNum, bNum)
+		case aErr == nil:
+			c = -1
+		case bErr == nil:
+			c = 1
+		default:
+			c = strings.Compare(aFields[i], bFields[i])
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return compareUint(uint64(len(aFields)), uint64(len(bFields)))
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/registry/blob"
+	"github.com/satishgonella2024/sentinelstacks/pkg/types"
+)
+
+// maxManifestSize bounds the manifest read from an uploaded archive
+const maxManifestSize = 1 << 20
+
+// packageManifest holds the manifest fields the registry indexes
+type packageManifest struct {
+	Name         string             `json:"name"`
+	Version      string             `json:"version"`
+	Type         types.PackageType  `json:"type"`
+	Description  string             `json:"description"`
+	Author       string             `json:"author"`
+	Dependencies []types.Dependency `json:"dependencies"`
+	Labels       map[string]string  `json:"labels,omitempty"`
+	Signatures   []json.RawMessage  `json:"signatures"`
+}
+
+// readManifest finds and parses the manifest of a gzipped package archive,
+// with or without a PackageBuilder header
+func readManifest(archive io.Reader) (*packageManifest, error) {
+	tarReader, closer, err := blob.OpenArchive(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("package has no %s", blob.ArchiveManifestName)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read package archive: %w", err)
+		}
+		if header.Name != blob.ArchiveManifestName && header.Name != blob.BuilderManifestName {
+			continue
+		}
+
+		data, err := io.ReadAll(io.LimitReader(tarReader, maxManifestSize))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read package manifest: %w", err)
+		}
+
+		return parseManifest(data)
+	}
+}
+
+// parseManifest parses a package manifest, from an archive or a config blob
+func parseManifest(data []byte) (*packageManifest, error) {
+	var manifest packageManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse package manifest: %w", err)
+	}
+	if manifest.Name == "" || manifest.Version == "" {
+		return nil, fmt.Errorf("package manifest must set name and version")
+	}
+
+	return &manifest, nil
+}
@@ -0,0 +1,254 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/registry/blob"
+)
+
+// blobSource reads blobs from a registry store
+type blobSource struct {
+	store Store
+}
+
+// Open implements blob.Source
+func (b blobSource) Open(d blob.Digest) (io.ReadCloser, error) {
+	return b.store.OpenBlob(d)
+}
+
+// blobDigest parses the digest route variable, writing an error response
+// if it is malformed
+func (s *Server) blobDigest(w http.ResponseWriter, r *http.Request) (blob.Digest, bool) {
+	d, err := blob.Parse(pathVar(r, "digest"))
+	if err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid digest")
+		return "", false
+	}
+	return d, true
+}
+
+// getBlobHandler returns a blob, or only its size for HEAD requests
+func (s *Server) getBlobHandler(w http.ResponseWriter, r *http.Request) {
+	d, ok := s.blobDigest(w, r)
+	if !ok {
+		return
+	}
+
+	size, err := s.config.Store.StatBlob(d)
+	if err != nil {
+		s.sendStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	w.Header().Set("X-Content-Digest", d.String())
+	if r.Method == http.MethodHead {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	content, err := s.config.Store.OpenBlob(d)
+	if err != nil {
+		s.sendStoreError(w, err)
+		return
+	}
+	defer content.Close()
+
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, content); err != nil {
+		s.log.Printf("Error sending blob %s: %v", d.Short(), err)
+	}
+}
+
+// putBlobHandler stores the request body under the digest in the URL,
+// rejecting content that does not match it
+func (s *Server) putBlobHandler(w http.ResponseWriter, r *http.Request) {
+	d, ok := s.blobDigest(w, r)
+	if !ok {
+		return
+	}
+
+	if _, err := s.config.Store.StatBlob(d); err == nil {
+		s.sendJSON(w, http.StatusOK, map[string]string{"digest": d.String()})
+		return
+	}
+
+	err := s.config.Store.PutBlob(d, http.MaxBytesReader(w, r.Body, s.config.MaxUploadSize))
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		s.sendError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Blob exceeds the maximum size of %d bytes", s.config.MaxUploadSize))
+		return
+	case errors.Is(err, blob.ErrDigestMismatch):
+		s.sendError(w, http.StatusBadRequest, "Blob content does not match its digest")
+		return
+	case err != nil:
+		s.sendStoreError(w, err)
+		return
+	}
+
+	s.sendJSON(w, http.StatusCreated, map[string]string{"digest": d.String()})
+}
+
+// putManifestHandler publishes a package version from a manifest whose
+// blobs have all been uploaded. The config blob holds the package manifest
+// the registry indexes.
+func (s *Server) putManifestHandler(w http.ResponseWriter, r *http.Request) {
+	name, reference := pathVar(r, "name"), pathVar(r, "reference")
+	if !validName(name) || !validVersion(reference) {
+		s.sendError(w, http.StatusBadRequest, "Invalid package name or version")
+		return
+	}
+	if !s.canPush(w, r, KindPackage, name) {
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxManifestSize))
+	if err != nil {
+		s.sendError(w, http.StatusRequestEntityTooLarge, "Manifest is too large")
+		return
+	}
+	m, err := blob.ParseManifest(data)
+	if err != nil {
+		s.sendError(w, http.StatusBadRequest, fmt.Sprintf("Invalid manifest: %v", err))
+		return
+	}
+
+	var missing []string
+	for _, desc := range m.Blobs() {
+		if _, err := s.config.Store.StatBlob(desc.Digest); err != nil {
+			missing = append(missing, desc.Digest.String())
+		}
+	}
+	if len(missing) > 0 {
+		s.sendJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"error":   "Manifest references blobs that have not been uploaded",
+			"missing": missing,
+		})
+		return
+	}
+
+	config, err := s.readConfig(m)
+	if err != nil {
+		s.sendError(w, http.StatusBadRequest, fmt.Sprintf("Invalid package config: %v", err))
+		return
+	}
+	if config.Name != name {
+		s.sendError(w, http.StatusBadRequest, fmt.Sprintf("Package config is for %q, not %q", config.Name, name))
+		return
+	}
+
+	digest := blob.FromBytes(data)
+	now := time.Now().UTC()
+	artifact := &Artifact{
+		Kind:         KindPackage,
+		Name:         name,
+		Version:      reference,
+		MediaType:    blob.MediaTypeManifest,
+		Type:         config.Type,
+		Description:  config.Description,
+		Author:       config.Author,
+		Publisher:    requestUser(r),
+		Filename:     fmt.Sprintf("%s-%s.sentinel-pkg", strings.ReplaceAll(name, "/", "-"), reference),
+		Size:         m.Size(),
+		Digest:       digest.String(),
+		Labels:       config.Labels,
+		Dependencies: config.Dependencies,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if existing, err := s.config.Store.Get(KindPackage, name, reference); err == nil {
+		artifact.CreatedAt = existing.CreatedAt
+	}
+
+	if err := s.config.Store.Put(artifact, bytes.NewReader(data)); err != nil {
+		s.sendStoreError(w, err)
+		return
+	}
+
+	s.log.Printf("Published package %s:%s (%s, %d blobs) by %s", name, reference, digest, len(m.Blobs()), artifact.Publisher)
+	w.Header().Set("X-Content-Digest", digest.String())
+	s.sendJSON(w, http.StatusCreated, newPackageInfo(artifact))
+}
+
+// getManifestHandler returns the manifest of a package version. Packages
+// uploaded as archives have no manifest.
+func (s *Server) getManifestHandler(w http.ResponseWriter, r *http.Request) {
+	artifact, ok := s.lookup(w, KindPackage, pathVar(r, "name"), pathVar(r, "reference"))
+	if !ok {
+		return
+	}
+	if artifact.MediaType != blob.MediaTypeManifest {
+		s.sendError(w, http.StatusNotFound, "Package was uploaded as an archive and has no manifest")
+		return
+	}
+
+	content, err := s.config.Store.Open(KindPackage, artifact.Name, artifact.Version)
+	if err != nil {
+		s.sendStoreError(w, err)
+		return
+	}
+	defer content.Close()
+
+	if err := s.config.Store.RecordDownload(KindPackage, artifact.Name, artifact.Version); err != nil {
+		s.log.Printf("Error recording download of %s:%s: %v", artifact.Name, artifact.Version, err)
+	}
+
+	w.Header().Set("Content-Type", blob.MediaTypeManifest)
+	w.Header().Set("X-Content-Digest", artifact.Digest)
+	w.Header().Set("X-Package-Version", artifact.Version)
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := io.Copy(w, content); err != nil {
+		s.log.Printf("Error sending manifest of %s:%s: %v", artifact.Name, artifact.Version, err)
+	}
+}
+
+// readConfig loads the package manifest held by a manifest's config blob
+func (s *Server) readConfig(m *blob.Manifest) (*packageManifest, error) {
+	content, err := s.config.Store.OpenBlob(m.Config.Digest)
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+
+	data, err := io.ReadAll(io.LimitReader(content, maxManifestSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	return parseManifest(data)
+}
+
+// writeManifestArchive sends a package pushed as blobs to clients of the
+// archive download API, assembling the archive from its blobs
+func (s *Server) writeManifestArchive(w http.ResponseWriter, artifact *Artifact, content io.Reader) {
+	data, err := io.ReadAll(io.LimitReader(content, maxManifestSize))
+	if err != nil {
+		s.sendStoreError(w, err)
+		return
+	}
+	m, err := blob.ParseManifest(data)
+	if err != nil {
+		s.sendStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", artifact.Filename))
+	w.Header().Set("X-Content-Digest", artifact.Digest)
+	w.Header().Set("X-Package-Version", artifact.Version)
+	w.WriteHeader(http.StatusOK)
+
+	if err := blob.WriteArchive(w, blobSource{store: s.config.Store}, m); err != nil {
+		s.log.Printf("Error sending %s:%s: %v", artifact.Name, artifact.Version, err)
+	}
+}
@@ -0,0 +1,262 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/registry/blob"
+)
+
+const (
+	// recordFile holds an artifact's record within its version directory
+	recordFile = "artifact.json"
+
+	// contentFile holds an artifact's content within its version directory
+	contentFile = "content"
+)
+
+// DiskStore keeps artifacts in a directory tree:
+// <root>/<kind>/<name>/<version>/{artifact.json,content}, and blobs in a
+// blob.Store under <root>/blobs
+type DiskStore struct {
+	root  string
+	blobs *blob.Store
+	mu    sync.RWMutex
+}
+
+// NewDiskStore creates a store rooted at a directory
+func NewDiskStore(root string) (*DiskStore, error) {
+	for _, kind := range []Kind{KindPackage, KindStack} {
+		if err := os.MkdirAll(filepath.Join(root, string(kind)), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create store directory: %w", err)
+		}
+	}
+
+	blobs, err := blob.NewStore(filepath.Join(root, "blobs"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &DiskStore{root: root, blobs: blobs}, nil
+}
+
+// nameDir returns the directory of an artifact. Names may contain slashes,
+// which are escaped to keep one directory level per name.
+func (s *DiskStore) nameDir(kind Kind, name string) string {
+	return filepath.Join(s.root, string(kind), url.PathEscape(name))
+}
+
+// versionDir returns the directory of an artifact version
+func (s *DiskStore) versionDir(kind Kind, name, version string) string {
+	return filepath.Join(s.nameDir(kind, name), version)
+}
+
+// Put stores an artifact and its content. The record is written last so a
+// version only becomes visible once its content is complete.
+func (s *DiskStore) Put(artifact *Artifact, content io.Reader) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dir := s.versionDir(artifact.Kind, artifact.Name, artifact.Version)
+	if _, err := os.Stat(filepath.Join(dir, recordFile)); err == nil && artifact.Version != LatestVersion {
+		return ErrExists
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create artifact directory: %w", err)
+	}
+
+	temp, err := os.CreateTemp(dir, ".content-*")
+	if err != nil {
+		return fmt.Errorf("failed to create content file: %w", err)
+	}
+	defer os.Remove(temp.Name())
+
+	if _, err := io.Copy(temp, content); err != nil {
+		temp.Close()
+		return fmt.Errorf("failed to write content: %w", err)
+	}
+	if err := temp.Close(); err != nil {
+		return fmt.Errorf("failed to write content: %w", err)
+	}
+	if err := os.Rename(temp.Name(), filepath.Join(dir, contentFile)); err != nil {
+		return fmt.Errorf("failed to store content: %w", err)
+	}
+
+	return s.writeRecord(dir, artifact)
+}
+
+// Get returns the record of an exact artifact version
+func (s *DiskStore) Get(kind Kind, name, version string) (*Artifact, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.readRecord(s.versionDir(kind, name, version))
+}
+
+// Open returns the content of an exact artifact version
+func (s *DiskStore) Open(kind Kind, name, version string) (io.ReadCloser, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	file, err := os.Open(filepath.Join(s.versionDir(kind, name, version), contentFile))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open content: %w", err)
+	}
+
+	return file, nil
+}
+
+// Versions returns the versions of an artifact, oldest first
+func (s *DiskStore) Versions(kind Kind, name string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.versions(s.nameDir(kind, name))
+}
+
+// versions lists the complete versions below an artifact directory
+func (s *DiskStore) versions(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read artifact directory: %w", err)
+	}
+
+	var versions []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, entry.Name(), recordFile)); err == nil {
+			versions = append(versions, entry.Name())
+		}
+	}
+	sortVersions(versions)
+
+	return versions, nil
+}
+
+// List returns the records of every version of every artifact of a kind
+func (s *DiskStore) List(kind Kind) ([]*Artifact, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	kindDir := filepath.Join(s.root, string(kind))
+	entries, err := os.ReadDir(kindDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read store directory: %w", err)
+	}
+
+	var artifacts []*Artifact
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		nameDir := filepath.Join(kindDir, entry.Name())
+		versions, err := s.versions(nameDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, version := range versions {
+			artifact, err := s.readRecord(filepath.Join(nameDir, version))
+			if err != nil {
+				return nil, err
+			}
+			artifacts = append(artifacts, artifact)
+		}
+	}
+
+	return artifacts, nil
+}
+
+// RecordDownload counts a download of an artifact version
+func (s *DiskStore) RecordDownload(kind Kind, name, version string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dir := s.versionDir(kind, name, version)
+	artifact, err := s.readRecord(dir)
+	if err != nil {
+		return err
+	}
+	artifact.Downloads++
+
+	return s.writeRecord(dir, artifact)
+}
+
+// StatBlob returns the size of a stored blob
+func (s *DiskStore) StatBlob(d blob.Digest) (int64, error) {
+	size, err := s.blobs.Stat(d)
+	if errors.Is(err, blob.ErrNotFound) {
+		return 0, ErrNotFound
+	}
+	return size, err
+}
+
+// PutBlob stores content under its digest
+func (s *DiskStore) PutBlob(d blob.Digest, content io.Reader) error {
+	_, _, err := s.blobs.Put(content, d)
+	return err
+}
+
+// OpenBlob returns the content of a stored blob
+func (s *DiskStore) OpenBlob(d blob.Digest) (io.ReadCloser, error) {
+	content, err := s.blobs.Open(d)
+	if errors.Is(err, blob.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	return content, err
+}
+
+// Close releases the store's resources
+func (s *DiskStore) Close() error {
+	return nil
+}
+
+// readRecord loads the record in a version directory
+func (s *DiskStore) readRecord(dir string) (*Artifact, error) {
+	data, err := os.ReadFile(filepath.Join(dir, recordFile))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read artifact record: %w", err)
+	}
+
+	var artifact Artifact
+	if err := json.Unmarshal(data, &artifact); err != nil {
+		return nil, fmt.Errorf("failed to parse artifact record: %w", err)
+	}
+
+	return &artifact, nil
+}
+
+// writeRecord atomically replaces the record in a version directory
+func (s *DiskStore) writeRecord(dir string, artifact *Artifact) error {
+	data, err := json.MarshalIndent(artifact, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal artifact record: %w", err)
+	}
+
+	temp := filepath.Join(dir, "."+recordFile)
+	if err := os.WriteFile(temp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write artifact record: %w", err)
+	}
+	if err := os.Rename(temp, filepath.Join(dir, recordFile)); err != nil {
+		return fmt.Errorf("failed to write artifact record: %w", err)
+	}
+
+	return nil
+}
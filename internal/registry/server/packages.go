@@ -0,0 +1,384 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/registry/blob"
+	"github.com/satishgonella2024/sentinelstacks/pkg/types"
+)
+
+const (
+	// defaultSearchLimit is the number of search results without a limit
+	defaultSearchLimit = 20
+
+	// maxMemoryUpload is how much of an upload is buffered in memory before
+	// spilling to a temporary file
+	maxMemoryUpload = 32 << 20
+)
+
+// packageInfo is the package description of the /api/v1 package API
+type packageInfo struct {
+	Name        string                 `json:"name"`
+	Version     string                 `json:"version"`
+	Type        types.PackageType      `json:"type,omitempty"`
+	Description string                 `json:"description"`
+	Author      string                 `json:"author"`
+	License     string                 `json:"license"`
+	CreatedAt   time.Time              `json:"createdAt"`
+	UpdatedAt   time.Time              `json:"updatedAt"`
+	Size        int64                  `json:"size"`
+	Digest      string                 `json:"digest"`
+	Downloads   int                    `json:"downloads"`
+	Metadata    map[string]interface{} `json:"metadata"`
+	Tags        []string               `json:"tags"`
+}
+
+// packageSummary is a search result of the /v1 package API
+type packageSummary struct {
+	Name         string             `json:"name"`
+	Type         types.PackageType  `json:"type"`
+	Version      string             `json:"version"`
+	Description  string             `json:"description"`
+	Author       string             `json:"author"`
+	CreatedAt    time.Time          `json:"createdAt"`
+	Downloads    int                `json:"downloads"`
+	Labels       map[string]string  `json:"labels,omitempty"`
+	Dependencies []types.Dependency `json:"dependencies,omitempty"`
+	Verified     bool               `json:"verified"`
+}
+
+// newPackageInfo describes an artifact for the /api/v1 package API. Labels
+// are reported as key=value tags, like a package's own summary.
+func newPackageInfo(artifact *Artifact) packageInfo {
+	tags := make([]string, 0, len(artifact.Labels))
+	for key, value := range artifact.Labels {
+		tags = append(tags, fmt.Sprintf("%s=%s", key, value))
+	}
+	sort.Strings(tags)
+
+	metadata := artifact.Metadata
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+
+	return packageInfo{
+		Name:        artifact.Name,
+		Version:     artifact.Version,
+		Type:        artifact.Type,
+		Description: artifact.Description,
+		Author:      artifact.Author,
+		License:     artifact.License,
+		CreatedAt:   artifact.CreatedAt,
+		UpdatedAt:   artifact.UpdatedAt,
+		Size:        artifact.Size,
+		Digest:      artifact.Digest,
+		Downloads:   artifact.Downloads,
+		Metadata:    metadata,
+		Tags:        tags,
+	}
+}
+
+// uploadPackageHandler stores a package archive sent as the "package" field
+// of a multipart form. Name and version come from the archive's manifest.
+func (s *Server) uploadPackageHandler(w http.ResponseWriter, r *http.Request) {
+	file, header, ok := s.readUpload(w, r, "package")
+	if !ok {
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+	defer file.Close()
+
+	manifest, err := readManifest(file)
+	if err != nil {
+		s.sendError(w, http.StatusBadRequest, fmt.Sprintf("Invalid package: %v", err))
+		return
+	}
+	if !validName(manifest.Name) || !validVersion(manifest.Version) {
+		s.sendError(w, http.StatusBadRequest, "Invalid package name or version")
+		return
+	}
+	if !s.canPush(w, r, KindPackage, manifest.Name) {
+		return
+	}
+
+	digest, size, err := digestFile(file)
+	if err != nil {
+		s.sendError(w, http.StatusInternalServerError, "Failed to read package")
+		return
+	}
+
+	description := manifest.Description
+	if description == "" {
+		description = r.FormValue("description")
+	}
+
+	var metadata map[string]interface{}
+	if raw := r.FormValue("metadata"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &metadata); err != nil {
+			s.sendError(w, http.StatusBadRequest, "Invalid metadata: must be a JSON object")
+			return
+		}
+	}
+
+	filename := header.Filename
+	if filename == "" {
+		filename = fmt.Sprintf("%s-%s.sentinel-pkg", strings.ReplaceAll(manifest.Name, "/", "-"), manifest.Version)
+	}
+
+	now := time.Now().UTC()
+	artifact := &Artifact{
+		Kind:         KindPackage,
+		Name:         manifest.Name,
+		Version:      manifest.Version,
+		Type:         manifest.Type,
+		Description:  description,
+		Author:       manifest.Author,
+		Publisher:    requestUser(r),
+		Filename:     filename,
+		Size:         size,
+		Digest:       digest,
+		Metadata:     metadata,
+		Labels:       manifest.Labels,
+		Dependencies: manifest.Dependencies,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if existing, err := s.config.Store.Get(KindPackage, artifact.Name, artifact.Version); err == nil {
+		artifact.CreatedAt = existing.CreatedAt
+	}
+
+	if err := s.config.Store.Put(artifact, file); err != nil {
+		s.sendStoreError(w, err)
+		return
+	}
+
+	s.log.Printf("Published package %s:%s (%s) by %s", artifact.Name, artifact.Version, artifact.Digest, artifact.Publisher)
+	s.sendJSON(w, http.StatusCreated, newPackageInfo(artifact))
+}
+
+// downloadPackageHandler returns the archive of a package version. Packages
+// pushed as blobs are assembled into an archive.
+func (s *Server) downloadPackageHandler(w http.ResponseWriter, r *http.Request) {
+	artifact, ok := s.lookup(w, KindPackage, pathVar(r, "name"), pathVar(r, "version"))
+	if !ok {
+		return
+	}
+
+	content, err := s.config.Store.Open(KindPackage, artifact.Name, artifact.Version)
+	if err != nil {
+		s.sendStoreError(w, err)
+		return
+	}
+	defer content.Close()
+
+	if err := s.config.Store.RecordDownload(KindPackage, artifact.Name, artifact.Version); err != nil {
+		s.log.Printf("Error recording download of %s:%s: %v", artifact.Name, artifact.Version, err)
+	}
+
+	if artifact.MediaType == blob.MediaTypeManifest {
+		s.writeManifestArchive(w, artifact, content)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.FormatInt(artifact.Size, 10))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", artifact.Filename))
+	w.Header().Set("X-Content-Digest", artifact.Digest)
+	w.Header().Set("X-Package-Version", artifact.Version)
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := io.Copy(w, content); err != nil {
+		s.log.Printf("Error sending %s:%s: %v", artifact.Name, artifact.Version, err)
+	}
+}
+
+// packageInfoHandler describes a package version
+func (s *Server) packageInfoHandler(w http.ResponseWriter, r *http.Request) {
+	artifact, ok := s.lookup(w, KindPackage, pathVar(r, "name"), pathVar(r, "version"))
+	if !ok {
+		return
+	}
+
+	s.sendJSON(w, http.StatusOK, newPackageInfo(artifact))
+}
+
+// packageTagsHandler lists the versions of a package as {"tags": [...]}
+func (s *Server) packageTagsHandler(w http.ResponseWriter, r *http.Request) {
+	versions, ok := s.versions(w, KindPackage, pathVar(r, "name"))
+	if !ok {
+		return
+	}
+
+	s.sendJSON(w, http.StatusOK, map[string][]string{"tags": versions})
+}
+
+// packageVersionsHandler lists the versions of a package as a JSON array
+func (s *Server) packageVersionsHandler(w http.ResponseWriter, r *http.Request) {
+	versions, ok := s.versions(w, KindPackage, pathVar(r, "name"))
+	if !ok {
+		return
+	}
+
+	s.sendJSON(w, http.StatusOK, versions)
+}
+
+// searchPackagesHandler searches packages for the /api/v1 package API
+func (s *Server) searchPackagesHandler(w http.ResponseWriter, r *http.Request) {
+	groups, total, err := search(s.config.Store, KindPackage, r.URL.Query().Get("q"), "", searchLimit(r))
+	if err != nil {
+		s.sendStoreError(w, err)
+		return
+	}
+
+	results := make([]packageInfo, 0, len(groups))
+	for _, group := range groups {
+		info := newPackageInfo(group.latest)
+		info.Downloads = group.downloads
+		results = append(results, info)
+	}
+
+	s.sendJSON(w, http.StatusOK, map[string]interface{}{
+		"results": results,
+		"total":   total,
+	})
+}
+
+// packageSummariesHandler searches packages for the /v1 package API
+func (s *Server) packageSummariesHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	groups, total, err := search(s.config.Store, KindPackage, query.Get("q"), types.PackageType(query.Get("type")), searchLimit(r))
+	if err != nil {
+		s.sendStoreError(w, err)
+		return
+	}
+
+	items := make([]packageSummary, 0, len(groups))
+	for _, group := range groups {
+		latest := group.latest
+		items = append(items, packageSummary{
+			Name:         latest.Name,
+			Type:         latest.Type,
+			Version:      latest.Version,
+			Description:  latest.Description,
+			Author:       latest.Author,
+			CreatedAt:    latest.CreatedAt,
+			Downloads:    group.downloads,
+			Labels:       latest.Labels,
+			Dependencies: latest.Dependencies,
+		})
+	}
+
+	s.sendJSON(w, http.StatusOK, map[string]interface{}{
+		"totalCount": total,
+		"items":      items,
+	})
+}
+
+// lookup resolves and loads an artifact version, writing an error response
+// if it does not exist
+func (s *Server) lookup(w http.ResponseWriter, kind Kind, name, version string) (*Artifact, bool) {
+	if !validName(name) || (version != "" && !validVersion(version)) {
+		s.sendError(w, http.StatusBadRequest, "Invalid name or version")
+		return nil, false
+	}
+
+	resolved, err := resolveVersion(s.config.Store, kind, name, version)
+	if err != nil {
+		s.sendStoreError(w, err)
+		return nil, false
+	}
+
+	artifact, err := s.config.Store.Get(kind, name, resolved)
+	if err != nil {
+		s.sendStoreError(w, err)
+		return nil, false
+	}
+
+	return artifact, true
+}
+
+// versions lists the versions of an artifact, writing an error response if
+// it does not exist
+func (s *Server) versions(w http.ResponseWriter, kind Kind, name string) ([]string, bool) {
+	if !validName(name) {
+		s.sendError(w, http.StatusBadRequest, "Invalid name")
+		return nil, false
+	}
+
+	versions, err := s.config.Store.Versions(kind, name)
+	if err != nil {
+		s.sendStoreError(w, err)
+		return nil, false
+	}
+	if len(versions) == 0 {
+		s.sendStoreError(w, ErrNotFound)
+		return nil, false
+	}
+
+	return versions, true
+}
+
+// readUpload parses a multipart push request and returns one of its files,
+// writing an error response on failure. Callers remove the form's temporary
+// files once done.
+func (s *Server) readUpload(w http.ResponseWriter, r *http.Request, field string) (multipart.File, *multipart.FileHeader, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.config.MaxUploadSize)
+	if err := r.ParseMultipartForm(maxMemoryUpload); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			s.sendError(w, http.StatusRequestEntityTooLarge,
+				fmt.Sprintf("Upload exceeds the limit of %d bytes", s.config.MaxUploadSize))
+			return nil, nil, false
+		}
+		s.sendError(w, http.StatusBadRequest, "Invalid multipart form")
+		return nil, nil, false
+	}
+
+	file, header, err := r.FormFile(field)
+	if err != nil {
+		s.sendError(w, http.StatusBadRequest, fmt.Sprintf("Missing %q file", field))
+		return nil, nil, false
+	}
+
+	return file, header, true
+}
+
+// digestFile returns the sha256 digest and size of an uploaded file and
+// rewinds it
+func digestFile(file multipart.File) (string, int64, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", 0, err
+	}
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", 0, err
+	}
+
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), size, nil
+}
+
+// searchLimit reads the limit query parameter of a search
+func searchLimit(r *http.Request) int {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		return defaultSearchLimit
+	}
+	return limit
+}
@@ -0,0 +1,296 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/satishgonella2024/sentinelstacks/internal/registry/auth"
+	"github.com/satishgonella2024/sentinelstacks/internal/registry/security"
+)
+
+// DefaultMaxUploadSize is the largest package or stack accepted by default
+const DefaultMaxUploadSize = 100 << 20
+
+// contextKey is used for request context values
+type contextKey string
+
+// userContextKey holds the authenticated username of a request
+const userContextKey contextKey = "user"
+
+// Config contains registry server configuration
+type Config struct {
+	// Store persists the registry's packages and stacks
+	Store Store
+	// Users authenticates logins; without users nobody can publish
+	Users *auth.UserStore
+	// Tokens issues and verifies bearer tokens
+	Tokens *auth.TokenIssuer
+	// Private requires a token for reads as well as writes
+	Private bool
+	// Admins may push to names published by other users
+	Admins []string
+	// MaxUploadSize bounds the size of a push request in bytes
+	MaxUploadSize int64
+	// Logger receives request and publish logs
+	Logger *log.Logger
+	// Issuer holds the key that certifies keyless signing keys for
+	// authenticated users; without it keyless signing is not offered
+	Issuer *security.KeyManager
+	// IssuerKeyID names the issuer key
+	IssuerKeyID string
+	// CertificateTTL bounds the validity of issued certificates
+	CertificateTTL time.Duration
+}
+
+// Server is an HTTP registry for packages and stacks
+type Server struct {
+	router *mux.Router
+	config Config
+	log    *log.Logger
+}
+
+// NewServer creates a registry server
+func NewServer(config Config) (*Server, error) {
+	if config.Store == nil {
+		return nil, fmt.Errorf("registry store is required")
+	}
+	if config.Tokens == nil {
+		return nil, fmt.Errorf("token issuer is required")
+	}
+	if config.MaxUploadSize <= 0 {
+		config.MaxUploadSize = DefaultMaxUploadSize
+	}
+	if config.Issuer != nil && config.IssuerKeyID == "" {
+		return nil, fmt.Errorf("issuer key ID is required")
+	}
+
+	logger := config.Logger
+	if logger == nil {
+		logger = log.New(os.Stdout, "[REGISTRY] ", log.LstdFlags)
+	}
+
+	s := &Server{
+		// Names are path-escaped by clients, so match on the encoded path
+		router: mux.NewRouter().UseEncodedPath(),
+		config: config,
+		log:    logger,
+	}
+	s.setupRoutes()
+
+	return s, nil
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.router.ServeHTTP(w, r)
+}
+
+// setupRoutes configures the registry routes. Fixed paths are registered
+// before the {name}/{version} routes they would otherwise match.
+func (s *Server) setupRoutes() {
+	s.router.Use(s.loggingMiddleware)
+
+	s.router.HandleFunc("/v1/health", s.healthHandler).Methods("GET")
+
+	// Login, under each prefix the clients use
+	for _, prefix := range []string{"", "/v1", "/api/v1"} {
+		s.router.HandleFunc(prefix+"/auth/login", s.loginHandler).Methods("POST")
+	}
+
+	// Package API of client.Client
+	s.router.HandleFunc("/api/v1/packages/upload", s.write(s.uploadPackageHandler)).Methods("POST")
+	s.router.HandleFunc("/api/v1/packages/search", s.read(s.searchPackagesHandler)).Methods("GET")
+	s.router.HandleFunc("/api/v1/packages/{name}/tags", s.read(s.packageTagsHandler)).Methods("GET")
+	s.router.HandleFunc("/api/v1/packages/{name}/{version}/download", s.read(s.downloadPackageHandler)).Methods("GET")
+	s.router.HandleFunc("/api/v1/packages/{name}/{version}", s.read(s.packageInfoHandler)).Methods("GET")
+
+	// Package API of client.RegistryClient
+	s.router.HandleFunc("/v1/packages/publish", s.write(s.uploadPackageHandler)).Methods("POST")
+	s.router.HandleFunc("/v1/packages/search", s.read(s.packageSummariesHandler)).Methods("GET")
+	s.router.HandleFunc("/v1/packages/{name}/versions", s.read(s.packageVersionsHandler)).Methods("GET")
+	s.router.HandleFunc("/v1/packages/{name}/{version}", s.read(s.downloadPackageHandler)).Methods("GET")
+
+	// Content-addressed blob API of blob.Client
+	s.router.HandleFunc("/v1/blobs/{digest}", s.read(s.getBlobHandler)).Methods("GET", "HEAD")
+	s.router.HandleFunc("/v1/blobs/{digest}", s.write(s.putBlobHandler)).Methods("PUT")
+	s.router.HandleFunc("/v1/manifests/{name}/{reference}", s.read(s.getManifestHandler)).Methods("GET")
+	s.router.HandleFunc("/v1/manifests/{name}/{reference}", s.write(s.putManifestHandler)).Methods("PUT")
+
+	// Keyless signing certificates of security.RemoteIssuer
+	s.router.HandleFunc("/v1/signing/issuer", s.issuerHandler).Methods("GET")
+	s.router.HandleFunc("/v1/signing/certificate", s.write(s.certificateHandler)).Methods("POST")
+
+	// Stack API of stack.RegistryClient
+	s.router.HandleFunc("/v1/stacks/search", s.read(s.searchStacksHandler)).Methods("GET")
+	s.router.HandleFunc("/v1/stacks/{name}/tags", s.read(s.stackTagsHandler)).Methods("GET")
+	s.router.HandleFunc("/v1/stacks/{name}/tags/{tag}", s.write(s.pushStackHandler)).Methods("PUT")
+	s.router.HandleFunc("/v1/stacks/{name}/tags/{tag}", s.read(s.pullStackHandler)).Methods("GET")
+}
+
+// loggingMiddleware logs each request
+func (s *Server) loggingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.log.Printf("%s %s %s", r.RemoteAddr, r.Method, r.URL.EscapedPath())
+		next.ServeHTTP(w, r)
+	})
+}
+
+// write wraps a handler that changes the registry, which always requires
+// a valid token
+func (s *Server) write(next http.HandlerFunc) http.HandlerFunc {
+	return s.authenticate(next)
+}
+
+// read wraps a handler that only reads the registry, which requires a
+// token on private registries
+func (s *Server) read(next http.HandlerFunc) http.HandlerFunc {
+	if !s.config.Private {
+		return next
+	}
+	return s.authenticate(next)
+}
+
+// authenticate verifies the bearer token of a request and stores its user
+// in the request context
+func (s *Server) authenticate(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="sentinel-registry"`)
+			s.sendError(w, http.StatusUnauthorized, "Authentication required")
+			return
+		}
+
+		username, err := s.config.Tokens.Verify(token)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="sentinel-registry", error="invalid_token"`)
+			s.sendError(w, http.StatusUnauthorized, "Invalid or expired token")
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), userContextKey, username)))
+	}
+}
+
+// requestUser returns the authenticated user of a request, if any
+func requestUser(r *http.Request) string {
+	username, _ := r.Context().Value(userContextKey).(string)
+	return username
+}
+
+// canPush reports whether the user of a request may publish under name:
+// names belong to the user who first published them, and admins may push
+// to any name. Otherwise it writes a 403 response.
+func (s *Server) canPush(w http.ResponseWriter, r *http.Request, kind Kind, name string) bool {
+	user := requestUser(r)
+	for _, admin := range s.config.Admins {
+		if admin == user {
+			return true
+		}
+	}
+
+	versions, err := s.config.Store.Versions(kind, name)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		s.sendStoreError(w, err)
+		return false
+	}
+
+	var owner *Artifact
+	for _, version := range versions {
+		artifact, err := s.config.Store.Get(kind, name, version)
+		if err != nil {
+			s.sendStoreError(w, err)
+			return false
+		}
+		if artifact.Publisher != "" && (owner == nil || artifact.CreatedAt.Before(owner.CreatedAt)) {
+			owner = artifact
+		}
+	}
+	if owner != nil && owner.Publisher != user {
+		s.log.Printf("Refused push of %s by %s: published by %s", name, user, owner.Publisher)
+		s.sendError(w, http.StatusForbidden, fmt.Sprintf("%s is published by another user", name))
+		return false
+	}
+	return true
+}
+
+// healthHandler reports that the registry is up
+func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) {
+	s.sendJSON(w, http.StatusOK, map[string]interface{}{
+		"status":  "ok",
+		"private": s.config.Private,
+	})
+}
+
+// loginHandler exchanges a username and password for a bearer token
+func (s *Server) loginHandler(w http.ResponseWriter, r *http.Request) {
+	var req auth.LoginRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if s.config.Users == nil || !s.config.Users.Authenticate(req.Username, req.Password) {
+		s.log.Printf("Failed login for user %q from %s", req.Username, r.RemoteAddr)
+		s.sendError(w, http.StatusUnauthorized, "Invalid username or password")
+		return
+	}
+
+	token, expiresAt, err := s.config.Tokens.Issue(req.Username)
+	if err != nil {
+		s.log.Printf("Error issuing token: %v", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to issue token")
+		return
+	}
+
+	s.sendJSON(w, http.StatusOK, auth.LoginResponse{
+		Token:     token,
+		Expires:   expiresAt,
+		ExpiresIn: int64(time.Until(expiresAt).Seconds()),
+	})
+}
+
+// pathVar returns an unescaped route variable
+func pathVar(r *http.Request, key string) string {
+	value := mux.Vars(r)[key]
+	if unescaped, err := url.PathUnescape(value); err == nil {
+		return unescaped
+	}
+	return value
+}
+
+// sendStoreError maps a store error to a response
+func (s *Server) sendStoreError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		s.sendError(w, http.StatusNotFound, "Not found")
+	case errors.Is(err, ErrExists):
+		s.sendError(w, http.StatusConflict, "Version already exists; versions are immutable except for latest")
+	default:
+		s.log.Printf("Store error: %v", err)
+		s.sendError(w, http.StatusInternalServerError, "Internal server error")
+	}
+}
+
+// sendJSON writes a JSON response
+func (s *Server) sendJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		s.log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// sendError writes a JSON error response
+func (s *Server) sendError(w http.ResponseWriter, status int, message string) {
+	s.sendJSON(w, status, map[string]string{"error": message})
+}
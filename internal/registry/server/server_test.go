@@ -0,0 +1,521 @@
+package server
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/registry/auth"
+	"github.com/satishgonella2024/sentinelstacks/internal/registry/blob"
+	"github.com/satishgonella2024/sentinelstacks/internal/registry/security"
+)
+
+// newTestServer starts a registry with one user, alice/secret
+func newTestServer(t *testing.T, store Store, private bool) *httptest.Server {
+	t.Helper()
+
+	users, err := auth.LoadUserStore(filepath.Join(t.TempDir(), "users.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Add("alice", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	issuer, err := security.NewKeyManager(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := issuer.GenerateKey("registry-issuer", security.KeyTypeEd25519); err != nil {
+		t.Fatal(err)
+	}
+
+	srv, err := NewServer(Config{
+		Store:       store,
+		Users:       users,
+		Tokens:      auth.NewTokenIssuer(bytes.Repeat([]byte("k"), 32), 0),
+		Private:     private,
+		Logger:      log.New(io.Discard, "", 0),
+		Issuer:      issuer,
+		IssuerKeyID: "registry-issuer",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+	t.Cleanup(func() { store.Close() })
+	return ts
+}
+
+// login returns a token for alice
+func login(t *testing.T, baseURL string) string {
+	t.Helper()
+
+	resp, err := http.Post(baseURL+"/v1/auth/login", "application/json",
+		strings.NewReader(`{"username": "alice", "password": "secret"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("login status = %d", resp.StatusCode)
+	}
+
+	var result auth.LoginResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Token == "" || result.ExpiresIn <= 0 {
+		t.Fatalf("unexpected login response %+v", result)
+	}
+	return result.Token
+}
+
+// packageArchive builds a package archive holding only a manifest
+func packageArchive(t *testing.T, name, version string) []byte {
+	return buildArchive(t, blob.ArchiveManifestName, name, version)
+}
+
+// buildArchive builds a gzipped tar holding a manifest under entry
+func buildArchive(t *testing.T, entry, name, version string) []byte {
+	t.Helper()
+
+	manifest, _ := json.Marshal(map[string]interface{}{
+		"name":        name,
+		"version":     version,
+		"type":        "agent",
+		"description": "Summarizes " + name,
+		"labels":      map[string]string{"team": "docs"},
+	})
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	tw.WriteHeader(&tar.Header{Name: entry, Mode: 0644, Size: int64(len(manifest))})
+	tw.Write(manifest)
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+// send performs a request with an optional token and multipart body
+func send(t *testing.T, method, url, token string, fields map[string]string, files map[string][]byte) *http.Response {
+	t.Helper()
+
+	var body io.Reader
+	contentType := ""
+	if fields != nil || files != nil {
+		var buf bytes.Buffer
+		writer := multipart.NewWriter(&buf)
+		for key, value := range fields {
+			writer.WriteField(key, value)
+		}
+		for key, data := range files {
+			part, _ := writer.CreateFormFile(key, key+".bin")
+			part.Write(data)
+		}
+		writer.Close()
+		body = &buf
+		contentType = writer.FormDataContentType()
+	}
+
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func expectStatus(t *testing.T, resp *http.Response, want int) {
+	t.Helper()
+	if resp.StatusCode != want {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("%s %s: status = %d, want %d: %s", resp.Request.Method, resp.Request.URL.Path, resp.StatusCode, want, body)
+	}
+}
+
+func TestServerPackagesAndStacks(t *testing.T) {
+	stores := map[string]func(t *testing.T) Store{
+		"disk": func(t *testing.T) Store {
+			store, err := NewDiskStore(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			return store
+		},
+		"sqlite": func(t *testing.T) Store {
+			store, err := NewSQLiteStore(filepath.Join(t.TempDir(), "registry.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return store
+		},
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			ts := newTestServer(t, newStore(t), false)
+			token := login(t, ts.URL)
+
+			v1 := packageArchive(t, "summarizer", "1.2.0")
+			v2 := packageArchive(t, "summarizer", "1.10.0")
+
+			// Writes require a token; versions are immutable
+			expectStatus(t, send(t, "POST", ts.URL+"/api/v1/packages/upload", "", nil, map[string][]byte{"package": v1}), http.StatusUnauthorized)
+			expectStatus(t, send(t, "POST", ts.URL+"/api/v1/packages/upload", token, nil, map[string][]byte{"package": v1}), http.StatusCreated)
+			expectStatus(t, send(t, "POST", ts.URL+"/v1/packages/publish", token, nil, map[string][]byte{"package": v2}), http.StatusCreated)
+			expectStatus(t, send(t, "POST", ts.URL+"/v1/packages/publish", token, nil, map[string][]byte{"package": v1}), http.StatusConflict)
+			expectStatus(t, send(t, "POST", ts.URL+"/v1/packages/publish", token, nil, map[string][]byte{"package": []byte("not a package")}), http.StatusBadRequest)
+
+			// Packages written by PackageBuilder carry a magic header
+			built := append([]byte("SNTL-AGENT-PKG1.0\x00"), buildArchive(t, blob.BuilderManifestName, "translator", "0.1.0")...)
+			expectStatus(t, send(t, "POST", ts.URL+"/v1/packages/publish", token, nil, map[string][]byte{"package": built}), http.StatusCreated)
+
+			// Latest resolves to the highest version
+			resp := send(t, "GET", ts.URL+"/api/v1/packages/summarizer/latest/download", "", nil, nil)
+			expectStatus(t, resp, http.StatusOK)
+			data, _ := io.ReadAll(resp.Body)
+			if !bytes.Equal(data, v2) {
+				t.Errorf("latest download did not return version 1.10.0")
+			}
+			if !strings.Contains(resp.Header.Get("Content-Disposition"), "attachment; filename=") {
+				t.Errorf("missing content disposition: %q", resp.Header.Get("Content-Disposition"))
+			}
+
+			resp = send(t, "GET", ts.URL+"/v1/packages/summarizer/1.2.0?type=package", "", nil, nil)
+			expectStatus(t, resp, http.StatusOK)
+			data, _ = io.ReadAll(resp.Body)
+			if !bytes.Equal(data, v1) {
+				t.Errorf("versioned download did not return version 1.2.0")
+			}
+
+			var versions []string
+			resp = send(t, "GET", ts.URL+"/v1/packages/summarizer/versions", "", nil, nil)
+			expectStatus(t, resp, http.StatusOK)
+			json.NewDecoder(resp.Body).Decode(&versions)
+			if strings.Join(versions, ",") != "1.2.0,1.10.0" {
+				t.Errorf("versions = %v", versions)
+			}
+
+			var results struct {
+				Results []packageInfo `json:"results"`
+				Total   int           `json:"total"`
+			}
+			resp = send(t, "GET", ts.URL+"/api/v1/packages/search?q=SUMMARIZER&limit=5", "", nil, nil)
+			expectStatus(t, resp, http.StatusOK)
+			json.NewDecoder(resp.Body).Decode(&results)
+			if results.Total != 1 || results.Results[0].Version != "1.10.0" || results.Results[0].Downloads != 2 {
+				t.Errorf("unexpected search results %+v", results)
+			}
+
+			expectStatus(t, send(t, "GET", ts.URL+"/api/v1/packages/missing/tags", "", nil, nil), http.StatusNotFound)
+
+			// Stacks
+			spec := `{"name": "pipeline", "description": "Two step pipeline", "agents": [{"id": "a"}, {"id": "b"}]}`
+			stackfile := []byte("name: pipeline\n")
+			expectStatus(t, send(t, "PUT", ts.URL+"/v1/stacks/pipeline/tags/v1.0.0", token,
+				map[string]string{"metadata": spec}, map[string][]byte{"stackfile": stackfile}), http.StatusCreated)
+			expectStatus(t, send(t, "PUT", ts.URL+"/v1/stacks/other/tags/v1.0.0", token,
+				map[string]string{"metadata": spec}, map[string][]byte{"stackfile": stackfile}), http.StatusBadRequest)
+
+			resp = send(t, "GET", ts.URL+"/v1/stacks/pipeline/tags/latest", "", nil, nil)
+			expectStatus(t, resp, http.StatusOK)
+			_, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			parts := map[string]string{}
+			reader := multipart.NewReader(resp.Body, params["boundary"])
+			for {
+				part, err := reader.NextPart()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				data, _ := io.ReadAll(part)
+				parts[part.FormName()] = string(data)
+			}
+			var pulled stackMetadata
+			if err := json.Unmarshal([]byte(parts["metadata"]), &pulled); err != nil || pulled.Name != "pipeline" || len(pulled.Agents) != 2 {
+				t.Errorf("unexpected stack metadata %q", parts["metadata"])
+			}
+			if parts["stackfile"] != string(stackfile) {
+				t.Errorf("stack file = %q", parts["stackfile"])
+			}
+
+			var stacks struct {
+				TotalCount int         `json:"totalCount"`
+				Items      []stackInfo `json:"items"`
+			}
+			resp = send(t, "GET", ts.URL+"/v1/stacks/search?q=pipeline", "", nil, nil)
+			expectStatus(t, resp, http.StatusOK)
+			json.NewDecoder(resp.Body).Decode(&stacks)
+			if stacks.TotalCount != 1 || stacks.Items[0].AgentCount != 2 || stacks.Items[0].Publisher != "alice" {
+				t.Errorf("unexpected stack search %+v", stacks)
+			}
+		})
+	}
+}
+
+func TestServerPrivateAndLogin(t *testing.T) {
+	store, err := NewDiskStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := newTestServer(t, store, true)
+
+	resp, err := http.Post(ts.URL+"/auth/login", "application/json",
+		strings.NewReader(`{"username": "alice", "password": "wrong"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("bad password: status = %d", resp.StatusCode)
+	}
+
+	expectStatus(t, send(t, "GET", ts.URL+"/v1/stacks/search", "", nil, nil), http.StatusUnauthorized)
+	expectStatus(t, send(t, "GET", ts.URL+"/v1/stacks/search", "not-a-token", nil, nil), http.StatusUnauthorized)
+	expectStatus(t, send(t, "GET", ts.URL+"/v1/stacks/search", login(t, ts.URL), nil, nil), http.StatusOK)
+}
+
+func TestServerPushOwnership(t *testing.T) {
+	store, err := NewDiskStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens := auth.NewTokenIssuer(bytes.Repeat([]byte("k"), 32), 0)
+	srv, err := NewServer(Config{
+		Store:  store,
+		Tokens: tokens,
+		Admins: []string{"root"},
+		Logger: log.New(io.Discard, "", 0),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	defer store.Close()
+
+	token := func(user string) string {
+		token, _, err := tokens.Issue(user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	alice, bob, root := token("alice"), token("bob"), token("root")
+
+	pushPackage := func(token, version string) *http.Response {
+		return send(t, "POST", ts.URL+"/v1/packages/publish", token, nil, map[string][]byte{"package": packageArchive(t, "summarizer", version)})
+	}
+	pushStack := func(token, tag string) *http.Response {
+		return send(t, "PUT", ts.URL+"/v1/stacks/pipeline/tags/"+tag, token,
+			map[string]string{"metadata": `{"name": "pipeline"}`}, map[string][]byte{"stackfile": []byte("name: pipeline\n")})
+	}
+
+	// Names belong to their first publisher
+	expectStatus(t, pushPackage(alice, "1.0.0"), http.StatusCreated)
+	expectStatus(t, pushPackage(bob, "1.1.0"), http.StatusForbidden)
+	expectStatus(t, pushPackage(bob, LatestVersion), http.StatusForbidden)
+	expectStatus(t, send(t, "PUT", ts.URL+"/v1/manifests/summarizer/1.1.0", bob, nil, nil), http.StatusForbidden)
+	expectStatus(t, pushPackage(alice, "1.1.0"), http.StatusCreated)
+
+	expectStatus(t, pushStack(alice, "v1"), http.StatusCreated)
+	expectStatus(t, pushStack(bob, "v2"), http.StatusForbidden)
+
+	// Admins may push to any name without taking it over
+	expectStatus(t, pushPackage(root, "1.2.0"), http.StatusCreated)
+	expectStatus(t, pushStack(root, "v2"), http.StatusCreated)
+	expectStatus(t, pushPackage(alice, "1.3.0"), http.StatusCreated)
+	expectStatus(t, pushStack(alice, "v3"), http.StatusCreated)
+
+	// Other names are free
+	expectStatus(t, send(t, "POST", ts.URL+"/v1/packages/publish", bob, nil, map[string][]byte{"package": packageArchive(t, "translator", "0.1.0")}), http.StatusCreated)
+}
+
+func TestCompareVersions(t *testing.T) {
+	versions := []string{"latest", "v1.10.0", "1.2.0", "v1.2.0-beta", "0.9", "v1.2.1"}
+	sortVersions(versions)
+
+	want := "0.9,v1.2.0-beta,1.2.0,v1.2.1,v1.10.0,latest"
+	if got := strings.Join(versions, ","); got != want {
+		t.Errorf("sorted versions = %s, want %s", got, want)
+	}
+}
+
+func TestServerIssuesSigningCertificates(t *testing.T) {
+	store, err := NewDiskStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := newTestServer(t, store, false)
+	ctx := context.Background()
+
+	anonymous := security.NewRemoteIssuer(ts.URL, "")
+	if _, err := security.SignKeyless(ctx, []byte("manifest"), anonymous); err == nil {
+		t.Fatal("certificate issued without a token")
+	}
+
+	issuer := security.NewRemoteIssuer(ts.URL, login(t, ts.URL))
+	signature, err := security.SignKeyless(ctx, []byte("manifest"), issuer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signature.Certificate.Identity != "alice" {
+		t.Fatalf("certificate identity = %q, want alice", signature.Certificate.Identity)
+	}
+
+	// Clients verify with the issuer key the registry publishes
+	info, err := issuer.Issuer(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	km, err := security.NewKeyManager(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := km.ImportPublicKeyData(info.KeyID, []byte(info.PublicKey)); err != nil {
+		t.Fatal(err)
+	}
+	if err := km.Verify(signature, []byte("manifest")); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+}
+
+func TestServerBlobDeduplication(t *testing.T) {
+	for name, open := range map[string]func(dir string) (Store, error){
+		"disk":   func(dir string) (Store, error) { return NewDiskStore(dir) },
+		"sqlite": func(dir string) (Store, error) { return NewSQLiteStore(filepath.Join(dir, "registry.db")) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			store, err := open(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer store.Close()
+
+			ts := newTestServer(t, store, false)
+			client := blob.NewClient(ts.URL, login(t, ts.URL))
+			ctx := context.Background()
+
+			local, err := blob.NewStore(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			dir := t.TempDir()
+			dataset := bytes.Repeat([]byte("example,row\n"), 1000)
+			writeFile(t, dir, "data.csv", dataset)
+
+			push := func(version, prompt string) *blob.TransferStats {
+				writeFile(t, dir, "prompt.txt", []byte(prompt))
+				config, _ := json.Marshal(map[string]string{"name": "summarizer", "version": version, "type": "agent"})
+				_, digest, err := blob.Pack(local, config, []blob.File{
+					{Path: "prompt.txt", SourcePath: filepath.Join(dir, "prompt.txt"), IsMain: true},
+					{Path: "data/data.csv", SourcePath: filepath.Join(dir, "data.csv")},
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				stats, err := client.Push(ctx, local, "summarizer", version, digest)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return stats
+			}
+
+			if stats := push("1.0.0", "Summarize v1"); stats.Blobs != 3 || stats.Transferred != 3 {
+				t.Fatalf("first push stats = %+v", stats)
+			}
+
+			// Only the new config and prompt are uploaded; the dataset is shared
+			stats := push("1.1.0", "Summarize v2")
+			if stats.Transferred != 2 || stats.SkippedBytes != int64(len(dataset)) {
+				t.Fatalf("second push stats = %+v", stats)
+			}
+
+			pulled, err := blob.NewStore(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			m, _, stats, err := client.Pull(ctx, pulled, "summarizer", "latest")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stats.Transferred != 3 {
+				t.Fatalf("pull stats = %+v", stats)
+			}
+
+			out := t.TempDir()
+			if err := blob.Unpack(pulled, m, out); err != nil {
+				t.Fatal(err)
+			}
+			if prompt, _ := os.ReadFile(filepath.Join(out, "prompt.txt")); string(prompt) != "Summarize v2" {
+				t.Fatalf("pulled prompt = %q", prompt)
+			}
+
+			// Pulling an older version only fetches what differs
+			if _, _, stats, err = client.Pull(ctx, pulled, "summarizer", "1.0.0"); err != nil || stats.Transferred != 2 {
+				t.Fatalf("second pull stats = %+v, err = %v", stats, err)
+			}
+
+			// Archive clients get an archive assembled from the blobs
+			resp := send(t, "GET", ts.URL+"/api/v1/packages/summarizer/1.1.0/download", "", nil, nil)
+			expectStatus(t, resp, http.StatusOK)
+			manifest, err := readManifest(resp.Body)
+			resp.Body.Close()
+			if err != nil || manifest.Version != "1.1.0" {
+				t.Fatalf("archive manifest = %+v, err = %v", manifest, err)
+			}
+
+			// Manifests must only reference uploaded blobs
+			missing := []byte(`{"schemaVersion":2,"mediaType":"` + blob.MediaTypeManifest + `","config":{"mediaType":"` +
+				blob.MediaTypeConfig + `","digest":"` + blob.FromBytes([]byte("missing")).String() + `","size":7},"layers":[]}`)
+			req, _ := http.NewRequest("PUT", ts.URL+"/v1/manifests/summarizer/2.0.0", bytes.NewReader(missing))
+			req.Header.Set("Authorization", "Bearer "+client.Token)
+			resp, err = http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expectStatus(t, resp, http.StatusBadRequest)
+			resp.Body.Close()
+		})
+	}
+}
+
+// writeFile writes a file in a directory
+func writeFile(t *testing.T, dir, name string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"net/http"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/registry/security"
+)
+
+// issuerHandler returns the public key of the certificate issuer, which
+// clients add to their keys directory to verify keyless signatures
+func (s *Server) issuerHandler(w http.ResponseWriter, r *http.Request) {
+	if s.config.Issuer == nil {
+		s.sendError(w, http.StatusNotFound, "Keyless signing is not enabled on this registry")
+		return
+	}
+
+	info, err := security.NewIssuerInfo(s.config.Issuer, s.config.IssuerKeyID)
+	if err != nil {
+		s.log.Printf("Error loading issuer key: %v", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to load issuer key")
+		return
+	}
+
+	s.sendJSON(w, http.StatusOK, info)
+}
+
+// certificateHandler certifies an ephemeral public key for the
+// authenticated user
+func (s *Server) certificateHandler(w http.ResponseWriter, r *http.Request) {
+	if s.config.Issuer == nil {
+		s.sendError(w, http.StatusNotFound, "Keyless signing is not enabled on this registry")
+		return
+	}
+
+	var req security.CertificateRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if len(req.PublicKey) != ed25519.PublicKeySize {
+		s.sendError(w, http.StatusBadRequest, "Public key must be an Ed25519 key")
+		return
+	}
+
+	cert, err := s.config.Issuer.IssueCertificate(s.config.IssuerKeyID, requestUser(r), ed25519.PublicKey(req.PublicKey), s.config.CertificateTTL)
+	if err != nil {
+		s.log.Printf("Error issuing certificate: %v", err)
+		s.sendError(w, http.StatusInternalServerError, "Failed to issue certificate")
+		return
+	}
+
+	s.log.Printf("Issued signing certificate for %s valid until %s", cert.Identity, cert.NotAfter.Format("15:04:05"))
+	s.sendJSON(w, http.StatusCreated, cert)
+}
@@ -0,0 +1,274 @@
+package server
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/satishgonella2024/sentinelstacks/internal/registry/blob"
+)
+
+// SQLiteStore keeps artifact records and content in a single SQLite file
+type SQLiteStore struct {
+	db   *sql.DB
+	path string
+}
+
+// NewSQLiteStore creates a store backed by the SQLite database at path
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	// Create directory if it doesn't exist
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SQLite database: %w", err)
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS artifacts (
+			kind TEXT NOT NULL,
+			name TEXT NOT NULL,
+			version TEXT NOT NULL,
+			record TEXT NOT NULL,
+			content BLOB NOT NULL,
+			downloads INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (kind, name, version)
+		);
+
+		CREATE TABLE IF NOT EXISTS blobs (
+			digest TEXT PRIMARY KEY,
+			content BLOB NOT NULL
+		);
+	`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create tables: %w", err)
+	}
+
+	return &SQLiteStore{
+		db:   db,
+		path: path,
+	}, nil
+}
+
+// Put stores an artifact and its content, replacing an existing latest tag
+func (s *SQLiteStore) Put(artifact *Artifact, content io.Reader) error {
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return fmt.Errorf("failed to read content: %w", err)
+	}
+
+	record, err := json.Marshal(artifact)
+	if err != nil {
+		return fmt.Errorf("failed to marshal artifact record: %w", err)
+	}
+
+	statement := `INSERT INTO artifacts (kind, name, version, record, content) VALUES (?, ?, ?, ?, ?);`
+	if artifact.Version == LatestVersion {
+		statement = `INSERT INTO artifacts (kind, name, version, record, content) VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT(kind, name, version) DO UPDATE SET
+				record = excluded.record,
+				content = excluded.content;`
+	}
+
+	_, err = s.db.Exec(statement, string(artifact.Kind), artifact.Name, artifact.Version, string(record), data)
+	if err != nil {
+		if exists, _ := s.exists(artifact.Kind, artifact.Name, artifact.Version); exists {
+			return ErrExists
+		}
+		return fmt.Errorf("failed to insert artifact: %w", err)
+	}
+
+	return nil
+}
+
+// exists reports whether an artifact version is stored
+func (s *SQLiteStore) exists(kind Kind, name, version string) (bool, error) {
+	var count int
+	err := s.db.QueryRow(
+		`SELECT COUNT(*) FROM artifacts WHERE kind = ? AND name = ? AND version = ?;`,
+		string(kind), name, version,
+	).Scan(&count)
+	return count > 0, err
+}
+
+// Get returns the record of an exact artifact version
+func (s *SQLiteStore) Get(kind Kind, name, version string) (*Artifact, error) {
+	var record string
+	var downloads int
+	err := s.db.QueryRow(
+		`SELECT record, downloads FROM artifacts WHERE kind = ? AND name = ? AND version = ?;`,
+		string(kind), name, version,
+	).Scan(&record, &downloads)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query artifact: %w", err)
+	}
+
+	return decodeRecord(record, downloads)
+}
+
+// Open returns the content of an exact artifact version
+func (s *SQLiteStore) Open(kind Kind, name, version string) (io.ReadCloser, error) {
+	var content []byte
+	err := s.db.QueryRow(
+		`SELECT content FROM artifacts WHERE kind = ? AND name = ? AND version = ?;`,
+		string(kind), name, version,
+	).Scan(&content)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query content: %w", err)
+	}
+
+	return io.NopCloser(bytes.NewReader(content)), nil
+}
+
+// Versions returns the versions of an artifact, oldest first
+func (s *SQLiteStore) Versions(kind Kind, name string) ([]string, error) {
+	rows, err := s.db.Query(
+		`SELECT version FROM artifacts WHERE kind = ? AND name = ?;`,
+		string(kind), name,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query versions: %w", err)
+	}
+	defer rows.Close()
+
+	var versions []string
+	for rows.Next() {
+		var version string
+		if err := rows.Scan(&version); err != nil {
+			return nil, fmt.Errorf("failed to scan version: %w", err)
+		}
+		versions = append(versions, version)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query versions: %w", err)
+	}
+	sortVersions(versions)
+
+	return versions, nil
+}
+
+// List returns the records of every version of every artifact of a kind
+func (s *SQLiteStore) List(kind Kind) ([]*Artifact, error) {
+	rows, err := s.db.Query(
+		`SELECT record, downloads FROM artifacts WHERE kind = ? ORDER BY name;`,
+		string(kind),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query artifacts: %w", err)
+	}
+	defer rows.Close()
+
+	var artifacts []*Artifact
+	for rows.Next() {
+		var record string
+		var downloads int
+		if err := rows.Scan(&record, &downloads); err != nil {
+			return nil, fmt.Errorf("failed to scan artifact: %w", err)
+		}
+		artifact, err := decodeRecord(record, downloads)
+		if err != nil {
+			return nil, err
+		}
+		artifacts = append(artifacts, artifact)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query artifacts: %w", err)
+	}
+
+	return artifacts, nil
+}
+
+// RecordDownload counts a download of an artifact version
+func (s *SQLiteStore) RecordDownload(kind Kind, name, version string) error {
+	result, err := s.db.Exec(
+		`UPDATE artifacts SET downloads = downloads + 1 WHERE kind = ? AND name = ? AND version = ?;`,
+		string(kind), name, version,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record download: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// StatBlob returns the size of a stored blob
+func (s *SQLiteStore) StatBlob(d blob.Digest) (int64, error) {
+	var size int64
+	err := s.db.QueryRow(`SELECT length(content) FROM blobs WHERE digest = ?;`, string(d)).Scan(&size)
+	if err == sql.ErrNoRows {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to query blob: %w", err)
+	}
+
+	return size, nil
+}
+
+// PutBlob stores content under its digest
+func (s *SQLiteStore) PutBlob(d blob.Digest, content io.Reader) error {
+	if err := d.Validate(); err != nil {
+		return err
+	}
+
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return fmt.Errorf("failed to read blob: %w", err)
+	}
+	if actual := blob.FromBytes(data); actual != d {
+		return fmt.Errorf("%w: expected %s, got %s", blob.ErrDigestMismatch, d, actual)
+	}
+
+	if _, err := s.db.Exec(`INSERT OR IGNORE INTO blobs (digest, content) VALUES (?, ?);`, string(d), data); err != nil {
+		return fmt.Errorf("failed to insert blob: %w", err)
+	}
+
+	return nil
+}
+
+// OpenBlob returns the content of a stored blob
+func (s *SQLiteStore) OpenBlob(d blob.Digest) (io.ReadCloser, error) {
+	var content []byte
+	err := s.db.QueryRow(`SELECT content FROM blobs WHERE digest = ?;`, string(d)).Scan(&content)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query blob: %w", err)
+	}
+
+	return io.NopCloser(bytes.NewReader(content)), nil
+}
+
+// Close closes the database
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// decodeRecord parses a stored record and applies its download count
+func decodeRecord(record string, downloads int) (*Artifact, error) {
+	var artifact Artifact
+	if err := json.Unmarshal([]byte(record), &artifact); err != nil {
+		return nil, fmt.Errorf("failed to parse artifact record: %w", err)
+	}
+	artifact.Downloads = downloads
+
+	return &artifact, nil
+}
@@ -0,0 +1,197 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"path/filepath"
+	"time"
+
+	"github.com/satishgonella2024/sentinelstacks/pkg/types"
+)
+
+// stackMetadata holds the stack specification fields the registry indexes
+type stackMetadata struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Version     string            `json:"version"`
+	Agents      []json.RawMessage `json:"agents"`
+}
+
+// stackInfo is a search result of the stack API
+type stackInfo struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Version     string    `json:"version"`
+	Tags        []string  `json:"tags"`
+	Publisher   string    `json:"publisher"`
+	CreatedAt   time.Time `json:"createdAt"`
+	Downloads   int       `json:"downloads"`
+	AgentCount  int       `json:"agentCount"`
+	Size        int64     `json:"size"`
+}
+
+// pushStackHandler stores a stack under a tag. The multipart request holds
+// the stack specification as the "metadata" field and the stack file as
+// the "stackfile" file.
+func (s *Server) pushStackHandler(w http.ResponseWriter, r *http.Request) {
+	name, tag := pathVar(r, "name"), pathVar(r, "tag")
+	if !validName(name) || !validVersion(tag) {
+		s.sendError(w, http.StatusBadRequest, "Invalid stack name or tag")
+		return
+	}
+	if !s.canPush(w, r, KindStack, name) {
+		return
+	}
+
+	file, header, ok := s.readUpload(w, r, "stackfile")
+	if !ok {
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+	defer file.Close()
+
+	raw := r.FormValue("metadata")
+	var spec stackMetadata
+	if err := json.Unmarshal([]byte(raw), &spec); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid stack metadata")
+		return
+	}
+	if spec.Name != "" && spec.Name != name {
+		s.sendError(w, http.StatusBadRequest, fmt.Sprintf("Stack metadata is for %q, not %q", spec.Name, name))
+		return
+	}
+
+	digest, size, err := digestFile(file)
+	if err != nil {
+		s.sendError(w, http.StatusInternalServerError, "Failed to read stack file")
+		return
+	}
+
+	filename := filepath.Base(header.Filename)
+	if filename == "" || filename == "." || filename == "/" {
+		filename = "Stackfile.yaml"
+	}
+
+	now := time.Now().UTC()
+	artifact := &Artifact{
+		Kind:        KindStack,
+		Name:        name,
+		Version:     tag,
+		Type:        types.PackageTypeStack,
+		Description: spec.Description,
+		Publisher:   requestUser(r),
+		Filename:    filename,
+		Size:        size,
+		Digest:      digest,
+		Spec:        json.RawMessage(raw),
+		AgentCount:  len(spec.Agents),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if existing, err := s.config.Store.Get(KindStack, name, tag); err == nil {
+		artifact.CreatedAt = existing.CreatedAt
+	}
+
+	if err := s.config.Store.Put(artifact, file); err != nil {
+		s.sendStoreError(w, err)
+		return
+	}
+
+	s.log.Printf("Published stack %s:%s (%s) by %s", name, tag, digest, artifact.Publisher)
+	s.sendJSON(w, http.StatusCreated, map[string]interface{}{
+		"name":   name,
+		"tag":    tag,
+		"digest": digest,
+		"size":   size,
+	})
+}
+
+// pullStackHandler returns a tagged stack as a multipart response with the
+// specification as the "metadata" part and the stack file as "stackfile"
+func (s *Server) pullStackHandler(w http.ResponseWriter, r *http.Request) {
+	artifact, ok := s.lookup(w, KindStack, pathVar(r, "name"), pathVar(r, "tag"))
+	if !ok {
+		return
+	}
+
+	content, err := s.config.Store.Open(KindStack, artifact.Name, artifact.Version)
+	if err != nil {
+		s.sendStoreError(w, err)
+		return
+	}
+	defer content.Close()
+
+	if err := s.config.Store.RecordDownload(KindStack, artifact.Name, artifact.Version); err != nil {
+		s.log.Printf("Error recording download of %s:%s: %v", artifact.Name, artifact.Version, err)
+	}
+
+	writer := multipart.NewWriter(w)
+	w.Header().Set("Content-Type", writer.FormDataContentType())
+	w.Header().Set("X-Content-Digest", artifact.Digest)
+	w.Header().Set("X-Stack-Tag", artifact.Version)
+	w.WriteHeader(http.StatusOK)
+
+	metadataHeader := textproto.MIMEHeader{}
+	metadataHeader.Set("Content-Disposition", `form-data; name="metadata"`)
+	metadataHeader.Set("Content-Type", "application/json")
+	part, err := writer.CreatePart(metadataHeader)
+	if err == nil {
+		_, err = part.Write(artifact.Spec)
+	}
+	if err == nil {
+		part, err = writer.CreateFormFile("stackfile", artifact.Filename)
+	}
+	if err == nil {
+		_, err = io.Copy(part, content)
+	}
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		s.log.Printf("Error sending stack %s:%s: %v", artifact.Name, artifact.Version, err)
+	}
+}
+
+// stackTagsHandler lists the tags of a stack as a JSON array
+func (s *Server) stackTagsHandler(w http.ResponseWriter, r *http.Request) {
+	tags, ok := s.versions(w, KindStack, pathVar(r, "name"))
+	if !ok {
+		return
+	}
+
+	s.sendJSON(w, http.StatusOK, tags)
+}
+
+// searchStacksHandler searches stacks, returning the newest tag of each
+func (s *Server) searchStacksHandler(w http.ResponseWriter, r *http.Request) {
+	groups, total, err := search(s.config.Store, KindStack, r.URL.Query().Get("q"), "", searchLimit(r))
+	if err != nil {
+		s.sendStoreError(w, err)
+		return
+	}
+
+	items := make([]stackInfo, 0, len(groups))
+	for _, group := range groups {
+		latest := group.latest
+		items = append(items, stackInfo{
+			Name:        latest.Name,
+			Description: latest.Description,
+			Version:     latest.Version,
+			Tags:        group.versions,
+			Publisher:   latest.Publisher,
+			CreatedAt:   latest.CreatedAt,
+			Downloads:   group.downloads,
+			AgentCount:  latest.AgentCount,
+			Size:        latest.Size,
+		})
+	}
+
+	s.sendJSON(w, http.StatusOK, map[string]interface{}{
+		"totalCount": total,
+		"items":      items,
+	})
+}
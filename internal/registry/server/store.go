@@ -0,0 +1,293 @@
+// Package server implements a self-hosted registry for agent packages and
+// stacks, serving the protocols of the registry client packages
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/registry/blob"
+	"github.com/satishgonella2024/sentinelstacks/pkg/types"
+)
+
+// Kind separates the namespaces of a registry
+type Kind string
+
+const (
+	// KindPackage is a packaged agent or stack archive
+	KindPackage Kind = "package"
+
+	// KindStack is a stack definition pushed through the stack API
+	KindStack Kind = "stack"
+)
+
+// LatestVersion is the version that resolves to the newest one
+const LatestVersion = "latest"
+
+var (
+	// ErrNotFound is returned for artifacts that do not exist
+	ErrNotFound = errors.New("artifact not found")
+
+	// ErrExists is returned when pushing a version that already exists.
+	// Only the latest tag may be overwritten.
+	ErrExists = errors.New("artifact version already exists")
+)
+
+// Artifact describes a stored package or stack version
+type Artifact struct {
+	Kind         Kind                   `json:"kind"`
+	Name         string                 `json:"name"`
+	Version      string                 `json:"version"`
+	Type         types.PackageType      `json:"type,omitempty"`
+	Description  string                 `json:"description,omitempty"`
+	Author       string                 `json:"author,omitempty"`
+	License      string                 `json:"license,omitempty"`
+	Publisher    string                 `json:"publisher,omitempty"`
+	Filename     string                 `json:"filename"`
+	Size         int64                  `json:"size"`
+	Digest       string                 `json:"digest"`
+	Metadata     map[string]interface{} `json:"metadata,omitempty"`
+	Labels       map[string]string      `json:"labels,omitempty"`
+	Dependencies []types.Dependency     `json:"dependencies,omitempty"`
+	// MediaType is blob.MediaTypeManifest for packages pushed as blobs,
+	// whose content is their manifest
+	MediaType string `json:"mediaType,omitempty"`
+	// Spec is the stack specification pushed alongside a stack file
+	Spec       json.RawMessage `json:"spec,omitempty"`
+	AgentCount int             `json:"agentCount,omitempty"`
+	Downloads  int             `json:"downloads"`
+	CreatedAt  time.Time       `json:"createdAt"`
+	UpdatedAt  time.Time       `json:"updatedAt"`
+}
+
+// Store persists registry artifacts and their content
+type Store interface {
+	// Put stores an artifact and its content, replacing an existing latest tag
+	Put(artifact *Artifact, content io.Reader) error
+
+	// Get returns the record of an exact artifact version
+	Get(kind Kind, name, version string) (*Artifact, error)
+
+	// Open returns the content of an exact artifact version
+	Open(kind Kind, name, version string) (io.ReadCloser, error)
+
+	// Versions returns the versions of an artifact, oldest first
+	Versions(kind Kind, name string) ([]string, error)
+
+	// List returns the records of every version of every artifact of a kind
+	List(kind Kind) ([]*Artifact, error)
+
+	// RecordDownload counts a download of an artifact version
+	RecordDownload(kind Kind, name, version string) error
+
+	// StatBlob returns the size of a stored blob
+	StatBlob(d blob.Digest) (int64, error)
+
+	// PutBlob stores content under its digest, rejecting content that does
+	// not match it with blob.ErrDigestMismatch
+	PutBlob(d blob.Digest, content io.Reader) error
+
+	// OpenBlob returns the content of a stored blob
+	OpenBlob(d blob.Digest) (io.ReadCloser, error)
+
+	// Close releases the store's resources
+	Close() error
+}
+
+var (
+	namePattern    = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*(/[a-zA-Z0-9][a-zA-Z0-9._-]*)*$`)
+	versionPattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._+-]*$`)
+)
+
+// validName reports whether a name is safe to store
+func validName(name string) bool {
+	return len(name) <= 128 && namePattern.MatchString(name) && !strings.Contains(name, "..")
+}
+
+// validVersion reports whether a version is safe to store
+func validVersion(version string) bool {
+	return len(version) <= 64 && versionPattern.MatchString(version) && !strings.Contains(version, "..")
+}
+
+// sortVersions orders versions oldest first
+func sortVersions(versions []string) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		return compareVersions(versions[i], versions[j]) < 0
+	})
+}
+
+// compareVersions orders semantic versions, with or without a leading v.
+// Pre-releases sort before their release and the latest tag sorts last;
+// anything unparseable falls back to string order.
+func compareVersions(a, b string) int {
+	if a == b {
+		return 0
+	}
+	if a == LatestVersion {
+		return 1
+	}
+	if b == LatestVersion {
+		return -1
+	}
+
+	aCore, aPre, _ := strings.Cut(strings.TrimPrefix(a, "v"), "-")
+	bCore, bPre, _ := strings.Cut(strings.TrimPrefix(b, "v"), "-")
+	aCore, _, _ = strings.Cut(aCore, "+")
+	bCore, _, _ = strings.Cut(bCore, "+")
+
+	aParts := strings.Split(aCore, ".")
+	bParts := strings.Split(bCore, ".")
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		var aPart, bPart string
+		if i < len(aParts) {
+			aPart = aParts[i]
+		}
+		if i < len(bParts) {
+			bPart = bParts[i]
+		}
+		if c := comparePart(aPart, bPart); c != 0 {
+			return c
+		}
+	}
+
+	switch {
+	case aPre == bPre:
+		return strings.Compare(a, b)
+	case aPre == "":
+		return 1
+	case bPre == "":
+		return -1
+	}
+	return strings.Compare(aPre, bPre)
+}
+
+// comparePart compares version components numerically when both are numbers
+func comparePart(a, b string) int {
+	aNum, aErr := strconv.Atoi(orZero(a))
+	bNum, bErr := strconv.Atoi(orZero(b))
+	if aErr != nil || bErr != nil {
+		return strings.Compare(a, b)
+	}
+	switch {
+	case aNum < bNum:
+		return -1
+	case aNum > bNum:
+		return 1
+	}
+	return 0
+}
+
+func orZero(s string) string {
+	if s == "" {
+		return "0"
+	}
+	return s
+}
+
+// resolveVersion maps a requested version to a stored one. The latest tag
+// resolves to itself if it was pushed, or else to the newest version.
+func resolveVersion(store Store, kind Kind, name, version string) (string, error) {
+	if version == "" {
+		version = LatestVersion
+	}
+
+	versions, err := store.Versions(kind, name)
+	if err != nil {
+		return "", err
+	}
+	if len(versions) == 0 {
+		return "", ErrNotFound
+	}
+
+	if version == LatestVersion {
+		return versions[len(versions)-1], nil
+	}
+	for _, v := range versions {
+		if v == version {
+			return v, nil
+		}
+	}
+
+	return "", ErrNotFound
+}
+
+// artifactGroup is every version of one artifact, for search results
+type artifactGroup struct {
+	latest    *Artifact
+	versions  []string
+	downloads int
+}
+
+// search returns the artifacts of a kind that match a query, one group per
+// name in name order, and the total number of matches. Only the newest
+// version of each artifact is matched against the query.
+func search(store Store, kind Kind, query string, packageType types.PackageType, limit int) ([]artifactGroup, int, error) {
+	artifacts, err := store.List(kind)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	groups := make(map[string]*artifactGroup)
+	for _, artifact := range artifacts {
+		group, exists := groups[artifact.Name]
+		if !exists {
+			group = &artifactGroup{}
+			groups[artifact.Name] = group
+		}
+		group.versions = append(group.versions, artifact.Version)
+		group.downloads += artifact.Downloads
+		if group.latest == nil || compareVersions(artifact.Version, group.latest.Version) > 0 {
+			group.latest = artifact
+		}
+	}
+
+	query = strings.ToLower(strings.TrimSpace(query))
+	var matches []artifactGroup
+	for _, group := range groups {
+		if packageType != "" && group.latest.Type != packageType {
+			continue
+		}
+		if !matchesQuery(group.latest, query) {
+			continue
+		}
+		sortVersions(group.versions)
+		matches = append(matches, *group)
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].latest.Name < matches[j].latest.Name
+	})
+
+	total := len(matches)
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+
+	return matches, total, nil
+}
+
+// matchesQuery reports whether a lowercase query occurs in an artifact's
+// name, description, author or labels
+func matchesQuery(artifact *Artifact, query string) bool {
+	if query == "" {
+		return true
+	}
+
+	fields := []string{artifact.Name, artifact.Description, artifact.Author}
+	for key, value := range artifact.Labels {
+		fields = append(fields, key, value)
+	}
+	for _, field := range fields {
+		if strings.Contains(strings.ToLower(field), query) {
+			return true
+		}
+	}
+
+	return false
+}
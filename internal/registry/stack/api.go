@@ -305,6 +305,42 @@ func (c *RegistryClient) GetStackTags(ctx context.Context, name string) ([]strin
 	return tags, nil
 }
 
+// GetStackDigest returns the content digest of a stack tag, which changes
+// if the tag is ever pushed again
+func (c *RegistryClient) GetStackDigest(ctx context.Context, name string, tag string) (string, error) {
+	// Create request
+	url := fmt.Sprintf("%s/v1/stacks/%s/tags/%s", c.BaseURL, name, tag)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Set headers
+	if c.AuthToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.AuthToken)
+	}
+
+	// Send request; the digest is in the headers, so the body is not read
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Check status code
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := ioutil.ReadAll(resp.Body)
+		return "", fmt.Errorf("failed to get stack digest: %s (status code: %d)", string(bodyBytes), resp.StatusCode)
+	}
+
+	digest := resp.Header.Get("X-Content-Digest")
+	if digest == "" {
+		return "", fmt.Errorf("registry did not report a digest for %s:%s", name, tag)
+	}
+
+	return digest, nil
+}
+
 // extractBoundary extracts the boundary from a multipart content type
 func extractBoundary(contentType string) string {
 	parts := strings.Split(contentType, "boundary=")
@@ -4,47 +4,201 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"os/exec"
+	"sort"
 	"strings"
 
+	"github.com/satishgonella2024/sentinelstacks/internal/registry"
+	"github.com/satishgonella2024/sentinelstacks/internal/registry/client"
+	"github.com/satishgonella2024/sentinelstacks/internal/registry/semver"
 	"github.com/satishgonella2024/sentinelstacks/internal/stack"
 )
 
-// DependencyResolver handles resolving and managing stack dependencies
+// VersionSource lists the published versions of a stack's dependencies
+type VersionSource interface {
+	// Versions lists the published versions of a dependency
+	Versions(ctx context.Context, name string) ([]string, error)
+
+	// Digest returns the content digest a version was published with
+	Digest(ctx context.Context, name, version string) (string, error)
+}
+
+// PackageSource resolves dependencies published as packages, which is how
+// agents are pushed
+type PackageSource struct {
+	Client *client.RegistryClient
+}
+
+// Versions implements VersionSource
+func (s PackageSource) Versions(ctx context.Context, name string) ([]string, error) {
+	return s.Client.GetPackageVersions(ctx, name)
+}
+
+// Digest implements VersionSource
+func (s PackageSource) Digest(ctx context.Context, name, version string) (string, error) {
+	return s.Client.GetPackageDigest(ctx, name, version)
+}
+
+// StackSource resolves dependencies pushed with the stack API
+type StackSource struct {
+	Client *RegistryClient
+}
+
+// Versions implements VersionSource
+func (s StackSource) Versions(ctx context.Context, name string) ([]string, error) {
+	return s.Client.GetStackTags(ctx, name)
+}
+
+// Digest implements VersionSource
+func (s StackSource) Digest(ctx context.Context, name, version string) (string, error) {
+	return s.Client.GetStackDigest(ctx, name, version)
+}
+
+// DependencyResolver resolves the version constraints in a stack's "uses:"
+// references to exact versions
 type DependencyResolver struct {
-	RegistryClient *RegistryClient
-	SentinelPath   string
+	Source VersionSource
 }
 
-// DependencyResult contains the results of dependency resolution
+// DependencyResult contains the results of dependency resolution. Tags of
+// the references are the resolved versions.
 type DependencyResult struct {
 	Required  []AgentReference
 	Missing   []AgentReference
 	Available []AgentReference
+	Lock      *Lockfile
 }
 
 // NewDependencyResolver creates a new dependency resolver
-func NewDependencyResolver(client *RegistryClient, sentinelPath string) *DependencyResolver {
+func NewDependencyResolver(source VersionSource) *DependencyResolver {
 	return &DependencyResolver{
-		RegistryClient: client,
-		SentinelPath:   sentinelPath,
+		Source: source,
 	}
 }
 
-// ResolveStackDependencies identifies all agent dependencies for a stack
-func (r *DependencyResolver) ResolveStackDependencies(spec stack.StackSpec) (*DependencyResult, error) {
+// ResolveStackDependencies resolves the "uses:" reference of every agent in
+// a stack to an exact version and records it in a lockfile. References to
+// the same agent are resolved together, to the newest version satisfying
+// all of them, so a stack never runs two versions of one agent.
+func (r *DependencyResolver) ResolveStackDependencies(ctx context.Context, spec stack.StackSpec) (*DependencyResult, error) {
+	constraints := make(map[string][]string)
+	var names []string
+	for _, agent := range spec.Agents {
+		name, constraint := ParseUses(agent.Uses)
+		if _, ok := constraints[name]; !ok {
+			names = append(names, name)
+		}
+		if !containsString(constraints[name], constraint) {
+			constraints[name] = append(constraints[name], constraint)
+		}
+	}
+	sort.Strings(names)
+
+	// Images built or pulled on this machine are reported as available
+	localRegistry, err := registry.GetLocalRegistry()
+	if err != nil {
+		log.Printf("Warning: failed to open local image registry: %v", err)
+	}
+
 	result := &DependencyResult{
 		Required:  []AgentReference{},
 		Missing:   []AgentReference{},
 		Available: []AgentReference{},
+		Lock:      &Lockfile{Version: LockfileVersion, Stack: spec.Name},
 	}
 
-	// Extract all agent references
-	for _, agent := range spec.Agents {
-		ref := parseAgentReference(agent.Uses)
+	for _, name := range names {
+		versions, err := r.Source.Versions(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list versions of %s: %w", name, err)
+		}
+		version, err := semver.Select(versions, constraints[name]...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %w", name, err)
+		}
+		digest, err := r.Source.Digest(ctx, name, version)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get digest of %s:%s: %w", name, version, err)
+		}
+
+		result.Lock.Agents = append(result.Lock.Agents, LockedAgent{
+			Name:        name,
+			Constraints: constraints[name],
+			Version:     version,
+			Digest:      digest,
+		})
+
+		ref := AgentReference{Name: name, Tag: version}
 		result.Required = append(result.Required, ref)
+		if localRegistry != nil {
+			if _, err := localRegistry.Get(name, version); err == nil {
+				result.Available = append(result.Available, ref)
+				continue
+			}
+		}
+		result.Missing = append(result.Missing, ref)
+	}
 
-		// Check if agent is available locally
-		available, err := r.isAgentAvailable(ref)
+	return result, nil
+}
+
+// VerifyLock checks that every locked version is still published with the
+// digest it was locked with, so a republished version is noticed
+func (r *DependencyResolver) VerifyLock(ctx context.Context, lock *Lockfile) error {
+	var mismatches []string
+	for _, agent := range lock.Agents {
+		if agent.Digest == "" {
+			continue
+		}
+		digest, err := r.Source.Digest(ctx, agent.Name, agent.Version)
 		if err != nil {
-			return nil, fmt.Errorf("failed to check
\ No newline at end of file
+			return fmt.Errorf("failed to get digest of %s:%s: %w", agent.Name, agent.Version, err)
+		}
+		if digest != agent.Digest {
+			mismatches = append(mismatches, fmt.Sprintf("%s:%s is %s, locked %s", agent.Name, agent.Version, digest, agent.Digest))
+		}
+	}
+
+	if len(mismatches) > 0 {
+		return fmt.Errorf("locked versions were republished:\n  %s", strings.Join(mismatches, "\n  "))
+	}
+	return nil
+}
+
+// ParseUses splits a "uses:" reference into the agent name and its version
+// constraint, which defaults to "latest". The constraint follows the first
+// colon after the last slash, so registry hosts with ports are kept in the
+// name.
+func ParseUses(uses string) (string, string) {
+	uses = strings.TrimSpace(uses)
+	start := strings.LastIndex(uses, "/") + 1
+	if i := strings.Index(uses[start:], ":"); i >= 0 {
+		name, constraint := uses[:start+i], strings.TrimSpace(uses[start+i+1:])
+		if constraint == "" {
+			constraint = semver.Latest
+		}
+		return name, constraint
+	}
+	return uses, semver.Latest
+}
+
+// NeedsResolution reports whether any agent of a stack uses a version
+// constraint, such as "^1.2", that must be resolved before it can run
+func NeedsResolution(spec stack.StackSpec) bool {
+	for _, agent := range spec.Agents {
+		_, constraint := ParseUses(agent.Uses)
+		if semver.IsConstraint(constraint) && !semver.IsExact(constraint) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsString reports whether a list holds a string
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
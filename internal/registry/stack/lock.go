@@ -0,0 +1,111 @@
+package stack
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/stack"
+)
+
+// LockfileName is the name of the file, next to a Stackfile, that records
+// the exact versions its agents resolved to
+const LockfileName = "stack.lock"
+
+// LockfileVersion is the current version of the lockfile format
+const LockfileVersion = 1
+
+// ErrLockOutdated is returned when a stack uses an agent or constraint its
+// lockfile does not record
+var ErrLockOutdated = errors.New("stack.lock is out of date")
+
+// lockfileHeader is written above the lockfile contents
+const lockfileHeader = "# Generated by sentinel stack lock. Do not edit; commit it with the Stackfile.\n"
+
+// Lockfile pins the agents of a stack to exact versions and digests
+type Lockfile struct {
+	Version  int           `yaml:"lockfileVersion"`
+	Stack    string        `yaml:"stack,omitempty"`
+	Registry string        `yaml:"registry,omitempty"`
+	Agents   []LockedAgent `yaml:"agents"`
+}
+
+// LockedAgent is the version an agent's constraints resolved to
+type LockedAgent struct {
+	Name        string   `yaml:"name"`
+	Constraints []string `yaml:"constraints"`
+	Version     string   `yaml:"version"`
+	Digest      string   `yaml:"digest,omitempty"`
+}
+
+// LockfilePath returns the lockfile path of a Stackfile
+func LockfilePath(stackFilePath string) string {
+	return filepath.Join(filepath.Dir(stackFilePath), LockfileName)
+}
+
+// ReadLockfile reads a lockfile. A missing file returns an error matching
+// os.ErrNotExist.
+func ReadLockfile(path string) (*Lockfile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lockfile: %w", err)
+	}
+
+	var lock Lockfile
+	if err := yaml.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("failed to parse lockfile %s: %w", path, err)
+	}
+	if lock.Version > LockfileVersion {
+		return nil, fmt.Errorf("lockfile %s has version %d; this sentinel supports up to %d", path, lock.Version, LockfileVersion)
+	}
+
+	return &lock, nil
+}
+
+// Write saves the lockfile
+func (l *Lockfile) Write(path string) error {
+	data, err := yaml.Marshal(l)
+	if err != nil {
+		return fmt.Errorf("failed to marshal lockfile: %w", err)
+	}
+	if err := os.WriteFile(path, append([]byte(lockfileHeader), data...), 0644); err != nil {
+		return fmt.Errorf("failed to write lockfile: %w", err)
+	}
+	return nil
+}
+
+// Find returns the locked version of an agent, or nil
+func (l *Lockfile) Find(name string) *LockedAgent {
+	for i := range l.Agents {
+		if l.Agents[i].Name == name {
+			return &l.Agents[i]
+		}
+	}
+	return nil
+}
+
+// Apply rewrites the "uses:" reference of every agent in a stack to its
+// locked version. It returns ErrLockOutdated, naming the references, when
+// the stack uses an agent or constraint the lockfile was not resolved for.
+func (l *Lockfile) Apply(spec *stack.StackSpec) error {
+	var outdated []string
+	for i := range spec.Agents {
+		agent := &spec.Agents[i]
+		name, constraint := ParseUses(agent.Uses)
+		locked := l.Find(name)
+		if locked == nil || !containsString(locked.Constraints, constraint) {
+			outdated = append(outdated, fmt.Sprintf("%s (agent %s)", agent.Uses, agent.ID))
+			continue
+		}
+		agent.Uses = name + ":" + locked.Version
+	}
+
+	if len(outdated) > 0 {
+		return fmt.Errorf("%w: not locked: %s", ErrLockOutdated, strings.Join(outdated, ", "))
+	}
+	return nil
+}
@@ -3,15 +3,19 @@ package runtime
 import (
 	"context"
 	"fmt"
+	"sync"
 	
 	"github.com/satishgonella2024/sentinelstacks/internal/multimodal"
 	"github.com/satishgonella2024/sentinelstacks/internal/shim"
 	"github.com/satishgonella2024/sentinelstacks/internal/tools"
 	"github.com/satishgonella2024/sentinelstacks/internal/tools/file"
+	"github.com/satishgonella2024/sentinelstacks/internal/tools/mcp"
+	"github.com/satishgonella2024/sentinelstacks/internal/tools/plugin"
+	"github.com/satishgonella2024/sentinelstacks/internal/tools/shell"
 	"github.com/satishgonella2024/sentinelstacks/internal/tools/web"
 )
 
-// init registers all tools
+// init registers the tools that need no config
 func init() {
 	// Register file tools
 	if err := file.RegisterFileTools(); err != nil {
@@ -24,15 +28,43 @@ func init() {
 	}
 }
 
+var configuredToolsOnce sync.Once
+
+// RegisterConfiguredTools registers the tools that depend on the loaded
+// config: the shell tools, whose sandbox uses the "shell" settings, and the
+// tools of the plugins in the plugin directory. It only runs once, when
+// agent tools are first set up.
+func RegisterConfiguredTools() {
+	configuredToolsOnce.Do(func() {
+		if err := shell.RegisterShellTools(); err != nil {
+			fmt.Printf("Warning: Failed to register shell tools: %v\n", err)
+		}
+		
+		if err := plugin.RegisterPluginTools(); err != nil {
+			fmt.Printf("Warning: Failed to register plugin tools: %v\n", err)
+		}
+	})
+}
+
+// ShutdownTools stops the plugins started by RegisterConfiguredTools and
+// disconnects any MCP servers still connected
+func ShutdownTools() {
+	plugin.Shutdown()
+	mcp.GetManager().Close()
+}
+
 // ToolsCoordinator manages tool execution for an agent
 type ToolsCoordinator struct {
 	agentID      string
 	executor     shim.ToolExecutor
 	conversation map[string]interface{}
+	settings     map[string]interface{}
 }
 
 // NewToolsCoordinator creates a new tools coordinator
 func NewToolsCoordinator(agentID string) (*ToolsCoordinator, error) {
+	RegisterConfiguredTools()
+	
 	// Create tool executor
 	executor, err := shim.NewDefaultToolExecutor()
 	if err != nil {
@@ -46,8 +78,88 @@ func NewToolsCoordinator(agentID string) (*ToolsCoordinator, error) {
 	}, nil
 }
 
-// ProcessMultimodalInput processes a multimodal input with tools support
+// SetToolSettings sets the agent's tool settings, keyed by tool name, that
+// tools read while running for the agent
+func (c *ToolsCoordinator) SetToolSettings(settings map[string]interface{}) {
+	c.settings = settings
+}
+
+// ProcessMultimodalInput processes a multimodal input with tools support.
+// Text input is sent with structured tool calls, whose results go back to
+// the model as tool messages until it answers or maxTurns calls were made.
+// Input with images goes through the multimodal API, where tools are
+// described in the prompt.
 func (c *ToolsCoordinator) ProcessMultimodalInput(ctx context.Context, mmAgent *MultimodalAgent, input *multimodal.Input, maxTurns int) (*multimodal.Output, error) {
+	ctx = tools.WithSettings(ctx, c.settings)
+	schemas := c.executor.GetAvailableTools(c.agentID)
+	if len(schemas) == 0 || !isTextOnly(input) {
+		return c.processWithPromptTools(ctx, mmAgent, input, maxTurns)
+	}
+	
+	// Set generation parameters if not already set
+	maxTokens, temperature := input.MaxTokens, input.Temperature
+	if maxTokens <= 0 {
+		maxTokens = mmAgent.MaxTokens
+	}
+	if temperature <= 0 {
+		temperature = mmAgent.Temperature
+	}
+	
+	text := extractTextFromInput(input)
+	var messages []tools.Message
+	if system, ok := input.GetMetadata("system"); ok {
+		if systemPrompt, ok := system.(string); ok && systemPrompt != "" {
+			messages = append(messages, tools.Message{Role: tools.RoleSystem, Content: systemPrompt})
+		}
+	}
+	messages = append(messages, tools.Message{Role: tools.RoleUser, Content: text})
+	mmAgent.History.AddMessage("user", text)
+	
+	for turn := 0; ; turn++ {
+		response, err := mmAgent.LLM.CompletionWithTools(ctx, messages, schemas, maxTokens, temperature)
+		if err != nil {
+			return nil, fmt.Errorf("failed to process input: %w", err)
+		}
+		
+		// No tool calls, so this is the answer
+		if len(response.ToolCalls) == 0 {
+			mmAgent.History.AddMessage("assistant", response.Content)
+			if err := mmAgent.saveConversation(); err != nil {
+				fmt.Printf("Warning: Failed to save conversation: %v\n", err)
+			}
+			
+			output := multimodal.NewOutput()
+			output.AddText(response.Content)
+			return output, nil
+		}
+		
+		if turn >= maxTurns {
+			// No more turns, return warning
+			warningOutput := multimodal.NewOutput()
+			warningOutput.AddText(fmt.Sprintf("Tool call detected (%s), but maximum tool call limit reached. Tool was not executed.", response.ToolCalls[0].Name))
+			return warningOutput, nil
+		}
+		
+		// Execute every call and send the results back, failures included,
+		// so the model can correct itself
+		messages = append(messages, response.AssistantMessage())
+		for i := range response.ToolCalls {
+			call := &response.ToolCalls[i]
+			result := &tools.FunctionResult{Name: call.Name}
+			value, err := c.executor.ExecuteTool(ctx, c.agentID, call.Name, call.Parameters)
+			if err != nil {
+				result.Error = err.Error()
+			} else {
+				result.Result = value
+			}
+			messages = append(messages, tools.NewToolMessage(call, result))
+		}
+	}
+}
+
+// processWithPromptTools describes the tools in the prompt and scrapes a
+// function call from the reply, for input the structured path cannot carry
+func (c *ToolsCoordinator) processWithPromptTools(ctx context.Context, mmAgent *MultimodalAgent, input *multimodal.Input, maxTurns int) (*multimodal.Output, error) {
 	// Create tool-augmented input
 	toolInput := shim.NewToolAugmentedInput(input, c.agentID, c.executor)
 	
@@ -117,5 +229,15 @@ func (c *ToolsCoordinator) ProcessMultimodalInput(ctx context.Context, mmAgent *
 	newToolInput.AddToolResults(functionCall.Name, result)
 	
 	// Recursively process with new input and reduced maxTurns
-	return c.ProcessMultimodalInput(ctx, mmAgent, followupInput, maxTurns-1)
+	return c.processWithPromptTools(ctx, mmAgent, followupInput, maxTurns-1)
+}
+
+// isTextOnly reports whether an input has no content other than text
+func isTextOnly(input *multimodal.Input) bool {
+	for _, content := range input.Contents {
+		if content.Type != multimodal.MediaTypeText {
+			return false
+		}
+	}
+	return true
 }
\ No newline at end of file
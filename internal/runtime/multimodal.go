@@ -11,6 +11,7 @@ import (
 	"github.com/satishgonella2024/sentinelstacks/internal/conversation"
 	"github.com/satishgonella2024/sentinelstacks/internal/multimodal"
 	"github.com/satishgonella2024/sentinelstacks/internal/shim"
+	"github.com/satishgonella2024/sentinelstacks/internal/tools/mcp"
 )
 
 // MultimodalAgent extends the Agent struct with multimodal capabilities
@@ -22,6 +23,7 @@ type MultimodalAgent struct {
 	Temperature     float64
 	ConversationDir string
 	metadata        map[string]interface{}
+	mcpServers      []string
 }
 
 // NewMultimodalAgent creates a new multimodal agent
@@ -143,8 +145,11 @@ func (ma *MultimodalAgent) ProcessMultimodalInput(ctx context.Context, userInput
 		userInput.Temperature = ma.Temperature
 	}
 
+	// Ground the turn in snippets from the agent's memory, if configured
+	promptInput := ma.withRetrievedContext(ctx, userInput, textContent)
+
 	// Generate a response
-	output, err := ma.LLM.MultimodalCompletionWithContext(ctx, userInput)
+	output, err := ma.LLM.MultimodalCompletionWithContext(ctx, promptInput)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate multimodal response: %w", err)
 	}
@@ -302,6 +307,12 @@ func (ma *MultimodalAgent) Close() error {
 		fmt.Printf("Warning: Failed to save conversation during close: %v\n", err)
 	}
 	
+	// Release the MCP servers connected for the agent
+	for _, name := range ma.mcpServers {
+		mcp.GetManager().RemoveServer(name)
+	}
+	ma.mcpServers = nil
+	
 	// Close the LLM
 	if err := ma.LLM.Close(); err != nil {
 		return fmt.Errorf("failed to close LLM: %w", err)
@@ -38,10 +38,6 @@ func TestMultimodalAgent(t *testing.T) {
 	shimConfig := shim.Config{
 		Provider: "mock",
 		Model:    "mock-model",
-		Parameters: map[string]interface{}{
-			"temperature": 0.7,
-			"max_tokens":  1024,
-		},
 	}
 
 	// Create multimodal agent
@@ -56,19 +52,19 @@ func TestMultimodalAgent(t *testing.T) {
 	ctx := context.Background()
 	resp, err := mmAgent.ProcessTextInput(ctx, "Hello, world!")
 	require.NoError(t, err)
-	assert.Contains(t, resp, "Mock")
-	assert.Contains(t, resp, "Hello, world!")
+	// The "mock" provider is shim.MockShim, whose answers do not echo the
+	// input; the input is checked in the conversation history below
+	assert.Equal(t, "This is a mock text response from the multimodal API.", resp)
 
 	// Process multimodal input with image
 	imageData, err := ioutil.ReadFile(imagePath)
 	require.NoError(t, err)
 
-	contents := []*multimodal.Content{
-		multimodal.NewTextContent("What's in this image?"),
-		multimodal.NewImageContent(imageData, "image/jpeg"),
-	}
+	input := multimodal.NewInput()
+	input.AddText("What's in this image?")
+	input.AddImage(imageData, "image/jpeg")
 
-	output, err := mmAgent.ProcessMultimodalInput(ctx, contents)
+	output, err := mmAgent.ProcessMultimodalInput(ctx, input)
 	require.NoError(t, err)
 	assert.NotNil(t, output)
 
@@ -80,13 +76,14 @@ func TestMultimodalAgent(t *testing.T) {
 			break
 		}
 	}
-	assert.Contains(t, responseText, "Mock multimodal response")
+	assert.Equal(t, "This is a mock multimodal response. I can see the image you provided.", responseText)
 
 	// Check conversation history
 	history := mmAgent.GetConversationHistory()
 	assert.NotNil(t, history)
 	assert.GreaterOrEqual(t, len(history.Messages), 3) // System prompt + text input + multimodal input
 	assert.Equal(t, "system", string(history.Messages[0].Role))
+	assert.Equal(t, "Hello, world!", history.Messages[1].Content)
 
 	// Close the agent
 	err = mmAgent.Close()
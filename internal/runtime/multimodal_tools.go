@@ -7,6 +7,7 @@ import (
 
 	"github.com/satishgonella2024/sentinelstacks/internal/multimodal"
 	"github.com/satishgonella2024/sentinelstacks/internal/tools"
+	"github.com/satishgonella2024/sentinelstacks/internal/tools/mcp"
 	"github.com/satishgonella2024/sentinelstacks/pkg/agent"
 )
 
@@ -99,7 +100,7 @@ func (a *MultimodalAgent) GetToolPermissions() ([]tools.Permission, error) {
 // ConfigureToolsFromDefinition configures tools based on agent definition
 func (a *MultimodalAgent) ConfigureToolsFromDefinition(def *agent.Definition) error {
 	// Check if agent has tools
-	if len(def.Tools) == 0 {
+	if len(def.Tools) == 0 && len(def.MCPServers) == 0 {
 		return nil // No tools to configure
 	}
 	
@@ -107,6 +108,9 @@ func (a *MultimodalAgent) ConfigureToolsFromDefinition(def *agent.Definition) er
 	if err := a.AddToolsToAgent(); err != nil {
 		return fmt.Errorf("failed to add tools to agent: %w", err)
 	}
+	if coordinator, ok := a.metadata["tools_coordinator"].(*ToolsCoordinator); ok {
+		coordinator.SetToolSettings(def.ToolSettings)
+	}
 	
 	// Grant permissions based on tools
 	handler, err := tools.NewToolHandler()
@@ -125,8 +129,36 @@ func (a *MultimodalAgent) ConfigureToolsFromDefinition(def *agent.Definition) er
 	// Keep track of permissions that need to be granted
 	permissionsToGrant := make(map[tools.Permission]bool)
 	
+	// Connect MCP servers, whose tools the agent uses
+	if len(def.MCPServers) > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), mcp.ConnectTimeout)
+		defer cancel()
+		
+		for _, server := range def.MCPServers {
+			serverTools, err := mcp.GetManager().AddServer(ctx, server)
+			if err != nil {
+				return fmt.Errorf("failed to connect MCP server %s: %w", server.Name, err)
+			}
+			a.mcpServers = append(a.mcpServers, server.Name)
+			for _, tool := range serverTools {
+				if permission := tool.RequiredPermission(); permission != tools.PermissionNone {
+					permissionsToGrant[permission] = true
+				}
+			}
+		}
+	}
+	
 	// Check each tool
+	registry := tools.GetRegistry()
 	for _, toolName := range def.Tools {
+		// Registered tools, plugins included, declare their permission
+		if tool, err := registry.GetTool(toolName); err == nil {
+			if permission := tool.RequiredPermission(); permission != tools.PermissionNone {
+				permissionsToGrant[permission] = true
+			}
+			continue
+		}
+		
 		// Look for matching prefix
 		for prefix, permission := range permissionMap {
 			if strings.HasPrefix(toolName, prefix) {
@@ -0,0 +1,297 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/memory"
+	"github.com/satishgonella2024/sentinelstacks/internal/multimodal"
+	"github.com/satishgonella2024/sentinelstacks/pkg/embedding"
+	"github.com/satishgonella2024/sentinelstacks/pkg/types"
+)
+
+// Retrieval defaults
+const (
+	DefaultRetrievalTopK        = 5
+	DefaultRetrievalTokenBudget = 1000
+)
+
+// charsPerToken estimates the length of a token, for the token budget
+const charsPerToken = 4
+
+// minSnippetTokens is the smallest part of a snippet worth injecting when
+// the budget cuts it short
+const minSnippetTokens = 16
+
+// RetrievalConfig configures the snippets retrieved from an agent's memory
+// for each turn. It is read from the retrieval parameter of a Sentinelfile,
+// either a boolean or a map:
+//
+//	parameters:
+//	  retrieval:
+//	    topK: 5
+//	    tokenBudget: 1000
+//	    minScore: 0.2
+//	    embeddingProvider: ollama
+//	    embeddingModel: nomic-embed-text
+type RetrievalConfig struct {
+	Enabled     bool
+	TopK        int
+	TokenBudget int
+	MinScore    float64
+	Embedding   types.EmbeddingConfig
+}
+
+// MemorySearcher finds the documents in an agent's memory most similar to
+// an embedding, as services.MemoryService does
+type MemorySearcher interface {
+	SearchSimilar(ctx context.Context, agentID string, queryEmbedding []float32, topK int) ([]memory.SimilarityMatch, error)
+}
+
+// ParseRetrievalConfig reads the retrieval parameter from definition
+// parameters. Retrieval is disabled when the parameter is absent.
+func ParseRetrievalConfig(parameters map[string]interface{}) (RetrievalConfig, error) {
+	config := RetrievalConfig{
+		TopK:        DefaultRetrievalTopK,
+		TokenBudget: DefaultRetrievalTokenBudget,
+		Embedding:   types.EmbeddingConfig{Provider: embedding.ProviderLocal},
+	}
+
+	raw, ok := parameters["retrieval"]
+	if !ok || raw == nil {
+		return config, nil
+	}
+
+	if enabled, ok := boolParameter(raw); ok {
+		config.Enabled = enabled
+		return config, nil
+	}
+	options, ok := raw.(map[string]interface{})
+	if !ok {
+		return config, fmt.Errorf("retrieval must be a boolean or a map, got %T", raw)
+	}
+
+	config.Enabled = true
+	if value, ok := options["enabled"]; ok {
+		enabled, ok := boolParameter(value)
+		if !ok {
+			return config, fmt.Errorf("retrieval.enabled must be a boolean")
+		}
+		config.Enabled = enabled
+	}
+
+	for name, target := range map[string]*int{
+		"topK":                &config.TopK,
+		"tokenBudget":         &config.TokenBudget,
+		"embeddingDimensions": &config.Embedding.Dimensions,
+	} {
+		value, ok := options[name]
+		if !ok {
+			continue
+		}
+		number, ok := numberParameter(value)
+		if !ok || number < 0 || number != float64(int(number)) {
+			return config, fmt.Errorf("retrieval.%s must be a whole number", name)
+		}
+		*target = int(number)
+	}
+	if config.TopK == 0 || config.TokenBudget == 0 {
+		return config, fmt.Errorf("retrieval.topK and retrieval.tokenBudget must be positive")
+	}
+
+	if value, ok := options["minScore"]; ok {
+		number, ok := numberParameter(value)
+		if !ok {
+			return config, fmt.Errorf("retrieval.minScore must be a number")
+		}
+		config.MinScore = number
+	}
+	if provider, ok := options["embeddingProvider"].(string); ok && provider != "" {
+		config.Embedding.Provider = provider
+	}
+	if model, ok := options["embeddingModel"].(string); ok {
+		config.Embedding.Model = model
+	}
+
+	return config, nil
+}
+
+// boolParameter reads a boolean parameter, which the natural language
+// parser may leave as a string
+func boolParameter(value interface{}) (bool, bool) {
+	switch v := value.(type) {
+	case bool:
+		return v, true
+	case string:
+		b, err := strconv.ParseBool(v)
+		return b, err == nil
+	}
+	return false, false
+}
+
+// numberParameter reads a numeric parameter
+func numberParameter(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// Retriever finds snippets in an agent's memory relevant to a turn
+type Retriever struct {
+	config   RetrievalConfig
+	embedder types.Embedder
+	memory   MemorySearcher
+	agentID  string
+}
+
+// NewRetriever creates a retriever searching the memory of agentID
+func NewRetriever(config RetrievalConfig, embedder types.Embedder, searcher MemorySearcher, agentID string) *Retriever {
+	return &Retriever{
+		config:   config,
+		embedder: embedder,
+		memory:   searcher,
+		agentID:  agentID,
+	}
+}
+
+// Retrieve returns the snippets most similar to text as a numbered context
+// block, or an empty string when none match
+func (r *Retriever) Retrieve(ctx context.Context, text string) (string, error) {
+	if strings.TrimSpace(text) == "" {
+		return "", nil
+	}
+
+	embeddings, err := r.embedder.Embed(ctx, []string{text})
+	if err != nil {
+		return "", fmt.Errorf("failed to embed input: %w", err)
+	}
+	if len(embeddings) != 1 {
+		return "", fmt.Errorf("expected 1 embedding, got %d", len(embeddings))
+	}
+
+	matches, err := r.memory.SearchSimilar(ctx, r.agentID, embeddings[0], r.config.TopK)
+	if err != nil {
+		return "", fmt.Errorf("failed to search memory: %w", err)
+	}
+
+	return formatRetrievedContext(matches, r.config.MinScore, r.config.TokenBudget), nil
+}
+
+// formatRetrievedContext lists the text of each match scoring at least
+// minScore with its citation, until the token budget is spent. The last
+// snippet is cut short to fit.
+func formatRetrievedContext(matches []memory.SimilarityMatch, minScore float64, tokenBudget int) string {
+	header := "Relevant context from memory. Cite it by number where you use it.\n"
+	remaining := tokenBudget*charsPerToken - len(header)
+
+	var snippets []string
+	for _, match := range matches {
+		if float64(match.Score) < minScore {
+			continue
+		}
+		text, _ := match.Metadata["text"].(string)
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+
+		label := fmt.Sprintf("\n[%d] ", len(snippets)+1)
+		source := " (" + citation(match) + ")"
+		room := remaining - len(label) - len(source)
+		if room < minSnippetTokens*charsPerToken {
+			break
+		}
+		if len(text) > room {
+			text = truncateText(text, room-len("..."))
+			remaining = 0
+		} else {
+			remaining -= len(label) + len(text) + len(source)
+		}
+		snippets = append(snippets, label+text+source)
+	}
+
+	if len(snippets) == 0 {
+		return ""
+	}
+	return header + strings.Join(snippets, "")
+}
+
+// citation names the source of a match, and where in it the snippet starts
+func citation(match memory.SimilarityMatch) string {
+	source, _ := match.Metadata["source"].(string)
+	if source == "" {
+		source = match.Key
+	}
+	if offset, ok := match.Metadata["offset"]; ok {
+		return fmt.Sprintf("%s, offset %v", source, offset)
+	}
+	return source
+}
+
+// truncateText cuts text to at most n bytes at a word boundary, marking
+// the cut
+func truncateText(text string, n int) string {
+	if len(text) <= n {
+		return text
+	}
+	cut := text[:n]
+	if i := strings.LastIndexAny(cut, " \n\t"); i > n/2 {
+		cut = cut[:i]
+	}
+	return strings.ToValidUTF8(cut, "") + "..."
+}
+
+// ConfigureRetrieval enables retrieval from the agent's memory when the
+// parameters of its definition ask for it
+func (a *MultimodalAgent) ConfigureRetrieval(parameters map[string]interface{}, searcher MemorySearcher) error {
+	config, err := ParseRetrievalConfig(parameters)
+	if err != nil {
+		return fmt.Errorf("invalid retrieval configuration: %w", err)
+	}
+	if !config.Enabled {
+		return nil
+	}
+
+	embedder, err := embedding.New(config.Embedding)
+	if err != nil {
+		return fmt.Errorf("failed to create embedder: %w", err)
+	}
+
+	// Memory is kept under the agent name, which outlives its ID
+	a.metadata["retriever"] = NewRetriever(config, embedder, searcher, a.Agent.Name)
+	return nil
+}
+
+// withRetrievedContext returns the input with the snippets retrieved for
+// text placed before its contents. Without a retriever, or when retrieval
+// fails, the input is returned as it is.
+func (a *MultimodalAgent) withRetrievedContext(ctx context.Context, input *multimodal.Input, text string) *multimodal.Input {
+	retriever, ok := a.metadata["retriever"].(*Retriever)
+	if !ok {
+		return input
+	}
+
+	retrieved, err := retriever.Retrieve(ctx, text)
+	if err != nil {
+		fmt.Printf("Warning: Failed to retrieve context: %v\n", err)
+		return input
+	}
+	if retrieved == "" {
+		return input
+	}
+
+	augmented := *input
+	augmented.Contents = append([]*multimodal.Content{multimodal.NewTextContent(retrieved)}, input.Contents...)
+	return &augmented
+}
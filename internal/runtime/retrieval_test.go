@@ -0,0 +1,103 @@
+package runtime
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/memory"
+	"github.com/satishgonella2024/sentinelstacks/internal/multimodal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSearcher returns fixed matches and records the searches made
+type fakeSearcher struct {
+	matches  []memory.SimilarityMatch
+	agentIDs []string
+}
+
+func (s *fakeSearcher) SearchSimilar(ctx context.Context, agentID string, queryEmbedding []float32, topK int) ([]memory.SimilarityMatch, error) {
+	s.agentIDs = append(s.agentIDs, agentID)
+	if len(s.matches) > topK {
+		return s.matches[:topK], nil
+	}
+	return s.matches, nil
+}
+
+func TestParseRetrievalConfig(t *testing.T) {
+	config, err := ParseRetrievalConfig(map[string]interface{}{"temperature": 0.7})
+	require.NoError(t, err)
+	assert.False(t, config.Enabled)
+
+	config, err = ParseRetrievalConfig(map[string]interface{}{"retrieval": "true"})
+	require.NoError(t, err)
+	assert.True(t, config.Enabled)
+	assert.Equal(t, DefaultRetrievalTopK, config.TopK)
+
+	config, err = ParseRetrievalConfig(map[string]interface{}{"retrieval": map[string]interface{}{
+		"topK":              3,
+		"tokenBudget":       200.0,
+		"minScore":          0.25,
+		"embeddingProvider": "ollama",
+		"embeddingModel":    "nomic-embed-text",
+	}})
+	require.NoError(t, err)
+	assert.True(t, config.Enabled)
+	assert.Equal(t, 3, config.TopK)
+	assert.Equal(t, 200, config.TokenBudget)
+	assert.Equal(t, 0.25, config.MinScore)
+	assert.Equal(t, "ollama", config.Embedding.Provider)
+
+	for _, retrieval := range []interface{}{
+		42,
+		map[string]interface{}{"topK": 2.5},
+		map[string]interface{}{"tokenBudget": 0},
+		map[string]interface{}{"enabled": "sometimes"},
+	} {
+		_, err := ParseRetrievalConfig(map[string]interface{}{"retrieval": retrieval})
+		assert.Error(t, err, "retrieval %v", retrieval)
+	}
+}
+
+func TestFormatRetrievedContext(t *testing.T) {
+	matches := []memory.SimilarityMatch{
+		{Key: "a", Score: 0.9, Metadata: map[string]interface{}{"text": "The deploy runs at noon.", "source": "docs/deploy.md", "offset": 120}},
+		{Key: "b", Score: 0.1, Metadata: map[string]interface{}{"text": "Unrelated.", "source": "docs/other.md"}},
+		{Key: "c", Score: 0.8, Metadata: map[string]interface{}{"text": strings.Repeat("rollback steps ", 100)}},
+	}
+
+	block := formatRetrievedContext(matches, 0.5, 100)
+	assert.Contains(t, block, "[1] The deploy runs at noon. (docs/deploy.md, offset 120)")
+	assert.NotContains(t, block, "Unrelated")
+	assert.Contains(t, block, "[2] rollback steps")
+	assert.Contains(t, block, "... (c)")
+	assert.LessOrEqual(t, len(block), 100*charsPerToken)
+
+	assert.Empty(t, formatRetrievedContext(matches, 0.95, 100))
+}
+
+func TestMultimodalAgentRetrieval(t *testing.T) {
+	searcher := &fakeSearcher{matches: []memory.SimilarityMatch{
+		{Key: "a", Score: 0.9, Metadata: map[string]interface{}{"text": "The deploy runs at noon.", "source": "docs/deploy.md"}},
+	}}
+	agent := &MultimodalAgent{
+		Agent:    &Agent{ID: "test-agent", Name: "ops-assistant"},
+		metadata: make(map[string]interface{}),
+	}
+	require.NoError(t, agent.ConfigureRetrieval(map[string]interface{}{"retrieval": true}, searcher))
+
+	input := multimodal.NewInput()
+	input.AddText("When does the deploy run?")
+	augmented := agent.withRetrievedContext(context.Background(), input, "When does the deploy run?")
+
+	require.Len(t, augmented.Contents, 2)
+	assert.Contains(t, augmented.Contents[0].Text, "[1] The deploy runs at noon. (docs/deploy.md)")
+	assert.Equal(t, "When does the deploy run?", augmented.Contents[1].Text)
+	assert.Len(t, input.Contents, 1, "the input itself is left as it is")
+	assert.Equal(t, []string{"ops-assistant"}, searcher.agentIDs)
+
+	// Without retrieval configured the input is passed through
+	plain := &MultimodalAgent{Agent: &Agent{Name: "plain"}, metadata: make(map[string]interface{})}
+	assert.Same(t, input, plain.withRetrievedContext(context.Background(), input, "hello"))
+}
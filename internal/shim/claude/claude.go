@@ -13,7 +13,6 @@ import (
 	"time"
 
 	"github.com/satishgonella2024/sentinelstacks/internal/multimodal"
-	"github.com/satishgonella2024/sentinelstacks/internal/shim"
 )
 
 // Constants for Claude API
@@ -41,7 +40,9 @@ type AnthropicMessage struct {
 	Content []AnthropicMessagePart `json:"content"`
 }
 
-// AnthropicMessagePart represents a part of a message in the Claude API
+// AnthropicMessagePart represents a part of a message in the Claude API.
+// tool_use parts carry ID, Name and Input; tool_result parts carry
+// ToolUseID, Content and IsError.
 type AnthropicMessagePart struct {
 	Type  string `json:"type"`
 	Text  string `json:"text,omitempty"`
@@ -52,6 +53,12 @@ type AnthropicMessagePart struct {
 			Data      string `json:"data"`
 		} `json:"source"`
 	} `json:"image,omitempty"`
+	ID        string      `json:"id,omitempty"`
+	Name      string      `json:"name,omitempty"`
+	Input     interface{} `json:"input,omitempty"`
+	ToolUseID string      `json:"tool_use_id,omitempty"`
+	Content   string      `json:"content,omitempty"`
+	IsError   bool        `json:"is_error,omitempty"`
 }
 
 // AnthropicRequest represents a request to the Claude API
@@ -62,6 +69,7 @@ type AnthropicRequest struct {
 	Temperature float64            `json:"temperature,omitempty"`
 	Stream      bool               `json:"stream,omitempty"`
 	System      string             `json:"system,omitempty"`
+	Tools       []AnthropicTool    `json:"tools,omitempty"`
 }
 
 // AnthropicResponse represents a response from the Claude API
@@ -99,8 +107,16 @@ type AnthropicStreamingChunk struct {
 	} `json:"error,omitempty"`
 }
 
+// Config configures a ClaudeShim
+type Config struct {
+	Model    string
+	APIKey   string
+	Endpoint string
+	Timeout  time.Duration
+}
+
 // NewClaudeShim creates a new ClaudeShim with config
-func NewClaudeShim(config shim.Config) *ClaudeShim {
+func NewClaudeShim(config Config) *ClaudeShim {
 	// Set defaults if not provided
 	endpoint := config.Endpoint
 	if endpoint == "" {
@@ -434,6 +450,7 @@ Your task is to extract information from the Sentinelfile and output a valid JSO
 - baseModel: The LLM model to use (string, required)
 - capabilities: List of capabilities the agent should have (array of strings, optional)
 - tools: List of tools the agent should have access to (array of strings, optional)
+- mcpServers: MCP servers whose tools the agent uses, each with a name and either command and args (stdio servers) or url (HTTP servers) (array of objects, optional)
 - stateSchema: Description of the state the agent should maintain (object, optional)
 - parameters: Configuration parameters for the agent (object, optional)
 
@@ -660,7 +677,6 @@ func (s *ClaudeShim) streamRequest(ctx context.Context, request AnthropicRequest
 				case ch <- fmt.Sprintf("Error parsing chunk: %v", err):
 					return
 				}
-				continue
 			}
 
 			// Check for errors
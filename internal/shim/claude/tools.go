@@ -0,0 +1,128 @@
+package claude
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/tools"
+)
+
+// AnthropicTool declares a tool Claude may use
+type AnthropicTool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	InputSchema map[string]interface{} `json:"input_schema"`
+}
+
+// ChatWithTools sends a conversation to Claude with the given tools and
+// returns the reply, including any tool_use blocks as tool calls
+func (s *ClaudeShim) ChatWithTools(ctx context.Context, messages []tools.Message, schemas []tools.Schema, maxTokens int, temperature float64) (*tools.ChatResponse, error) {
+	// Set defaults if not provided
+	if maxTokens <= 0 {
+		maxTokens = DefaultMaxTokens
+	}
+
+	if temperature <= 0 {
+		temperature = DefaultTemperature
+	}
+
+	request := AnthropicRequest{
+		Model:       s.Model,
+		MaxTokens:   maxTokens,
+		Temperature: temperature,
+	}
+
+	for _, schema := range schemas {
+		request.Tools = append(request.Tools, AnthropicTool{
+			Name:        tools.WireName(schema.Name),
+			Description: schema.Description,
+			InputSchema: schema.Parameters,
+		})
+	}
+
+	// System messages go in the system field; tool results are user
+	// messages, and consecutive results share one
+	var system []string
+	if s.SystemPrompt != "" {
+		system = append(system, s.SystemPrompt)
+	}
+	for _, message := range messages {
+		var role string
+		var parts []AnthropicMessagePart
+
+		switch message.Role {
+		case tools.RoleSystem:
+			system = append(system, message.Content)
+			continue
+		case tools.RoleTool:
+			role = "user"
+			parts = append(parts, AnthropicMessagePart{
+				Type:      "tool_result",
+				ToolUseID: message.ToolCallID,
+				Content:   message.Content,
+				IsError:   message.IsError,
+			})
+		case tools.RoleAssistant:
+			role = "assistant"
+			if message.Content != "" {
+				parts = append(parts, AnthropicMessagePart{Type: "text", Text: message.Content})
+			}
+			for _, call := range message.ToolCalls {
+				input := call.Parameters
+				if input == nil {
+					input = make(map[string]interface{})
+				}
+				parts = append(parts, AnthropicMessagePart{
+					Type:  "tool_use",
+					ID:    call.ID,
+					Name:  tools.WireName(call.Name),
+					Input: input,
+				})
+			}
+		default:
+			role = "user"
+			parts = append(parts, AnthropicMessagePart{Type: "text", Text: message.Content})
+		}
+
+		if n := len(request.Messages); n > 0 && request.Messages[n-1].Role == role {
+			request.Messages[n-1].Content = append(request.Messages[n-1].Content, parts...)
+			continue
+		}
+		request.Messages = append(request.Messages, AnthropicMessage{Role: role, Content: parts})
+	}
+	request.System = strings.Join(system, "\n\n")
+
+	response, err := s.makeRequest(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("Claude API request failed: %w", err)
+	}
+
+	result := &tools.ChatResponse{StopReason: response.StopReason}
+	for _, part := range response.Content {
+		switch part.Type {
+		case "text":
+			result.Content += part.Text
+		case "tool_use":
+			// Input decodes as a generic value; round-trip it into parameters
+			params := make(map[string]interface{})
+			if part.Input != nil {
+				data, err := json.Marshal(part.Input)
+				if err != nil {
+					return nil, fmt.Errorf("failed to read input of %s: %w", part.Name, err)
+				}
+				if err := json.Unmarshal(data, &params); err != nil {
+					return nil, fmt.Errorf("invalid input for %s: %w", part.Name, err)
+				}
+			}
+			result.ToolCalls = append(result.ToolCalls, tools.FunctionCall{
+				ID:         part.ID,
+				Name:       tools.ResolveWireName(schemas, part.Name),
+				Parameters: params,
+			})
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,107 @@
+package claude
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/tools"
+)
+
+func TestChatWithTools(t *testing.T) {
+	schemas := []tools.Schema{{
+		Name:        "file/write",
+		Description: "Write a file",
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"path":    map[string]interface{}{"type": "string"},
+				"content": map[string]interface{}{"type": "string"},
+			},
+		},
+	}}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request AnthropicRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if len(request.Tools) != 1 || request.Tools[0].Name != "file_write" || request.Tools[0].InputSchema["type"] != "object" {
+			t.Errorf("Expected tool file_write with its schema, got %+v", request.Tools)
+		}
+		if request.System != "Be brief" {
+			t.Errorf("Expected the system message in the system field, got %q", request.System)
+		}
+
+		// The previous call goes back as a tool_use block and its result as
+		// a tool_result block of a user message
+		if len(request.Messages) != 3 {
+			t.Errorf("Expected 3 messages, got %d", len(request.Messages))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		call := request.Messages[1]
+		if call.Role != "assistant" || len(call.Content) != 2 || call.Content[0].Text != "Saving" {
+			t.Errorf("Expected an assistant message with text and a tool call, got %+v", call)
+		} else if use := call.Content[1]; use.Type != "tool_use" || use.ID != "toolu_1" || use.Name != "file_write" {
+			t.Errorf("Expected tool_use toolu_1 of file_write, got %+v", use)
+		} else if input, ok := use.Input.(map[string]interface{}); !ok || input["path"] != "a.txt" {
+			t.Errorf("Expected the call's input, got %+v", use.Input)
+		}
+		result := request.Messages[2]
+		if result.Role != "user" || len(result.Content) != 1 {
+			t.Errorf("Expected a user message with a tool result, got %+v", result)
+		} else if part := result.Content[0]; part.Type != "tool_result" || part.ToolUseID != "toolu_1" || !part.IsError || part.Content == "" {
+			t.Errorf("Expected a failed tool_result for toolu_1, got %+v", part)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{
+			"id": "msg_2",
+			"type": "message",
+			"role": "assistant",
+			"content": [
+				{"type": "text", "text": "Retrying with content."},
+				{"type": "tool_use", "id": "toolu_2", "name": "file_write", "input": {"path": "notes.txt", "content": "a, b = c", "meta": {"tags": ["x"]}}}
+			],
+			"stop_reason": "tool_use"
+		}`))
+	}))
+	defer server.Close()
+
+	shim := NewClaudeShim(Config{APIKey: "test-key", Endpoint: server.URL})
+
+	messages := []tools.Message{
+		{Role: tools.RoleSystem, Content: "Be brief"},
+		{Role: tools.RoleUser, Content: "Save my notes"},
+		{Role: tools.RoleAssistant, Content: "Saving", ToolCalls: []tools.FunctionCall{{ID: "toolu_1", Name: "file/write", Parameters: map[string]interface{}{"path": "a.txt"}}}},
+		tools.NewToolMessage(&tools.FunctionCall{ID: "toolu_1", Name: "file/write"}, &tools.FunctionResult{Name: "file/write", Error: "missing content"}),
+	}
+
+	response, err := shim.ChatWithTools(context.Background(), messages, schemas, 0, 0)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if response.Content != "Retrying with content." || response.StopReason != "tool_use" {
+		t.Errorf("Expected the text and stop reason, got %q and %q", response.Content, response.StopReason)
+	}
+	if len(response.ToolCalls) != 1 {
+		t.Fatalf("Expected 1 tool call, got %d", len(response.ToolCalls))
+	}
+	call := response.ToolCalls[0]
+	if call.ID != "toolu_2" || call.Name != "file/write" {
+		t.Errorf("Expected toolu_2 to file/write, got %s to %s", call.ID, call.Name)
+	}
+	if call.Parameters["content"] != "a, b = c" {
+		t.Errorf("Expected content with commas to survive, got %v", call.Parameters["content"])
+	}
+	if _, ok := call.Parameters["meta"].(map[string]interface{}); !ok {
+		t.Errorf("Expected nested object parameter, got %T", call.Parameters["meta"])
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/satishgonella2024/sentinelstacks/internal/multimodal"
+	"github.com/satishgonella2024/sentinelstacks/internal/tools"
 	"github.com/satishgonella2024/sentinelstacks/internal/shim/claude"
 )
 
@@ -19,8 +20,7 @@ type ClaudeShim struct {
 // NewClaudeShim creates a new shim for Claude
 func NewClaudeShim(config Config) *ClaudeShim {
 	// Create the inner Claude shim
-	claudeShim := claude.NewClaudeShim(Config{
-		Provider: config.Provider,
+	claudeShim := claude.NewClaudeShim(claude.Config{
 		Model:    config.Model,
 		APIKey:   config.APIKey,
 		Endpoint: config.Endpoint,
@@ -142,6 +142,22 @@ func (s *ClaudeShim) StreamMultimodalCompletion(ctx context.Context, input *mult
 	return resultCh, nil
 }
 
+// CompletionWithTools runs a turn of a tool-calling conversation using
+// Claude tool use
+func (s *ClaudeShim) CompletionWithTools(ctx context.Context, messages []tools.Message, toolSchemas []tools.Schema, maxTokens int, temperature float64) (*tools.ChatResponse, error) {
+	// Use the inner Claude shim if available
+	if s.claude != nil {
+		return s.claude.ChatWithTools(ctx, messages, toolSchemas, maxTokens, temperature)
+	}
+	
+	return TextToolCompletion(ctx, s, messages, toolSchemas, maxTokens, temperature)
+}
+
+// SupportsTools returns whether Claude calls tools natively
+func (s *ClaudeShim) SupportsTools() bool {
+	return s.claude != nil
+}
+
 // SetSystemPrompt sets the system prompt for Claude
 func (s *ClaudeShim) SetSystemPrompt(prompt string) {
 	s.systemPrompt = prompt
@@ -9,7 +9,6 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"strings"
 	"time"
 
 	"github.com/satishgonella2024/sentinelstacks/internal/multimodal"
@@ -37,6 +36,8 @@ type GoogleShim struct {
 type ContentPart struct {
 	Text  string             `json:"text,omitempty"`
 	InlineData *InlineData   `json:"inlineData,omitempty"`
+	FunctionCall     *FunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *FunctionResponse `json:"functionResponse,omitempty"`
 }
 
 // InlineData represents image data in the Google API
@@ -50,6 +51,8 @@ type ContentRequest struct {
 	Contents     []Content           `json:"contents"`
 	SafetySettings []SafetySetting   `json:"safetySettings,omitempty"`
 	GenerationConfig GenerationConfig `json:"generationConfig,omitempty"`
+	Tools             []Tool   `json:"tools,omitempty"`
+	SystemInstruction *Content `json:"systemInstruction,omitempty"`
 }
 
 // Content represents a content message in the Google API
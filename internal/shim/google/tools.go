@@ -0,0 +1,178 @@
+package google
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/tools"
+)
+
+// Tool groups the functions Gemini may call
+type Tool struct {
+	FunctionDeclarations []FunctionDeclaration `json:"functionDeclarations"`
+}
+
+// FunctionDeclaration describes a function and its parameters
+type FunctionDeclaration struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty"`
+}
+
+// FunctionCall is a function call made by the model
+type FunctionCall struct {
+	Name string                 `json:"name"`
+	Args map[string]interface{} `json:"args"`
+}
+
+// FunctionResponse is the result of a function call sent back to the model
+type FunctionResponse struct {
+	Name     string                 `json:"name"`
+	Response map[string]interface{} `json:"response"`
+}
+
+// ChatWithTools sends a conversation to Gemini with the given tools and
+// returns the reply, including any function calls the model made
+func (s *GoogleShim) ChatWithTools(ctx context.Context, messages []tools.Message, schemas []tools.Schema, systemPrompt string) (*tools.ChatResponse, error) {
+	request := ContentRequest{
+		GenerationConfig: GenerationConfig{
+			Temperature:     s.Temperature,
+			MaxOutputTokens: s.MaxTokens,
+		},
+	}
+
+	if len(schemas) > 0 {
+		var declarations []FunctionDeclaration
+		for _, schema := range schemas {
+			declarations = append(declarations, FunctionDeclaration{
+				Name:        tools.WireName(schema.Name),
+				Description: schema.Description,
+				Parameters:  parameterSchema(schema.Parameters),
+			})
+		}
+		request.Tools = []Tool{{FunctionDeclarations: declarations}}
+	}
+
+	// System messages become the system instruction; function responses
+	// are user turns, and consecutive responses share one
+	var system []string
+	if systemPrompt != "" {
+		system = append(system, systemPrompt)
+	}
+	for _, message := range messages {
+		var role string
+		var parts []ContentPart
+
+		switch message.Role {
+		case tools.RoleSystem:
+			system = append(system, message.Content)
+			continue
+		case tools.RoleTool:
+			role = "user"
+			parts = append(parts, ContentPart{
+				FunctionResponse: &FunctionResponse{
+					Name:     tools.WireName(message.Name),
+					Response: responseObject(message.Content),
+				},
+			})
+		case tools.RoleAssistant:
+			role = "model"
+			if message.Content != "" {
+				parts = append(parts, ContentPart{Text: message.Content})
+			}
+			for _, call := range message.ToolCalls {
+				args := call.Parameters
+				if args == nil {
+					args = make(map[string]interface{})
+				}
+				parts = append(parts, ContentPart{
+					FunctionCall: &FunctionCall{Name: tools.WireName(call.Name), Args: args},
+				})
+			}
+		default:
+			role = "user"
+			parts = append(parts, ContentPart{Text: message.Content})
+		}
+
+		if n := len(request.Contents); n > 0 && request.Contents[n-1].Role == role {
+			request.Contents[n-1].Parts = append(request.Contents[n-1].Parts, parts...)
+			continue
+		}
+		request.Contents = append(request.Contents, Content{Role: role, Parts: parts})
+	}
+	if len(system) > 0 {
+		request.SystemInstruction = &Content{Parts: []ContentPart{{Text: strings.Join(system, "\n\n")}}}
+	}
+
+	response, err := s.makeContentRequest(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	if len(response.Candidates) == 0 {
+		return nil, fmt.Errorf("no candidates in response")
+	}
+
+	// Gemini does not identify calls, so they are numbered to match results
+	candidate := response.Candidates[0]
+	result := &tools.ChatResponse{StopReason: candidate.FinishReason}
+	for _, part := range candidate.Content.Parts {
+		result.Content += part.Text
+		if part.FunctionCall != nil {
+			args := part.FunctionCall.Args
+			if args == nil {
+				args = make(map[string]interface{})
+			}
+			result.ToolCalls = append(result.ToolCalls, tools.FunctionCall{
+				ID:         fmt.Sprintf("call_%d", len(result.ToolCalls)),
+				Name:       tools.ResolveWireName(schemas, part.FunctionCall.Name),
+				Parameters: args,
+			})
+		}
+	}
+
+	return result, nil
+}
+
+// parameterSchema adapts a JSON schema to the subset Gemini accepts: it
+// has no defaults, and objects without properties are left out
+func parameterSchema(schema map[string]interface{}) map[string]interface{} {
+	if properties, ok := schema["properties"].(map[string]interface{}); ok && len(properties) == 0 {
+		return nil
+	}
+
+	adapted := make(map[string]interface{}, len(schema))
+	for key, value := range schema {
+		switch key {
+		case "default":
+			continue
+		case "properties":
+			if properties, ok := value.(map[string]interface{}); ok {
+				adaptedProperties := make(map[string]interface{}, len(properties))
+				for name, property := range properties {
+					if nested, ok := property.(map[string]interface{}); ok {
+						property = parameterSchema(nested)
+					}
+					adaptedProperties[name] = property
+				}
+				value = adaptedProperties
+			}
+		}
+		adapted[key] = value
+	}
+	return adapted
+}
+
+// responseObject decodes the JSON content of a tool message into the
+// object Gemini expects, wrapping values that are not objects
+func responseObject(content string) map[string]interface{} {
+	var value interface{}
+	if err := json.Unmarshal([]byte(content), &value); err != nil {
+		return map[string]interface{}{"content": content}
+	}
+	if object, ok := value.(map[string]interface{}); ok {
+		return object
+	}
+	return map[string]interface{}{"content": value}
+}
@@ -0,0 +1,119 @@
+package google
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/tools"
+)
+
+func TestChatWithTools(t *testing.T) {
+	schemas := []tools.Schema{{
+		Name:        "file/write",
+		Description: "Write a file",
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"path":    map[string]interface{}{"type": "string", "default": "notes.txt"},
+				"content": map[string]interface{}{"type": "string"},
+			},
+		},
+	}}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/gemini-pro:generateContent" {
+			t.Errorf("Expected request to /gemini-pro:generateContent, got %s", r.URL.Path)
+		}
+
+		var request ContentRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if len(request.Tools) != 1 || len(request.Tools[0].FunctionDeclarations) != 1 {
+			t.Errorf("Expected one function declaration, got %+v", request.Tools)
+		} else if declaration := request.Tools[0].FunctionDeclarations[0]; declaration.Name != "file_write" {
+			t.Errorf("Expected function file_write, got %s", declaration.Name)
+		} else {
+			properties, _ := declaration.Parameters["properties"].(map[string]interface{})
+			if path, ok := properties["path"].(map[string]interface{}); !ok || path["default"] != nil {
+				t.Errorf("Expected the path parameter without its default, got %+v", properties["path"])
+			}
+		}
+		if request.SystemInstruction == nil || request.SystemInstruction.Parts[0].Text != "Be brief" {
+			t.Errorf("Expected the system message as the system instruction, got %+v", request.SystemInstruction)
+		}
+
+		// The previous call goes back as a functionCall part of a model
+		// turn and its result as a functionResponse part of a user turn
+		if len(request.Contents) != 3 {
+			t.Errorf("Expected 3 contents, got %d", len(request.Contents))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		call := request.Contents[1]
+		if call.Role != "model" || len(call.Parts) != 1 || call.Parts[0].FunctionCall == nil {
+			t.Errorf("Expected a model turn with a function call, got %+v", call)
+		} else if fc := call.Parts[0].FunctionCall; fc.Name != "file_write" || fc.Args["path"] != "a.txt" {
+			t.Errorf("Expected file_write of a.txt, got %+v", fc)
+		}
+		result := request.Contents[2]
+		if result.Role != "user" || len(result.Parts) != 1 || result.Parts[0].FunctionResponse == nil {
+			t.Errorf("Expected a user turn with a function response, got %+v", result)
+		} else if fr := result.Parts[0].FunctionResponse; fr.Name != "file_write" || fr.Response["error"] != "missing content" {
+			t.Errorf("Expected the error of file_write, got %+v", fr)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{
+			"candidates": [{
+				"content": {
+					"role": "model",
+					"parts": [
+						{"text": "Retrying with content."},
+						{"functionCall": {"name": "file_write", "args": {"path": "notes.txt", "content": "a, b = c", "meta": {"tags": ["x"]}}}}
+					]
+				},
+				"finishReason": "STOP"
+			}]
+		}`))
+	}))
+	defer server.Close()
+
+	shim := NewGoogleShim("test-key", "gemini-pro")
+	shim.Endpoint = server.URL
+
+	messages := []tools.Message{
+		{Role: tools.RoleSystem, Content: "Be brief"},
+		{Role: tools.RoleUser, Content: "Save my notes"},
+		{Role: tools.RoleAssistant, ToolCalls: []tools.FunctionCall{{ID: "call_0", Name: "file/write", Parameters: map[string]interface{}{"path": "a.txt"}}}},
+		tools.NewToolMessage(&tools.FunctionCall{ID: "call_0", Name: "file/write"}, &tools.FunctionResult{Name: "file/write", Error: "missing content"}),
+	}
+
+	response, err := shim.ChatWithTools(context.Background(), messages, schemas, "")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if response.Content != "Retrying with content." || response.StopReason != "STOP" {
+		t.Errorf("Expected the text and finish reason, got %q and %q", response.Content, response.StopReason)
+	}
+	if len(response.ToolCalls) != 1 {
+		t.Fatalf("Expected 1 tool call, got %d", len(response.ToolCalls))
+	}
+	call := response.ToolCalls[0]
+	if call.ID != "call_0" || call.Name != "file/write" {
+		t.Errorf("Expected call_0 to file/write, got %s to %s", call.ID, call.Name)
+	}
+	if call.Parameters["content"] != "a, b = c" {
+		t.Errorf("Expected content with commas to survive, got %v", call.Parameters["content"])
+	}
+	if _, ok := call.Parameters["meta"].(map[string]interface{}); !ok {
+		t.Errorf("Expected nested object parameter, got %T", call.Parameters["meta"])
+	}
+}
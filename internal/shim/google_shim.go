@@ -2,10 +2,13 @@ package shim
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/satishgonella2024/sentinelstacks/internal/multimodal"
+	"github.com/satishgonella2024/sentinelstacks/internal/tools"
 	"github.com/satishgonella2024/sentinelstacks/internal/shim/google"
 )
 
@@ -184,6 +187,7 @@ Your task is to extract information from the Sentinelfile and output a valid JSO
 - baseModel: The LLM model to use (string, required)
 - capabilities: List of capabilities the agent should have (array of strings, optional)
 - tools: List of tools the agent should have access to (array of strings, optional)
+- mcpServers: MCP servers whose tools the agent uses, each with a name and either command and args (stdio servers) or url (HTTP servers) (array of objects, optional)
 - stateSchema: Description of the state the agent should maintain (object, optional)
 - parameters: Configuration parameters for the agent (object, optional)
 - lifecycle: Object containing initialization and termination behaviors (object, optional)
@@ -222,6 +226,30 @@ Output ONLY the JSON object, no additional text or explanation.`
 	}, nil
 }
 
+// CompletionWithTools runs a turn of a tool-calling conversation using
+// Gemini function calling
+func (s *GoogleShim) CompletionWithTools(ctx context.Context, messages []tools.Message, toolSchemas []tools.Schema, maxTokens int, temperature float64) (*tools.ChatResponse, error) {
+	if s.google == nil {
+		return TextToolCompletion(ctx, s, messages, toolSchemas, maxTokens, temperature)
+	}
+	
+	// Set parameters for this request
+	if maxTokens > 0 {
+		s.google.MaxTokens = maxTokens
+	}
+	
+	if temperature > 0 {
+		s.google.Temperature = temperature
+	}
+	
+	return s.google.ChatWithTools(ctx, messages, toolSchemas, s.systemPrompt)
+}
+
+// SupportsTools returns whether Gemini calls tools natively
+func (s *GoogleShim) SupportsTools() bool {
+	return s.google != nil
+}
+
 // SupportsMultimodal returns whether this shim supports multimodal inputs
 func (s *GoogleShim) SupportsMultimodal() bool {
 	// Google multimodal-capable models
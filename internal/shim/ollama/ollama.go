@@ -125,6 +125,7 @@ Your task is to extract information from the Sentinelfile and output a valid JSO
 - baseModel: The LLM model to use (string, required)
 - capabilities: List of capabilities the agent should have (array of strings, optional)
 - tools: List of tools the agent should have access to (array of strings, optional)
+- mcpServers: MCP servers whose tools the agent uses, each with a name and either command and args (stdio servers) or url (HTTP servers) (array of objects, optional)
 - stateSchema: Description of the state the agent should maintain (object, optional)
 - parameters: Configuration parameters for the agent (object, optional)
 - lifecycle: Object containing initialization and termination behaviors (object, optional)
@@ -1,9 +1,14 @@
 package ollama
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/tools"
 )
 
 func TestNewOllamaShim(t *testing.T) {
@@ -99,3 +104,56 @@ func TestCompleteChatPrompt(t *testing.T) {
 
 // Skip the ParseSentinelfile test for now as it's more complex
 // and would require mocking the CompleteChatPrompt function
+
+func TestChatWithTools(t *testing.T) {
+	schemas := []tools.Schema{{Name: "web/search", Description: "Search the web"}}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request ToolChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if request.Model == "tinyllama" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "registry.ollama.ai/library/tinyllama does not support tools"}`))
+			return
+		}
+
+		if len(request.Tools) != 1 || request.Tools[0].Function.Name != "web_search" {
+			t.Errorf("Expected tool web_search, got %+v", request.Tools)
+		}
+
+		w.Write([]byte(`{
+			"model": "llama3.1",
+			"message": {
+				"role": "assistant",
+				"content": "",
+				"tool_calls": [{"function": {"name": "web_search", "arguments": {"query": "go, generics", "max_results": 3}}}]
+			},
+			"done": true
+		}`))
+	}))
+	defer server.Close()
+
+	messages := []tools.Message{{Role: tools.RoleUser, Content: "Search for Go generics"}}
+
+	shim := NewOllamaShim(server.URL, "llama3.1", "", 0)
+	response, err := shim.ChatWithTools(context.Background(), messages, schemas, 0.7)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(response.ToolCalls) != 1 {
+		t.Fatalf("Expected 1 tool call, got %d", len(response.ToolCalls))
+	}
+	if call := response.ToolCalls[0]; call.Name != "web/search" || call.Parameters["query"] != "go, generics" {
+		t.Errorf("Expected web/search with query 'go, generics', got %s %v", call.Name, call.Parameters)
+	}
+
+	shim = NewOllamaShim(server.URL, "tinyllama", "", 0)
+	if _, err := shim.ChatWithTools(context.Background(), messages, schemas, 0.7); !errors.Is(err, tools.ErrToolsNotSupported) {
+		t.Errorf("Expected ErrToolsNotSupported, got %v", err)
+	}
+}
@@ -12,6 +12,8 @@ import (
 	"time"
 
 	"github.com/satishgonella2024/sentinelstacks/internal/multimodal"
+	"github.com/satishgonella2024/sentinelstacks/pkg/embedding"
+	"github.com/satishgonella2024/sentinelstacks/pkg/types"
 )
 
 // Provider implements the LLM provider interface for Ollama
@@ -20,6 +22,7 @@ type Provider struct {
 	model            string
 	client           *http.Client
 	multimodalModels []string
+	embedder         types.Embedder
 }
 
 // MultimediaMessage represents a message with multimedia content
@@ -56,8 +59,16 @@ type MultimodalRequest struct {
 
 // NewProvider creates a new Ollama provider
 func NewProvider() interface{} {
+	baseURL := "http://localhost:11434"
+	embedder, _ := embedding.New(types.EmbeddingConfig{
+		Provider:          embedding.ProviderOllama,
+		Model:             embedding.DefaultOllamaModel,
+		AdditionalOptions: map[string]interface{}{"endpoint": baseURL},
+	})
+
 	return &Provider{
-		baseURL: "http://localhost:11434",
+		baseURL:  baseURL,
+		embedder: embedder,
 		client: &http.Client{
 			Timeout: 60 * time.Second,
 		},
@@ -261,16 +272,10 @@ func (p *Provider) StreamResponse(ctx context.Context, prompt string, params map
 	return ch, nil
 }
 
-// GetEmbeddings gets embeddings for the given texts
+// GetEmbeddings gets embeddings for the given texts from the server's
+// embedding model
 func (p *Provider) GetEmbeddings(ctx context.Context, texts []string) ([][]float32, error) {
-	// Simple implementation for embeddings (most Ollama models don't have a separate embeddings endpoint)
-	embeddings := make([][]float32, len(texts))
-	for i := range texts {
-		// Return dummy embeddings for now - in a real implementation,
-		// we would call the Ollama API to get actual embeddings
-		embeddings[i] = []float32{0.1, 0.2, 0.3, 0.4, 0.5}
-	}
-	return embeddings, nil
+	return p.embedder.Embed(ctx, texts)
 }
 
 // SupportsMultimodal checks if the provider supports multimodal inputs and outputs
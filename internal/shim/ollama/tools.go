@@ -0,0 +1,157 @@
+package ollama
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/tools"
+)
+
+// ToolDefinition declares a function the model may call
+type ToolDefinition struct {
+	Type     string             `json:"type"`
+	Function FunctionDefinition `json:"function"`
+}
+
+// FunctionDefinition describes a function and its JSON schema parameters
+type FunctionDefinition struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters"`
+}
+
+// ToolCall is a function call made by the model
+type ToolCall struct {
+	Function struct {
+		Name      string                 `json:"name"`
+		Arguments map[string]interface{} `json:"arguments"`
+	} `json:"function"`
+}
+
+// ToolChatMessage is a chat message that can carry tool calls or a tool
+// result
+type ToolChatMessage struct {
+	Role      string     `json:"role"`
+	Content   string     `json:"content"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	ToolName  string     `json:"tool_name,omitempty"`
+}
+
+// ToolChatRequest represents a chat request with tools
+type ToolChatRequest struct {
+	Model    string                 `json:"model"`
+	Messages []ToolChatMessage      `json:"messages"`
+	Tools    []ToolDefinition       `json:"tools,omitempty"`
+	Stream   bool                   `json:"stream"`
+	Options  map[string]interface{} `json:"options,omitempty"`
+}
+
+// ToolChatResponse represents a chat response that may contain tool calls
+type ToolChatResponse struct {
+	Model   string          `json:"model"`
+	Message ToolChatMessage `json:"message"`
+	Done    bool            `json:"done"`
+	Reason  string          `json:"done_reason"`
+}
+
+// ChatWithTools sends a conversation to Ollama's chat API with the given
+// tools and returns the reply, including any tool calls the model made. It
+// returns tools.ErrToolsNotSupported for models without tool support.
+func (s *OllamaShim) ChatWithTools(ctx context.Context, messages []tools.Message, schemas []tools.Schema, temperature float64) (*tools.ChatResponse, error) {
+	chatReq := ToolChatRequest{
+		Model:    s.Model,
+		Messages: make([]ToolChatMessage, 0, len(messages)),
+		Stream:   false,
+		Options: map[string]interface{}{
+			"temperature": temperature,
+			"num_predict": s.MaxTokens,
+		},
+	}
+
+	for _, schema := range schemas {
+		chatReq.Tools = append(chatReq.Tools, ToolDefinition{
+			Type: "function",
+			Function: FunctionDefinition{
+				Name:        tools.WireName(schema.Name),
+				Description: schema.Description,
+				Parameters:  schema.Parameters,
+			},
+		})
+	}
+
+	for _, message := range messages {
+		chatMessage := ToolChatMessage{
+			Role:    message.Role,
+			Content: message.Content,
+		}
+		if message.Role == tools.RoleTool {
+			chatMessage.ToolName = tools.WireName(message.Name)
+		}
+		for _, call := range message.ToolCalls {
+			var toolCall ToolCall
+			toolCall.Function.Name = tools.WireName(call.Name)
+			toolCall.Function.Arguments = call.Parameters
+			chatMessage.ToolCalls = append(chatMessage.ToolCalls, toolCall)
+		}
+		chatReq.Messages = append(chatReq.Messages, chatMessage)
+	}
+
+	reqBody, err := json.Marshal(chatReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal chat request: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/api/chat", s.BaseURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if s.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.APIKey)
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request to Ollama: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		if strings.Contains(string(body), "does not support tools") {
+			return nil, fmt.Errorf("%s: %w", s.Model, tools.ErrToolsNotSupported)
+		}
+		return nil, fmt.Errorf("Ollama API returned non-200 status code %d: %s", resp.StatusCode, string(body))
+	}
+
+	var chatResp ToolChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	// Ollama does not identify calls, so they are numbered to match results
+	response := &tools.ChatResponse{
+		Content:    chatResp.Message.Content,
+		StopReason: chatResp.Reason,
+	}
+	for i, toolCall := range chatResp.Message.ToolCalls {
+		params := toolCall.Function.Arguments
+		if params == nil {
+			params = make(map[string]interface{})
+		}
+		response.ToolCalls = append(response.ToolCalls, tools.FunctionCall{
+			ID:         fmt.Sprintf("call_%d", i),
+			Name:       tools.ResolveWireName(schemas, toolCall.Function.Name),
+			Parameters: params,
+		})
+	}
+
+	return response, nil
+}
@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -12,6 +13,8 @@ import (
 	"time"
 
 	"github.com/satishgonella2024/sentinelstacks/internal/multimodal"
+	"github.com/satishgonella2024/sentinelstacks/internal/shim/ollama"
+	"github.com/satishgonella2024/sentinelstacks/internal/tools"
 )
 
 // OllamaShim is an implementation of LLMShim for Ollama models
@@ -19,6 +22,7 @@ type OllamaShim struct {
 	config       Config
 	systemPrompt string
 	httpClient   *http.Client
+	noTools      bool // set once the model rejected native tool calls
 }
 
 // OllamaGenerateRequest represents a request to the Ollama generate API
@@ -529,6 +533,33 @@ func (s *OllamaShim) StreamMultimodalCompletion(ctx context.Context, input *mult
 	return resultCh, nil
 }
 
+// CompletionWithTools runs a turn of a tool-calling conversation using
+// Ollama's chat API. Models without tool support fall back to describing
+// the tools in the prompt.
+func (s *OllamaShim) CompletionWithTools(ctx context.Context, messages []tools.Message, toolSchemas []tools.Schema, maxTokens int, temperature float64) (*tools.ChatResponse, error) {
+	if s.noTools {
+		return TextToolCompletion(ctx, s, messages, toolSchemas, maxTokens, temperature)
+	}
+	
+	// The chat API lives next to the configured generate endpoint
+	baseURL := strings.TrimSuffix(strings.TrimSuffix(s.config.Endpoint, "/"), "/api/generate")
+	chat := ollama.NewOllamaShim(baseURL, s.config.Model, s.config.APIKey, maxTokens)
+	chat.Client = s.httpClient
+	
+	response, err := chat.ChatWithTools(ctx, withSystemPrompt(messages, s.systemPrompt), toolSchemas, temperature)
+	if errors.Is(err, tools.ErrToolsNotSupported) {
+		s.noTools = true
+		return TextToolCompletion(ctx, s, messages, toolSchemas, maxTokens, temperature)
+	}
+	return response, err
+}
+
+// SupportsTools returns whether the model calls tools natively, as far as
+// is known: it is reported until the model rejects a tool call
+func (s *OllamaShim) SupportsTools() bool {
+	return !s.noTools
+}
+
 // SetSystemPrompt sets the system prompt for Ollama
 func (s *OllamaShim) SetSystemPrompt(prompt string) {
 	s.systemPrompt = prompt
@@ -8,9 +8,13 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/satishgonella2024/sentinelstacks/internal/multimodal"
+	"github.com/satishgonella2024/sentinelstacks/pkg/embedding"
+	"github.com/satishgonella2024/sentinelstacks/pkg/types"
 )
 
 // OpenAIShim implements the LLM provider interface for OpenAI
@@ -98,6 +102,9 @@ type Provider struct {
 	apiKey   string
 	endpoint string
 	model    string
+
+	embedderMu sync.Mutex
+	embedder   types.Embedder
 }
 
 // NewOpenAIShim creates a new OpenAIShim
@@ -173,6 +180,7 @@ Your task is to extract information from the Sentinelfile and output a valid JSO
 - baseModel: The LLM model to use (string, required)
 - capabilities: List of capabilities the agent should have (array of strings, optional)
 - tools: List of tools the agent should have access to (array of strings, optional)
+- mcpServers: MCP servers whose tools the agent uses, each with a name and either command and args (stdio servers) or url (HTTP servers) (array of objects, optional)
 - stateSchema: Description of the state the agent should maintain (object, optional)
 - parameters: Configuration parameters for the agent (object, optional)
 - lifecycle: Object containing initialization and termination behaviors (object, optional)
@@ -350,14 +358,29 @@ func (p *Provider) StreamResponse(ctx context.Context, prompt string, params map
 	return p.streamOpenAIRequest(ctx, request)
 }
 
-// GetEmbeddings gets embeddings for the given texts
+// GetEmbeddings gets embeddings for the given texts from the embeddings
+// endpoint next to the chat completions endpoint
 func (p *Provider) GetEmbeddings(ctx context.Context, texts []string) ([][]float32, error) {
-	// Simple implementation for now
-	embeddings := make([][]float32, len(texts))
-	for i := range texts {
-		embeddings[i] = []float32{0.1, 0.2, 0.3} // Dummy embeddings
+	p.embedderMu.Lock()
+	if p.embedder == nil {
+		embedder, err := embedding.New(types.EmbeddingConfig{
+			Provider: embedding.ProviderOpenAI,
+			Model:    embedding.DefaultOpenAIModel,
+			AdditionalOptions: map[string]interface{}{
+				"endpoint": strings.TrimSuffix(p.endpoint, "/chat/completions"),
+				"api_key":  p.apiKey,
+			},
+		})
+		if err != nil {
+			p.embedderMu.Unlock()
+			return nil, err
+		}
+		p.embedder = embedder
 	}
-	return embeddings, nil
+	embedder := p.embedder
+	p.embedderMu.Unlock()
+
+	return embedder.Embed(ctx, texts)
 }
 
 // SupportsMultimodal checks if the provider supports multimodal inputs and outputs
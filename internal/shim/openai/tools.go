@@ -0,0 +1,166 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/tools"
+)
+
+// ToolDefinition declares a function the model may call
+type ToolDefinition struct {
+	Type     string             `json:"type"`
+	Function FunctionDefinition `json:"function"`
+}
+
+// FunctionDefinition describes a function and its JSON schema parameters
+type FunctionDefinition struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters"`
+}
+
+// ToolCall is a function call made by the model. Arguments is a JSON
+// encoded object.
+type ToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// ToolChatMessage is a chat message that can carry tool calls or a tool
+// result
+type ToolChatMessage struct {
+	Role       string     `json:"role"`
+	Content    string     `json:"content"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+}
+
+// ToolChatRequest represents a chat request with tools
+type ToolChatRequest struct {
+	Model       string            `json:"model"`
+	Messages    []ToolChatMessage `json:"messages"`
+	Tools       []ToolDefinition  `json:"tools,omitempty"`
+	Temperature float64           `json:"temperature,omitempty"`
+	MaxTokens   int               `json:"max_tokens,omitempty"`
+}
+
+// ToolChatResponse represents a chat response that may contain tool calls
+type ToolChatResponse struct {
+	Choices []struct {
+		Message struct {
+			Role      string     `json:"role"`
+			Content   string     `json:"content"`
+			ToolCalls []ToolCall `json:"tool_calls"`
+		} `json:"message"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+}
+
+// NewToolDefinitions converts tool schemas to OpenAI function tools
+func NewToolDefinitions(schemas []tools.Schema) []ToolDefinition {
+	definitions := make([]ToolDefinition, 0, len(schemas))
+	for _, schema := range schemas {
+		definitions = append(definitions, ToolDefinition{
+			Type: "function",
+			Function: FunctionDefinition{
+				Name:        tools.WireName(schema.Name),
+				Description: schema.Description,
+				Parameters:  schema.Parameters,
+			},
+		})
+	}
+	return definitions
+}
+
+// ChatWithTools sends a conversation to OpenAI with the given tools and
+// returns the reply, including any tool calls the model made
+func (s *OpenAIShim) ChatWithTools(ctx context.Context, messages []tools.Message, schemas []tools.Schema) (*tools.ChatResponse, error) {
+	chatReq := ToolChatRequest{
+		Model:       s.Model,
+		Messages:    make([]ToolChatMessage, 0, len(messages)),
+		Tools:       NewToolDefinitions(schemas),
+		Temperature: s.Temperature,
+		MaxTokens:   s.MaxTokens,
+	}
+
+	for _, message := range messages {
+		chatMessage := ToolChatMessage{
+			Role:       message.Role,
+			Content:    message.Content,
+			ToolCallID: message.ToolCallID,
+		}
+		for _, call := range message.ToolCalls {
+			arguments, err := json.Marshal(call.Parameters)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal arguments of %s: %w", call.Name, err)
+			}
+			var toolCall ToolCall
+			toolCall.ID = call.ID
+			toolCall.Type = "function"
+			toolCall.Function.Name = tools.WireName(call.Name)
+			toolCall.Function.Arguments = string(arguments)
+			chatMessage.ToolCalls = append(chatMessage.ToolCalls, toolCall)
+		}
+		chatReq.Messages = append(chatReq.Messages, chatMessage)
+	}
+
+	reqBody, err := json.Marshal(chatReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal chat request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.Endpoint, bytes.NewBuffer(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+s.APIKey)
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request to OpenAI: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("OpenAI API returned non-200 status code %d: %s", resp.StatusCode, string(body))
+	}
+
+	var chatResp ToolChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if len(chatResp.Choices) == 0 {
+		return nil, fmt.Errorf("no content in OpenAI response")
+	}
+
+	choice := chatResp.Choices[0]
+	response := &tools.ChatResponse{
+		Content:    choice.Message.Content,
+		StopReason: choice.FinishReason,
+	}
+	for _, toolCall := range choice.Message.ToolCalls {
+		params, err := tools.ParseArguments(toolCall.Function.Arguments)
+		if err != nil {
+			return nil, fmt.Errorf("tool call %s: %w", toolCall.Function.Name, err)
+		}
+		response.ToolCalls = append(response.ToolCalls, tools.FunctionCall{
+			ID:         toolCall.ID,
+			Name:       tools.ResolveWireName(schemas, toolCall.Function.Name),
+			Parameters: params,
+		})
+	}
+
+	return response, nil
+}
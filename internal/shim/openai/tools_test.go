@@ -0,0 +1,99 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/tools"
+)
+
+func TestChatWithTools(t *testing.T) {
+	schemas := []tools.Schema{{
+		Name:        "file/write",
+		Description: "Write a file",
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"path":    map[string]interface{}{"type": "string"},
+				"content": map[string]interface{}{"type": "string"},
+			},
+		},
+	}}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request ToolChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if len(request.Tools) != 1 || request.Tools[0].Function.Name != "file_write" {
+			t.Errorf("Expected tool file_write, got %+v", request.Tools)
+		}
+
+		// The previous call and its result are sent back as structured messages
+		if len(request.Messages) != 3 {
+			t.Errorf("Expected 3 messages, got %d", len(request.Messages))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if calls := request.Messages[1].ToolCalls; len(calls) != 1 || calls[0].ID != "call_1" || calls[0].Function.Name != "file_write" {
+			t.Errorf("Expected assistant tool call call_1, got %+v", calls)
+		}
+		if request.Messages[2].Role != "tool" || request.Messages[2].ToolCallID != "call_1" {
+			t.Errorf("Expected tool result for call_1, got %+v", request.Messages[2])
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{
+			"choices": [{
+				"message": {
+					"role": "assistant",
+					"content": "",
+					"tool_calls": [{
+						"id": "call_2",
+						"type": "function",
+						"function": {
+							"name": "file_write",
+							"arguments": "{\"path\": \"notes.txt\", \"content\": \"a, b = c\", \"meta\": {\"tags\": [\"x\"]}}"
+						}
+					}]
+				},
+				"finish_reason": "tool_calls"
+			}]
+		}`))
+	}))
+	defer server.Close()
+
+	shim := NewOpenAIShim("test-key", "gpt-4-turbo")
+	shim.Endpoint = server.URL
+
+	messages := []tools.Message{
+		{Role: tools.RoleUser, Content: "Save my notes"},
+		{Role: tools.RoleAssistant, ToolCalls: []tools.FunctionCall{{ID: "call_1", Name: "file/write", Parameters: map[string]interface{}{"path": "a.txt"}}}},
+		tools.NewToolMessage(&tools.FunctionCall{ID: "call_1", Name: "file/write"}, &tools.FunctionResult{Name: "file/write", Error: "missing content"}),
+	}
+
+	response, err := shim.ChatWithTools(context.Background(), messages, schemas)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(response.ToolCalls) != 1 {
+		t.Fatalf("Expected 1 tool call, got %d", len(response.ToolCalls))
+	}
+	call := response.ToolCalls[0]
+	if call.ID != "call_2" || call.Name != "file/write" {
+		t.Errorf("Expected call_2 to file/write, got %s to %s", call.ID, call.Name)
+	}
+	if call.Parameters["content"] != "a, b = c" {
+		t.Errorf("Expected content with commas to survive, got %v", call.Parameters["content"])
+	}
+	if _, ok := call.Parameters["meta"].(map[string]interface{}); !ok {
+		t.Errorf("Expected nested object parameter, got %T", call.Parameters["meta"])
+	}
+}
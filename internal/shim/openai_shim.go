@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/satishgonella2024/sentinelstacks/internal/multimodal"
+	"github.com/satishgonella2024/sentinelstacks/internal/tools"
 	"github.com/satishgonella2024/sentinelstacks/internal/shim/openai"
 )
 
@@ -165,6 +166,30 @@ func (s *OpenAIShim) StreamMultimodalCompletion(ctx context.Context, input *mult
 	return resultCh, nil
 }
 
+// CompletionWithTools runs a turn of a tool-calling conversation using
+// OpenAI function calling
+func (s *OpenAIShim) CompletionWithTools(ctx context.Context, messages []tools.Message, toolSchemas []tools.Schema, maxTokens int, temperature float64) (*tools.ChatResponse, error) {
+	if s.openai == nil {
+		return TextToolCompletion(ctx, s, messages, toolSchemas, maxTokens, temperature)
+	}
+	
+	// Set parameters for this request
+	if maxTokens > 0 {
+		s.openai.MaxTokens = maxTokens
+	}
+	
+	if temperature > 0 {
+		s.openai.Temperature = temperature
+	}
+	
+	return s.openai.ChatWithTools(ctx, withSystemPrompt(messages, s.systemPrompt), toolSchemas)
+}
+
+// SupportsTools returns whether OpenAI calls tools natively
+func (s *OpenAIShim) SupportsTools() bool {
+	return s.openai != nil
+}
+
 // SetSystemPrompt sets the system prompt for OpenAI
 func (s *OpenAIShim) SetSystemPrompt(prompt string) {
 	s.systemPrompt = prompt
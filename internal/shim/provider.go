@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"os"
 	"strings"
-	"time"
 )
 
 // Provider constants
@@ -94,14 +93,6 @@ func CreateShimFromEnv() (LLMShim, error) {
 	endpoint := GetEndpointFromEnv(provider)
 	apiKey := GetAPIKeyFromEnv()
 	
-	// Create config
-	config := Config{
-		Provider: provider,
-		Model:    model,
-		APIKey:   apiKey,
-		Endpoint: endpoint,
-	}
-	
 	// Create shim
 	return ShimFactory(provider, endpoint, apiKey, model)
 }
@@ -227,6 +218,7 @@ Your task is to extract information from the Sentinelfile and output a valid JSO
 - baseModel: The LLM model to use (string, required)
 - capabilities: List of capabilities the agent should have (array of strings, optional)
 - tools: List of tools the agent should have access to (array of strings, optional)
+- mcpServers: MCP servers whose tools the agent uses, each with a name and either command and args (stdio servers) or url (HTTP servers) (array of objects, optional)
 - stateSchema: Description of the state the agent should maintain (object, optional)
 - parameters: Configuration parameters for the agent (object, optional)
 - lifecycle: Object containing initialization and termination behaviors (object, optional)
@@ -240,6 +232,7 @@ Parse the provided Sentinelfile text into a JSON object with these fields:
 - baseModel: LLM model to use (string, required)
 - capabilities: List of agent capabilities (array of strings, optional)
 - tools: List of required tools (array of strings, optional)
+- mcpServers: MCP servers whose tools the agent uses, each with a name and either command and args (stdio servers) or url (HTTP servers) (array of objects, optional)
 - stateSchema: State schema definition (object, optional)
 - parameters: Configuration parameters (object, optional)
 - lifecycle: Initialization and termination behaviors (object, optional)
@@ -256,6 +249,7 @@ Extract information from the Sentinelfile into a JSON object with these fields:
 - baseModel: LLM model to use (string, required)
 - capabilities: List of agent capabilities (array of strings, optional)
 - tools: List of required tools (array of strings, optional)
+- mcpServers: MCP servers whose tools the agent uses, each with a name and either command and args (stdio servers) or url (HTTP servers) (array of objects, optional)
 - stateSchema: State schema definition (object, optional)
 - parameters: Configuration parameters (object, optional)
 - lifecycle: Initialization and termination behaviors (object, optional)
@@ -275,18 +269,6 @@ func CreateShimWithDefaultConfig(provider string) (LLMShim, error) {
 	endpoint := DefaultEndpoints[provider]
 	apiKey := GetAPIKeyFromEnv()
 	
-	// Set default timeout
-	timeout := 60 * time.Second
-	
-	// Create config
-	config := Config{
-		Provider: provider,
-		Model:    model,
-		APIKey:   apiKey,
-		Endpoint: endpoint,
-		Timeout:  timeout,
-	}
-	
 	// Create shim
 	shim, err := ShimFactory(provider, endpoint, apiKey, model)
 	if err != nil {
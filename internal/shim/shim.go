@@ -2,12 +2,11 @@ package shim
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"strings"
 	"time"
 
 	"github.com/satishgonella2024/sentinelstacks/internal/multimodal"
+	"github.com/satishgonella2024/sentinelstacks/internal/tools"
 )
 
 // Config represents configuration for an LLM provider
@@ -33,6 +32,12 @@ type LLMShim interface {
 	StreamCompletion(ctx context.Context, prompt string, maxTokens int, temperature float64) (<-chan string, error)
 	StreamMultimodalCompletion(ctx context.Context, input *multimodal.Input) (<-chan *multimodal.Chunk, error)
 	
+	// Tool calling: the model may answer with structured tool calls, whose
+	// results are sent back as tool messages. Shims without native support
+	// fall back to describing the tools in the prompt.
+	CompletionWithTools(ctx context.Context, messages []tools.Message, toolSchemas []tools.Schema, maxTokens int, temperature float64) (*tools.ChatResponse, error)
+	SupportsTools() bool
+	
 	// System prompts
 	SetSystemPrompt(prompt string)
 	
@@ -186,6 +191,16 @@ func (s *MockShim) StreamMultimodalCompletion(ctx context.Context, input *multim
 	return ch, nil
 }
 
+// CompletionWithTools mocks tool calling through the text fallback
+func (s *MockShim) CompletionWithTools(ctx context.Context, messages []tools.Message, toolSchemas []tools.Schema, maxTokens int, temperature float64) (*tools.ChatResponse, error) {
+	return TextToolCompletion(ctx, s, messages, toolSchemas, maxTokens, temperature)
+}
+
+// SupportsTools returns whether the mock calls tools natively
+func (s *MockShim) SupportsTools() bool {
+	return false
+}
+
 // SetSystemPrompt sets the system prompt for the model
 func (s *MockShim) SetSystemPrompt(prompt string) {
 	s.systemPrompt = prompt
@@ -269,4 +269,77 @@ func ParseFunctionCallFromLLMResponse(response string) (*tools.FunctionCall, err
 	}
 	
 	return nil, fmt.Errorf("no function call found in response")
-}
\ No newline at end of file
+}
+// TextToolCompletion runs a turn of a tool-calling conversation with a
+// model that cannot call tools natively. The tools and the conversation so
+// far are written into the prompt, and a call to one of the tools is
+// scraped from the reply.
+func TextToolCompletion(ctx context.Context, llm LLMShim, messages []tools.Message, toolSchemas []tools.Schema, maxTokens int, temperature float64) (*tools.ChatResponse, error) {
+	var prompt strings.Builder
+	for _, message := range messages {
+		if message.Role == tools.RoleSystem {
+			prompt.WriteString(message.Content)
+			prompt.WriteString("\n\n")
+		}
+	}
+	prompt.WriteString(generateToolsDescription(toolSchemas))
+
+	calls := 0
+	for _, message := range messages {
+		switch message.Role {
+		case tools.RoleUser:
+			prompt.WriteString(fmt.Sprintf("User: %s\n\n", message.Content))
+		case tools.RoleAssistant:
+			if message.Content != "" {
+				prompt.WriteString(fmt.Sprintf("Assistant: %s\n\n", message.Content))
+			}
+			for _, call := range message.ToolCalls {
+				params, _ := json.Marshal(call.Parameters)
+				prompt.WriteString(fmt.Sprintf("Assistant called:\n```json\n{\"name\": %q, \"parameters\": %s}\n```\n\n", call.Name, params))
+				calls++
+			}
+		case tools.RoleTool:
+			prompt.WriteString(fmt.Sprintf("Function %s returned:\n```json\n%s\n```\n\n", message.Name, message.Content))
+		}
+	}
+	prompt.WriteString("Assistant:")
+
+	text, err := llm.CompletionWithContext(ctx, prompt.String(), maxTokens, temperature)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &tools.ChatResponse{Content: text}
+
+	// Only calls to declared tools count, so prose that happens to look
+	// like a call is left alone
+	call, err := ParseFunctionCallFromLLMResponse(text)
+	if err == nil && declaresTool(toolSchemas, call.Name) {
+		call.ID = fmt.Sprintf("call_%d", calls)
+		if call.Parameters == nil {
+			call.Parameters = make(map[string]interface{})
+		}
+		response.ToolCalls = []tools.FunctionCall{*call}
+	}
+
+	return response, nil
+}
+
+// declaresTool reports whether a tool is among the schemas
+func declaresTool(toolSchemas []tools.Schema, name string) bool {
+	for _, schema := range toolSchemas {
+		if schema.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// withSystemPrompt prepends a shim's system prompt to a conversation that
+// has no system message of its own
+func withSystemPrompt(messages []tools.Message, systemPrompt string) []tools.Message {
+	if systemPrompt == "" || (len(messages) > 0 && messages[0].Role == tools.RoleSystem) {
+		return messages
+	}
+	return append([]tools.Message{{Role: tools.RoleSystem, Content: systemPrompt}}, messages...)
+}
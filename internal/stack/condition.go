@@ -0,0 +1,613 @@
+package stack
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Condition is a compiled `when` expression of an agent. Expressions compare
+// upstream outputs with literals, for example
+//
+//	classifier.output.label == "bug" && classifier.output.confidence >= 0.8
+//
+// References have the form <agent>.output[.<key> | [<index>] | [*]]... and resolve
+// against the outputs of upstream agents, looking into the structured reply
+// under data first. Supported operators are ==, !=, <,
+// <=, >, >=, && (and), || (or), ! (not) and parentheses. A bare reference is
+// true when it resolves to a non-empty value.
+type Condition struct {
+	source string
+	root   conditionNode
+	refs   []string
+	paths  []*referenceNode
+}
+
+// OutputLookup returns the outputs of an agent, or false if it has none
+type OutputLookup func(agentID string) (map[string]interface{}, bool)
+
+// ParseCondition compiles a `when` expression
+func ParseCondition(expression string) (*Condition, error) {
+	tokens, err := tokenizeCondition(expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid condition %q: %w", expression, err)
+	}
+
+	p := &conditionParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err == nil && p.peek().kind != tokenEOF {
+		err = fmt.Errorf("unexpected %q", p.peek().text)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid condition %q: %w", expression, err)
+	}
+
+	return &Condition{source: expression, root: root, refs: p.refs, paths: p.paths}, nil
+}
+
+// OutputReference is a compiled <agent>.output[.<key>]... reference
+type OutputReference struct {
+	source string
+	ref    *referenceNode
+}
+
+// ParseOutputReference compiles a reference to an agent's output
+func ParseOutputReference(expression string) (*OutputReference, error) {
+	tokens, err := tokenizeCondition(expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reference %q: %w", expression, err)
+	}
+
+	p := &conditionParser{tokens: tokens}
+	first := p.next()
+	if first.kind != tokenIdent {
+		return nil, fmt.Errorf("invalid reference %q: expected an agent ID", expression)
+	}
+	ref, err := p.parseReference(first.text)
+	if err == nil && p.peek().kind != tokenEOF {
+		err = fmt.Errorf("unexpected %q", p.peek().text)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid reference %q: %w", expression, err)
+	}
+
+	return &OutputReference{source: expression, ref: ref.(*referenceNode)}, nil
+}
+
+// AgentID returns the ID of the referenced agent
+func (r *OutputReference) AgentID() string {
+	return r.ref.agentID
+}
+
+// String returns the source expression
+func (r *OutputReference) String() string {
+	return r.source
+}
+
+// Resolve returns the referenced value, or nil if it does not exist
+func (r *OutputReference) Resolve(lookup OutputLookup) interface{} {
+	value, _ := r.ref.resolve(lookup)
+	return value
+}
+
+// Lookup returns the referenced value and whether it exists
+func (r *OutputReference) Lookup(lookup OutputLookup) (interface{}, bool) {
+	return r.ref.resolve(lookup)
+}
+
+// String returns the source expression
+func (c *Condition) String() string {
+	return c.source
+}
+
+// References returns the IDs of the agents the condition reads from
+func (c *Condition) References() []string {
+	return c.refs
+}
+
+// Evaluate evaluates the condition against upstream outputs. References to
+// agents without outputs, such as failed agents whose failure is tolerated,
+// resolve to null; references to paths missing from an available output are
+// an error.
+func (c *Condition) Evaluate(lookup OutputLookup) (bool, error) {
+	value, err := c.root.eval(lookup)
+	if err != nil {
+		return false, fmt.Errorf("failed to evaluate condition %q: %w", c.source, err)
+	}
+	return truthy(value), nil
+}
+
+// Token kinds produced by tokenizeCondition
+const (
+	tokenEOF = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenOperator
+	tokenLParen
+	tokenRParen
+	tokenLBracket
+	tokenRBracket
+	tokenDot
+	tokenStar
+)
+
+type conditionToken struct {
+	kind int
+	text string
+}
+
+// tokenizeCondition splits an expression into tokens
+func tokenizeCondition(expression string) ([]conditionToken, error) {
+	tokens := []conditionToken{}
+	runes := []rune(expression)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case r == '"' || r == '\'':
+			var sb strings.Builder
+			j := i + 1
+			for ; j < len(runes) && runes[j] != r; j++ {
+				if runes[j] == '\\' && j+1 < len(runes) {
+					j++
+				}
+				sb.WriteRune(runes[j])
+			}
+			if j >= len(runes) {
+				return nil, fmt.Errorf("unterminated string at position %d", i)
+			}
+			tokens = append(tokens, conditionToken{kind: tokenString, text: sb.String()})
+			i = j + 1
+
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			j := i + 1
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, conditionToken{kind: tokenNumber, text: string(runes[i:j])})
+			i = j
+
+		case unicode.IsLetter(r) || r == '_':
+			j := i + 1
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_' || runes[j] == '-') {
+				j++
+			}
+			tokens = append(tokens, conditionToken{kind: tokenIdent, text: string(runes[i:j])})
+			i = j
+
+		case r == '(':
+			tokens = append(tokens, conditionToken{kind: tokenLParen, text: "("})
+			i++
+		case r == ')':
+			tokens = append(tokens, conditionToken{kind: tokenRParen, text: ")"})
+			i++
+		case r == '[':
+			tokens = append(tokens, conditionToken{kind: tokenLBracket, text: "["})
+			i++
+		case r == ']':
+			tokens = append(tokens, conditionToken{kind: tokenRBracket, text: "]"})
+			i++
+		case r == '.':
+			tokens = append(tokens, conditionToken{kind: tokenDot, text: "."})
+			i++
+		case r == '*':
+			tokens = append(tokens, conditionToken{kind: tokenStar, text: "*"})
+			i++
+
+		default:
+			op := ""
+			for _, candidate := range []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "!"} {
+				if strings.HasPrefix(string(runes[i:]), candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character %q at position %d", r, i)
+			}
+			tokens = append(tokens, conditionToken{kind: tokenOperator, text: op})
+			i += len(op)
+		}
+	}
+
+	return append(tokens, conditionToken{kind: tokenEOF}), nil
+}
+
+// conditionParser is a recursive descent parser for condition expressions
+type conditionParser struct {
+	tokens []conditionToken
+	pos    int
+	refs   []string
+	paths  []*referenceNode
+}
+
+func (p *conditionParser) peek() conditionToken {
+	return p.tokens[p.pos]
+}
+
+func (p *conditionParser) next() conditionToken {
+	token := p.tokens[p.pos]
+	if token.kind != tokenEOF {
+		p.pos++
+	}
+	return token
+}
+
+// isOperator reports whether the next token is one of the given operators,
+// accepting the keywords and, or and not as aliases
+func (p *conditionParser) isOperator(ops ...string) bool {
+	token := p.peek()
+	text := token.text
+	if token.kind == tokenIdent {
+		switch text {
+		case "and":
+			text = "&&"
+		case "or":
+			text = "||"
+		case "not":
+			text = "!"
+		default:
+			return false
+		}
+	} else if token.kind != tokenOperator {
+		return false
+	}
+
+	for _, op := range ops {
+		if text == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *conditionParser) parseOr() (conditionNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("||") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{op: "||", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *conditionParser) parseAnd() (conditionNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("&&") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{op: "&&", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *conditionParser) parseNot() (conditionNode, error) {
+	if p.isOperator("!") {
+		p.next()
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *conditionParser) parseComparison() (conditionNode, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	if p.isOperator("==", "!=", "<", "<=", ">", ">=") {
+		op := p.next().text
+		right, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		return &comparisonNode{op: op, left: left, right: right}, nil
+	}
+	return left, nil
+}
+
+func (p *conditionParser) parsePrimary() (conditionNode, error) {
+	token := p.next()
+
+	switch token.kind {
+	case tokenLParen:
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next().kind != tokenRParen {
+			return nil, fmt.Errorf("missing closing parenthesis")
+		}
+		return inner, nil
+
+	case tokenString:
+		return &literalNode{value: token.text}, nil
+
+	case tokenNumber:
+		number, err := strconv.ParseFloat(token.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", token.text)
+		}
+		return &literalNode{value: number}, nil
+
+	case tokenIdent:
+		switch token.text {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		case "null", "nil":
+			return &literalNode{value: nil}, nil
+		}
+		return p.parseReference(token.text)
+
+	case tokenEOF:
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+
+	return nil, fmt.Errorf("unexpected %q", token.text)
+}
+
+// parseReference parses the remainder of an <agent>.output... reference
+func (p *conditionParser) parseReference(agentID string) (conditionNode, error) {
+	if p.next().kind != tokenDot || p.next().text != "output" {
+		return nil, fmt.Errorf("reference to %s must have the form %s.output[.key]", agentID, agentID)
+	}
+
+	ref := &referenceNode{agentID: agentID}
+	for {
+		switch p.peek().kind {
+		case tokenDot:
+			p.next()
+			key := p.next()
+			switch key.kind {
+			case tokenIdent:
+				ref.path = append(ref.path, pathSegment{kind: segmentKey, key: key.text})
+			case tokenStar:
+				ref.path = append(ref.path, pathSegment{kind: segmentWildcard})
+			default:
+				return nil, fmt.Errorf("expected key after '.' in reference to %s", agentID)
+			}
+
+		case tokenLBracket:
+			p.next()
+			index := p.next()
+			switch index.kind {
+			case tokenNumber:
+				n, err := strconv.Atoi(index.text)
+				if err != nil {
+					return nil, fmt.Errorf("invalid index %s in reference to %s", index.text, agentID)
+				}
+				ref.path = append(ref.path, pathSegment{kind: segmentIndex, index: n})
+			case tokenString:
+				ref.path = append(ref.path, pathSegment{kind: segmentKey, key: index.text})
+			case tokenStar:
+				ref.path = append(ref.path, pathSegment{kind: segmentWildcard})
+			default:
+				return nil, fmt.Errorf("expected index, key or * inside [] in reference to %s", agentID)
+			}
+			if p.next().kind != tokenRBracket {
+				return nil, fmt.Errorf("missing ']' in reference to %s", agentID)
+			}
+
+		default:
+			p.addRef(agentID)
+			p.paths = append(p.paths, ref)
+			return ref, nil
+		}
+	}
+}
+
+func (p *conditionParser) addRef(agentID string) {
+	for _, ref := range p.refs {
+		if ref == agentID {
+			return
+		}
+	}
+	p.refs = append(p.refs, agentID)
+}
+
+// conditionNode is a node of a compiled condition expression
+type conditionNode interface {
+	eval(lookup OutputLookup) (interface{}, error)
+}
+
+type literalNode struct {
+	value interface{}
+}
+
+func (n *literalNode) eval(lookup OutputLookup) (interface{}, error) {
+	return n.value, nil
+}
+
+type referenceNode struct {
+	agentID string
+	path    []pathSegment
+}
+
+func (n *referenceNode) eval(lookup OutputLookup) (interface{}, error) {
+	if _, ok := lookup(n.agentID); !ok {
+		return nil, nil
+	}
+	value, ok := n.resolve(lookup)
+	if !ok {
+		return nil, fmt.Errorf("%s not found in the output of agent %s", n, n.agentID)
+	}
+	return value, nil
+}
+
+// resolve returns the referenced value and whether it exists
+func (n *referenceNode) resolve(lookup OutputLookup) (interface{}, bool) {
+	outputs, ok := lookup(n.agentID)
+	if !ok {
+		return nil, false
+	}
+	return resolveOutput(outputs, n.path)
+}
+
+// String returns the reference in expression syntax
+func (n *referenceNode) String() string {
+	return n.agentID + ".output" + formatPath(n.path)
+}
+
+type notNode struct {
+	operand conditionNode
+}
+
+func (n *notNode) eval(lookup OutputLookup) (interface{}, error) {
+	value, err := n.operand.eval(lookup)
+	if err != nil {
+		return nil, err
+	}
+	return !truthy(value), nil
+}
+
+type logicalNode struct {
+	op          string
+	left, right conditionNode
+}
+
+func (n *logicalNode) eval(lookup OutputLookup) (interface{}, error) {
+	left, err := n.left.eval(lookup)
+	if err != nil {
+		return nil, err
+	}
+
+	// Short-circuit like the equivalent Go operators
+	if n.op == "&&" && !truthy(left) {
+		return false, nil
+	}
+	if n.op == "||" && truthy(left) {
+		return true, nil
+	}
+
+	right, err := n.right.eval(lookup)
+	if err != nil {
+		return nil, err
+	}
+	return truthy(right), nil
+}
+
+type comparisonNode struct {
+	op          string
+	left, right conditionNode
+}
+
+func (n *comparisonNode) eval(lookup OutputLookup) (interface{}, error) {
+	left, err := n.left.eval(lookup)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(lookup)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return valuesEqual(left, right), nil
+	case "!=":
+		return !valuesEqual(left, right), nil
+	}
+
+	// Ordering comparisons need two numbers or two strings; anything else,
+	// such as null, makes the comparison false
+	if l, ok := toFloat(left); ok {
+		if r, ok := toFloat(right); ok {
+			return compareOrdered(n.op, l < r, l == r), nil
+		}
+	}
+	if l, ok := left.(string); ok {
+		if r, ok := right.(string); ok {
+			return compareOrdered(n.op, l < r, l == r), nil
+		}
+	}
+	return false, nil
+}
+
+// compareOrdered applies an ordering operator given less and equal results
+func compareOrdered(op string, less, equal bool) bool {
+	switch op {
+	case "<":
+		return less
+	case "<=":
+		return less || equal
+	case ">":
+		return !less && !equal
+	default:
+		return !less
+	}
+}
+
+// valuesEqual compares two values, treating all numeric types alike
+func valuesEqual(a, b interface{}) bool {
+	if af, ok := toFloat(a); ok {
+		bf, ok := toFloat(b)
+		return ok && af == bf
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// toFloat converts numeric values to float64
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// truthy reports whether a value counts as true: non-empty strings,
+// collections and non-zero numbers are true, null is false
+func truthy(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	case []interface{}:
+		return len(v) > 0
+	case map[string]interface{}:
+		return len(v) > 0
+	}
+	if f, ok := toFloat(value); ok {
+		return f != 0
+	}
+	return true
+}
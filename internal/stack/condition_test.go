@@ -0,0 +1,164 @@
+package stack
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// staticLookup returns an OutputLookup over fixed agent outputs
+func staticLookup(outputs map[string]map[string]interface{}) OutputLookup {
+	return func(agentID string) (map[string]interface{}, bool) {
+		output, ok := outputs[agentID]
+		return output, ok
+	}
+}
+
+func TestConditionEvaluate(t *testing.T) {
+	lookup := staticLookup(map[string]map[string]interface{}{
+		"classifier": {
+			"text": `{"label": "bug", "confidence": 0.92, "tags": ["ui", "crash"]}`,
+			"data": map[string]interface{}{
+				"label":      "bug",
+				"confidence": 0.92,
+				"tags":       []interface{}{"ui", "crash"},
+			},
+			"model": "test-model",
+		},
+		"counter": {"count": 3, "empty": ""},
+	})
+
+	tests := []struct {
+		expression string
+		expected   bool
+		expectErr  string
+	}{
+		{expression: `classifier.output.label == "bug"`, expected: true},
+		{expression: `classifier.output.data.label == "bug"`, expected: true},
+		{expression: `classifier.output.model == "test-model"`, expected: true},
+		{expression: `classifier.output.confidence >= 0.8 && classifier.output.label != "feature"`, expected: true},
+		{expression: `classifier.output.tags[0] == "ui" and classifier.output.tags[-1] == "crash"`, expected: true},
+		{expression: `not (counter.output.count > 5) || false`, expected: true},
+		{expression: `counter.output.count == 3.0`, expected: true},
+		{expression: `counter.output.count < "3"`, expected: false},
+		{expression: `counter.output.empty`, expected: false},
+		{expression: `classifier.output.tags`, expected: true},
+		// Agents without outputs read as null
+		{expression: `failed.output.label == null`, expected: true},
+		{expression: `failed.output.label`, expected: false},
+		// Paths missing from an available output are errors
+		{expression: `classifier.output.severity == "high"`, expectErr: "classifier.output.severity not found in the output of agent classifier"},
+		{expression: `classifier.output.tags[5]`, expectErr: "not found"},
+		// Short-circuiting skips the missing path
+		{expression: `classifier.output.label == "feature" && classifier.output.severity > 2`, expected: false},
+	}
+
+	for _, tt := range tests {
+		condition, err := ParseCondition(tt.expression)
+		if err != nil {
+			t.Fatalf("ParseCondition(%q): %v", tt.expression, err)
+		}
+
+		met, err := condition.Evaluate(lookup)
+		if tt.expectErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.expectErr) {
+				t.Errorf("Evaluate(%q): expected an error containing %q, got %v", tt.expression, tt.expectErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Evaluate(%q): %v", tt.expression, err)
+			continue
+		}
+		if met != tt.expected {
+			t.Errorf("Evaluate(%q) = %v, expected %v", tt.expression, met, tt.expected)
+		}
+	}
+}
+
+func TestParseConditionErrors(t *testing.T) {
+	for _, expression := range []string{
+		`classifier.label == "bug"`,
+		`classifier.output.label ==`,
+		`(classifier.output.label == "bug"`,
+		`classifier.output.label == "bug`,
+		`classifier.output[`,
+		`classifier.output.label # 1`,
+	} {
+		if _, err := ParseCondition(expression); err == nil {
+			t.Errorf("ParseCondition(%q) succeeded", expression)
+		}
+	}
+
+	condition, err := ParseCondition(`a.output.x == 1 || b.output.y || a.output.z`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refs := condition.References(); len(refs) != 2 || refs[0] != "a" || refs[1] != "b" {
+		t.Errorf("Expected references [a b], got %v", refs)
+	}
+}
+
+func TestConditionalAgents(t *testing.T) {
+	classify := func(label string) func(context.Context, int, map[string]interface{}) (map[string]interface{}, error) {
+		return func(context.Context, int, map[string]interface{}) (map[string]interface{}, error) {
+			return map[string]interface{}{
+				"text": `{"label": "` + label + `"}`,
+				"data": map[string]interface{}{"label": label},
+			}, nil
+		}
+	}
+
+	tests := []struct {
+		name      string
+		when      string
+		label     string
+		expectErr string
+		statuses  map[string]AgentStatus
+	}{
+		{
+			name:     "condition met",
+			when:     `classifier.output.label == "bug"`,
+			label:    "bug",
+			statuses: map[string]AgentStatus{"triage": AgentStatusCompleted, "notify": AgentStatusCompleted},
+		},
+		{
+			name:     "condition not met skips the branch",
+			when:     `classifier.output.label == "bug"`,
+			label:    "question",
+			statuses: map[string]AgentStatus{"triage": AgentStatusSkipped, "notify": AgentStatusSkipped},
+		},
+		{
+			name:      "unresolved path fails the agent",
+			when:      `classifier.output.priority == "high"`,
+			label:     "bug",
+			expectErr: "classifier.output.priority not found",
+			statuses:  map[string]AgentStatus{"triage": AgentStatusFailed, "notify": AgentStatusSkipped},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runtime := newFakeRuntime().on("classifier", classify(tt.label))
+			engine := newTestEngine(t, StackSpec{Agents: []StackAgentSpec{
+				{ID: "classifier", Uses: "test"},
+				{ID: "triage", Uses: "test", When: tt.when},
+				{ID: "notify", Uses: "test", InputFrom: []string{"triage"}},
+			}}, runtime)
+
+			err := engine.Execute(context.Background())
+			switch {
+			case tt.expectErr == "" && err != nil:
+				t.Fatalf("Execute failed: %v", err)
+			case tt.expectErr != "" && (err == nil || !strings.Contains(err.Error(), tt.expectErr)):
+				t.Fatalf("Expected an error containing %q, got %v", tt.expectErr, err)
+			}
+
+			for agentID, expected := range tt.statuses {
+				if status := agentStatus(t, engine, agentID); status != expected {
+					t.Errorf("Expected %s to be %s, got %s", agentID, expected, status)
+				}
+			}
+		})
+	}
+}
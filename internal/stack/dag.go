@@ -12,6 +12,10 @@ type Node struct {
 	Dependencies []*Node
 	AgentSpec   StackAgentSpec
 	State       AgentStatus
+	// Condition is the compiled `when` expression, nil if the agent always runs
+	Condition   *Condition
+	// ForEach is the list the agent is expanded over, nil for regular agents
+	ForEach     *OutputReference
 }
 
 // DAG represents a directed acyclic graph of agents
@@ -51,6 +55,10 @@ func NewDAG(spec StackSpec) (*DAG, error) {
 		dag.Nodes[agentSpec.ID] = node
 	}
 
+	if err := ValidateOutputReferences(spec); err != nil {
+		return nil, err
+	}
+
 	// Connect nodes based on dependencies
 	for _, agentSpec := range spec.Agents {
 		node := dag.Nodes[agentSpec.ID]
@@ -90,6 +98,63 @@ func NewDAG(spec StackSpec) (*DAG, error) {
 				inputNode.Dependents = append(inputNode.Dependents, node)
 			}
 		}
+
+		// Agents referenced by the 'when' condition must run first
+		if agentSpec.When != "" {
+			condition, err := ParseCondition(agentSpec.When)
+			if err != nil {
+				return nil, fmt.Errorf("agent %s: %w", agentSpec.ID, err)
+			}
+			node.Condition = condition
+
+			for _, refID := range condition.References() {
+				refNode, exists := dag.Nodes[refID]
+				if !exists {
+					return nil, fmt.Errorf("agent %s has a condition on non-existent agent %s", agentSpec.ID, refID)
+				}
+				if refID == agentSpec.ID {
+					return nil, fmt.Errorf("agent %s has a condition on its own output", agentSpec.ID)
+				}
+				if !node.dependsDirectlyOn(refID) {
+					node.Dependencies = append(node.Dependencies, refNode)
+					refNode.Dependents = append(refNode.Dependents, node)
+				}
+			}
+		}
+
+		// Agents referenced by the 'inputs' mappings must run first
+		for _, value := range agentSpec.Inputs {
+			mapping, _ := ParseInputMapping(value)
+			for _, refID := range mapping.References() {
+				if !node.dependsDirectlyOn(refID) {
+					refNode := dag.Nodes[refID]
+					node.Dependencies = append(node.Dependencies, refNode)
+					refNode.Dependents = append(refNode.Dependents, node)
+				}
+			}
+		}
+
+		// The agent producing a forEach list must run first
+		if agentSpec.ForEach != "" {
+			forEach, err := ParseOutputReference(agentSpec.ForEach)
+			if err != nil {
+				return nil, fmt.Errorf("agent %s has an invalid forEach: %w", agentSpec.ID, err)
+			}
+			node.ForEach = forEach
+
+			sourceID := forEach.AgentID()
+			sourceNode, exists := dag.Nodes[sourceID]
+			if !exists {
+				return nil, fmt.Errorf("agent %s iterates over non-existent agent %s", agentSpec.ID, sourceID)
+			}
+			if sourceID == agentSpec.ID {
+				return nil, fmt.Errorf("agent %s cannot iterate over its own output", agentSpec.ID)
+			}
+			if !node.dependsDirectlyOn(sourceID) {
+				node.Dependencies = append(node.Dependencies, sourceNode)
+				sourceNode.Dependents = append(sourceNode.Dependents, node)
+			}
+		}
 	}
 
 	// Detect cycles in the graph
@@ -97,6 +162,17 @@ func NewDAG(spec StackSpec) (*DAG, error) {
 		return nil, errors.New("cycle detected in agent dependencies - DAG must be acyclic")
 	}
 
+	// Validate fallback references now that the graph is known to be acyclic
+	if err := validateFailurePolicies(dag); err != nil {
+		return nil, err
+	}
+	if err := validateRetryPolicies(dag); err != nil {
+		return nil, err
+	}
+	if err := validateOutputSchemas(dag); err != nil {
+		return nil, err
+	}
+
 	// Find start nodes (nodes with no dependencies)
 	for _, node := range dag.Nodes {
 		if len(node.Dependencies) == 0 {
@@ -111,6 +187,44 @@ func NewDAG(spec StackSpec) (*DAG, error) {
 	return dag, nil
 }
 
+// clone returns a copy of the graph that can be extended during a run
+// without affecting the original
+func (d *DAG) clone() *DAG {
+	c := &DAG{Nodes: make(map[string]*Node, len(d.Nodes))}
+
+	for id, node := range d.Nodes {
+		nodeCopy := *node
+		nodeCopy.Dependencies = nil
+		nodeCopy.Dependents = nil
+		c.Nodes[id] = &nodeCopy
+	}
+
+	for id, node := range d.Nodes {
+		for _, dep := range node.Dependencies {
+			c.Nodes[id].Dependencies = append(c.Nodes[id].Dependencies, c.Nodes[dep.ID])
+		}
+		for _, dep := range node.Dependents {
+			c.Nodes[id].Dependents = append(c.Nodes[id].Dependents, c.Nodes[dep.ID])
+		}
+	}
+
+	for _, node := range d.StartNodes {
+		c.StartNodes = append(c.StartNodes, c.Nodes[node.ID])
+	}
+
+	return c
+}
+
+// dependsDirectlyOn reports whether the node has a direct dependency on id
+func (n *Node) dependsDirectlyOn(id string) bool {
+	for _, dep := range n.Dependencies {
+		if dep.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
 // hasCycle checks if the DAG contains a cycle
 func hasCycle(dag *DAG) bool {
 	// Create a map to track visited nodes
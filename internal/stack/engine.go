@@ -9,8 +9,11 @@ import (
 	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/satishgonella2024/sentinelstacks/internal/memory"
+	"github.com/satishgonella2024/sentinelstacks/internal/shim"
 	stackmemory "github.com/satishgonella2024/sentinelstacks/internal/stack/memory"
+	"github.com/satishgonella2024/sentinelstacks/internal/tools/mcp"
 	pkgRuntime "github.com/satishgonella2024/sentinelstacks/pkg/runtime"
 	"github.com/satishgonella2024/sentinelstacks/pkg/types"
 	stackTypes "github.com/satishgonella2024/sentinelstacks/pkg/types"
@@ -27,7 +30,22 @@ type StackEngine struct {
 	runID         string
 	isRunning     bool
 	verbose       bool
-	memoryFactory stackTypes.MemoryStoreFactory
+	memoryFactory memory.MemoryStoreFactory
+	failureCauses map[string]string
+
+	// stateStoreType selects the memory store that holds execution state
+	stateStoreType stackTypes.MemoryStoreType
+	// resumeRunID is the execution to resume instead of starting a new one
+	resumeRunID string
+	// resumedAgents holds agents completed by the resumed execution
+	resumedAgents     map[string]bool
+	persistentManager *stackmemory.PersistentStateManager
+
+	// events receives the engine's execution events, if set
+	events *EventBus
+	// agentRuntime executes every agent when set, instead of a runtime
+	// created per agent
+	agentRuntime types.AgentRuntime
 }
 
 // Create state manager adapter that implements the StateManager interface
@@ -81,6 +99,11 @@ func (a *stateManagerAdapter) Clear(agentID string) error {
 	return nil
 }
 
+func (a *stateManagerAdapter) AddAgents(agentIDs []string) error {
+	a.persistentManager.AddAgents(agentIDs)
+	return nil
+}
+
 func (a *stateManagerAdapter) GetStackSummary() *StackExecutionSummary {
 	pkgSummary := a.persistentManager.GetStackSummary()
 
@@ -93,10 +116,11 @@ func (a *stateManagerAdapter) GetStackSummary() *StackExecutionSummary {
 		CompletedCount: pkgSummary.CompletedCount,
 		FailedCount:    pkgSummary.FailedCount,
 		BlockedCount:   pkgSummary.BlockedCount,
+		SkippedCount:   pkgSummary.SkippedCount,
 		AgentStates:    make(map[string]AgentState),
 	}
 
-	// Convert agent states
+	// Convert agent states, with the attempt history kept in agent state
 	for id, state := range pkgSummary.AgentStates {
 		agentState := AgentState{
 			ID:           state.ID,
@@ -105,6 +129,9 @@ func (a *stateManagerAdapter) GetStackSummary() *StackExecutionSummary {
 			StartTime:    state.StartTime.Unix(),
 			EndTime:      state.EndTime.Unix(),
 		}
+		if values, err := a.persistentManager.GetAll(id); err == nil {
+			agentState.Attempts = AttemptsFromState(values)
+		}
 		summary.AgentStates[id] = agentState
 	}
 
@@ -123,7 +150,7 @@ func NewStackEngine(spec StackSpec, options ...EngineOption) (*StackEngine, erro
 	ctx, cancel := context.WithCancel(context.Background())
 
 	// Generate run ID
-	runID := fmt.Sprintf("run-%d", time.Now().Unix())
+	runID := "run-" + uuid.New().String()
 
 	// Create engine with defaults
 	engine := &StackEngine{
@@ -142,6 +169,11 @@ func NewStackEngine(spec StackSpec, options ...EngineOption) (*StackEngine, erro
 		option(engine)
 	}
 
+	// A resumed execution keeps the run ID it was started with
+	if engine.resumeRunID != "" {
+		engine.runID = engine.resumeRunID
+	}
+
 	// Create default memory factory if none provided
 	if engine.memoryFactory == nil {
 		factory, err := memory.NewMemoryStoreFactory("")
@@ -162,15 +194,28 @@ func NewStackEngine(spec StackSpec, options ...EngineOption) (*StackEngine, erro
 		ctx,
 		spec.Name,
 		engine.memoryFactory,
+		engine.stateStoreType,
 		spec.Name,
-		runID,
+		engine.runID,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create state manager: %w", err)
 	}
 
-	// Initialize state
-	persistentManager.InitializeAgents(agentIDs)
+	if engine.resumeRunID != "" {
+		if err := engine.restoreState(persistentManager); err != nil {
+			return nil, err
+		}
+	}
+
+	// Initialize state. A resumed execution keeps the agents it restored,
+	// including the instances of expanded forEach agents.
+	if engine.resumeRunID != "" {
+		persistentManager.AddAgents(agentIDs)
+	} else {
+		persistentManager.InitializeAgents(agentIDs)
+	}
+	engine.persistentManager = persistentManager
 
 	// Create adapter and set state manager
 	engine.stateManager = newStateManagerAdapter(persistentManager)
@@ -178,11 +223,84 @@ func NewStackEngine(spec StackSpec, options ...EngineOption) (*StackEngine, erro
 	return engine, nil
 }
 
+// restoreState loads the persisted state of the execution being resumed and
+// resets every agent that did not complete so it runs again
+func (e *StackEngine) restoreState(manager *stackmemory.PersistentStateManager) error {
+	if !manager.Restored() {
+		return fmt.Errorf("no saved state found for run %s", e.resumeRunID)
+	}
+
+	summary := manager.GetStackSummary()
+	if summary.StackName != e.spec.Name {
+		return fmt.Errorf("run %s belongs to stack %s, not %s", e.resumeRunID, summary.StackName, e.spec.Name)
+	}
+
+	// The stack definition must still contain every agent of the saved run
+	for _, agentID := range manager.AgentIDs() {
+		nodeID := agentID
+		if parentID, ok := parseInstanceID(agentID); ok {
+			nodeID = parentID
+		}
+		if _, exists := e.dag.Nodes[nodeID]; !exists {
+			return fmt.Errorf("cannot resume run %s: agent %s is no longer part of the stack", e.resumeRunID, agentID)
+		}
+	}
+
+	completed, err := manager.ResetIncompleteAgents()
+	if err != nil {
+		return fmt.Errorf("failed to prepare run %s for resume: %w", e.resumeRunID, err)
+	}
+
+	e.resumedAgents = make(map[string]bool, len(completed))
+	for _, agentID := range completed {
+		e.resumedAgents[agentID] = true
+	}
+
+	if e.verbose {
+		log.Printf("Resuming run %s: %d agents already completed %v", e.resumeRunID, len(completed), completed)
+	}
+
+	return nil
+}
+
+// RunID returns the identifier of the engine's execution, which can be
+// passed to WithResume to resume it later
+func (e *StackEngine) RunID() string {
+	return e.runID
+}
+
 // BuildExecutionGraph validates and returns the DAG for the stack
 func (e *StackEngine) BuildExecutionGraph() (*DAG, error) {
 	return e.dag, nil
 }
 
+// connectMCPServers connects the MCP servers declared by the stack and
+// registers their tools, returning the names of those connected. Servers
+// that fail to connect are skipped with a warning, leaving agents without
+// their tools.
+func (e *StackEngine) connectMCPServers(ctx context.Context) []string {
+	var connected []string
+	for _, server := range e.spec.MCPServers {
+		serverTools, err := mcp.GetManager().AddServer(ctx, server)
+		if err != nil {
+			log.Printf("Warning: Failed to connect MCP server %s: %v", server.Name, err)
+			continue
+		}
+		connected = append(connected, server.Name)
+		if e.verbose {
+			log.Printf("Connected MCP server %s with %d tools", server.Name, len(serverTools))
+		}
+	}
+	return connected
+}
+
+// disconnectMCPServers releases the servers connected for a run
+func (e *StackEngine) disconnectMCPServers(names []string) {
+	for _, name := range names {
+		mcp.GetManager().RemoveServer(name)
+	}
+}
+
 // Execute runs the stack with provided options
 func (e *StackEngine) Execute(ctx context.Context, options ...ExecuteOption) error {
 	e.mu.Lock()
@@ -193,18 +311,29 @@ func (e *StackEngine) Execute(ctx context.Context, options ...ExecuteOption) err
 	e.isRunning = true
 	e.mu.Unlock()
 
+	defer func() {
+		e.mu.Lock()
+		e.isRunning = false
+		e.mu.Unlock()
+	}()
+
 	// Apply execution options
 	execOptions := &ExecuteOptions{
 		Timeout:        0,
 		Input:          make(map[string]interface{}),
 		RuntimeOptions: make(map[string]interface{}),
 		RuntimeType:    "direct",
+		MaxParallelism: DefaultMaxParallelism,
 	}
 
 	for _, option := range options {
 		option(execOptions)
 	}
 
+	if execOptions.MaxParallelism < 1 {
+		execOptions.MaxParallelism = 1
+	}
+
 	// Set up context with cancellation
 	var execCtx context.Context
 	var cancel context.CancelFunc
@@ -214,121 +343,132 @@ func (e *StackEngine) Execute(ctx context.Context, options ...ExecuteOption) err
 	} else {
 		execCtx, cancel = context.WithCancel(ctx)
 	}
+	defer cancel()
 	e.cancel = cancel
 	e.ctx = execCtx
 
-	// Get execution order
-	executionOrder, err := e.dag.TopologicalSort()
-	if err != nil {
+	// Validate the graph before scheduling anything
+	if _, err := e.dag.TopologicalSort(); err != nil {
 		return fmt.Errorf("failed to determine execution order: %w", err)
 	}
 
-	// Create a map to track executed nodes
-	executedNodes := make(map[string]bool)
-
 	if e.verbose {
-		log.Printf("Starting stack execution: %s (Run ID: %s)", e.spec.Name, e.runID)
-		log.Printf("Execution order: %v", executionOrder)
+		log.Printf("Starting stack execution: %s (Run ID: %s, max parallelism: %d)",
+			e.spec.Name, e.runID, execOptions.MaxParallelism)
 	}
 
-	// Execute agents in order
-	for _, agentID := range executionOrder {
-		select {
-		case <-execCtx.Done():
-			if e.verbose {
-				log.Printf("Stack execution cancelled: %s", e.runID)
-			}
-			return execCtx.Err()
-		default:
-			// Continue execution
-		}
+	if err := e.prepareRunInput(execOptions); err != nil {
+		return err
+	}
 
-		// Get agent spec
-		var agentSpec StackAgentSpec
-		for _, a := range e.spec.Agents {
-			if a.ID == agentID {
-				agentSpec = a
-				break
-			}
-		}
+	defer e.disconnectMCPServers(e.connectMCPServers(execCtx))
 
-		// Set agent status to running
-		if err := e.stateManager.UpdateAgentStatus(agentID, AgentStatusRunning); err != nil {
-			if e.verbose {
-				log.Printf("Error updating agent status: %v", err)
-			}
-		}
+	startTime := time.Now()
+	e.publish(Event{Type: EventStackStarted, Time: startTime})
 
-		if e.verbose {
-			log.Printf("Executing agent: %s", agentID)
-		}
+	run := newExecutionRun(e, execOptions)
 
-		// Collect inputs from dependencies
-		inputs, err := e.collectInputs(agentSpec, execOptions.Input, executedNodes)
-		if err != nil {
-			if e.verbose {
-				log.Printf("Error collecting inputs for agent %s: %v", agentID, err)
-			}
-			e.stateManager.UpdateAgentStatus(agentID, AgentStatusFailed)
-			e.stateManager.Set(agentID, "error", fmt.Sprintf("Failed to collect inputs: %v", err))
-			continue
-		}
+	// Agents completed by a resumed execution keep their outputs
+	e.mu.Lock()
+	resumed := e.resumedAgents
+	e.resumedAgents = nil
+	e.mu.Unlock()
+	run.resume(resumed)
 
-		// Set agent inputs
-		if err := e.stateManager.Set(agentID, "input", inputs); err != nil {
-			if e.verbose {
-				log.Printf("Error setting agent inputs: %v", err)
-			}
-		}
+	err := run.execute(execCtx)
 
-		// Execute agent
-		outputs, err := e.executeAgent(execCtx, agentSpec, inputs, execOptions)
-		if err != nil {
-			if e.verbose {
-				log.Printf("Agent %s execution failed: %v", agentID, err)
-			}
-			e.stateManager.UpdateAgentStatus(agentID, AgentStatusFailed)
-			e.stateManager.Set(agentID, "error", fmt.Sprintf("Execution failed: %v", err))
-			continue
-		}
+	// Keep the reasons agents did not complete for GetState
+	e.mu.Lock()
+	e.failureCauses = run.causes
+	e.mu.Unlock()
 
-		// Set agent outputs
-		if err := e.stateManager.Set(agentID, "output", outputs); err != nil {
-			if e.verbose {
-				log.Printf("Error setting agent outputs: %v", err)
+	e.publishFinished(startTime, execCtx, err)
+
+	if err != nil {
+		if e.verbose {
+			if execCtx.Err() != nil {
+				log.Printf("Stack execution cancelled: %s", e.runID)
+			} else {
+				log.Printf("Stack execution completed with errors: %v", err)
 			}
 		}
+		return err
+	}
 
-		// Mark agent as completed
-		e.stateManager.UpdateAgentStatus(agentID, AgentStatusCompleted)
-		executedNodes[agentID] = true
+	if e.verbose {
+		log.Printf("Stack execution completed successfully: %s (Run ID: %s)", e.spec.Name, e.runID)
+	}
 
-		if e.verbose {
-			log.Printf("Agent completed: %s", agentID)
+	return nil
+}
+
+// publishFinished publishes the event that ends a run, with its final
+// status and agent counts
+func (e *StackEngine) publishFinished(startTime time.Time, execCtx context.Context, err error) {
+	if e.events == nil {
+		return
+	}
+
+	status := "completed"
+	switch {
+	case err != nil && execCtx.Err() != nil:
+		status = "cancelled"
+	case err != nil:
+		status = "failed"
+	}
+
+	event := Event{
+		Type:       EventStackFinished,
+		StartTime:  &startTime,
+		DurationMs: time.Since(startTime).Milliseconds(),
+		Status:     status,
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	if summary := e.stateManager.GetStackSummary(); summary != nil {
+		event.Summary = map[string]int{
+			"total":     summary.TotalAgents,
+			"completed": summary.CompletedCount,
+			"failed":    summary.FailedCount,
+			"skipped":   summary.SkippedCount,
 		}
 	}
 
-	// Check for any agents that didn't execute
-	summary := e.stateManager.GetStackSummary()
-	if summary.CompletedCount != summary.TotalAgents {
-		if e.verbose {
-			log.Printf("Stack execution completed with errors: %d/%d agents completed",
-				summary.CompletedCount, summary.TotalAgents)
+	e.publish(event)
+}
+
+// prepareRunInput persists the stack input so the run can be resumed, or
+// reuses the persisted input when a resumed run is given none
+func (e *StackEngine) prepareRunInput(execOptions *ExecuteOptions) error {
+	if e.persistentManager == nil {
+		return nil
+	}
+
+	if len(execOptions.Input) == 0 && e.resumedAgents != nil {
+		input, err := e.persistentManager.LoadRunInput()
+		if err != nil {
+			if e.verbose {
+				log.Printf("No saved input for run %s: %v", e.runID, err)
+			}
+			return nil
+		}
+		if input != nil {
+			execOptions.Input = input
 		}
-		return fmt.Errorf("stack execution completed with errors: %d/%d agents completed",
-			summary.CompletedCount, summary.TotalAgents)
+		return nil
 	}
 
-	if e.verbose {
-		log.Printf("Stack execution completed successfully: %s (Run ID: %s)", e.spec.Name, e.runID)
+	if err := e.persistentManager.SaveRunInput(execOptions.Input); err != nil {
+		return fmt.Errorf("failed to save stack input: %w", err)
 	}
-	e.isRunning = false
 
 	return nil
 }
 
-// collectInputs gathers inputs for an agent from its dependencies
-func (e *StackEngine) collectInputs(agentSpec StackAgentSpec, initialInput map[string]interface{}, executedNodes map[string]bool) (map[string]interface{}, error) {
+// collectInputs gathers inputs for an agent from its dependencies. Failed
+// dependencies listed in toleratedNodes are left out of the inputs.
+func (e *StackEngine) collectInputs(agentSpec StackAgentSpec, initialInput map[string]interface{}, executedNodes map[string]bool, toleratedNodes map[string]bool) (map[string]interface{}, error) {
 	inputs := make(map[string]interface{})
 
 	// Add initial inputs
@@ -351,7 +491,12 @@ func (e *StackEngine) collectInputs(agentSpec StackAgentSpec, initialInput map[s
 			continue
 		}
 
-		// Skip dependencies that haven't executed
+		// Skip dependencies whose failure the stack tolerates
+		if toleratedNodes[inputFrom] {
+			continue
+		}
+
+		// Fail on dependencies that haven't executed
 		if !executedNodes[inputFrom] {
 			return nil, fmt.Errorf("dependency %s has not executed yet", inputFrom)
 		}
@@ -381,9 +526,33 @@ func (e *StackEngine) collectInputs(agentSpec StackAgentSpec, initialInput map[s
 		inputs[inputFrom] = output
 	}
 
+	// Resolve the inputs mapping against completed upstream agents
+	if len(agentSpec.Inputs) > 0 {
+		if err := resolveInputMappings(agentSpec, e.outputLookup(executedNodes), inputs); err != nil {
+			return nil, err
+		}
+	}
+
 	return inputs, nil
 }
 
+// outputLookup returns an OutputLookup over the outputs of completed agents
+func (e *StackEngine) outputLookup(completed map[string]bool) OutputLookup {
+	return func(agentID string) (map[string]interface{}, bool) {
+		if !completed[agentID] {
+			return nil, false
+		}
+
+		value, err := e.stateManager.Get(agentID, "output")
+		if err != nil {
+			return nil, false
+		}
+
+		outputs, ok := value.(map[string]interface{})
+		return outputs, ok
+	}
+}
+
 // executeAgent runs a single agent with the given inputs
 func (e *StackEngine) executeAgent(ctx context.Context, agentSpec StackAgentSpec, inputs map[string]interface{}, options *ExecuteOptions) (map[string]interface{}, error) {
 	if e.verbose {
@@ -393,24 +562,26 @@ func (e *StackEngine) executeAgent(ctx context.Context, agentSpec StackAgentSpec
 	// Create a runtime adapter that wraps a pkg runtime
 	var runtime agentRuntime
 
-	// Create factory
-	factory := pkgRuntime.NewRuntimeFactory(e.verbose)
+	publicRuntime := e.agentRuntime
+	if publicRuntime == nil {
+		// Create factory
+		factory := pkgRuntime.NewRuntimeFactory(e.verbose, pkgRuntime.WithLLMFactory(newLLMClient))
 
-	// Get appropriate runtime
-	var publicRuntime types.AgentRuntime
-	var err error
-
-	switch options.RuntimeType {
-	case "direct":
-		publicRuntime, err = factory.CreateRuntime(types.RuntimeTypeDirect)
-	case "cli":
-		publicRuntime, err = factory.CreateRuntime(types.RuntimeTypeCli)
-	default:
-		publicRuntime, err = factory.DefaultRuntime()
-	}
+		// Get appropriate runtime
+		var err error
+		switch options.RuntimeType {
+		case "direct":
+			publicRuntime, err = factory.CreateRuntime(types.RuntimeTypeDirect)
+		case "cli":
+			publicRuntime, err = factory.CreateRuntime(types.RuntimeTypeCli)
+		default:
+			publicRuntime, err = factory.DefaultRuntime()
+		}
 
-	if err != nil {
-		return nil, fmt.Errorf("failed to create agent runtime: %w", err)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create agent runtime: %w", err)
+		}
+		defer publicRuntime.Cleanup()
 	}
 
 	// Create adapter that converts between types
@@ -419,8 +590,6 @@ func (e *StackEngine) executeAgent(ctx context.Context, agentSpec StackAgentSpec
 		spec:    agentSpec,
 	}
 
-	defer runtime.Cleanup()
-
 	// Execute the agent using the adapter
 	outputs, err := runtime.Execute(ctx, agentSpec, inputs)
 	if err != nil {
@@ -430,6 +599,11 @@ func (e *StackEngine) executeAgent(ctx context.Context, agentSpec StackAgentSpec
 	return outputs, nil
 }
 
+// newLLMClient creates the LLM shim the direct runtime uses to call models
+func newLLMClient(provider, endpoint, apiKey, model string) (types.CompletionClient, error) {
+	return shim.ShimFactory(provider, endpoint, apiKey, model)
+}
+
 // runtimeAdapter adapts pkg runtime to internal types
 type runtimeAdapter struct {
 	runtime types.AgentRuntime
@@ -458,13 +632,24 @@ func (e *StackEngine) Stop() {
 	if e.cancel != nil {
 		e.cancel()
 	}
-	e.isRunning = false
 	log.Printf("Stack execution stopped: %s (Run ID: %s)", e.spec.Name, e.runID)
 }
 
 // GetState returns the current state of the stack execution
 func (e *StackEngine) GetState() *StackExecutionSummary {
-	return e.stateManager.GetStackSummary()
+	summary := e.stateManager.GetStackSummary()
+
+	// Fill in why agents did not complete where the state manager has no message
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for id, cause := range e.failureCauses {
+		if state, ok := summary.AgentStates[id]; ok && state.ErrorMessage == "" {
+			state.ErrorMessage = cause
+			summary.AgentStates[id] = state
+		}
+	}
+
+	return summary
 }
 
 // GetAgentState returns the current state of an agent
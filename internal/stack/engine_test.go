@@ -0,0 +1,280 @@
+package stack
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/memory"
+	"github.com/satishgonella2024/sentinelstacks/pkg/types"
+)
+
+// fakeRuntime executes agents without a model. Agents return their ID as
+// "result" unless a behaviour is registered for them or, for forEach
+// instances, for the agent they expand.
+type fakeRuntime struct {
+	mu         sync.Mutex
+	delay      time.Duration
+	behaviours map[string]func(ctx context.Context, call int, inputs map[string]interface{}) (map[string]interface{}, error)
+	calls      map[string]int
+	inputs     map[string]map[string]interface{}
+	params     map[string][]map[string]interface{}
+	order      []string
+	running    int
+	maxRunning int
+}
+
+func newFakeRuntime() *fakeRuntime {
+	return &fakeRuntime{
+		behaviours: make(map[string]func(context.Context, int, map[string]interface{}) (map[string]interface{}, error)),
+		calls:      make(map[string]int),
+		inputs:     make(map[string]map[string]interface{}),
+		params:     make(map[string][]map[string]interface{}),
+	}
+}
+
+// on registers the behaviour of an agent; call counts from 1
+func (f *fakeRuntime) on(agentID string, behaviour func(ctx context.Context, call int, inputs map[string]interface{}) (map[string]interface{}, error)) *fakeRuntime {
+	f.behaviours[agentID] = behaviour
+	return f
+}
+
+// fail makes an agent fail on every call
+func (f *fakeRuntime) fail(agentID string) *fakeRuntime {
+	return f.on(agentID, func(context.Context, int, map[string]interface{}) (map[string]interface{}, error) {
+		return nil, fmt.Errorf("%s failed", agentID)
+	})
+}
+
+func (f *fakeRuntime) Execute(ctx context.Context, spec types.StackAgentSpec, inputs map[string]interface{}) (map[string]interface{}, error) {
+	f.mu.Lock()
+	f.calls[spec.ID]++
+	call := f.calls[spec.ID]
+	f.inputs[spec.ID] = inputs
+	f.params[spec.ID] = append(f.params[spec.ID], spec.With)
+	f.order = append(f.order, spec.ID)
+	f.running++
+	if f.running > f.maxRunning {
+		f.maxRunning = f.running
+	}
+	behaviour, ok := f.behaviours[spec.ID]
+	if !ok {
+		if parentID, isInstance := parseInstanceID(spec.ID); isInstance {
+			behaviour = f.behaviours[parentID]
+		}
+	}
+	f.mu.Unlock()
+
+	defer func() {
+		f.mu.Lock()
+		f.running--
+		f.mu.Unlock()
+	}()
+
+	if f.delay > 0 {
+		select {
+		case <-time.After(f.delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	if behaviour != nil {
+		return behaviour(ctx, call, inputs)
+	}
+	return map[string]interface{}{"result": spec.ID}, nil
+}
+
+func (f *fakeRuntime) Cleanup() error {
+	return nil
+}
+
+func (f *fakeRuntime) callCount(agentID string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[agentID]
+}
+
+func (f *fakeRuntime) lastInputs(agentID string) map[string]interface{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.inputs[agentID]
+}
+
+// newTestEngine creates an engine over spec that executes agents with
+// runtime and keeps its state in memory
+func newTestEngine(t *testing.T, spec StackSpec, runtime *fakeRuntime, options ...EngineOption) *StackEngine {
+	t.Helper()
+	if spec.Name == "" {
+		spec.Name = t.Name()
+	}
+	options = append([]EngineOption{WithAgentRuntime(runtime)}, options...)
+	engine, err := NewStackEngine(spec, options...)
+	if err != nil {
+		t.Fatalf("NewStackEngine failed: %v", err)
+	}
+	return engine
+}
+
+// agentStatus returns the status of an agent after a run
+func agentStatus(t *testing.T, engine *StackEngine, agentID string) AgentStatus {
+	t.Helper()
+	state, ok := engine.GetState().AgentStates[agentID]
+	if !ok {
+		t.Fatalf("No state for agent %s", agentID)
+	}
+	return state.Status
+}
+
+// agentOutput returns the outputs an agent stored
+func agentOutput(t *testing.T, engine *StackEngine, agentID string) map[string]interface{} {
+	t.Helper()
+	value, err := engine.stateManager.Get(agentID, "output")
+	if err != nil {
+		t.Fatalf("No output for agent %s: %v", agentID, err)
+	}
+	outputs, _ := value.(map[string]interface{})
+	return outputs
+}
+
+func TestSchedulerRespectsMaxParallelism(t *testing.T) {
+	var agents []StackAgentSpec
+	for i := 0; i < 6; i++ {
+		agents = append(agents, StackAgentSpec{ID: fmt.Sprintf("worker-%d", i), Uses: "test"})
+	}
+	agents = append(agents, StackAgentSpec{ID: "summary", Uses: "test", Depends: []string{"worker-0", "worker-5"}})
+
+	tests := []struct {
+		name           string
+		maxParallelism int
+		expectedMax    int
+	}{
+		{"sequential", 1, 1},
+		{"bounded", 2, 2},
+		{"below one", 0, 1},
+		{"wider than the stack", 10, 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runtime := newFakeRuntime()
+			runtime.delay = 20 * time.Millisecond
+			engine := newTestEngine(t, StackSpec{Agents: agents}, runtime)
+
+			if err := engine.Execute(context.Background(), WithMaxParallelism(tt.maxParallelism)); err != nil {
+				t.Fatalf("Execute failed: %v", err)
+			}
+			if runtime.maxRunning != tt.expectedMax {
+				t.Errorf("Expected at most %d agents at once, saw %d", tt.expectedMax, runtime.maxRunning)
+			}
+			for _, agent := range agents {
+				if n := runtime.callCount(agent.ID); n != 1 {
+					t.Errorf("Agent %s ran %d times", agent.ID, n)
+				}
+			}
+			if last := runtime.order[len(runtime.order)-1]; last != "summary" {
+				t.Errorf("Expected summary to run last, ran %v", runtime.order)
+			}
+		})
+	}
+}
+
+func TestSchedulerPassesOutputsDownstream(t *testing.T) {
+	runtime := newFakeRuntime()
+	engine := newTestEngine(t, StackSpec{Agents: []StackAgentSpec{
+		{ID: "research", Uses: "test"},
+		{ID: "review", Uses: "test", Params: map[string]interface{}{"tone": "brief"}},
+		{ID: "write", Uses: "test", InputFrom: []string{"research", "review"}},
+	}}, runtime)
+
+	if err := engine.Execute(context.Background(), WithInput(map[string]interface{}{"topic": "dags"})); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	inputs := runtime.lastInputs("write")
+	if inputs["topic"] != "dags" {
+		t.Errorf("Expected the stack input to reach write, got %v", inputs)
+	}
+	research, _ := inputs["research"].(map[string]interface{})
+	if research["result"] != "research" {
+		t.Errorf("Expected the research output in write's inputs, got %v", inputs["research"])
+	}
+	if runtime.lastInputs("review")["tone"] != "brief" {
+		t.Errorf("Expected params in review's inputs, got %v", runtime.lastInputs("review"))
+	}
+
+	summary := engine.GetState()
+	if summary.TotalAgents != 3 || summary.CompletedCount != 3 {
+		t.Errorf("Expected 3/3 agents completed, got %d/%d", summary.CompletedCount, summary.TotalAgents)
+	}
+}
+
+func TestSchedulerRejectsCycles(t *testing.T) {
+	_, err := NewStackEngine(StackSpec{Name: "cycle", Agents: []StackAgentSpec{
+		{ID: "a", Uses: "test", Depends: []string{"b"}},
+		{ID: "b", Uses: "test", Depends: []string{"a"}},
+	}}, WithAgentRuntime(newFakeRuntime()))
+	if err == nil {
+		t.Error("Expected a cyclic stack to be rejected")
+	}
+}
+
+func TestRunIDsAreUnique(t *testing.T) {
+	spec := StackSpec{Name: "ids", Agents: []StackAgentSpec{{ID: "a", Uses: "test"}}}
+	seen := make(map[string]bool)
+	for i := 0; i < 20; i++ {
+		engine := newTestEngine(t, spec, newFakeRuntime())
+		if seen[engine.RunID()] {
+			t.Fatalf("Run ID %s was generated twice", engine.RunID())
+		}
+		seen[engine.RunID()] = true
+	}
+}
+
+func TestResumeKeepsForEachInstances(t *testing.T) {
+	spec := StackSpec{Name: "resume", Agents: []StackAgentSpec{
+		{ID: "list", Uses: "test"},
+		{ID: "mapper", Uses: "test", ForEach: "list.output.items"},
+		{ID: "final", Uses: "test", InputFrom: []string{"mapper"}},
+	}}
+	runtime := newFakeRuntime().
+		on("list", func(context.Context, int, map[string]interface{}) (map[string]interface{}, error) {
+			return map[string]interface{}{"items": []interface{}{"a", "b", "c"}}, nil
+		}).
+		on("final", failFirst(1, fmt.Errorf("model unavailable")))
+
+	// State stores are shared through the factory, as a durable store would
+	factory := memory.NewDefaultFactory()
+	first := newTestEngine(t, spec, runtime, WithMemoryFactory(factory))
+	if err := first.Execute(context.Background()); err == nil {
+		t.Fatal("Expected the first run to fail")
+	}
+
+	resumed := newTestEngine(t, spec, runtime, WithMemoryFactory(factory), WithResume(first.RunID()))
+	if resumed.RunID() != first.RunID() {
+		t.Errorf("Expected the resumed run to keep ID %s, got %s", first.RunID(), resumed.RunID())
+	}
+
+	// The restored instances keep their state before the run starts
+	summary := resumed.GetState()
+	if summary.TotalAgents != 6 {
+		t.Errorf("Expected 6 agents including the forEach instances, got %d", summary.TotalAgents)
+	}
+	for i := 0; i < 3; i++ {
+		id := instanceID("mapper", i)
+		if status := agentStatus(t, resumed, id); status != AgentStatusCompleted {
+			t.Errorf("Expected restored instance %s to be completed, got %s", id, status)
+		}
+	}
+
+	if err := resumed.Execute(context.Background()); err != nil {
+		t.Fatalf("Resumed run failed: %v", err)
+	}
+	for agentID, expected := range map[string]int{"list": 1, "mapper[0]": 1, "mapper[1]": 1, "mapper[2]": 1, "final": 2} {
+		if n := runtime.callCount(agentID); n != expected {
+			t.Errorf("Expected %s to run %d times, ran %d", agentID, expected, n)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package stack
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// EventType identifies a stack execution event
+type EventType string
+
+const (
+	// EventStackStarted is published when a run begins
+	EventStackStarted EventType = "stack.started"
+	// EventAgentStarted is published when an agent is handed to a worker
+	EventAgentStarted EventType = "agent.started"
+	// EventAgentCompleted is published when an agent produced its output
+	EventAgentCompleted EventType = "agent.completed"
+	// EventAgentFailed is published when an agent failed
+	EventAgentFailed EventType = "agent.failed"
+	// EventAgentRetry is published when a failed attempt is about to be retried
+	EventAgentRetry EventType = "agent.retry"
+	// EventAgentSkipped is published when an agent will not run
+	EventAgentSkipped EventType = "agent.skipped"
+	// EventStackFinished is published when a run ends, successfully or not
+	EventStackFinished EventType = "stack.finished"
+)
+
+const (
+	// eventBufferSize is how many undelivered events a subscriber may queue
+	eventBufferSize = 256
+
+	// maxRetainedRuns is how many runs' event histories a bus keeps
+	maxRetainedRuns = 32
+)
+
+// Event describes something that happened during a stack run
+type Event struct {
+	Type    EventType `json:"type"`
+	RunID   string    `json:"runId"`
+	Stack   string    `json:"stack"`
+	AgentID string    `json:"agentId,omitempty"`
+	// Seq numbers the events of a run from 1
+	Seq  int       `json:"seq"`
+	Time time.Time `json:"time"`
+	// StartTime is when the agent or run started, for events that end one
+	StartTime *time.Time `json:"startTime,omitempty"`
+	// DurationMs is how long the agent or run took, for events that end one
+	DurationMs int64 `json:"durationMs,omitempty"`
+	// Attempt is the failed attempt, for retry events
+	Attempt int `json:"attempt,omitempty"`
+	// RetryInMs is the backoff before the next attempt, for retry events
+	RetryInMs int64  `json:"retryInMs,omitempty"`
+	Error     string `json:"error,omitempty"`
+	// Reason explains why an agent was skipped
+	Reason string `json:"reason,omitempty"`
+	// Status is the final status of a run: completed, failed or cancelled
+	Status string `json:"status,omitempty"`
+	// Summary holds the agent counts of a finished run
+	Summary map[string]int `json:"summary,omitempty"`
+}
+
+// Finished reports whether the event ends a run
+func (ev Event) Finished() bool {
+	return ev.Type == EventStackFinished
+}
+
+// EventBus distributes stack execution events to subscribers. It keeps the
+// events of recent runs so subscribers that join late see a run from the
+// start.
+type EventBus struct {
+	mu          sync.Mutex
+	subscribers map[*eventSubscription]bool
+	history     map[string][]Event
+	// runs holds run IDs in the order they first published an event
+	runs []string
+}
+
+// eventSubscription is a subscriber's queue of events for one run, or for
+// every run if runID is empty
+type eventSubscription struct {
+	runID  string
+	events chan Event
+}
+
+// NewEventBus creates an empty event bus
+func NewEventBus() *EventBus {
+	return &EventBus{
+		subscribers: make(map[*eventSubscription]bool),
+		history:     make(map[string][]Event),
+	}
+}
+
+// Publish numbers an event within its run, records it and delivers it to
+// subscribers. Publishing never blocks; a subscriber whose queue is full
+// misses the event.
+func (b *EventBus) Publish(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, exists := b.history[event.RunID]; !exists {
+		b.runs = append(b.runs, event.RunID)
+		b.evictRuns()
+	}
+	event.Seq = len(b.history[event.RunID]) + 1
+	b.history[event.RunID] = append(b.history[event.RunID], event)
+
+	for sub := range b.subscribers {
+		if sub.runID != "" && sub.runID != event.RunID {
+			continue
+		}
+
+		select {
+		case sub.events <- event:
+		default:
+			log.Printf("Dropping %s event of run %s for a slow subscriber", event.Type, event.RunID)
+		}
+
+		// A run subscription ends with the run
+		if sub.runID != "" && event.Finished() {
+			close(sub.events)
+			delete(b.subscribers, sub)
+		}
+	}
+}
+
+// Subscribe returns the events of a run, starting with those already
+// published, followed by new ones as they happen. The channel is closed
+// after the run finishes or when the returned cancel function is called.
+// An empty runID subscribes to new events of every run.
+func (b *EventBus) Subscribe(runID string) (<-chan Event, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	past := b.history[runID]
+	if runID == "" {
+		past = nil
+	}
+
+	sub := &eventSubscription{
+		runID:  runID,
+		events: make(chan Event, len(past)+eventBufferSize),
+	}
+	for _, event := range past {
+		sub.events <- event
+	}
+
+	if len(past) > 0 && past[len(past)-1].Finished() {
+		close(sub.events)
+		return sub.events, func() {}
+	}
+
+	b.subscribers[sub] = true
+
+	cancel := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if b.subscribers[sub] {
+			delete(b.subscribers, sub)
+			close(sub.events)
+		}
+	}
+
+	return sub.events, cancel
+}
+
+// History returns the events published so far for a run
+func (b *EventBus) History(runID string) []Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	events := make([]Event, len(b.history[runID]))
+	copy(events, b.history[runID])
+	return events
+}
+
+// HasRun reports whether the bus has seen events of a run
+func (b *EventBus) HasRun(runID string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	_, exists := b.history[runID]
+	return exists
+}
+
+// evictRuns forgets the oldest finished runs beyond maxRetainedRuns
+func (b *EventBus) evictRuns() {
+	for i := 0; len(b.runs) > maxRetainedRuns && i < len(b.runs); {
+		runID := b.runs[i]
+		events := b.history[runID]
+		if len(events) > 0 && !events[len(events)-1].Finished() {
+			i++
+			continue
+		}
+		delete(b.history, runID)
+		b.runs = append(b.runs[:i], b.runs[i+1:]...)
+	}
+}
+
+// publish sends an engine event for the current run if an event bus is set
+func (e *StackEngine) publish(event Event) {
+	if e.events == nil {
+		return
+	}
+	event.RunID = e.runID
+	event.Stack = e.spec.Name
+	e.events.Publish(event)
+}
+
+// publishAgentEnd publishes an event that ends an agent, with its timings
+func (r *executionRun) publishAgentEnd(event Event) {
+	if started, ok := r.startedAt[event.AgentID]; ok {
+		now := time.Now()
+		event.Time = now
+		event.StartTime = &started
+		event.DurationMs = now.Sub(started).Milliseconds()
+	}
+	r.engine.publish(event)
+}
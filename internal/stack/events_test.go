@@ -0,0 +1,191 @@
+package stack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// eventTypes returns the types of the events about an agent, or about the
+// run itself for an empty agentID
+func eventTypes(events []Event, agentID string) []EventType {
+	var types []EventType
+	for _, event := range events {
+		if event.AgentID == agentID {
+			types = append(types, event.Type)
+		}
+	}
+	return types
+}
+
+func TestRunEvents(t *testing.T) {
+	runtime := newFakeRuntime().
+		on("fetch", failFirst(1, errors.New("connection reset"))).
+		fail("summarize")
+	bus := NewEventBus()
+	engine := newTestEngine(t, StackSpec{Agents: []StackAgentSpec{
+		{ID: "fetch", Uses: "test", Retry: &RetryPolicy{Max: 1, Backoff: Duration(time.Millisecond)}},
+		{ID: "summarize", Uses: "test", InputFrom: []string{"fetch"}},
+		{ID: "publish", Uses: "test", InputFrom: []string{"summarize"}},
+	}}, runtime, WithEventBus(bus))
+
+	if err := engine.Execute(context.Background()); err == nil {
+		t.Fatal("Expected the run to fail")
+	}
+
+	events := bus.History(engine.RunID())
+	for i, event := range events {
+		if event.Seq != i+1 {
+			t.Errorf("Expected event %d to have seq %d, got %d", i, i+1, event.Seq)
+		}
+		if event.RunID != engine.RunID() || event.Stack != engine.spec.Name {
+			t.Errorf("Expected event %d to belong to run %s, got %s of %s", i, engine.RunID(), event.RunID, event.Stack)
+		}
+	}
+
+	expected := map[string][]EventType{
+		"":          {EventStackStarted, EventStackFinished},
+		"fetch":     {EventAgentStarted, EventAgentRetry, EventAgentCompleted},
+		"summarize": {EventAgentStarted, EventAgentFailed},
+		"publish":   {EventAgentSkipped},
+	}
+	for agentID, types := range expected {
+		if got := eventTypes(events, agentID); !reflect.DeepEqual(got, types) {
+			t.Errorf("Expected events %v for %q, got %v", types, agentID, got)
+		}
+	}
+
+	if first := events[0]; first.Type != EventStackStarted {
+		t.Errorf("Expected the run to start with %s, got %s", EventStackStarted, first.Type)
+	}
+	last := events[len(events)-1]
+	if !last.Finished() || last.Status != "failed" || last.Error == "" || last.StartTime == nil {
+		t.Errorf("Expected the run to end with a failed %s event, got %+v", EventStackFinished, last)
+	}
+	if summary := map[string]int{"total": 3, "completed": 1, "failed": 1, "skipped": 1}; !reflect.DeepEqual(last.Summary, summary) {
+		t.Errorf("Expected summary %v, got %v", summary, last.Summary)
+	}
+
+	for _, event := range events {
+		switch event.Type {
+		case EventAgentRetry:
+			if event.Attempt != 1 || event.RetryInMs != 1 || !strings.Contains(event.Error, "connection reset") {
+				t.Errorf("Unexpected retry event %+v", event)
+			}
+		case EventAgentCompleted, EventAgentFailed:
+			if event.StartTime == nil || event.Time.Before(*event.StartTime) {
+				t.Errorf("Expected %s of %s to carry its start time, got %+v", event.Type, event.AgentID, event)
+			}
+		case EventAgentSkipped:
+			if event.Reason == "" {
+				t.Errorf("Expected a reason for skipping %s", event.AgentID)
+			}
+		}
+	}
+}
+
+func TestRunEventsWhenCancelled(t *testing.T) {
+	bus := NewEventBus()
+	engine := newTestEngine(t, StackSpec{Agents: []StackAgentSpec{
+		{ID: "slow", Uses: "test"},
+	}}, newFakeRuntime().on("slow", hangFirst(1)), WithEventBus(bus))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := engine.Execute(ctx); err == nil {
+		t.Fatal("Expected the run to be cancelled")
+	}
+
+	events := bus.History(engine.RunID())
+	if last := events[len(events)-1]; !last.Finished() || last.Status != "cancelled" {
+		t.Errorf("Expected a cancelled %s event, got %+v", EventStackFinished, last)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	bus := NewEventBus()
+	bus.Publish(Event{Type: EventStackStarted, RunID: "run"})
+	bus.Publish(Event{Type: EventAgentStarted, RunID: "run", AgentID: "a"})
+
+	events, cancel := bus.Subscribe("run")
+	defer cancel()
+	all, cancelAll := bus.Subscribe("")
+	defer cancelAll()
+
+	bus.Publish(Event{Type: EventStackStarted, RunID: "other"})
+	bus.Publish(Event{Type: EventStackFinished, RunID: "run"})
+
+	var seqs []int
+	for event := range events {
+		if event.RunID != "run" {
+			t.Errorf("Received an event of run %s", event.RunID)
+		}
+		seqs = append(seqs, event.Seq)
+	}
+	if !reflect.DeepEqual(seqs, []int{1, 2, 3}) {
+		t.Errorf("Expected the history followed by new events, got seqs %v", seqs)
+	}
+
+	// Subscribers to every run only receive new events
+	for _, runID := range []string{"other", "run"} {
+		select {
+		case event := <-all:
+			if event.RunID != runID {
+				t.Errorf("Expected an event of run %s, got %s", runID, event.RunID)
+			}
+		default:
+			t.Fatalf("Expected an event of run %s", runID)
+		}
+	}
+
+	// Subscribing to a finished run replays it and closes the channel
+	replayed, _ := bus.Subscribe("run")
+	count := 0
+	for range replayed {
+		count++
+	}
+	if count != 3 {
+		t.Errorf("Expected 3 replayed events, got %d", count)
+	}
+}
+
+func TestSubscribeCancel(t *testing.T) {
+	bus := NewEventBus()
+	events, cancel := bus.Subscribe("run")
+	cancel()
+	cancel()
+
+	if _, ok := <-events; ok {
+		t.Error("Expected the channel to be closed")
+	}
+	bus.Publish(Event{Type: EventStackStarted, RunID: "run"})
+}
+
+func TestEventBusEvictsFinishedRuns(t *testing.T) {
+	bus := NewEventBus()
+	bus.Publish(Event{Type: EventStackStarted, RunID: "running"})
+	for i := 0; i < maxRetainedRuns+5; i++ {
+		runID := fmt.Sprintf("run-%d", i)
+		bus.Publish(Event{Type: EventStackStarted, RunID: runID})
+		bus.Publish(Event{Type: EventStackFinished, RunID: runID})
+	}
+
+	if !bus.HasRun("running") {
+		t.Error("Expected an unfinished run to be kept")
+	}
+	for i := 0; i < 6; i++ {
+		if runID := fmt.Sprintf("run-%d", i); bus.HasRun(runID) {
+			t.Errorf("Expected %s to be evicted", runID)
+		}
+	}
+	if !bus.HasRun(fmt.Sprintf("run-%d", maxRetainedRuns+4)) {
+		t.Error("Expected the newest run to be kept")
+	}
+	if len(bus.runs) != maxRetainedRuns {
+		t.Errorf("Expected %d retained runs, got %d", maxRetainedRuns, len(bus.runs))
+	}
+}
@@ -0,0 +1,490 @@
+package stack
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// agentResult carries the outcome of a single agent execution back to the scheduler
+type agentResult struct {
+	agentID string
+	outputs map[string]interface{}
+	err     error
+}
+
+// executionRun holds the scheduling state of a single Execute call. All of
+// its fields are only touched by the scheduling goroutine; workers report
+// back through the results and attempts channels.
+type executionRun struct {
+	engine  *StackEngine
+	options *ExecuteOptions
+	// dag is a copy of the engine's graph that forEach expansion extends
+	dag *DAG
+
+	// completed holds agents whose outputs are available to dependents
+	completed map[string]bool
+	// finished holds every agent that has reached a terminal state
+	finished map[string]bool
+	// running holds agents currently owned by a worker
+	running map[string]bool
+	// tolerated holds failed agents whose dependents still run
+	tolerated map[string]bool
+
+	// fallbackFor maps an activated fallback agent to the agent it replaces
+	fallbackFor map[string]string
+	// fallbackOnly holds agents that only run as another agent's fallback
+	fallbackOnly map[string]bool
+	// pendingFallback holds failed agents waiting for their fallback to report back
+	pendingFallback map[string]bool
+
+	// causes records why each agent that did not complete stopped
+	causes map[string]string
+
+	// abortedBy is the fail-fast agent that aborted the run, if any
+	abortedBy    string
+	cancelAgents context.CancelFunc
+
+	// attemptHistory records every execution attempt per agent
+	attemptHistory map[string][]AttemptRecord
+
+	// expanded maps each expanded forEach agent to its instances in order
+	expanded map[string][]string
+	// items holds the list item each forEach instance runs on
+	items map[string]forEachItem
+	// resumed holds agents completed by a previous run of a resumed execution
+	resumed map[string]bool
+	// startedAt records when each agent started, for event timings
+	startedAt map[string]time.Time
+
+	results  chan agentResult
+	attempts chan attemptReport
+}
+
+// newExecutionRun prepares the scheduling state for a run of the engine's DAG
+func newExecutionRun(e *StackEngine, options *ExecuteOptions) *executionRun {
+	r := &executionRun{
+		engine:          e,
+		options:         options,
+		dag:             e.dag.clone(),
+		completed:       make(map[string]bool),
+		finished:        make(map[string]bool),
+		running:         make(map[string]bool),
+		tolerated:       make(map[string]bool),
+		fallbackFor:     make(map[string]string),
+		fallbackOnly:    make(map[string]bool),
+		pendingFallback: make(map[string]bool),
+		causes:          make(map[string]string),
+		attemptHistory:  make(map[string][]AttemptRecord),
+		expanded:        make(map[string][]string),
+		items:           make(map[string]forEachItem),
+		resumed:         make(map[string]bool),
+		startedAt:       make(map[string]time.Time),
+		results:         make(chan agentResult),
+		attempts:        make(chan attemptReport),
+	}
+
+	for _, node := range r.dag.Nodes {
+		if policy := node.AgentSpec.OnFailure; policy.Action == FailureActionFallback {
+			r.fallbackOnly[policy.Fallback] = true
+		}
+	}
+
+	return r
+}
+
+// resume marks agents completed by a previous run of the execution as done,
+// so their outputs are reused instead of executing them again
+func (r *executionRun) resume(completed map[string]bool) {
+	for agentID := range completed {
+		r.resumed[agentID] = true
+		r.completed[agentID] = true
+		r.finished[agentID] = true
+	}
+}
+
+// execute schedules every ready agent onto a bounded pool of workers until
+// no more agents can run, then reports agents that did not complete
+func (r *executionRun) execute(ctx context.Context) error {
+	agentCtx, cancelAgents := context.WithCancel(ctx)
+	defer cancelAgents()
+	r.cancelAgents = cancelAgents
+
+	for {
+		// Dispatch every ready node while there is worker capacity
+		if r.canDispatch(ctx) {
+			for _, node := range r.readyNodes() {
+				if len(r.running) >= r.options.MaxParallelism {
+					break
+				}
+				r.startAgent(agentCtx, node.AgentSpec)
+			}
+		}
+
+		if len(r.running) == 0 {
+			// Failed input collection may have unblocked further nodes
+			if r.canDispatch(ctx) && len(r.readyNodes()) > 0 {
+				continue
+			}
+			break
+		}
+
+		// Wait for a worker to report an attempt or its final result
+		select {
+		case report := <-r.attempts:
+			r.recordAttempt(report)
+		case result := <-r.results:
+			delete(r.running, result.agentID)
+			r.finishAgent(result)
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.skipRemaining()
+
+	return r.report()
+}
+
+// canDispatch reports whether new agents may still be started
+func (r *executionRun) canDispatch(ctx context.Context) bool {
+	return ctx.Err() == nil && r.abortedBy == ""
+}
+
+// readyNodes returns the nodes whose dependencies have all finished and which
+// are not already running, sorted by ID so scheduling is deterministic
+func (r *executionRun) readyNodes() []*Node {
+	ready := []*Node{}
+	for _, node := range r.dag.GetReadyNodes(r.finished) {
+		if r.running[node.ID] || r.pendingFallback[node.ID] {
+			continue
+		}
+		// Fallback agents wait until the agent they back up has failed
+		if r.fallbackOnly[node.ID] && r.fallbackFor[node.ID] == "" {
+			continue
+		}
+		ready = append(ready, node)
+	}
+
+	sort.Slice(ready, func(i, j int) bool {
+		return ready[i].ID < ready[j].ID
+	})
+
+	return ready
+}
+
+// startAgent prepares an agent's inputs and hands it to a worker goroutine
+func (r *executionRun) startAgent(ctx context.Context, agentSpec StackAgentSpec) {
+	e := r.engine
+	agentID := agentSpec.ID
+	node := r.dag.Nodes[agentID]
+
+	// An expanded forEach agent is ready again once all its instances finished
+	if instances, expanded := r.expanded[agentID]; expanded {
+		r.gatherInstances(agentID, instances)
+		return
+	}
+
+	// Agents whose condition is not met are skipped along with their dependents
+	if condition := node.Condition; condition != nil {
+		met, err := condition.Evaluate(e.outputLookup(r.completed))
+		if err != nil {
+			r.failAgent(agentID, err.Error())
+			return
+		}
+		if !met {
+			r.skipBranch(agentID, fmt.Sprintf("condition not met: %s", condition))
+			return
+		}
+	}
+
+	if node.ForEach != nil {
+		r.expandForEach(node)
+		return
+	}
+
+	// Set agent status to running
+	if err := e.stateManager.UpdateAgentStatus(agentID, AgentStatusRunning); err != nil {
+		if e.verbose {
+			log.Printf("Error updating agent status: %v", err)
+		}
+	}
+
+	if e.verbose {
+		log.Printf("Executing agent: %s", agentID)
+	}
+
+	// Collect inputs from dependencies
+	inputs, err := e.collectInputs(agentSpec, r.options.Input, r.completed, r.tolerated)
+	if err == nil {
+		err = r.addReplacedInputs(agentID, inputs)
+	}
+	if err != nil {
+		if e.verbose {
+			log.Printf("Error collecting inputs for agent %s: %v", agentID, err)
+		}
+		r.failAgent(agentID, fmt.Sprintf("Failed to collect inputs: %v", err))
+		return
+	}
+
+	// forEach instances receive their list item
+	if item, ok := r.items[agentID]; ok {
+		inputs["item"] = item.value
+		inputs["index"] = item.index
+	}
+
+	// Set agent inputs
+	if err := e.stateManager.Set(agentID, "input", inputs); err != nil {
+		if e.verbose {
+			log.Printf("Error setting agent inputs: %v", err)
+		}
+	}
+
+	r.running[agentID] = true
+	r.startedAt[agentID] = time.Now()
+	e.publish(Event{Type: EventAgentStarted, AgentID: agentID, Time: r.startedAt[agentID]})
+
+	go func() {
+		outputs, err := r.runAttempts(ctx, agentSpec, inputs)
+		r.results <- agentResult{agentID: agentID, outputs: outputs, err: err}
+	}()
+}
+
+// addReplacedInputs gives a fallback agent the inputs of the agent it replaces
+func (r *executionRun) addReplacedInputs(agentID string, inputs map[string]interface{}) error {
+	replaced := r.fallbackFor[agentID]
+	if replaced == "" {
+		return nil
+	}
+
+	value, err := r.engine.stateManager.Get(replaced, "input")
+	if err != nil {
+		return fmt.Errorf("failed to get inputs of replaced agent %s: %w", replaced, err)
+	}
+
+	replacedInputs, _ := value.(map[string]interface{})
+	for k, v := range replacedInputs {
+		if _, exists := inputs[k]; !exists {
+			inputs[k] = v
+		}
+	}
+
+	return nil
+}
+
+// finishAgent records the result of an agent execution
+func (r *executionRun) finishAgent(result agentResult) {
+	e := r.engine
+	agentID := result.agentID
+
+	if result.err != nil {
+		if e.verbose {
+			log.Printf("Agent %s execution failed: %v", agentID, result.err)
+		}
+
+		if r.abortedBy != "" {
+			// The failure is a consequence of the abort, not a new root cause
+			r.markFailed(agentID, fmt.Sprintf("cancelled after %s failed", r.abortedBy))
+			r.finished[agentID] = true
+			return
+		}
+
+		r.failAgent(agentID, fmt.Sprintf("Execution failed: %v", result.err))
+		return
+	}
+
+	r.completeAgent(agentID, result.outputs, true)
+
+	// A successful fallback stands in for the agent it replaces
+	if replaced := r.fallbackFor[agentID]; replaced != "" {
+		delete(r.pendingFallback, replaced)
+		e.stateManager.Set(replaced, "recoveredBy", agentID)
+		r.completeAgent(replaced, result.outputs, false)
+		delete(r.causes, replaced)
+
+		if e.verbose {
+			log.Printf("Agent %s recovered by fallback %s", replaced, agentID)
+		}
+	}
+}
+
+// completeAgent stores an agent's outputs and makes them available
+// downstream. Agents recovered by a fallback keep their failed status.
+func (r *executionRun) completeAgent(agentID string, outputs map[string]interface{}, markCompleted bool) {
+	e := r.engine
+
+	// Set agent outputs
+	if err := e.stateManager.Set(agentID, "output", outputs); err != nil {
+		if e.verbose {
+			log.Printf("Error setting agent outputs: %v", err)
+		}
+	}
+
+	if markCompleted {
+		e.stateManager.UpdateAgentStatus(agentID, AgentStatusCompleted)
+	}
+
+	r.completed[agentID] = true
+	r.finished[agentID] = true
+
+	if markCompleted {
+		r.publishAgentEnd(Event{Type: EventAgentCompleted, AgentID: agentID})
+	}
+
+	if e.verbose {
+		log.Printf("Agent completed: %s", agentID)
+	}
+}
+
+// markFailed records an agent failure in the state manager
+func (r *executionRun) markFailed(agentID, message string) {
+	e := r.engine
+	e.stateManager.UpdateAgentStatus(agentID, AgentStatusFailed)
+	e.stateManager.Set(agentID, "error", message)
+	r.causes[agentID] = "failed: " + message
+	r.publishAgentEnd(Event{Type: EventAgentFailed, AgentID: agentID, Error: message})
+}
+
+// failAgent records an agent failure and applies the agent's onFailure policy
+func (r *executionRun) failAgent(agentID, message string) {
+	e := r.engine
+	r.markFailed(agentID, message)
+
+	// A failed fallback leaves the agent it replaces unrecovered
+	if replaced := r.fallbackFor[agentID]; replaced != "" {
+		delete(r.pendingFallback, replaced)
+		r.finished[agentID] = true
+		r.finished[replaced] = true
+		r.causes[replaced] = fmt.Sprintf("%s; fallback %s also failed", r.causes[replaced], agentID)
+		r.skipDependents(replaced, replaced)
+		return
+	}
+
+	policy := r.dag.Nodes[agentID].AgentSpec.OnFailure
+
+	switch policy.EffectiveAction() {
+	case FailureActionFailFast:
+		r.finished[agentID] = true
+		r.abortedBy = agentID
+		if r.cancelAgents != nil {
+			r.cancelAgents()
+		}
+		if e.verbose {
+			log.Printf("Agent %s failed with fail-fast policy, aborting stack", agentID)
+		}
+
+	case FailureActionContinue:
+		r.finished[agentID] = true
+		r.tolerated[agentID] = true
+
+	case FailureActionFallback:
+		// The agent stays unfinished, but is not dispatched again, until its
+		// fallback reports back
+		r.fallbackFor[policy.Fallback] = agentID
+		r.pendingFallback[agentID] = true
+		if e.verbose {
+			log.Printf("Agent %s failed, running fallback %s", agentID, policy.Fallback)
+		}
+
+	default:
+		r.finished[agentID] = true
+		r.skipDependents(agentID, agentID)
+	}
+}
+
+// skipDependents marks every agent downstream of agentID as skipped
+func (r *executionRun) skipDependents(agentID, rootCause string) {
+	for _, dependent := range r.dag.Nodes[agentID].Dependents {
+		if r.finished[dependent.ID] || r.running[dependent.ID] {
+			continue
+		}
+		r.markSkipped(dependent.ID, fmt.Sprintf("upstream agent %s failed", rootCause))
+		r.skipDependents(dependent.ID, rootCause)
+	}
+}
+
+// markSkipped records that an agent will not run because of a failure
+func (r *executionRun) markSkipped(agentID, reason string) {
+	r.recordSkip(agentID, reason)
+	r.causes[agentID] = "skipped: " + reason
+}
+
+// skipBranch marks an agent whose condition is not met, and everything
+// downstream of it, as skipped. Such skips are expected and not errors.
+func (r *executionRun) skipBranch(agentID, reason string) {
+	r.recordSkip(agentID, reason)
+
+	for _, dependent := range r.dag.Nodes[agentID].Dependents {
+		if r.finished[dependent.ID] || r.running[dependent.ID] {
+			continue
+		}
+		r.skipBranch(dependent.ID, fmt.Sprintf("upstream agent %s was skipped", agentID))
+	}
+}
+
+// recordSkip sets an agent's status to skipped and stores the reason
+func (r *executionRun) recordSkip(agentID, reason string) {
+	e := r.engine
+	e.stateManager.UpdateAgentStatus(agentID, AgentStatusSkipped)
+	e.stateManager.Set(agentID, "skipReason", reason)
+	r.finished[agentID] = true
+	e.publish(Event{Type: EventAgentSkipped, AgentID: agentID, Reason: reason})
+
+	if e.verbose {
+		log.Printf("Agent %s skipped: %s", agentID, reason)
+	}
+}
+
+// skipRemaining marks agents that never became ready as skipped
+func (r *executionRun) skipRemaining() {
+	order, _ := r.dag.TopologicalSort()
+
+	for _, agentID := range order {
+		if r.finished[agentID] {
+			continue
+		}
+
+		switch {
+		case r.causes[agentID] != "":
+			// A failed agent whose fallback never got to run
+			delete(r.pendingFallback, agentID)
+			r.finished[agentID] = true
+			r.causes[agentID] += "; fallback " + r.dag.Nodes[agentID].AgentSpec.OnFailure.Fallback + " could not run"
+			r.skipDependents(agentID, agentID)
+		case r.fallbackOnly[agentID] && r.fallbackFor[agentID] == "":
+			// An unused fallback is expected and not an error
+			r.recordSkip(agentID, "fallback not needed")
+		case r.abortedBy != "":
+			r.markSkipped(agentID, fmt.Sprintf("stack aborted after %s failed", r.abortedBy))
+		default:
+			r.markSkipped(agentID, "dependencies did not complete")
+		}
+	}
+}
+
+// report returns an error listing the root cause for every agent that did
+// not complete, or nil if the stack succeeded
+func (r *executionRun) report() error {
+	if len(r.causes) == 0 {
+		return nil
+	}
+
+	summary := r.engine.stateManager.GetStackSummary()
+	order, _ := r.dag.TopologicalSort()
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("stack execution completed with errors: %d/%d agents completed",
+		summary.CompletedCount, summary.TotalAgents))
+	for _, agentID := range order {
+		if cause, ok := r.causes[agentID]; ok {
+			sb.WriteString(fmt.Sprintf("\n  - %s: %s", agentID, cause))
+		}
+	}
+
+	return fmt.Errorf("%s", sb.String())
+}
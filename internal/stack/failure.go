@@ -0,0 +1,197 @@
+package stack
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FailureAction defines what the engine does when an agent fails
+type FailureAction string
+
+const (
+	// FailureActionFailFast aborts the whole stack when the agent fails
+	FailureActionFailFast FailureAction = "fail-fast"
+	// FailureActionContinue tolerates the failure and still runs dependents
+	FailureActionContinue FailureAction = "continue"
+	// FailureActionSkipDependents skips every agent downstream of the failed agent
+	FailureActionSkipDependents FailureAction = "skip-dependents"
+	// FailureActionFallback runs another agent in place of the failed agent
+	FailureActionFallback FailureAction = "fallback"
+)
+
+// DefaultFailureAction is applied to agents that do not declare onFailure
+const DefaultFailureAction = FailureActionSkipDependents
+
+// FailurePolicy describes how a stack reacts to an agent failure. In a
+// Stackfile it is written as a string, e.g. "continue" or "fallback: backup".
+type FailurePolicy struct {
+	Action   FailureAction
+	Fallback string
+}
+
+// ParseFailurePolicy parses the textual form of a failure policy
+func ParseFailurePolicy(value string) (FailurePolicy, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return FailurePolicy{}, nil
+	}
+
+	if strings.HasPrefix(value, string(FailureActionFallback)) {
+		rest := strings.TrimSpace(strings.TrimPrefix(value, string(FailureActionFallback)))
+		if !strings.HasPrefix(rest, ":") {
+			return FailurePolicy{}, fmt.Errorf("invalid onFailure policy %q: fallback must name an agent, e.g. \"fallback: <agentID>\"", value)
+		}
+		agentID := strings.TrimSpace(strings.TrimPrefix(rest, ":"))
+		if agentID == "" {
+			return FailurePolicy{}, fmt.Errorf("invalid onFailure policy %q: fallback agent ID is empty", value)
+		}
+		return FailurePolicy{Action: FailureActionFallback, Fallback: agentID}, nil
+	}
+
+	switch action := FailureAction(value); action {
+	case FailureActionFailFast, FailureActionContinue, FailureActionSkipDependents:
+		return FailurePolicy{Action: action}, nil
+	default:
+		return FailurePolicy{}, fmt.Errorf("invalid onFailure policy %q: must be one of fail-fast, continue, skip-dependents or fallback: <agentID>", value)
+	}
+}
+
+// EffectiveAction returns the action to take, applying the default when unset
+func (p FailurePolicy) EffectiveAction() FailureAction {
+	if p.Action == "" {
+		return DefaultFailureAction
+	}
+	return p.Action
+}
+
+// String returns the textual form of the policy
+func (p FailurePolicy) String() string {
+	if p.Action == FailureActionFallback {
+		return fmt.Sprintf("%s: %s", p.Action, p.Fallback)
+	}
+	return string(p.Action)
+}
+
+// IsZero reports whether the policy is unset
+func (p FailurePolicy) IsZero() bool {
+	return p.Action == ""
+}
+
+// MarshalJSON encodes the policy as a string
+func (p FailurePolicy) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.String())
+}
+
+// UnmarshalJSON decodes the policy from a string or a {"fallback": id} object
+func (p *FailurePolicy) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		policy, err := ParseFailurePolicy(text)
+		if err != nil {
+			return err
+		}
+		*p = policy
+		return nil
+	}
+
+	var obj map[string]string
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return fmt.Errorf("invalid onFailure policy: %s", string(data))
+	}
+	return p.fromMap(obj)
+}
+
+// MarshalYAML encodes the policy as a string
+func (p FailurePolicy) MarshalYAML() (interface{}, error) {
+	return p.String(), nil
+}
+
+// UnmarshalYAML decodes the policy from a string or a {fallback: id} mapping
+func (p *FailurePolicy) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.MappingNode {
+		var obj map[string]string
+		if err := value.Decode(&obj); err != nil {
+			return fmt.Errorf("invalid onFailure policy at line %d: %w", value.Line, err)
+		}
+		return p.fromMap(obj)
+	}
+
+	var text string
+	if err := value.Decode(&text); err != nil {
+		return fmt.Errorf("invalid onFailure policy at line %d: %w", value.Line, err)
+	}
+	policy, err := ParseFailurePolicy(text)
+	if err != nil {
+		return err
+	}
+	*p = policy
+	return nil
+}
+
+// fromMap decodes the mapping form, which YAML produces for an unquoted
+// "fallback: <agentID>" value
+func (p *FailurePolicy) fromMap(obj map[string]string) error {
+	agentID, ok := obj[string(FailureActionFallback)]
+	if !ok || len(obj) != 1 {
+		return fmt.Errorf("invalid onFailure policy: only {fallback: <agentID>} may be written as a mapping")
+	}
+	policy, err := ParseFailurePolicy(fmt.Sprintf("%s: %s", FailureActionFallback, agentID))
+	if err != nil {
+		return err
+	}
+	*p = policy
+	return nil
+}
+
+// validateFailurePolicies checks that fallback agents exist and can run in
+// place of the agent that names them
+func validateFailurePolicies(dag *DAG) error {
+	fallbackOwners := make(map[string]string)
+
+	for _, node := range dag.Nodes {
+		policy := node.AgentSpec.OnFailure
+		if policy.Action != FailureActionFallback {
+			continue
+		}
+
+		fallbackNode, exists := dag.Nodes[policy.Fallback]
+		if !exists {
+			return fmt.Errorf("agent %s falls back to non-existent agent %s", node.ID, policy.Fallback)
+		}
+		if fallbackNode.ID == node.ID {
+			return fmt.Errorf("agent %s cannot be its own fallback", node.ID)
+		}
+		if owner, taken := fallbackOwners[fallbackNode.ID]; taken {
+			return fmt.Errorf("agent %s is already the fallback for %s and cannot also back up %s", fallbackNode.ID, owner, node.ID)
+		}
+		fallbackOwners[fallbackNode.ID] = node.ID
+
+		if dependsOn(fallbackNode, node.ID) {
+			return fmt.Errorf("fallback agent %s cannot depend on %s, which it replaces", fallbackNode.ID, node.ID)
+		}
+	}
+
+	// Fallback agents only run on demand, so nothing may wait on them
+	for _, node := range dag.Nodes {
+		for _, dep := range node.Dependencies {
+			if owner, isFallback := fallbackOwners[dep.ID]; isFallback {
+				return fmt.Errorf("agent %s cannot depend on %s, which only runs as the fallback for %s", node.ID, dep.ID, owner)
+			}
+		}
+	}
+
+	return nil
+}
+
+// dependsOn reports whether node depends on agentID directly or transitively
+func dependsOn(node *Node, agentID string) bool {
+	for _, dep := range node.Dependencies {
+		if dep.ID == agentID || dependsOn(dep, agentID) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,143 @@
+package stack
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestFailurePolicies(t *testing.T) {
+	tests := []struct {
+		name      string
+		agents    []StackAgentSpec
+		failing   []string
+		expectErr string
+		statuses  map[string]AgentStatus
+		calls     map[string]int
+	}{
+		{
+			name: "skip dependents by default",
+			agents: []StackAgentSpec{
+				{ID: "a", Uses: "test"},
+				{ID: "b", Uses: "test"},
+				{ID: "c", Uses: "test", InputFrom: []string{"a"}},
+				{ID: "d", Uses: "test", Depends: []string{"c"}},
+			},
+			failing:   []string{"a"},
+			expectErr: "upstream agent a failed",
+			statuses:  map[string]AgentStatus{"a": AgentStatusFailed, "b": AgentStatusCompleted, "c": AgentStatusSkipped, "d": AgentStatusSkipped},
+			calls:     map[string]int{"a": 1, "b": 1, "c": 0, "d": 0},
+		},
+		{
+			name: "fail fast",
+			agents: []StackAgentSpec{
+				{ID: "a", Uses: "test", OnFailure: FailurePolicy{Action: FailureActionFailFast}},
+				{ID: "b", Uses: "test"},
+				{ID: "c", Uses: "test", Depends: []string{"a"}},
+			},
+			failing:   []string{"a"},
+			expectErr: "stack aborted after a failed",
+			statuses:  map[string]AgentStatus{"a": AgentStatusFailed, "b": AgentStatusSkipped, "c": AgentStatusSkipped},
+			calls:     map[string]int{"a": 1, "b": 0, "c": 0},
+		},
+		{
+			name: "continue",
+			agents: []StackAgentSpec{
+				{ID: "a", Uses: "test", OnFailure: FailurePolicy{Action: FailureActionContinue}},
+				{ID: "b", Uses: "test"},
+				{ID: "c", Uses: "test", InputFrom: []string{"a", "b"}},
+			},
+			failing:   []string{"a"},
+			expectErr: "a: failed",
+			statuses:  map[string]AgentStatus{"a": AgentStatusFailed, "b": AgentStatusCompleted, "c": AgentStatusCompleted},
+			calls:     map[string]int{"a": 1, "b": 1, "c": 1},
+		},
+		{
+			name: "fallback recovers",
+			agents: []StackAgentSpec{
+				{ID: "primary", Uses: "test", OnFailure: FailurePolicy{Action: FailureActionFallback, Fallback: "backup"}},
+				{ID: "backup", Uses: "test"},
+				{ID: "report", Uses: "test", InputFrom: []string{"primary"}},
+			},
+			failing:  []string{"primary"},
+			statuses: map[string]AgentStatus{"primary": AgentStatusFailed, "backup": AgentStatusCompleted, "report": AgentStatusCompleted},
+			calls:    map[string]int{"primary": 1, "backup": 1, "report": 1},
+		},
+		{
+			name: "fallback fails too",
+			agents: []StackAgentSpec{
+				{ID: "primary", Uses: "test", OnFailure: FailurePolicy{Action: FailureActionFallback, Fallback: "backup"}},
+				{ID: "backup", Uses: "test"},
+				{ID: "report", Uses: "test", InputFrom: []string{"primary"}},
+			},
+			failing:   []string{"primary", "backup"},
+			expectErr: "fallback backup also failed",
+			statuses:  map[string]AgentStatus{"primary": AgentStatusFailed, "backup": AgentStatusFailed, "report": AgentStatusSkipped},
+			calls:     map[string]int{"primary": 1, "backup": 1, "report": 0},
+		},
+		{
+			name: "fallback not needed",
+			agents: []StackAgentSpec{
+				{ID: "primary", Uses: "test", OnFailure: FailurePolicy{Action: FailureActionFallback, Fallback: "backup"}},
+				{ID: "backup", Uses: "test"},
+			},
+			statuses: map[string]AgentStatus{"primary": AgentStatusCompleted, "backup": AgentStatusSkipped},
+			calls:    map[string]int{"primary": 1, "backup": 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runtime := newFakeRuntime()
+			for _, agentID := range tt.failing {
+				runtime.fail(agentID)
+			}
+			engine := newTestEngine(t, StackSpec{Agents: tt.agents}, runtime)
+
+			err := engine.Execute(context.Background(), WithMaxParallelism(1))
+			switch {
+			case tt.expectErr == "" && err != nil:
+				t.Fatalf("Execute failed: %v", err)
+			case tt.expectErr != "" && (err == nil || !strings.Contains(err.Error(), tt.expectErr)):
+				t.Fatalf("Expected an error containing %q, got %v", tt.expectErr, err)
+			}
+
+			for agentID, expected := range tt.statuses {
+				if status := agentStatus(t, engine, agentID); status != expected {
+					t.Errorf("Expected %s to be %s, got %s", agentID, expected, status)
+				}
+			}
+			for agentID, expected := range tt.calls {
+				if n := runtime.callCount(agentID); n != expected {
+					t.Errorf("Expected %s to run %d times, ran %d", agentID, expected, n)
+				}
+			}
+		})
+	}
+}
+
+func TestFallbackStandsInForFailedAgent(t *testing.T) {
+	runtime := newFakeRuntime().fail("primary")
+	engine := newTestEngine(t, StackSpec{Agents: []StackAgentSpec{
+		{ID: "primary", Uses: "test", Params: map[string]interface{}{"query": "status"}, OnFailure: FailurePolicy{Action: FailureActionFallback, Fallback: "backup"}},
+		{ID: "backup", Uses: "test"},
+		{ID: "report", Uses: "test", InputFrom: []string{"primary"}},
+	}}, runtime)
+
+	// Parallel workers must not pick the failed agent up again while its
+	// fallback runs
+	if err := engine.Execute(context.Background(), WithMaxParallelism(4)); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if n := runtime.callCount("primary"); n != 1 {
+		t.Errorf("Expected the failed agent to run once, ran %d times", n)
+	}
+
+	if query := runtime.lastInputs("backup")["query"]; query != "status" {
+		t.Errorf("Expected the fallback to receive the replaced agent's inputs, got %v", runtime.lastInputs("backup"))
+	}
+	primary, _ := runtime.lastInputs("report")["primary"].(map[string]interface{})
+	if primary["result"] != "backup" {
+		t.Errorf("Expected the fallback's outputs to stand in for primary, got %v", runtime.lastInputs("report")["primary"])
+	}
+}
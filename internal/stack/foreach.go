@@ -0,0 +1,155 @@
+package stack
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// forEachItem is the list item a forEach instance runs on
+type forEachItem struct {
+	index int
+	value interface{}
+}
+
+// instanceID returns the ID of the forEach instance at index
+func instanceID(parentID string, index int) string {
+	return fmt.Sprintf("%s[%d]", parentID, index)
+}
+
+// parseInstanceID returns the forEach agent an instance ID belongs to
+func parseInstanceID(agentID string) (string, bool) {
+	open := strings.LastIndex(agentID, "[")
+	if open <= 0 || !strings.HasSuffix(agentID, "]") {
+		return "", false
+	}
+	if _, err := strconv.Atoi(agentID[open+1 : len(agentID)-1]); err != nil {
+		return "", false
+	}
+	return agentID[:open], true
+}
+
+// expandForEach resolves a forEach agent's list and adds one instance per
+// item to the run's graph. The forEach agent then depends on its instances
+// and gathers their outputs once they have all finished.
+func (r *executionRun) expandForEach(node *Node) {
+	e := r.engine
+	agentID := node.ID
+
+	value := node.ForEach.Resolve(e.outputLookup(r.completed))
+	items, ok := toList(value)
+	if !ok {
+		r.failAgent(agentID, fmt.Sprintf("forEach %s did not resolve to a list (got %T)", node.ForEach, value))
+		return
+	}
+
+	e.stateManager.UpdateAgentStatus(agentID, AgentStatusRunning)
+	r.startedAt[agentID] = time.Now()
+	e.publish(Event{Type: EventAgentStarted, AgentID: agentID, Time: r.startedAt[agentID]})
+
+	// Instances run the same agent without the forEach and condition
+	spec := node.AgentSpec
+	spec.ForEach = ""
+	spec.When = ""
+	if spec.OnFailure.Action == FailureActionFallback {
+		spec.OnFailure = FailurePolicy{}
+	}
+
+	// Instances completed before a resume are only reused if the list is unchanged
+	reuse := r.resumed[node.ForEach.AgentID()]
+
+	instanceIDs := make([]string, 0, len(items))
+	instances := make([]*Node, 0, len(items))
+	for i, item := range items {
+		id := instanceID(agentID, i)
+		instanceSpec := spec
+		instanceSpec.ID = id
+
+		instance := &Node{
+			ID:           id,
+			AgentSpec:    instanceSpec,
+			State:        AgentStatusPending,
+			Dependencies: append([]*Node(nil), node.Dependencies...),
+			Dependents:   []*Node{node},
+		}
+		for _, dep := range instance.Dependencies {
+			dep.Dependents = append(dep.Dependents, instance)
+		}
+
+		r.dag.Nodes[id] = instance
+		r.items[id] = forEachItem{index: i, value: item}
+		if !reuse {
+			delete(r.completed, id)
+			delete(r.finished, id)
+		}
+
+		instanceIDs = append(instanceIDs, id)
+		instances = append(instances, instance)
+	}
+
+	node.Dependencies = append(node.Dependencies, instances...)
+	r.expanded[agentID] = instanceIDs
+
+	if err := e.stateManager.AddAgents(instanceIDs); err != nil {
+		if e.verbose {
+			log.Printf("Error adding forEach instances of agent %s: %v", agentID, err)
+		}
+	}
+	e.stateManager.Set(agentID, "instances", instanceIDs)
+
+	if e.verbose {
+		log.Printf("Agent %s expanded into %d instances over %s", agentID, len(items), node.ForEach)
+	}
+
+	// Nothing to wait for if the list is empty
+	if len(instanceIDs) == 0 {
+		r.gatherInstances(agentID, instanceIDs)
+	}
+}
+
+// gatherInstances completes a forEach agent with the outputs of its
+// instances, in list order. Instances whose failure was tolerated
+// contribute a null result.
+func (r *executionRun) gatherInstances(agentID string, instanceIDs []string) {
+	results := make([]interface{}, len(instanceIDs))
+	failed := 0
+
+	for i, id := range instanceIDs {
+		if !r.completed[id] {
+			failed++
+			continue
+		}
+		if output, err := r.engine.stateManager.Get(id, "output"); err == nil {
+			results[i] = output
+		}
+	}
+
+	outputs := map[string]interface{}{
+		"results": results,
+		"count":   len(instanceIDs),
+		"failed":  failed,
+	}
+
+	r.completeAgent(agentID, outputs, true)
+}
+
+// toList converts any slice value to a list of items
+func toList(value interface{}) ([]interface{}, bool) {
+	if list, ok := value.([]interface{}); ok {
+		return list, true
+	}
+
+	v := reflect.ValueOf(value)
+	if !v.IsValid() || (v.Kind() != reflect.Slice && v.Kind() != reflect.Array) {
+		return nil, false
+	}
+
+	list := make([]interface{}, v.Len())
+	for i := range list {
+		list[i] = v.Index(i).Interface()
+	}
+	return list, true
+}
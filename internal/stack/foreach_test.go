@@ -0,0 +1,157 @@
+package stack
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// listOf returns a behaviour that answers with items as structured data
+func listOf(items ...interface{}) func(context.Context, int, map[string]interface{}) (map[string]interface{}, error) {
+	return func(context.Context, int, map[string]interface{}) (map[string]interface{}, error) {
+		return map[string]interface{}{"data": map[string]interface{}{"items": items}}, nil
+	}
+}
+
+// echoItem returns a behaviour that answers with the forEach item it received
+func echoItem(ctx context.Context, call int, inputs map[string]interface{}) (map[string]interface{}, error) {
+	return map[string]interface{}{"item": inputs["item"], "index": inputs["index"]}, nil
+}
+
+func TestForEach(t *testing.T) {
+	tests := []struct {
+		name      string
+		list      func(context.Context, int, map[string]interface{}) (map[string]interface{}, error)
+		mapper    func(context.Context, int, map[string]interface{}) (map[string]interface{}, error)
+		onFailure FailurePolicy
+		expectErr string
+		results   []interface{}
+		failed    int
+	}{
+		{
+			name:   "fan out and gather in order",
+			list:   listOf("a", "b", "c"),
+			mapper: echoItem,
+			results: []interface{}{
+				map[string]interface{}{"item": "a", "index": 0},
+				map[string]interface{}{"item": "b", "index": 1},
+				map[string]interface{}{"item": "c", "index": 2},
+			},
+		},
+		{
+			name:    "empty list",
+			list:    listOf(),
+			mapper:  echoItem,
+			results: []interface{}{},
+		},
+		{
+			name: "tolerated instance failure",
+			list: listOf("a", "b", "c"),
+			mapper: func(ctx context.Context, call int, inputs map[string]interface{}) (map[string]interface{}, error) {
+				if inputs["item"] == "b" {
+					return nil, fmt.Errorf("cannot map b")
+				}
+				return echoItem(ctx, call, inputs)
+			},
+			onFailure: FailurePolicy{Action: FailureActionContinue},
+			expectErr: "cannot map b",
+			results: []interface{}{
+				map[string]interface{}{"item": "a", "index": 0},
+				nil,
+				map[string]interface{}{"item": "c", "index": 2},
+			},
+			failed: 1,
+		},
+		{
+			name: "not a list",
+			list: func(context.Context, int, map[string]interface{}) (map[string]interface{}, error) {
+				return map[string]interface{}{"data": map[string]interface{}{"items": "a, b"}}, nil
+			},
+			mapper:    echoItem,
+			expectErr: "did not resolve to a list",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runtime := newFakeRuntime().on("list", tt.list).on("mapper", tt.mapper)
+			engine := newTestEngine(t, StackSpec{Agents: []StackAgentSpec{
+				{ID: "list", Uses: "test"},
+				{ID: "mapper", Uses: "test", ForEach: "list.output.items", OnFailure: tt.onFailure},
+				{ID: "merge", Uses: "test", InputFrom: []string{"mapper"}},
+			}}, runtime)
+
+			err := engine.Execute(context.Background())
+			switch {
+			case tt.expectErr == "" && err != nil:
+				t.Fatalf("Execute failed: %v", err)
+			case tt.expectErr != "" && (err == nil || !strings.Contains(err.Error(), tt.expectErr)):
+				t.Fatalf("Expected an error containing %q, got %v", tt.expectErr, err)
+			}
+			if tt.results == nil {
+				if n := runtime.callCount("merge"); n != 0 {
+					t.Errorf("Expected merge not to run, ran %d times", n)
+				}
+				return
+			}
+
+			output := agentOutput(t, engine, "mapper")
+			if !reflect.DeepEqual(output["results"], tt.results) {
+				t.Errorf("Expected results %v, got %v", tt.results, output["results"])
+			}
+			if output["count"] != len(tt.results) || output["failed"] != tt.failed {
+				t.Errorf("Expected count %d and failed %d, got %v and %v", len(tt.results), tt.failed, output["count"], output["failed"])
+			}
+
+			merged, _ := runtime.lastInputs("merge")["mapper"].(map[string]interface{})
+			if !reflect.DeepEqual(merged["results"], tt.results) {
+				t.Errorf("Expected merge to receive the gathered results, got %v", runtime.lastInputs("merge")["mapper"])
+			}
+
+			summary := engine.GetState()
+			if summary.TotalAgents != 3+len(tt.results) {
+				t.Errorf("Expected the instances to be tracked, got %d agents", summary.TotalAgents)
+			}
+		})
+	}
+}
+
+func TestForEachInstancesShareParallelism(t *testing.T) {
+	runtime := newFakeRuntime().on("list", listOf(1, 2, 3, 4, 5, 6))
+	runtime.delay = 10 * time.Millisecond
+	engine := newTestEngine(t, StackSpec{Agents: []StackAgentSpec{
+		{ID: "list", Uses: "test"},
+		{ID: "mapper", Uses: "test", ForEach: "list.output.items"},
+	}}, runtime)
+
+	if err := engine.Execute(context.Background(), WithMaxParallelism(2)); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if runtime.maxRunning != 2 {
+		t.Errorf("Expected instances to run two at a time, saw %d", runtime.maxRunning)
+	}
+	for i := 0; i < 6; i++ {
+		if n := runtime.callCount(instanceID("mapper", i)); n != 1 {
+			t.Errorf("Expected instance %d to run once, ran %d times", i, n)
+		}
+	}
+}
+
+func TestInstanceIDs(t *testing.T) {
+	if id := instanceID("mapper", 3); id != "mapper[3]" {
+		t.Errorf("Unexpected instance ID %s", id)
+	}
+	for id, expected := range map[string]string{"mapper[3]": "mapper", "a[b][0]": "a[b]"} {
+		if parentID, ok := parseInstanceID(id); !ok || parentID != expected {
+			t.Errorf("parseInstanceID(%q) = %q, %v", id, parentID, ok)
+		}
+	}
+	for _, id := range []string{"mapper", "[0]", "mapper[x]", "mapper[0"} {
+		if _, ok := parseInstanceID(id); ok {
+			t.Errorf("parseInstanceID(%q) accepted a regular agent ID", id)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package stack
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// forEachOutputKeys are the keys of a forEach agent's gathered output
+var forEachOutputKeys = map[string]bool{"results": true, "count": true, "failed": true}
+
+// InputMapping is a compiled value of an agent's `inputs` block. A value is
+// either a single reference such as ${researcher.output.summary}, which
+// yields the referenced value unchanged, or text with embedded references,
+// which yields a string.
+type InputMapping struct {
+	source string
+	parts  []mappingPart
+}
+
+// mappingPart is a literal text segment or a reference of an input mapping
+type mappingPart struct {
+	text string
+	ref  *OutputReference
+}
+
+// ParseInputMapping compiles an input mapping value
+func ParseInputMapping(value string) (*InputMapping, error) {
+	mapping := &InputMapping{source: value}
+
+	rest := value
+	for {
+		start := strings.Index(rest, "${")
+		if start < 0 {
+			if rest != "" {
+				mapping.parts = append(mapping.parts, mappingPart{text: rest})
+			}
+			break
+		}
+
+		end := strings.Index(rest[start:], "}")
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated ${ in %q", value)
+		}
+		end += start
+
+		if start > 0 {
+			mapping.parts = append(mapping.parts, mappingPart{text: rest[:start]})
+		}
+
+		ref, err := ParseOutputReference(strings.TrimSpace(rest[start+2 : end]))
+		if err != nil {
+			return nil, err
+		}
+		mapping.parts = append(mapping.parts, mappingPart{ref: ref})
+
+		rest = rest[end+1:]
+	}
+
+	return mapping, nil
+}
+
+// References returns the IDs of the agents the mapping reads from
+func (m *InputMapping) References() []string {
+	refs := []string{}
+	seen := make(map[string]bool)
+	for _, part := range m.parts {
+		if part.ref != nil && !seen[part.ref.AgentID()] {
+			seen[part.ref.AgentID()] = true
+			refs = append(refs, part.ref.AgentID())
+		}
+	}
+	return refs
+}
+
+// Resolve evaluates the mapping. References to agents without outputs, such
+// as failed agents whose failure is tolerated, resolve to null; references to
+// paths missing from an available output are an error.
+func (m *InputMapping) Resolve(lookup OutputLookup) (interface{}, error) {
+	values := make([]interface{}, len(m.parts))
+	for i, part := range m.parts {
+		if part.ref == nil {
+			values[i] = part.text
+			continue
+		}
+		if _, ok := lookup(part.ref.AgentID()); !ok {
+			continue
+		}
+		value, ok := part.ref.Lookup(lookup)
+		if !ok {
+			return nil, fmt.Errorf("%s not found in the output of agent %s", part.ref, part.ref.AgentID())
+		}
+		values[i] = value
+	}
+
+	// A single reference keeps the type of the referenced value
+	if len(m.parts) == 1 {
+		return values[0], nil
+	}
+
+	var sb strings.Builder
+	for _, value := range values {
+		sb.WriteString(formatMappingValue(value))
+	}
+	return sb.String(), nil
+}
+
+// formatMappingValue renders a value embedded in text; lists and objects are
+// written as JSON
+func formatMappingValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case map[string]interface{}, []interface{}:
+		data, err := json.Marshal(v)
+		if err == nil {
+			return string(data)
+		}
+	}
+	return fmt.Sprint(value)
+}
+
+// runtimeOutputKeys are the keys a model runtime returns around an agent's
+// reply, which an outputSchema does not describe
+var runtimeOutputKeys = map[string]bool{
+	"text": true, "data": true, "model": true, "provider": true,
+	"agent_id": true, "agent_type": true, "estimated_usage": true,
+}
+
+// ValidateOutputReferences checks every reference to an agent's output, in
+// `inputs` mappings, `when` conditions and forEach lists: the references
+// must parse, refer to other agents of the stack, and start with a key the
+// referenced output can contain
+func ValidateOutputReferences(spec StackSpec) error {
+	agents := make(map[string]StackAgentSpec, len(spec.Agents))
+	for _, agent := range spec.Agents {
+		agents[agent.ID] = agent
+	}
+
+	// check validates a single reference of agent, described by what
+	check := func(agent StackAgentSpec, what string, ref *referenceNode) error {
+		source, exists := agents[ref.agentID]
+		if !exists {
+			return fmt.Errorf("agent %s %s references non-existent agent %s", agent.ID, what, ref.agentID)
+		}
+		if ref.agentID == agent.ID {
+			return fmt.Errorf("agent %s %s references its own output", agent.ID, what)
+		}
+		if err := checkOutputPath(ref, source); err != nil {
+			return fmt.Errorf("agent %s %s: %w", agent.ID, what, err)
+		}
+		return nil
+	}
+
+	for _, agent := range spec.Agents {
+		for _, name := range sortedMappingNames(agent.Inputs) {
+			what := "input " + name
+			mapping, err := ParseInputMapping(agent.Inputs[name])
+			if err != nil {
+				return fmt.Errorf("agent %s %s: %w", agent.ID, what, err)
+			}
+			for _, part := range mapping.parts {
+				if part.ref == nil {
+					continue
+				}
+				if err := check(agent, what, part.ref.ref); err != nil {
+					return err
+				}
+			}
+		}
+
+		if agent.When != "" {
+			condition, err := ParseCondition(agent.When)
+			if err != nil {
+				return fmt.Errorf("agent %s: %w", agent.ID, err)
+			}
+			for _, ref := range condition.paths {
+				if err := check(agent, "condition", ref); err != nil {
+					return err
+				}
+			}
+		}
+
+		if agent.ForEach != "" {
+			forEach, err := ParseOutputReference(agent.ForEach)
+			if err != nil {
+				return fmt.Errorf("agent %s has an invalid forEach: %w", agent.ID, err)
+			}
+			if err := check(agent, "forEach", forEach.ref); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkOutputPath reports a reference whose first key the output of source
+// cannot contain. The output of a forEach agent only has its gathered keys.
+// The reply of an agent whose outputSchema declares properties only has
+// those, either directly or under data, next to the keys the runtime adds.
+func checkOutputPath(ref *referenceNode, source StackAgentSpec) error {
+	path := ref.path
+	if len(path) == 0 || path[0].kind != segmentKey {
+		return nil
+	}
+
+	if source.ForEach != "" {
+		if !forEachOutputKeys[path[0].key] {
+			return fmt.Errorf("%s cannot exist, the output of forEach agent %s only has results, count and failed", ref, source.ID)
+		}
+		return nil
+	}
+
+	properties := schemaProperties(source)
+	if properties == nil || properties[path[0].key] {
+		return nil
+	}
+
+	key := path[0].key
+	if key == "data" && len(path) > 1 && path[1].kind == segmentKey {
+		key = path[1].key
+	} else if runtimeOutputKeys[key] {
+		return nil
+	}
+	if !properties[key] {
+		return fmt.Errorf("%s cannot exist, the outputSchema of agent %s does not declare %s", ref, source.ID, key)
+	}
+	return nil
+}
+
+// schemaProperties returns the properties declared by an agent's inline
+// outputSchema, or nil if it declares none
+func schemaProperties(agentSpec StackAgentSpec) map[string]bool {
+	if agentSpec.OutputSchema == nil || agentSpec.OutputSchema.FromState {
+		return nil
+	}
+	declared, ok := agentSpec.OutputSchema.Schema["properties"].(map[string]interface{})
+	if !ok || len(declared) == 0 {
+		return nil
+	}
+
+	properties := make(map[string]bool, len(declared))
+	for name := range declared {
+		properties[name] = true
+	}
+	return properties
+}
+
+// sortedMappingNames returns the input names of a mapping block in order
+func sortedMappingNames(inputs map[string]string) []string {
+	names := make([]string, 0, len(inputs))
+	for name := range inputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolveInputMappings evaluates an agent's `inputs` block into inputs
+func resolveInputMappings(agentSpec StackAgentSpec, lookup OutputLookup, inputs map[string]interface{}) error {
+	for _, name := range sortedMappingNames(agentSpec.Inputs) {
+		mapping, err := ParseInputMapping(agentSpec.Inputs[name])
+		if err != nil {
+			return fmt.Errorf("input %s: %w", name, err)
+		}
+
+		value, err := mapping.Resolve(lookup)
+		if err != nil {
+			return fmt.Errorf("input %s: %w", name, err)
+		}
+		inputs[name] = value
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package stack
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateOutputReferences(t *testing.T) {
+	classifier := StackAgentSpec{ID: "classifier", Uses: "test", OutputSchema: &OutputSchema{Schema: map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"label": map[string]interface{}{"type": "string"}},
+	}}}
+	fetcher := StackAgentSpec{ID: "fetcher", Uses: "test"}
+	mapper := StackAgentSpec{ID: "mapper", Uses: "test", ForEach: "fetcher.output.items"}
+
+	tests := []struct {
+		name      string
+		agent     StackAgentSpec
+		expectErr string
+	}{
+		{name: "any key without a schema", agent: StackAgentSpec{Inputs: map[string]string{"q": "${fetcher.output.anything[0].text}"}}},
+		{name: "declared key", agent: StackAgentSpec{Inputs: map[string]string{"q": "${classifier.output.label}"}}},
+		{name: "declared key under data", agent: StackAgentSpec{Inputs: map[string]string{"q": "${classifier.output.data.label}"}}},
+		{name: "runtime key", agent: StackAgentSpec{Inputs: map[string]string{"q": "Said: ${classifier.output.text}"}}},
+		{name: "gathered key", agent: StackAgentSpec{Inputs: map[string]string{"q": "${mapper.output.results[*].summary}"}}},
+		{name: "whole output", agent: StackAgentSpec{Inputs: map[string]string{"q": "${mapper.output}"}}},
+		{
+			name:      "undeclared key",
+			agent:     StackAgentSpec{Inputs: map[string]string{"q": "${classifier.output.lable}"}},
+			expectErr: "input q: classifier.output.lable cannot exist, the outputSchema of agent classifier does not declare lable",
+		},
+		{
+			name:      "undeclared key under data",
+			agent:     StackAgentSpec{Inputs: map[string]string{"q": "${classifier.output.data.score}"}},
+			expectErr: "does not declare score",
+		},
+		{
+			name:      "key a forEach output cannot have",
+			agent:     StackAgentSpec{Inputs: map[string]string{"q": "${mapper.output.summary}"}},
+			expectErr: "only has results, count and failed",
+		},
+		{
+			name:      "non-existent agent",
+			agent:     StackAgentSpec{Inputs: map[string]string{"q": "${writer.output.text}"}},
+			expectErr: "input q references non-existent agent writer",
+		},
+		{
+			name:      "own output",
+			agent:     StackAgentSpec{Inputs: map[string]string{"q": "${agent.output.text}"}},
+			expectErr: "references its own output",
+		},
+		{
+			name:      "malformed mapping",
+			agent:     StackAgentSpec{Inputs: map[string]string{"q": "${fetcher.output.items"}},
+			expectErr: "unterminated",
+		},
+		{
+			name:      "condition",
+			agent:     StackAgentSpec{When: `classifier.output.label == "bug" && classifier.output.urgency > 2`},
+			expectErr: "agent agent condition: classifier.output.urgency cannot exist",
+		},
+		{
+			name:      "condition on a forEach agent",
+			agent:     StackAgentSpec{When: `mapper.output.count > 0 && mapper.output.label`},
+			expectErr: "mapper.output.label cannot exist",
+		},
+		{
+			name:      "forEach",
+			agent:     StackAgentSpec{ForEach: "classifier.output.data.items"},
+			expectErr: "agent agent forEach: classifier.output.data.items cannot exist",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agent := tt.agent
+			agent.ID, agent.Uses = "agent", "test"
+			err := ValidateOutputReferences(StackSpec{Agents: []StackAgentSpec{classifier, fetcher, mapper, agent}})
+			switch {
+			case tt.expectErr == "" && err != nil:
+				t.Errorf("Unexpected error: %v", err)
+			case tt.expectErr != "" && (err == nil || !strings.Contains(err.Error(), tt.expectErr)):
+				t.Errorf("Expected an error containing %q, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestInputMappings(t *testing.T) {
+	runtime := newFakeRuntime().
+		on("researcher", func(context.Context, int, map[string]interface{}) (map[string]interface{}, error) {
+			return map[string]interface{}{
+				"text": `{"summary": "DAGs schedule agents", "sources": 3}`,
+				"data": map[string]interface{}{"summary": "DAGs schedule agents", "sources": 3},
+			}, nil
+		}).
+		on("fetcher", func(context.Context, int, map[string]interface{}) (map[string]interface{}, error) {
+			return map[string]interface{}{"items": []interface{}{
+				map[string]interface{}{"text": "first"},
+				map[string]interface{}{"text": "second"},
+			}}, nil
+		})
+	engine := newTestEngine(t, StackSpec{Agents: []StackAgentSpec{
+		{ID: "researcher", Uses: "test"},
+		{ID: "fetcher", Uses: "test"},
+		{ID: "writer", Uses: "test", Inputs: map[string]string{
+			"query":   "${researcher.output.summary}",
+			"sources": "${researcher.output.data.sources}",
+			"docs":    "${fetcher.output.items[*].text}",
+			"last":    "${fetcher.output.items[-1]}",
+			"title":   "Report on ${researcher.output.summary} (${researcher.output.sources} sources)",
+		}},
+	}}, runtime)
+
+	if err := engine.Execute(context.Background()); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	inputs := runtime.lastInputs("writer")
+	expected := map[string]interface{}{
+		"query":   "DAGs schedule agents",
+		"sources": 3,
+		"docs":    []interface{}{"first", "second"},
+		"last":    map[string]interface{}{"text": "second"},
+		"title":   "Report on DAGs schedule agents (3 sources)",
+	}
+	for name, value := range expected {
+		if !reflect.DeepEqual(inputs[name], value) {
+			t.Errorf("Expected input %s to be %#v, got %#v", name, value, inputs[name])
+		}
+	}
+}
+
+func TestInputMappingMissingPathFailsAgent(t *testing.T) {
+	runtime := newFakeRuntime()
+	engine := newTestEngine(t, StackSpec{Agents: []StackAgentSpec{
+		{ID: "researcher", Uses: "test"},
+		{ID: "writer", Uses: "test", Inputs: map[string]string{"query": "${researcher.output.summary}"}},
+	}}, runtime)
+
+	err := engine.Execute(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "researcher.output.summary not found in the output of agent researcher") {
+		t.Fatalf("Expected the missing path to fail writer, got %v", err)
+	}
+	if n := runtime.callCount("writer"); n != 0 {
+		t.Errorf("Expected writer not to run, ran %d times", n)
+	}
+}
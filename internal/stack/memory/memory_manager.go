@@ -2,31 +2,39 @@ package memory
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
-	"github.com/satishgonella2024/sentinelstacks/pkg/types"
+	memorystore "github.com/satishgonella2024/sentinelstacks/internal/memory"
 )
 
 // MemoryManager manages memory for a stack execution
 type MemoryManager struct {
-	factory       types.MemoryStoreFactory
-	stores        map[string]types.MemoryStore
+	factory       memorystore.MemoryStoreFactory
+	storeType     memorystore.MemoryStoreType
+	stores        map[string]memorystore.MemoryStore
 	stackID       string
 	executionID   string
-	defaultConfig types.MemoryConfig
+	defaultConfig memorystore.MemoryConfig
 	mu            sync.Mutex
 }
 
-// NewMemoryManager creates a new memory manager for a stack execution
-func NewMemoryManager(ctx context.Context, factory types.MemoryStoreFactory, stackID, executionID string) (*MemoryManager, error) {
+// NewMemoryManager creates a new memory manager for a stack execution. An
+// empty store type uses the in-process local store.
+func NewMemoryManager(ctx context.Context, factory memorystore.MemoryStoreFactory, storeType memorystore.MemoryStoreType, stackID, executionID string) (*MemoryManager, error) {
+	if storeType == "" {
+		storeType = memorystore.MemoryStoreTypeLocal
+	}
+
 	return &MemoryManager{
 		factory:     factory,
-		stores:      make(map[string]types.MemoryStore),
+		storeType:   storeType,
+		stores:      make(map[string]memorystore.MemoryStore),
 		stackID:     stackID,
 		executionID: executionID,
-		defaultConfig: types.MemoryConfig{
+		defaultConfig: memorystore.MemoryConfig{
 			TTL:              24 * time.Hour, // Default TTL for execution data
 			VectorDimensions: 1536,
 		},
@@ -34,7 +42,7 @@ func NewMemoryManager(ctx context.Context, factory types.MemoryStoreFactory, sta
 }
 
 // GetAgentStore gets or creates a memory store for an agent in the stack
-func (m *MemoryManager) GetAgentStore(ctx context.Context, agentID string) (types.MemoryStore, error) {
+func (m *MemoryManager) GetAgentStore(ctx context.Context, agentID string) (memorystore.MemoryStore, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	
@@ -52,7 +60,7 @@ func (m *MemoryManager) GetAgentStore(ctx context.Context, agentID string) (type
 	config.Namespace = m.executionID
 	
 	// Create new store
-	store, err := m.factory.Create(types.MemoryStoreTypeLocal, config)
+	store, err := m.factory.Create(m.storeType, config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create agent memory store: %w", err)
 	}
@@ -64,7 +72,7 @@ func (m *MemoryManager) GetAgentStore(ctx context.Context, agentID string) (type
 }
 
 // GetStackStore gets or creates a memory store for the stack itself
-func (m *MemoryManager) GetStackStore(ctx context.Context) (types.MemoryStore, error) {
+func (m *MemoryManager) GetStackStore(ctx context.Context) (memorystore.MemoryStore, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	
@@ -82,7 +90,7 @@ func (m *MemoryManager) GetStackStore(ctx context.Context) (types.MemoryStore, e
 	config.Namespace = m.executionID
 	
 	// Create new store
-	store, err := m.factory.Create(types.MemoryStoreTypeLocal, config)
+	store, err := m.factory.Create(m.storeType, config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create stack memory store: %w", err)
 	}
@@ -122,7 +130,7 @@ func (m *MemoryManager) LoadAgentState(ctx context.Context, agentID string) (map
 	stateValue, err := store.Load(ctx, "state")
 	if err != nil {
 		// Return empty state if not found
-		if fmt.Sprintf("%v", err) == fmt.Sprintf("key not found: %s", "state") {
+		if errors.Is(err, memorystore.ErrKeyNotFound) {
 			return make(map[string]interface{}), nil
 		}
 		return nil, fmt.Errorf("failed to load agent state: %w", err)
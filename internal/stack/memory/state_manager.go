@@ -2,10 +2,14 @@ package memory
 
 import (
 	"context"
+	"errors"
+	"encoding/json"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
+	memorystore "github.com/satishgonella2024/sentinelstacks/internal/memory"
 	"github.com/satishgonella2024/sentinelstacks/pkg/types"
 )
 
@@ -17,13 +21,16 @@ type PersistentStateManager struct {
 	agentIDs      []string
 	agentStates   map[string]*types.AgentState
 	summary       *types.StackExecutionSummary
+	restored      bool
 	mu            sync.RWMutex
 }
 
-// NewPersistentStateManager creates a new persistent state manager
-func NewPersistentStateManager(ctx context.Context, stackName string, factory types.MemoryStoreFactory, stackID, executionID string) (*PersistentStateManager, error) {
+// NewPersistentStateManager creates a new persistent state manager. State is
+// kept in stores of the given type, so a durable type such as SQLite allows
+// the execution to be resumed by a later process.
+func NewPersistentStateManager(ctx context.Context, stackName string, factory memorystore.MemoryStoreFactory, storeType memorystore.MemoryStoreType, stackID, executionID string) (*PersistentStateManager, error) {
 	// Create memory manager
-	memManager, err := NewMemoryManager(ctx, factory, stackID, executionID)
+	memManager, err := NewMemoryManager(ctx, factory, storeType, stackID, executionID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create memory manager: %w", err)
 	}
@@ -68,7 +75,6 @@ func (m *PersistentStateManager) InitializeAgents(agentIDs []string) {
 				Status:       types.AgentStatusPending,
 				StartTime:    time.Time{},
 				EndTime:      time.Time{},
-				Dependencies: []string{},
 				Inputs:       make(map[string]interface{}),
 				Outputs:      make(map[string]interface{}),
 			}
@@ -81,6 +87,30 @@ func (m *PersistentStateManager) InitializeAgents(agentIDs []string) {
 	m.saveState()
 }
 
+// AddAgents adds agents created during execution, leaving agents that
+// already have state unchanged
+func (m *PersistentStateManager) AddAgents(agentIDs []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, agentID := range agentIDs {
+		if _, exists := m.agentStates[agentID]; !exists {
+			m.agentStates[agentID] = &types.AgentState{
+				ID:           agentID,
+				Status:       types.AgentStatusPending,
+				Inputs:       make(map[string]interface{}),
+				Outputs:      make(map[string]interface{}),
+			}
+			m.agentIDs = append(m.agentIDs, agentID)
+		}
+		m.summary.AgentStates[agentID] = m.agentStates[agentID]
+	}
+	m.summary.TotalAgents = len(m.summary.AgentStates)
+
+	// Save state
+	m.saveState()
+}
+
 // UpdateAgentStatus updates the status of an agent
 func (m *PersistentStateManager) UpdateAgentStatus(agentID string, status types.AgentStatus) error {
 	m.mu.Lock()
@@ -100,7 +130,7 @@ func (m *PersistentStateManager) UpdateAgentStatus(agentID string, status types.
 	now := time.Now()
 	if status == types.AgentStatusRunning && agentState.StartTime.IsZero() {
 		agentState.StartTime = now
-	} else if (status == types.AgentStatusCompleted || status == types.AgentStatusFailed) && agentState.EndTime.IsZero() {
+	} else if (status == types.AgentStatusCompleted || status == types.AgentStatusFailed || status == types.AgentStatusSkipped) && agentState.EndTime.IsZero() {
 		agentState.EndTime = now
 	}
 	
@@ -115,6 +145,8 @@ func (m *PersistentStateManager) UpdateAgentStatus(agentID string, status types.
 		m.summary.BlockedCount++
 	} else if prevStatus == types.AgentStatusBlocked && status == types.AgentStatusRunning {
 		m.summary.BlockedCount--
+	} else if status == types.AgentStatusSkipped && prevStatus != types.AgentStatusSkipped {
+		m.summary.SkippedCount++
 	}
 	
 	// Save updated state
@@ -184,7 +216,7 @@ func (m *PersistentStateManager) Set(agentID, key string, value interface{}) err
 	allValues, err := m.GetAll(agentID)
 	if err != nil {
 		// If agent has no state yet, create empty map
-		if err.Error() == fmt.Sprintf("failed to load agent state: key not found: %s", "state") {
+		if errors.Is(err, memorystore.ErrKeyNotFound) {
 			allValues = make(map[string]interface{})
 		} else {
 			return err
@@ -254,6 +286,7 @@ func (m *PersistentStateManager) GetStackSummary() *types.StackExecutionSummary
 		CompletedCount: m.summary.CompletedCount,
 		FailedCount:    m.summary.FailedCount,
 		BlockedCount:   m.summary.BlockedCount,
+		SkippedCount:   m.summary.SkippedCount,
 		AgentStates:    make(map[string]*types.AgentState),
 	}
 	
@@ -297,14 +330,25 @@ func (m *PersistentStateManager) loadState() error {
 		return nil
 	}
 	
-	// Convert to summary
+	// Convert to summary; durable stores return the decoded JSON form
 	summary, ok := summaryValue.(*types.StackExecutionSummary)
 	if !ok {
-		return fmt.Errorf("invalid summary format: %T", summaryValue)
+		data, err := json.Marshal(summaryValue)
+		if err != nil {
+			return fmt.Errorf("invalid summary format: %T", summaryValue)
+		}
+		summary = &types.StackExecutionSummary{}
+		if err := json.Unmarshal(data, summary); err != nil {
+			return fmt.Errorf("invalid summary format: %w", err)
+		}
+	}
+	if summary.AgentStates == nil {
+		summary.AgentStates = make(map[string]*types.AgentState)
 	}
 	
 	// Update summary
 	m.summary = summary
+	m.restored = true
 	
 	// Update agent states
 	for agentID, state := range summary.AgentStates {
@@ -313,3 +357,98 @@ func (m *PersistentStateManager) loadState() error {
 	
 	return nil
 }
+
+// Restored reports whether state of a previous execution was loaded
+func (m *PersistentStateManager) Restored() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.restored
+}
+
+// AgentIDs returns the IDs of all agents known to the state manager
+func (m *PersistentStateManager) AgentIDs() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	agentIDs := make([]string, 0, len(m.agentStates))
+	for agentID := range m.agentStates {
+		agentIDs = append(agentIDs, agentID)
+	}
+	sort.Strings(agentIDs)
+	return agentIDs
+}
+
+// ResetIncompleteAgents prepares a restored execution to be resumed. Completed
+// agents keep their state and outputs; every other agent is reset to pending
+// and its stored state is cleared. It returns the IDs of completed agents.
+func (m *PersistentStateManager) ResetIncompleteAgents() ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	completed := []string{}
+	reset := []string{}
+	for agentID, state := range m.agentStates {
+		if state.Status == types.AgentStatusCompleted {
+			completed = append(completed, agentID)
+			continue
+		}
+
+		state.Status = types.AgentStatusPending
+		state.ErrorMessage = ""
+		state.StartTime = time.Time{}
+		state.EndTime = time.Time{}
+		reset = append(reset, agentID)
+	}
+	sort.Strings(completed)
+
+	// Only completed agents are counted once the others are pending again
+	m.summary.CompletedCount = len(completed)
+	m.summary.FailedCount = 0
+	m.summary.BlockedCount = 0
+	m.summary.SkippedCount = 0
+	m.summary.EndTime = time.Time{}
+
+	for _, agentID := range reset {
+		if err := m.memoryManager.SaveAgentState(m.ctx, agentID, make(map[string]interface{})); err != nil {
+			return nil, fmt.Errorf("failed to reset state of agent %s: %w", agentID, err)
+		}
+	}
+
+	m.saveState()
+
+	return completed, nil
+}
+
+// SaveRunInput stores the input the execution was started with
+func (m *PersistentStateManager) SaveRunInput(input map[string]interface{}) error {
+	stackStore, err := m.memoryManager.GetStackStore(m.ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get stack store: %w", err)
+	}
+
+	if err := stackStore.Save(m.ctx, "input", input); err != nil {
+		return fmt.Errorf("failed to save run input: %w", err)
+	}
+
+	return nil
+}
+
+// LoadRunInput loads the input the execution was started with
+func (m *PersistentStateManager) LoadRunInput() (map[string]interface{}, error) {
+	stackStore, err := m.memoryManager.GetStackStore(m.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stack store: %w", err)
+	}
+
+	value, err := stackStore.Load(m.ctx, "input")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load run input: %w", err)
+	}
+
+	input, ok := value.(map[string]interface{})
+	if !ok && value != nil {
+		return nil, fmt.Errorf("invalid run input format: %T", value)
+	}
+
+	return input, nil
+}
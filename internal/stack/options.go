@@ -2,6 +2,7 @@ package stack
 
 import (
 	"github.com/satishgonella2024/sentinelstacks/internal/memory"
+	"github.com/satishgonella2024/sentinelstacks/pkg/types"
 )
 
 // EngineOption defines a function that configures a StackEngine
@@ -28,15 +29,61 @@ func WithStateManager(stateManager StateManager) EngineOption {
 	}
 }
 
+// WithStateStore sets the type of memory store that holds execution state.
+// A durable store such as SQLite is required to resume runs later.
+func WithStateStore(storeType types.MemoryStoreType) EngineOption {
+	return func(e *StackEngine) {
+		e.stateStoreType = storeType
+	}
+}
+
+// WithResume resumes the execution with the given run ID instead of starting
+// a new one. Agents that completed keep their outputs; failed, skipped and
+// pending agents are executed again.
+func WithResume(runID string) EngineOption {
+	return func(e *StackEngine) {
+		e.resumeRunID = runID
+	}
+}
+
+// WithRunID sets the run ID of a new execution instead of generating a
+// unique one
+func WithRunID(runID string) EngineOption {
+	return func(e *StackEngine) {
+		e.runID = runID
+	}
+}
+
+// WithEventBus publishes the engine's execution events to the given bus
+func WithEventBus(bus *EventBus) EngineOption {
+	return func(e *StackEngine) {
+		e.events = bus
+	}
+}
+
+// WithAgentRuntime executes every agent with the given runtime instead of
+// one created from the execution's runtime type. The engine does not clean
+// it up.
+func WithAgentRuntime(runtime types.AgentRuntime) EngineOption {
+	return func(e *StackEngine) {
+		e.agentRuntime = runtime
+	}
+}
+
 // ExecuteOption defines a function that configures execution options
 type ExecuteOption func(*ExecuteOptions)
 
+// DefaultMaxParallelism is the number of agents executed concurrently when
+// no WithMaxParallelism option is given
+const DefaultMaxParallelism = 4
+
 // ExecuteOptions defines options for executing a stack
 type ExecuteOptions struct {
-	Timeout         int
-	Input           map[string]interface{}
-	RuntimeOptions  map[string]interface{}
-	RuntimeType     string
+	Timeout        int
+	Input          map[string]interface{}
+	RuntimeOptions map[string]interface{}
+	RuntimeType    string
+	MaxParallelism int
 }
 
 // WithTimeout sets the execution timeout in seconds
@@ -66,3 +113,11 @@ func WithRuntimeType(runtimeType string) ExecuteOption {
 		o.RuntimeType = runtimeType
 	}
 }
+
+// WithMaxParallelism sets the maximum number of agents executed concurrently.
+// Values below 1 are treated as 1, which executes agents sequentially.
+func WithMaxParallelism(n int) ExecuteOption {
+	return func(o *ExecuteOptions) {
+		o.MaxParallelism = n
+	}
+}
@@ -0,0 +1,251 @@
+package stack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/registry"
+	"github.com/satishgonella2024/sentinelstacks/pkg/agent"
+	"gopkg.in/yaml.v3"
+)
+
+// outputSchemaFromState is the outputSchema value that derives the schema
+// from the stateSchema of the agent's Sentinelfile
+const outputSchemaFromState = "stateSchema"
+
+// Actions for an output that does not match the agent's outputSchema
+const (
+	// InvalidOutputFail fails the agent
+	InvalidOutputFail = "fail"
+	// InvalidOutputReprompt asks the model once more, passing the validation errors
+	InvalidOutputReprompt = "reprompt"
+)
+
+// OutputSchema is the JSON Schema an agent's output must match. In a
+// Stackfile it is written either as a schema mapping or as the string
+// "stateSchema", which uses the stateSchema declared by the agent's
+// Sentinelfile.
+type OutputSchema struct {
+	Schema    map[string]interface{}
+	FromState bool
+}
+
+// MarshalJSON encodes the schema as a mapping or the string "stateSchema"
+func (s OutputSchema) MarshalJSON() ([]byte, error) {
+	if s.FromState {
+		return json.Marshal(outputSchemaFromState)
+	}
+	return json.Marshal(s.Schema)
+}
+
+// UnmarshalJSON decodes the schema from a mapping or the string "stateSchema"
+func (s *OutputSchema) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		return s.fromString(text)
+	}
+
+	var schema map[string]interface{}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return fmt.Errorf("invalid outputSchema: must be a JSON Schema object or %q", outputSchemaFromState)
+	}
+	*s = OutputSchema{Schema: schema}
+	return nil
+}
+
+// MarshalYAML encodes the schema as a mapping or the string "stateSchema"
+func (s OutputSchema) MarshalYAML() (interface{}, error) {
+	if s.FromState {
+		return outputSchemaFromState, nil
+	}
+	return s.Schema, nil
+}
+
+// UnmarshalYAML decodes the schema from a mapping or the string "stateSchema"
+func (s *OutputSchema) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		return s.fromString(value.Value)
+	}
+
+	var schema map[string]interface{}
+	if err := value.Decode(&schema); err != nil {
+		return fmt.Errorf("invalid outputSchema at line %d: %w", value.Line, err)
+	}
+	*s = OutputSchema{Schema: schema}
+	return nil
+}
+
+func (s *OutputSchema) fromString(text string) error {
+	if strings.TrimSpace(text) != outputSchemaFromState {
+		return fmt.Errorf("invalid outputSchema %q: must be a JSON Schema object or %q", text, outputSchemaFromState)
+	}
+	*s = OutputSchema{FromState: true}
+	return nil
+}
+
+// OutputValidationError reports an agent output that does not match the
+// agent's outputSchema
+type OutputValidationError struct {
+	AgentID    string
+	Violations []agent.SchemaViolation
+}
+
+// Error lists every violation with the path of the offending value
+func (e *OutputValidationError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		messages[i] = violation.String()
+	}
+	return fmt.Sprintf("output of agent %s does not match its outputSchema: %s", e.AgentID, strings.Join(messages, "; "))
+}
+
+// resolveOutputSchema returns the JSON Schema for an agent's output, or nil
+// if the agent does not declare one
+func resolveOutputSchema(agentSpec StackAgentSpec) (map[string]interface{}, error) {
+	if agentSpec.OutputSchema == nil {
+		return nil, nil
+	}
+	if !agentSpec.OutputSchema.FromState {
+		return agentSpec.OutputSchema.Schema, nil
+	}
+
+	localRegistry, err := registry.GetLocalRegistry()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open local image registry: %w", err)
+	}
+
+	name, tag := agentSpec.Uses, "latest"
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, tag = name[:i], name[i+1:]
+	}
+
+	image, err := localRegistry.Get(name, tag)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve image %s for outputSchema: %w", agentSpec.Uses, err)
+	}
+	if len(image.Definition.StateSchema) == 0 {
+		return nil, fmt.Errorf("outputSchema uses the stateSchema of %s, but its Sentinelfile declares none", agentSpec.Uses)
+	}
+
+	return agent.StateSchemaToJSONSchema(image.Definition.StateSchema), nil
+}
+
+// validateOutput checks an agent's output against a schema. Runtimes that
+// call a model return its reply as text and, if it is a JSON object, as
+// data; the structured reply is what gets validated. Other runtimes have
+// their output map validated as a whole.
+func validateOutput(schema map[string]interface{}, outputs map[string]interface{}) []agent.SchemaViolation {
+	if data, ok := outputs["data"]; ok {
+		return agent.ValidateJSONSchema(schema, data)
+	}
+
+	if text, ok := outputs["text"].(string); ok {
+		trimmed := strings.TrimSpace(text)
+		trimmed = strings.TrimPrefix(trimmed, "```json")
+		trimmed = strings.TrimPrefix(trimmed, "```")
+		trimmed = strings.TrimSuffix(trimmed, "```")
+
+		var value interface{}
+		if err := json.Unmarshal([]byte(strings.TrimSpace(trimmed)), &value); err != nil {
+			return []agent.SchemaViolation{{Path: "$", Message: "output is not valid JSON"}}
+		}
+		return agent.ValidateJSONSchema(schema, value)
+	}
+
+	return agent.ValidateJSONSchema(schema, outputs)
+}
+
+// executeValidated executes an agent and checks its output against its
+// outputSchema. With onInvalidOutput: reprompt, an invalid output is
+// followed by one more call that tells the model what was wrong.
+func (r *executionRun) executeValidated(ctx context.Context, agentSpec StackAgentSpec, inputs map[string]interface{}) (map[string]interface{}, error) {
+	schema, err := resolveOutputSchema(agentSpec)
+	if err != nil {
+		return nil, fmt.Errorf("agent %s: %w", agentSpec.ID, err)
+	}
+	if schema == nil {
+		return r.engine.executeAgent(ctx, agentSpec, inputs, r.options)
+	}
+
+	// Tell the model the shape of the answer it should give
+	agentSpec = withSchemaParams(agentSpec, schema, nil)
+
+	outputs, err := r.engine.executeAgent(ctx, agentSpec, inputs, r.options)
+	if err != nil {
+		return nil, err
+	}
+
+	violations := validateOutput(schema, outputs)
+	if len(violations) == 0 {
+		return outputs, nil
+	}
+	if agentSpec.OnInvalidOutput != InvalidOutputReprompt {
+		return nil, &OutputValidationError{AgentID: agentSpec.ID, Violations: violations}
+	}
+
+	if r.engine.verbose {
+		log.Printf("Agent %s returned invalid output, re-prompting: %v",
+			agentSpec.ID, &OutputValidationError{AgentID: agentSpec.ID, Violations: violations})
+	}
+
+	outputs, err = r.engine.executeAgent(ctx, withSchemaParams(agentSpec, schema, violations), inputs, r.options)
+	if err != nil {
+		return nil, err
+	}
+
+	if violations := validateOutput(schema, outputs); len(violations) > 0 {
+		return nil, fmt.Errorf("%w (after re-prompting)", &OutputValidationError{AgentID: agentSpec.ID, Violations: violations})
+	}
+	return outputs, nil
+}
+
+// withSchemaParams returns a copy of the spec whose params ask for JSON
+// output matching the schema and, on a re-prompt, list what was wrong with
+// the previous answer
+func withSchemaParams(agentSpec StackAgentSpec, schema map[string]interface{}, violations []agent.SchemaViolation) StackAgentSpec {
+	params := make(map[string]interface{}, len(agentSpec.Params)+3)
+	for k, v := range agentSpec.Params {
+		params[k] = v
+	}
+
+	params["output_format"] = "json"
+	params["output_schema"] = schema
+
+	if len(violations) > 0 {
+		var sb strings.Builder
+		sb.WriteString("Your previous answer did not match the required schema:\n")
+		for _, violation := range violations {
+			sb.WriteString(fmt.Sprintf("- %s\n", violation))
+		}
+		sb.WriteString("Answer again with a JSON value that fixes these problems.")
+		params["feedback"] = sb.String()
+	}
+
+	agentSpec.Params = params
+	return agentSpec
+}
+
+// validateOutputSchemas checks the outputSchema settings of every agent
+func validateOutputSchemas(dag *DAG) error {
+	for _, node := range dag.Nodes {
+		spec := node.AgentSpec
+		switch spec.OnInvalidOutput {
+		case "", InvalidOutputFail:
+		case InvalidOutputReprompt:
+			if spec.OutputSchema == nil {
+				return fmt.Errorf("agent %s sets onInvalidOutput: %s without an outputSchema", spec.ID, InvalidOutputReprompt)
+			}
+		default:
+			return fmt.Errorf("agent %s has an invalid onInvalidOutput %q: must be %s or %s",
+				spec.ID, spec.OnInvalidOutput, InvalidOutputFail, InvalidOutputReprompt)
+		}
+
+		if spec.OutputSchema != nil && !spec.OutputSchema.FromState && len(spec.OutputSchema.Schema) == 0 {
+			return fmt.Errorf("agent %s has an empty outputSchema", spec.ID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package stack
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// replies returns a behaviour that answers each call with the next text,
+// exposing JSON objects as data the way model runtimes do
+func replies(texts ...string) func(context.Context, int, map[string]interface{}) (map[string]interface{}, error) {
+	return func(_ context.Context, call int, _ map[string]interface{}) (map[string]interface{}, error) {
+		text := texts[len(texts)-1]
+		if call <= len(texts) {
+			text = texts[call-1]
+		}
+		outputs := map[string]interface{}{"text": text}
+		var data map[string]interface{}
+		if err := json.Unmarshal([]byte(text), &data); err == nil {
+			outputs["data"] = data
+		}
+		return outputs, nil
+	}
+}
+
+func TestOutputSchema(t *testing.T) {
+	schema := &OutputSchema{Schema: map[string]interface{}{
+		"type":     "object",
+		"required": []interface{}{"label", "confidence"},
+		"properties": map[string]interface{}{
+			"label":      map[string]interface{}{"type": "string", "enum": []interface{}{"bug", "feature"}},
+			"confidence": map[string]interface{}{"type": "number"},
+		},
+	}}
+	valid := `{"label": "bug", "confidence": 0.9}`
+	invalid := `{"label": "question"}`
+
+	tests := []struct {
+		name            string
+		onInvalidOutput string
+		replies         []string
+		expectErr       string
+		calls           int
+	}{
+		{name: "valid output", replies: []string{valid}, calls: 1},
+		{name: "invalid output fails", replies: []string{invalid}, expectErr: "does not match its outputSchema", calls: 1},
+		{name: "reply that is not JSON", replies: []string{"It is a bug."}, expectErr: "output is not valid JSON", calls: 1},
+		{name: "reprompt fixes the output", onInvalidOutput: InvalidOutputReprompt, replies: []string{invalid, valid}, calls: 2},
+		{
+			name:            "reprompt still invalid",
+			onInvalidOutput: InvalidOutputReprompt,
+			replies:         []string{invalid, invalid},
+			expectErr:       "after re-prompting",
+			calls:           2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runtime := newFakeRuntime().on("classifier", replies(tt.replies...))
+			engine := newTestEngine(t, StackSpec{Agents: []StackAgentSpec{
+				{ID: "classifier", Uses: "test", OutputSchema: schema, OnInvalidOutput: tt.onInvalidOutput},
+			}}, runtime)
+
+			err := engine.Execute(context.Background())
+			switch {
+			case tt.expectErr == "" && err != nil:
+				t.Fatalf("Execute failed: %v", err)
+			case tt.expectErr != "" && (err == nil || !strings.Contains(err.Error(), tt.expectErr)):
+				t.Fatalf("Expected an error containing %q, got %v", tt.expectErr, err)
+			}
+			if n := runtime.callCount("classifier"); n != tt.calls {
+				t.Errorf("Expected %d calls, got %d", tt.calls, n)
+			}
+
+			params := runtime.params["classifier"]
+			if params[0]["output_format"] != "json" || params[0]["output_schema"] == nil {
+				t.Errorf("Expected the first call to ask for the schema, got params %v", params[0])
+			}
+			if _, ok := params[0]["feedback"]; ok {
+				t.Errorf("Expected no feedback on the first call, got %v", params[0]["feedback"])
+			}
+			if tt.calls > 1 {
+				feedback, _ := params[1]["feedback"].(string)
+				if !strings.Contains(feedback, "label") {
+					t.Errorf("Expected the re-prompt to explain the invalid label, got %q", feedback)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateOutputSchemas(t *testing.T) {
+	tests := []struct {
+		agent     StackAgentSpec
+		expectErr string
+	}{
+		{agent: StackAgentSpec{OutputSchema: &OutputSchema{FromState: true}, OnInvalidOutput: InvalidOutputReprompt}},
+		{agent: StackAgentSpec{OnInvalidOutput: InvalidOutputReprompt}, expectErr: "without an outputSchema"},
+		{agent: StackAgentSpec{OutputSchema: &OutputSchema{FromState: true}, OnInvalidOutput: "retry"}, expectErr: "invalid onInvalidOutput"},
+		{agent: StackAgentSpec{OutputSchema: &OutputSchema{Schema: map[string]interface{}{}}}, expectErr: "empty outputSchema"},
+	}
+
+	for _, tt := range tests {
+		agent := tt.agent
+		agent.ID, agent.Uses = "agent", "test"
+		_, err := NewDAG(StackSpec{Agents: []StackAgentSpec{agent}})
+		switch {
+		case tt.expectErr == "" && err != nil:
+			t.Errorf("Unexpected error for %+v: %v", tt.agent, err)
+		case tt.expectErr != "" && (err == nil || !strings.Contains(err.Error(), tt.expectErr)):
+			t.Errorf("Expected an error containing %q for %+v, got %v", tt.expectErr, tt.agent, err)
+		}
+	}
+}
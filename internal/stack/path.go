@@ -0,0 +1,133 @@
+package stack
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// segmentKind identifies the kind of a path segment
+type segmentKind int
+
+const (
+	// segmentKey selects a key of an object, as in .summary or ["summary"]
+	segmentKey segmentKind = iota
+	// segmentIndex selects an element of a list, as in [0] or [-1]
+	segmentIndex
+	// segmentWildcard selects every element of a list or object, as in [*]
+	segmentWildcard
+)
+
+// pathSegment is one step of a JSONPath-style path into an agent's output
+type pathSegment struct {
+	kind  segmentKind
+	key   string
+	index int
+}
+
+// String returns the segment in path syntax
+func (s pathSegment) String() string {
+	switch s.kind {
+	case segmentIndex:
+		return "[" + strconv.Itoa(s.index) + "]"
+	case segmentWildcard:
+		return "[*]"
+	default:
+		return "." + s.key
+	}
+}
+
+// formatPath returns a path in JSONPath-style syntax
+func formatPath(path []pathSegment) string {
+	var sb strings.Builder
+	for _, segment := range path {
+		sb.WriteString(segment.String())
+	}
+	return sb.String()
+}
+
+// resolveOutput resolves a path into an agent's outputs. Runtimes that call
+// a model expose a JSON reply as data, so the path is resolved against data
+// first and against the outputs as a whole when data does not contain it.
+func resolveOutput(outputs map[string]interface{}, path []pathSegment) (interface{}, bool) {
+	if data, ok := outputs["data"].(map[string]interface{}); ok && len(path) > 0 {
+		if value, ok := resolvePath(data, path); ok {
+			return value, true
+		}
+	}
+	return resolvePath(outputs, path)
+}
+
+// resolvePath walks a path through decoded JSON-like values. A wildcard maps
+// the rest of the path over every element and collects the results that
+// exist into a list. It reports false if the path does not exist.
+func resolvePath(value interface{}, path []pathSegment) (interface{}, bool) {
+	for i, segment := range path {
+		switch segment.kind {
+		case segmentWildcard:
+			elements, ok := wildcardElements(value)
+			if !ok {
+				return nil, false
+			}
+			results := make([]interface{}, 0, len(elements))
+			for _, element := range elements {
+				if result, ok := resolvePath(element, path[i+1:]); ok {
+					results = append(results, result)
+				}
+			}
+			return results, true
+
+		case segmentIndex:
+			if object, ok := value.(map[string]interface{}); ok {
+				// Numeric keys of objects may be written as indices
+				if value, ok = object[strconv.Itoa(segment.index)]; !ok {
+					return nil, false
+				}
+				continue
+			}
+			list, ok := toList(value)
+			if !ok {
+				return nil, false
+			}
+			index := segment.index
+			if index < 0 {
+				index += len(list)
+			}
+			if index < 0 || index >= len(list) {
+				return nil, false
+			}
+			value = list[index]
+
+		default:
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if value, ok = object[segment.key]; !ok {
+				return nil, false
+			}
+		}
+	}
+
+	return value, true
+}
+
+// wildcardElements returns the elements of a list, or the values of an
+// object ordered by key
+func wildcardElements(value interface{}) ([]interface{}, bool) {
+	if object, ok := value.(map[string]interface{}); ok {
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		elements := make([]interface{}, len(keys))
+		for i, key := range keys {
+			elements[i] = object[key]
+		}
+		return elements, true
+	}
+
+	return toList(value)
+}
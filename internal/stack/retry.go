@@ -0,0 +1,317 @@
+package stack
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// defaultRetryBackoff is the first retry delay when a retry policy sets none
+	defaultRetryBackoff = time.Second
+
+	// maxRetryBackoff caps the exponential growth of retry delays
+	maxRetryBackoff = 2 * time.Minute
+)
+
+// Retry classes understood by RetryPolicy.RetryOn. Any other value is
+// matched as a case-insensitive substring of the error message.
+const (
+	RetryOnAny         = "any"
+	RetryOnTimeout     = "timeout"
+	RetryOnRateLimit   = "rate-limit"
+	RetryOnServerError = "server-error"
+	RetryOnNetwork     = "network"
+)
+
+// Duration is a time.Duration that is written in Stackfiles either as a Go
+// duration string such as "30s" or as a number of seconds
+type Duration time.Duration
+
+// ParseDuration parses a duration string, treating a bare number as seconds
+func ParseDuration(value string) (Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		var seconds float64
+		if _, scanErr := fmt.Sscanf(value, "%g", &seconds); scanErr != nil {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		d = time.Duration(seconds * float64(time.Second))
+	}
+
+	if d < 0 {
+		return 0, fmt.Errorf("invalid duration %q: must not be negative", value)
+	}
+
+	return Duration(d), nil
+}
+
+// String returns the duration in Go duration syntax
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// MarshalJSON encodes the duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON decodes the duration from a string or a number of seconds
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var seconds float64
+	if err := json.Unmarshal(data, &seconds); err == nil {
+		if seconds < 0 {
+			return fmt.Errorf("invalid duration %s: must not be negative", string(data))
+		}
+		*d = Duration(seconds * float64(time.Second))
+		return nil
+	}
+
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return fmt.Errorf("invalid duration: %s", string(data))
+	}
+	parsed, err := ParseDuration(text)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// MarshalYAML encodes the duration as a string
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return d.String(), nil
+}
+
+// UnmarshalYAML decodes the duration from a string or a number of seconds
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	parsed, err := ParseDuration(value.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", value.Line, err)
+	}
+	*d = parsed
+	return nil
+}
+
+// RetryPolicy defines how often and on which errors an agent is retried
+type RetryPolicy struct {
+	// Max is the number of retries after the first attempt
+	Max int `json:"max" yaml:"max"`
+	// Backoff is the delay before the first retry; it doubles on each retry
+	Backoff Duration `json:"backoff,omitempty" yaml:"backoff,omitempty"`
+	// RetryOn restricts retries to matching errors; empty retries on any error
+	RetryOn []string `json:"retryOn,omitempty" yaml:"retryOn,omitempty"`
+}
+
+// Delay returns the backoff before the given retry, starting at 1
+func (p *RetryPolicy) Delay(retry int) time.Duration {
+	delay := time.Duration(p.Backoff)
+	if delay == 0 {
+		delay = defaultRetryBackoff
+	}
+
+	for i := 1; i < retry; i++ {
+		delay *= 2
+		if delay >= maxRetryBackoff {
+			return maxRetryBackoff
+		}
+	}
+
+	return delay
+}
+
+// ShouldRetry reports whether err matches the policy's retryOn classes
+func (p *RetryPolicy) ShouldRetry(err error) bool {
+	if len(p.RetryOn) == 0 {
+		return true
+	}
+
+	for _, class := range p.RetryOn {
+		if errorMatchesClass(err, class) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// errorMatchesClass reports whether err belongs to a retry class
+func errorMatchesClass(err error, class string) bool {
+	message := strings.ToLower(err.Error())
+	class = strings.ToLower(strings.TrimSpace(class))
+
+	switch class {
+	case RetryOnAny, "*":
+		return true
+	case RetryOnTimeout:
+		return errors.Is(err, context.DeadlineExceeded) ||
+			strings.Contains(message, "timeout") || strings.Contains(message, "timed out")
+	case RetryOnRateLimit:
+		return strings.Contains(message, "429") || strings.Contains(message, "rate limit") ||
+			strings.Contains(message, "rate_limit") || strings.Contains(message, "too many requests")
+	case RetryOnServerError:
+		for _, code := range []string{"500", "502", "503", "504", "529"} {
+			if strings.Contains(message, code) {
+				return true
+			}
+		}
+		return strings.Contains(message, "overloaded") || strings.Contains(message, "internal server error")
+	case RetryOnNetwork:
+		return strings.Contains(message, "connection refused") || strings.Contains(message, "connection reset") ||
+			strings.Contains(message, "no such host") || strings.Contains(message, "eof")
+	default:
+		return strings.Contains(message, class)
+	}
+}
+
+// AttemptRecord describes a single execution attempt of an agent
+type AttemptRecord struct {
+	Attempt   int    `json:"attempt"`
+	StartTime int64  `json:"startTime"`
+	EndTime   int64  `json:"endTime"`
+	Error     string `json:"error,omitempty"`
+}
+
+// attemptReport carries an attempt record from a worker to the scheduler
+type attemptReport struct {
+	agentID string
+	record  AttemptRecord
+}
+
+// runAttempts executes an agent, applying its timeout to every attempt and
+// retrying failed attempts according to its retry policy. It runs on a
+// worker goroutine and reports each attempt to the scheduler.
+func (r *executionRun) runAttempts(ctx context.Context, agentSpec StackAgentSpec, inputs map[string]interface{}) (map[string]interface{}, error) {
+	maxRetries := 0
+	if agentSpec.Retry != nil {
+		maxRetries = agentSpec.Retry.Max
+	}
+
+	for attempt := 1; ; attempt++ {
+		record := AttemptRecord{Attempt: attempt, StartTime: time.Now().Unix()}
+
+		outputs, err := r.runAttempt(ctx, agentSpec, inputs)
+
+		record.EndTime = time.Now().Unix()
+		if err != nil {
+			record.Error = err.Error()
+		}
+		r.attempts <- attemptReport{agentID: agentSpec.ID, record: record}
+
+		if err == nil {
+			return outputs, nil
+		}
+
+		// Stop when the run is cancelled, retries are exhausted or the error is not retryable
+		if ctx.Err() != nil || attempt > maxRetries || !agentSpec.Retry.ShouldRetry(err) {
+			if attempt > 1 {
+				return nil, fmt.Errorf("%w (after %d attempts)", err, attempt)
+			}
+			return nil, err
+		}
+
+		delay := agentSpec.Retry.Delay(attempt)
+		r.engine.publish(Event{
+			Type:      EventAgentRetry,
+			AgentID:   agentSpec.ID,
+			Attempt:   attempt,
+			RetryInMs: delay.Milliseconds(),
+			Error:     err.Error(),
+		})
+		if r.engine.verbose {
+			log.Printf("Agent %s attempt %d failed, retrying in %v: %v", agentSpec.ID, attempt, delay, err)
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w (retry cancelled after %d attempts)", err, attempt)
+		}
+	}
+}
+
+// runAttempt executes a single attempt of an agent under its timeout
+func (r *executionRun) runAttempt(ctx context.Context, agentSpec StackAgentSpec, inputs map[string]interface{}) (map[string]interface{}, error) {
+	if agentSpec.Timeout <= 0 {
+		return r.executeValidated(ctx, agentSpec, inputs)
+	}
+
+	attemptCtx, cancel := context.WithTimeout(ctx, time.Duration(agentSpec.Timeout))
+	defer cancel()
+
+	outputs, err := r.executeValidated(attemptCtx, agentSpec, inputs)
+	if err != nil && ctx.Err() == nil && errors.Is(attemptCtx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("agent timed out after %v: %w", agentSpec.Timeout, context.DeadlineExceeded)
+	}
+
+	return outputs, err
+}
+
+// recordAttempt appends an attempt to the agent's attempt history in state
+func (r *executionRun) recordAttempt(report attemptReport) {
+	e := r.engine
+	attempts := append(r.attemptHistory[report.agentID], report.record)
+	r.attemptHistory[report.agentID] = attempts
+
+	history := make([]AttemptRecord, len(attempts))
+	copy(history, attempts)
+
+	if err := e.stateManager.Set(report.agentID, "attempts", history); err != nil {
+		if e.verbose {
+			log.Printf("Error recording attempt for agent %s: %v", report.agentID, err)
+		}
+	}
+}
+
+// AttemptsFromState extracts the attempt history from an agent state as
+// returned by GetAgentState, whether or not it went through serialization
+func AttemptsFromState(agentState map[string]interface{}) []AttemptRecord {
+	switch attempts := agentState["attempts"].(type) {
+	case nil:
+		return nil
+	case []AttemptRecord:
+		return attempts
+	default:
+		data, err := json.Marshal(attempts)
+		if err != nil {
+			return nil
+		}
+		var records []AttemptRecord
+		if err := json.Unmarshal(data, &records); err != nil {
+			return nil
+		}
+		return records
+	}
+}
+
+// validateRetryPolicies checks the retry and timeout settings of every agent
+func validateRetryPolicies(dag *DAG) error {
+	for _, node := range dag.Nodes {
+		spec := node.AgentSpec
+		if spec.Timeout < 0 {
+			return fmt.Errorf("agent %s has a negative timeout", spec.ID)
+		}
+		if spec.Retry == nil {
+			continue
+		}
+		if spec.Retry.Max < 0 {
+			return fmt.Errorf("agent %s has a negative retry max", spec.ID)
+		}
+		if spec.Retry.Backoff < 0 {
+			return fmt.Errorf("agent %s has a negative retry backoff", spec.ID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package stack
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// failFirst fails the first n calls with err and then succeeds
+func failFirst(n int, err error) func(context.Context, int, map[string]interface{}) (map[string]interface{}, error) {
+	return func(_ context.Context, call int, _ map[string]interface{}) (map[string]interface{}, error) {
+		if call <= n {
+			return nil, err
+		}
+		return map[string]interface{}{"result": "ok"}, nil
+	}
+}
+
+// hangFirst blocks the first n calls until they are cancelled and then succeeds
+func hangFirst(n int) func(context.Context, int, map[string]interface{}) (map[string]interface{}, error) {
+	return func(ctx context.Context, call int, _ map[string]interface{}) (map[string]interface{}, error) {
+		if call <= n {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return map[string]interface{}{"result": "ok"}, nil
+	}
+}
+
+func TestRetriesAndTimeouts(t *testing.T) {
+	backoff := Duration(time.Millisecond)
+	tests := []struct {
+		name      string
+		retry     *RetryPolicy
+		timeout   Duration
+		behaviour func(context.Context, int, map[string]interface{}) (map[string]interface{}, error)
+		expectErr string
+		calls     int
+	}{
+		{
+			name:      "succeeds after retries",
+			retry:     &RetryPolicy{Max: 3, Backoff: backoff},
+			behaviour: failFirst(2, errors.New("connection reset")),
+			calls:     3,
+		},
+		{
+			name:      "retries exhausted",
+			retry:     &RetryPolicy{Max: 2, Backoff: backoff},
+			behaviour: failFirst(10, errors.New("connection reset")),
+			expectErr: "after 3 attempts",
+			calls:     3,
+		},
+		{
+			name:      "no retry policy",
+			behaviour: failFirst(1, errors.New("connection reset")),
+			expectErr: "connection reset",
+			calls:     1,
+		},
+		{
+			name:      "error not retryable",
+			retry:     &RetryPolicy{Max: 3, Backoff: backoff, RetryOn: []string{RetryOnRateLimit}},
+			behaviour: failFirst(1, errors.New("invalid request")),
+			expectErr: "invalid request",
+			calls:     1,
+		},
+		{
+			name:      "retryable class",
+			retry:     &RetryPolicy{Max: 3, Backoff: backoff, RetryOn: []string{RetryOnRateLimit}},
+			behaviour: failFirst(1, errors.New("status 429: too many requests")),
+			calls:     2,
+		},
+		{
+			name:      "timeout",
+			timeout:   Duration(10 * time.Millisecond),
+			behaviour: hangFirst(1),
+			expectErr: "timed out after 10ms",
+			calls:     1,
+		},
+		{
+			name:      "timeout retried",
+			retry:     &RetryPolicy{Max: 1, Backoff: backoff, RetryOn: []string{RetryOnTimeout}},
+			timeout:   Duration(10 * time.Millisecond),
+			behaviour: hangFirst(1),
+			calls:     2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runtime := newFakeRuntime().on("agent", tt.behaviour)
+			engine := newTestEngine(t, StackSpec{Agents: []StackAgentSpec{
+				{ID: "agent", Uses: "test", Retry: tt.retry, Timeout: tt.timeout},
+			}}, runtime)
+
+			err := engine.Execute(context.Background())
+			switch {
+			case tt.expectErr == "" && err != nil:
+				t.Fatalf("Execute failed: %v", err)
+			case tt.expectErr != "" && (err == nil || !strings.Contains(err.Error(), tt.expectErr)):
+				t.Fatalf("Expected an error containing %q, got %v", tt.expectErr, err)
+			}
+
+			if n := runtime.callCount("agent"); n != tt.calls {
+				t.Errorf("Expected %d calls, got %d", tt.calls, n)
+			}
+
+			state, err := engine.GetAgentState("agent")
+			if err != nil {
+				t.Fatalf("GetAgentState failed: %v", err)
+			}
+			attempts := AttemptsFromState(state)
+			if len(attempts) != tt.calls {
+				t.Fatalf("Expected %d attempts recorded, got %+v", tt.calls, attempts)
+			}
+			for i, attempt := range attempts {
+				failed := attempt.Error != ""
+				if expectFailed := i < tt.calls-1 || tt.expectErr != ""; failed != expectFailed {
+					t.Errorf("Attempt %d recorded error %q", attempt.Attempt, attempt.Error)
+				}
+			}
+			if summarized := engine.GetState().AgentStates["agent"].Attempts; !reflect.DeepEqual(summarized, attempts) {
+				t.Errorf("Expected the summary to carry attempts %+v, got %+v", attempts, summarized)
+			}
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	policy := &RetryPolicy{Backoff: Duration(time.Second)}
+	for retry, expected := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 4: 8 * time.Second, 20: maxRetryBackoff} {
+		if delay := policy.Delay(retry); delay != expected {
+			t.Errorf("Delay(%d) = %v, expected %v", retry, delay, expected)
+		}
+	}
+	if delay := (&RetryPolicy{}).Delay(1); delay != defaultRetryBackoff {
+		t.Errorf("Expected the default backoff, got %v", delay)
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := map[string]Duration{
+		"":     0,
+		"30s":  Duration(30 * time.Second),
+		"1m":   Duration(time.Minute),
+		"45":   Duration(45 * time.Second),
+		"0.5":  Duration(500 * time.Millisecond),
+		"10ms": Duration(10 * time.Millisecond),
+	}
+	for value, expected := range tests {
+		d, err := ParseDuration(value)
+		if err != nil || d != expected {
+			t.Errorf("ParseDuration(%q) = %v, %v; expected %v", value, d, err, expected)
+		}
+	}
+
+	for _, invalid := range []string{"soon", "-5s", "-1"} {
+		if _, err := ParseDuration(invalid); err == nil {
+			t.Errorf("ParseDuration(%q) succeeded", invalid)
+		}
+	}
+}
@@ -4,6 +4,7 @@ package runtime
 import (
 	"context"
 
+	"github.com/satishgonella2024/sentinelstacks/internal/shim"
 	"github.com/satishgonella2024/sentinelstacks/internal/stack"
 	"github.com/satishgonella2024/sentinelstacks/pkg/adapter"
 	pkgRuntime "github.com/satishgonella2024/sentinelstacks/pkg/runtime"
@@ -57,7 +58,7 @@ func (a *InternalRuntimeAdapter) Cleanup() error {
 // GetDirectRuntime returns an adapter for the direct runtime
 func GetDirectRuntime(verbose bool) (InternalAgentRuntime, error) {
 	// Create a public direct runtime
-	publicRuntime, err := pkgRuntime.NewDirectRuntime(verbose)
+	publicRuntime, err := pkgRuntime.NewDirectRuntime(verbose, pkgRuntime.WithLLMFactory(newLLMClient))
 	if err != nil {
 		return nil, err
 	}
@@ -81,7 +82,7 @@ func GetCliRuntime(verbose bool) (InternalAgentRuntime, error) {
 // GetDefaultRuntime returns the default runtime
 func GetDefaultRuntime(verbose bool) (InternalAgentRuntime, error) {
 	// Create a public factory
-	factory := pkgRuntime.NewRuntimeFactory(verbose)
+	factory := pkgRuntime.NewRuntimeFactory(verbose, pkgRuntime.WithLLMFactory(newLLMClient))
 
 	// Get default runtime
 	runtime, err := factory.DefaultRuntime()
@@ -92,3 +93,8 @@ func GetDefaultRuntime(verbose bool) (InternalAgentRuntime, error) {
 	// Wrap with adapter
 	return NewInternalRuntimeAdapter(runtime), nil
 }
+
+// newLLMClient creates the LLM shim the direct runtime uses to call models
+func newLLMClient(provider, endpoint, apiKey, model string) (types.CompletionClient, error) {
+	return shim.ShimFactory(provider, endpoint, apiKey, model)
+}
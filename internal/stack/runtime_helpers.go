@@ -53,7 +53,7 @@ func (r *RealAgentRuntime) pullAgent(ctx context.Context, agentRef string) error
 // createSentinelfile creates a Sentinelfile for a mock agent
 func (r *RealAgentRuntime) createSentinelfile(agentType string) (string, error) {
 	// Create a temporary directory for the Sentinelfile
-	tmpDir, err := ioutil.TempDir("", "sentinelfile-")
+	tmpDir, err := os.MkdirTemp("", "sentinelfile-")
 	if err != nil {
 		return "", fmt.Errorf("failed to create temporary directory: %w", err)
 	}
@@ -179,7 +179,7 @@ prompt_template: |
 	
 	// Write Sentinelfile
 	sentinelfilePath := filepath.Join(tmpDir, "Sentinelfile")
-	if err := ioutil.WriteFile(sentinelfilePath, []byte(content), 0644); err != nil {
+	if err := os.WriteFile(sentinelfilePath, []byte(content), 0644); err != nil {
 		return "", fmt.Errorf("failed to write Sentinelfile: %w", err)
 	}
 	
@@ -28,6 +28,10 @@ type StateManager interface {
 	// Clear removes all state for an agent
 	Clear(agentID string) error
 	
+	// AddAgents adds agents created during execution, such as forEach
+	// instances; agents that already have state are left unchanged
+	AddAgents(agentIDs []string) error
+	
 	// GetStackSummary returns a summary of the current stack execution
 	GetStackSummary() *StackExecutionSummary
 }
@@ -64,6 +68,26 @@ func (m *InMemoryStateManager) InitializeAgents(agentIDs []string) {
 	}
 }
 
+// AddAgents sets up initial state for agents that do not have any yet
+func (m *InMemoryStateManager) AddAgents(agentIDs []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	for _, id := range agentIDs {
+		if _, exists := m.agentStates[id]; exists {
+			continue
+		}
+		m.agentStates[id] = &AgentState{
+			ID:      id,
+			Status:  AgentStatusPending,
+			Inputs:  make(map[string]interface{}),
+			Outputs: make(map[string]interface{}),
+		}
+	}
+	
+	return nil
+}
+
 // Get retrieves a value from an agent's state
 func (m *InMemoryStateManager) Get(agentID, key string) (interface{}, error) {
 	m.mu.RLock()
@@ -78,6 +102,8 @@ func (m *InMemoryStateManager) Get(agentID, key string) (interface{}, error) {
 		return state.Inputs, nil
 	} else if key == "output" {
 		return state.Outputs, nil
+	} else if key == "attempts" {
+		return state.Attempts, nil
 	}
 	
 	value, exists := state.Outputs[key]
@@ -113,6 +139,13 @@ func (m *InMemoryStateManager) Set(agentID, key string, value interface{}) error
 		}
 		state.Outputs = outputMap
 		return nil
+	} else if key == "attempts" {
+		attempts, ok := value.([]AttemptRecord)
+		if !ok {
+			return errors.New("attempts must be a []AttemptRecord")
+		}
+		state.Attempts = attempts
+		return nil
 	}
 	
 	// Regular key-value setting
@@ -138,6 +171,10 @@ func (m *InMemoryStateManager) GetAll(agentID string) (map[string]interface{}, e
 		"outputs": make(map[string]interface{}),
 	}
 	
+	if len(state.Attempts) > 0 {
+		allState["attempts"] = append([]AttemptRecord(nil), state.Attempts...)
+	}
+	
 	// Copy inputs
 	for k, v := range state.Inputs {
 		allState["inputs"].(map[string]interface{})[k] = v
@@ -168,7 +205,7 @@ func (m *InMemoryStateManager) UpdateAgentStatus(agentID string, status AgentSta
 	now := time.Now().Unix()
 	if status == AgentStatusRunning && state.StartTime == 0 {
 		state.StartTime = now
-	} else if (status == AgentStatusCompleted || status == AgentStatusFailed) && state.EndTime == 0 {
+	} else if (status == AgentStatusCompleted || status == AgentStatusFailed || status == AgentStatusSkipped) && state.EndTime == 0 {
 		state.EndTime = now
 	}
 	
@@ -233,6 +270,7 @@ func (m *InMemoryStateManager) GetStackSummary() *StackExecutionSummary {
 			EndTime:      state.EndTime,
 			Inputs:       make(map[string]interface{}),
 			Outputs:      make(map[string]interface{}),
+			Attempts:     append([]AttemptRecord(nil), state.Attempts...),
 		}
 		
 		// Copy inputs and outputs
@@ -253,6 +291,8 @@ func (m *InMemoryStateManager) GetStackSummary() *StackExecutionSummary {
 			summary.FailedCount++
 		case AgentStatusBlocked:
 			summary.BlockedCount++
+		case AgentStatusSkipped:
+			summary.SkippedCount++
 		}
 	}
 	
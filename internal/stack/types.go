@@ -1,25 +1,36 @@
 package stack
 
+import "github.com/satishgonella2024/sentinelstacks/pkg/types"
+
 // StackSpec defines the structure of a multi-agent stack
 type StackSpec struct {
-	Name        string                 `json:"name" yaml:"name"`
-	Description string                 `json:"description" yaml:"description"`
-	Version     string                 `json:"version" yaml:"version"`
-	Agents      []StackAgentSpec       `json:"agents" yaml:"agents"`
-	Networks    []string               `json:"networks" yaml:"networks"`
-	Volumes     []string               `json:"volumes" yaml:"volumes"`
-	Metadata    map[string]interface{} `json:"metadata" yaml:"metadata"`
+	Name        string                  `json:"name" yaml:"name"`
+	Description string                  `json:"description" yaml:"description"`
+	Version     string                  `json:"version" yaml:"version"`
+	Agents      []StackAgentSpec        `json:"agents" yaml:"agents"`
+	Networks    []string                `json:"networks" yaml:"networks"`
+	Volumes     []string                `json:"volumes" yaml:"volumes"`
+	Metadata    map[string]interface{}  `json:"metadata" yaml:"metadata"`
+	MCPServers  []types.MCPServerConfig `json:"mcpServers,omitempty" yaml:"mcpServers,omitempty"`
 }
 
 // StackAgentSpec defines an individual agent within a stack
 type StackAgentSpec struct {
-	ID        string                 `json:"id" yaml:"id"`
-	Uses      string                 `json:"uses" yaml:"uses"`
-	InputFrom []string               `json:"inputFrom" yaml:"inputFrom"`
-	InputKey  string                 `json:"inputKey" yaml:"inputKey"`
-	OutputKey string                 `json:"outputKey" yaml:"outputKey"`
-	Params    map[string]interface{} `json:"params" yaml:"params"`
-	Depends   []string               `json:"depends" yaml:"depends"`
+	ID              string                 `json:"id" yaml:"id"`
+	Uses            string                 `json:"uses" yaml:"uses"`
+	InputFrom       []string               `json:"inputFrom" yaml:"inputFrom"`
+	InputKey        string                 `json:"inputKey" yaml:"inputKey"`
+	OutputKey       string                 `json:"outputKey" yaml:"outputKey"`
+	Params          map[string]interface{} `json:"params" yaml:"params"`
+	Depends         []string               `json:"depends" yaml:"depends"`
+	OnFailure       FailurePolicy          `json:"onFailure,omitempty" yaml:"onFailure,omitempty"`
+	Retry           *RetryPolicy           `json:"retry,omitempty" yaml:"retry,omitempty"`
+	Timeout         Duration               `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	When            string                 `json:"when,omitempty" yaml:"when,omitempty"`
+	ForEach         string                 `json:"forEach,omitempty" yaml:"forEach,omitempty"`
+	Inputs          map[string]string      `json:"inputs,omitempty" yaml:"inputs,omitempty"`
+	OutputSchema    *OutputSchema          `json:"outputSchema,omitempty" yaml:"outputSchema,omitempty"`
+	OnInvalidOutput string                 `json:"onInvalidOutput,omitempty" yaml:"onInvalidOutput,omitempty"`
 }
 
 // AgentState represents the current state of an agent in the execution flow
@@ -28,6 +39,7 @@ type AgentState struct {
 	Status       AgentStatus
 	Inputs       map[string]interface{}
 	Outputs      map[string]interface{}
+	Attempts     []AttemptRecord
 	ErrorMessage string
 	StartTime    int64
 	EndTime      int64
@@ -47,6 +59,8 @@ const (
 	AgentStatusFailed AgentStatus = "failed"
 	// AgentStatusBlocked indicates the agent is blocked on dependencies
 	AgentStatusBlocked AgentStatus = "blocked"
+	// AgentStatusSkipped indicates the agent was not executed because of an upstream failure
+	AgentStatusSkipped AgentStatus = "skipped"
 )
 
 // StackExecutionSummary provides a summary of the stack execution
@@ -58,5 +72,6 @@ type StackExecutionSummary struct {
 	CompletedCount int
 	FailedCount    int
 	BlockedCount   int
+	SkippedCount   int
 	AgentStates    map[string]AgentState
 }
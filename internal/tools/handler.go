@@ -8,8 +8,10 @@ import (
 	"time"
 )
 
-// FunctionCall represents a function call from an LLM
+// FunctionCall represents a function call from an LLM. ID is set by
+// providers with structured tool calling and identifies the call's result.
 type FunctionCall struct {
+	ID         string                 `json:"id,omitempty"`
 	Name       string                 `json:"name"`
 	Parameters map[string]interface{} `json:"parameters"`
 }
@@ -144,7 +146,8 @@ func (h *ToolHandler) HandleFunctionCall(ctx context.Context, agentID string, fu
 	return result
 }
 
-// ParseFunctionCall parses a function call string from an LLM
+// ParseFunctionCall parses a function call string from an LLM. It is the
+// fallback for models without structured tool calling.
 func ParseFunctionCall(functionCallStr string) (*FunctionCall, error) {
 	// Remove any potential prefixes like "function_call:" or similar
 	functionCallStr = strings.TrimSpace(functionCallStr)
@@ -0,0 +1,280 @@
+// Package mcp is a Model Context Protocol client. It connects to MCP
+// servers over stdio or streamable HTTP and exposes their tools and
+// resources as SentinelStacks tools.
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/satishgonella2024/sentinelstacks/pkg/types"
+)
+
+// ProtocolVersion is the MCP revision requested from servers
+const ProtocolVersion = "2025-06-18"
+
+// supportedVersions are the revisions a server may answer with
+var supportedVersions = map[string]bool{
+	"2025-06-18": true,
+	"2025-03-26": true,
+	"2024-11-05": true,
+}
+
+// ConnectTimeout bounds starting a server and the initialize handshake
+const ConnectTimeout = 30 * time.Second
+
+// Implementation names a client or server
+type Implementation struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+// InitializeParams are the parameters of the initialize request
+type InitializeParams struct {
+	ProtocolVersion string                 `json:"protocolVersion"`
+	Capabilities    map[string]interface{} `json:"capabilities"`
+	ClientInfo      Implementation         `json:"clientInfo"`
+}
+
+// ServerCapabilities lists the features a server offers. A feature is
+// offered when its field is present, even if empty.
+type ServerCapabilities struct {
+	Tools     map[string]interface{} `json:"tools"`
+	Resources map[string]interface{} `json:"resources"`
+	Prompts   map[string]interface{} `json:"prompts"`
+}
+
+// InitializeResult is a server's answer to the initialize request
+type InitializeResult struct {
+	ProtocolVersion string             `json:"protocolVersion"`
+	Capabilities    ServerCapabilities `json:"capabilities"`
+	ServerInfo      Implementation     `json:"serverInfo"`
+	Instructions    string             `json:"instructions,omitempty"`
+}
+
+// ToolInfo describes a tool offered by a server
+type ToolInfo struct {
+	Name        string                 `json:"name"`
+	Title       string                 `json:"title,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	InputSchema map[string]interface{} `json:"inputSchema"`
+}
+
+// Content is an item of a tool result
+type Content struct {
+	Type     string            `json:"type"`
+	Text     string            `json:"text,omitempty"`
+	Data     string            `json:"data,omitempty"`
+	MimeType string            `json:"mimeType,omitempty"`
+	URI      string            `json:"uri,omitempty"`
+	Name     string            `json:"name,omitempty"`
+	Resource *ResourceContents `json:"resource,omitempty"`
+}
+
+// CallToolResult is the result of a tool call. Errors of the tool itself
+// are reported with IsError rather than as protocol errors.
+type CallToolResult struct {
+	Content           []Content   `json:"content"`
+	StructuredContent interface{} `json:"structuredContent,omitempty"`
+	IsError           bool        `json:"isError,omitempty"`
+}
+
+// Text returns the content of the result as text. Binary content is
+// described rather than included.
+func (r *CallToolResult) Text() string {
+	var parts []string
+	for _, content := range r.Content {
+		switch content.Type {
+		case "text":
+			parts = append(parts, content.Text)
+		case "resource":
+			if content.Resource != nil {
+				parts = append(parts, content.Resource.String())
+			}
+		case "resource_link":
+			parts = append(parts, fmt.Sprintf("[resource %s]", content.URI))
+		default:
+			parts = append(parts, fmt.Sprintf("[%s content (%s)]", content.Type, content.MimeType))
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// Resource describes a resource offered by a server
+type Resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// ResourceContents is the content of a resource, as text or base64 blob
+type ResourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Blob     string `json:"blob,omitempty"`
+}
+
+// String returns the text of the resource, or a description of a blob
+func (c *ResourceContents) String() string {
+	if c.Blob != "" {
+		return fmt.Sprintf("[binary resource %s (%s)]", c.URI, c.MimeType)
+	}
+	return c.Text
+}
+
+// conn is a connection to a server over one of the transports
+type conn interface {
+	Call(ctx context.Context, method string, params, result interface{}) error
+	Notify(method string, params interface{}) error
+	Close() error
+}
+
+// Client is a connection to an MCP server
+type Client struct {
+	config types.MCPServerConfig
+	conn   conn
+	info   InitializeResult
+}
+
+// Connect starts or reaches the server and performs the initialize
+// handshake
+func Connect(ctx context.Context, config types.MCPServerConfig) (*Client, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, ConnectTimeout)
+	defer cancel()
+
+	var c conn
+	var err error
+	switch config.TransportType() {
+	case types.MCPTransportHTTP:
+		c = newHTTPConn(config)
+	default:
+		c, err = startStdioConn(config)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	client := &Client{config: config, conn: c}
+	if err := client.initialize(ctx); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("failed to initialize MCP server %s: %w", config.Name, err)
+	}
+	return client, nil
+}
+
+// initialize performs the handshake and tells the server it is done
+func (c *Client) initialize(ctx context.Context) error {
+	params := InitializeParams{
+		ProtocolVersion: ProtocolVersion,
+		Capabilities:    map[string]interface{}{},
+		ClientInfo:      Implementation{Name: "sentinelstacks"},
+	}
+	if err := c.conn.Call(ctx, "initialize", params, &c.info); err != nil {
+		return err
+	}
+	if !supportedVersions[c.info.ProtocolVersion] {
+		return fmt.Errorf("unsupported protocol version %q", c.info.ProtocolVersion)
+	}
+	if h, ok := c.conn.(*httpConn); ok {
+		h.setProtocolVersion(c.info.ProtocolVersion)
+	}
+
+	return c.conn.Notify("notifications/initialized", nil)
+}
+
+// Config returns the declaration of the server
+func (c *Client) Config() types.MCPServerConfig {
+	return c.config
+}
+
+// ServerInfo returns the server's answer to the handshake
+func (c *Client) ServerInfo() InitializeResult {
+	return c.info
+}
+
+// ListTools returns the tools offered by the server
+func (c *Client) ListTools(ctx context.Context) ([]ToolInfo, error) {
+	var all []ToolInfo
+	cursor := ""
+	for {
+		var page struct {
+			Tools      []ToolInfo `json:"tools"`
+			NextCursor string     `json:"nextCursor,omitempty"`
+		}
+		if err := c.conn.Call(ctx, "tools/list", pageParams(cursor), &page); err != nil {
+			return nil, fmt.Errorf("failed to list tools: %w", err)
+		}
+		all = append(all, page.Tools...)
+		if page.NextCursor == "" {
+			return all, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// CallTool calls a tool of the server
+func (c *Client) CallTool(ctx context.Context, name string, arguments map[string]interface{}) (*CallToolResult, error) {
+	if arguments == nil {
+		arguments = make(map[string]interface{})
+	}
+	params := map[string]interface{}{"name": name, "arguments": arguments}
+
+	var result CallToolResult
+	if err := c.conn.Call(ctx, "tools/call", params, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ListResources returns the resources offered by the server
+func (c *Client) ListResources(ctx context.Context) ([]Resource, error) {
+	var all []Resource
+	cursor := ""
+	for {
+		var page struct {
+			Resources  []Resource `json:"resources"`
+			NextCursor string     `json:"nextCursor,omitempty"`
+		}
+		if err := c.conn.Call(ctx, "resources/list", pageParams(cursor), &page); err != nil {
+			return nil, fmt.Errorf("failed to list resources: %w", err)
+		}
+		all = append(all, page.Resources...)
+		if page.NextCursor == "" {
+			return all, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// ReadResource returns the contents of a resource
+func (c *Client) ReadResource(ctx context.Context, uri string) ([]ResourceContents, error) {
+	var result struct {
+		Contents []ResourceContents `json:"contents"`
+	}
+	if err := c.conn.Call(ctx, "resources/read", map[string]string{"uri": uri}, &result); err != nil {
+		return nil, err
+	}
+	return result.Contents, nil
+}
+
+// Close ends the connection, stopping a stdio server
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// pageParams returns the parameters requesting a page of a list
+func pageParams(cursor string) interface{} {
+	if cursor == "" {
+		return map[string]interface{}{}
+	}
+	return map[string]string{"cursor": cursor}
+}
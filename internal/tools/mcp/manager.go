@@ -0,0 +1,142 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+	"sync"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/tools"
+	"github.com/satishgonella2024/sentinelstacks/pkg/types"
+)
+
+var (
+	manager     *Manager
+	managerOnce sync.Once
+)
+
+// GetManager returns the manager registering with the global tool registry
+func GetManager() *Manager {
+	managerOnce.Do(func() {
+		manager = NewManager(tools.GetRegistry())
+	})
+	return manager
+}
+
+// server is a connected server, the tools registered for it and how many
+// users added it
+type server struct {
+	client *Client
+	tools  []tools.Tool
+	refs   int
+}
+
+// Manager connects MCP servers and registers their tools. Each server is
+// connected once, however many agents declare it, and disconnected when
+// all of them have removed it.
+type Manager struct {
+	registry *tools.Registry
+
+	mu      sync.Mutex
+	servers map[string]*server
+}
+
+// NewManager returns a manager registering tools with registry
+func NewManager(registry *tools.Registry) *Manager {
+	return &Manager{
+		registry: registry,
+		servers:  make(map[string]*server),
+	}
+}
+
+// AddServer connects the server and registers its tools, returning them.
+// A server already connected under the same name returns its tools, unless
+// it was declared differently. Tools whose names are taken are skipped with
+// a warning. Every successful AddServer is released with RemoveServer.
+func (m *Manager) AddServer(ctx context.Context, config types.MCPServerConfig) ([]tools.Tool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if existing, ok := m.servers[config.Name]; ok {
+		if !reflect.DeepEqual(existing.client.Config(), config) {
+			return nil, fmt.Errorf("MCP server %s is already declared with a different configuration", config.Name)
+		}
+		existing.refs++
+		return existing.tools, nil
+	}
+
+	client, err := Connect(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+
+	serverTools, err := client.Tools(ctx)
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("MCP server %s: %w", config.Name, err)
+	}
+
+	var registered []tools.Tool
+	for _, tool := range serverTools {
+		if err := m.registry.RegisterTool(tool); err != nil {
+			log.Printf("Warning: Failed to register tool from MCP server %s: %v", config.Name, err)
+			continue
+		}
+		registered = append(registered, tool)
+	}
+
+	m.servers[config.Name] = &server{client: client, tools: registered, refs: 1}
+	return registered, nil
+}
+
+// Client returns the connection to a server added under name
+func (m *Manager) Client(name string) (*Client, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.servers[name]
+	if !ok {
+		return nil, false
+	}
+	return s.client, true
+}
+
+// RemoveServer releases a server added under name. Once every AddServer
+// of it is released, the server is disconnected and its tools unregistered.
+func (m *Manager) RemoveServer(name string) {
+	m.mu.Lock()
+	s, ok := m.servers[name]
+	if ok {
+		s.refs--
+		if s.refs > 0 {
+			ok = false
+		} else {
+			delete(m.servers, name)
+		}
+	}
+	m.mu.Unlock()
+
+	if ok {
+		m.disconnect(s)
+	}
+}
+
+// Close disconnects every server and unregisters their tools
+func (m *Manager) Close() {
+	m.mu.Lock()
+	servers := m.servers
+	m.servers = make(map[string]*server)
+	m.mu.Unlock()
+
+	for _, s := range servers {
+		m.disconnect(s)
+	}
+}
+
+// disconnect unregisters the tools of a server and closes its connection
+func (m *Manager) disconnect(s *server) {
+	for _, tool := range s.tools {
+		m.registry.UnregisterTool(tool.GetName())
+	}
+	s.client.Close()
+}
@@ -0,0 +1,375 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/jsonrpc"
+	"github.com/satishgonella2024/sentinelstacks/internal/tools"
+	"github.com/satishgonella2024/sentinelstacks/pkg/types"
+)
+
+// The test binary doubles as a stdio MCP server
+func TestMain(m *testing.M) {
+	if os.Getenv("SENTINEL_TEST_MCP") == "1" {
+		runStdioStub()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runStdioStub serves the stub on stdio
+func runStdioStub() {
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var message jsonrpc.Message
+		if err := json.Unmarshal(scanner.Bytes(), &message); err != nil {
+			continue
+		}
+		if response := handleStub(&message); response != nil {
+			data, _ := json.Marshal(response)
+			os.Stdout.Write(append(data, '\n'))
+		}
+	}
+}
+
+// handleStub answers a message sent to the stub server
+func handleStub(message *jsonrpc.Message) *jsonrpc.Message {
+	if len(message.ID) == 0 {
+		return nil
+	}
+
+	var result interface{}
+	switch message.Method {
+	case "initialize":
+		result = InitializeResult{
+			ProtocolVersion: ProtocolVersion,
+			Capabilities: ServerCapabilities{
+				Tools:     map[string]interface{}{},
+				Resources: map[string]interface{}{},
+			},
+			ServerInfo: Implementation{Name: "stub", Version: "1.0.0"},
+		}
+	case "tools/list":
+		var params struct {
+			Cursor string `json:"cursor"`
+		}
+		json.Unmarshal(message.Params, &params)
+		if params.Cursor == "" {
+			result = map[string]interface{}{
+				"tools": []ToolInfo{{
+					Name:        "echo",
+					Description: "Echo text",
+					InputSchema: map[string]interface{}{
+						"type":       "object",
+						"properties": map[string]interface{}{"text": map[string]interface{}{"type": "string"}},
+						"required":   []string{"text"},
+					},
+				}},
+				"nextCursor": "2",
+			}
+		} else {
+			result = map[string]interface{}{
+				"tools": []ToolInfo{
+					{Name: "add", Description: "Add numbers", InputSchema: map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"a": map[string]interface{}{"type": "number"},
+							"b": map[string]interface{}{"type": "number"},
+						},
+					}},
+					{Name: "fail", Description: "Fail"},
+				},
+			}
+		}
+	case "tools/call":
+		var params struct {
+			Name      string                 `json:"name"`
+			Arguments map[string]interface{} `json:"arguments"`
+		}
+		json.Unmarshal(message.Params, &params)
+		switch params.Name {
+		case "echo":
+			result = CallToolResult{Content: []Content{{Type: "text", Text: fmt.Sprint(params.Arguments["text"])}}}
+		case "add":
+			a, _ := params.Arguments["a"].(float64)
+			b, _ := params.Arguments["b"].(float64)
+			result = CallToolResult{
+				Content:           []Content{{Type: "text", Text: fmt.Sprint(a + b)}},
+				StructuredContent: map[string]interface{}{"sum": a + b},
+			}
+		case "fail":
+			result = CallToolResult{Content: []Content{{Type: "text", Text: "boom"}}, IsError: true}
+		default:
+			return &jsonrpc.Message{JSONRPC: jsonrpc.Version, ID: message.ID, Error: &jsonrpc.Error{Code: jsonrpc.CodeInvalidParams, Message: "unknown tool"}}
+		}
+	case "resources/list":
+		result = map[string]interface{}{"resources": []Resource{{URI: "stub://readme", Name: "readme", MimeType: "text/plain"}}}
+	case "resources/read":
+		result = map[string]interface{}{"contents": []ResourceContents{{URI: "stub://readme", MimeType: "text/plain", Text: "hello from stub"}}}
+	default:
+		return &jsonrpc.Message{JSONRPC: jsonrpc.Version, ID: message.ID, Error: &jsonrpc.Error{Code: jsonrpc.CodeMethodNotFound, Message: "method not found"}}
+	}
+
+	data, _ := json.Marshal(result)
+	return &jsonrpc.Message{JSONRPC: jsonrpc.Version, ID: message.ID, Result: data}
+}
+
+// stdioConfig declares the test binary as a stdio server
+func stdioConfig(t *testing.T) types.MCPServerConfig {
+	t.Helper()
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatalf("Failed to find test binary: %v", err)
+	}
+	return types.MCPServerConfig{
+		Name:    "stub",
+		Command: executable,
+		Env:     map[string]string{"SENTINEL_TEST_MCP": "1"},
+	}
+}
+
+func TestStdioServer(t *testing.T) {
+	registry := tools.NewRegistry()
+	manager := NewManager(registry)
+	defer manager.Close()
+
+	registered, err := manager.AddServer(context.Background(), stdioConfig(t))
+	if err != nil {
+		t.Fatalf("AddServer failed: %v", err)
+	}
+	if len(registered) != 5 {
+		t.Fatalf("Expected 3 tools and 2 resource tools, got %d", len(registered))
+	}
+
+	// Tools are named after the server and need the api permission
+	echo, err := registry.GetTool("stub/echo")
+	if err != nil {
+		t.Fatalf("Expected stub/echo to be registered: %v", err)
+	}
+	if echo.RequiredPermission() != tools.PermissionAPI {
+		t.Errorf("Expected api permission, got %s", echo.RequiredPermission())
+	}
+	if params := echo.GetParameters(); len(params) != 1 || params[0].Name != "text" || !params[0].Required {
+		t.Errorf("Expected parameters from the input schema, got %+v", params)
+	}
+	if schema := tools.GenerateSchema(echo); schema.Parameters["required"] == nil {
+		t.Errorf("Expected the server's input schema, got %v", schema.Parameters)
+	}
+
+	result, err := tools.ExecuteTool(context.Background(), echo, map[string]interface{}{"text": "hi"})
+	if err != nil || result != "hi" {
+		t.Errorf("Expected echoed text, got %v, %v", result, err)
+	}
+
+	// Structured content is returned as is
+	add, _ := registry.GetTool("stub/add")
+	result, err = add.Execute(context.Background(), map[string]interface{}{"a": 2, "b": 3})
+	if err != nil {
+		t.Fatalf("stub/add failed: %v", err)
+	}
+	if sum := result.(map[string]interface{})["sum"]; sum != float64(5) {
+		t.Errorf("Expected sum 5, got %v", sum)
+	}
+
+	// Tool errors are returned as errors
+	fail, _ := registry.GetTool("stub/fail")
+	if _, err := fail.Execute(context.Background(), nil); err == nil || err.Error() != "boom" {
+		t.Errorf("Expected tool error, got %v", err)
+	}
+
+	// Resources can be listed and read
+	list, _ := registry.GetTool("stub/list_resources")
+	result, err = list.Execute(context.Background(), nil)
+	if resources, _ := result.([]Resource); err != nil || len(resources) != 1 || resources[0].URI != "stub://readme" {
+		t.Errorf("Unexpected resources: %v, %v", result, err)
+	}
+	read, _ := registry.GetTool("stub/read_resource")
+	result, err = read.Execute(context.Background(), map[string]interface{}{"uri": "stub://readme"})
+	if contents, _ := result.([]ResourceContents); err != nil || len(contents) != 1 || contents[0].Text != "hello from stub" {
+		t.Errorf("Unexpected resource contents: %v, %v", result, err)
+	}
+
+	// The same declaration reuses the connection; a different one is refused
+	again, err := manager.AddServer(context.Background(), stdioConfig(t))
+	if err != nil || len(again) != len(registered) {
+		t.Errorf("Expected the server to be reused, got %d tools, %v", len(again), err)
+	}
+	changed := stdioConfig(t)
+	changed.Args = []string{"-x"}
+	if _, err := manager.AddServer(context.Background(), changed); err == nil {
+		t.Error("Expected a conflicting declaration to be refused")
+	}
+}
+
+func TestRemoveServer(t *testing.T) {
+	registry := tools.NewRegistry()
+	manager := NewManager(registry)
+	defer manager.Close()
+
+	// Two users of the server share the connection
+	for i := 0; i < 2; i++ {
+		if _, err := manager.AddServer(context.Background(), stdioConfig(t)); err != nil {
+			t.Fatalf("AddServer failed: %v", err)
+		}
+	}
+
+	// Its tools stay until the last user removes it
+	manager.RemoveServer("stub")
+	if _, err := registry.GetTool("stub/echo"); err != nil {
+		t.Errorf("Expected stub/echo to stay registered: %v", err)
+	}
+	manager.RemoveServer("stub")
+	if _, err := registry.GetTool("stub/echo"); err == nil {
+		t.Error("Expected stub/echo to be unregistered")
+	}
+
+	// A removed server can be added again
+	if _, err := manager.AddServer(context.Background(), stdioConfig(t)); err != nil {
+		t.Fatalf("Expected the server to reconnect, got %v", err)
+	}
+	manager.Close()
+	if _, err := registry.GetTool("stub/echo"); err == nil {
+		t.Error("Expected Close to unregister stub/echo")
+	}
+}
+
+func TestHTTPServer(t *testing.T) {
+	const sessionID = "session-1"
+
+	var mu sync.Mutex
+	var methods []string
+	var deleted bool
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if r.Method == http.MethodDelete {
+			mu.Lock()
+			deleted = r.Header.Get("Mcp-Session-Id") == sessionID
+			mu.Unlock()
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		var message jsonrpc.Message
+		json.Unmarshal(body, &message)
+
+		mu.Lock()
+		methods = append(methods, message.Method)
+		mu.Unlock()
+
+		if message.Method == "initialize" {
+			w.Header().Set("Mcp-Session-Id", sessionID)
+		} else if r.Header.Get("Mcp-Session-Id") != sessionID {
+			http.Error(w, "missing session", http.StatusBadRequest)
+			return
+		}
+
+		response := handleStub(&message)
+		if response == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		data, _ := json.Marshal(response)
+
+		// Tool calls are answered on an event stream, after a notification
+		if message.Method == "tools/call" {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprintf(w, "event: message\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/progress\",\"params\":{}}\n\n")
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", data)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	config := types.MCPServerConfig{
+		Name:       "remote",
+		URL:        server.URL,
+		Headers:    map[string]string{"Authorization": "Bearer secret"},
+		Permission: "network",
+		Tools:      []string{"echo"},
+	}
+
+	registry := tools.NewRegistry()
+	manager := NewManager(registry)
+	registered, err := manager.AddServer(context.Background(), config)
+	if err != nil {
+		t.Fatalf("AddServer failed: %v", err)
+	}
+
+	// Only the listed tool is used, with the declared permission
+	var names []string
+	for _, tool := range registered {
+		names = append(names, tool.GetName())
+		if tool.RequiredPermission() != tools.PermissionNetwork {
+			t.Errorf("Expected network permission for %s, got %s", tool.GetName(), tool.RequiredPermission())
+		}
+	}
+	expected := []string{"remote/echo", "remote/list_resources", "remote/read_resource"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected tools %v, got %v", expected, names)
+	}
+
+	echo, _ := registry.GetTool("remote/echo")
+	result, err := echo.Execute(context.Background(), map[string]interface{}{"text": "over http"})
+	if err != nil || result != "over http" {
+		t.Errorf("Expected echoed text, got %v, %v", result, err)
+	}
+
+	manager.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(methods) < 2 || methods[0] != "initialize" || methods[1] != "notifications/initialized" {
+		t.Errorf("Expected the handshake to come first, got %v", methods)
+	}
+	if !deleted {
+		t.Error("Expected the session to be deleted on close")
+	}
+
+	// Requests without the configured headers are refused
+	config.Headers = nil
+	if _, err := Connect(context.Background(), config); err == nil {
+		t.Error("Expected connecting without the authorization header to fail")
+	}
+}
+
+func TestServerConfigValidate(t *testing.T) {
+	valid := []types.MCPServerConfig{
+		{Name: "fs", Command: "mcp-server-filesystem"},
+		{Name: "remote", URL: "https://example.com/mcp"},
+	}
+	for _, config := range valid {
+		if err := config.Validate(); err != nil {
+			t.Errorf("Expected %+v to be valid, got %v", config, err)
+		}
+	}
+
+	invalid := []types.MCPServerConfig{
+		{Command: "server"},
+		{Name: "a/b", Command: "server"},
+		{Name: "fs"},
+		{Name: "remote", Transport: "http"},
+		{Name: "remote", Transport: "websocket", URL: "ws://example.com"},
+	}
+	for _, config := range invalid {
+		if err := config.Validate(); err == nil {
+			t.Errorf("Expected %+v to be invalid", config)
+		}
+	}
+}
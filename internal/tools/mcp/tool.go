@@ -0,0 +1,232 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/jsonrpc"
+	"github.com/satishgonella2024/sentinelstacks/internal/tools"
+)
+
+// validPermissions are the permissions a server may be declared with
+var validPermissions = map[tools.Permission]bool{
+	tools.PermissionNone:    true,
+	tools.PermissionFile:    true,
+	tools.PermissionNetwork: true,
+	tools.PermissionAPI:     true,
+	tools.PermissionShell:   true,
+	tools.PermissionAll:     true,
+}
+
+// permission returns the permission agents need to use the server's tools
+func (c *Client) permission() (tools.Permission, error) {
+	if c.config.Permission == "" {
+		return tools.PermissionAPI, nil
+	}
+	perm := tools.Permission(c.config.Permission)
+	if !validPermissions[perm] {
+		return "", fmt.Errorf("MCP server %s: invalid permission %q", c.config.Name, c.config.Permission)
+	}
+	return perm, nil
+}
+
+// Tools returns proxies for the server's tools, limited to those listed in
+// the server's declaration. Servers offering resources also get tools to
+// list and read them.
+func (c *Client) Tools(ctx context.Context) ([]tools.Tool, error) {
+	perm, err := c.permission()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []tools.Tool
+	if c.info.Capabilities.Tools != nil {
+		infos, err := c.ListTools(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		wanted := make(map[string]bool, len(c.config.Tools))
+		for _, name := range c.config.Tools {
+			wanted[name] = true
+		}
+
+		for _, info := range infos {
+			if len(wanted) > 0 && !wanted[info.Name] {
+				continue
+			}
+			result = append(result, newTool(c, info, perm))
+		}
+	}
+
+	if c.info.Capabilities.Resources != nil {
+		result = append(result, newListResourcesTool(c, perm), newReadResourceTool(c, perm))
+	}
+
+	return result, nil
+}
+
+// Tool is a proxy for a tool of an MCP server, named <server>/<tool>
+type Tool struct {
+	tools.BaseTool
+	client *Client
+	remote string
+	schema map[string]interface{}
+}
+
+// newTool returns a proxy for a tool described by the server
+func newTool(client *Client, info ToolInfo, perm tools.Permission) *Tool {
+	description := info.Description
+	if description == "" {
+		description = info.Title
+	}
+
+	schema := info.InputSchema
+	if schema == nil {
+		schema = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+	}
+
+	return &Tool{
+		BaseTool: tools.BaseTool{
+			Name:        client.config.Name + "/" + info.Name,
+			Description: description,
+			Parameters:  schemaParameters(schema),
+			Permission:  perm,
+		},
+		client: client,
+		remote: info.Name,
+		schema: schema,
+	}
+}
+
+// ParameterSchema returns the input schema declared by the server
+func (t *Tool) ParameterSchema() map[string]interface{} {
+	return t.schema
+}
+
+// Execute calls the tool on the server. Results with structured content
+// return it; others return their text.
+func (t *Tool) Execute(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	result, err := t.client.CallTool(ctx, t.remote, params)
+	if err != nil {
+		return nil, t.client.wrapError(err)
+	}
+	if result.IsError {
+		return nil, errors.New(result.Text())
+	}
+	if result.StructuredContent != nil {
+		return result.StructuredContent, nil
+	}
+	return result.Text(), nil
+}
+
+// schemaParameters derives parameters from the properties of a JSON schema,
+// for validation and for callers that do not use the schema itself
+func schemaParameters(schema map[string]interface{}) []tools.Parameter {
+	properties, _ := schema["properties"].(map[string]interface{})
+
+	required := make(map[string]bool)
+	if names, ok := schema["required"].([]interface{}); ok {
+		for _, name := range names {
+			if s, ok := name.(string); ok {
+				required[s] = true
+			}
+		}
+	}
+
+	params := make([]tools.Parameter, 0, len(properties))
+	for name, value := range properties {
+		property, _ := value.(map[string]interface{})
+		paramType, _ := property["type"].(string)
+		description, _ := property["description"].(string)
+		params = append(params, tools.Parameter{
+			Name:        name,
+			Type:        paramType,
+			Description: description,
+			Required:    required[name],
+			Default:     property["default"],
+		})
+	}
+
+	sort.Slice(params, func(i, j int) bool {
+		return params[i].Name < params[j].Name
+	})
+	return params
+}
+
+// wrapError names the server in errors other than those of the protocol
+func (c *Client) wrapError(err error) error {
+	var rpcErr *jsonrpc.Error
+	if errors.As(err, &rpcErr) {
+		return fmt.Errorf("MCP server %s: %s", c.config.Name, rpcErr.Message)
+	}
+	return fmt.Errorf("MCP server %s: %w", c.config.Name, err)
+}
+
+// ListResourcesTool lists the resources of an MCP server
+type ListResourcesTool struct {
+	tools.BaseTool
+	client *Client
+}
+
+func newListResourcesTool(client *Client, perm tools.Permission) *ListResourcesTool {
+	return &ListResourcesTool{
+		BaseTool: tools.BaseTool{
+			Name:        client.config.Name + "/list_resources",
+			Description: fmt.Sprintf("List the resources offered by the %s MCP server", client.config.Name),
+			Parameters:  []tools.Parameter{},
+			Permission:  perm,
+		},
+		client: client,
+	}
+}
+
+// Execute returns the server's resources
+func (t *ListResourcesTool) Execute(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	resources, err := t.client.ListResources(ctx)
+	if err != nil {
+		return nil, t.client.wrapError(err)
+	}
+	return resources, nil
+}
+
+// ReadResourceTool reads a resource of an MCP server
+type ReadResourceTool struct {
+	tools.BaseTool
+	client *Client
+}
+
+func newReadResourceTool(client *Client, perm tools.Permission) *ReadResourceTool {
+	return &ReadResourceTool{
+		BaseTool: tools.BaseTool{
+			Name:        client.config.Name + "/read_resource",
+			Description: fmt.Sprintf("Read a resource of the %s MCP server by its URI", client.config.Name),
+			Parameters: []tools.Parameter{
+				{
+					Name:        "uri",
+					Type:        "string",
+					Description: "URI of the resource, as returned by " + client.config.Name + "/list_resources",
+					Required:    true,
+				},
+			},
+			Permission: perm,
+		},
+		client: client,
+	}
+}
+
+// Execute returns the contents of the resource
+func (t *ReadResourceTool) Execute(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	uri, ok := params["uri"].(string)
+	if !ok || uri == "" {
+		return nil, fmt.Errorf("uri parameter is required")
+	}
+
+	contents, err := t.client.ReadResource(ctx, uri)
+	if err != nil {
+		return nil, t.client.wrapError(err)
+	}
+	return contents, nil
+}
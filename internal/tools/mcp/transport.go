@@ -0,0 +1,353 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/jsonrpc"
+	"github.com/satishgonella2024/sentinelstacks/pkg/types"
+)
+
+// ShutdownTimeout bounds waiting for a stdio server to exit
+const ShutdownTimeout = 2 * time.Second
+
+// cancelledParams are the parameters of notifications/cancelled
+type cancelledParams struct {
+	RequestID int64  `json:"requestId"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// handleServerRequest answers requests sent by a server. Only ping is
+// supported, since the client declares no capabilities.
+func handleServerRequest(method string, params json.RawMessage) (interface{}, error) {
+	if method == "ping" {
+		return map[string]interface{}{}, nil
+	}
+	return nil, &jsonrpc.Error{Code: jsonrpc.CodeMethodNotFound, Message: "method not found: " + method}
+}
+
+// stdioConn talks to a server running as a child process
+type stdioConn struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	client *jsonrpc.Client
+	exited chan struct{}
+	once   sync.Once
+}
+
+// startStdioConn starts the server process
+func startStdioConn(config types.MCPServerConfig) (*stdioConn, error) {
+	cmd := exec.Command(config.Command, config.Args...)
+	cmd.Env = os.Environ()
+	for key, value := range config.Env {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+
+	// Servers log to stderr
+	cmd.Stderr = &lineLogger{prefix: fmt.Sprintf("[mcp %s] ", config.Name)}
+	cmd.WaitDelay = ShutdownTimeout
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start MCP server %s: %w", config.Name, err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start MCP server %s: %w", config.Name, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start MCP server %s: %w", config.Name, err)
+	}
+
+	c := &stdioConn{cmd: cmd, stdin: stdin, exited: make(chan struct{})}
+	var client *jsonrpc.Client
+	client = jsonrpc.NewClient(stdout, stdin,
+		jsonrpc.WithRequestHandler(handleServerRequest),
+		jsonrpc.WithCancelFunc(func(id int64) {
+			client.Notify("notifications/cancelled", cancelledParams{RequestID: id, Reason: "request abandoned"})
+		}))
+	c.client = client
+
+	go func() {
+		<-client.Done()
+		cmd.Wait()
+		close(c.exited)
+	}()
+
+	return c, nil
+}
+
+func (c *stdioConn) Call(ctx context.Context, method string, params, result interface{}) error {
+	return c.client.Call(ctx, method, params, result)
+}
+
+func (c *stdioConn) Notify(method string, params interface{}) error {
+	return c.client.Notify(method, params)
+}
+
+// Close closes the server's stdin and kills it if it does not exit in time
+func (c *stdioConn) Close() error {
+	c.once.Do(func() {
+		c.stdin.Close()
+		select {
+		case <-c.exited:
+		case <-time.After(ShutdownTimeout):
+			c.cmd.Process.Kill()
+			select {
+			case <-c.exited:
+			case <-time.After(ShutdownTimeout):
+			}
+		}
+	})
+	return nil
+}
+
+// httpConn talks to a server over the streamable HTTP transport. Each
+// message is POSTed to the endpoint; responses come back as JSON or as a
+// stream of server-sent events.
+type httpConn struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+	nextID  int64
+
+	mu              sync.Mutex
+	sessionID       string
+	protocolVersion string
+}
+
+// newHTTPConn returns a connection to the server's endpoint
+func newHTTPConn(config types.MCPServerConfig) *httpConn {
+	return &httpConn{
+		url:     config.URL,
+		headers: config.Headers,
+		client:  &http.Client{},
+	}
+}
+
+// setProtocolVersion sets the negotiated revision sent with later requests
+func (c *httpConn) setProtocolVersion(version string) {
+	c.mu.Lock()
+	c.protocolVersion = version
+	c.mu.Unlock()
+}
+
+func (c *httpConn) Call(ctx context.Context, method string, params, result interface{}) error {
+	id := atomic.AddInt64(&c.nextID, 1)
+	rawID := json.RawMessage(strconv.FormatInt(id, 10))
+
+	resp, err := c.post(ctx, rawID, method, params)
+	if err != nil {
+		if ctx.Err() != nil {
+			c.cancel(id)
+			return ctx.Err()
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return httpError(resp)
+	}
+
+	message, err := c.readResponse(resp, rawID)
+	if err != nil {
+		if ctx.Err() != nil {
+			c.cancel(id)
+			return ctx.Err()
+		}
+		return fmt.Errorf("failed to read response to %s: %w", method, err)
+	}
+	if message.Error != nil {
+		return message.Error
+	}
+	if result == nil || len(message.Result) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(message.Result, result); err != nil {
+		return fmt.Errorf("failed to decode result of %s: %w", method, err)
+	}
+	return nil
+}
+
+func (c *httpConn) Notify(method string, params interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), ConnectTimeout)
+	defer cancel()
+
+	resp, err := c.post(ctx, nil, method, params)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusOK {
+		return httpError(resp)
+	}
+	return nil
+}
+
+// Close ends the session on the server, if it issued one
+func (c *httpConn) Close() error {
+	c.mu.Lock()
+	sessionID := c.sessionID
+	c.mu.Unlock()
+	if sessionID == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, c.url, nil)
+	if err != nil {
+		return err
+	}
+	c.setHeaders(req)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// post sends a message to the endpoint, remembering the session the server
+// assigns in its answer to initialize
+func (c *httpConn) post(ctx context.Context, id json.RawMessage, method string, params interface{}) (*http.Response, error) {
+	message := jsonrpc.Message{JSONRPC: jsonrpc.Version, ID: id, Method: method}
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode parameters of %s: %w", method, err)
+		}
+		message.Params = data
+	}
+	body, err := json.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("invalid MCP server url: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	c.setHeaders(req)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if sessionID := resp.Header.Get("Mcp-Session-Id"); sessionID != "" && method == "initialize" {
+		c.mu.Lock()
+		c.sessionID = sessionID
+		c.mu.Unlock()
+	}
+	return resp, nil
+}
+
+// setHeaders adds the configured headers and the session to a request
+func (c *httpConn) setHeaders(req *http.Request) {
+	for key, value := range c.headers {
+		req.Header.Set(key, value)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.sessionID != "" {
+		req.Header.Set("Mcp-Session-Id", c.sessionID)
+	}
+	if c.protocolVersion != "" {
+		req.Header.Set("MCP-Protocol-Version", c.protocolVersion)
+	}
+}
+
+// readResponse returns the response with the given ID from a JSON body or
+// an event stream. Other messages on the stream are skipped.
+func (c *httpConn) readResponse(resp *http.Response, id json.RawMessage) (*jsonrpc.Message, error) {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/event-stream" {
+		var message jsonrpc.Message
+		if err := json.NewDecoder(resp.Body).Decode(&message); err != nil {
+			return nil, err
+		}
+		return &message, nil
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	var data []string
+	for {
+		line, err := reader.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+
+		switch {
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		case line == "" && len(data) > 0:
+			// A blank line ends the event
+			var message jsonrpc.Message
+			if json.Unmarshal([]byte(strings.Join(data, "\n")), &message) == nil &&
+				message.IsResponse() && bytes.Equal(message.ID, id) {
+				return &message, nil
+			}
+			data = nil
+		}
+
+		if err != nil {
+			if err == io.EOF {
+				return nil, fmt.Errorf("event stream ended without a response")
+			}
+			return nil, err
+		}
+	}
+}
+
+// cancel tells the server to abandon a request, without waiting for it
+func (c *httpConn) cancel(id int64) {
+	go c.Notify("notifications/cancelled", cancelledParams{RequestID: id, Reason: "request abandoned"})
+}
+
+// httpError describes a failed request
+func httpError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if text := strings.TrimSpace(string(body)); text != "" {
+		return fmt.Errorf("MCP server returned %s: %s", resp.Status, text)
+	}
+	return fmt.Errorf("MCP server returned %s", resp.Status)
+}
+
+// lineLogger logs each line written to it
+type lineLogger struct {
+	prefix string
+	buf    []byte
+}
+
+func (l *lineLogger) Write(p []byte) (int, error) {
+	l.buf = append(l.buf, p...)
+	for {
+		i := bytes.IndexByte(l.buf, '\n')
+		if i < 0 {
+			break
+		}
+		log.Printf("%s%s", l.prefix, l.buf[:i])
+		l.buf = l.buf[i+1:]
+	}
+	return len(p), nil
+}
@@ -0,0 +1,110 @@
+package tools
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// ErrToolsNotSupported is returned by providers when a model cannot call
+// tools natively; callers fall back to describing tools in the prompt
+var ErrToolsNotSupported = errors.New("model does not support tool calling")
+
+// Message roles of a tool-calling conversation
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleTool      = "tool"
+)
+
+// Message is a turn of a conversation in which the model can call tools.
+// Assistant messages carry the calls the model made; tool messages carry
+// the result of one call, matched to it by ToolCallID.
+type Message struct {
+	Role       string         `json:"role"`
+	Content    string         `json:"content,omitempty"`
+	ToolCalls  []FunctionCall `json:"tool_calls,omitempty"`
+	ToolCallID string         `json:"tool_call_id,omitempty"`
+	Name       string         `json:"name,omitempty"`
+	IsError    bool           `json:"is_error,omitempty"`
+}
+
+// ChatResponse is a model's reply in a tool-calling conversation. When
+// ToolCalls is not empty the model is waiting for their results.
+type ChatResponse struct {
+	Content    string         `json:"content"`
+	ToolCalls  []FunctionCall `json:"tool_calls,omitempty"`
+	StopReason string         `json:"stop_reason,omitempty"`
+}
+
+// AssistantMessage returns the response as a message to append to the
+// conversation
+func (r *ChatResponse) AssistantMessage() Message {
+	return Message{
+		Role:      RoleAssistant,
+		Content:   r.Content,
+		ToolCalls: r.ToolCalls,
+	}
+}
+
+// NewToolMessage returns the message reporting the result of a call
+func NewToolMessage(call *FunctionCall, result *FunctionResult) Message {
+	return Message{
+		Role:       RoleTool,
+		Content:    FormatResultContent(result),
+		ToolCallID: call.ID,
+		Name:       call.Name,
+		IsError:    result.Error != "",
+	}
+}
+
+// FormatResultContent encodes a function result as the JSON content of a
+// tool message
+func FormatResultContent(result *FunctionResult) string {
+	var value interface{} = result.Result
+	if result.Error != "" {
+		value = map[string]string{"error": result.Error}
+	}
+
+	content, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf(`{"error": "result of %s could not be encoded"}`, result.Name)
+	}
+	return string(content)
+}
+
+// ParseArguments decodes the JSON arguments of a structured tool call. An
+// empty string is no arguments.
+func ParseArguments(arguments string) (map[string]interface{}, error) {
+	params := make(map[string]interface{})
+	if arguments == "" {
+		return params, nil
+	}
+	if err := json.Unmarshal([]byte(arguments), &params); err != nil {
+		return nil, fmt.Errorf("invalid tool call arguments: %w", err)
+	}
+	return params, nil
+}
+
+// invalidWireChars matches characters providers do not allow in tool names
+var invalidWireChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// WireName returns the name a tool is declared to providers under. Tool
+// names such as "file/read" contain characters providers reject, so they
+// are replaced with underscores.
+func WireName(name string) string {
+	return invalidWireChars.ReplaceAllString(name, "_")
+}
+
+// ResolveWireName returns the tool name a provider's tool call refers to,
+// or the wire name itself if no schema declares it
+func ResolveWireName(schemas []Schema, wireName string) string {
+	for _, schema := range schemas {
+		if WireName(schema.Name) == wireName {
+			return schema.Name
+		}
+	}
+	return wireName
+}
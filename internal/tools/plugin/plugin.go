@@ -0,0 +1,279 @@
+// Package plugin runs tools provided by external executables. A plugin is
+// started once, describes its tools in a JSON-RPC handshake over stdio and
+// then serves calls to them until it is shut down.
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/jsonrpc"
+	"github.com/satishgonella2024/sentinelstacks/internal/tools"
+)
+
+// Timeouts of the plugin lifecycle
+const (
+	HandshakeTimeout = 10 * time.Second
+	ShutdownTimeout  = 2 * time.Second
+)
+
+// validPermissions are the permissions a plugin tool may declare
+var validPermissions = map[tools.Permission]bool{
+	tools.PermissionNone:    true,
+	tools.PermissionFile:    true,
+	tools.PermissionNetwork: true,
+	tools.PermissionAPI:     true,
+	tools.PermissionShell:   true,
+	tools.PermissionAll:     true,
+}
+
+// Plugin is a running plugin process
+type Plugin struct {
+	path string
+
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	client *jsonrpc.Client
+	info   *InitializeResult
+	exited chan struct{}
+}
+
+// Start starts the plugin executable at path and performs the handshake
+func Start(ctx context.Context, path string) (*Plugin, error) {
+	p := &Plugin{path: path}
+	if err := p.start(ctx); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Path returns the path of the plugin executable
+func (p *Plugin) Path() string {
+	return p.path
+}
+
+// Info returns the plugin's answer to the handshake
+func (p *Plugin) Info() *InitializeResult {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.info
+}
+
+// Tools returns proxies for the plugin's tools. Tools declaring an invalid
+// name or permission are left out with a warning.
+func (p *Plugin) Tools() []tools.Tool {
+	info := p.Info()
+
+	var result []tools.Tool
+	for _, spec := range info.Tools {
+		if spec.Name == "" {
+			log.Printf("Warning: plugin %s declares a tool without a name", info.Name)
+			continue
+		}
+		if !validPermissions[spec.Permission] {
+			log.Printf("Warning: plugin %s declares tool %s with invalid permission %q", info.Name, spec.Name, spec.Permission)
+			continue
+		}
+		result = append(result, &Tool{
+			BaseTool: tools.BaseTool{
+				Name:        spec.Name,
+				Description: spec.Description,
+				Parameters:  spec.Parameters,
+				Permission:  spec.Permission,
+			},
+			plugin: p,
+		})
+	}
+	return result
+}
+
+// Call runs one of the plugin's tools. The plugin is restarted if it has
+// exited since the last call. When ctx ends first, the plugin is told to
+// abandon the call.
+func (p *Plugin) Call(ctx context.Context, name string, arguments, settings map[string]interface{}) (interface{}, error) {
+	client, err := p.running(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if arguments == nil {
+		arguments = make(map[string]interface{})
+	}
+	params := CallToolParams{Name: name, Arguments: arguments, Settings: settings}
+
+	var result interface{}
+	if err := client.Call(ctx, MethodCallTool, params, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Close asks the plugin to shut down and kills it if it does not exit in
+// time
+func (p *Plugin) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stop()
+}
+
+// running returns the client of the plugin process, restarting the
+// process if it has exited
+func (p *Plugin) running(ctx context.Context) (*jsonrpc.Client, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	select {
+	case <-p.client.Done():
+		log.Printf("Plugin %s exited, restarting it", p.path)
+		p.stop()
+		if err := p.start(ctx); err != nil {
+			return nil, err
+		}
+	default:
+	}
+	return p.client, nil
+}
+
+// start starts the process and performs the handshake. It is called with
+// the lock held or before the plugin is shared.
+func (p *Plugin) start(ctx context.Context) error {
+	cmd := exec.Command(p.path)
+	cmd.Dir = filepath.Dir(p.path)
+	cmd.Env = append(os.Environ(), fmt.Sprintf("SENTINEL_PLUGIN_PROTOCOL=%d", ProtocolVersion))
+
+	// Plugins log to stderr
+	name := filepath.Base(p.path)
+	cmd.Stderr = &lineLogger{prefix: fmt.Sprintf("[plugin %s] ", name)}
+	cmd.WaitDelay = ShutdownTimeout
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("failed to start plugin %s: %w", p.path, err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to start plugin %s: %w", p.path, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start plugin %s: %w", p.path, err)
+	}
+
+	// The cancel function must not take the lock, which is held while the
+	// handshake runs
+	var client *jsonrpc.Client
+	client = jsonrpc.NewClient(stdout, stdin, jsonrpc.WithCancelFunc(func(id int64) {
+		client.Notify(MethodCancel, CancelParams{ID: id})
+	}))
+	exited := make(chan struct{})
+	go func() {
+		<-client.Done()
+		cmd.Wait()
+		close(exited)
+	}()
+
+	p.cmd, p.stdin, p.client, p.exited = cmd, stdin, client, exited
+
+	ctx, cancel := context.WithTimeout(ctx, HandshakeTimeout)
+	defer cancel()
+
+	var info InitializeResult
+	params := InitializeParams{ProtocolVersion: ProtocolVersion, Runtime: "sentinel"}
+	if err := client.Call(ctx, MethodInitialize, params, &info); err != nil {
+		p.stop()
+		return fmt.Errorf("handshake with plugin %s failed: %w", p.path, err)
+	}
+	if info.ProtocolVersion != ProtocolVersion {
+		p.stop()
+		return fmt.Errorf("plugin %s speaks protocol version %d, expected %d", p.path, info.ProtocolVersion, ProtocolVersion)
+	}
+	if info.Name == "" {
+		info.Name = name
+	}
+	p.info = &info
+
+	return nil
+}
+
+// stop shuts the process down. It is called with the lock held.
+func (p *Plugin) stop() error {
+	if p.cmd == nil {
+		return nil
+	}
+
+	select {
+	case <-p.exited:
+	default:
+		ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+		p.client.Call(ctx, MethodShutdown, nil, nil)
+		cancel()
+		p.stdin.Close()
+
+		select {
+		case <-p.exited:
+		case <-time.After(ShutdownTimeout):
+			p.cmd.Process.Kill()
+			select {
+			case <-p.exited:
+			case <-time.After(ShutdownTimeout):
+			}
+		}
+	}
+
+	p.cmd = nil
+	return nil
+}
+
+// lineLogger logs each line written to it
+type lineLogger struct {
+	prefix string
+	buf    []byte
+}
+
+func (l *lineLogger) Write(p []byte) (int, error) {
+	l.buf = append(l.buf, p...)
+	for {
+		i := bytes.IndexByte(l.buf, '\n')
+		if i < 0 {
+			break
+		}
+		log.Printf("%s%s", l.prefix, l.buf[:i])
+		l.buf = l.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Tool is a proxy for a tool provided by a plugin
+type Tool struct {
+	tools.BaseTool
+	plugin *Plugin
+}
+
+// Plugin returns the plugin providing the tool
+func (t *Tool) Plugin() *Plugin {
+	return t.plugin
+}
+
+// Execute calls the tool in the plugin process, passing along the tool's
+// settings for the calling agent
+func (t *Tool) Execute(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	result, err := t.plugin.Call(ctx, t.Name, params, tools.SettingsFromContext(ctx, t.Name))
+	if err != nil {
+		// Errors reported by the tool are passed on as they are
+		var rpcErr *jsonrpc.Error
+		if errors.As(err, &rpcErr) {
+			return nil, errors.New(rpcErr.Message)
+		}
+		return nil, fmt.Errorf("plugin %s: %w", t.plugin.Info().Name, err)
+	}
+	return result, nil
+}
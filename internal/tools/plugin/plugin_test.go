@@ -0,0 +1,228 @@
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/jsonrpc"
+	"github.com/satishgonella2024/sentinelstacks/internal/tools"
+)
+
+// The test binary doubles as a plugin when started by a wrapper script
+func TestMain(m *testing.M) {
+	if os.Getenv("SENTINEL_TEST_PLUGIN") == "1" {
+		runTestPlugin()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runTestPlugin serves the plugin protocol on stdio
+func runTestPlugin() {
+	var writeMu sync.Mutex
+	write := func(message jsonrpc.Message) {
+		message.JSONRPC = jsonrpc.Version
+		data, _ := json.Marshal(message)
+		writeMu.Lock()
+		os.Stdout.Write(append(data, '\n'))
+		writeMu.Unlock()
+	}
+	respond := func(id json.RawMessage, result interface{}) {
+		data, _ := json.Marshal(result)
+		write(jsonrpc.Message{ID: id, Result: data})
+	}
+
+	var mu sync.Mutex
+	cancelled := make(map[int64]chan struct{})
+	waiting := func(id json.RawMessage) chan struct{} {
+		var n int64
+		json.Unmarshal(id, &n)
+		mu.Lock()
+		defer mu.Unlock()
+		if cancelled[n] == nil {
+			cancelled[n] = make(chan struct{})
+		}
+		return cancelled[n]
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var message jsonrpc.Message
+		if err := json.Unmarshal(scanner.Bytes(), &message); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid message: %v\n", err)
+			continue
+		}
+
+		switch message.Method {
+		case MethodInitialize:
+			fmt.Fprintln(os.Stderr, "initializing")
+			respond(message.ID, InitializeResult{
+				Name:            "test",
+				Version:         "1.0.0",
+				ProtocolVersion: ProtocolVersion,
+				Tools: []ToolSpec{
+					{Name: "test/echo", Description: "Echo", Permission: tools.PermissionNone, Parameters: []tools.Parameter{{Name: "text", Type: "string", Required: true}}},
+					{Name: "test/wait", Description: "Wait until cancelled", Permission: tools.PermissionNone},
+					{Name: "test/fail", Description: "Fail", Permission: tools.PermissionNone},
+					{Name: "test/exit", Description: "Exit", Permission: tools.PermissionNone},
+					{Name: "test/invalid", Description: "Invalid permission", Permission: "root"},
+				},
+			})
+		case MethodCancel:
+			var params CancelParams
+			json.Unmarshal(message.Params, &params)
+			close(waiting(json.RawMessage(fmt.Sprint(params.ID))))
+		case MethodShutdown:
+			respond(message.ID, nil)
+		case MethodCallTool:
+			var params CallToolParams
+			json.Unmarshal(message.Params, &params)
+			switch params.Name {
+			case "test/echo":
+				respond(message.ID, map[string]interface{}{"text": params.Arguments["text"], "settings": params.Settings, "pid": os.Getpid()})
+			case "test/wait":
+				done := waiting(message.ID)
+				id := message.ID
+				go func() {
+					<-done
+					fmt.Fprintf(os.Stderr, "cancelled %s\n", id)
+					write(jsonrpc.Message{ID: id, Error: &jsonrpc.Error{Code: -32800, Message: "cancelled"}})
+				}()
+			case "test/fail":
+				write(jsonrpc.Message{ID: message.ID, Error: &jsonrpc.Error{Code: 1, Message: "tool failed"}})
+			case "test/exit":
+				os.Exit(1)
+			}
+		}
+	}
+}
+
+// writeTestPlugin writes a script starting the test binary as a plugin
+func writeTestPlugin(t *testing.T, dir string) string {
+	t.Helper()
+
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatalf("Failed to find test binary: %v", err)
+	}
+
+	path := filepath.Join(dir, "test-plugin")
+	script := fmt.Sprintf("#!/bin/sh\nSENTINEL_TEST_PLUGIN=1 exec %q\n", executable)
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write plugin: %v", err)
+	}
+	return path
+}
+
+func TestDiscover(t *testing.T) {
+	dir := t.TempDir()
+	writeTestPlugin(t, dir)
+	os.WriteFile(filepath.Join(dir, "README.md"), []byte("not a plugin"), 0644)
+	os.WriteFile(filepath.Join(dir, ".hidden"), []byte("#!/bin/sh\n"), 0755)
+	os.Mkdir(filepath.Join(dir, "subdir"), 0755)
+
+	paths, err := Discover(dir)
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+	if len(paths) != 1 || filepath.Base(paths[0]) != "test-plugin" {
+		t.Errorf("Expected only the executable to be discovered, got %v", paths)
+	}
+
+	paths, err = Discover(filepath.Join(dir, "missing"))
+	if err != nil || len(paths) != 0 {
+		t.Errorf("Expected a missing directory to have no plugins, got %v, %v", paths, err)
+	}
+}
+
+func TestRegisterPluginTools(t *testing.T) {
+	dir := t.TempDir()
+	writeTestPlugin(t, dir)
+
+	registry := tools.NewRegistry()
+	if err := RegisterPluginToolsFrom(context.Background(), dir, registry); err != nil {
+		t.Fatalf("RegisterPluginToolsFrom failed: %v", err)
+	}
+	defer Shutdown()
+
+	if _, err := registry.GetTool("test/invalid"); err == nil {
+		t.Error("Expected tool with an invalid permission to be skipped")
+	}
+
+	tool, err := registry.GetTool("test/echo")
+	if err != nil {
+		t.Fatalf("Expected plugin tool to be registered: %v", err)
+	}
+	if params := tool.GetParameters(); len(params) != 1 || params[0].Name != "text" {
+		t.Errorf("Expected declared parameters, got %+v", params)
+	}
+
+	// Calls carry the tool's settings for the agent
+	ctx := tools.WithSettings(context.Background(), map[string]interface{}{
+		"test/echo": map[string]interface{}{"greeting": "hello"},
+	})
+	result, err := tools.ExecuteTool(ctx, tool, map[string]interface{}{"text": "hi"})
+	if err != nil {
+		t.Fatalf("ExecuteTool failed: %v", err)
+	}
+	echo := result.(map[string]interface{})
+	if echo["text"] != "hi" {
+		t.Errorf("Expected echoed text, got %v", echo["text"])
+	}
+	if settings, _ := echo["settings"].(map[string]interface{}); settings["greeting"] != "hello" {
+		t.Errorf("Expected settings to be passed, got %v", echo["settings"])
+	}
+
+	// Tool errors are passed on
+	failTool, _ := registry.GetTool("test/fail")
+	if _, err := failTool.Execute(context.Background(), nil); err == nil || err.Error() != "tool failed" {
+		t.Errorf("Expected tool error, got %v", err)
+	}
+}
+
+func TestPluginCancelAndRestart(t *testing.T) {
+	p, err := Start(context.Background(), writeTestPlugin(t, t.TempDir()))
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer p.Close()
+
+	// A call abandoned by its context is cancelled in the plugin
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := p.Call(ctx, "test/wait", nil, nil); err != context.DeadlineExceeded {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected call to return when its context ended, took %s", elapsed)
+	}
+
+	first, err := p.Call(context.Background(), "test/echo", map[string]interface{}{"text": "a"}, nil)
+	if err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+
+	// A plugin that exits is restarted on the next call
+	if _, err := p.Call(context.Background(), "test/exit", nil, nil); err == nil {
+		t.Error("Expected call to fail when the plugin exits")
+	}
+	second, err := p.Call(context.Background(), "test/echo", map[string]interface{}{"text": "b"}, nil)
+	if err != nil {
+		t.Fatalf("Call after restart failed: %v", err)
+	}
+	if first.(map[string]interface{})["pid"] == second.(map[string]interface{})["pid"] {
+		t.Error("Expected the plugin to run in a new process")
+	}
+	if !strings.Contains(fmt.Sprint(second), "b") {
+		t.Errorf("Unexpected result after restart: %v", second)
+	}
+}
@@ -0,0 +1,57 @@
+package plugin
+
+import (
+	"github.com/satishgonella2024/sentinelstacks/internal/tools"
+)
+
+// ProtocolVersion is the version of the plugin protocol spoken by the
+// runtime. Plugins must answer the handshake with the same version.
+const ProtocolVersion = 1
+
+// Methods of the plugin protocol
+const (
+	// MethodInitialize is the handshake, the first call sent to a plugin
+	MethodInitialize = "initialize"
+	// MethodCallTool runs one of the plugin's tools
+	MethodCallTool = "tools/call"
+	// MethodCancel is a notification telling the plugin to abandon a call
+	MethodCancel = "$/cancel"
+	// MethodShutdown asks the plugin to exit; stdin is closed afterwards
+	MethodShutdown = "shutdown"
+)
+
+// InitializeParams are the parameters of the handshake
+type InitializeParams struct {
+	ProtocolVersion int    `json:"protocol_version"`
+	Runtime         string `json:"runtime"`
+}
+
+// InitializeResult is a plugin's answer to the handshake, describing the
+// tools it provides
+type InitializeResult struct {
+	Name            string     `json:"name"`
+	Version         string     `json:"version,omitempty"`
+	ProtocolVersion int        `json:"protocol_version"`
+	Tools           []ToolSpec `json:"tools"`
+}
+
+// ToolSpec describes a tool provided by a plugin
+type ToolSpec struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Parameters  []tools.Parameter `json:"parameters,omitempty"`
+	Permission  tools.Permission  `json:"permission"`
+}
+
+// CallToolParams are the parameters of a tool call. Settings is the tool's
+// section of the calling agent's toolSettings.
+type CallToolParams struct {
+	Name      string                 `json:"name"`
+	Arguments map[string]interface{} `json:"arguments"`
+	Settings  map[string]interface{} `json:"settings,omitempty"`
+}
+
+// CancelParams are the parameters of a cancel notification
+type CancelParams struct {
+	ID int64 `json:"id"`
+}
@@ -0,0 +1,133 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/tools"
+	"github.com/spf13/viper"
+)
+
+var (
+	loaded   []*Plugin
+	loadedMu sync.Mutex
+)
+
+// Dir returns the directory plugins are discovered in: the
+// "tools.plugin_dir" setting, or ~/.sentinel/tools
+func Dir() (string, error) {
+	if dir := viper.GetString("tools.plugin_dir"); dir != "" {
+		return dir, nil
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("could not get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".sentinel", "tools"), nil
+}
+
+// Discover returns the executables in dir, sorted by name. Hidden files
+// and directories are skipped; a missing directory has no plugins.
+func Discover(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read plugin directory: %w", err)
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		// Follow symlinks to the executable
+		path := filepath.Join(dir, entry.Name())
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() || info.Mode()&0111 == 0 {
+			continue
+		}
+		paths = append(paths, path)
+	}
+
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// RegisterPluginTools starts the plugins in the plugin directory and
+// registers their tools. Plugins that fail to start and tools that cannot
+// be registered are skipped with a warning.
+func RegisterPluginTools() error {
+	dir, err := Dir()
+	if err != nil {
+		return err
+	}
+	return RegisterPluginToolsFrom(context.Background(), dir, tools.GetRegistry())
+}
+
+// RegisterPluginToolsFrom starts the plugins in dir and registers their
+// tools with registry
+func RegisterPluginToolsFrom(ctx context.Context, dir string, registry *tools.Registry) error {
+	paths, err := Discover(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		p, err := Start(ctx, path)
+		if err != nil {
+			log.Printf("Warning: Failed to load plugin: %v", err)
+			continue
+		}
+
+		registered := 0
+		for _, tool := range p.Tools() {
+			if err := registry.RegisterTool(tool); err != nil {
+				log.Printf("Warning: Failed to register tool from plugin %s: %v", p.Info().Name, err)
+				continue
+			}
+			registered++
+		}
+
+		// Keep only plugins that provide something
+		if registered == 0 {
+			p.Close()
+			continue
+		}
+
+		loadedMu.Lock()
+		loaded = append(loaded, p)
+		loadedMu.Unlock()
+	}
+
+	return nil
+}
+
+// Loaded returns the plugins started by RegisterPluginTools
+func Loaded() []*Plugin {
+	loadedMu.Lock()
+	defer loadedMu.Unlock()
+	return append([]*Plugin(nil), loaded...)
+}
+
+// Shutdown stops the plugins started by RegisterPluginTools. Plugins also
+// exit when the runtime does, since their stdin is closed.
+func Shutdown() {
+	loadedMu.Lock()
+	plugins := loaded
+	loaded = nil
+	loadedMu.Unlock()
+
+	for _, p := range plugins {
+		p.Close()
+	}
+}
@@ -29,6 +29,13 @@ func GetRegistry() *Registry {
 	return globalRegistry
 }
 
+// NewRegistry creates an empty registry, separate from the global one
+func NewRegistry() *Registry {
+	return &Registry{
+		tools: make(map[string]Tool),
+	}
+}
+
 // RegisterTool registers a tool with the registry
 func (r *Registry) RegisterTool(tool Tool) error {
 	r.mu.Lock()
@@ -47,6 +54,14 @@ func (r *Registry) RegisterTool(tool Tool) error {
 	return nil
 }
 
+// UnregisterTool removes a tool from the registry, if it is registered
+func (r *Registry) UnregisterTool(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	
+	delete(r.tools, name)
+}
+
 // GetTool returns a tool by name
 func (r *Registry) GetTool(name string) (Tool, error) {
 	r.mu.RLock()
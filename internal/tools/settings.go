@@ -0,0 +1,95 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+)
+
+// settingsKey is the context key of an agent's tool settings
+type settingsKey struct{}
+
+// WithSettings returns a context carrying an agent's tool settings, the
+// toolSettings section of its definition keyed by tool name. Tools read
+// their section with SettingsFromContext.
+func WithSettings(ctx context.Context, settings map[string]interface{}) context.Context {
+	if len(settings) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, settingsKey{}, settings)
+}
+
+// SettingsFromContext returns the settings of a tool for the agent it runs
+// for, or nil if the agent has none
+func SettingsFromContext(ctx context.Context, toolName string) map[string]interface{} {
+	settings, _ := ctx.Value(settingsKey{}).(map[string]interface{})
+	return stringMap(settings[toolName])
+}
+
+// stringMap returns a decoded YAML or JSON object as a map with string
+// keys, or nil if the value is not an object
+func stringMap(value interface{}) map[string]interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return v
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			converted[fmt.Sprint(key)] = item
+		}
+		return converted
+	}
+	return nil
+}
+
+// SettingString returns a string setting, or "" if it is not set
+func SettingString(settings map[string]interface{}, name string) string {
+	value, _ := settings[name].(string)
+	return value
+}
+
+// SettingInt returns a numeric setting, or fallback if it is not set
+func SettingInt(settings map[string]interface{}, name string, fallback int64) int64 {
+	switch v := settings[name].(type) {
+	case int:
+		return int64(v)
+	case int64:
+		return v
+	case float64:
+		return int64(v)
+	}
+	return fallback
+}
+
+// SettingStrings returns a list of strings setting. A single string is a
+// list of one.
+func SettingStrings(settings map[string]interface{}, name string) []string {
+	switch v := settings[name].(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// SettingMap returns an object setting with string values, or nil if it
+// is not set
+func SettingMap(settings map[string]interface{}, name string) map[string]string {
+	object := stringMap(settings[name])
+	if object == nil {
+		return nil
+	}
+	values := make(map[string]string, len(object))
+	for key, item := range object {
+		values[key] = fmt.Sprint(item)
+	}
+	return values
+}
@@ -0,0 +1,75 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/tools"
+	"github.com/spf13/viper"
+)
+
+// RegisterShellTools registers the shell tools with a sandbox configured
+// from the "shell" config section
+func RegisterShellTools() error {
+	sandbox, err := NewSandbox(ConfigFromViper())
+	if err != nil {
+		return err
+	}
+
+	// Register tools
+	registry := tools.GetRegistry()
+	if err := registry.RegisterTool(NewExecTool(sandbox)); err != nil {
+		return err
+	}
+	if err := registry.RegisterTool(NewPythonTool(sandbox)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ConfigFromViper returns the default sandbox configuration overridden by
+// any settings in the "shell" config section
+func ConfigFromViper() Config {
+	config := DefaultConfig()
+
+	if viper.IsSet("shell.work_dir") {
+		config.WorkDir = expandHome(viper.GetString("shell.work_dir"))
+	}
+	if viper.IsSet("shell.timeout") {
+		config.Timeout = viper.GetDuration("shell.timeout")
+	}
+	if viper.IsSet("shell.cpu_seconds") {
+		config.CPUSeconds = viper.GetInt("shell.cpu_seconds")
+	}
+	if viper.IsSet("shell.memory_mb") {
+		config.MemoryBytes = viper.GetInt64("shell.memory_mb") * 1024 * 1024
+	}
+	if viper.IsSet("shell.max_output_bytes") {
+		config.MaxOutputBytes = viper.GetInt("shell.max_output_bytes")
+	}
+	if viper.IsSet("shell.env_allowlist") {
+		config.EnvAllowlist = viper.GetStringSlice("shell.env_allowlist")
+	}
+	if viper.IsSet("shell.isolate_network") {
+		config.IsolateNetwork = viper.GetBool("shell.isolate_network")
+	}
+	if viper.IsSet("shell.python") {
+		config.Python = viper.GetString("shell.python")
+	}
+
+	return config
+}
+
+// expandHome expands a leading ~ in a path to the home directory
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(homeDir, path[1:])
+}
@@ -0,0 +1,327 @@
+package shell
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Default sandbox limits
+const (
+	DefaultTimeout        = 30 * time.Second
+	DefaultCPUSeconds     = 30
+	DefaultMemoryBytes    = 512 * 1024 * 1024
+	DefaultMaxOutputBytes = 64 * 1024
+	DefaultShell          = "/bin/sh"
+	DefaultPython         = "python3"
+	defaultPath           = "/usr/local/bin:/usr/bin:/bin"
+)
+
+// DefaultEnvAllowlist lists the environment variables passed through to
+// sandboxed commands by default
+var DefaultEnvAllowlist = []string{"PATH", "LANG", "LC_ALL", "LC_CTYPE", "TZ"}
+
+// Config controls where and how sandboxed commands run
+type Config struct {
+	// WorkDir is the directory commands run in; they cannot be pointed
+	// outside of it
+	WorkDir string
+	// Timeout is the wall-clock limit of a command
+	Timeout time.Duration
+	// CPUSeconds is the CPU time limit (RLIMIT_CPU); 0 disables it
+	CPUSeconds int
+	// MemoryBytes is the address space limit (RLIMIT_AS); 0 disables it
+	MemoryBytes int64
+	// MaxOutputBytes caps the stdout and stderr kept from a command
+	MaxOutputBytes int
+	// EnvAllowlist names the environment variables passed to commands
+	EnvAllowlist []string
+	// IsolateNetwork runs commands in new user and network namespaces,
+	// leaving them only a loopback interface. It is supported on Linux.
+	IsolateNetwork bool
+	// Shell is the shell used to run commands and apply limits
+	Shell string
+	// Python is the interpreter used to run Python code
+	Python string
+}
+
+// DefaultConfig returns a sandbox configuration rooted at
+// ~/.sentinel/sandbox
+func DefaultConfig() Config {
+	workDir := filepath.Join(os.TempDir(), "sentinel-sandbox")
+	if homeDir, err := os.UserHomeDir(); err == nil {
+		workDir = filepath.Join(homeDir, ".sentinel", "sandbox")
+	}
+
+	return Config{
+		WorkDir:        workDir,
+		Timeout:        DefaultTimeout,
+		CPUSeconds:     DefaultCPUSeconds,
+		MemoryBytes:    DefaultMemoryBytes,
+		MaxOutputBytes: DefaultMaxOutputBytes,
+		EnvAllowlist:   DefaultEnvAllowlist,
+		Shell:          DefaultShell,
+		Python:         DefaultPython,
+	}
+}
+
+// Result is the outcome of a sandboxed command
+type Result struct {
+	Stdout     string `json:"stdout"`
+	Stderr     string `json:"stderr"`
+	ExitCode   int    `json:"exit_code"`
+	TimedOut   bool   `json:"timed_out,omitempty"`
+	Truncated  bool   `json:"truncated,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// Sandbox runs commands under the limits of its configuration
+type Sandbox struct {
+	config Config
+}
+
+// NewSandbox creates a sandbox, creating its working directory if needed
+func NewSandbox(config Config) (*Sandbox, error) {
+	if config.WorkDir == "" {
+		return nil, fmt.Errorf("sandbox working directory is required")
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultTimeout
+	}
+	if config.MaxOutputBytes <= 0 {
+		config.MaxOutputBytes = DefaultMaxOutputBytes
+	}
+	if config.Shell == "" {
+		config.Shell = DefaultShell
+	}
+	if config.Python == "" {
+		config.Python = DefaultPython
+	}
+
+	workDir, err := filepath.Abs(config.WorkDir)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sandbox working directory: %w", err)
+	}
+	if err := os.MkdirAll(workDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create sandbox working directory: %w", err)
+	}
+	// Resolve symlinks so that containment checks compare real paths
+	if workDir, err = filepath.EvalSymlinks(workDir); err != nil {
+		return nil, fmt.Errorf("invalid sandbox working directory: %w", err)
+	}
+	config.WorkDir = workDir
+
+	return &Sandbox{config: config}, nil
+}
+
+// Config returns the sandbox configuration
+func (s *Sandbox) Config() Config {
+	return s.config
+}
+
+// ResolveDir returns the directory for a path relative to the working
+// directory, creating it if needed. Paths leaving the working directory
+// are rejected.
+func (s *Sandbox) ResolveDir(dir string) (string, error) {
+	if dir == "" {
+		return s.config.WorkDir, nil
+	}
+	if filepath.IsAbs(dir) {
+		return "", fmt.Errorf("directory must be relative to the sandbox: %s", dir)
+	}
+
+	path := filepath.Join(s.config.WorkDir, dir)
+	if !s.contains(path) {
+		return "", fmt.Errorf("directory is outside the sandbox: %s", dir)
+	}
+	if err := os.MkdirAll(path, 0700); err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	// A symlink inside the sandbox may still point out of it
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", fmt.Errorf("invalid directory: %w", err)
+	}
+	if !s.contains(resolved) {
+		return "", fmt.Errorf("directory is outside the sandbox: %s", dir)
+	}
+
+	return resolved, nil
+}
+
+// contains reports whether path is the working directory or inside it
+func (s *Sandbox) contains(path string) bool {
+	rel, err := filepath.Rel(s.config.WorkDir, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
+
+// RunShell runs a shell command in dir, relative to the working directory
+func (s *Sandbox) RunShell(ctx context.Context, dir, command string, timeout time.Duration) (*Result, error) {
+	return s.Run(ctx, dir, timeout, s.config.Shell, "-c", command)
+}
+
+// RunPython runs Python source code in dir, relative to the working
+// directory. The code is written to a temporary file that is removed
+// afterwards.
+func (s *Sandbox) RunPython(ctx context.Context, dir, code string, timeout time.Duration) (*Result, error) {
+	path, err := s.ResolveDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.CreateTemp(path, ".sentinel-*.py")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create script: %w", err)
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.WriteString(code); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to write script: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write script: %w", err)
+	}
+
+	// Isolated mode ignores PYTHON* variables and the user site directory
+	return s.Run(ctx, dir, timeout, s.config.Python, "-I", filepath.Base(file.Name()))
+}
+
+// Run runs a program in dir, relative to the working directory. A timeout
+// of zero, or one above the configured timeout, uses the configured one.
+// Commands that fail or time out are reported in the result; an error is
+// returned only if the command could not be run.
+func (s *Sandbox) Run(ctx context.Context, dir string, timeout time.Duration, name string, args ...string) (*Result, error) {
+	path, err := s.ResolveDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if timeout <= 0 || timeout > s.config.Timeout {
+		timeout = s.config.Timeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	argv := s.limitedCommand(name, args)
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Dir = path
+	cmd.Env = s.environ()
+	stdout := &cappedBuffer{limit: s.config.MaxOutputBytes}
+	stderr := &cappedBuffer{limit: s.config.MaxOutputBytes}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	// Stop waiting for output held open by orphaned children
+	cmd.WaitDelay = time.Second
+
+	if err := configureCommand(cmd, s.config); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	err = cmd.Run()
+	result := &Result{
+		Stdout:     stdout.String(),
+		Stderr:     stderr.String(),
+		Truncated:  stdout.truncated || stderr.truncated,
+		DurationMS: time.Since(start).Milliseconds(),
+	}
+
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		result.TimedOut = true
+		result.ExitCode = -1
+		return result, nil
+	case context.Canceled:
+		return nil, ctx.Err()
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+	case errors.Is(err, exec.ErrWaitDelay):
+		result.ExitCode = cmd.ProcessState.ExitCode()
+	default:
+		if s.config.IsolateNetwork {
+			return nil, fmt.Errorf("failed to run %s with network isolation: %w", name, err)
+		}
+		return nil, fmt.Errorf("failed to run %s: %w", name, err)
+	}
+
+	return result, nil
+}
+
+// limitedCommand wraps a command in a shell that applies the CPU and memory
+// rlimits before replacing itself with the command
+func (s *Sandbox) limitedCommand(name string, args []string) []string {
+	var limits []string
+	if s.config.CPUSeconds > 0 {
+		limits = append(limits, fmt.Sprintf("ulimit -t %d", s.config.CPUSeconds))
+	}
+	if s.config.MemoryBytes > 0 {
+		limits = append(limits, fmt.Sprintf("ulimit -v %d", s.config.MemoryBytes/1024))
+	}
+	if len(limits) == 0 {
+		return append([]string{name}, args...)
+	}
+
+	script := strings.Join(limits, " && ") + ` && exec "$@"`
+	return append([]string{s.config.Shell, "-c", script, "sentinel-sandbox", name}, args...)
+}
+
+// environ returns the environment of sandboxed commands: the allowlisted
+// variables, with HOME and TMPDIR pointing at the working directory
+func (s *Sandbox) environ() []string {
+	var env []string
+	hasPath := false
+	for _, name := range s.config.EnvAllowlist {
+		if name == "HOME" || name == "TMPDIR" {
+			continue
+		}
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+			hasPath = hasPath || name == "PATH"
+		}
+	}
+	if !hasPath {
+		env = append(env, "PATH="+defaultPath)
+	}
+
+	return append(env, "HOME="+s.config.WorkDir, "TMPDIR="+s.config.WorkDir)
+}
+
+// cappedBuffer keeps the first limit bytes written to it and discards the
+// rest, so that a noisy command is not blocked on a full pipe
+type cappedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	remaining := b.limit - b.buf.Len()
+	if len(p) > remaining {
+		b.truncated = true
+		if remaining > 0 {
+			b.buf.Write(p[:remaining])
+		}
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *cappedBuffer) String() string {
+	return b.buf.String()
+}
@@ -0,0 +1,28 @@
+package shell
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// configureCommand runs the command in its own process group, so that a
+// timeout kills everything it started, and in new user and network
+// namespaces when network isolation is enabled
+func configureCommand(cmd *exec.Cmd, config Config) error {
+	attr := &syscall.SysProcAttr{Setpgid: true}
+
+	if config.IsolateNetwork {
+		// The user namespace lets unprivileged users create the network
+		// namespace; the caller's IDs map to themselves inside it
+		attr.Cloneflags = syscall.CLONE_NEWUSER | syscall.CLONE_NEWNET
+		attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getuid(), HostID: os.Getuid(), Size: 1}}
+		attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getgid(), HostID: os.Getgid(), Size: 1}}
+	}
+
+	cmd.SysProcAttr = attr
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	return nil
+}
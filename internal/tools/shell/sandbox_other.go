@@ -0,0 +1,16 @@
+//go:build !linux
+
+package shell
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// configureCommand rejects network isolation, which needs Linux namespaces
+func configureCommand(cmd *exec.Cmd, config Config) error {
+	if config.IsolateNetwork {
+		return fmt.Errorf("network isolation is only supported on Linux")
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package shell
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestSandbox(t *testing.T, modify func(*Config)) *Sandbox {
+	t.Helper()
+
+	config := DefaultConfig()
+	config.WorkDir = t.TempDir()
+	if modify != nil {
+		modify(&config)
+	}
+
+	sandbox, err := NewSandbox(config)
+	if err != nil {
+		t.Fatalf("NewSandbox failed: %v", err)
+	}
+	return sandbox
+}
+
+func TestRunShell(t *testing.T) {
+	sandbox := newTestSandbox(t, nil)
+
+	result, err := sandbox.RunShell(context.Background(), "data", "pwd; echo oops >&2; exit 3", 0)
+	if err != nil {
+		t.Fatalf("RunShell failed: %v", err)
+	}
+
+	if result.ExitCode != 3 {
+		t.Errorf("Expected exit code 3, got %d", result.ExitCode)
+	}
+	if got := strings.TrimSpace(result.Stdout); got != filepath.Join(sandbox.Config().WorkDir, "data") {
+		t.Errorf("Expected command to run in the data directory, got %q", got)
+	}
+	if result.Stderr != "oops\n" {
+		t.Errorf("Expected stderr %q, got %q", "oops\n", result.Stderr)
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	sandbox := newTestSandbox(t, nil)
+
+	start := time.Now()
+	result, err := sandbox.RunShell(context.Background(), "", "sleep 10 & sleep 10", 200*time.Millisecond)
+	if err != nil {
+		t.Fatalf("RunShell failed: %v", err)
+	}
+
+	if !result.TimedOut {
+		t.Error("Expected command to time out")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected command and its children to be killed, took %s", elapsed)
+	}
+}
+
+func TestRunOutputCap(t *testing.T) {
+	sandbox := newTestSandbox(t, func(config *Config) {
+		config.MaxOutputBytes = 100
+	})
+
+	result, err := sandbox.RunShell(context.Background(), "", "yes | head -c 10000", 0)
+	if err != nil {
+		t.Fatalf("RunShell failed: %v", err)
+	}
+
+	if len(result.Stdout) != 100 {
+		t.Errorf("Expected 100 bytes of output, got %d", len(result.Stdout))
+	}
+	if !result.Truncated {
+		t.Error("Expected output to be marked as truncated")
+	}
+}
+
+func TestRunEnvironment(t *testing.T) {
+	t.Setenv("SENTINEL_TEST_SECRET", "secret")
+	t.Setenv("SENTINEL_TEST_ALLOWED", "allowed")
+	sandbox := newTestSandbox(t, func(config *Config) {
+		config.EnvAllowlist = []string{"PATH", "SENTINEL_TEST_ALLOWED"}
+	})
+
+	result, err := sandbox.RunShell(context.Background(), "", "env", 0)
+	if err != nil {
+		t.Fatalf("RunShell failed: %v", err)
+	}
+
+	if strings.Contains(result.Stdout, "SENTINEL_TEST_SECRET") {
+		t.Error("Expected variables outside the allowlist to be removed")
+	}
+	if !strings.Contains(result.Stdout, "SENTINEL_TEST_ALLOWED=allowed") {
+		t.Error("Expected allowlisted variables to be passed")
+	}
+	if !strings.Contains(result.Stdout, "HOME="+sandbox.Config().WorkDir) {
+		t.Error("Expected HOME to be the sandbox directory")
+	}
+}
+
+func TestRunLimits(t *testing.T) {
+	sandbox := newTestSandbox(t, func(config *Config) {
+		config.CPUSeconds = 7
+		config.MemoryBytes = 256 * 1024 * 1024
+	})
+
+	result, err := sandbox.RunShell(context.Background(), "", "ulimit -t; ulimit -v", 0)
+	if err != nil {
+		t.Fatalf("RunShell failed: %v", err)
+	}
+
+	expected := fmt.Sprintf("7\n%d\n", 256*1024)
+	if result.Stdout != expected {
+		t.Errorf("Expected limits %q, got %q (stderr %q)", expected, result.Stdout, result.Stderr)
+	}
+}
+
+func TestResolveDir(t *testing.T) {
+	sandbox := newTestSandbox(t, nil)
+	workDir := sandbox.Config().WorkDir
+
+	if err := os.Symlink(os.TempDir(), filepath.Join(workDir, "escape")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+
+	for _, dir := range []string{"..", "../other", "/tmp", "a/../../other", "escape"} {
+		if _, err := sandbox.ResolveDir(dir); err == nil {
+			t.Errorf("Expected %q to be rejected", dir)
+		}
+	}
+
+	path, err := sandbox.ResolveDir("a/../b")
+	if err != nil {
+		t.Fatalf("ResolveDir failed: %v", err)
+	}
+	if path != filepath.Join(workDir, "b") {
+		t.Errorf("Expected %s, got %s", filepath.Join(workDir, "b"), path)
+	}
+}
+
+func TestRunPython(t *testing.T) {
+	sandbox := newTestSandbox(t, nil)
+
+	result, err := sandbox.RunPython(context.Background(), "", "print(sum(range(10)))", 0)
+	if err != nil {
+		t.Skipf("Python is not available: %v", err)
+	}
+	if result.ExitCode != 0 {
+		t.Skipf("Python is not available: %s", result.Stderr)
+	}
+
+	if result.Stdout != "45\n" {
+		t.Errorf("Expected output %q, got %q", "45\n", result.Stdout)
+	}
+
+	// The script is removed after it runs
+	entries, _ := os.ReadDir(sandbox.Config().WorkDir)
+	if len(entries) != 0 {
+		t.Errorf("Expected script to be removed, found %d entries", len(entries))
+	}
+}
+
+func TestRunIsolateNetwork(t *testing.T) {
+	sandbox := newTestSandbox(t, func(config *Config) {
+		config.IsolateNetwork = true
+	})
+
+	result, err := sandbox.RunShell(context.Background(), "", "cat /proc/net/dev", 0)
+	if err != nil {
+		t.Skipf("Network isolation is not available: %v", err)
+	}
+
+	// Only the loopback interface exists in a new network namespace
+	lines := strings.Split(strings.TrimSpace(result.Stdout), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[2], "lo:") {
+		t.Errorf("Expected only a loopback interface, got:\n%s", result.Stdout)
+	}
+}
@@ -0,0 +1,124 @@
+package shell
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/tools"
+)
+
+// ExecTool implements a tool for running shell commands in the sandbox
+type ExecTool struct {
+	tools.BaseTool
+	sandbox *Sandbox
+}
+
+// NewExecTool creates a new shell command tool
+func NewExecTool(sandbox *Sandbox) *ExecTool {
+	return &ExecTool{
+		BaseTool: tools.BaseTool{
+			Name:        "shell/exec",
+			Description: "Run a shell command in a sandboxed working directory and return its output and exit code",
+			Parameters: []tools.Parameter{
+				{
+					Name:        "command",
+					Type:        "string",
+					Description: "Shell command to run",
+					Required:    true,
+				},
+				{
+					Name:        "cwd",
+					Type:        "string",
+					Description: "Directory to run in, relative to the sandbox",
+					Required:    false,
+				},
+				{
+					Name:        "timeout",
+					Type:        "integer",
+					Description: fmt.Sprintf("Timeout in seconds (max %d)", int(sandbox.Config().Timeout.Seconds())),
+					Required:    false,
+				},
+			},
+			Permission: tools.PermissionShell,
+		},
+		sandbox: sandbox,
+	}
+}
+
+// Execute runs a shell command
+func (t *ExecTool) Execute(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	// Get parameters
+	command, _ := params["command"].(string)
+	if command == "" {
+		return nil, fmt.Errorf("command is required")
+	}
+	cwd, _ := params["cwd"].(string)
+
+	return t.sandbox.RunShell(ctx, cwd, command, timeoutParam(params))
+}
+
+// PythonTool implements a tool for running Python code in the sandbox
+type PythonTool struct {
+	tools.BaseTool
+	sandbox *Sandbox
+}
+
+// NewPythonTool creates a new Python code tool
+func NewPythonTool(sandbox *Sandbox) *PythonTool {
+	return &PythonTool{
+		BaseTool: tools.BaseTool{
+			Name:        "shell/run_python",
+			Description: "Run a Python 3 script in a sandboxed working directory and return its output and exit code. Print the values you need to see.",
+			Parameters: []tools.Parameter{
+				{
+					Name:        "code",
+					Type:        "string",
+					Description: "Python source code to run",
+					Required:    true,
+				},
+				{
+					Name:        "cwd",
+					Type:        "string",
+					Description: "Directory to run in, relative to the sandbox",
+					Required:    false,
+				},
+				{
+					Name:        "timeout",
+					Type:        "integer",
+					Description: fmt.Sprintf("Timeout in seconds (max %d)", int(sandbox.Config().Timeout.Seconds())),
+					Required:    false,
+				},
+			},
+			Permission: tools.PermissionShell,
+		},
+		sandbox: sandbox,
+	}
+}
+
+// Execute runs Python code
+func (t *PythonTool) Execute(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	// Get parameters
+	code, _ := params["code"].(string)
+	if code == "" {
+		return nil, fmt.Errorf("code is required")
+	}
+	cwd, _ := params["cwd"].(string)
+
+	return t.sandbox.RunPython(ctx, cwd, code, timeoutParam(params))
+}
+
+// timeoutParam returns the timeout parameter as a duration, or zero for
+// the sandbox default
+func timeoutParam(params map[string]interface{}) time.Duration {
+	var seconds float64
+	switch value := params["timeout"].(type) {
+	case float64:
+		seconds = value
+	case int:
+		seconds = float64(value)
+	case int64:
+		seconds = float64(value)
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
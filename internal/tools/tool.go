@@ -40,8 +40,24 @@ type Schema struct {
 	Parameters  map[string]interface{} `json:"parameters"`
 }
 
+// SchemaProvider is implemented by tools whose parameters are described by
+// a JSON schema richer than their Parameters, such as tools of MCP servers
+type SchemaProvider interface {
+	// ParameterSchema returns the JSON schema of the tool's parameters
+	ParameterSchema() map[string]interface{}
+}
+
 // GenerateSchema generates a JSON schema for the tool that can be used with LLM function calling
 func GenerateSchema(tool Tool) Schema {
+	// Tools with their own schema provide it as is
+	if provider, ok := tool.(SchemaProvider); ok {
+		return Schema{
+			Name:        tool.GetName(),
+			Description: tool.GetDescription(),
+			Parameters:  provider.ParameterSchema(),
+		}
+	}
+	
 	// Create schema base
 	schema := Schema{
 		Name:        tool.GetName(),
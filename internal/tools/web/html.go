@@ -0,0 +1,239 @@
+package web
+
+import (
+	"io"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// skippedElements hold no readable text
+var skippedElements = map[string]bool{
+	"head": true, "script": true, "style": true, "noscript": true,
+	"template": true, "svg": true, "iframe": true, "canvas": true,
+}
+
+// paragraphElements are separated from surrounding text by a blank line
+var paragraphElements = map[string]bool{
+	"p": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"pre": true, "blockquote": true, "table": true, "ul": true, "ol": true, "dl": true,
+	"section": true, "article": true, "header": true, "footer": true, "main": true,
+	"aside": true, "nav": true, "figure": true, "form": true, "hr": true,
+}
+
+// lineElements start on a new line
+var lineElements = map[string]bool{
+	"div": true, "li": true, "tr": true, "dt": true, "dd": true, "br": true,
+	"figcaption": true, "caption": true, "address": true,
+}
+
+var blankLines = regexp.MustCompile(`\n{3,}`)
+
+// HTMLToText converts an HTML document to readable plain text. Scripts and
+// styles are dropped, block elements become lines and paragraphs, list
+// items are bulleted and links keep their target. The document title is
+// returned separately.
+func HTMLToText(r io.Reader) (text, title string) {
+	var w textWriter
+	var titleText strings.Builder
+	skipDepth, preDepth, inTitle := 0, 0, false
+	var links []string
+
+	z := html.NewTokenizer(r)
+	for {
+		tokenType := z.Next()
+		if tokenType == html.ErrorToken {
+			break
+		}
+
+		token := z.Token()
+		name := token.Data
+		switch tokenType {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if name == "title" {
+				inTitle = tokenType == html.StartTagToken
+				continue
+			}
+			if skippedElements[name] {
+				if tokenType == html.StartTagToken {
+					skipDepth++
+				}
+				continue
+			}
+			if skipDepth > 0 {
+				continue
+			}
+
+			switch {
+			case paragraphElements[name]:
+				w.breakLine(2)
+			case lineElements[name]:
+				w.breakLine(1)
+			}
+			switch name {
+			case "pre":
+				preDepth++
+			case "h1", "h2", "h3", "h4", "h5", "h6":
+				w.writeRaw(strings.Repeat("#", int(name[1]-'0')) + " ")
+			case "li":
+				w.writeRaw("- ")
+			case "td", "th":
+				if !w.atLineStart() {
+					w.writeRaw(" | ")
+				}
+			case "img":
+				if alt := attr(token, "alt"); alt != "" {
+					w.writeText("[" + alt + "]")
+				}
+			case "a":
+				links = append(links, linkTarget(attr(token, "href")))
+			}
+
+		case html.EndTagToken:
+			if name == "title" {
+				inTitle = false
+				continue
+			}
+			if skippedElements[name] {
+				if skipDepth > 0 {
+					skipDepth--
+				}
+				continue
+			}
+			if skipDepth > 0 {
+				continue
+			}
+
+			switch name {
+			case "pre":
+				if preDepth > 0 {
+					preDepth--
+				}
+			case "a":
+				if n := len(links); n > 0 {
+					if links[n-1] != "" {
+						w.writeRaw(" (" + links[n-1] + ")")
+					}
+					links = links[:n-1]
+				}
+			}
+			switch {
+			case paragraphElements[name]:
+				w.breakLine(2)
+			case lineElements[name]:
+				w.breakLine(1)
+			}
+
+		case html.TextToken:
+			if inTitle {
+				titleText.WriteString(token.Data)
+				continue
+			}
+			if skipDepth > 0 {
+				continue
+			}
+			if preDepth > 0 {
+				w.writeRaw(token.Data)
+				continue
+			}
+			w.writeText(token.Data)
+		}
+	}
+
+	text = blankLines.ReplaceAllString(strings.TrimSpace(w.b.String()), "\n\n")
+	title = strings.Join(strings.Fields(titleText.String()), " ")
+	return text, title
+}
+
+// attr returns the value of an attribute of a tag
+func attr(token html.Token, name string) string {
+	for _, a := range token.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// linkTarget returns the href of a link worth showing, or ""
+func linkTarget(href string) string {
+	href = strings.TrimSpace(href)
+	if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+		return ""
+	}
+	return href
+}
+
+// textWriter builds text from HTML, collapsing whitespace the way a
+// browser would outside of preformatted elements
+type textWriter struct {
+	b        strings.Builder
+	space    bool
+	newlines int
+}
+
+// writeText writes text with its whitespace collapsed
+func (w *textWriter) writeText(s string) {
+	words := strings.Fields(s)
+	if len(words) == 0 {
+		if s != "" {
+			w.space = true
+		}
+		return
+	}
+
+	first, _ := utf8.DecodeRuneInString(s)
+	if w.space || unicode.IsSpace(first) {
+		w.separate()
+	}
+	w.b.WriteString(strings.Join(words, " "))
+
+	last, _ := utf8.DecodeLastRuneInString(s)
+	w.space = unicode.IsSpace(last)
+	w.newlines = 0
+}
+
+// writeRaw writes text as is
+func (w *textWriter) writeRaw(s string) {
+	if s == "" {
+		return
+	}
+	if w.space && !strings.HasPrefix(s, " ") {
+		w.separate()
+	}
+	w.b.WriteString(s)
+	w.space = false
+	w.newlines = 0
+	if strings.HasSuffix(s, "\n") {
+		w.newlines = 1
+	}
+}
+
+// separate writes a space unless the text already ends in whitespace
+func (w *textWriter) separate() {
+	if w.atLineStart() || strings.HasSuffix(w.b.String(), " ") {
+		return
+	}
+	w.b.WriteByte(' ')
+}
+
+// breakLine ends the current line, leaving n line breaks before the next
+// text
+func (w *textWriter) breakLine(n int) {
+	w.space = false
+	if w.b.Len() == 0 {
+		return
+	}
+	for w.newlines < n {
+		w.b.WriteByte('\n')
+		w.newlines++
+	}
+}
+
+// atLineStart reports whether nothing has been written on the current line
+func (w *textWriter) atLineStart() bool {
+	return w.b.Len() == 0 || w.newlines > 0
+}
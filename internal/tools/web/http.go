@@ -0,0 +1,286 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/tools"
+)
+
+// HTTPRequestToolName is the name of the HTTP request tool and of its
+// section in an agent's toolSettings
+const HTTPRequestToolName = "web/http_request"
+
+// Default HTTP request limits
+const (
+	DefaultHTTPTimeout      = 30 * time.Second
+	DefaultMaxRequestBytes  = 1024 * 1024
+	DefaultMaxResponseBytes = 256 * 1024
+	maxRedirects            = 10
+)
+
+// HTTPRequestTool implements a tool for calling HTTP APIs and fetching web
+// pages on the domains an agent is allowed to reach
+type HTTPRequestTool struct {
+	tools.BaseTool
+	transport http.RoundTripper
+}
+
+// HTTPResponse represents the response to an HTTP request. JSON bodies are
+// decoded into JSON and HTML bodies are converted to text.
+type HTTPResponse struct {
+	URL         string            `json:"url"`
+	StatusCode  int               `json:"status_code"`
+	Status      string            `json:"status"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	ContentType string            `json:"content_type,omitempty"`
+	Title       string            `json:"title,omitempty"`
+	Body        string            `json:"body,omitempty"`
+	JSON        interface{}       `json:"json,omitempty"`
+	Truncated   bool              `json:"truncated,omitempty"`
+}
+
+// httpSettings are the per-agent settings of the HTTP request tool
+type httpSettings struct {
+	allowedDomains   []string
+	headers          map[string]string
+	timeout          time.Duration
+	maxRequestBytes  int64
+	maxResponseBytes int64
+}
+
+// NewHTTPRequestTool creates a new HTTP request tool
+func NewHTTPRequestTool() *HTTPRequestTool {
+	return &HTTPRequestTool{
+		BaseTool: tools.BaseTool{
+			Name:        HTTPRequestToolName,
+			Description: "Send an HTTP request to an allowed domain and return the response. HTML pages are returned as readable text and JSON responses are decoded.",
+			Parameters: []tools.Parameter{
+				{
+					Name:        "url",
+					Type:        "string",
+					Description: "URL to request (http or https)",
+					Required:    true,
+				},
+				{
+					Name:        "method",
+					Type:        "string",
+					Description: "HTTP method (GET, POST, PUT, DELETE)",
+					Required:    false,
+					Default:     "GET",
+				},
+				{
+					Name:        "headers",
+					Type:        "object",
+					Description: "Request headers",
+					Required:    false,
+				},
+				{
+					Name:        "json",
+					Type:        "object",
+					Description: "JSON body to send",
+					Required:    false,
+				},
+				{
+					Name:        "body",
+					Type:        "string",
+					Description: "Raw body to send, used when json is not given",
+					Required:    false,
+				},
+			},
+			Permission: tools.PermissionNetwork,
+		},
+		transport: http.DefaultTransport,
+	}
+}
+
+// Execute sends an HTTP request
+func (t *HTTPRequestTool) Execute(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	settings := loadHTTPSettings(tools.SettingsFromContext(ctx, t.Name))
+
+	// Get parameters
+	rawURL, _ := params["url"].(string)
+	reqURL, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+	if reqURL.Scheme != "http" && reqURL.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported url scheme: %q", reqURL.Scheme)
+	}
+	if err := settings.checkHost(reqURL); err != nil {
+		return nil, err
+	}
+
+	method, _ := params["method"].(string)
+	method = strings.ToUpper(method)
+	switch method {
+	case "":
+		method = http.MethodGet
+	case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete:
+	default:
+		return nil, fmt.Errorf("unsupported method: %s", method)
+	}
+
+	// Build body
+	var body []byte
+	contentType := ""
+	if value, ok := params["json"]; ok && value != nil {
+		if body, err = json.Marshal(value); err != nil {
+			return nil, fmt.Errorf("failed to encode json body: %w", err)
+		}
+		contentType = "application/json"
+	} else if raw, ok := params["body"].(string); ok {
+		body = []byte(raw)
+	}
+	if int64(len(body)) > settings.maxRequestBytes {
+		return nil, fmt.Errorf("request body of %d bytes exceeds the limit of %d bytes", len(body), settings.maxRequestBytes)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, settings.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, reqURL.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if len(body) == 0 {
+		req.Body = http.NoBody
+	}
+
+	// Headers from the agent's settings take precedence over the model's
+	if headers, ok := params["headers"].(map[string]interface{}); ok {
+		for name, value := range headers {
+			req.Header.Set(name, fmt.Sprint(value))
+		}
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", "application/json, text/html;q=0.9, text/*;q=0.8, */*;q=0.5")
+	}
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", "SentinelStacks")
+	}
+	for name, value := range settings.headers {
+		req.Header.Set(name, value)
+	}
+
+	// Redirects are followed only within the allowlist
+	client := &http.Client{
+		Transport: t.transport,
+		CheckRedirect: func(next *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			return settings.checkHost(next.URL)
+		},
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, settings.maxResponseBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	result := &HTTPResponse{
+		URL:        resp.Request.URL.String(),
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Headers:    make(map[string]string, len(resp.Header)),
+	}
+	for name := range resp.Header {
+		result.Headers[name] = resp.Header.Get(name)
+	}
+	if int64(len(data)) > settings.maxResponseBytes {
+		data = data[:settings.maxResponseBytes]
+		result.Truncated = true
+	}
+
+	setResponseBody(result, resp.Header.Get("Content-Type"), data)
+	return result, nil
+}
+
+// setResponseBody sets the body of a response according to its content
+// type: decoded JSON, HTML as text, or text. Binary bodies are omitted.
+func setResponseBody(result *HTTPResponse, contentType string, data []byte) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	result.ContentType = mediaType
+
+	switch {
+	case mediaType == "text/html" || mediaType == "application/xhtml+xml":
+		result.Body, result.Title = HTMLToText(bytes.NewReader(data))
+		return
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		var value interface{}
+		if !result.Truncated && json.Unmarshal(data, &value) == nil {
+			result.JSON = value
+			return
+		}
+	}
+
+	if len(data) > 0 && !utf8.Valid(data) && !result.Truncated {
+		result.Body = fmt.Sprintf("[%d bytes of binary content]", len(data))
+		return
+	}
+	result.Body = string(data)
+}
+
+// loadHTTPSettings reads the tool's section of an agent's toolSettings
+func loadHTTPSettings(settings map[string]interface{}) *httpSettings {
+	s := &httpSettings{
+		allowedDomains:   tools.SettingStrings(settings, "allowed_domains"),
+		headers:          tools.SettingMap(settings, "headers"),
+		timeout:          time.Duration(tools.SettingInt(settings, "timeout", 0)) * time.Second,
+		maxRequestBytes:  tools.SettingInt(settings, "max_request_bytes", DefaultMaxRequestBytes),
+		maxResponseBytes: tools.SettingInt(settings, "max_response_bytes", DefaultMaxResponseBytes),
+	}
+	if s.timeout <= 0 {
+		s.timeout = DefaultHTTPTimeout
+	}
+	return s
+}
+
+// checkHost returns an error unless the host of a URL is allowed. Entries
+// of the allowlist are host names, "*.example.com" for any subdomain of
+// example.com, or "*" for any host.
+func (s *httpSettings) checkHost(u *url.URL) error {
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "" {
+		return fmt.Errorf("url has no host: %s", u)
+	}
+	if len(s.allowedDomains) == 0 {
+		return fmt.Errorf("no domains are allowed; set allowed_domains in the %s tool settings", HTTPRequestToolName)
+	}
+
+	for _, domain := range s.allowedDomains {
+		domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+		switch {
+		case domain == "*":
+			return nil
+		case strings.HasPrefix(domain, "*."):
+			if strings.HasSuffix(host, domain[1:]) && net.ParseIP(host) == nil {
+				return nil
+			}
+		case domain == host:
+			return nil
+		}
+	}
+
+	return fmt.Errorf("domain not allowed: %s", host)
+}
@@ -0,0 +1,172 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/tools"
+)
+
+func withHTTPSettings(settings map[string]interface{}) context.Context {
+	return tools.WithSettings(context.Background(), map[string]interface{}{
+		HTTPRequestToolName: settings,
+	})
+}
+
+func TestHTTPRequestAllowlist(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "http://example.invalid/", http.StatusFound)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	tool := NewHTTPRequestTool()
+
+	// Without settings no domain is allowed
+	if _, err := tool.Execute(context.Background(), map[string]interface{}{"url": server.URL}); err == nil {
+		t.Error("Expected request without an allowlist to be rejected")
+	}
+
+	ctx := withHTTPSettings(map[string]interface{}{"allowed_domains": []interface{}{"*.example.com"}})
+	if _, err := tool.Execute(ctx, map[string]interface{}{"url": server.URL}); err == nil {
+		t.Error("Expected request to a domain outside the allowlist to be rejected")
+	}
+
+	ctx = withHTTPSettings(map[string]interface{}{"allowed_domains": []interface{}{"127.0.0.1"}})
+	result, err := tool.Execute(ctx, map[string]interface{}{"url": server.URL})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if body := result.(*HTTPResponse).Body; body != "ok" {
+		t.Errorf("Expected body %q, got %q", "ok", body)
+	}
+
+	if _, err := tool.Execute(ctx, map[string]interface{}{"url": server.URL + "/redirect"}); err == nil || !strings.Contains(err.Error(), "domain not allowed") {
+		t.Errorf("Expected redirect outside the allowlist to be rejected, got %v", err)
+	}
+}
+
+func TestHTTPRequestJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"method":        r.Method,
+			"content_type":  r.Header.Get("Content-Type"),
+			"authorization": r.Header.Get("Authorization"),
+			"body":          string(body),
+		})
+	}))
+	defer server.Close()
+
+	tool := NewHTTPRequestTool()
+	ctx := withHTTPSettings(map[string]interface{}{
+		"allowed_domains": "127.0.0.1",
+		"headers":         map[string]interface{}{"Authorization": "Bearer configured"},
+	})
+
+	result, err := tool.Execute(ctx, map[string]interface{}{
+		"url":     server.URL,
+		"method":  "put",
+		"headers": map[string]interface{}{"Authorization": "Bearer model"},
+		"json":    map[string]interface{}{"name": "sentinel"},
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	echo, ok := result.(*HTTPResponse).JSON.(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected decoded JSON response, got %+v", result)
+	}
+	if echo["method"] != "PUT" {
+		t.Errorf("Expected method PUT, got %v", echo["method"])
+	}
+	if echo["content_type"] != "application/json" {
+		t.Errorf("Expected JSON content type, got %v", echo["content_type"])
+	}
+	if echo["authorization"] != "Bearer configured" {
+		t.Errorf("Expected configured header to take precedence, got %v", echo["authorization"])
+	}
+	if echo["body"] != `{"name":"sentinel"}` {
+		t.Errorf("Unexpected body: %v", echo["body"])
+	}
+
+	if _, err := tool.Execute(ctx, map[string]interface{}{"url": server.URL, "method": "PATCH"}); err == nil {
+		t.Error("Expected unsupported method to be rejected")
+	}
+}
+
+func TestHTTPRequestLimits(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(strings.Repeat("a", 1000)))
+	}))
+	defer server.Close()
+
+	tool := NewHTTPRequestTool()
+	ctx := withHTTPSettings(map[string]interface{}{
+		"allowed_domains":    []interface{}{"127.0.0.1"},
+		"max_response_bytes": 100,
+		"max_request_bytes":  10,
+	})
+
+	result, err := tool.Execute(ctx, map[string]interface{}{"url": server.URL})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	response := result.(*HTTPResponse)
+	if len(response.Body) != 100 || !response.Truncated {
+		t.Errorf("Expected 100 bytes of truncated body, got %d (truncated %t)", len(response.Body), response.Truncated)
+	}
+
+	if _, err := tool.Execute(ctx, map[string]interface{}{"url": server.URL, "method": "POST", "body": "more than ten bytes"}); err == nil {
+		t.Error("Expected oversized request body to be rejected")
+	}
+}
+
+func TestHTMLToText(t *testing.T) {
+	page := `<!DOCTYPE html>
+<html><head><title> Quarterly
+ report </title><style>body { color: red }</style></head>
+<body>
+<script>var hidden = true;</script>
+<h1>Results</h1>
+<p>Revenue <b>grew</b>   by 10%.<br>See <a href="/details">the details</a>.</p>
+<ul><li>North &amp; South</li><li>East</li></ul>
+<table><tr><th>Region</th><th>Sales</th></tr><tr><td>North</td><td>5</td></tr></table>
+<pre>line 1
+  line 2</pre>
+</body></html>`
+
+	text, title := HTMLToText(strings.NewReader(page))
+
+	if title != "Quarterly report" {
+		t.Errorf("Expected title %q, got %q", "Quarterly report", title)
+	}
+
+	expected := `# Results
+
+Revenue grew by 10%.
+See the details (/details).
+
+- North & South
+- East
+
+Region | Sales
+North | 5
+
+line 1
+  line 2`
+	if text != expected {
+		t.Errorf("Unexpected text:\n%s\n\nexpected:\n%s", text, expected)
+	}
+}
@@ -19,6 +19,9 @@ func RegisterWebTools() error {
 	if err := registry.RegisterTool(searchTool); err != nil {
 		return err
 	}
+	if err := registry.RegisterTool(NewHTTPRequestTool()); err != nil {
+		return err
+	}
 	
 	return nil
 }
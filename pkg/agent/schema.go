@@ -0,0 +1,396 @@
+package agent
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// SchemaViolation describes one way a value does not match a JSON Schema
+type SchemaViolation struct {
+	// Path locates the offending value, such as $.items[2].name
+	Path string
+	// Message explains the violation
+	Message string
+}
+
+// String returns the violation as "path: message"
+func (v SchemaViolation) String() string {
+	return fmt.Sprintf("%s: %s", v.Path, v.Message)
+}
+
+// StateFieldSchema returns the JSON Schema for a state field. Sentinelfile
+// type names such as int, float, list and map are mapped to their JSON
+// Schema equivalents.
+func StateFieldSchema(field StateField) map[string]interface{} {
+	schema := map[string]interface{}{}
+
+	if jsonType := jsonSchemaType(field.Type); jsonType != "" {
+		schema["type"] = jsonType
+	}
+	if field.Description != "" {
+		schema["description"] = field.Description
+	}
+	if field.Default != nil {
+		schema["default"] = field.Default
+	}
+
+	return schema
+}
+
+// StateSchemaToJSONSchema converts a Sentinelfile state schema into a JSON
+// Schema for an object. Fields without a default are required.
+func StateSchemaToJSONSchema(fields map[string]StateField) map[string]interface{} {
+	properties := make(map[string]interface{}, len(fields))
+	required := []interface{}{}
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		field := fields[name]
+		properties[name] = StateFieldSchema(field)
+		if field.Default == nil {
+			required = append(required, name)
+		}
+	}
+
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+}
+
+// jsonSchemaType maps a state field type to a JSON Schema type
+func jsonSchemaType(fieldType string) string {
+	switch strings.ToLower(strings.TrimSpace(fieldType)) {
+	case "string", "text":
+		return "string"
+	case "int", "integer":
+		return "integer"
+	case "float", "number", "double":
+		return "number"
+	case "bool", "boolean":
+		return "boolean"
+	case "list", "array":
+		return "array"
+	case "map", "object", "dict":
+		return "object"
+	case "null":
+		return "null"
+	}
+	return ""
+}
+
+// ValidateJSONSchema checks a decoded JSON value against a JSON Schema and
+// returns every violation found. It supports the commonly used keywords:
+// type, enum, const, properties, required, additionalProperties, items,
+// minItems, maxItems, minLength, maxLength, pattern, minimum, maximum,
+// exclusiveMinimum, exclusiveMaximum, allOf, anyOf, oneOf and not.
+func ValidateJSONSchema(schema map[string]interface{}, value interface{}) []SchemaViolation {
+	v := &schemaValidator{}
+	v.validate(schema, value, "$")
+	return v.violations
+}
+
+type schemaValidator struct {
+	violations []SchemaViolation
+}
+
+func (v *schemaValidator) fail(path, format string, args ...interface{}) {
+	v.violations = append(v.violations, SchemaViolation{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *schemaValidator) validate(schema map[string]interface{}, value interface{}, path string) {
+	if schema == nil {
+		return
+	}
+
+	if expected, ok := schema["type"]; ok {
+		types := schemaTypes(expected)
+		if len(types) > 0 && !matchesAnyType(value, types) {
+			v.fail(path, "expected %s, got %s", strings.Join(types, " or "), describeType(value))
+			// Further keywords assume the right type
+			return
+		}
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, candidate := range enum {
+			if schemaEqual(candidate, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.fail(path, "value %v is not one of %v", formatValue(value), formatValue(enum))
+		}
+	}
+
+	if constant, ok := schema["const"]; ok && !schemaEqual(constant, value) {
+		v.fail(path, "value %v does not equal %v", formatValue(value), formatValue(constant))
+	}
+
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		v.validateObject(schema, typed, path)
+	case []interface{}:
+		v.validateArray(schema, typed, path)
+	case string:
+		v.validateString(schema, typed, path)
+	default:
+		if number, ok := toNumber(value); ok {
+			v.validateNumber(schema, number, path)
+		}
+	}
+
+	v.validateCombinators(schema, value, path)
+}
+
+func (v *schemaValidator) validateObject(schema map[string]interface{}, object map[string]interface{}, path string) {
+	for _, name := range toStrings(schema["required"]) {
+		if _, ok := object[name]; !ok {
+			v.fail(path, "missing required property %q", name)
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]interface{})
+
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		childPath := path + "." + name
+		if propertySchema, ok := properties[name].(map[string]interface{}); ok {
+			v.validate(propertySchema, object[name], childPath)
+			continue
+		}
+		if _, declared := properties[name]; declared {
+			continue
+		}
+
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				v.fail(childPath, "property is not allowed")
+			}
+		case map[string]interface{}:
+			v.validate(additional, object[name], childPath)
+		}
+	}
+}
+
+func (v *schemaValidator) validateArray(schema map[string]interface{}, array []interface{}, path string) {
+	if min, ok := toNumber(schema["minItems"]); ok && float64(len(array)) < min {
+		v.fail(path, "expected at least %v items, got %d", min, len(array))
+	}
+	if max, ok := toNumber(schema["maxItems"]); ok && float64(len(array)) > max {
+		v.fail(path, "expected at most %v items, got %d", max, len(array))
+	}
+
+	if items, ok := schema["items"].(map[string]interface{}); ok {
+		for i, item := range array {
+			v.validate(items, item, fmt.Sprintf("%s[%d]", path, i))
+		}
+	}
+}
+
+func (v *schemaValidator) validateString(schema map[string]interface{}, s string, path string) {
+	length := float64(len([]rune(s)))
+	if min, ok := toNumber(schema["minLength"]); ok && length < min {
+		v.fail(path, "expected at least %v characters, got %v", min, length)
+	}
+	if max, ok := toNumber(schema["maxLength"]); ok && length > max {
+		v.fail(path, "expected at most %v characters, got %v", max, length)
+	}
+
+	if pattern, ok := schema["pattern"].(string); ok {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			v.fail(path, "schema pattern %q is invalid: %v", pattern, err)
+		} else if !re.MatchString(s) {
+			v.fail(path, "value %q does not match pattern %q", s, pattern)
+		}
+	}
+}
+
+func (v *schemaValidator) validateNumber(schema map[string]interface{}, n float64, path string) {
+	if min, ok := toNumber(schema["minimum"]); ok && n < min {
+		v.fail(path, "value %v is less than the minimum %v", n, min)
+	}
+	if max, ok := toNumber(schema["maximum"]); ok && n > max {
+		v.fail(path, "value %v is greater than the maximum %v", n, max)
+	}
+	if min, ok := toNumber(schema["exclusiveMinimum"]); ok && n <= min {
+		v.fail(path, "value %v must be greater than %v", n, min)
+	}
+	if max, ok := toNumber(schema["exclusiveMaximum"]); ok && n >= max {
+		v.fail(path, "value %v must be less than %v", n, max)
+	}
+}
+
+func (v *schemaValidator) validateCombinators(schema map[string]interface{}, value interface{}, path string) {
+	if allOf, ok := schema["allOf"].([]interface{}); ok {
+		for _, sub := range allOf {
+			if subSchema, ok := sub.(map[string]interface{}); ok {
+				v.validate(subSchema, value, path)
+			}
+		}
+	}
+
+	if anyOf, ok := schema["anyOf"].([]interface{}); ok && countMatches(anyOf, value) == 0 {
+		v.fail(path, "value does not match any of the allowed schemas")
+	}
+
+	if oneOf, ok := schema["oneOf"].([]interface{}); ok {
+		if matches := countMatches(oneOf, value); matches != 1 {
+			v.fail(path, "value matches %d of the oneOf schemas, expected exactly 1", matches)
+		}
+	}
+
+	if not, ok := schema["not"].(map[string]interface{}); ok && len(ValidateJSONSchema(not, value)) == 0 {
+		v.fail(path, "value must not match the schema in not")
+	}
+}
+
+// countMatches returns how many of the schemas a value matches
+func countMatches(schemas []interface{}, value interface{}) int {
+	matches := 0
+	for _, sub := range schemas {
+		if subSchema, ok := sub.(map[string]interface{}); ok && len(ValidateJSONSchema(subSchema, value)) == 0 {
+			matches++
+		}
+	}
+	return matches
+}
+
+// schemaTypes returns the type names of a type keyword
+func schemaTypes(value interface{}) []string {
+	if s, ok := value.(string); ok {
+		return []string{s}
+	}
+	return toStrings(value)
+}
+
+// matchesAnyType reports whether a value has one of the JSON Schema types
+func matchesAnyType(value interface{}, types []string) bool {
+	for _, t := range types {
+		if matchesType(value, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesType reports whether a value has the given JSON Schema type
+func matchesType(value interface{}, schemaType string) bool {
+	switch schemaType {
+	case "null":
+		return value == nil
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "number":
+		_, ok := toNumber(value)
+		return ok
+	case "integer":
+		n, ok := toNumber(value)
+		return ok && n == math.Trunc(n)
+	}
+	return false
+}
+
+// describeType names the JSON type of a value for error messages
+func describeType(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	}
+	if _, ok := toNumber(value); ok {
+		return "number"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// toNumber converts numeric values to float64
+func toNumber(value interface{}) (float64, bool) {
+	switch n := value.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// toStrings converts a list of strings in either Go or decoded JSON form
+func toStrings(value interface{}) []string {
+	switch list := value.(type) {
+	case []string:
+		return list
+	case []interface{}:
+		result := make([]string, 0, len(list))
+		for _, item := range list {
+			if s, ok := item.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	}
+	return nil
+}
+
+// schemaEqual compares two JSON values, treating all numeric types alike
+func schemaEqual(a, b interface{}) bool {
+	if an, ok := toNumber(a); ok {
+		bn, ok := toNumber(b)
+		return ok && an == bn
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// formatValue renders a value for error messages
+func formatValue(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return fmt.Sprintf("%q", s)
+	}
+	return fmt.Sprintf("%v", value)
+}
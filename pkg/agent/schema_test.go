@@ -0,0 +1,75 @@
+package agent
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestValidateJSONSchema(t *testing.T) {
+	schema := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(`{
+		"type": "object",
+		"required": ["label", "score"],
+		"properties": {
+			"label": {"type": "string", "enum": ["bug", "feature"]},
+			"score": {"type": "number", "minimum": 0, "maximum": 1},
+			"tags": {"type": "array", "items": {"type": "string"}, "maxItems": 2}
+		},
+		"additionalProperties": false
+	}`), &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		value string
+		want  []string
+	}{
+		{"valid", `{"label": "bug", "score": 0.5, "tags": ["ui"]}`, nil},
+		{"missing required", `{"label": "bug"}`, []string{`$: missing required property "score"`}},
+		{"wrong type", `{"label": "bug", "score": "high"}`, []string{"$.score: expected number, got string"}},
+		{"enum", `{"label": "question", "score": 1}`, []string{`$.label: value "question" is not one of`}},
+		{"nested item", `{"label": "bug", "score": 1, "tags": ["ui", 3]}`, []string{"$.tags[1]: expected string, got number"}},
+		{"additional property", `{"label": "bug", "score": 1, "extra": true}`, []string{"$.extra: property is not allowed"}},
+		{"not an object", `[1, 2]`, []string{"$: expected object, got array"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var value interface{}
+			if err := json.Unmarshal([]byte(tt.value), &value); err != nil {
+				t.Fatal(err)
+			}
+
+			violations := ValidateJSONSchema(schema, value)
+			if len(violations) != len(tt.want) {
+				t.Fatalf("got %d violations %v, want %d", len(violations), violations, len(tt.want))
+			}
+			for i, want := range tt.want {
+				if !strings.HasPrefix(violations[i].String(), want) {
+					t.Errorf("violation %d = %q, want prefix %q", i, violations[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestStateSchemaToJSONSchema(t *testing.T) {
+	schema := StateSchemaToJSONSchema(map[string]StateField{
+		"count":   {Type: "int"},
+		"summary": {Type: "string", Default: ""},
+	})
+
+	if violations := ValidateJSONSchema(schema, map[string]interface{}{"count": 3.0}); len(violations) != 0 {
+		t.Errorf("unexpected violations: %v", violations)
+	}
+
+	violations := ValidateJSONSchema(schema, map[string]interface{}{"count": 1.5, "summary": 2.0})
+	if len(violations) != 2 {
+		t.Fatalf("got violations %v, want 2", violations)
+	}
+	if violations[0].Path != "$.count" || violations[1].Path != "$.summary" {
+		t.Errorf("unexpected violation paths: %v", violations)
+	}
+}
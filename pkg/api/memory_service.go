@@ -15,6 +15,9 @@ type MemoryServiceConfig struct {
 	// StoragePath is where memory data is persisted
 	StoragePath string
 
+	// StoreType is the store implementation: "local", "sqlite" or "chroma"
+	StoreType string
+
 	// EmbeddingProvider specifies the embedding provider to use
 	EmbeddingProvider string
 
@@ -23,6 +26,13 @@ type MemoryServiceConfig struct {
 
 	// EmbeddingDimensions specifies the dimensions of embeddings
 	EmbeddingDimensions int
+
+	// EmbeddingOptions are provider-specific options, such as endpoint and
+	// api_key
+	EmbeddingOptions map[string]interface{}
+
+	// MetadataFields are metadata fields indexed for filtered searches
+	MetadataFields []string
 }
 
 // MemoryService implements types.MemoryService
@@ -39,8 +49,15 @@ func NewMemoryService(config MemoryServiceConfig) (*MemoryService, error) {
 	// Create a memory factory
 	factoryConfig := memory.FactoryConfig{
 		BasePath:                config.StoragePath,
-		PreferredStoreType:      config.EmbeddingProvider,
+		PreferredStoreType:      config.StoreType,
 		DefaultVectorDimensions: config.EmbeddingDimensions,
+		MetadataFields:          config.MetadataFields,
+		Embeddings: types.EmbeddingConfig{
+			Provider:          config.EmbeddingProvider,
+			Model:             config.EmbeddingModel,
+			Dimensions:        config.EmbeddingDimensions,
+			AdditionalOptions: config.EmbeddingOptions,
+		},
 	}
 
 	factory := memory.NewFactory(factoryConfig)
@@ -143,3 +160,29 @@ func (s *MemoryService) SearchSimilar(ctx context.Context, collection string, te
 
 	return store.SearchVector(ctx, text, limit, filter)
 }
+
+// SearchFiltered finds similar texts whose metadata passes filter. See
+// memory.ParseFilter for the filter language.
+func (s *MemoryService) SearchFiltered(ctx context.Context, collection string, text string, limit int, filter map[string]interface{}) ([]types.MemoryMatch, error) {
+	store, err := s.getOrCreateVectorStore(ctx, collection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get vector store: %w", err)
+	}
+
+	return store.SearchVector(ctx, text, limit, filter)
+}
+
+// SearchHybrid finds texts by keyword relevance, weighted by keywordWeight
+// from 0 to 1, and vector similarity. Stores without keyword search rank by
+// vector similarity alone.
+func (s *MemoryService) SearchHybrid(ctx context.Context, collection string, text string, limit int, filter map[string]interface{}, keywordWeight float64) ([]types.MemoryMatch, error) {
+	store, err := s.getOrCreateVectorStore(ctx, collection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get vector store: %w", err)
+	}
+
+	if hybrid, ok := store.(types.HybridVectorStore); ok {
+		return hybrid.SearchHybrid(ctx, text, limit, filter, keywordWeight)
+	}
+	return store.SearchVector(ctx, text, limit, filter)
+}
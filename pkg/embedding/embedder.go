@@ -0,0 +1,250 @@
+// Package embedding provides text embedders for the vector stores, backed
+// by Ollama, OpenAI or a local hashing model
+package embedding
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/satishgonella2024/sentinelstacks/pkg/types"
+)
+
+// Embedding providers
+const (
+	ProviderOllama = "ollama"
+	ProviderOpenAI = "openai"
+	ProviderLocal  = "local"
+)
+
+// Defaults of the AdditionalOptions of an EmbeddingConfig
+const (
+	DefaultBatchSize = 64
+	DefaultCacheSize = 1024
+	DefaultTimeout   = 60 * time.Second
+)
+
+// modelDimensions are the dimensionalities of well-known models, used when
+// the configuration does not give one
+var modelDimensions = map[string]int{
+	"nomic-embed-text":       768,
+	"mxbai-embed-large":      1024,
+	"all-minilm":             384,
+	"snowflake-arctic-embed": 1024,
+	"bge-m3":                 1024,
+	"text-embedding-3-small": 1536,
+	"text-embedding-3-large": 3072,
+	"text-embedding-ada-002": 1536,
+}
+
+// batchEmbedder embeds a batch of texts with one request
+type batchEmbedder interface {
+	embed(ctx context.Context, texts []string) ([][]float32, error)
+	model() string
+}
+
+// New returns the embedder described by config. The AdditionalOptions
+// endpoint, api_key, timeout (seconds), batch_size and cache_size tune it.
+//
+// Embeddings are requested in batches of batch_size texts and the last
+// cache_size are cached. Embeddings whose dimensionality differs from the
+// configured one, or from the model's known one, are rejected.
+func New(config types.EmbeddingConfig) (types.Embedder, error) {
+	options := config.AdditionalOptions
+	timeout := DefaultTimeout
+	if seconds := intOption(options, "timeout"); seconds > 0 {
+		timeout = time.Duration(seconds) * time.Second
+	}
+
+	dimensions := config.Dimensions
+	if dimensions == 0 {
+		dimensions = modelDimensions[strings.SplitN(config.Model, ":", 2)[0]]
+	}
+
+	var base batchEmbedder
+	switch strings.ToLower(config.Provider) {
+	case ProviderOllama:
+		base = newOllamaEmbedder(config.Model, stringOption(options, "endpoint"), timeout)
+	case ProviderOpenAI:
+		var err error
+		base, err = newOpenAIEmbedder(config.Model, stringOption(options, "endpoint"), stringOption(options, "api_key"), config.Dimensions, timeout)
+		if err != nil {
+			return nil, err
+		}
+	case ProviderLocal, "":
+		if dimensions == 0 {
+			dimensions = DefaultHashDimensions
+		}
+		base = NewHashEmbedder(dimensions)
+	default:
+		return nil, fmt.Errorf("unsupported embedding provider: %s", config.Provider)
+	}
+
+	batchSize := intOption(options, "batch_size")
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	cacheSize := intOption(options, "cache_size")
+	if cacheSize <= 0 {
+		cacheSize = DefaultCacheSize
+	}
+
+	return &embedder{
+		base:       base,
+		batchSize:  batchSize,
+		dimensions: dimensions,
+		cache:      newCache(cacheSize),
+	}, nil
+}
+
+// embedder batches, caches and checks the embeddings of a batchEmbedder
+type embedder struct {
+	base      batchEmbedder
+	batchSize int
+	cache     *cache
+
+	mu         sync.Mutex
+	dimensions int
+}
+
+// Dimensions returns the dimensionality of the embeddings
+func (e *embedder) Dimensions() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.dimensions
+}
+
+// Embed returns the embedding of each text, requesting those not cached
+func (e *embedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	result := make([][]float32, len(texts))
+
+	// Collect the distinct texts that are not cached
+	var missing []string
+	positions := make(map[string][]int)
+	for i, text := range texts {
+		if vector, ok := e.cache.get(text); ok {
+			result[i] = vector
+			continue
+		}
+		if _, seen := positions[text]; !seen {
+			missing = append(missing, text)
+		}
+		positions[text] = append(positions[text], i)
+	}
+
+	for start := 0; start < len(missing); start += e.batchSize {
+		end := start + e.batchSize
+		if end > len(missing) {
+			end = len(missing)
+		}
+		batch := missing[start:end]
+
+		vectors, err := e.base.embed(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+		if len(vectors) != len(batch) {
+			return nil, fmt.Errorf("embedding model %s returned %d embeddings for %d texts", e.base.model(), len(vectors), len(batch))
+		}
+
+		for i, vector := range vectors {
+			if err := e.checkDimensions(vector); err != nil {
+				return nil, err
+			}
+			e.cache.put(batch[i], vector)
+			for _, position := range positions[batch[i]] {
+				result[position] = vector
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// checkDimensions rejects a vector of the wrong dimensionality. The first
+// vector sets it when it is not known.
+func (e *embedder) checkDimensions(vector []float32) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.dimensions == 0 {
+		e.dimensions = len(vector)
+	}
+	if len(vector) != e.dimensions {
+		return fmt.Errorf("embedding model %s returned %d dimensions, expected %d", e.base.model(), len(vector), e.dimensions)
+	}
+	return nil
+}
+
+// cache is a least recently used cache of embeddings by text
+type cache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	text   string
+	vector []float32
+}
+
+func newCache(size int) *cache {
+	return &cache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (c *cache) get(text string) ([]float32, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[text]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*cacheEntry).vector, true
+}
+
+func (c *cache) put(text string, vector []float32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[text]; ok {
+		element.Value.(*cacheEntry).vector = vector
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[text] = c.order.PushFront(&cacheEntry{text: text, vector: vector})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).text)
+	}
+}
+
+// stringOption returns a string option, or "" when it is not set
+func stringOption(options map[string]interface{}, key string) string {
+	value, _ := options[key].(string)
+	return value
+}
+
+// intOption returns a numeric option, or 0 when it is not set
+func intOption(options map[string]interface{}, key string) int {
+	switch value := options[key].(type) {
+	case int:
+		return value
+	case int64:
+		return int(value)
+	case float64:
+		return int(value)
+	}
+	return 0
+}
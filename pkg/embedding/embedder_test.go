@@ -0,0 +1,180 @@
+package embedding
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/satishgonella2024/sentinelstacks/pkg/types"
+)
+
+// embeddingServer serves the Ollama and OpenAI embedding endpoints,
+// embedding each text as its length repeated dimensions times
+func embeddingServer(t *testing.T, dimensions int) (*httptest.Server, func() [][]string) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var batches [][]string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model string   `json:"model"`
+			Input []string `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		mu.Lock()
+		batches = append(batches, req.Input)
+		mu.Unlock()
+
+		vectors := make([][]float32, len(req.Input))
+		for i, text := range req.Input {
+			vectors[i] = make([]float32, dimensions)
+			for j := range vectors[i] {
+				vectors[i][j] = float32(len(text))
+			}
+		}
+
+		switch r.URL.Path {
+		case "/api/embed":
+			json.NewEncoder(w).Encode(map[string]interface{}{"embeddings": vectors})
+		case "/v1/embeddings":
+			if r.Header.Get("Authorization") != "Bearer key" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			// Returned out of order, as the index says where each belongs
+			var data []map[string]interface{}
+			for i := len(vectors) - 1; i >= 0; i-- {
+				data = append(data, map[string]interface{}{"index": i, "embedding": vectors[i]})
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	return server, func() [][]string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([][]string(nil), batches...)
+	}
+}
+
+func TestOllamaEmbedderBatchesAndCaches(t *testing.T) {
+	server, batches := embeddingServer(t, 4)
+	defer server.Close()
+
+	embedder, err := New(types.EmbeddingConfig{
+		Provider:          ProviderOllama,
+		Model:             "test-model",
+		AdditionalOptions: map[string]interface{}{"endpoint": server.URL, "batch_size": 2},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	ctx := context.Background()
+	vectors, err := embedder.Embed(ctx, []string{"a", "bb", "ccc", "a", "dddd", "eeeee"})
+	if err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+	if len(vectors) != 6 || vectors[2][0] != 3 || vectors[3][0] != 1 {
+		t.Errorf("Unexpected embeddings: %v", vectors)
+	}
+	if embedder.Dimensions() != 4 {
+		t.Errorf("Expected dimensions to be learned from the model, got %d", embedder.Dimensions())
+	}
+
+	// Duplicates are embedded once, in batches of two
+	if got := batches(); len(got) != 3 || strings.Join(got[2], ",") != "eeeee" {
+		t.Errorf("Expected 3 batches, got %v", got)
+	}
+
+	// Cached texts are not requested again
+	if _, err := embedder.Embed(ctx, []string{"bb", "ffffff"}); err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+	if got := batches(); len(got) != 4 || strings.Join(got[3], ",") != "ffffff" {
+		t.Errorf("Expected only the new text to be requested, got %v", got)
+	}
+}
+
+func TestOpenAIEmbedder(t *testing.T) {
+	server, _ := embeddingServer(t, 3)
+	defer server.Close()
+
+	embedder, err := New(types.EmbeddingConfig{
+		Provider:          ProviderOpenAI,
+		Model:             "test-model",
+		Dimensions:        3,
+		AdditionalOptions: map[string]interface{}{"endpoint": server.URL + "/v1", "api_key": "key"},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	vectors, err := embedder.Embed(context.Background(), []string{"a", "bb"})
+	if err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+	if vectors[0][0] != 1 || vectors[1][0] != 2 {
+		t.Errorf("Expected embeddings in input order, got %v", vectors)
+	}
+}
+
+func TestEmbedderRejectsDimensionMismatch(t *testing.T) {
+	server, _ := embeddingServer(t, 4)
+	defer server.Close()
+
+	embedder, err := New(types.EmbeddingConfig{
+		Provider:          ProviderOllama,
+		Model:             "test-model",
+		Dimensions:        768,
+		AdditionalOptions: map[string]interface{}{"endpoint": server.URL},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	if _, err := embedder.Embed(context.Background(), []string{"a"}); err == nil || !strings.Contains(err.Error(), "expected 768") {
+		t.Errorf("Expected a dimension mismatch error, got %v", err)
+	}
+}
+
+func TestHashEmbedder(t *testing.T) {
+	embedder, err := New(types.EmbeddingConfig{Provider: ProviderLocal, Dimensions: 64})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	vectors, err := embedder.Embed(context.Background(), []string{
+		"the cat sat on the mat",
+		"a cat sat on a mat",
+		"quarterly revenue grew strongly",
+	})
+	if err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+	if len(vectors[0]) != 64 {
+		t.Fatalf("Expected 64 dimensions, got %d", len(vectors[0]))
+	}
+
+	dot := func(a, b []float32) float32 {
+		var sum float32
+		for i := range a {
+			sum += a[i] * b[i]
+		}
+		return sum
+	}
+	if dot(vectors[0], vectors[1]) <= dot(vectors[0], vectors[2]) {
+		t.Error("Expected texts sharing words to be more similar")
+	}
+}
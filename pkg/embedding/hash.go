@@ -0,0 +1,88 @@
+package embedding
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// DefaultHashDimensions is the dimensionality of local embeddings when the
+// configuration does not give one
+const DefaultHashDimensions = 384
+
+// HashEmbedder embeds texts locally by hashing their words and word pairs
+// into a fixed number of dimensions. It captures lexical rather than
+// semantic similarity, and needs no model or server.
+type HashEmbedder struct {
+	dimensions int
+}
+
+// NewHashEmbedder returns a local embedder of the given dimensionality
+func NewHashEmbedder(dimensions int) *HashEmbedder {
+	if dimensions <= 0 {
+		dimensions = DefaultHashDimensions
+	}
+	return &HashEmbedder{dimensions: dimensions}
+}
+
+// Dimensions returns the dimensionality of the embeddings
+func (e *HashEmbedder) Dimensions() int {
+	return e.dimensions
+}
+
+// Embed returns the normalized embedding of each text
+func (e *HashEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return e.embed(ctx, texts)
+}
+
+func (e *HashEmbedder) model() string {
+	return "local"
+}
+
+func (e *HashEmbedder) embed(ctx context.Context, texts []string) ([][]float32, error) {
+	embeddings := make([][]float32, len(texts))
+	for i, text := range texts {
+		embeddings[i] = e.vector(text)
+	}
+	return embeddings, nil
+}
+
+// vector adds each word and word pair of text to the dimension its hash
+// selects, with the sign given by another bit of the hash
+func (e *HashEmbedder) vector(text string) []float32 {
+	vector := make([]float32, e.dimensions)
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	add := func(feature string, weight float32) {
+		h := fnv.New64a()
+		h.Write([]byte(feature))
+		sum := h.Sum64()
+		index := int(sum % uint64(e.dimensions))
+		if sum>>63 == 1 {
+			weight = -weight
+		}
+		vector[index] += weight
+	}
+	for i, word := range words {
+		add(word, 1)
+		if i > 0 {
+			add(words[i-1]+" "+word, 0.5)
+		}
+	}
+
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v * v)
+	}
+	if norm > 0 {
+		scale := float32(1 / math.Sqrt(norm))
+		for i := range vector {
+			vector[i] *= scale
+		}
+	}
+	return vector
+}
@@ -0,0 +1,78 @@
+package embedding
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Ollama defaults
+const (
+	DefaultOllamaEndpoint = "http://localhost:11434"
+	DefaultOllamaModel    = "nomic-embed-text"
+)
+
+// ollamaEmbedder uses the /api/embed endpoint of an Ollama server
+type ollamaEmbedder struct {
+	endpoint string
+	name     string
+	client   *http.Client
+}
+
+func newOllamaEmbedder(model, endpoint string, timeout time.Duration) *ollamaEmbedder {
+	if model == "" {
+		model = DefaultOllamaModel
+	}
+	if endpoint == "" {
+		endpoint = DefaultOllamaEndpoint
+	}
+	return &ollamaEmbedder{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		name:     model,
+		client:   &http.Client{Timeout: timeout},
+	}
+}
+
+func (e *ollamaEmbedder) model() string {
+	return e.name
+}
+
+func (e *ollamaEmbedder) embed(ctx context.Context, texts []string) ([][]float32, error) {
+	reqBody, err := json.Marshal(map[string]interface{}{
+		"model": e.name,
+		"input": texts,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint+"/api/embed", bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request embeddings from Ollama: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("ollama embeddings request failed: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var result struct {
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode embeddings: %w", err)
+	}
+	return result.Embeddings, nil
+}
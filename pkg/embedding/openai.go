@@ -0,0 +1,116 @@
+package embedding
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// OpenAI defaults
+const (
+	DefaultOpenAIEndpoint = "https://api.openai.com/v1"
+	DefaultOpenAIModel    = "text-embedding-3-small"
+)
+
+// openAIEmbedder uses the /embeddings endpoint of the OpenAI API or a
+// compatible server
+type openAIEmbedder struct {
+	endpoint   string
+	apiKey     string
+	name       string
+	dimensions int
+	client     *http.Client
+}
+
+// newOpenAIEmbedder returns an embedder for model. The API key falls back
+// to the OPENAI_API_KEY environment variable. Dimensions, when set, are
+// requested from models that can shorten their embeddings.
+func newOpenAIEmbedder(model, endpoint, apiKey string, dimensions int, timeout time.Duration) (*openAIEmbedder, error) {
+	if model == "" {
+		model = DefaultOpenAIModel
+	}
+	if endpoint == "" {
+		endpoint = DefaultOpenAIEndpoint
+	}
+	if apiKey == "" {
+		apiKey = os.Getenv("OPENAI_API_KEY")
+	}
+	if apiKey == "" {
+		return nil, fmt.Errorf("API key is required for OpenAI embeddings")
+	}
+
+	if !strings.HasPrefix(model, "text-embedding-3") {
+		dimensions = 0
+	}
+
+	return &openAIEmbedder{
+		endpoint:   strings.TrimSuffix(endpoint, "/"),
+		apiKey:     apiKey,
+		name:       model,
+		dimensions: dimensions,
+		client:     &http.Client{Timeout: timeout},
+	}, nil
+}
+
+func (e *openAIEmbedder) model() string {
+	return e.name
+}
+
+func (e *openAIEmbedder) embed(ctx context.Context, texts []string) ([][]float32, error) {
+	request := map[string]interface{}{
+		"model": e.name,
+		"input": texts,
+	}
+	if e.dimensions > 0 {
+		request["dimensions"] = e.dimensions
+	}
+	reqBody, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint+"/embeddings", bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+e.apiKey)
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request embeddings from OpenAI: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("openai embeddings request failed: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var result struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode embeddings: %w", err)
+	}
+
+	// Embeddings are returned with the index of their input
+	sort.Slice(result.Data, func(i, j int) bool {
+		return result.Data[i].Index < result.Data[j].Index
+	})
+	embeddings := make([][]float32, len(result.Data))
+	for i, item := range result.Data {
+		embeddings[i] = item.Embedding
+	}
+	return embeddings, nil
+}
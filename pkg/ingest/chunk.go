@@ -0,0 +1,127 @@
+package ingest
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Chunking defaults, in characters
+const (
+	DefaultChunkSize    = 1000
+	DefaultChunkOverlap = 200
+)
+
+// ChunkConfig configures how text is split into chunks
+type ChunkConfig struct {
+	// Size is the most characters in a chunk
+	Size int
+	// Overlap is how many characters of a chunk's end the next chunk
+	// repeats, so that text cut at a boundary is whole in one of them
+	Overlap int
+}
+
+// DefaultChunkConfig returns the default chunking
+func DefaultChunkConfig() ChunkConfig {
+	return ChunkConfig{Size: DefaultChunkSize, Overlap: DefaultChunkOverlap}
+}
+
+// Validate checks that chunks advance through the text
+func (c ChunkConfig) Validate() error {
+	if c.Size <= 0 {
+		return fmt.Errorf("chunk size must be positive")
+	}
+	if c.Overlap < 0 || c.Overlap >= c.Size {
+		return fmt.Errorf("chunk overlap must be at least 0 and less than the chunk size")
+	}
+	return nil
+}
+
+// Chunk is a part of a document's text
+type Chunk struct {
+	// Text is the chunk's text, trimmed of surrounding white space
+	Text string
+	// Offset is the byte offset of Text in the document's text
+	Offset int
+}
+
+// Split divides text into chunks of at most config.Size characters, each
+// overlapping the last by about config.Overlap. Chunks end at the last
+// paragraph, line, sentence or word break in their second half, where
+// there is one.
+func Split(text string, config ChunkConfig) ([]Chunk, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	var chunks []Chunk
+	for start := 0; start < len(text); {
+		end := advance(text, start, config.Size)
+		if end < len(text) {
+			end = breakPoint(text, start, end)
+		}
+
+		if chunk, offset := trimChunk(text[start:end]); chunk != "" {
+			chunks = append(chunks, Chunk{Text: chunk, Offset: start + offset})
+		}
+		if end == len(text) {
+			break
+		}
+
+		// Step back by the overlap, to the start of a word
+		next := retreat(text, end, config.Overlap)
+		if i := strings.IndexFunc(text[next:end], unicode.IsSpace); config.Overlap > 0 && i >= 0 {
+			next += i
+		}
+		if next <= start {
+			next = end
+		}
+		start = next
+	}
+	return chunks, nil
+}
+
+// advance returns the offset n characters after start, or the end of text
+func advance(text string, start, n int) int {
+	i := start
+	for ; n > 0 && i < len(text); n-- {
+		_, size := utf8.DecodeRuneInString(text[i:])
+		i += size
+	}
+	return i
+}
+
+// retreat returns the offset n characters before end, or start of text
+func retreat(text string, end, n int) int {
+	i := end
+	for ; n > 0 && i > 0; n-- {
+		_, size := utf8.DecodeLastRuneInString(text[:i])
+		i -= size
+	}
+	return i
+}
+
+// breaks are the places a chunk may end, best first
+var breaks = []string{"\n\n", "\n", ". ", "? ", "! ", " "}
+
+// breakPoint returns where the chunk from start to end is best cut: after
+// the last break in its second half, or at end when there is none
+func breakPoint(text string, start, end int) int {
+	half := start + (end-start)/2
+	window := text[half:end]
+	for _, sep := range breaks {
+		if i := strings.LastIndex(window, sep); i >= 0 {
+			return half + i + len(sep)
+		}
+	}
+	return end
+}
+
+// trimChunk trims white space from a chunk, returning the offset of the
+// trimmed text in it
+func trimChunk(chunk string) (string, int) {
+	trimmed := strings.TrimLeftFunc(chunk, unicode.IsSpace)
+	offset := len(chunk) - len(trimmed)
+	return strings.TrimRightFunc(trimmed, unicode.IsSpace), offset
+}
@@ -0,0 +1,318 @@
+// Package ingest extracts the text of documents and splits it into chunks
+// for embedding into an agent's memory
+package ingest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// Document formats
+const (
+	FormatText = "text"
+	FormatHTML = "html"
+	FormatPDF  = "pdf"
+	FormatCode = "code"
+)
+
+// MaxFileSize is the largest file loaded, beyond which a file is taken not
+// to be a document
+const MaxFileSize = 32 << 20
+
+// formats maps file extensions to the formats they hold
+var formats = map[string]string{
+	".md":       FormatText,
+	".markdown": FormatText,
+	".mdx":      FormatText,
+	".txt":      FormatText,
+	".text":     FormatText,
+	".rst":      FormatText,
+	".adoc":     FormatText,
+	".org":      FormatText,
+	".csv":      FormatText,
+	".log":      FormatText,
+	".html":     FormatHTML,
+	".htm":      FormatHTML,
+	".xhtml":    FormatHTML,
+	".pdf":      FormatPDF,
+	".go":       FormatCode,
+	".py":       FormatCode,
+	".js":       FormatCode,
+	".jsx":      FormatCode,
+	".ts":       FormatCode,
+	".tsx":      FormatCode,
+	".java":     FormatCode,
+	".kt":       FormatCode,
+	".scala":    FormatCode,
+	".c":        FormatCode,
+	".h":        FormatCode,
+	".cc":       FormatCode,
+	".cpp":      FormatCode,
+	".hpp":      FormatCode,
+	".cs":       FormatCode,
+	".rs":       FormatCode,
+	".rb":       FormatCode,
+	".php":      FormatCode,
+	".swift":    FormatCode,
+	".sh":       FormatCode,
+	".bash":     FormatCode,
+	".sql":      FormatCode,
+	".proto":    FormatCode,
+	".yaml":     FormatCode,
+	".yml":      FormatCode,
+	".toml":     FormatCode,
+	".json":     FormatCode,
+	".xml":      FormatCode,
+	".tf":       FormatCode,
+}
+
+// namedFormats maps the names of files without a telling extension to
+// their formats
+var namedFormats = map[string]string{
+	"Makefile":     FormatCode,
+	"Dockerfile":   FormatCode,
+	"Sentinelfile": FormatText,
+	"README":       FormatText,
+	"LICENSE":      FormatText,
+}
+
+// Document is the text extracted from a file
+type Document struct {
+	// Source is the absolute path of the file
+	Source string
+	// Format is the format the text was extracted from
+	Format string
+	// Hash is the SHA-256 of the file's content
+	Hash string
+	// Text is the extracted text
+	Text string
+}
+
+// FormatOf returns the format of the file at path, or an empty string when
+// it is not a supported document
+func FormatOf(path string) string {
+	base := filepath.Base(path)
+	if format, ok := namedFormats[base]; ok {
+		return format
+	}
+	return formats[strings.ToLower(filepath.Ext(base))]
+}
+
+// Load reads the file at path and extracts its text
+func Load(path string) (*Document, error) {
+	format := FormatOf(path)
+	if format == "" {
+		return nil, fmt.Errorf("unsupported document type: %s", path)
+	}
+
+	source, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+	info, err := os.Stat(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+	if info.Size() > MaxFileSize {
+		return nil, fmt.Errorf("%s is larger than %d bytes", path, MaxFileSize)
+	}
+	data, err := os.ReadFile(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	text, err := Extract(format, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract text from %s: %w", path, err)
+	}
+
+	sum := sha256.Sum256(data)
+	return &Document{
+		Source: source,
+		Format: format,
+		Hash:   hex.EncodeToString(sum[:]),
+		Text:   text,
+	}, nil
+}
+
+// skippedDirs are directories of dependencies and build output, which
+// Walk skips
+var skippedDirs = map[string]bool{
+	"node_modules": true,
+	"vendor":       true,
+	"__pycache__":  true,
+}
+
+// Walk calls fn with the path of each supported document under root, which
+// may itself be a document. Hidden files and directories, and dependency
+// directories such as node_modules, are skipped.
+func Walk(root string, fn func(path string) error) error {
+	info, err := os.Stat(root)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		if FormatOf(root) == "" {
+			return fmt.Errorf("unsupported document type: %s", root)
+		}
+		return fn(root)
+	}
+
+	return filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := entry.Name()
+		if path != root && (strings.HasPrefix(name, ".") || (entry.IsDir() && skippedDirs[name])) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() || !entry.Type().IsRegular() || FormatOf(path) == "" {
+			return nil
+		}
+		return fn(path)
+	})
+}
+
+// Extract returns the text of data in the given format
+func Extract(format string, data []byte) (string, error) {
+	switch format {
+	case FormatText, FormatCode:
+		if bytes.IndexByte(data, 0) >= 0 {
+			return "", fmt.Errorf("content is binary")
+		}
+		return strings.ToValidUTF8(string(data), "�"), nil
+	case FormatHTML:
+		return extractHTML(data)
+	case FormatPDF:
+		return extractPDF(data)
+	}
+	return "", fmt.Errorf("unsupported format: %s", format)
+}
+
+// blockElements are the HTML elements that break lines of text
+var blockElements = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true,
+	"br": true, "dd": true, "div": true, "dl": true, "dt": true,
+	"figcaption": true, "footer": true, "h1": true, "h2": true, "h3": true,
+	"h4": true, "h5": true, "h6": true, "header": true, "hr": true,
+	"li": true, "main": true, "nav": true, "ol": true, "p": true,
+	"pre": true, "section": true, "table": true, "title": true, "tr": true,
+	"ul": true,
+}
+
+// skippedElements are the HTML elements whose content is not text
+var skippedElements = map[string]bool{
+	"script": true, "style": true, "noscript": true, "template": true,
+	"svg": true, "iframe": true,
+}
+
+// extractHTML returns the visible text of an HTML document, a line per
+// block element
+func extractHTML(data []byte) (string, error) {
+	if !utf8.Valid(data) {
+		data = bytes.ToValidUTF8(data, []byte("�"))
+	}
+
+	var b strings.Builder
+	// space is whether white space separates the last text from the next
+	var space bool
+	newline := func() {
+		text := b.String()
+		if len(text) > 0 && !strings.HasSuffix(text, "\n") {
+			b.WriteByte('\n')
+		}
+		space = false
+	}
+
+	tokenizer := html.NewTokenizer(bytes.NewReader(data))
+	var skipping string
+	var pre int
+	for {
+		token := tokenizer.Next()
+		switch token {
+		case html.ErrorToken:
+			if err := tokenizer.Err(); err != io.EOF {
+				return "", err
+			}
+			return strings.TrimSpace(b.String()), nil
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, _ := tokenizer.TagName()
+			tag := string(name)
+			if skipping != "" {
+				continue
+			}
+			if skippedElements[tag] {
+				if token == html.StartTagToken {
+					skipping = tag
+				}
+				continue
+			}
+			if tag == "pre" {
+				pre++
+			}
+			if blockElements[tag] {
+				newline()
+			}
+
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			tag := string(name)
+			if skipping != "" {
+				if tag == skipping {
+					skipping = ""
+				}
+				continue
+			}
+			if tag == "pre" && pre > 0 {
+				pre--
+			}
+			if blockElements[tag] {
+				newline()
+			}
+
+		case html.TextToken:
+			if skipping != "" {
+				continue
+			}
+			text := string(tokenizer.Text())
+			if pre > 0 {
+				b.WriteString(text)
+				continue
+			}
+			words := strings.Fields(text)
+			if len(words) == 0 {
+				space = space || text != ""
+				continue
+			}
+			if (space || startsWithSpace(text)) && b.Len() > 0 && !strings.HasSuffix(b.String(), "\n") {
+				b.WriteByte(' ')
+			}
+			b.WriteString(strings.Join(words, " "))
+			space = endsWithSpace(text)
+		}
+	}
+}
+
+// startsWithSpace reports whether text begins with white space
+func startsWithSpace(text string) bool {
+	return strings.TrimLeft(text, " \t\r\n\f") != text
+}
+
+// endsWithSpace reports whether text ends with white space
+func endsWithSpace(text string) bool {
+	return strings.TrimRight(text, " \t\r\n\f") != text
+}
@@ -0,0 +1,174 @@
+package ingest
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplit(t *testing.T) {
+	var paragraphs []string
+	for i := 0; i < 40; i++ {
+		paragraphs = append(paragraphs, fmt.Sprintf("Paragraph %d explains step %d of the deployment. It is followed by another sentence about ünïcode.", i, i))
+	}
+	text := strings.Join(paragraphs, "\n\n")
+
+	config := ChunkConfig{Size: 300, Overlap: 60}
+	chunks, err := Split(text, config)
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+	if len(chunks) < len(text)/config.Size {
+		t.Fatalf("Expected at least %d chunks, got %d", len(text)/config.Size, len(chunks))
+	}
+
+	covered := 0
+	for i, chunk := range chunks {
+		if n := utf8.RuneCountInString(chunk.Text); n > config.Size {
+			t.Errorf("Chunk %d has %d characters", i, n)
+		}
+		if text[chunk.Offset:chunk.Offset+len(chunk.Text)] != chunk.Text {
+			t.Errorf("Chunk %d is not at its offset %d", i, chunk.Offset)
+		}
+		if !utf8.ValidString(chunk.Text) {
+			t.Errorf("Chunk %d splits a character", i)
+		}
+		if i > 0 && chunk.Offset >= covered {
+			t.Errorf("Chunk %d at %d does not overlap the last, ending at %d", i, chunk.Offset, covered)
+		}
+		covered = chunk.Offset + len(chunk.Text)
+	}
+	if covered != len(text) {
+		t.Errorf("Chunks end at %d of %d", covered, len(text))
+	}
+
+	// Chunks end at paragraph breaks where they can
+	if !strings.HasSuffix(chunks[0].Text, "ünïcode.") {
+		t.Errorf("Expected the first chunk to end with a paragraph, got %q", chunks[0].Text)
+	}
+
+	for _, config := range []ChunkConfig{{Size: 0}, {Size: 100, Overlap: 100}, {Size: 100, Overlap: -1}} {
+		if _, err := Split(text, config); err == nil {
+			t.Errorf("Expected %+v to be rejected", config)
+		}
+	}
+}
+
+func TestExtractHTML(t *testing.T) {
+	page := `<html><head><title>Runbook</title><style>p { color: red; }</style></head>
+<body><h1>Deploys</h1><p>Deploys run at <b>noon</b> on
+  weekdays &amp; never on holidays.</p><script>alert("x")</script>
+<ul><li>Check the dashboard</li><li>Tag the release</li></ul>
+<pre>make deploy
+make verify</pre></body></html>`
+
+	text, err := Extract(FormatHTML, []byte(page))
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	expected := "Runbook\nDeploys\nDeploys run at noon on weekdays & never on holidays.\nCheck the dashboard\nTag the release\nmake deploy\nmake verify"
+	if text != expected {
+		t.Errorf("Unexpected text:\n%s", text)
+	}
+}
+
+// buildPDF returns a PDF whose single page draws content, compressed
+func buildPDF(content string) []byte {
+	var compressed bytes.Buffer
+	w := zlib.NewWriter(&compressed)
+	w.Write([]byte(content))
+	w.Close()
+
+	var pdf bytes.Buffer
+	pdf.WriteString("%PDF-1.4\n")
+	pdf.WriteString("1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n")
+	pdf.WriteString("2 0 obj\n<< /Type /Pages /Kids [3 0 R] /Count 1 >>\nendobj\n")
+	pdf.WriteString("3 0 obj\n<< /Type /Page /Parent 2 0 R /Contents 4 0 R >>\nendobj\n")
+	fmt.Fprintf(&pdf, "4 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n", compressed.Len())
+	pdf.Write(compressed.Bytes())
+	pdf.WriteString("\nendstream\nendobj\n")
+	pdf.WriteString("5 0 obj\n<< /Type /XObject /Subtype /Image /Length 6 >>\nstream\nBT (x) Tj ET\nendstream\nendobj\n")
+	pdf.WriteString("trailer\n<< /Root 1 0 R >>\n%%EOF\n")
+	return pdf.Bytes()
+}
+
+func TestExtractPDF(t *testing.T) {
+	content := `BT /F1 18 Tf 72 720 Td (Quarterly \(Q3\) report) Tj ET
+q 1 0 0 1 0 0 cm Q
+BT /F1 12 Tf 72 690 Td [(Revenue) -300 (grew) -250 (by) -20 (ten) -300 (percent.)] TJ
+0 -14 Td <FEFF00430061006600E9> Tj T* (Costs fell\056) Tj ET`
+
+	text, err := Extract(FormatPDF, buildPDF(content))
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	expected := "Quarterly (Q3) report\nRevenue grew byten percent.\nCafé\nCosts fell."
+	if text != expected {
+		t.Errorf("Unexpected text:\n%s", text)
+	}
+
+	if _, err := Extract(FormatPDF, buildPDF("0 0 m 10 10 l S")); err == nil {
+		t.Error("Expected a PDF without text to fail")
+	}
+	if _, err := Extract(FormatPDF, []byte("not a pdf")); err == nil {
+		t.Error("Expected a non-PDF to fail")
+	}
+}
+
+func TestWalkAndLoad(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"guide.md":                 "# Guide\n\nRead me.",
+		"notes.txt":                "Plain notes.",
+		"src/main.go":              "package main\n",
+		"src/image.png":            "\x89PNG",
+		".git/config":              "[core]",
+		"node_modules/lib/lib.js":  "module.exports = 1",
+		"docs/.draft.md":           "Not yet.",
+		"docs/Makefile":            "all:\n\techo ok\n",
+		"docs/reference/page.html": "<p>Reference</p>",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var found []string
+	err := Walk(root, func(path string) error {
+		rel, _ := filepath.Rel(root, path)
+		found = append(found, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+	sort.Strings(found)
+	expected := []string{"docs/Makefile", "docs/reference/page.html", "guide.md", "notes.txt", "src/main.go"}
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("Walk found %v, expected %v", found, expected)
+	}
+
+	if err := Walk(filepath.Join(root, "src/image.png"), func(string) error { return nil }); err == nil {
+		t.Error("Expected an unsupported file to be rejected")
+	}
+
+	doc, err := Load(filepath.Join(root, "docs/reference/page.html"))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if doc.Text != "Reference" || doc.Format != FormatHTML || !filepath.IsAbs(doc.Source) || len(doc.Hash) != 64 {
+		t.Errorf("Unexpected document %+v", doc)
+	}
+}
@@ -0,0 +1,458 @@
+package ingest
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// maxPDFStreamSize bounds a decompressed PDF stream
+const maxPDFStreamSize = 64 << 20
+
+var (
+	pdfFilterPattern  = regexp.MustCompile(`/Filter\s*(\[[^\]]*\]|/[A-Za-z0-9]+)`)
+	pdfNamePattern    = regexp.MustCompile(`/([A-Za-z0-9]+)`)
+	pdfLengthPattern  = regexp.MustCompile(`/Length\s+(\d+)(\s+\d+\s+R)?`)
+	pdfSkippedPattern = regexp.MustCompile(`/Subtype\s*/Image|/Type\s*/(XRef|ObjStm|Metadata|EmbeddedFile)|/Length[123]\b`)
+)
+
+// extractPDF returns the text shown by the content streams of a PDF. Text
+// is read from its strings byte by byte, so text in fonts with their own
+// encodings, as in many CJK documents, and scanned pages yield nothing.
+func extractPDF(data []byte) (string, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte("%PDF-")) {
+		return "", fmt.Errorf("not a PDF document")
+	}
+
+	var pages []string
+	for _, stream := range pdfStreams(data) {
+		content, ok := decodePDFStream(stream.dict, stream.data)
+		if !ok {
+			continue
+		}
+		if text := pdfContentText(content); text != "" {
+			pages = append(pages, text)
+		}
+	}
+	if len(pages) == 0 {
+		return "", fmt.Errorf("no text found; the PDF may be scanned or use embedded font encodings")
+	}
+	return strings.Join(pages, "\n\n"), nil
+}
+
+// pdfStream is a stream object of a PDF
+type pdfStream struct {
+	dict []byte
+	data []byte
+}
+
+// pdfStreams finds the stream objects of a PDF with their dictionaries
+func pdfStreams(data []byte) []pdfStream {
+	var streams []pdfStream
+	keyword := []byte("stream")
+	for pos := 0; pos < len(data); {
+		i := bytes.Index(data[pos:], keyword)
+		if i < 0 {
+			break
+		}
+		start := pos + i
+		pos = start + len(keyword)
+		if start >= 3 && string(data[start-3:start]) == "end" {
+			continue
+		}
+
+		// The dictionary runs from the object header to the keyword
+		dictStart := bytes.LastIndex(data[:start], []byte("obj"))
+		if dictStart < 0 {
+			continue
+		}
+		dict := data[dictStart:start]
+
+		// The data starts after the end of the keyword's line
+		body := pos
+		if body < len(data) && data[body] == '\r' {
+			body++
+		}
+		if body < len(data) && data[body] == '\n' {
+			body++
+		}
+
+		end := -1
+		if m := pdfLengthPattern.FindSubmatch(dict); m != nil && len(m[2]) == 0 {
+			length, err := strconv.Atoi(string(m[1]))
+			if err == nil && body+length <= len(data) && bytes.HasPrefix(bytes.TrimLeft(data[body+length:], " \r\n"), []byte("endstream")) {
+				end = body + length
+			}
+		}
+		if end < 0 {
+			j := bytes.Index(data[body:], []byte("endstream"))
+			if j < 0 {
+				break
+			}
+			end = body + j
+		}
+
+		streams = append(streams, pdfStream{dict: dict, data: data[body:end]})
+		pos = end
+	}
+	return streams
+}
+
+// decodePDFStream returns the content of a stream that may hold page
+// content, undoing Flate compression
+func decodePDFStream(dict, data []byte) ([]byte, bool) {
+	if pdfSkippedPattern.Match(dict) {
+		return nil, false
+	}
+
+	m := pdfFilterPattern.FindSubmatch(dict)
+	if m == nil {
+		return data, true
+	}
+	for _, name := range pdfNamePattern.FindAllSubmatch(m[1], -1) {
+		if filter := string(name[1]); filter != "FlateDecode" && filter != "Fl" {
+			return nil, false
+		}
+	}
+
+	reader, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, false
+	}
+	defer reader.Close()
+	content, err := io.ReadAll(io.LimitReader(reader, maxPDFStreamSize))
+	// Streams are often cut short of their checksum; keep what was read
+	if err != nil && len(content) == 0 {
+		return nil, false
+	}
+	return content, true
+}
+
+// pdfArray marks the start of an array on the operand stack
+type pdfArray struct{}
+
+// pdfContentText returns the text shown by the operators of a content
+// stream, a line per line of text
+func pdfContentText(content []byte) string {
+	var b strings.Builder
+	space := func() {
+		if text := b.String(); len(text) > 0 && !strings.HasSuffix(text, " ") && !strings.HasSuffix(text, "\n") {
+			b.WriteByte(' ')
+		}
+	}
+	newline := func() {
+		if text := b.String(); len(text) > 0 && !strings.HasSuffix(text, "\n") {
+			b.WriteByte('\n')
+		}
+	}
+
+	var stack []interface{}
+	var lineY float64
+	inText := false
+
+	for i := 0; i < len(content); {
+		c := content[i]
+		switch {
+		case isPDFSpace(c):
+			i++
+
+		case c == '%':
+			for i < len(content) && content[i] != '\n' && content[i] != '\r' {
+				i++
+			}
+
+		case c == '(':
+			s, n := readPDFLiteral(content[i:])
+			stack = append(stack, s)
+			i += n
+
+		case c == '<' && i+1 < len(content) && content[i+1] == '<':
+			i += 2
+		case c == '>' && i+1 < len(content) && content[i+1] == '>':
+			i += 2
+
+		case c == '<':
+			end := bytes.IndexByte(content[i:], '>')
+			if end < 0 {
+				i = len(content)
+				break
+			}
+			stack = append(stack, decodePDFHex(content[i+1:i+end]))
+			i += end + 1
+
+		case c == '[':
+			stack = append(stack, pdfArray{})
+			i++
+
+		case c == ']':
+			start := len(stack) - 1
+			for start >= 0 {
+				if _, ok := stack[start].(pdfArray); ok {
+					break
+				}
+				start--
+			}
+			if start < 0 {
+				i++
+				break
+			}
+			array := append([]interface{}{}, stack[start+1:]...)
+			stack = append(stack[:start], array)
+			i++
+
+		case c == '/':
+			j := i + 1
+			for j < len(content) && !isPDFSpace(content[j]) && !isPDFDelimiter(content[j]) {
+				j++
+			}
+			stack = append(stack, nil)
+			i = j
+
+		case c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9'):
+			j := i + 1
+			for j < len(content) && (content[j] == '.' || (content[j] >= '0' && content[j] <= '9')) {
+				j++
+			}
+			number, _ := strconv.ParseFloat(string(content[i:j]), 64)
+			stack = append(stack, number)
+			i = j
+
+		default:
+			j := i
+			for j < len(content) && !isPDFSpace(content[j]) && !isPDFDelimiter(content[j]) {
+				j++
+			}
+			if j == i {
+				i++
+				break
+			}
+			op := string(content[i:j])
+			i = j
+
+			switch op {
+			case "BI":
+				// Skip inline image data
+				end := bytes.Index(content[i:], []byte("EI"))
+				for end >= 0 && i+end+2 < len(content) && !isPDFSpace(content[i+end+2]) {
+					next := bytes.Index(content[i+end+2:], []byte("EI"))
+					if next < 0 {
+						end = -1
+						break
+					}
+					end += 2 + next
+				}
+				if end < 0 {
+					i = len(content)
+				} else {
+					i += end + 2
+				}
+			case "BT":
+				inText = true
+			case "ET":
+				inText = false
+				newline()
+			}
+
+			if inText {
+				switch op {
+				case "Tj":
+					if s, ok := lastString(stack); ok {
+						b.WriteString(s)
+					}
+				case "'", "\"":
+					newline()
+					if s, ok := lastString(stack); ok {
+						b.WriteString(s)
+					}
+				case "TJ":
+					if len(stack) > 0 {
+						if array, ok := stack[len(stack)-1].([]interface{}); ok {
+							for _, element := range array {
+								switch element := element.(type) {
+								case string:
+									b.WriteString(element)
+								case float64:
+									// Wide gaps, in thousandths of an em, are spaces
+									if element <= -200 {
+										space()
+									}
+								}
+							}
+						}
+					}
+				case "Td", "TD":
+					if ty, ok := lastNumber(stack); ok && ty != 0 {
+						newline()
+					} else {
+						space()
+					}
+				case "T*":
+					newline()
+				case "Tm":
+					if y, ok := lastNumber(stack); ok {
+						if y != lineY {
+							newline()
+						} else {
+							space()
+						}
+						lineY = y
+					}
+				}
+			}
+			stack = stack[:0]
+		}
+	}
+
+	var lines []string
+	for _, line := range strings.Split(b.String(), "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// lastString returns the last operand as a string
+func lastString(stack []interface{}) (string, bool) {
+	if len(stack) == 0 {
+		return "", false
+	}
+	s, ok := stack[len(stack)-1].(string)
+	return s, ok
+}
+
+// lastNumber returns the last operand as a number
+func lastNumber(stack []interface{}) (float64, bool) {
+	if len(stack) == 0 {
+		return 0, false
+	}
+	n, ok := stack[len(stack)-1].(float64)
+	return n, ok
+}
+
+// readPDFLiteral decodes the literal string at the start of data, returning
+// it and its encoded length
+func readPDFLiteral(data []byte) (string, int) {
+	var raw []byte
+	depth := 0
+	i := 0
+	for i < len(data) {
+		c := data[i]
+		switch {
+		case c == '(':
+			if depth > 0 {
+				raw = append(raw, c)
+			}
+			depth++
+			i++
+		case c == ')':
+			depth--
+			i++
+			if depth == 0 {
+				return decodePDFText(raw), i
+			}
+			raw = append(raw, c)
+		case c == '\\' && i+1 < len(data):
+			i++
+			e := data[i]
+			switch e {
+			case 'n':
+				raw = append(raw, '\n')
+			case 'r':
+				raw = append(raw, '\r')
+			case 't':
+				raw = append(raw, '\t')
+			case 'b', 'f':
+			case '\r':
+				if i+1 < len(data) && data[i+1] == '\n' {
+					i++
+				}
+			case '\n':
+			default:
+				if e >= '0' && e <= '7' {
+					j := i
+					for j < len(data) && j < i+3 && data[j] >= '0' && data[j] <= '7' {
+						j++
+					}
+					value, _ := strconv.ParseUint(string(data[i:j]), 8, 8)
+					raw = append(raw, byte(value))
+					i = j
+					continue
+				}
+				raw = append(raw, e)
+			}
+			i++
+		default:
+			raw = append(raw, c)
+			i++
+		}
+	}
+	return decodePDFText(raw), len(data)
+}
+
+// decodePDFHex decodes a hexadecimal string
+func decodePDFHex(data []byte) string {
+	var digits []byte
+	for _, c := range data {
+		if (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F') {
+			digits = append(digits, c)
+		}
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	raw := make([]byte, len(digits)/2)
+	for i := range raw {
+		value, _ := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		raw[i] = byte(value)
+	}
+	return decodePDFText(raw)
+}
+
+// decodePDFText decodes the bytes of a string as UTF-16 when they start
+// with its byte order mark, and otherwise as Latin-1, dropping control
+// characters
+func decodePDFText(raw []byte) string {
+	if len(raw) >= 2 && raw[0] == 0xFE && raw[1] == 0xFF {
+		units := make([]uint16, 0, len(raw)/2)
+		for i := 2; i+1 < len(raw); i += 2 {
+			units = append(units, uint16(raw[i])<<8|uint16(raw[i+1]))
+		}
+		return string(utf16.Decode(units))
+	}
+
+	var b strings.Builder
+	for _, c := range raw {
+		switch {
+		case c == '\n' || c == '\r' || c == '\t':
+			b.WriteByte(' ')
+		case c < 0x20 || c == 0x7F:
+		default:
+			b.WriteRune(rune(c))
+		}
+	}
+	return b.String()
+}
+
+// isPDFSpace reports whether c is PDF white space
+func isPDFSpace(c byte) bool {
+	switch c {
+	case ' ', '\t', '\r', '\n', '\f', 0:
+		return true
+	}
+	return false
+}
+
+// isPDFDelimiter reports whether c delimits PDF tokens
+func isPDFDelimiter(c byte) bool {
+	switch c {
+	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
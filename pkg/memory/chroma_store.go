@@ -8,8 +8,10 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/satishgonella2024/sentinelstacks/pkg/embedding"
 	"github.com/satishgonella2024/sentinelstacks/pkg/types"
 )
 
@@ -26,12 +28,21 @@ type ChromaConfig struct {
 
 	// APIKey is the API key for the Chroma server
 	APIKey string
+
+	// Embedder embeds texts. It defaults to a local embedder of Dimensions
+	// dimensions.
+	Embedder types.Embedder
 }
 
+// chromaOverfetch is how many results per match are requested when part
+// of a filter is applied by the client
+const chromaOverfetch = 4
+
 // ChromaStore is a vector store implementation using the Chroma DB API
 type ChromaStore struct {
 	config ChromaConfig
 	client *http.Client
+	mu     sync.Mutex
 }
 
 // NewChromaStore creates a new Chroma vector store
@@ -42,8 +53,13 @@ func NewChromaStore(config ChromaConfig) (*ChromaStore, error) {
 	if config.CollectionName == "" {
 		return nil, fmt.Errorf("collection name is required")
 	}
-	if config.Dimensions <= 0 {
-		config.Dimensions = 1536 // Default for OpenAI embeddings
+	if config.Embedder != nil {
+		config.Dimensions = config.Embedder.Dimensions()
+	} else {
+		if config.Dimensions <= 0 {
+			config.Dimensions = 1536 // Default for OpenAI embeddings
+		}
+		config.Embedder = embedding.NewHashEmbedder(config.Dimensions)
 	}
 
 	// Create HTTP client
@@ -151,15 +167,30 @@ func (s *ChromaStore) setHeaders(req *http.Request) {
 	}
 }
 
+// embed returns the embedding of text, rejecting one whose dimensionality
+// differs from the collection's
+func (s *ChromaStore) embed(ctx context.Context, text string) ([]float32, error) {
+	vectors, err := s.config.Embedder.Embed(ctx, []string{text})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed text: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.config.Dimensions == 0 {
+		s.config.Dimensions = len(vectors[0])
+	}
+	if err := checkDimensions(vectors[0], s.config.Dimensions); err != nil {
+		return nil, err
+	}
+	return vectors[0], nil
+}
+
 // StoreVector stores a text with its vector embedding
 func (s *ChromaStore) StoreVector(ctx context.Context, id string, text string, metadata map[string]interface{}) error {
-	// In a real implementation, we would generate embeddings here
-	// For now, we'll just create a mock vector
-	vector := make([]float32, s.config.Dimensions)
-	for i := 0; i < s.config.Dimensions && i < len(text); i++ {
-		if i < len(text) {
-			vector[i] = float32(text[i]) / 255.0
-		}
+	vector, err := s.embed(ctx, text)
+	if err != nil {
+		return err
 	}
 
 	// Prepare the request
@@ -202,31 +233,39 @@ func (s *ChromaStore) StoreVector(ctx context.Context, id string, text string, m
 	return nil
 }
 
-// SearchVector finds similar vectors using vector similarity
+// SearchVector finds similar vectors using vector similarity, among those
+// whose metadata passes filter (see ParseFilter). Conditions Chroma cannot
+// evaluate are applied to extra results fetched for them.
 func (s *ChromaStore) SearchVector(ctx context.Context, text string, limit int, filter map[string]interface{}) ([]types.MemoryMatch, error) {
 	if limit <= 0 {
 		limit = 10
 	}
 
-	// In a real implementation, we would generate embeddings here
-	// For now, we'll just create a mock vector
-	queryVector := make([]float32, s.config.Dimensions)
-	for i := 0; i < s.config.Dimensions && i < len(text); i++ {
-		if i < len(text) {
-			queryVector[i] = float32(text[i]) / 255.0
-		}
+	f, err := ParseFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	where, rest := f.chromaWhere()
+	nResults := limit
+	if !rest.Empty() {
+		nResults = limit * chromaOverfetch
+	}
+
+	queryVector, err := s.embed(ctx, text)
+	if err != nil {
+		return nil, err
 	}
 
 	// Prepare the request
 	reqBody := struct {
 		QueryEmbeddings [][]float32            `json:"query_embeddings"`
 		NResults        int                    `json:"n_results"`
-		Where           map[string]interface{} `json:"where"`
+		Where           map[string]interface{} `json:"where,omitempty"`
 		Include         []string               `json:"include"`
 	}{
 		QueryEmbeddings: [][]float32{queryVector},
-		NResults:        limit,
-		Where:           filter,
+		NResults:        nResults,
+		Where:           where,
 		Include:         []string{"documents", "metadatas", "distances"},
 	}
 
@@ -260,33 +299,48 @@ func (s *ChromaStore) SearchVector(ctx context.Context, text string, limit int,
 		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
+	// Results are given per query embedding
 	var result struct {
-		IDs       []string                 `json:"ids"`
-		Documents []string                 `json:"documents"`
-		Metadatas []map[string]interface{} `json:"metadatas"`
-		Distances []float64                `json:"distances"`
+		IDs       [][]string                 `json:"ids"`
+		Documents [][]string                 `json:"documents"`
+		Metadatas [][]map[string]interface{} `json:"metadatas"`
+		Distances [][]float64                `json:"distances"`
 	}
 	if err := json.Unmarshal(body, &result); err != nil {
 		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
+	if len(result.IDs) == 0 {
+		return nil, nil
+	}
+	if len(result.Documents) == 0 || len(result.Metadatas) == 0 || len(result.Distances) == 0 ||
+		len(result.Documents[0]) != len(result.IDs[0]) || len(result.Metadatas[0]) != len(result.IDs[0]) || len(result.Distances[0]) != len(result.IDs[0]) {
+		return nil, fmt.Errorf("failed to parse response: incomplete results")
+	}
 
 	// Convert to MemoryMatch objects
-	matches := make([]types.MemoryMatch, 0, len(result.IDs))
-	for i := 0; i < len(result.IDs); i++ {
+	matches := make([]types.MemoryMatch, 0, len(result.IDs[0]))
+	for i, id := range result.IDs[0] {
+		if !rest.Match(result.Metadatas[0][i]) {
+			continue
+		}
+
 		// Convert similarity to score (higher is better)
 		// Chroma distances are typically L2 distances, so we need to convert
 		// Lower distance means more similar, so we invert it
-		distance := result.Distances[i]
+		distance := result.Distances[0][i]
 		score := 1.0 / (1.0 + distance)
 
 		matches = append(matches, types.MemoryMatch{
-			Key:       result.IDs[i],
-			Content:   result.Documents[i],
-			Metadata:  result.Metadatas[i],
+			Key:       id,
+			Content:   result.Documents[0][i],
+			Metadata:  result.Metadatas[0][i],
 			Score:     score,
 			Distance:  distance,
 			Timestamp: time.Now(), // We don't get timestamp from Chroma
 		})
+		if len(matches) == limit {
+			break
+		}
 	}
 
 	return matches, nil
@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"sync"
 
+	"github.com/satishgonella2024/sentinelstacks/pkg/embedding"
 	"github.com/satishgonella2024/sentinelstacks/pkg/types"
 )
 
@@ -15,6 +16,7 @@ type Factory struct {
 	config       FactoryConfig
 	memoryStores map[string]types.MemoryStore
 	vectorStores map[string]types.VectorStore
+	embedder     types.Embedder
 	mu           sync.RWMutex
 }
 
@@ -26,8 +28,15 @@ type FactoryConfig struct {
 	// DefaultVectorDimensions is the default dimensionality for vector stores
 	DefaultVectorDimensions int
 
+	// Embeddings configures the embedder shared by the vector stores
+	Embeddings types.EmbeddingConfig
+
 	// PreferredStoreType indicates the preferred memory store implementation
 	PreferredStoreType string // "sqlite", "chroma", "local"
+
+	// MetadataFields are metadata fields indexed in SQLite vector stores for
+	// filtered searches
+	MetadataFields []string
 }
 
 // NewFactory creates a new memory store factory
@@ -85,25 +94,31 @@ func (f *Factory) CreateVectorStore(ctx context.Context, name string) (types.Vec
 	}
 
 	var newStore types.VectorStore
-	var err error
 
-	dimensions := f.config.DefaultVectorDimensions
-	if dimensions <= 0 {
-		dimensions = 1536 // Default for OpenAI embeddings
+	embedder, err := f.Embedder()
+	if err != nil {
+		return nil, err
 	}
 
 	switch f.config.PreferredStoreType {
 	case "chroma":
 		chromaConfig := ChromaConfig{
 			CollectionName: name,
-			Dimensions:     dimensions,
+			Embedder:       embedder,
 		}
 		newStore, err = NewChromaStore(chromaConfig)
 	case "sqlite":
 		storePath := filepath.Join(f.config.BasePath, "vectors", fmt.Sprintf("%s.db", name))
-		newStore, err = NewSQLiteVectorStore(storePath, dimensions)
+		var sqliteStore *SQLiteVectorStore
+		sqliteStore, err = NewSQLiteVectorStore(storePath, embedder)
+		if err == nil {
+			newStore = sqliteStore
+			if err = sqliteStore.IndexMetadataFields(f.config.MetadataFields); err != nil {
+				sqliteStore.Close()
+			}
+		}
 	case "local", "":
-		newStore = NewLocalVectorStore(dimensions)
+		newStore = NewLocalVectorStore(embedder)
 		err = nil
 	default:
 		return nil, fmt.Errorf("unsupported vector store type: %s", f.config.PreferredStoreType)
@@ -119,3 +134,26 @@ func (f *Factory) CreateVectorStore(ctx context.Context, name string) (types.Vec
 
 	return newStore, nil
 }
+
+// Embedder returns the embedder shared by the vector stores, creating it
+// from the Embeddings configuration on first use
+func (f *Factory) Embedder() (types.Embedder, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.embedder != nil {
+		return f.embedder, nil
+	}
+
+	config := f.config.Embeddings
+	if config.Dimensions <= 0 {
+		config.Dimensions = f.config.DefaultVectorDimensions
+	}
+	embedder, err := embedding.New(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embedder: %w", err)
+	}
+
+	f.embedder = embedder
+	return embedder, nil
+}
@@ -0,0 +1,383 @@
+package memory
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Filter operators. A filter maps metadata fields to a value, which the
+// field must equal, or to operators:
+//
+//	{"project": "apollo"}
+//	{"project": {"$in": ["apollo", "gemini"]}}
+//	{"created": {"$gte": "2025-01-01T00:00:00Z", "$lt": "2025-02-01T00:00:00Z"}}
+//	{"reviewed": {"$exists": true}}
+//
+// Values are strings, numbers, booleans or null; times compare as their
+// RFC 3339 form. A range matches numbers against numbers and strings
+// against strings. All conditions must hold.
+const (
+	FilterEq     = "$eq"
+	FilterIn     = "$in"
+	FilterGt     = "$gt"
+	FilterGte    = "$gte"
+	FilterLt     = "$lt"
+	FilterLte    = "$lte"
+	FilterExists = "$exists"
+)
+
+// Filter is a parsed metadata filter
+type Filter struct {
+	conditions []condition
+}
+
+// condition is an operator applied to a metadata field
+type condition struct {
+	field string
+	op    string
+	value interface{}
+	// values are the operands of $in
+	values []interface{}
+}
+
+// ParseFilter parses a metadata filter. A nil or empty filter matches
+// everything.
+func ParseFilter(filter map[string]interface{}) (*Filter, error) {
+	f := &Filter{}
+	for field, spec := range filter {
+		if field == "" || strings.ContainsAny(field, `"\`) {
+			return nil, fmt.Errorf("invalid filter field %q", field)
+		}
+
+		operators, ok := spec.(map[string]interface{})
+		if !ok {
+			operators = map[string]interface{}{FilterEq: spec}
+		}
+		if len(operators) == 0 {
+			return nil, fmt.Errorf("filter on %s has no operators", field)
+		}
+
+		for op, operand := range operators {
+			c, err := parseCondition(field, op, operand)
+			if err != nil {
+				return nil, err
+			}
+			f.conditions = append(f.conditions, c)
+		}
+	}
+
+	// Keep the order stable, for the SQL generated
+	sort.Slice(f.conditions, func(i, j int) bool {
+		a, b := f.conditions[i], f.conditions[j]
+		if a.field != b.field {
+			return a.field < b.field
+		}
+		return a.op < b.op
+	})
+	return f, nil
+}
+
+// parseCondition parses an operator and its operand
+func parseCondition(field, op string, operand interface{}) (condition, error) {
+	c := condition{field: field, op: op}
+
+	switch op {
+	case FilterEq:
+		value, err := normalizeValue(operand)
+		if err != nil {
+			return c, fmt.Errorf("filter on %s: %w", field, err)
+		}
+		c.value = value
+
+	case FilterIn:
+		list := reflect.ValueOf(operand)
+		if operand == nil || (list.Kind() != reflect.Slice && list.Kind() != reflect.Array) {
+			return c, fmt.Errorf("filter on %s: %s takes a list", field, op)
+		}
+		for i := 0; i < list.Len(); i++ {
+			value, err := normalizeValue(list.Index(i).Interface())
+			if err != nil {
+				return c, fmt.Errorf("filter on %s: %w", field, err)
+			}
+			c.values = append(c.values, value)
+		}
+
+	case FilterGt, FilterGte, FilterLt, FilterLte:
+		value, err := normalizeValue(operand)
+		if err != nil {
+			return c, fmt.Errorf("filter on %s: %w", field, err)
+		}
+		switch value.(type) {
+		case float64, string:
+		default:
+			return c, fmt.Errorf("filter on %s: %s takes a number or string", field, op)
+		}
+		c.value = value
+
+	case FilterExists:
+		exists, ok := operand.(bool)
+		if !ok {
+			return c, fmt.Errorf("filter on %s: %s takes a boolean", field, op)
+		}
+		c.value = exists
+
+	default:
+		return c, fmt.Errorf("filter on %s: unknown operator %s", field, op)
+	}
+
+	return c, nil
+}
+
+// Empty reports whether the filter matches everything
+func (f *Filter) Empty() bool {
+	return f == nil || len(f.conditions) == 0
+}
+
+// Match reports whether metadata satisfies the filter
+func (f *Filter) Match(metadata map[string]interface{}) bool {
+	if f == nil {
+		return true
+	}
+	for _, c := range f.conditions {
+		if !c.match(metadata) {
+			return false
+		}
+	}
+	return true
+}
+
+// match reports whether metadata satisfies the condition
+func (c condition) match(metadata map[string]interface{}) bool {
+	raw, exists := metadata[c.field]
+	if c.op == FilterExists {
+		return exists == c.value.(bool)
+	}
+	if !exists {
+		return false
+	}
+	value, err := normalizeValue(raw)
+	if err != nil {
+		return false
+	}
+
+	switch c.op {
+	case FilterEq:
+		return value == c.value
+	case FilterIn:
+		for _, candidate := range c.values {
+			if value == candidate {
+				return true
+			}
+		}
+		return false
+	}
+
+	order, ok := compareValues(value, c.value)
+	if !ok {
+		return false
+	}
+	switch c.op {
+	case FilterGt:
+		return order > 0
+	case FilterGte:
+		return order >= 0
+	case FilterLt:
+		return order < 0
+	default:
+		return order <= 0
+	}
+}
+
+// SQL returns a SQLite condition applying the filter to a column of JSON
+// metadata, and its arguments. An empty filter gives "1".
+func (f *Filter) SQL(column string) (string, []interface{}) {
+	if f.Empty() {
+		return "1", nil
+	}
+
+	var clauses []string
+	var args []interface{}
+	for _, c := range f.conditions {
+		clause, clauseArgs := c.sql(column)
+		clauses = append(clauses, clause)
+		args = append(args, clauseArgs...)
+	}
+	return strings.Join(clauses, " AND "), args
+}
+
+// sql returns the SQLite form of the condition. Each comparison checks the
+// JSON type first, since SQLite would otherwise order numbers before text.
+func (c condition) sql(column string) (string, []interface{}) {
+	path := metadataPath(column, c.field)
+
+	switch c.op {
+	case FilterExists:
+		if c.value.(bool) {
+			return fmt.Sprintf("json_type(%s) IS NOT NULL", path), nil
+		}
+		return fmt.Sprintf("json_type(%s) IS NULL", path), nil
+
+	case FilterEq:
+		return compareSQL(path, "=", c.value)
+
+	case FilterIn:
+		if len(c.values) == 0 {
+			return "0", nil
+		}
+		var clauses []string
+		var args []interface{}
+		for _, value := range c.values {
+			clause, clauseArgs := compareSQL(path, "=", value)
+			clauses = append(clauses, clause)
+			args = append(args, clauseArgs...)
+		}
+		return "(" + strings.Join(clauses, " OR ") + ")", args
+	}
+
+	operators := map[string]string{FilterGt: ">", FilterGte: ">=", FilterLt: "<", FilterLte: "<="}
+	return compareSQL(path, operators[c.op], c.value)
+}
+
+// compareSQL compares the metadata value at path with value
+func compareSQL(path, operator string, value interface{}) (string, []interface{}) {
+	switch value := value.(type) {
+	case nil:
+		return fmt.Sprintf("json_type(%s) = 'null'", path), nil
+	case bool:
+		return fmt.Sprintf("json_type(%s) = '%t'", path, value), nil
+	case float64:
+		return fmt.Sprintf("(json_type(%s) IN ('integer', 'real') AND json_extract(%s) %s ?)", path, path, operator), []interface{}{value}
+	default:
+		return fmt.Sprintf("(json_type(%s) = 'text' AND json_extract(%s) %s ?)", path, path, operator), []interface{}{value}
+	}
+}
+
+// metadataPath returns the arguments of json_extract for a field of a
+// metadata column, as used by the metadata field indexes
+func metadataPath(column, field string) string {
+	return fmt.Sprintf(`%s, '$."%s"'`, column, strings.ReplaceAll(field, "'", "''"))
+}
+
+// normalizeValue converts a metadata or filter value to the form it takes
+// in JSON, so that values stored in memory and in SQLite compare alike
+func normalizeValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case nil, bool, string:
+		return v, nil
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case int:
+		return float64(v), nil
+	case int8:
+		return float64(v), nil
+	case int16:
+		return float64(v), nil
+	case int32:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case uint:
+		return float64(v), nil
+	case uint8:
+		return float64(v), nil
+	case uint16:
+		return float64(v), nil
+	case uint32:
+		return float64(v), nil
+	case uint64:
+		return float64(v), nil
+	case json.Number:
+		return v.Float64()
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	}
+	return nil, fmt.Errorf("unsupported filter value of type %T", value)
+}
+
+// compareValues orders two normalized values of the same type
+func compareValues(a, b interface{}) (int, bool) {
+	switch a := a.(type) {
+	case float64:
+		b, ok := b.(float64)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case a < b:
+			return -1, true
+		case a > b:
+			return 1, true
+		}
+		return 0, true
+	case string:
+		b, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(a, b), true
+	}
+	return 0, false
+}
+
+// chromaWhere splits the filter into a Chroma where clause and the
+// conditions Chroma cannot evaluate, which are left to the client: $exists,
+// null values and ranges over strings
+func (f *Filter) chromaWhere() (map[string]interface{}, *Filter) {
+	rest := &Filter{}
+	if f.Empty() {
+		return nil, rest
+	}
+
+	var clauses []map[string]interface{}
+	for _, c := range f.conditions {
+		if !c.chromaSupported() {
+			rest.conditions = append(rest.conditions, c)
+			continue
+		}
+		operand := c.value
+		if c.op == FilterIn {
+			operand = c.values
+		}
+		clauses = append(clauses, map[string]interface{}{c.field: map[string]interface{}{c.op: operand}})
+	}
+
+	switch len(clauses) {
+	case 0:
+		return nil, rest
+	case 1:
+		return clauses[0], rest
+	}
+	and := make([]interface{}, len(clauses))
+	for i, clause := range clauses {
+		and[i] = clause
+	}
+	return map[string]interface{}{"$and": and}, rest
+}
+
+// chromaSupported reports whether Chroma can evaluate the condition
+func (c condition) chromaSupported() bool {
+	switch c.op {
+	case FilterEq:
+		return c.value != nil
+	case FilterIn:
+		if len(c.values) == 0 {
+			return false
+		}
+		for _, value := range c.values {
+			if value == nil {
+				return false
+			}
+		}
+		return true
+	case FilterExists:
+		return false
+	}
+	_, numeric := c.value.(float64)
+	return numeric
+}
@@ -0,0 +1,243 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/satishgonella2024/sentinelstacks/pkg/embedding"
+	"github.com/satishgonella2024/sentinelstacks/pkg/types"
+)
+
+// filterCases are filters and the keys of filterDocuments they pass
+var filterCases = []struct {
+	filter map[string]interface{}
+	keys   []string
+}{
+	{map[string]interface{}{"project": "apollo"}, []string{"a1", "a2"}},
+	{map[string]interface{}{"project": map[string]interface{}{"$in": []string{"gemini", "mercury"}}}, []string{"g1", "m1"}},
+	{map[string]interface{}{"priority": map[string]interface{}{"$gte": 2, "$lt": 5}}, []string{"a2", "g1"}},
+	{map[string]interface{}{"created": map[string]interface{}{"$gte": "2025-02-01", "$lt": "2025-03-01"}}, []string{"g1"}},
+	{map[string]interface{}{"reviewed": map[string]interface{}{"$exists": true}}, []string{"a1", "m1"}},
+	{map[string]interface{}{"reviewed": false, "project": "mercury"}, []string{"m1"}},
+	{map[string]interface{}{"priority": "1"}, nil},
+}
+
+// filterDocuments are texts and metadata searched with filterCases
+var filterDocuments = map[string]map[string]interface{}{
+	"a1": {"project": "apollo", "priority": 1, "created": "2025-01-10", "reviewed": true},
+	"a2": {"project": "apollo", "priority": 3, "created": "2025-01-20"},
+	"g1": {"project": "gemini", "priority": 4.5, "created": "2025-02-14"},
+	"m1": {"project": "mercury", "priority": 9, "created": "2025-03-01", "reviewed": false},
+}
+
+func TestParseFilter(t *testing.T) {
+	for _, filter := range []map[string]interface{}{
+		{"project": map[string]interface{}{"$like": "a%"}},
+		{"project": map[string]interface{}{"$in": "apollo"}},
+		{"priority": map[string]interface{}{"$gt": true}},
+		{"reviewed": map[string]interface{}{"$exists": "yes"}},
+		{`bad"field`: 1},
+	} {
+		if _, err := ParseFilter(filter); err == nil {
+			t.Errorf("Expected %v to be rejected", filter)
+		}
+	}
+
+	f, err := ParseFilter(map[string]interface{}{"created": map[string]interface{}{"$lt": time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)}})
+	if err != nil {
+		t.Fatalf("ParseFilter failed: %v", err)
+	}
+	if !f.Match(map[string]interface{}{"created": "2025-01-31T12:00:00Z"}) || f.Match(map[string]interface{}{"created": 20250101}) {
+		t.Error("Expected times to compare as RFC 3339 strings")
+	}
+}
+
+func TestVectorStoreFilters(t *testing.T) {
+	ctx := context.Background()
+	embedder := embedding.NewHashEmbedder(32)
+
+	sqliteStore, err := NewSQLiteVectorStore(filepath.Join(t.TempDir(), "vectors.db"), embedder)
+	if err != nil {
+		t.Fatalf("NewSQLiteVectorStore failed: %v", err)
+	}
+	defer sqliteStore.Close()
+	if err := sqliteStore.IndexMetadataFields([]string{"project", "created"}); err != nil {
+		t.Fatalf("IndexMetadataFields failed: %v", err)
+	}
+
+	for name, store := range map[string]types.VectorStore{
+		"local":  NewLocalVectorStore(embedder),
+		"sqlite": sqliteStore,
+	} {
+		for key, metadata := range filterDocuments {
+			if err := store.StoreVector(ctx, key, "status report for "+key, metadata); err != nil {
+				t.Fatalf("%s: StoreVector failed: %v", name, err)
+			}
+		}
+
+		for _, c := range filterCases {
+			matches, err := store.SearchVector(ctx, "status report", 10, c.filter)
+			if err != nil {
+				t.Errorf("%s: SearchVector(%v) failed: %v", name, c.filter, err)
+				continue
+			}
+			var keys []string
+			for _, match := range matches {
+				keys = append(keys, match.Key)
+			}
+			sort.Strings(keys)
+			if !reflect.DeepEqual(keys, c.keys) {
+				t.Errorf("%s: filter %v matched %v, expected %v", name, c.filter, keys, c.keys)
+			}
+		}
+	}
+}
+
+func TestIndexMetadataFieldsCollidingNames(t *testing.T) {
+	store, err := NewSQLiteVectorStore(filepath.Join(t.TempDir(), "vectors.db"), embedding.NewHashEmbedder(32))
+	if err != nil {
+		t.Fatalf("NewSQLiteVectorStore failed: %v", err)
+	}
+	defer store.Close()
+
+	// a.b and a_b sanitize to the same name but need their own index
+	if err := store.IndexMetadataFields([]string{"a.b", "a_b"}); err != nil {
+		t.Fatalf("IndexMetadataFields failed: %v", err)
+	}
+	var count int
+	err = store.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name LIKE 'vectors_metadata_a_b%';`).Scan(&count)
+	if err != nil {
+		t.Fatalf("Failed to count indexes: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 indexes, got %d", count)
+	}
+}
+
+func TestChromaStoreFilter(t *testing.T) {
+	var where map[string]interface{}
+	var nResults int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/collections":
+			json.NewEncoder(w).Encode(map[string]interface{}{"collections": []map[string]string{{"name": "notes"}}})
+		case "/api/v1/collections/notes/query":
+			var req struct {
+				NResults int                    `json:"n_results"`
+				Where    map[string]interface{} `json:"where"`
+			}
+			json.NewDecoder(r.Body).Decode(&req)
+			where, nResults = req.Where, req.NResults
+
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"ids":       [][]string{{"a1", "a2"}},
+				"documents": [][]string{{"first", "second"}},
+				"metadatas": [][]map[string]interface{}{{{"project": "apollo"}, {"project": "apollo", "reviewed": true}}},
+				"distances": [][]float64{{0.1, 0.2}},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	store, err := NewChromaStore(ChromaConfig{Server: server.URL, CollectionName: "notes", Dimensions: 8})
+	if err != nil {
+		t.Fatalf("NewChromaStore failed: %v", err)
+	}
+
+	matches, err := store.SearchVector(context.Background(), "notes", 1, map[string]interface{}{
+		"project":  map[string]interface{}{"$in": []string{"apollo", "gemini"}},
+		"priority": map[string]interface{}{"$gte": 2},
+		"reviewed": map[string]interface{}{"$exists": true},
+	})
+	if err != nil {
+		t.Fatalf("SearchVector failed: %v", err)
+	}
+
+	// $exists is applied to extra results, the rest by Chroma
+	expected := map[string]interface{}{"$and": []interface{}{
+		map[string]interface{}{"priority": map[string]interface{}{"$gte": 2.0}},
+		map[string]interface{}{"project": map[string]interface{}{"$in": []interface{}{"apollo", "gemini"}}},
+	}}
+	if !reflect.DeepEqual(where, expected) {
+		t.Errorf("Unexpected where clause %v", where)
+	}
+	if nResults != chromaOverfetch {
+		t.Errorf("Expected %d results to be requested, got %d", chromaOverfetch, nResults)
+	}
+	if len(matches) != 1 || matches[0].Key != "a2" {
+		t.Errorf("Expected only the reviewed note, got %+v", matches)
+	}
+}
+
+func TestSQLiteVectorStoreHybridSearch(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewSQLiteVectorStore(filepath.Join(t.TempDir(), "vectors.db"), embedding.NewHashEmbedder(32))
+	if err != nil {
+		t.Fatalf("NewSQLiteVectorStore failed: %v", err)
+	}
+	defer store.Close()
+	if !store.keywords {
+		t.Skip("SQLite is built without FTS5; run with -tags sqlite_fts5")
+	}
+
+	for i := 0; i < 20; i++ {
+		text := fmt.Sprintf("incident report: the cache was restarted after a timeout on host %d", i)
+		project := "apollo"
+		if i == 7 {
+			text = "incident report: INC-4821 was resolved after the cache was restarted"
+		}
+		if i%2 == 1 {
+			project = "gemini"
+		}
+		if err := store.StoreVector(ctx, fmt.Sprint(i), text, map[string]interface{}{"project": project}); err != nil {
+			t.Fatalf("StoreVector failed: %v", err)
+		}
+	}
+	if err := store.DeleteVector(ctx, "3"); err != nil {
+		t.Fatalf("DeleteVector failed: %v", err)
+	}
+
+	matches, err := store.SearchHybrid(ctx, "what happened with INC-4821", 3, nil, 0.5)
+	if err != nil {
+		t.Fatalf("SearchHybrid failed: %v", err)
+	}
+	if len(matches) != 3 || matches[0].Key != "7" {
+		t.Errorf("Expected the exact identifier first, got %+v", matches)
+	}
+
+	matches, err = store.SearchHybrid(ctx, "what happened with INC-4821", 5, map[string]interface{}{"project": "apollo"}, 0.5)
+	if err != nil {
+		t.Fatalf("SearchHybrid failed: %v", err)
+	}
+	for _, match := range matches {
+		if match.Key == "7" || match.Metadata["project"] != "apollo" {
+			t.Errorf("Expected only apollo reports, got %s", match.Key)
+		}
+	}
+
+	// The keyword index follows deletes and is rebuilt when out of step
+	keywords, err := store.keywordSearch(ctx, "host", 50, nil)
+	if err != nil || len(keywords) != 18 {
+		t.Errorf("Expected 18 keyword matches, got %d, %v", len(keywords), err)
+	}
+	if _, err := store.db.Exec(`DELETE FROM vectors_fts WHERE rowid IN (SELECT rowid FROM vectors_fts LIMIT 5);`); err != nil {
+		t.Fatalf("Failed to damage the keyword index: %v", err)
+	}
+	if _, err := openKeywordIndex(store.db); err != nil {
+		t.Fatalf("openKeywordIndex failed: %v", err)
+	}
+	keywords, err = store.keywordSearch(ctx, "host", 50, nil)
+	if err != nil || len(keywords) != 18 {
+		t.Errorf("Expected 18 keyword matches after a rebuild, got %d, %v", len(keywords), err)
+	}
+}
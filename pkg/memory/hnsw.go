@@ -0,0 +1,518 @@
+package memory
+
+import (
+	"container/heap"
+	"encoding/gob"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// hnswFormatVersion is the version of the index file format
+const hnswFormatVersion = 1
+
+// HNSWConfig tunes a hierarchical navigable small world index
+type HNSWConfig struct {
+	// M is the number of neighbours kept per node on the upper layers, and
+	// half the number kept on the bottom layer
+	M int
+
+	// EfConstruction is the number of candidates considered when linking a
+	// new node
+	EfConstruction int
+
+	// EfSearch is the number of candidates considered by a search. Higher
+	// values trade latency for recall.
+	EfSearch int
+}
+
+// DefaultHNSWConfig returns the configuration used by the vector stores
+func DefaultHNSWConfig() HNSWConfig {
+	return HNSWConfig{
+		M:              16,
+		EfConstruction: 200,
+		EfSearch:       64,
+	}
+}
+
+// hnswNode is a vector in the index and its links on each layer
+type hnswNode struct {
+	Key       string
+	Vector    []float32
+	Neighbors [][]int32
+	Deleted   bool
+}
+
+// HNSWIndex is an approximate nearest neighbour index over normalized
+// vectors, ranked by cosine similarity. Deleted vectors stay in the graph
+// to keep it connected until the index is rebuilt.
+type HNSWIndex struct {
+	mu         sync.RWMutex
+	config     HNSWConfig
+	dimensions int
+	nodes      []*hnswNode
+	keys       map[string]int32
+	entry      int32
+	maxLevel   int
+	deleted    int
+	levelMult  float64
+	rng        *rand.Rand
+}
+
+// IndexHit is a vector found by a search
+type IndexHit struct {
+	Key        string
+	Similarity float32
+}
+
+// NewHNSWIndex creates an empty index. A dimensionality of 0 is set by the
+// first vector added.
+func NewHNSWIndex(dimensions int, config HNSWConfig) *HNSWIndex {
+	defaults := DefaultHNSWConfig()
+	if config.M <= 1 {
+		config.M = defaults.M
+	}
+	if config.EfConstruction <= 0 {
+		config.EfConstruction = defaults.EfConstruction
+	}
+	if config.EfSearch <= 0 {
+		config.EfSearch = defaults.EfSearch
+	}
+
+	return &HNSWIndex{
+		config:     config,
+		dimensions: dimensions,
+		keys:       make(map[string]int32),
+		entry:      -1,
+		levelMult:  1 / math.Log(float64(config.M)),
+		rng:        rand.New(rand.NewSource(1)),
+	}
+}
+
+// Len returns the number of vectors in the index
+func (idx *HNSWIndex) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.keys)
+}
+
+// Dimensions returns the dimensionality of the indexed vectors
+func (idx *HNSWIndex) Dimensions() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.dimensions
+}
+
+// Add inserts the vector for key, replacing any previous one
+func (idx *HNSWIndex) Add(key string, vector []float32) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if idx.dimensions == 0 {
+		idx.dimensions = len(vector)
+	}
+	if len(vector) != idx.dimensions {
+		return fmt.Errorf("vector has %d dimensions, but the index holds %d-dimensional vectors", len(vector), idx.dimensions)
+	}
+
+	idx.remove(key)
+	idx.insert(key, normalize(vector))
+	return nil
+}
+
+// Delete removes the vector for key
+func (idx *HNSWIndex) Delete(key string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.remove(key)
+}
+
+// Search returns up to k vectors most similar to query, most similar first
+func (idx *HNSWIndex) Search(query []float32, k int) []IndexHit {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	if idx.entry < 0 || k <= 0 || len(query) != idx.dimensions {
+		return nil
+	}
+	q := normalize(query)
+
+	ep := idx.entry
+	for level := idx.maxLevel; level > 0; level-- {
+		ep = idx.greedyClosest(q, ep, level)
+	}
+
+	ef := idx.config.EfSearch
+	if ef < k {
+		ef = k
+	}
+	// Deleted nodes take up candidate slots
+	ef += idx.deleted * ef / (len(idx.nodes) + 1)
+
+	hits := make([]IndexHit, 0, k)
+	for _, c := range idx.searchLayer(q, ep, ef, 0) {
+		node := idx.nodes[c.id]
+		if node.Deleted {
+			continue
+		}
+		hits = append(hits, IndexHit{Key: node.Key, Similarity: c.similarity})
+		if len(hits) == k {
+			break
+		}
+	}
+	return hits
+}
+
+// Rebuild returns a new index holding the vectors of this one, without
+// the deleted vectors left in the graph
+func (idx *HNSWIndex) Rebuild() *HNSWIndex {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	rebuilt := NewHNSWIndex(idx.dimensions, idx.config)
+	for _, node := range idx.nodes {
+		if !node.Deleted {
+			rebuilt.insert(node.Key, node.Vector)
+		}
+	}
+	return rebuilt
+}
+
+// stale reports whether more of the graph is deleted vectors than live
+// ones, when a rebuild pays off
+func (idx *HNSWIndex) stale() bool {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.deleted > len(idx.keys)
+}
+
+// remove marks the node for key deleted. It is called with the lock held.
+func (idx *HNSWIndex) remove(key string) {
+	id, ok := idx.keys[key]
+	if !ok {
+		return
+	}
+	idx.nodes[id].Deleted = true
+	delete(idx.keys, key)
+	idx.deleted++
+}
+
+// insert links a normalized vector into the graph. It is called with the
+// lock held.
+func (idx *HNSWIndex) insert(key string, vector []float32) {
+	level := int(-math.Log(1-idx.rng.Float64()) * idx.levelMult)
+	id := int32(len(idx.nodes))
+	node := &hnswNode{Key: key, Vector: vector, Neighbors: make([][]int32, level+1)}
+	idx.nodes = append(idx.nodes, node)
+	idx.keys[key] = id
+
+	if idx.entry < 0 {
+		idx.entry = id
+		idx.maxLevel = level
+		return
+	}
+
+	ep := idx.entry
+	for l := idx.maxLevel; l > level; l-- {
+		ep = idx.greedyClosest(vector, ep, l)
+	}
+
+	top := level
+	if top > idx.maxLevel {
+		top = idx.maxLevel
+	}
+	for l := top; l >= 0; l-- {
+		candidates := idx.searchLayer(vector, ep, idx.config.EfConstruction, l)
+		node.Neighbors[l] = idx.selectNeighbors(candidates, idx.config.M)
+
+		// Link back, pruning neighbours that have too many links
+		for _, n := range node.Neighbors[l] {
+			neighbor := idx.nodes[n]
+			neighbor.Neighbors[l] = append(neighbor.Neighbors[l], id)
+			if limit := idx.maxConnections(l); len(neighbor.Neighbors[l]) > limit {
+				links := make([]candidate, len(neighbor.Neighbors[l]))
+				for i, link := range neighbor.Neighbors[l] {
+					links[i] = candidate{id: link, similarity: dot(neighbor.Vector, idx.nodes[link].Vector)}
+				}
+				sortCandidates(links)
+				neighbor.Neighbors[l] = idx.selectNeighbors(links, limit)
+			}
+		}
+		ep = candidates[0].id
+	}
+
+	if level > idx.maxLevel {
+		idx.maxLevel = level
+		idx.entry = id
+	}
+}
+
+// maxConnections is the number of links a node keeps on a layer
+func (idx *HNSWIndex) maxConnections(level int) int {
+	if level == 0 {
+		return 2 * idx.config.M
+	}
+	return idx.config.M
+}
+
+// greedyClosest walks from ep to the node on level closest to q
+func (idx *HNSWIndex) greedyClosest(q []float32, ep int32, level int) int32 {
+	best := dot(q, idx.nodes[ep].Vector)
+	for changed := true; changed; {
+		changed = false
+		for _, n := range idx.nodes[ep].Neighbors[level] {
+			if similarity := dot(q, idx.nodes[n].Vector); similarity > best {
+				best, ep, changed = similarity, n, true
+			}
+		}
+	}
+	return ep
+}
+
+// searchLayer returns the ef nodes on level closest to q found by a best
+// first search from ep, most similar first
+func (idx *HNSWIndex) searchLayer(q []float32, ep int32, ef int, level int) []candidate {
+	visited := map[int32]bool{ep: true}
+	first := candidate{id: ep, similarity: dot(q, idx.nodes[ep].Vector)}
+
+	// Candidates to explore, best first, and results, worst first
+	candidates := &candidateHeap{best: true, items: []candidate{first}}
+	results := &candidateHeap{items: []candidate{first}}
+
+	for candidates.Len() > 0 {
+		c := heap.Pop(candidates).(candidate)
+		if results.Len() >= ef && c.similarity < results.items[0].similarity {
+			break
+		}
+
+		for _, n := range idx.nodes[c.id].Neighbors[level] {
+			if visited[n] {
+				continue
+			}
+			visited[n] = true
+
+			similarity := dot(q, idx.nodes[n].Vector)
+			if results.Len() < ef || similarity > results.items[0].similarity {
+				heap.Push(candidates, candidate{id: n, similarity: similarity})
+				heap.Push(results, candidate{id: n, similarity: similarity})
+				if results.Len() > ef {
+					heap.Pop(results)
+				}
+			}
+		}
+	}
+
+	sortCandidates(results.items)
+	return results.items
+}
+
+// selectNeighbors picks up to m of the candidates, most similar first,
+// skipping those closer to an already picked neighbour than to the node so
+// that links spread in all directions. Skipped candidates fill any
+// remaining places.
+func (idx *HNSWIndex) selectNeighbors(candidates []candidate, m int) []int32 {
+	selected := make([]int32, 0, m)
+	var skipped []int32
+
+	for _, c := range candidates {
+		if len(selected) == m {
+			break
+		}
+		keep := true
+		for _, s := range selected {
+			if dot(idx.nodes[c.id].Vector, idx.nodes[s].Vector) > c.similarity {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			selected = append(selected, c.id)
+		} else {
+			skipped = append(skipped, c.id)
+		}
+	}
+
+	for _, id := range skipped {
+		if len(selected) == m {
+			break
+		}
+		selected = append(selected, id)
+	}
+	return selected
+}
+
+// hnswFile is the on-disk form of an index
+type hnswFile struct {
+	Version    int
+	Config     HNSWConfig
+	Dimensions int
+	Entry      int32
+	MaxLevel   int
+	Nodes      []*hnswNode
+}
+
+// Save writes the index to path, replacing the previous file atomically
+func (idx *HNSWIndex) Save(path string) error {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to create index file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	file := hnswFile{
+		Version:    hnswFormatVersion,
+		Config:     idx.config,
+		Dimensions: idx.dimensions,
+		Entry:      idx.entry,
+		MaxLevel:   idx.maxLevel,
+		Nodes:      idx.nodes,
+	}
+	if err := gob.NewEncoder(tmp).Encode(&file); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace index: %w", err)
+	}
+	return nil
+}
+
+// LoadHNSWIndex reads an index written by Save
+func LoadHNSWIndex(path string) (*HNSWIndex, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var file hnswFile
+	if err := gob.NewDecoder(f).Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to read index %s: %w", path, err)
+	}
+	if file.Version != hnswFormatVersion {
+		return nil, fmt.Errorf("index %s has unsupported format version %d", path, file.Version)
+	}
+
+	if file.Entry >= int32(len(file.Nodes)) || (file.Entry < 0 && len(file.Nodes) > 0) {
+		return nil, fmt.Errorf("index %s is corrupt", path)
+	}
+
+	idx := NewHNSWIndex(file.Dimensions, file.Config)
+	idx.nodes = file.Nodes
+	idx.entry = file.Entry
+	idx.maxLevel = file.MaxLevel
+	for id, node := range idx.nodes {
+		if len(node.Vector) != idx.dimensions || len(node.Neighbors) == 0 {
+			return nil, fmt.Errorf("index %s is corrupt", path)
+		}
+		for _, links := range node.Neighbors {
+			for _, link := range links {
+				if link < 0 || link >= int32(len(idx.nodes)) {
+					return nil, fmt.Errorf("index %s is corrupt", path)
+				}
+			}
+		}
+		if node.Deleted {
+			idx.deleted++
+			continue
+		}
+		idx.keys[node.Key] = int32(id)
+	}
+	if idx.entry >= 0 && len(idx.nodes[idx.entry].Neighbors) <= idx.maxLevel {
+		return nil, fmt.Errorf("index %s is corrupt", path)
+	}
+	return idx, nil
+}
+
+// exactSearch ranks every vector in the index against query. It is the
+// brute-force path the graph search approximates.
+func (idx *HNSWIndex) exactSearch(query []float32, k int) []IndexHit {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	q := normalize(query)
+	hits := make([]IndexHit, 0, len(idx.keys))
+	for _, node := range idx.nodes {
+		if !node.Deleted {
+			hits = append(hits, IndexHit{Key: node.Key, Similarity: dot(q, node.Vector)})
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		return hits[i].Similarity > hits[j].Similarity
+	})
+	if len(hits) > k {
+		hits = hits[:k]
+	}
+	return hits
+}
+
+// candidate is a node and its similarity to the vector searched for
+type candidate struct {
+	id         int32
+	similarity float32
+}
+
+// candidateHeap orders candidates best first, or worst first
+type candidateHeap struct {
+	best  bool
+	items []candidate
+}
+
+func (h *candidateHeap) Len() int { return len(h.items) }
+func (h *candidateHeap) Less(i, j int) bool {
+	if h.best {
+		return h.items[i].similarity > h.items[j].similarity
+	}
+	return h.items[i].similarity < h.items[j].similarity
+}
+func (h *candidateHeap) Swap(i, j int)      { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *candidateHeap) Push(x interface{}) { h.items = append(h.items, x.(candidate)) }
+func (h *candidateHeap) Pop() interface{} {
+	last := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return last
+}
+
+// sortCandidates sorts candidates most similar first
+func sortCandidates(candidates []candidate) {
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].similarity > candidates[j].similarity
+	})
+}
+
+// normalize returns vector scaled to unit length, so that the dot product
+// of normalized vectors is their cosine similarity
+func normalize(vector []float32) []float32 {
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v) * float64(v)
+	}
+	normalized := make([]float32, len(vector))
+	if norm == 0 {
+		return normalized
+	}
+	scale := float32(1 / math.Sqrt(norm))
+	for i, v := range vector {
+		normalized[i] = v * scale
+	}
+	return normalized
+}
+
+// dot returns the dot product of two vectors of the same length
+func dot(a, b []float32) float32 {
+	var sum float32
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
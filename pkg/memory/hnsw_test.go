@@ -0,0 +1,190 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/satishgonella2024/sentinelstacks/pkg/embedding"
+)
+
+// clusteredVectors returns n random vectors gathered around a few centres,
+// as embeddings of related texts are
+func clusteredVectors(rng *rand.Rand, n, dimensions int) [][]float32 {
+	centres := make([][]float32, 20)
+	for i := range centres {
+		centres[i] = make([]float32, dimensions)
+		for j := range centres[i] {
+			centres[i][j] = float32(rng.NormFloat64())
+		}
+	}
+
+	vectors := make([][]float32, n)
+	for i := range vectors {
+		centre := centres[rng.Intn(len(centres))]
+		vectors[i] = make([]float32, dimensions)
+		for j := range vectors[i] {
+			vectors[i][j] = centre[j] + 0.5*float32(rng.NormFloat64())
+		}
+	}
+	return vectors
+}
+
+// recall returns the fraction of the exact hits found by an approximate
+// search
+func recall(exact, approximate []IndexHit) float64 {
+	found := make(map[string]bool, len(approximate))
+	for _, hit := range approximate {
+		found[hit.Key] = true
+	}
+	var matched int
+	for _, hit := range exact {
+		if found[hit.Key] {
+			matched++
+		}
+	}
+	return float64(matched) / float64(len(exact))
+}
+
+// buildTestIndex indexes vectors under their position
+func buildTestIndex(tb testing.TB, vectors [][]float32) *HNSWIndex {
+	tb.Helper()
+	index := NewHNSWIndex(len(vectors[0]), DefaultHNSWConfig())
+	for i, vector := range vectors {
+		if err := index.Add(fmt.Sprint(i), vector); err != nil {
+			tb.Fatalf("Add failed: %v", err)
+		}
+	}
+	return index
+}
+
+func TestHNSWIndexRecall(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	vectors := clusteredVectors(rng, 2050, 32)
+	index := buildTestIndex(t, vectors[:2000])
+	queries := vectors[2000:]
+
+	var total float64
+	for _, query := range queries {
+		total += recall(index.exactSearch(query, 10), index.Search(query, 10))
+	}
+	if mean := total / float64(len(queries)); mean < 0.9 {
+		t.Errorf("Expected recall@10 of at least 0.9, got %.3f", mean)
+	}
+
+	// Deleted vectors are never returned, and replaced ones only once
+	for i := 0; i < 1000; i++ {
+		index.Delete(fmt.Sprint(i))
+	}
+	index.Add("1500", queries[0])
+	hits := index.Search(queries[0], 10)
+	if len(hits) != 10 || hits[0].Key != "1500" {
+		t.Fatalf("Expected the replaced vector first, got %+v", hits)
+	}
+	for _, hit := range hits[1:] {
+		var id int
+		fmt.Sscan(hit.Key, &id)
+		if id < 1000 || hit.Key == "1500" {
+			t.Errorf("Unexpected hit %s", hit.Key)
+		}
+	}
+}
+
+func TestSQLiteVectorStoreIndexPersistence(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "vectors.db")
+	embedder := embedding.NewHashEmbedder(64)
+
+	store, err := NewSQLiteVectorStore(path, embedder)
+	if err != nil {
+		t.Fatalf("NewSQLiteVectorStore failed: %v", err)
+	}
+	texts := map[string]string{
+		"cats":    "cats like to sleep in the sun",
+		"dogs":    "dogs like to chase a ball in the park",
+		"revenue": "quarterly revenue grew by ten percent",
+	}
+	for key, text := range texts {
+		if err := store.StoreVector(ctx, key, text, map[string]interface{}{"topic": key}); err != nil {
+			t.Fatalf("StoreVector failed: %v", err)
+		}
+	}
+	if err := store.DeleteVector(ctx, "dogs"); err != nil {
+		t.Fatalf("DeleteVector failed: %v", err)
+	}
+
+	// The changes are in the log until the store is closed
+	if info, err := os.Stat(IndexPath(path) + ".log"); err != nil || info.Size() == 0 {
+		t.Fatalf("Expected an index log, got %v", err)
+	}
+	reopened, err := NewSQLiteVectorStore(path, embedder)
+	if err != nil {
+		t.Fatalf("Reopening failed: %v", err)
+	}
+	if reopened.index.Len() != 2 {
+		t.Errorf("Expected the log to be replayed into 2 vectors, got %d", reopened.index.Len())
+	}
+	reopened.Close()
+	store.Close()
+
+	store, err = NewSQLiteVectorStore(path, embedder)
+	if err != nil {
+		t.Fatalf("Reopening failed: %v", err)
+	}
+	matches, err := store.SearchVector(ctx, "cats like to sleep", 5, nil)
+	if err != nil || len(matches) != 2 || matches[0].Key != "cats" {
+		t.Errorf("Expected cats first of 2 matches, got %+v, %v", matches, err)
+	}
+	matches, err = store.SearchVector(ctx, "cats like to sleep", 1, map[string]interface{}{"topic": "revenue"})
+	if err != nil || len(matches) != 1 || matches[0].Key != "revenue" {
+		t.Errorf("Expected the filtered match, got %+v, %v", matches, err)
+	}
+	store.Close()
+
+	// A lost index is rebuilt, on open or on demand
+	os.Remove(IndexPath(path))
+	count, err := ReindexSQLiteVectorStore(path)
+	if err != nil || count != 2 {
+		t.Errorf("Expected 2 vectors reindexed, got %d, %v", count, err)
+	}
+	os.Remove(IndexPath(path))
+	store, err = NewSQLiteVectorStore(path, embedder)
+	if err != nil {
+		t.Fatalf("Reopening failed: %v", err)
+	}
+	defer store.Close()
+	if store.index.Len() != 2 {
+		t.Errorf("Expected the index to be rebuilt with 2 vectors, got %d", store.index.Len())
+	}
+}
+
+// BenchmarkVectorSearch compares the latency and recall@10 of the index
+// against ranking every vector
+func BenchmarkVectorSearch(b *testing.B) {
+	rng := rand.New(rand.NewSource(7))
+	vectors := clusteredVectors(rng, 10100, 128)
+	index := buildTestIndex(b, vectors[:10000])
+	queries := vectors[10000:]
+
+	b.Run("exact", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			index.exactSearch(queries[i%len(queries)], 10)
+		}
+	})
+
+	b.Run("hnsw", func(b *testing.B) {
+		var total float64
+		for i := 0; i < b.N; i++ {
+			query := queries[i%len(queries)]
+			hits := index.Search(query, 10)
+
+			b.StopTimer()
+			total += recall(index.exactSearch(query, 10), hits)
+			b.StartTimer()
+		}
+		b.ReportMetric(total/float64(b.N), "recall@10")
+	})
+}
@@ -5,8 +5,6 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"math"
-	"sort"
 	"sync"
 	"time"
 
@@ -21,33 +19,32 @@ type VectorEntry struct {
 	Metadata map[string]interface{}
 }
 
-// LocalVectorStore is an in-memory implementation of VectorStore
+// LocalVectorStore is an in-memory implementation of VectorStore, searched
+// through an HNSW index
 type LocalVectorStore struct {
+	embedder   types.Embedder
 	dimensions int
 	vectors    map[string]*VectorEntry
+	index      *HNSWIndex
 	mu         sync.RWMutex
 }
 
-// NewLocalVectorStore creates a new local in-memory vector store
-func NewLocalVectorStore(dimensions int) *LocalVectorStore {
+// NewLocalVectorStore creates a new local in-memory vector store that
+// embeds texts with embedder
+func NewLocalVectorStore(embedder types.Embedder) *LocalVectorStore {
 	return &LocalVectorStore{
-		dimensions: dimensions,
+		embedder:   embedder,
+		dimensions: embedder.Dimensions(),
 		vectors:    make(map[string]*VectorEntry),
+		index:      NewHNSWIndex(embedder.Dimensions(), DefaultHNSWConfig()),
 	}
 }
 
 // StoreVector stores a text with its vector embedding
 func (s *LocalVectorStore) StoreVector(ctx context.Context, id string, text string, metadata map[string]interface{}) error {
-	// In a real implementation, we would generate embeddings here
-	// For now, we'll just create a mock vector
-	vector := make([]float32, s.dimensions)
-
-	// Generate a simple mock vector based on the text content
-	// This is just for demonstration purposes
-	for i := 0; i < s.dimensions && i < len(text); i++ {
-		if i < len(text) {
-			vector[i] = float32(text[i]) / 255.0
-		}
+	vector, err := s.embed(ctx, text)
+	if err != nil {
+		return err
 	}
 
 	entry := &VectorEntry{
@@ -60,70 +57,88 @@ func (s *LocalVectorStore) StoreVector(ctx context.Context, id string, text stri
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if err := s.index.Add(id, vector); err != nil {
+		return fmt.Errorf("failed to index vector: %w", err)
+	}
 	s.vectors[id] = entry
 	return nil
 }
 
-// SearchVector finds similar vectors using cosine similarity
+// embed returns the embedding of text, rejecting one whose dimensionality
+// differs from the vectors already stored
+func (s *LocalVectorStore) embed(ctx context.Context, text string) ([]float32, error) {
+	vectors, err := s.embedder.Embed(ctx, []string{text})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed text: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dimensions == 0 {
+		s.dimensions = len(vectors[0])
+	}
+	if err := checkDimensions(vectors[0], s.dimensions); err != nil {
+		return nil, err
+	}
+	return vectors[0], nil
+}
+
+// SearchVector finds similar vectors using cosine similarity, among those
+// whose metadata passes filter (see ParseFilter)
 func (s *LocalVectorStore) SearchVector(ctx context.Context, text string, limit int, filter map[string]interface{}) ([]types.MemoryMatch, error) {
 	if limit <= 0 {
 		limit = 10
 	}
 
-	// Again, in a real implementation, we would generate embeddings for the query text
-	// For now, we'll create a mock query vector
-	queryVector := make([]float32, s.dimensions)
-	for i := 0; i < s.dimensions && i < len(text); i++ {
-		if i < len(text) {
-			queryVector[i] = float32(text[i]) / 255.0
-		}
+	f, err := ParseFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	queryVector, err := s.embed(ctx, text)
+	if err != nil {
+		return nil, err
 	}
 
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	// Calculate similarity for all vectors
-	type scoredVector struct {
-		id    string
-		score float32
+	toMatches := func(hits []IndexHit) ([]types.MemoryMatch, error) {
+		matches := make([]types.MemoryMatch, 0, len(hits))
+		for _, hit := range hits {
+			entry := s.vectors[hit.Key]
+			if !f.Match(entry.Metadata) {
+				continue
+			}
+			matches = append(matches, types.MemoryMatch{
+				Key:       entry.ID,
+				Content:   entry.Text,
+				Score:     float64(hit.Similarity),
+				Metadata:  entry.Metadata,
+				Timestamp: time.Now(),
+				Distance:  1.0 - float64(hit.Similarity), // Convert similarity to distance
+			})
+		}
+		return matches, nil
 	}
 
-	var results []scoredVector
-	for id, entry := range s.vectors {
-		// Check if entry matches filter
-		if !matchesFilter(entry.Metadata, filter) {
-			continue
+	// A selective filter is cheaper to apply first
+	if !f.Empty() {
+		passing := make(map[string][]float32)
+		for id, entry := range s.vectors {
+			if f.Match(entry.Metadata) {
+				passing[id] = entry.Vector
+			}
+		}
+		if selective(len(passing), len(s.vectors)) {
+			return toMatches(exactHits(queryVector, passing, limit))
 		}
-
-		// Calculate cosine similarity
-		similarity := cosineSimilarity(queryVector, entry.Vector)
-
-		results = append(results, scoredVector{
-			id:    id,
-			score: similarity,
-		})
 	}
 
-	// Sort by similarity (descending)
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].score > results[j].score
-	})
-
-	// Convert to MemoryMatch objects
-	matches := make([]types.MemoryMatch, 0, len(results))
-	for i := 0; i < len(results) && i < limit; i++ {
-		entry := s.vectors[results[i].id]
-		matches = append(matches, types.MemoryMatch{
-			Key:       entry.ID,
-			Content:   entry.Text,
-			Score:     float64(results[i].score),
-			Metadata:  entry.Metadata,
-			Timestamp: time.Now(),
-			Distance:  1.0 - float64(results[i].score), // Convert similarity to distance
-		})
+	search := func(k int) []IndexHit {
+		return s.index.Search(queryVector, k)
 	}
-
-	return matches, nil
+	return searchIndex(search, len(s.vectors), limit, toMatches)
 }
 
 // DeleteVector removes a vector by ID
@@ -136,6 +151,10 @@ func (s *LocalVectorStore) DeleteVector(ctx context.Context, id string) error {
 	}
 
 	delete(s.vectors, id)
+	s.index.Delete(id)
+	if s.index.stale() {
+		s.index = s.index.Rebuild()
+	}
 	return nil
 }
 
@@ -159,48 +178,13 @@ func (s *LocalVectorStore) GetVector(ctx context.Context, id string) (*types.Mem
 	}, nil
 }
 
-// cosineSimilarity calculates the cosine similarity between two vectors
-func cosineSimilarity(a, b []float32) float32 {
-	if len(a) != len(b) {
-		return 0
-	}
-
-	var dotProduct float32
-	var magnitudeA float32
-	var magnitudeB float32
-
-	for i := 0; i < len(a); i++ {
-		dotProduct += a[i] * b[i]
-		magnitudeA += a[i] * a[i]
-		magnitudeB += b[i] * b[i]
+// checkDimensions rejects a vector whose dimensionality differs from the
+// store's
+func checkDimensions(vector []float32, dimensions int) error {
+	if len(vector) != dimensions {
+		return fmt.Errorf("embedding has %d dimensions, but the store holds %d-dimensional vectors", len(vector), dimensions)
 	}
-
-	magnitudeA = float32(math.Sqrt(float64(magnitudeA)))
-	magnitudeB = float32(math.Sqrt(float64(magnitudeB)))
-
-	if magnitudeA == 0 || magnitudeB == 0 {
-		return 0
-	}
-
-	return dotProduct / (magnitudeA * magnitudeB)
-}
-
-// matchesFilter checks if metadata matches the filter criteria
-func matchesFilter(metadata, filter map[string]interface{}) bool {
-	// Empty filter matches everything
-	if len(filter) == 0 {
-		return true
-	}
-
-	// Check each filter condition
-	for key, value := range filter {
-		metaValue, exists := metadata[key]
-		if !exists || metaValue != value {
-			return false
-		}
-	}
-
-	return true
+	return nil
 }
 
 // ListVectors returns all keys in the store
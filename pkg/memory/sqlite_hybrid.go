@@ -0,0 +1,243 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/satishgonella2024/sentinelstacks/pkg/types"
+)
+
+// DefaultKeywordWeight is the weight of keyword relevance in a hybrid
+// search when none is given
+const DefaultKeywordWeight = 0.3
+
+// hybridCandidates is how many candidates per result a hybrid search takes
+// from each of the keyword and vector searches
+const hybridCandidates = 4
+
+// keywordTableSQL creates the full-text index of the texts. Its rows share
+// the rowid of their vectors row and keep the key to check that they do.
+const keywordTableSQL = `CREATE VIRTUAL TABLE IF NOT EXISTS vectors_fts USING fts5(key UNINDEXED, text);`
+
+// keywordSearchAvailable reports whether SQLite was built with FTS5, which
+// go-sqlite3 includes with the sqlite_fts5 build tag
+func keywordSearchAvailable(db *sql.DB) (bool, error) {
+	var available bool
+	if err := db.QueryRow(`SELECT sqlite_compileoption_used('ENABLE_FTS5');`).Scan(&available); err != nil {
+		return false, fmt.Errorf("failed to check for FTS5: %w", err)
+	}
+	return available, nil
+}
+
+// openKeywordIndex creates the keyword index when FTS5 is available, and
+// rebuilds it when it is out of step with the texts, as when the store was
+// written by a build without FTS5
+func openKeywordIndex(db *sql.DB) (bool, error) {
+	available, err := keywordSearchAvailable(db)
+	if err != nil || !available {
+		return false, err
+	}
+
+	if _, err := db.Exec(keywordTableSQL); err != nil {
+		return false, fmt.Errorf("failed to create keyword index: %w", err)
+	}
+
+	var texts, indexed, linked int
+	err = db.QueryRow(`SELECT
+		(SELECT COUNT(*) FROM vectors),
+		(SELECT COUNT(*) FROM vectors_fts),
+		(SELECT COUNT(*) FROM vectors_fts f JOIN vectors v ON v.rowid = f.rowid AND v.key = f.key);`).Scan(&texts, &indexed, &linked)
+	if err != nil {
+		return false, fmt.Errorf("failed to check keyword index: %w", err)
+	}
+	if texts != indexed || texts != linked {
+		if err := rebuildKeywordIndex(db); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// rebuildKeywordIndex indexes every stored text
+func rebuildKeywordIndex(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM vectors_fts;`); err != nil {
+		return fmt.Errorf("failed to clear keyword index: %w", err)
+	}
+	if _, err := tx.Exec(`INSERT INTO vectors_fts (rowid, key, text) SELECT rowid, key, text FROM vectors;`); err != nil {
+		return fmt.Errorf("failed to rebuild keyword index: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// indexKeywords replaces the keywords of the text stored under key
+func indexKeywords(ctx context.Context, tx *sql.Tx, key, text string) error {
+	var rowid int64
+	if err := tx.QueryRowContext(ctx, `SELECT rowid FROM vectors WHERE key = ?;`, key).Scan(&rowid); err != nil {
+		return fmt.Errorf("failed to index keywords: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM vectors_fts WHERE rowid = ?;`, rowid); err != nil {
+		return fmt.Errorf("failed to index keywords: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO vectors_fts (rowid, key, text) VALUES (?, ?, ?);`, rowid, key, text); err != nil {
+		return fmt.Errorf("failed to index keywords: %w", err)
+	}
+	return nil
+}
+
+// SearchHybrid ranks texts passing filter by keywordWeight times their
+// BM25 keyword relevance plus the remaining weight times their vector
+// similarity, so that exact identifiers match even where embeddings blur
+// them. Keyword relevance is scaled so the best keyword match scores 1.
+// Without FTS5 it ranks by vector similarity alone.
+func (s *SQLiteVectorStore) SearchHybrid(ctx context.Context, text string, limit int, filter map[string]interface{}, keywordWeight float64) ([]types.MemoryMatch, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+	if keywordWeight < 0 {
+		keywordWeight = 0
+	} else if keywordWeight > 1 {
+		keywordWeight = 1
+	}
+
+	f, err := ParseFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	queryVector, err := s.embed(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+
+	if !s.keywords || keywordWeight == 0 {
+		return s.searchVector(ctx, queryVector, limit, f)
+	}
+
+	candidates := limit * hybridCandidates
+	keywordScores, err := s.keywordSearch(ctx, text, candidates, f)
+	if err != nil {
+		return nil, err
+	}
+	matches, err := s.searchVector(ctx, queryVector, candidates, f)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keyword matches the vector search missed need their similarity
+	found := make(map[string]bool, len(matches))
+	for _, match := range matches {
+		found[match.Key] = true
+	}
+	var missing []string
+	for key := range keywordScores {
+		if !found[key] {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		vectors := make(map[string][]float32, len(missing))
+		err := inChunks(missing, func(placeholders string, args []interface{}) error {
+			chunk, err := s.queryEmbeddings(ctx, `SELECT key, embedding FROM embeddings WHERE key IN (`+placeholders+`);`, args)
+			for key, vector := range chunk {
+				vectors[key] = vector
+			}
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		extra, err := s.resolve(ctx, exactHits(queryVector, vectors, len(vectors)), nil)
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, extra...)
+	}
+
+	for i := range matches {
+		similarity := matches[i].Score
+		matches[i].Score = (1-keywordWeight)*similarity + keywordWeight*keywordScores[matches[i].Key]
+		matches[i].Distance = 1.0 - matches[i].Score
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Score > matches[j].Score
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
+
+// keywordSearch returns the relevance of the limit texts passing the filter
+// that best match the words of text, scaled so the best scores 1
+func (s *SQLiteVectorStore) keywordSearch(ctx context.Context, text string, limit int, f *Filter) (map[string]float64, error) {
+	query := keywordQuery(text)
+	if query == "" {
+		return nil, nil
+	}
+
+	where, args := f.SQL("v.metadata")
+	rows, err := s.db.QueryContext(ctx, `SELECT v.key, bm25(vectors_fts) AS rank
+		FROM vectors_fts JOIN vectors v ON v.rowid = vectors_fts.rowid
+		WHERE vectors_fts MATCH ? AND `+where+`
+		ORDER BY rank LIMIT ?;`, append(append([]interface{}{query}, args...), limit)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search keywords: %w", err)
+	}
+	defer rows.Close()
+
+	// BM25 ranks are negative, the best lowest
+	scores := make(map[string]float64)
+	var best float64
+	for rows.Next() {
+		var key string
+		var rank float64
+		if err := rows.Scan(&key, &rank); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		if len(scores) == 0 {
+			best = rank
+		}
+		scores[key] = 1
+		if best < 0 {
+			scores[key] = rank / best
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return scores, nil
+}
+
+// keywordQuery turns text into an FTS5 query matching any of its words,
+// quoted so that punctuation and operators in the text are not parsed
+func keywordQuery(text string) string {
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	seen := make(map[string]bool, len(words))
+	var terms []string
+	for _, word := range words {
+		word = strings.ToLower(word)
+		if !seen[word] {
+			seen[word] = true
+			terms = append(terms, `"`+word+`"`)
+		}
+	}
+	return strings.Join(terms, " OR ")
+}
@@ -5,26 +5,41 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"hash/fnv"
 	"math"
 	"os"
 	"path/filepath"
-	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/satishgonella2024/sentinelstacks/pkg/types"
 )
 
-// SQLiteVectorStore is a SQLite-backed vector store
+// SQLiteVectorStore is a SQLite-backed vector store, searched through an
+// HNSW index kept next to the database
 type SQLiteVectorStore struct {
 	db         *sql.DB
 	path       string
+	embedder   types.Embedder
 	dimensions int
+	mu         sync.Mutex
+
+	index   *diskIndex
+	indexMu sync.RWMutex
+
+	// keywords reports whether texts are indexed for keyword search
+	keywords bool
 }
 
-// NewSQLiteVectorStore creates a new SQLite-backed vector store
-func NewSQLiteVectorStore(path string, dimensions int) (*SQLiteVectorStore, error) {
+// NewSQLiteVectorStore creates a new SQLite-backed vector store that embeds
+// texts with embedder. A store holding vectors of another dimensionality
+// than the embedder's is rejected. An index that is missing, unreadable or
+// out of step with the database is rebuilt.
+func NewSQLiteVectorStore(path string, embedder types.Embedder) (*SQLiteVectorStore, error) {
 	// Create directory if it doesn't exist
 	dir := filepath.Dir(path)
 	if err := os.MkdirAll(dir, 0755); err != nil {
@@ -65,15 +80,214 @@ func NewSQLiteVectorStore(path string, dimensions int) (*SQLiteVectorStore, erro
 		return nil, fmt.Errorf("failed to enable foreign keys: %w", err)
 	}
 
+	// Vectors already stored fix the dimensionality
+	dimensions := embedder.Dimensions()
+	var stored int
+	err = db.QueryRow(`SELECT dimensions FROM embeddings LIMIT 1;`).Scan(&stored)
+	switch {
+	case err == sql.ErrNoRows:
+	case err != nil:
+		db.Close()
+		return nil, fmt.Errorf("failed to read embedding dimensions: %w", err)
+	case dimensions == 0:
+		dimensions = stored
+	case stored != dimensions:
+		db.Close()
+		return nil, fmt.Errorf("vector store %s holds %d-dimensional embeddings, but the embedder produces %d", path, stored, dimensions)
+	}
+
+	keywords, err := openKeywordIndex(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	index, err := openStoreIndex(db, path, dimensions)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
 	return &SQLiteVectorStore{
 		db:         db,
 		path:       path,
+		embedder:   embedder,
 		dimensions: dimensions,
+		index:      index,
+		keywords:   keywords,
 	}, nil
 }
 
+// IndexMetadataFields indexes metadata fields that filters often restrict,
+// such as a project or a creation time. Index names end with a hash of the
+// field, so fields that sanitize alike, such as a.b and a_b, get their own.
+func (s *SQLiteVectorStore) IndexMetadataFields(fields []string) error {
+	for _, field := range fields {
+		if field == "" || strings.ContainsAny(field, `"\`) {
+			return fmt.Errorf("invalid metadata field %q", field)
+		}
+		name := strings.Map(func(r rune) rune {
+			if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+				return r
+			}
+			return '_'
+		}, field)
+		hash := fnv.New32a()
+		hash.Write([]byte(field))
+
+		_, err := s.db.Exec(fmt.Sprintf(`CREATE INDEX IF NOT EXISTS "vectors_metadata_%s_%08x" ON vectors(json_extract(%s));`, name, hash.Sum32(), metadataPath("metadata", field)))
+		if err != nil {
+			return fmt.Errorf("failed to index metadata field %s: %w", field, err)
+		}
+	}
+	return nil
+}
+
+// openStoreIndex opens the index of the store at path, rebuilding it from
+// db when it does not match the stored embeddings
+func openStoreIndex(db *sql.DB, path string, dimensions int) (*diskIndex, error) {
+	indexPath := IndexPath(path)
+	index, err := openDiskIndex(indexPath, dimensions)
+	if err != nil {
+		fmt.Printf("Warning: discarding vector index %s: %v\n", indexPath, err)
+		os.Remove(indexPath)
+		os.Remove(indexPath + ".log")
+		if index, err = openDiskIndex(indexPath, dimensions); err != nil {
+			return nil, err
+		}
+	}
+
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM embeddings e JOIN vectors v ON v.key = e.key;`).Scan(&count); err != nil {
+		index.Close()
+		return nil, fmt.Errorf("failed to count embeddings: %w", err)
+	}
+	if index.Len() == count && (index.Dimensions() == dimensions || count == 0) {
+		return index, nil
+	}
+
+	rebuilt, err := buildIndex(db, dimensions)
+	if err == nil {
+		err = index.reset(rebuilt)
+	}
+	if err != nil {
+		index.Close()
+		return nil, fmt.Errorf("failed to rebuild vector index: %w", err)
+	}
+	return index, nil
+}
+
+// buildIndex indexes every embedding stored in db
+func buildIndex(db *sql.DB, dimensions int) (*HNSWIndex, error) {
+	rows, err := db.Query(`SELECT e.key, e.embedding FROM embeddings e JOIN vectors v ON v.key = e.key;`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query embeddings: %w", err)
+	}
+	defer rows.Close()
+
+	index := NewHNSWIndex(dimensions, DefaultHNSWConfig())
+	for rows.Next() {
+		var key string
+		var embeddingBytes []byte
+		if err := rows.Scan(&key, &embeddingBytes); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		if err := index.Add(key, bytesToVector(embeddingBytes)); err != nil {
+			return nil, fmt.Errorf("failed to index %s: %w", key, err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return index, nil
+}
+
+// ErrNotVectorStore is returned when reindexing a database that is not a
+// SQLite vector store
+var ErrNotVectorStore = errors.New("not a SQLite vector store")
+
+// ReindexSQLiteVectorStore rebuilds the index of the SQLite vector store at
+// path from its embeddings, and its keyword index from its texts, returning
+// the number of vectors indexed
+func ReindexSQLiteVectorStore(path string) (int, error) {
+	if _, err := os.Stat(path); err != nil {
+		return 0, err
+	}
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open SQLite database: %w", err)
+	}
+	defer db.Close()
+
+	var tables int
+	err = db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name IN ('vectors', 'embeddings');`).Scan(&tables)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open SQLite database: %w", err)
+	}
+	if tables != 2 {
+		return 0, ErrNotVectorStore
+	}
+
+	var dimensions int
+	err = db.QueryRow(`SELECT dimensions FROM embeddings LIMIT 1;`).Scan(&dimensions)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("failed to read embedding dimensions: %w", err)
+	}
+
+	keywords, err := keywordSearchAvailable(db)
+	if err != nil {
+		return 0, err
+	}
+	if keywords {
+		if _, err := db.Exec(keywordTableSQL); err != nil {
+			return 0, fmt.Errorf("failed to create keyword index: %w", err)
+		}
+		if err := rebuildKeywordIndex(db); err != nil {
+			return 0, err
+		}
+	}
+
+	index, err := buildIndex(db, dimensions)
+	if err != nil {
+		return 0, err
+	}
+	indexPath := IndexPath(path)
+	if err := index.Save(indexPath); err != nil {
+		return 0, err
+	}
+	if err := os.Remove(indexPath + ".log"); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return 0, fmt.Errorf("failed to remove index log: %w", err)
+	}
+	return index.Len(), nil
+}
+
+// embed returns the embedding of text, rejecting one whose dimensionality
+// differs from the vectors already stored
+func (s *SQLiteVectorStore) embed(ctx context.Context, text string) ([]float32, error) {
+	vectors, err := s.embedder.Embed(ctx, []string{text})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed text: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dimensions == 0 {
+		s.dimensions = len(vectors[0])
+	}
+	if err := checkDimensions(vectors[0], s.dimensions); err != nil {
+		return nil, err
+	}
+	return vectors[0], nil
+}
+
 // StoreVector stores a text with its vector embedding
 func (s *SQLiteVectorStore) StoreVector(ctx context.Context, key string, text string, metadata map[string]interface{}) error {
+	// Embed before the transaction, which would otherwise wait on the model
+	vector, err := s.embed(ctx, text)
+	if err != nil {
+		return err
+	}
+
 	// Start a transaction
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -102,15 +316,9 @@ func (s *SQLiteVectorStore) StoreVector(ctx context.Context, key string, text st
 		return fmt.Errorf("failed to insert/update vector: %w", err)
 	}
 
-	// In a real implementation, we would generate embeddings here
-	// For now, we'll just create a mock vector
-	vector := make([]float32, s.dimensions)
-
-	// Generate a simple mock vector based on the text content
-	// This is just for demonstration purposes
-	for i := 0; i < s.dimensions && i < len(text); i++ {
-		if i < len(text) {
-			vector[i] = float32(text[i]) / 255.0
+	if s.keywords {
+		if err := indexKeywords(ctx, tx, key, text); err != nil {
+			return err
 		}
 	}
 
@@ -128,7 +336,7 @@ func (s *SQLiteVectorStore) StoreVector(ctx context.Context, key string, text st
 		ON CONFLICT(key) DO UPDATE SET
 			embedding = excluded.embedding,
 			dimensions = excluded.dimensions;`,
-		key, vectorBytes, s.dimensions,
+		key, vectorBytes, len(vector),
 	)
 	if err != nil {
 		return fmt.Errorf("failed to insert/update embedding: %w", err)
@@ -139,115 +347,176 @@ func (s *SQLiteVectorStore) StoreVector(ctx context.Context, key string, text st
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
+	s.indexMu.Lock()
+	defer s.indexMu.Unlock()
+	if err := s.index.Add(key, vector); err != nil {
+		return fmt.Errorf("failed to index vector: %w", err)
+	}
 	return nil
 }
 
-// SearchVector finds similar vectors using cosine similarity
+// SearchVector finds similar vectors using cosine similarity, among those
+// whose metadata passes filter (see ParseFilter)
 func (s *SQLiteVectorStore) SearchVector(ctx context.Context, text string, limit int, filter map[string]interface{}) ([]types.MemoryMatch, error) {
 	if limit <= 0 {
 		limit = 10
 	}
 
-	// In a real implementation, we would generate embeddings for the query text
-	// For now, we'll create a mock query vector
-	queryVector := make([]float32, s.dimensions)
-	for i := 0; i < s.dimensions && i < len(text); i++ {
-		if i < len(text) {
-			queryVector[i] = float32(text[i]) / 255.0
+	f, err := ParseFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	queryVector, err := s.embed(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.searchVector(ctx, queryVector, limit, f)
+}
+
+// searchVector finds the limit vectors most similar to queryVector that
+// pass the filter
+func (s *SQLiteVectorStore) searchVector(ctx context.Context, queryVector []float32, limit int, f *Filter) ([]types.MemoryMatch, error) {
+	s.indexMu.RLock()
+	defer s.indexMu.RUnlock()
+
+	// A selective filter is cheaper to apply first
+	if !f.Empty() {
+		where, args := f.SQL("v.metadata")
+
+		var passing int
+		if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM vectors v WHERE `+where+`;`, args...).Scan(&passing); err != nil {
+			return nil, fmt.Errorf("failed to filter vectors: %w", err)
+		}
+		if selective(passing, s.index.Len()) {
+			vectors, err := s.queryEmbeddings(ctx, `SELECT v.key, e.embedding FROM vectors v JOIN embeddings e ON e.key = v.key WHERE `+where+`;`, args)
+			if err != nil {
+				return nil, err
+			}
+			return s.resolve(ctx, exactHits(queryVector, vectors, limit), nil)
 		}
 	}
 
-	// Get all vectors from the database
-	rows, err := s.db.QueryContext(
-		ctx,
-		`SELECT v.key, v.text, v.metadata, e.embedding, v.timestamp
-		FROM vectors v
-		JOIN embeddings e ON v.key = e.key;`,
-	)
+	search := func(k int) []IndexHit {
+		return s.index.Search(queryVector, k)
+	}
+	return searchIndex(search, s.index.Len(), limit, func(hits []IndexHit) ([]types.MemoryMatch, error) {
+		return s.resolve(ctx, hits, f)
+	})
+}
+
+// resolve loads the vectors found by a search, in order, dropping those
+// failing the filter
+func (s *SQLiteVectorStore) resolve(ctx context.Context, hits []IndexHit, f *Filter) ([]types.MemoryMatch, error) {
+	keys := make([]string, len(hits))
+	for i, hit := range hits {
+		keys[i] = hit.Key
+	}
+
+	where, filterArgs := f.SQL("metadata")
+	rows := make(map[string]types.MemoryMatch, len(hits))
+	err := inChunks(keys, func(placeholders string, args []interface{}) error {
+		query := `SELECT key, text, metadata, timestamp FROM vectors WHERE key IN (` + placeholders + `) AND ` + where + `;`
+		return s.queryMatches(ctx, query, append(args, filterArgs...), rows)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to query vectors: %w", err)
+		return nil, err
 	}
-	defer rows.Close()
 
-	// Calculate similarity for all vectors
-	type matchScore struct {
-		key       string
-		text      string
-		metadata  map[string]interface{}
-		score     float64
-		timestamp time.Time
+	matches := make([]types.MemoryMatch, 0, len(hits))
+	for _, hit := range hits {
+		match, ok := rows[hit.Key]
+		if !ok {
+			continue
+		}
+		match.Score = float64(hit.Similarity)
+		match.Distance = 1.0 - match.Score
+		matches = append(matches, match)
 	}
+	return matches, nil
+}
+
+// queryEmbeddings returns the embeddings by key selected by query
+func (s *SQLiteVectorStore) queryEmbeddings(ctx context.Context, query string, args []interface{}) (map[string][]float32, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query embeddings: %w", err)
+	}
+	defer rows.Close()
 
-	var matches []matchScore
+	vectors := make(map[string][]float32)
 	for rows.Next() {
-		var key, text, metadataJSON string
+		var key string
 		var embeddingBytes []byte
-		var timestamp time.Time
-
-		if err := rows.Scan(&key, &text, &metadataJSON, &embeddingBytes, &timestamp); err != nil {
+		if err := rows.Scan(&key, &embeddingBytes); err != nil {
 			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
+		vectors[key] = bytesToVector(embeddingBytes)
+	}
 
-		// Parse metadata
-		var metadata map[string]interface{}
-		if err := json.Unmarshal([]byte(metadataJSON), &metadata); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
-		}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return vectors, nil
+}
 
-		// Check filter
-		if !matchesFilter(metadata, filter) {
-			continue
+// inChunks calls fn with the placeholders and arguments of an IN list for
+// each chunk of keys, keeping below SQLite's limit on parameters
+func inChunks(keys []string, fn func(placeholders string, args []interface{}) error) error {
+	const chunkSize = 500
+
+	for start := 0; start < len(keys); start += chunkSize {
+		end := start + chunkSize
+		if end > len(keys) {
+			end = len(keys)
 		}
 
-		// Convert embedding bytes to vector
-		if len(embeddingBytes) != s.dimensions*4 {
-			return nil, fmt.Errorf("invalid embedding size: %d", len(embeddingBytes))
+		args := make([]interface{}, 0, end-start)
+		for _, key := range keys[start:end] {
+			args = append(args, key)
 		}
-		vector := make([]float32, s.dimensions)
-		for i := 0; i < s.dimensions; i++ {
-			vector[i] = bytesToFloat32(embeddingBytes[i*4 : (i+1)*4])
+		if err := fn(strings.TrimSuffix(strings.Repeat("?,", len(args)), ","), args); err != nil {
+			return err
 		}
-
-		// Calculate cosine similarity
-		similarity := cosineSimilarity(queryVector, vector)
-
-		matches = append(matches, matchScore{
-			key:       key,
-			text:      text,
-			metadata:  metadata,
-			score:     float64(similarity),
-			timestamp: timestamp,
-		})
 	}
+	return nil
+}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating rows: %w", err)
+// queryMatches adds the vectors returned by query to matches by key
+func (s *SQLiteVectorStore) queryMatches(ctx context.Context, query string, args []interface{}, matches map[string]types.MemoryMatch) error {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query vectors: %w", err)
 	}
+	defer rows.Close()
 
-	// Sort by similarity (descending)
-	sort.Slice(matches, func(i, j int) bool {
-		return matches[i].score > matches[j].score
-	})
+	for rows.Next() {
+		var key, text, metadataJSON string
+		var timestamp time.Time
 
-	// Limit results
-	if len(matches) > limit {
-		matches = matches[:limit]
-	}
-
-	// Convert to MemoryMatch objects
-	result := make([]types.MemoryMatch, len(matches))
-	for i, match := range matches {
-		result[i] = types.MemoryMatch{
-			Key:       match.key,
-			Content:   match.text,
-			Metadata:  match.metadata,
-			Score:     match.score,
-			Distance:  1.0 - match.score,
-			Timestamp: match.timestamp,
+		if err := rows.Scan(&key, &text, &metadataJSON, &timestamp); err != nil {
+			return fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		// Parse metadata
+		var metadata map[string]interface{}
+		if err := json.Unmarshal([]byte(metadataJSON), &metadata); err != nil {
+			return fmt.Errorf("failed to unmarshal metadata: %w", err)
+		}
+
+		matches[key] = types.MemoryMatch{
+			Key:       key,
+			Content:   text,
+			Metadata:  metadata,
+			Timestamp: timestamp,
 		}
 	}
 
-	return result, nil
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating rows: %w", err)
+	}
+	return nil
 }
 
 // GetVector retrieves a vector by key
@@ -286,7 +555,19 @@ func (s *SQLiteVectorStore) GetVector(ctx context.Context, key string) (*types.M
 
 // DeleteVector removes a vector by key
 func (s *SQLiteVectorStore) DeleteVector(ctx context.Context, key string) error {
-	_, err := s.db.ExecContext(
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if s.keywords {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM vectors_fts WHERE rowid = (SELECT rowid FROM vectors WHERE key = ?);`, key); err != nil {
+			return fmt.Errorf("failed to delete keywords: %w", err)
+		}
+	}
+
+	_, err = tx.ExecContext(
 		ctx,
 		`DELETE FROM vectors WHERE key = ?;`,
 		key,
@@ -294,6 +575,16 @@ func (s *SQLiteVectorStore) DeleteVector(ctx context.Context, key string) error
 	if err != nil {
 		return fmt.Errorf("failed to delete vector: %w", err)
 	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	s.indexMu.Lock()
+	defer s.indexMu.Unlock()
+	if err := s.index.Delete(key); err != nil {
+		return fmt.Errorf("failed to update vector index: %w", err)
+	}
 	return nil
 }
 
@@ -321,9 +612,16 @@ func (s *SQLiteVectorStore) ListVectors(ctx context.Context) ([]string, error) {
 	return keys, nil
 }
 
-// Close closes the database connection
+// Close saves the index and closes the database connection
 func (s *SQLiteVectorStore) Close() error {
-	return s.db.Close()
+	s.indexMu.Lock()
+	indexErr := s.index.Close()
+	s.indexMu.Unlock()
+
+	if err := s.db.Close(); err != nil {
+		return err
+	}
+	return indexErr
 }
 
 // float32ToBytes converts a float32 to bytes
@@ -337,6 +635,15 @@ func float32ToBytes(f float32) []byte {
 	return bytes
 }
 
+// bytesToVector converts a serialized embedding to a vector
+func bytesToVector(bytes []byte) []float32 {
+	vector := make([]float32, len(bytes)/4)
+	for i := range vector {
+		vector[i] = bytesToFloat32(bytes[i*4 : (i+1)*4])
+	}
+	return vector
+}
+
 // bytesToFloat32 converts bytes to a float32
 func bytesToFloat32(bytes []byte) float32 {
 	bits := uint32(bytes[0]) |
@@ -0,0 +1,305 @@
+package memory
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+
+	"github.com/satishgonella2024/sentinelstacks/pkg/types"
+)
+
+// Index log operations
+const (
+	indexLogAdd    byte = 'A'
+	indexLogDelete byte = 'D'
+)
+
+// minCompactEntries is the smallest log that is folded into the snapshot
+const minCompactEntries = 1024
+
+// Bounds on log records, beyond which a record is taken to be corrupt
+const (
+	maxIndexKeyLen     = 1 << 20
+	maxIndexDimensions = 1 << 16
+)
+
+// diskIndex is an HNSW index persisted as a snapshot at path and a log of
+// the changes made since, at path + ".log". The log is folded into the
+// snapshot once it grows past a quarter of the index, and on close.
+type diskIndex struct {
+	*HNSWIndex
+	path    string
+	log     *os.File
+	pending int
+}
+
+// IndexPath returns the path of the vector index kept next to a SQLite
+// vector store
+func IndexPath(dbPath string) string {
+	return dbPath + ".hnsw"
+}
+
+// openDiskIndex loads the index at path and replays its log. A missing
+// snapshot gives an empty index; a torn record at the end of the log, left
+// by a crash, is dropped.
+func openDiskIndex(path string, dimensions int) (*diskIndex, error) {
+	index, err := LoadHNSWIndex(path)
+	if errors.Is(err, os.ErrNotExist) {
+		index = NewHNSWIndex(dimensions, DefaultHNSWConfig())
+	} else if err != nil {
+		return nil, err
+	}
+
+	d := &diskIndex{HNSWIndex: index, path: path}
+	valid, err := d.replay()
+	if err != nil {
+		return nil, err
+	}
+
+	log, err := os.OpenFile(path+".log", os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open index log: %w", err)
+	}
+	if err := log.Truncate(valid); err != nil {
+		log.Close()
+		return nil, fmt.Errorf("failed to truncate index log: %w", err)
+	}
+	if _, err := log.Seek(valid, io.SeekStart); err != nil {
+		log.Close()
+		return nil, fmt.Errorf("failed to open index log: %w", err)
+	}
+	d.log = log
+	return d, nil
+}
+
+// replay applies the log to the index, returning the length of its valid
+// part
+func (d *diskIndex) replay() (int64, error) {
+	f, err := os.Open(d.path + ".log")
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to open index log: %w", err)
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	var valid int64
+	for {
+		op, key, vector, n, err := readIndexRecord(reader)
+		if err != nil {
+			// A clean end or a torn last record
+			return valid, nil
+		}
+		switch op {
+		case indexLogAdd:
+			if err := d.HNSWIndex.Add(key, vector); err != nil {
+				return valid, nil
+			}
+		case indexLogDelete:
+			d.HNSWIndex.Delete(key)
+		default:
+			return valid, nil
+		}
+		valid += n
+		d.pending++
+	}
+}
+
+// Add indexes the vector for key and logs the change
+func (d *diskIndex) Add(key string, vector []float32) error {
+	if err := d.HNSWIndex.Add(key, vector); err != nil {
+		return err
+	}
+	return d.append(indexLogAdd, key, vector)
+}
+
+// Delete removes the vector for key and logs the change
+func (d *diskIndex) Delete(key string) error {
+	d.HNSWIndex.Delete(key)
+	return d.append(indexLogDelete, key, nil)
+}
+
+// append writes a record to the log, compacting it when it is long
+func (d *diskIndex) append(op byte, key string, vector []float32) error {
+	if _, err := d.log.Write(encodeIndexRecord(op, key, vector)); err != nil {
+		return fmt.Errorf("failed to write index log: %w", err)
+	}
+	d.pending++
+
+	threshold := d.HNSWIndex.Len() / 4
+	if threshold < minCompactEntries {
+		threshold = minCompactEntries
+	}
+	if d.pending >= threshold {
+		return d.compact()
+	}
+	return nil
+}
+
+// compact writes a snapshot of the index and empties the log. An index
+// with more deleted than live vectors is rebuilt first.
+func (d *diskIndex) compact() error {
+	if d.HNSWIndex.stale() {
+		d.HNSWIndex = d.HNSWIndex.Rebuild()
+	}
+
+	if err := d.HNSWIndex.Save(d.path); err != nil {
+		return err
+	}
+	if err := d.log.Truncate(0); err != nil {
+		return fmt.Errorf("failed to truncate index log: %w", err)
+	}
+	if _, err := d.log.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to truncate index log: %w", err)
+	}
+	d.pending = 0
+	return nil
+}
+
+// reset replaces the index with a new one, as when it is rebuilt from the
+// store
+func (d *diskIndex) reset(index *HNSWIndex) error {
+	d.HNSWIndex = index
+	return d.compact()
+}
+
+// Close folds the log into the snapshot
+func (d *diskIndex) Close() error {
+	var err error
+	if d.pending > 0 {
+		err = d.compact()
+	}
+	if closeErr := d.log.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Filters passing at most exactSearchLimit vectors, or under one in
+// selectiveRatio of them, are applied before ranking the vectors passing
+// them exhaustively, rather than after searching the index
+const (
+	exactSearchLimit = 1000
+	selectiveRatio   = 10
+)
+
+// selective reports whether a filter passing passing of total vectors is
+// best applied first
+func selective(passing, total int) bool {
+	return passing <= exactSearchLimit || passing*selectiveRatio <= total
+}
+
+// exactHits ranks vectors by key against query, returning the k most
+// similar
+func exactHits(query []float32, vectors map[string][]float32, k int) []IndexHit {
+	q := normalize(query)
+	hits := make([]IndexHit, 0, len(vectors))
+	for key, vector := range vectors {
+		if len(vector) == len(q) {
+			hits = append(hits, IndexHit{Key: key, Similarity: dot(q, normalize(vector))})
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		return hits[i].Similarity > hits[j].Similarity
+	})
+	if len(hits) > k {
+		hits = hits[:k]
+	}
+	return hits
+}
+
+// searchIndex returns the limit best matches for an index search, resolved
+// to matches by resolve, which drops those failing the filter. While too
+// few pass, the search is widened until it covers the whole index.
+func searchIndex(search func(k int) []IndexHit, size, limit int, resolve func([]IndexHit) ([]types.MemoryMatch, error)) ([]types.MemoryMatch, error) {
+	for k := limit; ; k *= 4 {
+		hits := search(k)
+		matches, err := resolve(hits)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) >= limit {
+			return matches[:limit], nil
+		}
+		if len(hits) < k || k >= size {
+			return matches, nil
+		}
+	}
+}
+
+// encodeIndexRecord encodes a log record: the operation, the key length
+// and key, and for additions the vector length and values
+func encodeIndexRecord(op byte, key string, vector []float32) []byte {
+	buf := make([]byte, 0, 1+2*binary.MaxVarintLen64+len(key)+4*len(vector))
+	buf = append(buf, op)
+	buf = binary.AppendUvarint(buf, uint64(len(key)))
+	buf = append(buf, key...)
+	if op == indexLogAdd {
+		buf = binary.AppendUvarint(buf, uint64(len(vector)))
+		for _, v := range vector {
+			buf = binary.LittleEndian.AppendUint32(buf, math.Float32bits(v))
+		}
+	}
+	return buf
+}
+
+// readIndexRecord reads a record written by encodeIndexRecord, returning
+// its size
+func readIndexRecord(r *bufio.Reader) (byte, string, []float32, int64, error) {
+	op, err := r.ReadByte()
+	if err != nil {
+		return 0, "", nil, 0, err
+	}
+	size := int64(1)
+
+	keyLen, err := binary.ReadUvarint(r)
+	if err != nil {
+		return 0, "", nil, 0, err
+	}
+	if keyLen > maxIndexKeyLen {
+		return 0, "", nil, 0, fmt.Errorf("index log record has a key of %d bytes", keyLen)
+	}
+	size += int64(uvarintLen(keyLen))
+	key := make([]byte, keyLen)
+	if _, err := io.ReadFull(r, key); err != nil {
+		return 0, "", nil, 0, err
+	}
+	size += int64(keyLen)
+
+	if op != indexLogAdd {
+		return op, string(key), nil, size, nil
+	}
+
+	dims, err := binary.ReadUvarint(r)
+	if err != nil {
+		return 0, "", nil, 0, err
+	}
+	if dims > maxIndexDimensions {
+		return 0, "", nil, 0, fmt.Errorf("index log record has %d dimensions", dims)
+	}
+	size += int64(uvarintLen(dims))
+	raw := make([]byte, 4*dims)
+	if _, err := io.ReadFull(r, raw); err != nil {
+		return 0, "", nil, 0, err
+	}
+	size += int64(len(raw))
+
+	vector := make([]float32, dims)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(raw[i*4:]))
+	}
+	return op, string(key), vector, size, nil
+}
+
+// uvarintLen returns the encoded length of x
+func uvarintLen(x uint64) int {
+	var buf [binary.MaxVarintLen64]byte
+	return binary.PutUvarint(buf[:], x)
+}
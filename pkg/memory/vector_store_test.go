@@ -0,0 +1,54 @@
+package memory
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/satishgonella2024/sentinelstacks/pkg/embedding"
+)
+
+func TestLocalVectorStoreSearch(t *testing.T) {
+	ctx := context.Background()
+	store := NewLocalVectorStore(embedding.NewHashEmbedder(128))
+
+	store.StoreVector(ctx, "cats", "cats like to sleep in the sun", nil)
+	store.StoreVector(ctx, "revenue", "quarterly revenue grew by ten percent", nil)
+
+	matches, err := store.SearchVector(ctx, "where do cats sleep", 1, nil)
+	if err != nil {
+		t.Fatalf("SearchVector failed: %v", err)
+	}
+	if len(matches) != 1 || matches[0].Key != "cats" {
+		t.Errorf("Expected the text about cats, got %+v", matches)
+	}
+}
+
+func TestSQLiteVectorStoreRejectsDimensionMismatch(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "vectors.db")
+
+	store, err := NewSQLiteVectorStore(path, embedding.NewHashEmbedder(64))
+	if err != nil {
+		t.Fatalf("NewSQLiteVectorStore failed: %v", err)
+	}
+	if err := store.StoreVector(ctx, "a", "some text", map[string]interface{}{"source": "test"}); err != nil {
+		t.Fatalf("StoreVector failed: %v", err)
+	}
+	matches, err := store.SearchVector(ctx, "some text", 5, nil)
+	if err != nil || len(matches) != 1 || matches[0].Score < 0.99 {
+		t.Errorf("Expected an exact match, got %+v, %v", matches, err)
+	}
+	store.Close()
+
+	// Reopening with an embedder of another dimensionality fails
+	if _, err := NewSQLiteVectorStore(path, embedding.NewHashEmbedder(32)); err == nil {
+		t.Error("Expected a dimension mismatch to be rejected")
+	}
+
+	store, err = NewSQLiteVectorStore(path, embedding.NewHashEmbedder(64))
+	if err != nil {
+		t.Fatalf("Reopening with the same dimensions failed: %v", err)
+	}
+	store.Close()
+}
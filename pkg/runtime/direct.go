@@ -0,0 +1,421 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/registry"
+	"github.com/satishgonella2024/sentinelstacks/pkg/types"
+)
+
+// Provider names understood by the LLM shims
+const (
+	providerClaude = "claude"
+	providerOpenAI = "openai"
+	providerOllama = "ollama"
+	providerGoogle = "google"
+	providerMock   = "mock"
+)
+
+// defaultModels maps providers to the model used when none is configured
+var defaultModels = map[string]string{
+	providerClaude: "claude-3-5-sonnet-20240627",
+	providerOpenAI: "gpt-4-turbo",
+	providerOllama: "llama3",
+	providerGoogle: "gemini-1.5-pro",
+	providerMock:   "mock-model",
+}
+
+const (
+	// defaultMaxTokens is used when neither the stack nor the image sets max_tokens
+	defaultMaxTokens = 1024
+
+	// defaultTemperature is used when neither the stack nor the image sets temperature
+	defaultTemperature = 0.7
+)
+
+// ImageResolver looks up agent images by name and tag
+type ImageResolver interface {
+	Get(name, tag string) (*registry.Image, error)
+}
+
+// LLMFactory creates the LLM client for the given provider settings. It
+// has the same shape as shim.ShimFactory, which is what the stack engine uses.
+type LLMFactory func(provider, endpoint, apiKey, model string) (types.CompletionClient, error)
+
+// DirectRuntimeOption configures a DirectRuntime
+type DirectRuntimeOption func(*DirectRuntime)
+
+// WithImageResolver sets the registry used to resolve agent images
+func WithImageResolver(resolver ImageResolver) DirectRuntimeOption {
+	return func(r *DirectRuntime) {
+		r.images = resolver
+	}
+}
+
+// WithLLMFactory sets the function used to create LLM clients
+func WithLLMFactory(factory LLMFactory) DirectRuntimeOption {
+	return func(r *DirectRuntime) {
+		r.llmFactory = factory
+	}
+}
+
+// DirectRuntime executes agents directly using the LLM provider
+type DirectRuntime struct {
+	verbose    bool
+	images     ImageResolver
+	llmFactory LLMFactory
+}
+
+// NewDirectRuntime creates a new direct runtime
+func NewDirectRuntime(verbose bool, options ...DirectRuntimeOption) (*DirectRuntime, error) {
+	r := &DirectRuntime{
+		verbose: verbose,
+	}
+
+	for _, option := range options {
+		option(r)
+	}
+
+	return r, nil
+}
+
+// Execute runs an agent with the provided inputs and returns its outputs
+func (r *DirectRuntime) Execute(ctx context.Context, agentSpec types.StackAgentSpec, inputs map[string]interface{}) (map[string]interface{}, error) {
+	if r.llmFactory == nil {
+		return nil, fmt.Errorf("no LLM factory configured for direct runtime")
+	}
+
+	// Resolve the agent image
+	image, err := r.resolveImage(agentSpec.Uses)
+	if err != nil {
+		return nil, err
+	}
+
+	// Work out which provider and model to call
+	provider, model := resolveModel(agentSpec, image)
+
+	llm, err := r.llmFactory(provider, os.Getenv("SENTINEL_LLM_ENDPOINT"), apiKeyForProvider(provider), model)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create LLM client for agent %s: %w", agentSpec.ID, err)
+	}
+	defer llm.Close()
+
+	// Build the prompt
+	systemPrompt := buildSystemPrompt(image)
+	prompt, err := buildPrompt(agentSpec, image, inputs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build prompt for agent %s: %w", agentSpec.ID, err)
+	}
+
+	llm.SetSystemPrompt(systemPrompt)
+
+	maxTokens := intParam(agentSpec, image, "max_tokens", defaultMaxTokens)
+	temperature := floatParam(agentSpec, image, "temperature", defaultTemperature)
+
+	if r.verbose {
+		log.Printf("Calling %s model %s for agent %s", provider, model, agentSpec.ID)
+	}
+
+	// Call the model
+	text, err := llm.CompletionWithContext(ctx, prompt, maxTokens, temperature)
+	if err != nil {
+		return nil, fmt.Errorf("LLM completion failed for agent %s: %w", agentSpec.ID, err)
+	}
+
+	// The shims do not report token usage, so it is estimated
+	promptTokens := estimateTokens(systemPrompt) + estimateTokens(prompt)
+	completionTokens := estimateTokens(text)
+
+	outputs := map[string]interface{}{
+		"text":       text,
+		"model":      model,
+		"provider":   provider,
+		"agent_id":   agentSpec.ID,
+		"agent_type": agentSpec.Uses,
+		"estimated_usage": map[string]interface{}{
+			"prompt_tokens":     promptTokens,
+			"completion_tokens": completionTokens,
+			"total_tokens":      promptTokens + completionTokens,
+		},
+	}
+
+	// Agents that answer in JSON get their structured result exposed as data
+	if data, ok := parseJSONObject(text); ok {
+		outputs["data"] = data
+	}
+
+	return outputs, nil
+}
+
+// Cleanup releases resources
+func (r *DirectRuntime) Cleanup() error {
+	return nil
+}
+
+// resolveImage finds the image referenced by an agent's uses field
+func (r *DirectRuntime) resolveImage(ref string) (*registry.Image, error) {
+	if ref == "" {
+		return nil, fmt.Errorf("agent does not specify an image to use")
+	}
+
+	if r.images == nil {
+		localRegistry, err := registry.GetLocalRegistry()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open local image registry: %w", err)
+		}
+		r.images = localRegistry
+	}
+
+	name, tag := splitImageRef(ref)
+	image, err := r.images.Get(name, tag)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve image %s: %w", ref, err)
+	}
+
+	return image, nil
+}
+
+// splitImageRef splits an image reference such as user/name:tag into name and tag
+func splitImageRef(ref string) (string, string) {
+	lastColon := strings.LastIndex(ref, ":")
+	if lastColon > strings.LastIndex(ref, "/") {
+		return ref[:lastColon], ref[lastColon+1:]
+	}
+	return ref, "latest"
+}
+
+// resolveModel determines the provider and model for an agent. Stack
+// parameters take precedence over image parameters, which take precedence
+// over the image base model and environment defaults.
+func resolveModel(agentSpec types.StackAgentSpec, image *registry.Image) (string, string) {
+	provider := stringParam(agentSpec, image, "provider")
+	model := stringParam(agentSpec, image, "model")
+
+	if model == "" {
+		model = image.Definition.BaseModel
+	}
+
+	if provider == "" {
+		provider = providerForModel(model)
+	}
+
+	if model == "" {
+		model = os.Getenv("SENTINEL_LLM_MODEL")
+	}
+	if model == "" {
+		model = defaultModels[provider]
+	}
+
+	return provider, model
+}
+
+// providerForModel infers the LLM provider from a model name
+func providerForModel(model string) string {
+	switch {
+	case strings.HasPrefix(model, "claude"):
+		return providerClaude
+	case strings.HasPrefix(model, "gpt"):
+		return providerOpenAI
+	case strings.HasPrefix(model, "gemini"):
+		return providerGoogle
+	case strings.HasPrefix(model, "llama"), strings.HasPrefix(model, "mistral"):
+		return providerOllama
+	case strings.HasPrefix(model, "mock"):
+		return providerMock
+	}
+
+	if provider := os.Getenv("SENTINEL_LLM_PROVIDER"); provider != "" {
+		return provider
+	}
+	return providerClaude
+}
+
+// apiKeyForProvider returns the API key configured for a provider
+func apiKeyForProvider(provider string) string {
+	var key string
+	switch provider {
+	case providerClaude:
+		key = os.Getenv("ANTHROPIC_API_KEY")
+	case providerOpenAI:
+		key = os.Getenv("OPENAI_API_KEY")
+	case providerGoogle:
+		key = os.Getenv("GOOGLE_API_KEY")
+	}
+
+	if key == "" {
+		key = os.Getenv("SENTINEL_API_KEY")
+	}
+	return key
+}
+
+// buildSystemPrompt describes the agent to the model using its image definition
+func buildSystemPrompt(image *registry.Image) string {
+	def := image.Definition
+
+	if prompt, ok := def.Parameters["system_prompt"].(string); ok && prompt != "" {
+		return prompt
+	}
+	if prompt, ok := def.Parameters["systemPrompt"].(string); ok && prompt != "" {
+		return prompt
+	}
+
+	var sb strings.Builder
+	name := def.Name
+	if name == "" {
+		name = image.Name
+	}
+	sb.WriteString(fmt.Sprintf("You are %s", name))
+	if def.Description != "" {
+		sb.WriteString(fmt.Sprintf(", %s", def.Description))
+	}
+	sb.WriteString(".\n")
+
+	if len(def.Capabilities) > 0 {
+		sb.WriteString(fmt.Sprintf("Your capabilities: %s.\n", strings.Join(def.Capabilities, ", ")))
+	}
+	if len(def.Tools) > 0 {
+		sb.WriteString(fmt.Sprintf("Tools available to you: %s.\n", strings.Join(def.Tools, ", ")))
+	}
+
+	sb.WriteString("You are one step in a multi-agent pipeline. Respond only with the result of your task.")
+
+	return sb.String()
+}
+
+// buildPrompt renders the user prompt for an agent from its inputs
+func buildPrompt(agentSpec types.StackAgentSpec, image *registry.Image, inputs map[string]interface{}) (string, error) {
+	var sb strings.Builder
+
+	if task := stringParam(agentSpec, image, "prompt"); task != "" {
+		sb.WriteString(task)
+		sb.WriteString("\n\n")
+	}
+
+	if len(inputs) > 0 {
+		sb.WriteString("Inputs:\n")
+
+		// Sort keys so the prompt is stable between runs
+		keys := make([]string, 0, len(inputs))
+		for k := range inputs {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			switch v := inputs[k].(type) {
+			case string:
+				sb.WriteString(fmt.Sprintf("%s: %s\n", k, v))
+			default:
+				data, err := json.MarshalIndent(v, "", "  ")
+				if err != nil {
+					return "", fmt.Errorf("failed to encode input %s: %w", k, err)
+				}
+				sb.WriteString(fmt.Sprintf("%s: %s\n", k, data))
+			}
+		}
+	}
+
+	// A stack that validates the output passes its schema along
+	if schema, ok := lookupParam(agentSpec, image, "output_schema"); ok {
+		data, err := json.MarshalIndent(schema, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("failed to encode output schema: %w", err)
+		}
+		sb.WriteString(fmt.Sprintf("\nRespond with a single JSON value that matches this JSON Schema:\n%s\n", data))
+	} else if format := stringParam(agentSpec, image, "output_format"); format == "json" {
+		sb.WriteString("\nRespond with a single JSON object.")
+	}
+
+	// Feedback on a previous answer, such as schema validation errors
+	if feedback := stringParam(agentSpec, image, "feedback"); feedback != "" {
+		sb.WriteString("\n\n")
+		sb.WriteString(feedback)
+	}
+
+	return strings.TrimSpace(sb.String()), nil
+}
+
+// lookupParam returns a parameter from the stack spec or, failing that, the image
+func lookupParam(agentSpec types.StackAgentSpec, image *registry.Image, key string) (interface{}, bool) {
+	if v, ok := agentSpec.With[key]; ok {
+		return v, true
+	}
+	if v, ok := image.Definition.Parameters[key]; ok {
+		return v, true
+	}
+	return nil, false
+}
+
+// stringParam returns a string parameter or an empty string
+func stringParam(agentSpec types.StackAgentSpec, image *registry.Image, key string) string {
+	if v, ok := lookupParam(agentSpec, image, key); ok {
+		if s, ok := v.(string); ok {
+			return s
+		}
+	}
+	return ""
+}
+
+// intParam returns a numeric parameter as an int or the default
+func intParam(agentSpec types.StackAgentSpec, image *registry.Image, key string, def int) int {
+	if v, ok := lookupParam(agentSpec, image, key); ok {
+		switch n := v.(type) {
+		case int:
+			return n
+		case int64:
+			return int(n)
+		case float64:
+			return int(n)
+		}
+	}
+	return def
+}
+
+// floatParam returns a numeric parameter as a float64 or the default
+func floatParam(agentSpec types.StackAgentSpec, image *registry.Image, key string, def float64) float64 {
+	if v, ok := lookupParam(agentSpec, image, key); ok {
+		switch n := v.(type) {
+		case float64:
+			return n
+		case int:
+			return float64(n)
+		case int64:
+			return float64(n)
+		}
+	}
+	return def
+}
+
+// estimateTokens approximates the token count of a string using the common
+// heuristic of four characters per token
+func estimateTokens(s string) int {
+	if s == "" {
+		return 0
+	}
+	return (len(s) + 3) / 4
+}
+
+// parseJSONObject parses text as a JSON object, tolerating Markdown code fences
+func parseJSONObject(text string) (map[string]interface{}, bool) {
+	trimmed := strings.TrimSpace(text)
+	trimmed = strings.TrimPrefix(trimmed, "```json")
+	trimmed = strings.TrimPrefix(trimmed, "```")
+	trimmed = strings.TrimSuffix(trimmed, "```")
+	trimmed = strings.TrimSpace(trimmed)
+
+	if !strings.HasPrefix(trimmed, "{") {
+		return nil, false
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(trimmed), &data); err != nil {
+		return nil, false
+	}
+	return data, true
+}
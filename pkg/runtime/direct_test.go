@@ -0,0 +1,110 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/registry"
+	"github.com/satishgonella2024/sentinelstacks/internal/shim"
+	"github.com/satishgonella2024/sentinelstacks/pkg/types"
+)
+
+// staticImages resolves images from an in-memory map keyed by name:tag
+type staticImages map[string]*registry.Image
+
+func (s staticImages) Get(name, tag string) (*registry.Image, error) {
+	image, ok := s[name+":"+tag]
+	if !ok {
+		return nil, fmt.Errorf("image %s:%s not found", name, tag)
+	}
+	return image, nil
+}
+
+func TestDirectRuntimeExecute(t *testing.T) {
+	images := staticImages{
+		"team/summarizer:1.0": {
+			Name: "team/summarizer",
+			Tag:  "1.0",
+			Definition: registry.ImageDefinition{
+				Name:         "summarizer",
+				Description:  "summarizes research notes",
+				BaseModel:    "mock-model",
+				Capabilities: []string{"summarization"},
+			},
+		},
+	}
+
+	var gotProvider, gotModel string
+	factory := func(provider, endpoint, apiKey, model string) (types.CompletionClient, error) {
+		gotProvider, gotModel = provider, model
+		return shim.ShimFactory(provider, endpoint, apiKey, model)
+	}
+
+	rt, err := NewDirectRuntime(false, WithImageResolver(images), WithLLMFactory(factory))
+	if err != nil {
+		t.Fatalf("Failed to create runtime: %v", err)
+	}
+
+	spec := types.StackAgentSpec{
+		ID:   "summary",
+		Uses: "team/summarizer:1.0",
+		With: map[string]interface{}{"prompt": "Summarize the notes"},
+	}
+
+	outputs, err := rt.Execute(context.Background(), spec, map[string]interface{}{"notes": "alpha beta"})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	if gotProvider != providerMock || gotModel != "mock-model" {
+		t.Errorf("Expected mock/mock-model, got %s/%s", gotProvider, gotModel)
+	}
+
+	text, _ := outputs["text"].(string)
+	if !strings.Contains(text, "Summarize the notes") || !strings.Contains(text, "notes: alpha beta") {
+		t.Errorf("Expected prompt and inputs in completion, got %q", text)
+	}
+	if !strings.Contains(text, "summarizes research notes") {
+		t.Errorf("Expected system prompt built from definition, got %q", text)
+	}
+
+	if outputs["model"] != "mock-model" {
+		t.Errorf("Expected model mock-model, got %v", outputs["model"])
+	}
+
+	usage, ok := outputs["estimated_usage"].(map[string]interface{})
+	if !ok || usage["total_tokens"].(int) <= 0 {
+		t.Errorf("Expected estimated token usage in outputs, got %v", outputs["estimated_usage"])
+	}
+}
+
+func TestDirectRuntimeUnknownImage(t *testing.T) {
+	factory := func(provider, endpoint, apiKey, model string) (types.CompletionClient, error) {
+		return shim.ShimFactory(provider, endpoint, apiKey, model)
+	}
+	rt, _ := NewDirectRuntime(false, WithImageResolver(staticImages{}), WithLLMFactory(factory))
+
+	_, err := rt.Execute(context.Background(), types.StackAgentSpec{ID: "a", Uses: "missing"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "failed to resolve image missing") {
+		t.Errorf("Expected image resolution error, got %v", err)
+	}
+}
+
+func TestSplitImageRef(t *testing.T) {
+	cases := map[string][2]string{
+		"agent":                  {"agent", "latest"},
+		"agent:1.2":              {"agent", "1.2"},
+		"user/agent:v1":          {"user/agent", "v1"},
+		"localhost:5000/agent":   {"localhost:5000/agent", "latest"},
+		"localhost:5000/agent:2": {"localhost:5000/agent", "2"},
+	}
+
+	for ref, want := range cases {
+		name, tag := splitImageRef(ref)
+		if name != want[0] || tag != want[1] {
+			t.Errorf("splitImageRef(%q) = %s, %s; want %s, %s", ref, name, tag, want[0], want[1])
+		}
+	}
+}
@@ -10,13 +10,16 @@ import (
 
 // SimpleFactory creates agent runtimes for execution
 type SimpleFactory struct {
-	verbose bool
+	verbose       bool
+	directOptions []DirectRuntimeOption
 }
 
-// NewRuntimeFactory creates a new runtime factory
-func NewRuntimeFactory(verbose bool) *SimpleFactory {
+// NewRuntimeFactory creates a new runtime factory. The options are applied
+// to every direct runtime the factory creates.
+func NewRuntimeFactory(verbose bool, directOptions ...DirectRuntimeOption) *SimpleFactory {
 	return &SimpleFactory{
-		verbose: verbose,
+		verbose:       verbose,
+		directOptions: directOptions,
 	}
 }
 
@@ -24,7 +27,7 @@ func NewRuntimeFactory(verbose bool) *SimpleFactory {
 func (f *SimpleFactory) CreateRuntime(runtimeType types.RuntimeType) (types.AgentRuntime, error) {
 	switch runtimeType {
 	case types.RuntimeTypeDirect:
-		return NewDirectRuntime(f.verbose)
+		return NewDirectRuntime(f.verbose, f.directOptions...)
 	case types.RuntimeTypeCli:
 		return NewCliRuntime(f.verbose)
 	default:
@@ -35,43 +38,7 @@ func (f *SimpleFactory) CreateRuntime(runtimeType types.RuntimeType) (types.Agen
 // DefaultRuntime creates the default agent runtime based on environment
 func (f *SimpleFactory) DefaultRuntime() (types.AgentRuntime, error) {
 	// Use direct runtime as default
-	return NewDirectRuntime(f.verbose)
-}
-
-// DirectRuntime executes agents directly using the LLM provider
-type DirectRuntime struct {
-	verbose bool
-}
-
-// NewDirectRuntime creates a new direct runtime
-func NewDirectRuntime(verbose bool) (*DirectRuntime, error) {
-	return &DirectRuntime{
-		verbose: verbose,
-	}, nil
-}
-
-// Execute runs an agent with the provided inputs and returns its outputs
-func (r *DirectRuntime) Execute(ctx context.Context, agentSpec types.StackAgentSpec, inputs map[string]interface{}) (map[string]interface{}, error) {
-	// In a real implementation, this would use the agent provider to execute the agent
-	// For now, we'll create a simple mock implementation
-	outputs := make(map[string]interface{})
-
-	// Copy inputs to outputs
-	for k, v := range inputs {
-		outputs[k] = v
-	}
-
-	// Add some generated outputs
-	outputs["status"] = "success"
-	outputs["agent_id"] = agentSpec.ID
-	outputs["agent_type"] = agentSpec.Uses
-
-	return outputs, nil
-}
-
-// Cleanup releases resources
-func (r *DirectRuntime) Cleanup() error {
-	return nil
+	return NewDirectRuntime(f.verbose, f.directOptions...)
 }
 
 // CliRuntime executes agents using the CLI
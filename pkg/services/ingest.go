@@ -0,0 +1,312 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/memory"
+	"github.com/satishgonella2024/sentinelstacks/pkg/ingest"
+	"github.com/satishgonella2024/sentinelstacks/pkg/types"
+)
+
+// IngestOptions configures how documents are ingested
+type IngestOptions struct {
+	// Chunks configures how documents are split
+	Chunks ingest.ChunkConfig
+	// Force re-embeds documents even when they are unchanged
+	Force bool
+	// Embedding names the embedder's provider and model. Documents
+	// embedded by another provider, model or dimensions are re-embedded.
+	Embedding types.EmbeddingConfig
+}
+
+// IngestResult reports what ingesting a document did
+type IngestResult struct {
+	// Unchanged is whether the document was skipped, having been ingested
+	// before with the same content and chunking
+	Unchanged bool
+	// Stored is how many chunks were embedded and stored
+	Stored int
+	// Kept is how many chunks were already stored
+	Kept int
+	// Removed is how many chunks of an earlier version were removed
+	Removed int
+}
+
+// ingestRecord is what was last ingested from a document, kept to make
+// ingesting it again incremental
+type ingestRecord struct {
+	Source       string    `json:"source"`
+	Hash         string    `json:"hash"`
+	ChunkSize    int       `json:"chunkSize"`
+	ChunkOverlap int       `json:"chunkOverlap"`
+	IngestedAt   time.Time `json:"ingestedAt"`
+	// Embedding is the embedder the chunks were embedded with
+	Embedding ingestEmbedding `json:"embedding"`
+	// Chunks maps the IDs of the chunks stored to their offsets
+	Chunks map[string]int `json:"chunks"`
+}
+
+// ingestEmbedding identifies an embedder; embeddings of different ones
+// cannot be compared
+type ingestEmbedding struct {
+	Provider   string `json:"provider"`
+	Model      string `json:"model"`
+	Dimensions int    `json:"dimensions"`
+}
+
+// IngestDocument splits a document into chunks, embeds them and stores them
+// in the agent's vector store with their text, source and offset as
+// metadata. A document ingested before with the same content and chunking
+// is skipped; of a changed one, only new chunks are embedded, chunks that
+// moved have their offset updated and those no longer in it are removed.
+// A document embedded by another embedder is embedded again in full.
+func (s *MemoryService) IngestDocument(ctx context.Context, agentID string, doc *ingest.Document, embedder types.Embedder, options IngestOptions) (*IngestResult, error) {
+	record, err := loadIngestRecord(agentID, doc.Source)
+	if err != nil {
+		return nil, err
+	}
+	embedding := ingestEmbedding{
+		Provider:   options.Embedding.Provider,
+		Model:      options.Embedding.Model,
+		Dimensions: embedder.Dimensions(),
+	}
+	reuse := !options.Force && record != nil && record.Embedding == embedding
+	if reuse && record.Hash == doc.Hash &&
+		record.ChunkSize == options.Chunks.Size && record.ChunkOverlap == options.Chunks.Overlap {
+		return &IngestResult{Unchanged: true, Kept: len(record.Chunks)}, nil
+	}
+
+	chunks, err := ingest.Split(doc.Text, options.Chunks)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &IngestResult{}
+	updated := &ingestRecord{
+		Source:       doc.Source,
+		Hash:         doc.Hash,
+		ChunkSize:    options.Chunks.Size,
+		ChunkOverlap: options.Chunks.Overlap,
+		Embedding:    embedding,
+		IngestedAt:   time.Now(),
+		Chunks:       make(map[string]int, len(chunks)),
+	}
+
+	// Chunks whose text was stored before keep their embedding; only the
+	// offset of those that moved is updated
+	var pending []string
+	var pendingChunks []ingest.Chunk
+	for _, chunk := range chunks {
+		id := chunkID(doc.Source, chunk.Text, updated.Chunks)
+		updated.Chunks[id] = chunk.Offset
+		if reuse {
+			if offset, ok := record.Chunks[id]; ok {
+				if offset != chunk.Offset {
+					if err := s.UpdateEmbeddingMetadata(ctx, agentID, id, chunkMetadata(doc, chunk)); err != nil {
+						return nil, err
+					}
+				}
+				result.Kept++
+				continue
+			}
+		}
+		pending = append(pending, id)
+		pendingChunks = append(pendingChunks, chunk)
+	}
+
+	if len(pendingChunks) > 0 {
+		texts := make([]string, len(pendingChunks))
+		for i, chunk := range pendingChunks {
+			texts[i] = chunk.Text
+		}
+		embeddings, err := embedder.Embed(ctx, texts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to embed %s: %w", doc.Source, err)
+		}
+		if len(embeddings) != len(texts) {
+			return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(embeddings))
+		}
+
+		for i, chunk := range pendingChunks {
+			if err := s.SaveEmbedding(ctx, agentID, pending[i], embeddings[i], chunkMetadata(doc, chunk)); err != nil {
+				return nil, err
+			}
+			result.Stored++
+		}
+	}
+
+	if record != nil {
+		for id := range record.Chunks {
+			if _, ok := updated.Chunks[id]; ok {
+				continue
+			}
+			if err := s.DeleteEmbedding(ctx, agentID, id); err != nil {
+				return nil, err
+			}
+			result.Removed++
+		}
+	}
+
+	if err := saveIngestRecord(agentID, updated); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ForgetDocument removes the chunks ingested from source from the agent's
+// vector store, returning how many were removed
+func (s *MemoryService) ForgetDocument(ctx context.Context, agentID, source string) (int, error) {
+	record, err := loadIngestRecord(agentID, source)
+	if err != nil || record == nil {
+		return 0, err
+	}
+
+	for id := range record.Chunks {
+		if err := s.DeleteEmbedding(ctx, agentID, id); err != nil {
+			return 0, err
+		}
+	}
+
+	path, err := ingestRecordPath(agentID, source)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return 0, fmt.Errorf("failed to remove ingest record: %w", err)
+	}
+	return len(record.Chunks), nil
+}
+
+// IngestedDocuments returns the sources of the documents ingested into the
+// agent's memory
+func (s *MemoryService) IngestedDocuments(ctx context.Context, agentID string) ([]string, error) {
+	dir, err := ingestDir(agentID)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ingest records: %w", err)
+	}
+
+	var sources []string
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		record, err := readIngestRecord(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, record.Source)
+	}
+	sort.Strings(sources)
+	return sources, nil
+}
+
+// chunkID returns the ID of a chunk of source, from its text. Repeats of a
+// text in the same document, already in ids, are numbered.
+func chunkID(source, text string, ids map[string]int) string {
+	sum := sha256.Sum256([]byte(text))
+	base := fmt.Sprintf("%s#%s", source, hex.EncodeToString(sum[:8]))
+	id := base
+	for n := 2; ; n++ {
+		if _, ok := ids[id]; !ok {
+			return id
+		}
+		id = fmt.Sprintf("%s-%d", base, n)
+	}
+}
+
+// chunkMetadata returns the metadata stored with a chunk's embedding
+func chunkMetadata(doc *ingest.Document, chunk ingest.Chunk) map[string]interface{} {
+	return map[string]interface{}{
+		"text":   chunk.Text,
+		"source": doc.Source,
+		"offset": chunk.Offset,
+		"format": doc.Format,
+	}
+}
+
+// ingestDir returns the directory of the agent's ingest records
+func ingestDir(agentID string) (string, error) {
+	memoryPath, err := memory.DefaultMemoryPath()
+	if err != nil {
+		return "", fmt.Errorf("failed to get memory path: %w", err)
+	}
+	return filepath.Join(memoryPath, "ingest", url.PathEscape(agentID)), nil
+}
+
+// ingestRecordPath returns the path of the record of source
+func ingestRecordPath(agentID, source string) (string, error) {
+	dir, err := ingestDir(agentID)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(source))
+	return filepath.Join(dir, hex.EncodeToString(sum[:16])+".json"), nil
+}
+
+// loadIngestRecord returns the record of source, or nil when it was never
+// ingested
+func loadIngestRecord(agentID, source string) (*ingestRecord, error) {
+	path, err := ingestRecordPath(agentID, source)
+	if err != nil {
+		return nil, err
+	}
+	record, err := readIngestRecord(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return record, err
+}
+
+// readIngestRecord reads the record at path
+func readIngestRecord(path string) (*ingestRecord, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var record ingestRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("failed to parse ingest record %s: %w", path, err)
+	}
+	return &record, nil
+}
+
+// saveIngestRecord writes the record of a document, replacing the last
+func saveIngestRecord(agentID string, record *ingestRecord) error {
+	path, err := ingestRecordPath(agentID, record.Source)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create ingest directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize ingest record: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write ingest record: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write ingest record: %w", err)
+	}
+	return nil
+}
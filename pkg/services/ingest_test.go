@@ -0,0 +1,230 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/satishgonella2024/sentinelstacks/internal/memory"
+	"github.com/satishgonella2024/sentinelstacks/pkg/ingest"
+	"github.com/satishgonella2024/sentinelstacks/pkg/types"
+)
+
+// localVectorFactory creates in-memory vector stores, whatever the type
+type localVectorFactory struct{}
+
+func (localVectorFactory) Create(storeType memory.MemoryStoreType, config memory.MemoryConfig) (memory.MemoryStore, error) {
+	return nil, fmt.Errorf("memory stores are not used by ingestion")
+}
+
+func (localVectorFactory) CreateVector(storeType memory.MemoryStoreType, config memory.MemoryConfig) (memory.VectorStore, error) {
+	return memory.NewLocalVectorStore(config)
+}
+
+// countingEmbedder embeds texts as their length and records them
+type countingEmbedder struct {
+	texts      []string
+	dimensions int
+}
+
+func (e *countingEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	e.texts = append(e.texts, texts...)
+	embeddings := make([][]float32, len(texts))
+	for i, text := range texts {
+		embeddings[i] = []float32{float32(len(text)), 1}
+	}
+	return embeddings, nil
+}
+
+func (e *countingEmbedder) Dimensions() int {
+	if e.dimensions == 0 {
+		return 2
+	}
+	return e.dimensions
+}
+
+// document returns a document of paragraphs that each fit in one chunk
+func document(paragraphs ...string) *ingest.Document {
+	text := strings.Join(paragraphs, "\n\n")
+	return &ingest.Document{Source: "/docs/runbook.md", Format: ingest.FormatText, Hash: fmt.Sprintf("%x", text), Text: text}
+}
+
+// storedChunks returns the offset stored with each chunk of the runbook, by text
+func storedChunks(t *testing.T, service *MemoryService) map[string]interface{} {
+	t.Helper()
+	ctx := context.Background()
+	store, err := service.GetVectorStore(ctx, "agent", memory.MemoryStoreTypeChroma)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record, err := loadIngestRecord("agent", "/docs/runbook.md")
+	if err != nil || record == nil {
+		t.Fatalf("No ingest record: %v", err)
+	}
+
+	offsets := make(map[string]interface{})
+	for id := range record.Chunks {
+		_, metadata, err := store.(*memory.LocalVectorStore).GetVector(ctx, id)
+		if err != nil {
+			t.Fatalf("Chunk %s is recorded but not stored: %v", id, err)
+		}
+		offsets[metadata["text"].(string)] = metadata["offset"]
+	}
+	return offsets
+}
+
+func TestIngestDocument(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ctx := context.Background()
+	service := NewMemoryService(localVectorFactory{})
+	options := IngestOptions{Chunks: ingest.ChunkConfig{Size: 40}}
+
+	alpha := "Alpha explains the first step."
+	beta := "Beta explains the second step."
+	gamma := "Gamma explains the third step."
+	intro := "Intro says what the runbook is."
+
+	steps := []struct {
+		name     string
+		doc      *ingest.Document
+		force    bool
+		expected IngestResult
+		embedded []string
+		offsets  map[string]interface{}
+	}{
+		{
+			name:     "first ingest",
+			doc:      document(alpha, beta, gamma),
+			expected: IngestResult{Stored: 3},
+			embedded: []string{alpha, beta, gamma},
+			offsets:  map[string]interface{}{alpha: 0, beta: 32, gamma: 64},
+		},
+		{
+			name:     "unchanged document",
+			doc:      document(alpha, beta, gamma),
+			expected: IngestResult{Unchanged: true, Kept: 3},
+			offsets:  map[string]interface{}{alpha: 0, beta: 32, gamma: 64},
+		},
+		{
+			name:     "moved chunks are kept",
+			doc:      document(intro, alpha, beta),
+			expected: IngestResult{Stored: 1, Kept: 2, Removed: 1},
+			embedded: []string{intro},
+			offsets:  map[string]interface{}{intro: 0, alpha: 33, beta: 65},
+		},
+		{
+			name:     "forced",
+			doc:      document(intro, alpha, beta),
+			force:    true,
+			expected: IngestResult{Stored: 3},
+			embedded: []string{intro, alpha, beta},
+			offsets:  map[string]interface{}{intro: 0, alpha: 33, beta: 65},
+		},
+	}
+
+	for _, step := range steps {
+		embedder := &countingEmbedder{}
+		options.Force = step.force
+		result, err := service.IngestDocument(ctx, "agent", step.doc, embedder, options)
+		if err != nil {
+			t.Fatalf("%s: IngestDocument failed: %v", step.name, err)
+		}
+		if *result != step.expected {
+			t.Errorf("%s: expected %+v, got %+v", step.name, step.expected, *result)
+		}
+		if !reflect.DeepEqual(embedder.texts, step.embedded) {
+			t.Errorf("%s: expected to embed %q, embedded %q", step.name, step.embedded, embedder.texts)
+		}
+		if offsets := storedChunks(t, service); !reflect.DeepEqual(offsets, step.offsets) {
+			t.Errorf("%s: expected stored offsets %v, got %v", step.name, step.offsets, offsets)
+		}
+	}
+
+	sources, err := service.IngestedDocuments(ctx, "agent")
+	if err != nil || !reflect.DeepEqual(sources, []string{"/docs/runbook.md"}) {
+		t.Errorf("Expected the runbook to be listed, got %v (%v)", sources, err)
+	}
+
+	removed, err := service.ForgetDocument(ctx, "agent", "/docs/runbook.md")
+	if err != nil || removed != 3 {
+		t.Errorf("Expected 3 chunks to be forgotten, got %d (%v)", removed, err)
+	}
+	if sources, _ := service.IngestedDocuments(ctx, "agent"); len(sources) != 0 {
+		t.Errorf("Expected no documents after forgetting, got %v", sources)
+	}
+}
+
+func TestIngestDocumentRepeatedChunks(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ctx := context.Background()
+	service := NewMemoryService(localVectorFactory{})
+	options := IngestOptions{Chunks: ingest.ChunkConfig{Size: 40}}
+
+	notice := "See the on-call rota for contacts."
+	step := "Restart the worker and wait."
+	if _, err := service.IngestDocument(ctx, "agent", document(notice, step, notice), &countingEmbedder{}, options); err != nil {
+		t.Fatalf("IngestDocument failed: %v", err)
+	}
+
+	// Dropping the first copy moves the second into its place
+	embedder := &countingEmbedder{}
+	result, err := service.IngestDocument(ctx, "agent", document(step, notice), embedder, options)
+	if err != nil {
+		t.Fatalf("IngestDocument failed: %v", err)
+	}
+	if expected := (IngestResult{Kept: 2, Removed: 1}); *result != expected {
+		t.Errorf("Expected %+v, got %+v", expected, *result)
+	}
+	if len(embedder.texts) != 0 {
+		t.Errorf("Expected nothing to be embedded, embedded %q", embedder.texts)
+	}
+	if offsets, expected := storedChunks(t, service), map[string]interface{}{step: 0, notice: 30}; !reflect.DeepEqual(offsets, expected) {
+		t.Errorf("Expected stored offsets %v, got %v", expected, offsets)
+	}
+}
+
+func TestIngestDocumentEmbedderChange(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ctx := context.Background()
+	service := NewMemoryService(localVectorFactory{})
+	doc := document("Alpha explains the first step.", "Beta explains the second step.")
+
+	steps := []struct {
+		name       string
+		embedding  types.EmbeddingConfig
+		dimensions int
+		expected   IngestResult
+	}{
+		{"first ingest", types.EmbeddingConfig{Provider: "ollama", Model: "nomic-embed-text"}, 0, IngestResult{Stored: 2}},
+		{"same embedder", types.EmbeddingConfig{Provider: "ollama", Model: "nomic-embed-text"}, 0, IngestResult{Unchanged: true, Kept: 2}},
+		{"other model", types.EmbeddingConfig{Provider: "ollama", Model: "mxbai-embed-large"}, 0, IngestResult{Stored: 2}},
+		{"other provider", types.EmbeddingConfig{Provider: "openai", Model: "mxbai-embed-large"}, 0, IngestResult{Stored: 2}},
+		{"other dimensions", types.EmbeddingConfig{Provider: "openai", Model: "mxbai-embed-large"}, 3, IngestResult{Stored: 2}},
+		{"unchanged again", types.EmbeddingConfig{Provider: "openai", Model: "mxbai-embed-large"}, 3, IngestResult{Unchanged: true, Kept: 2}},
+	}
+
+	for _, step := range steps {
+		embedder := &countingEmbedder{dimensions: step.dimensions}
+		options := IngestOptions{Chunks: ingest.ChunkConfig{Size: 40}, Embedding: step.embedding}
+		result, err := service.IngestDocument(ctx, "agent", doc, embedder, options)
+		if err != nil {
+			t.Fatalf("%s: IngestDocument failed: %v", step.name, err)
+		}
+		if *result != step.expected {
+			t.Errorf("%s: expected %+v, got %+v", step.name, step.expected, *result)
+		}
+		if len(embedder.texts) != step.expected.Stored {
+			t.Errorf("%s: expected %d chunks to be embedded, embedded %q", step.name, step.expected.Stored, embedder.texts)
+		}
+	}
+
+	record, err := loadIngestRecord("agent", "/docs/runbook.md")
+	if err != nil || record == nil {
+		t.Fatalf("No ingest record: %v", err)
+	}
+	if expected := (ingestEmbedding{Provider: "openai", Model: "mxbai-embed-large", Dimensions: 3}); record.Embedding != expected {
+		t.Errorf("Expected the record to name embedder %+v, got %+v", expected, record.Embedding)
+	}
+}
@@ -2,6 +2,7 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -151,7 +152,7 @@ func (s *MemoryService) LoadAgentState(ctx context.Context, agentID string) (map
 	stateValue, err := store.Load(ctx, "state")
 	if err != nil {
 		// Return empty state if not found
-		if err.Error() == "key not found: state" {
+		if errors.Is(err, memory.ErrKeyNotFound) {
 			return make(map[string]interface{}), nil
 		}
 		return nil, fmt.Errorf("failed to load state: %w", err)
@@ -183,6 +184,40 @@ func (s *MemoryService) SaveEmbedding(ctx context.Context, agentID, documentID s
 	return nil
 }
 
+// UpdateEmbeddingMetadata replaces the metadata of a stored embedding
+func (s *MemoryService) UpdateEmbeddingMetadata(ctx context.Context, agentID, documentID string, metadata map[string]interface{}) error {
+	// Get vector store
+	store, err := s.GetVectorStore(ctx, agentID, memory.MemoryStoreTypeChroma)
+	if err != nil {
+		return fmt.Errorf("failed to get vector store: %w", err)
+	}
+	
+	// Update metadata
+	err = store.UpdateMetadata(ctx, documentID, metadata)
+	if err != nil {
+		return fmt.Errorf("failed to update embedding metadata: %w", err)
+	}
+	
+	return nil
+}
+
+// DeleteEmbedding deletes an embedding
+func (s *MemoryService) DeleteEmbedding(ctx context.Context, agentID, documentID string) error {
+	// Get vector store
+	store, err := s.GetVectorStore(ctx, agentID, memory.MemoryStoreTypeChroma)
+	if err != nil {
+		return fmt.Errorf("failed to get vector store: %w", err)
+	}
+	
+	// Delete embedding
+	err = store.DeleteEmbedding(ctx, documentID)
+	if err != nil {
+		return fmt.Errorf("failed to delete embedding: %w", err)
+	}
+	
+	return nil
+}
+
 // SearchSimilar searches for similar documents
 func (s *MemoryService) SearchSimilar(ctx context.Context, agentID string, queryEmbedding []float32, topK int) ([]memory.SimilarityMatch, error) {
 	// Get vector store
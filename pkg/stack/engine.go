@@ -9,6 +9,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/satishgonella2024/sentinelstacks/internal/shim"
 	"github.com/satishgonella2024/sentinelstacks/pkg/runtime"
 	"github.com/satishgonella2024/sentinelstacks/pkg/types"
 )
@@ -23,6 +24,8 @@ type Engine struct {
 	cancel    context.CancelFunc
 	runID     string
 	isRunning bool
+	// llmFactory creates the LLM clients of direct runtimes
+	llmFactory runtime.LLMFactory
 }
 
 // NewEngine creates a new stack execution engine
@@ -41,13 +44,14 @@ func NewEngine(spec types.StackSpec, options ...EngineOption) (*Engine, error) {
 
 	// Create engine with defaults
 	engine := &Engine{
-		spec:      spec,
-		dag:       dag,
-		ctx:       ctx,
-		cancel:    cancel,
-		runID:     runID,
-		isRunning: false,
-		verbose:   false,
+		spec:       spec,
+		dag:        dag,
+		ctx:        ctx,
+		cancel:     cancel,
+		runID:      runID,
+		isRunning:  false,
+		verbose:    false,
+		llmFactory: newLLMClient,
 	}
 
 	// Apply options
@@ -75,6 +79,14 @@ func WithRunID(runID string) EngineOption {
 	}
 }
 
+// WithLLMFactory sets the function used to create LLM clients, in place of
+// the provider shims
+func WithLLMFactory(factory runtime.LLMFactory) EngineOption {
+	return func(e *Engine) {
+		e.llmFactory = factory
+	}
+}
+
 // ExecuteOptions defines options for executing a stack
 type ExecuteOptions struct {
 	Timeout     int
@@ -232,7 +244,7 @@ func (e *Engine) executeAgent(ctx context.Context, agentSpec types.StackAgentSpe
 	}
 
 	// Create agent runtime factory
-	factory := runtime.NewRuntimeFactory(e.verbose)
+	factory := runtime.NewRuntimeFactory(e.verbose, runtime.WithLLMFactory(e.llmFactory))
 
 	// Create the runtime
 	agentRuntime, err := factory.CreateRuntime(runtimeType)
@@ -250,6 +262,11 @@ func (e *Engine) executeAgent(ctx context.Context, agentSpec types.StackAgentSpe
 	return outputs, nil
 }
 
+// newLLMClient creates an LLM client backed by the provider shims
+func newLLMClient(provider, endpoint, apiKey, model string) (types.CompletionClient, error) {
+	return shim.ShimFactory(provider, endpoint, apiKey, model)
+}
+
 // Stop cancels the execution of the stack
 func (e *Engine) Stop() {
 	if e.cancel != nil {
@@ -0,0 +1,80 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MCP server transports
+const (
+	// MCPTransportStdio runs the server as a child process speaking over stdio
+	MCPTransportStdio = "stdio"
+
+	// MCPTransportHTTP reaches the server over streamable HTTP
+	MCPTransportHTTP = "http"
+)
+
+// MCPServerConfig declares a Model Context Protocol server whose tools and
+// resources agents can use
+type MCPServerConfig struct {
+	// Name identifies the server; its tools are named <name>/<tool>
+	Name string `json:"name" yaml:"name"`
+
+	// Transport is stdio or http. It is inferred from Command or URL when
+	// empty.
+	Transport string `json:"transport,omitempty" yaml:"transport,omitempty"`
+
+	// Command and Args start a stdio server, with Env added to its
+	// environment
+	Command string            `json:"command,omitempty" yaml:"command,omitempty"`
+	Args    []string          `json:"args,omitempty" yaml:"args,omitempty"`
+	Env     map[string]string `json:"env,omitempty" yaml:"env,omitempty"`
+
+	// URL is the endpoint of an HTTP server, sent Headers with every request
+	URL     string            `json:"url,omitempty" yaml:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+
+	// Permission is the tool permission agents need to use the server's
+	// tools. It defaults to api.
+	Permission string `json:"permission,omitempty" yaml:"permission,omitempty"`
+
+	// Tools limits the tools used from the server; all are used when empty
+	Tools []string `json:"tools,omitempty" yaml:"tools,omitempty"`
+}
+
+// TransportType returns the transport of the server, inferring it from
+// Command or URL when Transport is not set
+func (c MCPServerConfig) TransportType() string {
+	if c.Transport != "" {
+		return strings.ToLower(c.Transport)
+	}
+	if c.URL != "" {
+		return MCPTransportHTTP
+	}
+	return MCPTransportStdio
+}
+
+// Validate checks that the server declaration is complete
+func (c MCPServerConfig) Validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("MCP server name is required")
+	}
+	if strings.ContainsAny(c.Name, "/ ") {
+		return fmt.Errorf("MCP server name %q must not contain slashes or spaces", c.Name)
+	}
+
+	switch c.TransportType() {
+	case MCPTransportStdio:
+		if c.Command == "" {
+			return fmt.Errorf("MCP server %s: command is required for the stdio transport", c.Name)
+		}
+	case MCPTransportHTTP:
+		if c.URL == "" {
+			return fmt.Errorf("MCP server %s: url is required for the http transport", c.Name)
+		}
+	default:
+		return fmt.Errorf("MCP server %s: unknown transport %q", c.Name, c.Transport)
+	}
+
+	return nil
+}
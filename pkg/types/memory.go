@@ -57,6 +57,16 @@ type EmbeddingConfig struct {
 	AdditionalOptions map[string]interface{}
 }
 
+// Embedder turns texts into vector embeddings
+type Embedder interface {
+	// Embed returns the embedding of each text, in order
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+
+	// Dimensions returns the dimensionality of the embeddings, or 0 while
+	// it is not yet known
+	Dimensions() int
+}
+
 // MemoryStore provides a generic interface for memory storage
 type MemoryStore interface {
 	// Save stores a value with the given key
@@ -96,6 +106,18 @@ type MemoryMatch struct {
 	Timestamp time.Time
 }
 
+// SimilarityResult is a stored vector found similar to a query vector
+type SimilarityResult struct {
+	// ID is the key the vector was stored under
+	ID string
+
+	// Score is the cosine similarity to the query
+	Score float32
+
+	// Metadata is the metadata stored with the vector
+	Metadata map[string]interface{}
+}
+
 // VectorStore provides a generic interface for vector storage
 type VectorStore interface {
 	// StoreVector stores a text with its vector embedding
@@ -117,6 +139,16 @@ type VectorStore interface {
 	Close() error
 }
 
+// HybridVectorStore is a VectorStore that can also rank texts by keyword
+// relevance
+type HybridVectorStore interface {
+	VectorStore
+
+	// SearchHybrid finds texts by a fusion of keyword relevance, weighted by
+	// keywordWeight from 0 to 1, and vector similarity
+	SearchHybrid(ctx context.Context, text string, limit int, filter map[string]interface{}, keywordWeight float64) ([]MemoryMatch, error)
+}
+
 // MemoryStoreFactory creates memory stores
 type MemoryStoreFactory interface {
 	// CreateMemoryStore creates a basic key-value memory store
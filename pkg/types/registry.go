@@ -23,6 +23,7 @@ type Dependency struct {
 	Version  string      `json:"version"`
 	Type     PackageType `json:"type"`
 	Required bool        `json:"required"`
+	Digest   string      `json:"digest,omitempty"`
 }
 
 // PackageInfo represents metadata about a package
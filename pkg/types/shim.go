@@ -103,6 +103,20 @@ func NewChunk(content *Content, isFinal bool) *Chunk {
 	}
 }
 
+// CompletionClient is the text completion subset of an LLM shim. Every
+// shim.LLMShim implementation satisfies it, so packages that cannot import
+// the shim package can still drive a model.
+type CompletionClient interface {
+	// CompletionWithContext generates a completion for the prompt
+	CompletionWithContext(ctx context.Context, prompt string, maxTokens int, temperature float64) (string, error)
+
+	// SetSystemPrompt sets the system prompt used for completions
+	SetSystemPrompt(prompt string)
+
+	// Close releases resources held by the client
+	Close() error
+}
+
 // LLMShimConfig represents configuration for an LLM shim
 type LLMShimConfig struct {
 	Provider string
@@ -74,6 +74,9 @@ const (
 
 	// AgentStatusBlocked indicates the agent is blocked on dependencies
 	AgentStatusBlocked AgentStatus = "blocked"
+
+	// AgentStatusSkipped indicates the agent was not executed because of an upstream failure
+	AgentStatusSkipped AgentStatus = "skipped"
 )
 
 // AgentState represents the state of an agent during execution
@@ -126,6 +129,9 @@ type StackExecutionSummary struct {
 	// BlockedCount is the number of blocked agents
 	BlockedCount int
 
+	// SkippedCount is the number of skipped agents
+	SkippedCount int
+
 	// AgentStates contains the state of each agent
 	AgentStates map[string]*AgentState
 }